@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/tool"
 )
 
 var (
@@ -28,6 +30,7 @@ to make automatic initialization process more smoothly`,
 			&subcmdRewriteAuthorizedKeys,
 			&subcmdSyncRepositoryHooks,
 			&subcmdReinitMissingRepositories,
+			&subcmdCollectLFSGarbage,
 		},
 	}
 
@@ -127,6 +130,17 @@ to make automatic initialization process more smoothly`,
 			stringFlag("config, c", "", "Custom configuration file path"),
 		},
 	}
+
+	subcmdCollectLFSGarbage = cli.Command{
+		Name:   "collect-lfs-garbage",
+		Usage:  "Delete LFS objects that are no longer referenced by any repository",
+		Action: runCollectLFSGarbage,
+		Flags: []cli.Flag{
+			boolFlag("dry-run", "Only report the reclaimable size without deleting anything"),
+			stringFlag("grace-period", "", "Keep objects uploaded within the duration, defaults to GRACE_PERIOD of [cron.lfs_garbage_collection]"),
+			stringFlag("config, c", "", "Custom configuration file path"),
+		},
+	}
 )
 
 func runCreateUser(ctx context.Context, cmd *cli.Command) error {
@@ -166,6 +180,46 @@ func runCreateUser(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+func runCollectLFSGarbage(ctx context.Context, cmd *cli.Command) error {
+	err := conf.Init(configFromLineage(cmd))
+	if err != nil {
+		return errors.Wrap(err, "init configuration")
+	}
+	conf.InitLogging(true)
+
+	if _, err = database.SetEngine(); err != nil {
+		return errors.Wrap(err, "set engine")
+	}
+
+	gracePeriod := conf.Cron.LFSGarbageCollection.GracePeriod
+	if cmd.IsSet("grace-period") {
+		gracePeriod, err = time.ParseDuration(cmd.String("grace-period"))
+		if err != nil {
+			return errors.Wrap(err, "parse grace period")
+		}
+	}
+
+	dryRun := cmd.Bool("dry-run")
+	garbage, err := database.Handle.LFS().CollectGarbage(ctx, database.LFSStoragers(),
+		database.CollectLFSGarbageOptions{
+			GracePeriod: gracePeriod,
+			DryRun:      dryRun,
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "collect garbage")
+	}
+
+	if dryRun {
+		fmt.Printf("Found %d unreferenced LFS object records, %d orphaned LFS objects, and %d unverified LFS uploads of %s reclaimable\n",
+			garbage.Records, garbage.Objects, garbage.Uploads, tool.FileSize(garbage.Size))
+		return nil
+	}
+	fmt.Printf("Deleted %d unreferenced LFS object records, %d orphaned LFS objects, and %d unverified LFS uploads of %s\n",
+		garbage.Records, garbage.Objects, garbage.Uploads, tool.FileSize(garbage.Size))
+	return nil
+}
+
 func adminDashboardOperation(operation func() error, successMessage string) func(context.Context, *cli.Command) error {
 	return func(_ context.Context, cmd *cli.Command) error {
 		err := conf.Init(configFromLineage(cmd))
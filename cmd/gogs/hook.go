@@ -67,7 +67,11 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 	setup(cmd, "pre-receive.log", true)
 
 	isWiki := strings.Contains(os.Getenv(database.EnvRepoCustomHooksPath), ".wiki.git/")
+	repoID, _ := strconv.ParseInt(os.Getenv(database.EnvRepoID), 10, 64)
+	userID, _ := strconv.ParseInt(os.Getenv(database.EnvAuthUserID), 10, 64)
+	repoPath := database.RepoPath(os.Getenv(database.EnvRepoOwnerName), os.Getenv(database.EnvRepoName))
 
+	var lfsLocks []*database.LFSLock
 	buf := bytes.NewBuffer(nil)
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
@@ -86,8 +90,19 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 		newCommitID := string(fields[1])
 		branchName := git.RefShortName(string(fields[2]))
 
+		// LFS locks
+		if newCommitID != git.EmptyID {
+			if lfsLocks == nil {
+				var err error
+				lfsLocks, err = database.Handle.LFS().ListLocks(context.Background(), repoID, database.ListLFSLocksOptions{})
+				if err != nil {
+					fail("Internal error", "Failed to list LFS locks [repo_id: %d]: %v", repoID, err)
+				}
+			}
+			checkLFSLocks(repoPath, userID, newCommitID, lfsLocks)
+		}
+
 		// Branch protection
-		repoID, _ := strconv.ParseInt(os.Getenv(database.EnvRepoID), 10, 64)
 		protectBranch, err := database.GetProtectBranchOfRepoByName(repoID, branchName)
 		if err != nil {
 			if database.IsErrBranchNotExist(err) {
@@ -103,7 +118,6 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 		bypassRequirePullRequest := false
 
 		// Check if user is in whitelist when enabled
-		userID, _ := strconv.ParseInt(os.Getenv(database.EnvAuthUserID), 10, 64)
 		if protectBranch.EnableWhitelist {
 			if !database.IsUserInProtectBranchWhitelist(repoID, userID, branchName) {
 				fail(fmt.Sprintf("Branch '%s' is protected and you are not in the push whitelist", branchName), "")
@@ -124,7 +138,7 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 
 		// Check force push
 		output, err := git.NewCommand("rev-list", "--max-count=1", oldCommitID, "^"+newCommitID).
-			RunInDir(database.RepoPath(os.Getenv(database.EnvRepoOwnerName), os.Getenv(database.EnvRepoName)))
+			RunInDir(repoPath)
 		if err != nil {
 			fail("Internal error", "Failed to detect force push: %v", err)
 		} else if len(output) > 0 {
@@ -143,7 +157,7 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 	} else {
 		hookCmd = exec.Command(customHooksPath)
 	}
-	hookCmd.Dir = database.RepoPath(os.Getenv(database.EnvRepoOwnerName), os.Getenv(database.EnvRepoName))
+	hookCmd.Dir = repoPath
 	hookCmd.Stdout = os.Stdout
 	hookCmd.Stdin = buf
 	hookCmd.Stderr = os.Stderr
@@ -153,6 +167,34 @@ func runHookPreReceive(_ context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+// checkLFSLocks fails the push when any of the commits that are new to the
+// repository modifies a file locked by someone other than the pusher.
+func checkLFSLocks(repoPath string, userID int64, newCommitID string, locks []*database.LFSLock) {
+	lockedByOthers := make(map[string]bool, len(locks))
+	for _, lock := range locks {
+		if lock.OwnerID != userID {
+			lockedByOthers[lock.Path] = true
+		}
+	}
+	if len(lockedByOthers) == 0 {
+		return
+	}
+
+	// NOTE: References are not updated until the pre-receive hook succeeds, thus
+	// "--not --all" excludes exactly the commits that already exist.
+	output, err := git.NewCommand("log", "--format=", "--name-only", "--no-renames", "-z", newCommitID, "--not", "--all").
+		RunInDir(repoPath)
+	if err != nil {
+		fail("Internal error", "Failed to list modified files: %v", err)
+	}
+	for _, name := range strings.Split(string(output), "\x00") {
+		name = strings.Trim(name, "\n")
+		if lockedByOthers[name] {
+			fail(fmt.Sprintf("File '%s' is locked by another user", name), "")
+		}
+	}
+}
+
 func runHookUpdate(_ context.Context, cmd *cli.Command) error {
 	if os.Getenv("SSH_ORIGINAL_COMMAND") == "" {
 		return nil
@@ -232,6 +274,7 @@ func runHookPostReceive(_ context.Context, cmd *cli.Command) error {
 		// Ask for running deliver hook and test pull request tasks
 		q := make(url.Values)
 		q.Add("branch", git.RefShortName(options.FullRefspec))
+		q.Add("commit", options.NewCommitID)
 		q.Add("secret", os.Getenv(database.EnvRepoOwnerSaltMd5))
 		q.Add("pusher", os.Getenv(database.EnvAuthUserID))
 		reqURL := fmt.Sprintf("%s%s/%s/tasks/trigger?%s", conf.Server.LocalRootURL, options.RepoUserName, options.RepoName, q.Encode())
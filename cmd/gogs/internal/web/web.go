@@ -110,6 +110,12 @@ func Run(configPath string, portOverride int) error {
 				m.Combo("/two_factor_recovery_codes").Get(user.SettingsTwoFactorRecoveryCodes).
 					Post(user.SettingsTwoFactorRecoveryCodesPost)
 				m.Post("/two_factor_disable", user.SettingsTwoFactorDisable)
+				m.Post("/webauthn/delete", user.SettingsWebAuthnCredentialDelete)
+			})
+			m.Group("/sessions", func() {
+				m.Get("", user.SettingsSessions)
+				m.Post("/revoke", user.SettingsSessionRevoke)
+				m.Post("/revoke_others", user.SettingsSessionsRevokeOthers)
 			})
 			m.Group("/repositories", func() {
 				m.Get("", user.SettingsRepos)
@@ -124,11 +130,24 @@ func Run(configPath string, portOverride int) error {
 			m.Combo("/applications").Get(settingsHandler.Applications()).
 				Post(bindIgnErr(form.NewAccessToken{}), settingsHandler.ApplicationsPost())
 			m.Post("/applications/delete", settingsHandler.DeleteApplication())
+			m.Post("/applications/revoke", settingsHandler.RevokeOAuth2Grant())
+			m.Group("/oauth2", func() {
+				m.Combo("").Get(user.SettingsOAuth2Applications).
+					Post(bindIgnErr(form.OAuth2Application{}), user.SettingsOAuth2ApplicationsPost)
+				m.Post("/delete", user.SettingsDeleteOAuth2Application)
+				m.Combo("/:id").Get(user.SettingsOAuth2Application).
+					Post(bindIgnErr(form.OAuth2Application{}), user.SettingsOAuth2ApplicationPost)
+				m.Post("/:id/regenerate_secret", user.SettingsOAuth2ApplicationRegenerateSecret)
+			})
 			m.Route("/delete", "GET,POST", user.SettingsDelete)
 		}, reqSignIn, func(c *context.Context) {
 			c.Data["PageIsUserSettings"] = true
 		})
 
+		m.Combo("/login/oauth/authorize", reqSignIn).
+			Get(user.OAuth2Authorize).
+			Post(user.OAuth2AuthorizePost)
+
 		m.Group("/user", func() {
 			m.Any("/activate_email", user.ActivateEmail)
 			m.Get("/email2user", user.Email2User)
@@ -148,6 +167,7 @@ func Run(configPath string, portOverride int) error {
 				m.Get("", admin.Users)
 				m.Combo("/new").Get(admin.NewUser).Post(bindIgnErr(form.AdminCrateUser{}), admin.NewUserPost)
 				m.Combo("/:userid").Get(admin.EditUser).Post(bindIgnErr(form.AdminEditUser{}), admin.EditUserPost)
+				m.Post("/:userid/sessions/revoke", admin.RevokeUserSessions)
 				m.Post("/:userid/delete", admin.DeleteUser)
 			})
 
@@ -173,6 +193,16 @@ func Run(configPath string, portOverride int) error {
 				m.Post("/delete", admin.DeleteNotices)
 				m.Get("/empty", admin.EmptyNotices)
 			})
+
+			m.Group("/audit_logs", func() {
+				m.Get("", admin.AuditLogs)
+				m.Get("/export", admin.ExportAuditLogs)
+			})
+
+			m.Group("/lockouts", func() {
+				m.Get("", admin.Lockouts)
+				m.Post("/clear", admin.ClearLockout)
+			})
 		}, reqAdmin)
 		// ***** END: Admin *****
 
@@ -374,6 +404,13 @@ func Run(configPath string, portOverride int) error {
 						Post(bindIgnErr(form.AddSSHKey{}), repo.SettingsDeployKeysPost)
 					m.Post("/delete", repo.DeleteDeployKey)
 				})
+
+				m.Group("/push_mirrors", func() {
+					m.Combo("").Get(repo.SettingsPushMirrors).
+						Post(bindIgnErr(form.AddPushMirror{}), repo.SettingsPushMirrorsPost)
+					m.Post("/delete", repo.DeletePushMirror)
+					m.Post("/sync", repo.SyncPushMirror)
+				})
 			}, func(c *context.Context) {
 				c.Data["PageIsSettings"] = true
 			})
@@ -513,6 +550,19 @@ func Run(configPath string, portOverride int) error {
 				m.Get("/commits", context.RepoRef(), repo.ViewPullCommits)
 				m.Get("/files", context.RepoRef(), repo.ViewPullFiles)
 				m.Post("/merge", reqRepoWriter, repo.MergePullRequest)
+				m.Group("/merge_queue", func() {
+					m.Post("", repo.AddToMergeQueue)
+					m.Post("/remove", repo.RemoveFromMergeQueue)
+				}, reqRepoWriter)
+				m.Group("/auto_merge", func() {
+					m.Post("", repo.EnableAutoMerge)
+					m.Post("/cancel", repo.CancelAutoMerge)
+				}, reqSignIn)
+				m.Group("", func() {
+					m.Post("/files/comments", bindIgnErr(form.CreateCodeComment{}), repo.CreateCodeComment)
+					m.Post("/reviews", bindIgnErr(form.SubmitReview{}), repo.SubmitReview)
+					m.Post("/reviews/request", repo.RequestReview)
+				}, reqSignIn)
 			}, repo.MustAllowPulls)
 
 			m.Group("", func() {
@@ -544,7 +594,7 @@ func Run(configPath string, portOverride int) error {
 			apiv1.RegisterRoutes(m)
 		}, ignSignIn)
 
-		m.Any("/api/web/*", flamegoBridger(webHandler))
+		m.Any("/api/web/*", webAPIBrowserOnly, flamegoBridger(webHandler))
 		m.Get("/redirect", flamegoBridger(webHandler))
 		m.Get("/captcha/*", flamegoBridger(webHandler))
 		m.Any("/*", func(c *context.Context) { c.ServeWeb() })
@@ -587,6 +637,22 @@ func Run(configPath string, portOverride int) error {
 		m.Route("/objects/pack/pack-:sha([0-9a-f]{40}).idx", "GET,OPTIONS", gitHTTP...)
 	})
 
+	// ******************************
+	// ----- OAuth2 token route -----
+	// ******************************
+
+	// The token endpoint authenticates applications rather than users, thus
+	// without session.
+	m.Post("/login/oauth/access_token", user.OAuth2AccessToken)
+
+	// ****************************
+	// ----- SAML relay route -----
+	// ****************************
+
+	// Identity providers post SAML responses cross-site, which come without the
+	// session cookie, thus without session to not replace the cookie.
+	m.Post("/user/saml/:id([0-9]+)/acs", user.SAMLAssertionConsumerService)
+
 	// ***************************
 	// ----- Internal routes -----
 	// ***************************
@@ -932,8 +998,10 @@ func initServices(customConf string) error {
 
 	cron.NewContext()
 	database.InitSyncMirrors()
+	database.InitSyncPushMirrors()
 	database.InitDeliverHooks()
 	database.InitTestPullRequests()
+	database.InitMergeQueues()
 
 	if conf.HasMinWinSvc {
 		log.Info("Builtin Windows Service is supported")
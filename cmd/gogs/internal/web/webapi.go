@@ -25,6 +25,7 @@ type (
 	webAPIUserKey    struct{}
 	webAPISessionKey struct{}
 	webAPIMacaronKey struct{}
+	webAPIContextKey struct{}
 	webAPILocaleKey  struct{}
 )
 
@@ -34,18 +35,31 @@ func flamegoBridger(webHandler http.Handler) func(c *context.Context, l i18n.Loc
 		ctx = stdctx.WithValue(ctx, webAPIUserKey{}, c.User)
 		ctx = stdctx.WithValue(ctx, webAPISessionKey{}, c.Session)
 		ctx = stdctx.WithValue(ctx, webAPIMacaronKey{}, c.Context)
+		ctx = stdctx.WithValue(ctx, webAPIContextKey{}, c)
 		ctx = stdctx.WithValue(ctx, webAPILocaleKey{}, l)
 		webHandler.ServeHTTP(c.Resp, c.Req.WithContext(ctx))
 	}
 }
 
+// webAPIBrowserOnly rejects requests to the web API that are authenticated
+// with HTTP Basic Authentication or an access token, as the web API is only
+// meant to be called by the web frontend on behalf of a signed-in browser.
+func webAPIBrowserOnly(c *context.Context) {
+	if c.IsBasicAuth || c.IsTokenAuth {
+		c.JSON(http.StatusUnauthorized, map[string]string{
+			"message": "The web API does not accept basic authentication or access tokens.",
+		})
+	}
+}
+
 func flamegoInjector(c flamego.Context) {
 	ctx := c.Request().Context()
 	user, _ := ctx.Value(webAPIUserKey{}).(*database.User)
 	sess, _ := ctx.Value(webAPISessionKey{}).(macaronsession.Store)
 	mc, _ := ctx.Value(webAPIMacaronKey{}).(*macaron.Context)
+	cc, _ := ctx.Value(webAPIContextKey{}).(*context.Context)
 	l, _ := ctx.Value(webAPILocaleKey{}).(i18n.Locale)
-	c.Map(user, sess, mc, l)
+	c.Map(user, sess, mc, cc, l)
 	c.MapTo(flamegoSessionAdapter{sess: sess}, (*session.Session)(nil))
 }
 
@@ -70,6 +84,11 @@ func enforceWebAPIMaxBodySize(c flamego.Context) {
 	r.Body = http.MaxBytesReader(c.ResponseWriter(), r.Body, 4*1024) // 4 KiB
 }
 
+func enforceSAMLMaxBodySize(c flamego.Context) {
+	r := c.Request().Request
+	r.Body = http.MaxBytesReader(c.ResponseWriter(), r.Body, 2*1024*1024) // 2 MiB
+}
+
 // webAPIValidator is the shared validator instance used by every webapi
 // binding. Registering the json-tag name function makes validation errors
 // carry the wire field name (e.g. "recoveryCode") via ve.Field(), so the
@@ -121,14 +140,32 @@ func mountWebAPIRoutes(f *flamego.Flame) {
 					Post(bindJSON(userResetPasswordEmailRequest{}), postUserResetPassword)
 				f.Post("/complete", bindJSON(userResetPasswordCompleteRequest{}), postUserResetPasswordComplete)
 			})
-			f.Combo("/sign-in").
-				Get(getUserSignIn).
-				Post(bindJSON(userSignInRequest{}), postUserSignIn)
+			f.Group("/sign-in", func() {
+				f.Combo("").
+					Get(getUserSignIn).
+					Post(bindJSON(userSignInRequest{}), postUserSignIn)
+				f.Post("/passkey/options", postUserSignInPasskeyOptions)
+				f.Post("/passkey", bindJSON(webAuthnAssertion{}), postUserSignInPasskey)
+			})
+			f.Group("/oidc/{id}", func() {
+				f.Get("", getUserOIDC)
+				f.Get("/callback", getUserOIDCCallback)
+			})
+			f.Group("/saml/{id}", func() {
+				f.Get("", getUserSAML)
+				f.Get("/metadata", getUserSAMLMetadata)
+			})
 			f.Group("/mfa", func() {
 				f.Combo("").
 					Get(getUserMFA).
 					Post(bindJSON(userMFARequest{}), postUserMFA)
 				f.Post("/recovery", bindJSON(userMFARecoveryRequest{}), postUserMFARecovery)
+				f.Group("/webauthn", func() {
+					f.Post("", bindJSON(webAuthnAssertion{}), postUserMFAWebAuthn)
+					f.Post("/options", postUserMFAWebAuthnOptions)
+					f.Post("/credentials", bindJSON(userMFAWebAuthnCredentialRequest{}), postUserMFAWebAuthnCredential)
+					f.Post("/credentials/options", bindJSON(userMFAWebAuthnCredentialOptionsRequest{}), postUserMFAWebAuthnCredentialOptions)
+				})
 			})
 			f.Group("/activate", func() {
 				f.Combo("").
@@ -145,6 +182,10 @@ func mountWebAPIRoutes(f *flamego.Flame) {
 			f.Combo("/star").Post(postRepoStar).Delete(deleteRepoStar)
 		}, withRepoContext)
 	}, enforceWebAPIMaxBodySize)
+
+	// SAML responses are relayed from the identity provider and may carry
+	// certificates and encrypted assertions that exceed the common limit.
+	f.Post("/api/web/user/saml/{id}/acs", enforceSAMLMaxBodySize, postUserSAMLACS)
 }
 
 // fieldErrors maps JSON field names to per-field localized messages. A non-nil
@@ -1,9 +1,13 @@
 package web
 
 import (
+	"bytes"
 	stdctx "context"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
@@ -11,6 +15,7 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/flamego/cache"
 	"github.com/flamego/captcha"
+	"github.com/flamego/flamego"
 	"github.com/flamego/session"
 	"github.com/go-macaron/i18n"
 	macaronsession "github.com/go-macaron/session"
@@ -18,11 +23,15 @@ import (
 	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/auth"
+	"gogs.io/gogs/internal/auth/oidc"
+	"gogs.io/gogs/internal/auth/saml"
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/database"
 	"gogs.io/gogs/internal/email"
 	"gogs.io/gogs/internal/tool"
 	"gogs.io/gogs/internal/userx"
+	"gogs.io/gogs/internal/webauthn"
 )
 
 func parseUserFromCode(ctx stdctx.Context, code string) (user *database.User) {
@@ -60,6 +69,12 @@ type loginSource struct {
 
 type getUserSignInResponse struct {
 	LoginSources []loginSource `json:"loginSources"`
+	// OIDCSources are login sources that users sign in through by being
+	// redirected to the OpenID Provider instead of with a password.
+	OIDCSources []loginSource `json:"oidcSources"`
+	// SAMLSources are login sources that users sign in through by being
+	// redirected to the SAML identity provider instead of with a password.
+	SAMLSources []loginSource `json:"samlSources"`
 }
 
 type getUserSignUpResponse struct {
@@ -164,10 +179,19 @@ func getUserSignIn(r *http.Request) (statusCode int, resp *getUserSignInResponse
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "list activated login sources")
 	}
 	loginSources := make([]loginSource, 0, len(sources))
+	oidcSources := make([]loginSource, 0)
+	samlSources := make([]loginSource, 0)
 	for _, s := range sources {
+		if s.IsOIDC() {
+			oidcSources = append(oidcSources, loginSource{ID: s.ID, Name: s.Name})
+			continue
+		} else if s.IsSAML() {
+			samlSources = append(samlSources, loginSource{ID: s.ID, Name: s.Name})
+			continue
+		}
 		loginSources = append(loginSources, loginSource{ID: s.ID, Name: s.Name, IsDefault: s.IsDefault})
 	}
-	return http.StatusOK, &getUserSignInResponse{LoginSources: loginSources}, nil
+	return http.StatusOK, &getUserSignInResponse{LoginSources: loginSources, OIDCSources: oidcSources, SAMLSources: samlSources}, nil
 }
 
 type userSignInRequest struct {
@@ -252,6 +276,10 @@ func postUserResetPasswordComplete(r *http.Request, l i18n.Locale, req userReset
 		log.Error("postUserResetPasswordComplete: update password for user %q: %v", u.Name, err)
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "update user")
 	}
+	if _, err := database.Handle.LoginSessions().DeleteByUserID(r.Context(), u.ID, 0); err != nil {
+		log.Error("postUserResetPasswordComplete: revoke login sessions of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "revoke login sessions")
+	}
 
 	log.Trace("User password reset: %s", u.Name)
 	return http.StatusNoContent, nil, nil
@@ -265,50 +293,331 @@ type userSignInResponse struct {
 }
 
 func postUserSignIn(r *http.Request, sess session.Session, mc *macaron.Context, l i18n.Locale, req userSignInRequest) (statusCode int, resp any, err error) {
+	if wait := context.ReserveLoginAttempt(r, req.Username); wait > 0 {
+		context.SetRetryAfter(mc.Resp.Header(), wait)
+		return http.StatusTooManyRequests, &bindingErrorResponse{Error: l.Tr("auth.too_many_login_failures")}, nil
+	}
+
 	u, err := database.Handle.Users().Authenticate(r.Context(), req.Username, req.Password, req.LoginSource)
 	if err != nil {
 		switch {
 		case auth.IsErrBadCredentials(err):
+			context.AuditLoginFailed(r, 0, req.Username, "bad credentials")
 			return http.StatusUnauthorized, &bindingErrorResponse{
 				Error:  l.Tr("form.username_password_incorrect"),
 				Fields: fieldErrors{"username": nil, "password": nil},
 			}, nil
 		case database.IsErrLoginSourceMismatch(err):
+			context.ReleaseLoginAttempt(r, req.Username)
 			return http.StatusUnprocessableEntity, nil, errors.New(l.Tr("form.auth_source_mismatch"))
 		default:
+			context.ReleaseLoginAttempt(r, req.Username)
 			log.Error("postUserSignIn: authenticate user %q: %v", req.Username, err)
 			return http.StatusInternalServerError, nil, errors.Wrap(err, "authenticate user")
 		}
 	}
+	context.ResetLoginFailures(r, req.Username)
 
-	if database.Handle.TwoFactors().IsEnabled(r.Context(), u.ID) {
+	if isMFAEnabled(r.Context(), u.ID) {
 		sess.Set("mfaUserID", u.ID)
 		return http.StatusOK, &userSignInResponse{MFA: true}, nil
 	}
 
-	completeSignIn(sess, mc, u)
+	if err := completeSignIn(r, sess, mc, u, "password"); err != nil {
+		log.Error("postUserSignIn: complete sign-in of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
 	return http.StatusOK, &userSignInResponse{}, nil
 }
 
-// completeSignIn finalizes the sign-in session for u: writes the auth session,
-// clears any in-flight MFA state, and sets the login-status cookie. The
-// caller is responsible for navigating to a post-login destination via
-// /redirect?to=.
-func completeSignIn(sess session.Session, mc *macaron.Context, u *database.User) {
+// isMFAEnabled returns true if the user has enabled any second factor, which
+// is either a TOTP token or WebAuthn credentials.
+func isMFAEnabled(ctx stdctx.Context, userID int64) bool {
+	return database.Handle.TwoFactors().IsEnabled(ctx, userID) ||
+		database.Handle.WebAuthnCredentials().IsEnabled(ctx, userID)
+}
+
+// completeSignIn finalizes the sign-in session for u: tracks the login session,
+// writes the auth session, clears any in-flight MFA state, sets the
+// login-status cookie, and records the sign-in with the method in the audit
+// log. The caller is responsible for navigating to a post-login destination
+// via /redirect?to=.
+func completeSignIn(r *http.Request, sess session.Session, mc *macaron.Context, u *database.User, method string) error {
+	key, err := database.Handle.LoginSessions().Create(
+		r.Context(),
+		u.ID,
+		database.CreateLoginSessionOptions{
+			UserAgent: r.UserAgent(),
+			IPAddress: context.ClientIP(r),
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "create login session")
+	}
+
+	context.AuditLog(r, u.ID, u.Name, database.AuditActionUserLogin, database.AuditTargetOfUser(u), "via "+method)
+
 	sess.Set("uid", u.ID)
 	sess.Set("uname", u.Name)
+	sess.Set("loginSessionKey", key)
 	sess.Delete("mfaUserID")
 
 	if conf.Security.EnableLoginStatusCookie {
 		mc.SetCookie(conf.Security.LoginStatusCookieName, "true", 0, conf.Server.Subpath)
 	}
+	return nil
+}
+
+// oidcRedirectURI returns the redirect URI of the OpenID Connect login source,
+// which must be registered with the OpenID Provider.
+func oidcRedirectURI(sourceID int64) string {
+	return conf.Server.ExternalURL + "api/web/user/oidc/" + strconv.FormatInt(sourceID, 10) + "/callback"
+}
+
+// externalSignInFailed redirects back to the sign-in page to show the error of
+// signing in through an external identity provider.
+func externalSignInFailed(c flamego.Context, reason string) {
+	c.Redirect(conf.Server.Subpath+"/user/sign-in?error="+reason, http.StatusSeeOther)
+}
+
+// getExternalLoginSource returns the login source of the given type with the ID
+// in the path, or nil if there is no such login source. Only activated login
+// sources are returned unless includeInactive is true.
+func getExternalLoginSource(c flamego.Context, typ auth.Type, includeInactive bool) *database.LoginSource {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	source, err := database.Handle.LoginSources().GetByID(c.Request().Context(), id)
+	if err != nil {
+		if !database.IsErrLoginSourceNotExist(err) {
+			log.Error("getExternalLoginSource: get login source %d: %v", id, err)
+		}
+		return nil
+	} else if (!source.IsActived && !includeInactive) || source.Type != typ {
+		return nil
+	}
+	return source
+}
+
+// signInExternalAccount signs in the user that is associated with the external
+// account authenticated by the login source, or asks for the second factor
+// first when enabled, and redirects to the given location afterwards.
+func signInExternalAccount(c flamego.Context, sess session.Session, mc *macaron.Context, sourceID int64, extAccount *auth.ExternalAccount, redirectTo string) error {
+	ctx := c.Request().Context()
+	u, err := database.Handle.Users().AuthenticateByExternalAccount(ctx, sourceID, extAccount)
+	if err != nil {
+		return err
+	}
+
+	if isMFAEnabled(ctx, u.ID) {
+		sess.Set("mfaUserID", u.ID)
+		to := conf.Server.Subpath + "/user/mfa"
+		if redirectTo != "" {
+			to += "?redirect_to=" + url.QueryEscape(redirectTo)
+		}
+		c.Redirect(to, http.StatusSeeOther)
+		return nil
+	}
+
+	if err = completeSignIn(c.Request().Request, sess, mc, u, "external account"); err != nil {
+		return err
+	}
+	c.Redirect(conf.Server.Subpath+"/redirect?to="+url.QueryEscape(redirectTo), http.StatusSeeOther)
+	return nil
+}
+
+// getUserOIDC starts the authorization code flow of the OpenID Connect login
+// source by redirecting the user to the OpenID Provider.
+func getUserOIDC(c flamego.Context, sess session.Session) {
+	source := getExternalLoginSource(c, auth.OIDC, false)
+	if source == nil {
+		externalSignInFailed(c, "oidc_failed")
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString()
+		if err != nil {
+			log.Error("getUserOIDC: generate random string: %v", err)
+			externalSignInFailed(c, "oidc_failed")
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	authCodeURL, err := source.OIDC().AuthCodeURL(c.Request().Context(), oidcRedirectURI(source.ID), state, nonce, codeVerifier)
+	if err != nil {
+		log.Error("getUserOIDC: get authorization URL of login source %d: %v", source.ID, err)
+		externalSignInFailed(c, "oidc_failed")
+		return
+	}
+
+	sess.Set("oidcSourceID", source.ID)
+	sess.Set("oidcState", state)
+	sess.Set("oidcNonce", nonce)
+	sess.Set("oidcCodeVerifier", codeVerifier)
+	sess.Set("oidcRedirectTo", c.Query("redirect_to"))
+	c.Redirect(authCodeURL, http.StatusSeeOther)
+}
+
+// getUserOIDCCallback completes the authorization code flow of the OpenID
+// Connect login source, and signs in the user that is associated with the
+// external account.
+func getUserOIDCCallback(c flamego.Context, sess session.Session, mc *macaron.Context) {
+	sourceID, _ := sess.Get("oidcSourceID").(int64)
+	state, _ := sess.Get("oidcState").(string)
+	nonce, _ := sess.Get("oidcNonce").(string)
+	codeVerifier, _ := sess.Get("oidcCodeVerifier").(string)
+	redirectTo, _ := sess.Get("oidcRedirectTo").(string)
+	// The flow can only be completed once.
+	for _, key := range []string{"oidcSourceID", "oidcState", "oidcNonce", "oidcCodeVerifier", "oidcRedirectTo"} {
+		sess.Delete(key)
+	}
+
+	source := getExternalLoginSource(c, auth.OIDC, false)
+	if source == nil || source.ID != sourceID || state == "" || c.Query("state") != state {
+		externalSignInFailed(c, "oidc_failed")
+		return
+	} else if errCode := c.Query("error"); errCode != "" {
+		log.Trace("OpenID Provider of login source %d returned an error: %s %s", source.ID, errCode, c.Query("error_description"))
+		externalSignInFailed(c, "oidc_failed")
+		return
+	}
+
+	ctx := c.Request().Context()
+	extAccount, err := source.OIDC().Exchange(ctx, oidcRedirectURI(source.ID), c.Query("code"), codeVerifier, nonce)
+	if err != nil {
+		log.Error("getUserOIDCCallback: exchange code of login source %d: %v", source.ID, err)
+		externalSignInFailed(c, "oidc_failed")
+		return
+	}
+
+	err = signInExternalAccount(c, sess, mc, source.ID, extAccount, redirectTo)
+	if err != nil {
+		if database.IsErrUserAlreadyExist(err) || database.IsErrEmailAlreadyUsed(err) {
+			externalSignInFailed(c, "oidc_user_exists")
+			return
+		}
+		log.Error("getUserOIDCCallback: authenticate external account %q: %v", extAccount.Login, err)
+		externalSignInFailed(c, "oidc_failed")
+	}
+}
+
+// samlEndpoints returns the endpoints of Gogs as the service provider of the
+// SAML login source, which are registered with the identity provider through
+// the service provider metadata.
+func samlEndpoints(sourceID int64) saml.Endpoints {
+	id := strconv.FormatInt(sourceID, 10)
+	return saml.Endpoints{
+		MetadataURL: conf.Server.ExternalURL + "api/web/user/saml/" + id + "/metadata",
+		// Identity providers post to the assertion consumer service cross-site,
+		// which is relayed to postUserSAMLACS by user.SAMLAssertionConsumerService.
+		ACSURL: conf.Server.ExternalURL + "user/saml/" + id + "/acs",
+	}
 }
 
-func getUserMFA(sess session.Session) (statusCode int, resp any, err error) {
-	if _, ok := sess.Get("mfaUserID").(int64); !ok {
+// getUserSAML starts the SAML web browser SSO profile of the SAML login source
+// by redirecting the user to the identity provider with an authentication
+// request.
+func getUserSAML(c flamego.Context, sess session.Session) {
+	source := getExternalLoginSource(c, auth.SAML, false)
+	if source == nil {
+		externalSignInFailed(c, "saml_failed")
+		return
+	}
+
+	requestID, err := saml.NewRequestID()
+	if err != nil {
+		log.Error("getUserSAML: generate request ID: %v", err)
+		externalSignInFailed(c, "saml_failed")
+		return
+	}
+	authnRequestURL, err := source.SAML().AuthnRequestURL(samlEndpoints(source.ID), requestID, time.Now())
+	if err != nil {
+		log.Error("getUserSAML: get authentication request URL of login source %d: %v", source.ID, err)
+		externalSignInFailed(c, "saml_failed")
+		return
+	}
+
+	sess.Set("samlSourceID", source.ID)
+	sess.Set("samlRequestID", requestID)
+	sess.Set("samlRedirectTo", c.Query("redirect_to"))
+	c.Redirect(authnRequestURL, http.StatusSeeOther)
+}
+
+// getUserSAMLMetadata serves the service provider metadata of the SAML login
+// source, which is also available before the login source is activated.
+func getUserSAMLMetadata(c flamego.Context) {
+	w := c.ResponseWriter()
+	source := getExternalLoginSource(c, auth.SAML, true)
+	if source == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	metadata, err := source.SAML().Metadata(samlEndpoints(source.ID))
+	if err != nil {
+		log.Error("getUserSAMLMetadata: get metadata of login source %d: %v", source.ID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(metadata)
+}
+
+// postUserSAMLACS verifies the SAML response to the authentication request of
+// the SAML login source, and signs in the user that is associated with the
+// external account.
+func postUserSAMLACS(c flamego.Context, sess session.Session, mc *macaron.Context) {
+	sourceID, _ := sess.Get("samlSourceID").(int64)
+	requestID, _ := sess.Get("samlRequestID").(string)
+	redirectTo, _ := sess.Get("samlRedirectTo").(string)
+	// Each authentication request can only be responded to once.
+	for _, key := range []string{"samlSourceID", "samlRequestID", "samlRedirectTo"} {
+		sess.Delete(key)
+	}
+
+	source := getExternalLoginSource(c, auth.SAML, false)
+	if source == nil || source.ID != sourceID || requestID == "" {
+		externalSignInFailed(c, "saml_failed")
+		return
+	}
+
+	extAccount, err := source.SAML().ParseResponse(samlEndpoints(source.ID), requestID, c.Request().PostFormValue("SAMLResponse"), time.Now())
+	if err != nil {
+		log.Error("postUserSAMLACS: parse response of login source %d: %v", source.ID, err)
+		externalSignInFailed(c, "saml_failed")
+		return
+	}
+
+	err = signInExternalAccount(c, sess, mc, source.ID, extAccount, redirectTo)
+	if err != nil {
+		if database.IsErrUserAlreadyExist(err) || database.IsErrEmailAlreadyUsed(err) {
+			externalSignInFailed(c, "saml_user_exists")
+			return
+		}
+		log.Error("postUserSAMLACS: authenticate external account %q: %v", extAccount.Login, err)
+		externalSignInFailed(c, "saml_failed")
+	}
+}
+
+type getUserMFAResponse struct {
+	// Passcode is true when the user can verify with a TOTP passcode or a
+	// recovery code.
+	Passcode bool `json:"passcode"`
+	// SecurityKey is true when the user can verify with a WebAuthn credential.
+	SecurityKey bool `json:"securityKey"`
+}
+
+func getUserMFA(r *http.Request, sess session.Session) (statusCode int, resp any, err error) {
+	userID, ok := sess.Get("mfaUserID").(int64)
+	if !ok {
 		return http.StatusNotFound, nil, nil
 	}
-	return http.StatusNoContent, nil, nil
+	return http.StatusOK, &getUserMFAResponse{
+		Passcode:    database.Handle.TwoFactors().IsEnabled(r.Context(), userID),
+		SecurityKey: database.Handle.WebAuthnCredentials().IsEnabled(r.Context(), userID),
+	}, nil
 }
 
 type userMFARequest struct {
@@ -325,6 +634,14 @@ func postUserMFA(r *http.Request, sess session.Session, mc *macaron.Context, ca
 
 	t, err := database.Handle.TwoFactors().GetByUserID(r.Context(), userID)
 	if err != nil {
+		// The user may have only registered WebAuthn credentials.
+		if database.IsErrTwoFactorNotFound(err) {
+			context.AuditLoginFailed(r, userID, "", "invalid two-factor passcode")
+			msg := l.Tr("auth.mfa_invalid_passcode")
+			return http.StatusUnauthorized, &bindingErrorResponse{
+				Fields: fieldErrors{"passcode": &msg},
+			}, nil
+		}
 		log.Error("postUserMFA: get two factor by user ID %d: %v", userID, err)
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "get two factor by user ID")
 	}
@@ -335,6 +652,7 @@ func postUserMFA(r *http.Request, sess session.Session, mc *macaron.Context, ca
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "validate TOTP")
 	}
 	if !valid {
+		context.AuditLoginFailed(r, userID, "", "invalid two-factor passcode")
 		msg := l.Tr("auth.mfa_invalid_passcode")
 		return http.StatusUnauthorized, &bindingErrorResponse{
 			Fields: fieldErrors{"passcode": &msg},
@@ -343,6 +661,7 @@ func postUserMFA(r *http.Request, sess session.Session, mc *macaron.Context, ca
 
 	cacheKey := userx.TwoFactorCacheKey(userID, req.Passcode)
 	if _, err := ca.Get(r.Context(), cacheKey); err == nil {
+		context.AuditLoginFailed(r, userID, "", "reused two-factor passcode")
 		msg := l.Tr("auth.mfa_reused_passcode")
 		return http.StatusUnauthorized, &bindingErrorResponse{
 			Fields: fieldErrors{"passcode": &msg},
@@ -360,7 +679,10 @@ func postUserMFA(r *http.Request, sess session.Session, mc *macaron.Context, ca
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "get user by ID")
 	}
 
-	completeSignIn(sess, mc, u)
+	if err := completeSignIn(r, sess, mc, u, "two-factor passcode"); err != nil {
+		log.Error("postUserMFA: complete sign-in of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
 	return http.StatusOK, &userMFAResponse{}, nil
 }
 
@@ -376,6 +698,7 @@ func postUserMFARecovery(r *http.Request, sess session.Session, mc *macaron.Cont
 
 	if err := database.Handle.TwoFactors().UseRecoveryCode(r.Context(), userID, req.RecoveryCode); err != nil {
 		if database.IsTwoFactorRecoveryCodeNotFound(err) {
+			context.AuditLoginFailed(r, userID, "", "invalid recovery code")
 			msg := l.Tr("auth.mfa_invalid_recovery_code")
 			return http.StatusUnauthorized, &bindingErrorResponse{
 				Fields: fieldErrors{"recoveryCode": &msg},
@@ -391,10 +714,448 @@ func postUserMFARecovery(r *http.Request, sess session.Session, mc *macaron.Cont
 		return http.StatusInternalServerError, nil, errors.Wrap(err, "get user by ID")
 	}
 
-	completeSignIn(sess, mc, u)
+	if err := completeSignIn(r, sess, mc, u, "recovery code"); err != nil {
+		log.Error("postUserMFARecovery: complete sign-in of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
+	return http.StatusOK, &userMFAResponse{}, nil
+}
+
+// webAuthnRelyingParty returns the WebAuthn relying party of the site, which
+// credentials are scoped to.
+func webAuthnRelyingParty() (*webauthn.RelyingParty, error) {
+	return webauthn.NewRelyingParty(conf.App.BrandName, conf.Server.ExternalURL)
+}
+
+// webAuthnUserHandle returns the user handle that authenticators store along
+// with discoverable credentials of the user.
+func webAuthnUserHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// newWebAuthnChallenge generates a challenge for a WebAuthn ceremony and saves
+// it to the session with the key, where it is consumed by
+// popWebAuthnChallenge.
+func newWebAuthnChallenge(sess session.Session, key string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	sess.Set(key, challenge)
+	return challenge, nil
+}
+
+// popWebAuthnChallenge returns the challenge saved in the session with the key
+// and deletes it, so that each challenge can only be used once.
+func popWebAuthnChallenge(sess session.Session, key string) string {
+	challenge, _ := sess.Get(key).(string)
+	sess.Delete(key)
+	return challenge
+}
+
+// webAuthnAssertion is the response of navigator.credentials.get(), with
+// binary values encoded with unpadded base64url.
+type webAuthnAssertion struct {
+	ID                string `json:"id" validate:"required"`
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData string `json:"authenticatorData" validate:"required"`
+	Signature         string `json:"signature" validate:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+var errInvalidWebAuthnAssertion = errors.New("invalid WebAuthn assertion")
+
+// verifyWebAuthnAssertion verifies the assertion of the authentication
+// ceremony with the challenge, and returns the credential that is used. Only
+// credentials that are allowed to sign in without a password are accepted for
+// passwordless sign-in, which also requires the user to be verified. It
+// returns errInvalidWebAuthnAssertion when the assertion is not valid.
+func verifyWebAuthnAssertion(ctx stdctx.Context, challenge string, req webAuthnAssertion, passwordless bool) (*database.WebAuthnCredential, error) {
+	var a webauthn.Assertion
+	for _, field := range []struct {
+		dst *[]byte
+		src string
+	}{
+		{&a.CredentialID, req.ID},
+		{&a.ClientDataJSON, req.ClientDataJSON},
+		{&a.AuthenticatorData, req.AuthenticatorData},
+		{&a.Signature, req.Signature},
+		{&a.UserHandle, req.UserHandle},
+	} {
+		b, err := webauthn.DecodeBinary(field.src)
+		if err != nil {
+			return nil, errInvalidWebAuthnAssertion
+		}
+		*field.dst = b
+	}
+
+	cred, err := database.Handle.WebAuthnCredentials().GetByCredentialID(ctx, a.CredentialID)
+	if err != nil {
+		if database.IsErrWebAuthnCredentialNotExist(err) {
+			return nil, errInvalidWebAuthnAssertion
+		}
+		return nil, errors.Wrap(err, "get WebAuthn credential")
+	}
+	if passwordless {
+		if !cred.Passwordless {
+			return nil, errInvalidWebAuthnAssertion
+		} else if len(a.UserHandle) > 0 && !bytes.Equal(a.UserHandle, webAuthnUserHandle(cred.UserID)) {
+			return nil, errInvalidWebAuthnAssertion
+		}
+	}
+
+	rp, err := webAuthnRelyingParty()
+	if err != nil {
+		return nil, errors.Wrap(err, "get relying party")
+	}
+	signCount, err := rp.VerifyAssertion(challenge, &a, cred.PublicKey, uint32(cred.SignCount), passwordless)
+	if err != nil {
+		log.Trace("Failed to verify WebAuthn assertion of credential %d: %v", cred.ID, err)
+		return nil, errInvalidWebAuthnAssertion
+	}
+
+	if err = database.Handle.WebAuthnCredentials().Use(ctx, cred.ID, signCount); err != nil {
+		return nil, errors.Wrap(err, "use WebAuthn credential")
+	}
+	return cred, nil
+}
+
+func postUserMFAWebAuthnOptions(r *http.Request, sess session.Session, l i18n.Locale) (statusCode int, resp any, err error) {
+	userID, ok := sess.Get("mfaUserID").(int64)
+	if !ok {
+		return http.StatusUnauthorized, &bindingErrorResponse{Error: l.Tr("auth.mfa_session_expired")}, nil
+	}
+
+	creds, err := database.Handle.WebAuthnCredentials().List(r.Context(), userID)
+	if err != nil {
+		log.Error("postUserMFAWebAuthnOptions: list WebAuthn credentials of user %d: %v", userID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "list WebAuthn credentials")
+	} else if len(creds) == 0 {
+		return http.StatusNotFound, nil, nil
+	}
+	credentialIDs := make([][]byte, 0, len(creds))
+	for _, cred := range creds {
+		credentialIDs = append(credentialIDs, cred.CredentialID)
+	}
+
+	rp, err := webAuthnRelyingParty()
+	if err != nil {
+		log.Error("postUserMFAWebAuthnOptions: get relying party: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get relying party")
+	}
+	challenge, err := newWebAuthnChallenge(sess, "mfaWebAuthnChallenge")
+	if err != nil {
+		log.Error("postUserMFAWebAuthnOptions: generate challenge: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "generate challenge")
+	}
+	return http.StatusOK, rp.RequestOptions(challenge, credentialIDs), nil
+}
+
+func postUserMFAWebAuthn(r *http.Request, sess session.Session, mc *macaron.Context, l i18n.Locale, req webAuthnAssertion) (statusCode int, resp any, err error) {
+	userID, ok := sess.Get("mfaUserID").(int64)
+	if !ok {
+		return http.StatusUnauthorized, &bindingErrorResponse{Error: l.Tr("auth.mfa_session_expired")}, nil
+	}
+
+	challenge := popWebAuthnChallenge(sess, "mfaWebAuthnChallenge")
+	cred, err := verifyWebAuthnAssertion(r.Context(), challenge, req, false)
+	if err == nil && cred.UserID != userID {
+		err = errInvalidWebAuthnAssertion
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidWebAuthnAssertion) {
+			context.AuditLoginFailed(r, userID, "", "invalid security key")
+			msg := l.Tr("auth.mfa_invalid_security_key")
+			return http.StatusUnauthorized, &bindingErrorResponse{
+				Fields: fieldErrors{"securityKey": &msg},
+			}, nil
+		}
+		log.Error("postUserMFAWebAuthn: verify WebAuthn assertion for user %d: %v", userID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "verify WebAuthn assertion")
+	}
+
+	u, err := database.Handle.Users().GetByID(r.Context(), userID)
+	if err != nil {
+		log.Error("postUserMFAWebAuthn: get user by ID %d: %v", userID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get user by ID")
+	}
+
+	if err := completeSignIn(r, sess, mc, u, "security key"); err != nil {
+		log.Error("postUserMFAWebAuthn: complete sign-in of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
 	return http.StatusOK, &userMFAResponse{}, nil
 }
 
+func postUserSignInPasskeyOptions(sess session.Session) (statusCode int, resp any, err error) {
+	rp, err := webAuthnRelyingParty()
+	if err != nil {
+		log.Error("postUserSignInPasskeyOptions: get relying party: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get relying party")
+	}
+	challenge, err := newWebAuthnChallenge(sess, "passkeyChallenge")
+	if err != nil {
+		log.Error("postUserSignInPasskeyOptions: generate challenge: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "generate challenge")
+	}
+	// Leaving the allowed credentials empty lets the authenticator offer its
+	// discoverable credentials for the site.
+	return http.StatusOK, rp.RequestOptions(challenge, nil), nil
+}
+
+// postUserSignInPasskey signs in the user with a passkey, which is a
+// discoverable WebAuthn credential that verifies the user by itself, so
+// neither the password nor another second factor is needed.
+func postUserSignInPasskey(r *http.Request, sess session.Session, mc *macaron.Context, l i18n.Locale, req webAuthnAssertion) (statusCode int, resp any, err error) {
+	challenge := popWebAuthnChallenge(sess, "passkeyChallenge")
+	cred, err := verifyWebAuthnAssertion(r.Context(), challenge, req, true)
+	if err != nil {
+		if errors.Is(err, errInvalidWebAuthnAssertion) {
+			context.AuditLoginFailed(r, 0, "", "invalid passkey")
+			return http.StatusUnauthorized, &bindingErrorResponse{Error: l.Tr("auth.passkey_sign_in_failed")}, nil
+		}
+		log.Error("postUserSignInPasskey: verify WebAuthn assertion: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "verify WebAuthn assertion")
+	}
+
+	u, err := database.Handle.Users().GetByID(r.Context(), cred.UserID)
+	if err != nil {
+		log.Error("postUserSignInPasskey: get user by ID %d: %v", cred.UserID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get user by ID")
+	}
+
+	if err := completeSignIn(r, sess, mc, u, "passkey"); err != nil {
+		log.Error("postUserSignInPasskey: complete sign-in of user %q: %v", u.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
+	return http.StatusOK, &userSignInResponse{}, nil
+}
+
+// webAuthnRegistrationConfirmLifetime is how long the confirmation of the
+// password or the two-factor passcode lasts for registering a WebAuthn
+// credential.
+const webAuthnRegistrationConfirmLifetime = 5 * time.Minute
+
+// requireLoginSession returns a non-zero status code when the request is not
+// made by a signed-in browser, e.g. with HTTP Basic Authentication or an access
+// token, which must not be enough to change how the user signs in.
+func requireLoginSession(c *context.Context, u *database.User) (statusCode int, err error) {
+	if u == nil || c == nil || c.IsBasicAuth || c.IsTokenAuth {
+		return http.StatusUnauthorized, nil
+	}
+
+	loginSession, err := c.CurrentLoginSession()
+	if err != nil {
+		return http.StatusInternalServerError, errors.Wrap(err, "get current login session")
+	} else if loginSession == nil {
+		return http.StatusForbidden, nil
+	}
+	return 0, nil
+}
+
+// confirmUser verifies the two-factor passcode of the signed-in user when
+// given, or their password otherwise, before a sensitive change. It returns a
+// non-zero status code along with the response when the verification fails.
+func confirmUser(r *http.Request, u *database.User, mc *macaron.Context, ca cache.Cache, l i18n.Locale, password, passcode string) (statusCode int, resp any, err error) {
+	if passcode != "" {
+		t, err := database.Handle.TwoFactors().GetByUserID(r.Context(), u.ID)
+		if err != nil && !database.IsErrTwoFactorNotFound(err) {
+			return http.StatusInternalServerError, nil, errors.Wrap(err, "get two factor by user ID")
+		}
+
+		valid := false
+		if t != nil {
+			valid, err = t.ValidateTOTP(passcode)
+			if err != nil {
+				return http.StatusInternalServerError, nil, errors.Wrap(err, "validate TOTP")
+			}
+		}
+		if !valid {
+			msg := l.Tr("auth.mfa_invalid_passcode")
+			return http.StatusForbidden, &bindingErrorResponse{
+				Fields: fieldErrors{"passcode": &msg},
+			}, nil
+		}
+
+		cacheKey := userx.TwoFactorCacheKey(u.ID, passcode)
+		if _, err := ca.Get(r.Context(), cacheKey); err == nil {
+			msg := l.Tr("auth.mfa_reused_passcode")
+			return http.StatusForbidden, &bindingErrorResponse{
+				Fields: fieldErrors{"passcode": &msg},
+			}, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Error("confirmUser: get two factor passcode cache for user %d: %v", u.ID, err)
+		}
+		if err = ca.Set(r.Context(), cacheKey, 1, 60*time.Second); err != nil {
+			log.Error("confirmUser: cache two factor passcode for user %d: %v", u.ID, err)
+		}
+		return 0, nil, nil
+	}
+
+	if wait := context.ReserveLoginAttempt(r, u.Name); wait > 0 {
+		context.SetRetryAfter(mc.Resp.Header(), wait)
+		return http.StatusTooManyRequests, &bindingErrorResponse{Error: l.Tr("auth.too_many_login_failures")}, nil
+	}
+
+	_, err = database.Handle.Users().Authenticate(r.Context(), u.Name, password, u.LoginSource)
+	if err != nil {
+		if auth.IsErrBadCredentials(err) {
+			msg := l.Tr("form.enterred_invalid_password")
+			return http.StatusForbidden, &bindingErrorResponse{
+				Fields: fieldErrors{"password": &msg},
+			}, nil
+		}
+		context.ReleaseLoginAttempt(r, u.Name)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "authenticate user")
+	}
+	context.ResetLoginFailures(r, u.Name)
+	return 0, nil, nil
+}
+
+// webAuthnRegistrationConfirmed returns true if the user has confirmed their
+// password or two-factor passcode recently to register a WebAuthn credential.
+func webAuthnRegistrationConfirmed(sess session.Session) bool {
+	confirmed, ok := sess.Get("webAuthnRegistrationConfirmedUnix").(int64)
+	return ok && time.Since(time.Unix(confirmed, 0)) < webAuthnRegistrationConfirmLifetime
+}
+
+type userMFAWebAuthnCredentialOptionsRequest struct {
+	// Passwordless is true when the credential is registered as a passkey that
+	// can be used to sign in without a password.
+	Passwordless bool `json:"passwordless"`
+	// The password or the two-factor passcode of the user to confirm the
+	// registration, which is not needed again until the confirmation expires.
+	Password string `json:"password"`
+	Passcode string `json:"passcode"`
+}
+
+func postUserMFAWebAuthnCredentialOptions(r *http.Request, c *context.Context, u *database.User, sess session.Session, mc *macaron.Context, ca cache.Cache, l i18n.Locale, req userMFAWebAuthnCredentialOptionsRequest) (statusCode int, resp any, err error) {
+	if statusCode, err := requireLoginSession(c, u); statusCode != 0 {
+		if err != nil {
+			log.Error("postUserMFAWebAuthnCredentialOptions: %v", err)
+		}
+		return statusCode, nil, err
+	}
+
+	if !webAuthnRegistrationConfirmed(sess) {
+		statusCode, resp, err := confirmUser(r, u, mc, ca, l, req.Password, req.Passcode)
+		if statusCode != 0 {
+			if err != nil {
+				log.Error("postUserMFAWebAuthnCredentialOptions: confirm user %q: %v", u.Name, err)
+			}
+			return statusCode, resp, err
+		}
+		sess.Set("webAuthnRegistrationConfirmedUnix", time.Now().Unix())
+	}
+
+	creds, err := database.Handle.WebAuthnCredentials().List(r.Context(), u.ID)
+	if err != nil {
+		log.Error("postUserMFAWebAuthnCredentialOptions: list WebAuthn credentials of user %d: %v", u.ID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "list WebAuthn credentials")
+	}
+	// Exclude the authenticators that are already registered.
+	credentialIDs := make([][]byte, 0, len(creds))
+	for _, cred := range creds {
+		credentialIDs = append(credentialIDs, cred.CredentialID)
+	}
+
+	rp, err := webAuthnRelyingParty()
+	if err != nil {
+		log.Error("postUserMFAWebAuthnCredentialOptions: get relying party: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get relying party")
+	}
+	challenge, err := newWebAuthnChallenge(sess, "webAuthnRegistrationChallenge")
+	if err != nil {
+		log.Error("postUserMFAWebAuthnCredentialOptions: generate challenge: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "generate challenge")
+	}
+	sess.Set("webAuthnRegistrationPasswordless", req.Passwordless)
+
+	user := webauthn.User{
+		ID:          webAuthnUserHandle(u.ID),
+		Name:        u.Name,
+		DisplayName: u.DisplayName(),
+	}
+	return http.StatusOK, rp.CreationOptions(challenge, user, credentialIDs, req.Passwordless), nil
+}
+
+// userMFAWebAuthnCredentialRequest carries the response of
+// navigator.credentials.create(), with binary values encoded with unpadded
+// base64url.
+type userMFAWebAuthnCredentialRequest struct {
+	Name              string `json:"name" validate:"required,max=255"`
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AttestationObject string `json:"attestationObject" validate:"required"`
+}
+
+func postUserMFAWebAuthnCredential(r *http.Request, c *context.Context, u *database.User, sess session.Session, l i18n.Locale, req userMFAWebAuthnCredentialRequest) (statusCode int, resp any, err error) {
+	if statusCode, err := requireLoginSession(c, u); statusCode != 0 {
+		if err != nil {
+			log.Error("postUserMFAWebAuthnCredential: %v", err)
+		}
+		return statusCode, nil, err
+	}
+	if !webAuthnRegistrationConfirmed(sess) {
+		return http.StatusForbidden, &bindingErrorResponse{Error: l.Tr("settings.webauthn_confirm_expired")}, nil
+	}
+
+	challenge := popWebAuthnChallenge(sess, "webAuthnRegistrationChallenge")
+	passwordless, _ := sess.Get("webAuthnRegistrationPasswordless").(bool)
+	sess.Delete("webAuthnRegistrationPasswordless")
+
+	clientDataJSON, err := webauthn.DecodeBinary(req.ClientDataJSON)
+	if err != nil {
+		return http.StatusBadRequest, &bindingErrorResponse{Error: l.Tr("form.invalid_request")}, nil
+	}
+	attestationObject, err := webauthn.DecodeBinary(req.AttestationObject)
+	if err != nil {
+		return http.StatusBadRequest, &bindingErrorResponse{Error: l.Tr("form.invalid_request")}, nil
+	}
+
+	rp, err := webAuthnRelyingParty()
+	if err != nil {
+		log.Error("postUserMFAWebAuthnCredential: get relying party: %v", err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "get relying party")
+	}
+	cred, err := rp.VerifyRegistration(challenge, clientDataJSON, attestationObject, passwordless)
+	if err != nil {
+		log.Trace("Failed to verify WebAuthn registration of user %d: %v", u.ID, err)
+		return http.StatusUnprocessableEntity, &bindingErrorResponse{Error: l.Tr("settings.webauthn_register_failed")}, nil
+	}
+
+	created, err := database.Handle.WebAuthnCredentials().Create(
+		r.Context(),
+		u.ID,
+		database.CreateWebAuthnCredentialOptions{
+			Name:         req.Name,
+			CredentialID: cred.ID,
+			PublicKey:    cred.PublicKey,
+			SignCount:    cred.SignCount,
+			Passwordless: passwordless,
+		},
+	)
+	if err != nil {
+		if database.IsErrWebAuthnCredentialAlreadyExist(err) {
+			msg := l.Tr("settings.webauthn_credential_already_exists")
+			return http.StatusUnprocessableEntity, &bindingErrorResponse{
+				Fields: fieldErrors{"name": &msg},
+			}, nil
+		}
+		log.Error("postUserMFAWebAuthnCredential: create WebAuthn credential for user %d: %v", u.ID, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "create WebAuthn credential")
+	}
+
+	sess.Delete("webAuthnRegistrationConfirmedUnix")
+
+	details := fmt.Sprintf("credential %q", created.Name)
+	if created.Passwordless {
+		details += " as a passkey"
+	}
+	context.AuditLog(r, u.ID, u.Name, database.AuditActionUserWebAuthnAdd, database.AuditTargetOfUser(u), details)
+	log.Trace("WebAuthn credential registered: %s", u.Name)
+	return http.StatusNoContent, nil, nil
+}
+
 type userInfo struct {
 	Username              string `json:"username"`
 	AvatarURL             string `json:"avatarURL"`
@@ -494,7 +1255,10 @@ func postUserActivateComplete(r *http.Request, sess session.Session, mc *macaron
 	}
 
 	log.Trace("User activated: %s", target.Name)
-	completeSignIn(sess, mc, target)
+	if err := completeSignIn(r, sess, mc, target, "activation code"); err != nil {
+		log.Error("postUserActivateComplete: complete sign-in of user %q: %v", target.Name, err)
+		return http.StatusInternalServerError, nil, errors.Wrap(err, "complete sign-in")
+	}
 	return http.StatusNoContent, nil, nil
 }
 
@@ -503,6 +1267,15 @@ type postUserSignOutResponse struct {
 }
 
 func postUserSignOut(sess macaronsession.Store, mc *macaron.Context) (statusCode int, resp *postUserSignOutResponse, err error) {
+	if key, _ := sess.Get("loginSessionKey").(string); key != "" {
+		ls, err := database.Handle.LoginSessions().GetByKey(mc.Req.Context(), key)
+		if err == nil {
+			err = database.Handle.LoginSessions().DeleteByID(mc.Req.Context(), ls.UserID, ls.ID)
+		}
+		if err != nil && !database.IsErrLoginSessionNotExist(err) {
+			log.Error("postUserSignOut: delete login session: %v", err)
+		}
+	}
 	_ = sess.Flush()
 	_ = sess.Destory(mc)
 	if conf.Auth.CustomLogoutURL != "" {
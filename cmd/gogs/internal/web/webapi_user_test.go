@@ -0,0 +1,152 @@
+package web
+
+import (
+	stdctx "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-macaron/i18n"
+	macaronsession "github.com/go-macaron/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaron.v1"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/database/databasetest"
+)
+
+type mapSession struct {
+	macaronsession.Store
+	values map[any]any
+}
+
+func newMapSession() *mapSession {
+	return &mapSession{values: make(map[any]any)}
+}
+
+func (s *mapSession) Get(key any) any { return s.values[key] }
+
+func (s *mapSession) Set(key, val any) error {
+	s.values[key] = val
+	return nil
+}
+
+func (s *mapSession) Delete(key any) error {
+	delete(s.values, key)
+	return nil
+}
+
+func TestPostUserMFAWebAuthnCredential(t *testing.T) {
+	databasetest.SetUp(t)
+	conf.SetMockApp(t, conf.AppOpts{BrandName: "Gogs"})
+	conf.SetMockServer(t, conf.ServerOpts{ExternalURL: "https://gogs.example.com/"})
+
+	ctx := stdctx.Background()
+	alice, err := database.Handle.Users().Create(ctx, "alice", "alice@example.com", database.CreateUserOptions{
+		Password:  "correct-password",
+		Activated: true,
+	})
+	require.NoError(t, err)
+	loginSessionKey, err := database.Handle.LoginSessions().Create(ctx, alice.ID, database.CreateLoginSessionOptions{})
+	require.NoError(t, err)
+
+	l := i18n.Locale{}
+	newContext := func(setup func(c *context.Context)) (*context.Context, *macaron.Context) {
+		r := httptest.NewRequest(http.MethodPost, "/api/web/user/mfa/webauthn/credentials/options", nil)
+		mc := &macaron.Context{
+			Req:  macaron.Request{Request: r},
+			Resp: macaron.NewResponseWriter(r.Method, httptest.NewRecorder()),
+		}
+		c := &context.Context{
+			Context:  mc,
+			Session:  newMapSession(),
+			User:     alice,
+			IsLogged: true,
+		}
+		setup(c)
+		return c, mc
+	}
+
+	t.Run("not signed in through the browser", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			setup    func(c *context.Context)
+			wantCode int
+		}{
+			{
+				name:     "access token",
+				setup:    func(c *context.Context) { c.IsTokenAuth = true },
+				wantCode: http.StatusUnauthorized,
+			},
+			{
+				name:     "basic auth",
+				setup:    func(c *context.Context) { c.IsBasicAuth = true },
+				wantCode: http.StatusUnauthorized,
+			},
+			{
+				name:     "no login session",
+				setup:    func(*context.Context) {},
+				wantCode: http.StatusForbidden,
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				c, mc := newContext(test.setup)
+				sess := flamegoSessionAdapter{sess: c.Session}
+				sess.Set("webAuthnRegistrationConfirmedUnix", time.Now().Unix())
+
+				statusCode, _, err := postUserMFAWebAuthnCredentialOptions(c.Req.Request, c, alice, sess, mc, nil, l, userMFAWebAuthnCredentialOptionsRequest{Password: "correct-password"})
+				require.NoError(t, err)
+				assert.Equal(t, test.wantCode, statusCode)
+
+				statusCode, _, err = postUserMFAWebAuthnCredential(c.Req.Request, c, alice, sess, l, userMFAWebAuthnCredentialRequest{})
+				require.NoError(t, err)
+				assert.Equal(t, test.wantCode, statusCode)
+			})
+		}
+	})
+
+	browser := func(c *context.Context) {
+		_ = c.Session.Set("loginSessionKey", loginSessionKey)
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		c, mc := newContext(browser)
+		sess := flamegoSessionAdapter{sess: c.Session}
+
+		statusCode, _, err := postUserMFAWebAuthnCredentialOptions(c.Req.Request, c, alice, sess, mc, nil, l, userMFAWebAuthnCredentialOptionsRequest{Password: "wrong-password"})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		assert.Nil(t, sess.Get("webAuthnRegistrationChallenge"))
+
+		// The credential is not stored without the confirmation.
+		statusCode, _, err = postUserMFAWebAuthnCredential(c.Req.Request, c, alice, sess, l, userMFAWebAuthnCredentialRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	})
+
+	t.Run("confirmed", func(t *testing.T) {
+		c, mc := newContext(browser)
+		sess := flamegoSessionAdapter{sess: c.Session}
+
+		statusCode, _, err := postUserMFAWebAuthnCredentialOptions(c.Req.Request, c, alice, sess, mc, nil, l, userMFAWebAuthnCredentialOptionsRequest{Password: "correct-password"})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.NotNil(t, sess.Get("webAuthnRegistrationChallenge"))
+		assert.True(t, webAuthnRegistrationConfirmed(sess))
+
+		// The password is not needed again until the confirmation expires.
+		statusCode, _, err = postUserMFAWebAuthnCredentialOptions(c.Req.Request, c, alice, sess, mc, nil, l, userMFAWebAuthnCredentialOptionsRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		sess.Set("webAuthnRegistrationConfirmedUnix", int64(1))
+		statusCode, _, err = postUserMFAWebAuthnCredential(c.Req.Request, c, alice, sess, l, userMFAWebAuthnCredentialRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	})
+}
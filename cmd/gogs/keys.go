@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	gossh "golang.org/x/crypto/ssh"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/ssh"
+)
+
+const (
+	tplCertAuthority = `cert-authority,principals="%s",command="%s serv user-%d --config='%s'",no-port-forwarding,no-X11-forwarding,no-agent-forwarding,no-pty %s` + "\n"
+)
+
+var keysCommand = cli.Command{
+	Name:  "keys",
+	Usage: "This command should only be called by SSH server as AuthorizedKeysCommand",
+	Description: `Keys looks up the public key offered by a client and prints the matching line
+in the format of "authorized_keys", so that OpenSSH does not need the file to be
+rewritten. Configure it in sshd_config like:
+
+    AuthorizedKeysCommand /path/to/gogs keys --config=/path/to/app.ini -e git -u %u -t %t -k %k
+    AuthorizedKeysCommandUser git
+
+The key may also be looked up by its fingerprint with "-f %f" instead of "-t %t -k %k".`,
+	Action: runKeys,
+	Flags: []cli.Flag{
+		stringFlag("config, c", "", "Custom configuration file path"),
+		stringFlag("expected, e", "git", "Expected username of the SSH connection, nothing is printed for other users"),
+		stringFlag("username, u", "", "Username of the SSH connection (%u)"),
+		stringFlag("type, t", "", "Type of the offered key (%t)"),
+		stringFlag("content, k", "", "Base64-encoded content of the offered key (%k)"),
+		stringFlag("fingerprint, f", "", "Fingerprint of the offered key (%f)"),
+	},
+}
+
+func runKeys(ctx context.Context, cmd *cli.Command) error {
+	setup(cmd, "keys.log", true)
+
+	if conf.SSH.Disabled {
+		return nil
+	}
+
+	// The command is called for every user of the system, keys of other users
+	// are left to other sources of authorized keys.
+	if cmd.String("username") != cmd.String("expected") {
+		log.Trace("Keys: skipped username %q", cmd.String("username"))
+		return nil
+	}
+
+	typ, content, fingerprint := cmd.String("type"), cmd.String("content"), cmd.String("fingerprint")
+	if fingerprint != "" {
+		key, err := database.GetPublicKeyByFingerprint(fingerprint)
+		if err != nil {
+			if database.IsErrKeyNotExist(err) {
+				return nil
+			}
+			fail("Internal error", "Failed to get public key by fingerprint %q: %v", fingerprint, err)
+		}
+		printAuthorizedKey(key)
+		return nil
+	}
+
+	if typ == "" || content == "" {
+		fail("Not enough arguments", "Either the fingerprint or the type and content of the key must be given")
+	}
+	pubKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(typ + " " + content))
+	if err != nil {
+		fail("Invalid key", "Failed to parse key of type %q: %v", typ, err)
+	}
+
+	if cert, ok := pubKey.(*gossh.Certificate); ok {
+		printCertAuthority(ctx, cert)
+		return nil
+	}
+
+	key, err := database.SearchPublicKeyByContent(strings.TrimSpace(string(gossh.MarshalAuthorizedKey(pubKey))))
+	if err != nil {
+		if database.IsErrKeyNotExist(err) {
+			return nil
+		}
+		fail("Internal error", "Failed to search public key by content: %v", err)
+	}
+	printAuthorizedKey(key)
+	return nil
+}
+
+// printAuthorizedKey prints the line of the key in the format of
+// "authorized_keys", unless the key is revoked.
+func printAuthorizedKey(key *database.PublicKey) {
+	if conf.SSH.RevokedKeys != "" {
+		pubKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key.Content))
+		if err != nil {
+			fail("Internal error", "Failed to parse content of key %d: %v", key.ID, err)
+		}
+		revokedKeys, err := ssh.NewRevokedKeys(conf.SSH.RevokedKeys)
+		if err != nil {
+			fail("Internal error", "Failed to load revoked keys: %v", err)
+		}
+		revoked, err := revokedKeys.IsRevoked(pubKey)
+		if err != nil {
+			fail("Internal error", "Failed to check revoked keys: %v", err)
+		} else if revoked {
+			log.Trace("Keys: key %d is revoked", key.ID)
+			return
+		}
+	}
+	fmt.Print(key.AuthorizedString())
+}
+
+// printCertAuthority prints a "cert-authority" line that lets OpenSSH accept
+// the user certificate for the user that it is issued for, which is determined
+// in the same way as the builtin SSH server. OpenSSH checks the certificate
+// against the line again, including its "source-address" critical option.
+func printCertAuthority(ctx context.Context, cert *gossh.Certificate) {
+	if conf.SSH.TrustedUserCAKeys == "" {
+		log.Trace("Keys: skipped certificate %q because certificate authentication is not enabled", cert.KeyId)
+		return
+	}
+
+	var revokedKeys *ssh.RevokedKeys
+	if conf.SSH.RevokedKeys != "" {
+		var err error
+		revokedKeys, err = ssh.NewRevokedKeys(conf.SSH.RevokedKeys)
+		if err != nil {
+			fail("Internal error", "Failed to load revoked keys: %v", err)
+		}
+	}
+	checker, err := ssh.NewUserCertChecker(conf.SSH.TrustedUserCAKeys, revokedKeys)
+	if err != nil {
+		fail("Internal error", "Failed to set up certificate authentication: %v", err)
+	}
+
+	u, err := checker.Authenticate(ctx, cert)
+	if err != nil {
+		log.Trace("Keys: certificate authentication failed: %v", err)
+		return
+	}
+
+	// Usernames are case-insensitive, but principals are not to OpenSSH.
+	var principal string
+	for _, p := range cert.ValidPrincipals {
+		if strings.EqualFold(p, u.Name) {
+			principal = p
+			break
+		}
+	}
+	caKey := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(cert.SignatureKey)))
+	fmt.Printf(tplCertAuthority, principal, conf.AppPath(), u.ID, conf.CustomConf, caKey)
+}
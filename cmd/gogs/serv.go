@@ -15,6 +15,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/route/lfs"
 )
 
 const (
@@ -121,6 +122,24 @@ var allowedCommands = map[string]database.AccessMode{
 	"git-upload-pack":    database.AccessModeRead,
 	"git-upload-archive": database.AccessModeRead,
 	"git-receive-pack":   database.AccessModeWrite,
+	// Git LFS commands require write access for the "upload" operation.
+	"git-lfs-authenticate": database.AccessModeRead,
+	"git-lfs-transfer":     database.AccessModeRead,
+}
+
+func isLFSCommand(verb string) bool {
+	return verb == "git-lfs-authenticate" || verb == "git-lfs-transfer"
+}
+
+// parseLFSArgs parses arguments of Git LFS commands in the form of
+// "<repo path> <operation> [<oid>]", where the repository path may be quoted
+// and prefixed with a slash.
+func parseLFSArgs(args string) (repoPath, operation string) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return "", ""
+	}
+	return "'" + strings.TrimPrefix(strings.Trim(fields[0], "'"), "/") + "'", fields[1]
 }
 
 func runServ(ctx context.Context, cmd *cli.Command) error {
@@ -143,6 +162,11 @@ func runServ(ctx context.Context, cmd *cli.Command) error {
 	}
 
 	verb, args := parseSSHCmd(sshCmd)
+	lfsCmd := isLFSCommand(verb)
+	var lfsOperation string
+	if lfsCmd {
+		args, lfsOperation = parseLFSArgs(args)
+	}
 	repoFullName := strings.ToLower(strings.Trim(args, "'"))
 	repoFields := strings.SplitN(repoFullName, "/", 2)
 	if len(repoFields) != 2 {
@@ -173,6 +197,15 @@ func runServ(ctx context.Context, cmd *cli.Command) error {
 	if !ok {
 		fail("Unknown git command", "Unknown git command '%s'", verb)
 	}
+	if lfsCmd {
+		switch lfsOperation {
+		case "upload":
+			requestMode = database.AccessModeWrite
+		case "download":
+		default:
+			fail("Unknown Git LFS operation", "Unknown Git LFS operation '%s'", lfsOperation)
+		}
+	}
 
 	// Prohibit push to mirror repositories.
 	if requestMode > database.AccessModeRead && repo.IsMirror {
@@ -182,23 +215,48 @@ func runServ(ctx context.Context, cmd *cli.Command) error {
 	// Allow anonymous (user is nil) clone for public repositories.
 	var user *database.User
 
-	keyID, _ := strconv.ParseInt(strings.TrimPrefix(cmd.Args().Get(0), "key-"), 10, 64)
-	key, err := database.GetPublicKeyByID(keyID)
-	if err != nil {
-		fail("Invalid key ID", "Invalid key ID '%s': %v", cmd.Args().Get(0), err)
+	// The argument is either "key-<id>" for a public key, or "user-<id>" for a
+	// user authenticated by an SSH certificate, in which case the key is nil.
+	var key *database.PublicKey
+	var certUser *database.User
+	if arg := cmd.Args().Get(0); strings.HasPrefix(arg, "user-") {
+		userID, _ := strconv.ParseInt(strings.TrimPrefix(arg, "user-"), 10, 64)
+		certUser, err = database.Handle.Users().GetByID(ctx, userID)
+		if err != nil {
+			fail("Invalid user ID", "Invalid user ID '%s': %v", arg, err)
+		} else if !certUser.IsActive || certUser.ProhibitLogin {
+			fail("User is not allowed to sign in", "User '%s' is not allowed to sign in", certUser.Name)
+		}
+	} else {
+		keyID, _ := strconv.ParseInt(strings.TrimPrefix(arg, "key-"), 10, 64)
+		key, err = database.GetPublicKeyByID(keyID)
+		if err != nil {
+			fail("Invalid key ID", "Invalid key ID '%s': %v", arg, err)
+		}
 	}
+	isDeployKey := key != nil && key.IsDeployKey()
 
-	if requestMode == database.AccessModeWrite || repo.IsPrivate {
+	// Git LFS always needs a user to act on behalf of, which deploy keys don't
+	// represent.
+	if lfsCmd && isDeployKey {
+		fail("Deploy keys cannot be used for Git LFS", "Cannot use deploy key for Git LFS: %d", key.ID)
+	}
+
+	if requestMode == database.AccessModeWrite || repo.IsPrivate || lfsCmd {
 		// Check deploy key or user key.
-		if key.IsDeployKey() {
+		if isDeployKey {
 			if key.Mode < requestMode {
 				fail("Key permission denied", "Cannot push with deployment key: %d", key.ID)
 			}
 			checkDeployKey(key, repo)
 		} else {
-			user, err = database.Handle.Users().GetByKeyID(ctx, key.ID)
-			if err != nil {
-				fail("Internal error", "Failed to get user by key ID '%d': %v", key.ID, err)
+			if certUser != nil {
+				user = certUser
+			} else {
+				user, err = database.Handle.Users().GetByKeyID(ctx, key.ID)
+				if err != nil {
+					fail("Internal error", "Failed to get user by key ID '%d': %v", key.ID, err)
+				}
 			}
 
 			mode := database.Handle.Permissions().AccessMode(ctx, user.ID, repo.ID,
@@ -222,13 +280,13 @@ func runServ(ctx context.Context, cmd *cli.Command) error {
 		// A deploy key doesn't represent a signed in user, so in a site with Auth.RequireSignInView enabled,
 		// we should give read access only in repositories where this deploy key is in use. In other cases,
 		// a server or system using an active deploy key can get read access to all repositories on a Gogs instance.
-		if key.IsDeployKey() && conf.Auth.RequireSigninView {
+		if isDeployKey && conf.Auth.RequireSigninView {
 			checkDeployKey(key, repo)
 		}
 	}
 
 	// Update user key activity.
-	if key.ID > 0 {
+	if key != nil && key.ID > 0 {
 		key, err := database.GetPublicKeyByID(key.ID)
 		if err != nil {
 			fail("Internal error", "GetPublicKeyByID: %v", err)
@@ -240,6 +298,19 @@ func runServ(ctx context.Context, cmd *cli.Command) error {
 		}
 	}
 
+	switch verb {
+	case "git-lfs-authenticate":
+		if err = lfs.ServeSSHAuthenticate(os.Stdout, user, repo, lfsOperation); err != nil {
+			fail("Internal error", "Failed to serve Git LFS authenticate: %v", err)
+		}
+		return nil
+	case "git-lfs-transfer":
+		if err = lfs.ServeSSHTransfer(ctx, os.Stdin, os.Stdout, user, repo, lfsOperation); err != nil {
+			fail("Internal error", "Failed to serve Git LFS transfer: %v", err)
+		}
+		return nil
+	}
+
 	// Special handle for Windows.
 	if conf.IsWindowsRuntime() {
 		verb = strings.Replace(verb, "-", " ", 1)
@@ -19,6 +19,8 @@ const (
 	PAM         // 4
 	DLDAP       // 5
 	GitHub      // 6
+	OIDC        // 7
+	SAML        // 8
 
 	Mock Type = 999
 )
@@ -31,6 +33,8 @@ func Name(typ Type) string {
 		SMTP:   "SMTP",
 		PAM:    "PAM",
 		GitHub: "GitHub",
+		OIDC:   "OpenID Connect",
+		SAML:   "SAML 2.0",
 	}[typ]
 }
 
@@ -69,6 +73,8 @@ type ExternalAccount struct {
 	Location string
 	// The website of the account.
 	Website string
+	// The groups the account belongs to.
+	Groups []string
 	// Whether the user should be prompted as a site admin.
 	Admin bool
 }
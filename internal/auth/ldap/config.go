@@ -55,6 +55,8 @@ type Config struct {
 	GroupFilter       string // Group name filter
 	GroupMemberUID    string `ini:"group_member_uid"` // Group Attribute containing array of UserUID
 	UserUID           string `ini:"user_uid"`         // User Attribute listed in group
+	TeamSyncEnabled   bool   // Whether to synchronize group memberships to organization teams
+	TeamGroupMap      string `ini:",omitempty"` // Mappings of group DNs to organization teams, one per line
 }
 
 func (c *Config) SecurityProtocolName() string {
@@ -188,16 +190,16 @@ func bindUser(l *ldap.Conn, userDN, passwd string) error {
 }
 
 // searchEntry searches an LDAP source if an entry (name, passwd) is valid and in the specific filter.
-func (c *Config) searchEntry(name, passwd string, directBind bool) (string, string, string, string, bool, bool) {
+func (c *Config) searchEntry(name, passwd string, directBind bool) (string, string, string, string, []string, bool, bool) {
 	// See https://tools.ietf.org/search/rfc4513#section-5.1.2
 	if passwd == "" {
 		log.Trace("authentication failed for '%s' with empty password", name)
-		return "", "", "", "", false, false
+		return "", "", "", "", nil, false, false
 	}
 	l, err := dial(c)
 	if err != nil {
 		log.Error("LDAP connect failed for '%s': %v", c.Host, err)
-		return "", "", "", "", false, false
+		return "", "", "", "", nil, false, false
 	}
 	defer l.Close()
 
@@ -208,7 +210,7 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 		var ok bool
 		userDN, ok = c.sanitizedUserDN(name)
 		if !ok {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 	} else {
 		log.Trace("LDAP will use BindDN")
@@ -216,7 +218,7 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 		var found bool
 		userDN, found = c.findUserDN(l, name)
 		if !found {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 	}
 
@@ -224,13 +226,13 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 		// binds user (checking password) before looking-up attributes in user context
 		err = bindUser(l, userDN, passwd)
 		if err != nil {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 	}
 
 	userFilter, ok := c.sanitizedUserQuery(name)
 	if !ok {
-		return "", "", "", "", false, false
+		return "", "", "", "", nil, false, false
 	}
 
 	log.Trace("Fetching attributes %q, %q, %q, %q, %q with user filter %q and user DN %q",
@@ -243,7 +245,7 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 	sr, err := l.Search(search)
 	if err != nil {
 		log.Error("LDAP: User search failed: %v", err)
-		return "", "", "", "", false, false
+		return "", "", "", "", nil, false, false
 	} else if len(sr.Entries) < 1 {
 		if directBind {
 			log.Trace("LDAP: User filter inhibited user login")
@@ -251,7 +253,7 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 			log.Trace("LDAP: User search failed: 0 entries")
 		}
 
-		return "", "", "", "", false, false
+		return "", "", "", "", nil, false, false
 	}
 
 	username := sr.Entries[0].GetAttributeValue(c.AttributeUsername)
@@ -264,11 +266,11 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 	if c.GroupEnabled {
 		groupFilter, ok := c.sanitizedGroupFilter(c.GroupFilter)
 		if !ok {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 		groupDN, ok := c.sanitizedGroupDN(c.GroupDN)
 		if !ok {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 
 		log.Trace("LDAP: Fetching groups '%v' with filter '%s' and base '%s'", c.GroupMemberUID, groupFilter, groupDN)
@@ -280,10 +282,10 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 		srg, err := l.Search(groupSearch)
 		if err != nil {
 			log.Error("LDAP: Group search failed: %v", err)
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		} else if len(srg.Entries) < 1 {
 			log.Trace("LDAP: Group search returned no entries")
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 
 		isMember := false
@@ -307,7 +309,16 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 
 		if !isMember {
 			log.Trace("LDAP: Group membership test failed [username: %s, group_member_uid: %s, user_uid: %s", username, c.GroupMemberUID, uid)
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
+		}
+	}
+
+	var groups []string
+	if c.TeamSyncEnabled {
+		groups, err = c.searchTeamGroups(l, sr.Entries[0])
+		if err != nil {
+			log.Error("LDAP: Team group search failed: %v", err)
+			return "", "", "", "", nil, false, false
 		}
 	}
 
@@ -333,9 +344,9 @@ func (c *Config) searchEntry(name, passwd string, directBind bool) (string, stri
 		// binds user (checking password) after looking-up attributes in BindDN context
 		err = bindUser(l, userDN, passwd)
 		if err != nil {
-			return "", "", "", "", false, false
+			return "", "", "", "", nil, false, false
 		}
 	}
 
-	return username, firstname, surname, mail, isAdmin, true
+	return username, firstname, surname, mail, groups, isAdmin, true
 }
@@ -23,7 +23,7 @@ func NewProvider(directBind bool, cfg *Config) auth.Provider {
 // Authenticate queries if login/password is valid against the LDAP directory pool,
 // and returns queried information when succeeded.
 func (p *Provider) Authenticate(login, password string) (*auth.ExternalAccount, error) {
-	username, fn, sn, email, isAdmin, succeed := p.config.searchEntry(login, password, p.directBind)
+	username, fn, sn, email, groups, isAdmin, succeed := p.config.searchEntry(login, password, p.directBind)
 	if !succeed {
 		return nil, auth.ErrBadCredentials{Args: map[string]any{"login": login}}
 	}
@@ -53,10 +53,17 @@ func (p *Provider) Authenticate(login, password string) (*auth.ExternalAccount,
 		Name:     username,
 		FullName: composeFullName(fn, sn, username),
 		Email:    email,
+		Groups:   groups,
 		Admin:    isAdmin,
 	}, nil
 }
 
+// SearchTeamGroups returns DNs of the groups mapped to organization teams that
+// the user with given login is a member of, without the password of the user.
+func (p *Provider) SearchTeamGroups(login string) ([]string, error) {
+	return p.config.SearchTeamGroups(login, p.directBind)
+}
+
 func (p *Provider) Config() any {
 	return p.config
 }
@@ -0,0 +1,157 @@
+package ldap
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-ldap/ldap/v3"
+	log "unknwon.dev/clog/v2"
+)
+
+// TeamMapping maps the members of an LDAP group to the members of an
+// organization team.
+type TeamMapping struct {
+	GroupDN string
+	Org     string
+	Team    string
+}
+
+// ParseTeamMappings parses mappings of LDAP groups to organization teams, one
+// per line in the form of "<group DN>: <organization>/<team>". Empty lines and
+// lines starting with "#" are ignored.
+func ParseTeamMappings(s string) ([]TeamMapping, error) {
+	var mappings []TeamMapping
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Organization and team names never contain colons, but group DNs may.
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
+			return nil, errors.Newf("line %d: missing colon between group DN and team", i+1)
+		}
+		groupDN := strings.TrimSpace(line[:idx])
+		if _, err := ldap.ParseDN(groupDN); err != nil || groupDN == "" {
+			return nil, errors.Newf("line %d: invalid group DN %q", i+1, groupDN)
+		}
+		org, team, ok := strings.Cut(strings.TrimSpace(line[idx+1:]), "/")
+		if !ok || org == "" || team == "" || strings.Contains(team, "/") {
+			return nil, errors.Newf("line %d: team must be in the form of <organization>/<team>", i+1)
+		}
+		mappings = append(mappings, TeamMapping{
+			GroupDN: groupDN,
+			Org:     org,
+			Team:    team,
+		})
+	}
+	return mappings, nil
+}
+
+// TeamMappings returns the mappings of LDAP groups to organization teams, or
+// nil if team synchronization is not enabled.
+func (c *Config) TeamMappings() ([]TeamMapping, error) {
+	if !c.TeamSyncEnabled {
+		return nil, nil
+	}
+	return ParseTeamMappings(c.TeamGroupMap)
+}
+
+// searchTeamGroups returns DNs of the mapped groups that the user entry is a
+// member of. Mapped groups that do not exist in the directory are skipped.
+func (c *Config) searchTeamGroups(l *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	mappings, err := c.TeamMappings()
+	if err != nil {
+		return nil, errors.Wrap(err, "parse team mappings")
+	}
+
+	memberAttribute := c.GroupMemberUID
+	if memberAttribute == "" {
+		memberAttribute = "member"
+	}
+	member := entry.DN
+	if c.UserUID != "" && c.UserUID != "dn" {
+		member = entry.GetAttributeValue(c.UserUID)
+		if member == "" {
+			return nil, errors.Newf("user entry %q has no %q attribute", entry.DN, c.UserUID)
+		}
+	}
+	filter := "(" + memberAttribute + "=" + ldap.EscapeFilter(member) + ")"
+
+	groups := make([]string, 0, len(mappings))
+	searched := make(map[string]bool, len(mappings))
+	for _, m := range mappings {
+		key := strings.ToLower(m.GroupDN)
+		if searched[key] {
+			continue
+		}
+		searched[key] = true
+
+		log.Trace("LDAP: Checking membership of group %q with filter %q", m.GroupDN, filter)
+		sr, err := l.Search(ldap.NewSearchRequest(
+			m.GroupDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, filter,
+			[]string{"dn"},
+			nil))
+		if err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+				log.Trace("LDAP: Group %q does not exist", m.GroupDN)
+				continue
+			}
+			return nil, errors.Wrapf(err, "search group %q", m.GroupDN)
+		} else if len(sr.Entries) > 0 {
+			groups = append(groups, m.GroupDN)
+		}
+	}
+	return groups, nil
+}
+
+// SearchTeamGroups returns DNs of the mapped groups that the user with given
+// login is a member of, by searching with the Bind DN or anonymously.
+func (c *Config) SearchTeamGroups(login string, directBind bool) ([]string, error) {
+	l, err := dial(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+	defer l.Close()
+
+	var userDN string
+	if directBind {
+		if c.BindDN != "" && c.BindPassword != "" {
+			if err = l.Bind(c.BindDN, c.BindPassword); err != nil {
+				return nil, errors.Wrap(err, "bind")
+			}
+		}
+
+		var ok bool
+		userDN, ok = c.sanitizedUserDN(login)
+		if !ok {
+			return nil, errors.Newf("invalid login %q", login)
+		}
+	} else {
+		var found bool
+		userDN, found = c.findUserDN(l, login)
+		if !found {
+			return nil, errors.Newf("user %q not found", login)
+		}
+	}
+
+	userFilter, ok := c.sanitizedUserQuery(login)
+	if !ok {
+		return nil, errors.Newf("invalid login %q", login)
+	}
+	attributes := []string{"dn"}
+	if c.UserUID != "" && c.UserUID != "dn" {
+		attributes = []string{c.UserUID}
+	}
+	sr, err := l.Search(ldap.NewSearchRequest(
+		userDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, userFilter,
+		attributes,
+		nil))
+	if err != nil {
+		return nil, errors.Wrap(err, "search user")
+	} else if len(sr.Entries) < 1 {
+		return nil, errors.Newf("user %q not found", login)
+	}
+	return c.searchTeamGroups(l, sr.Entries[0])
+}
@@ -0,0 +1,43 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTeamMappings(t *testing.T) {
+	got, err := ParseTeamMappings(`
+# Developers
+cn=developers,ou=group,dc=mydomain,dc=com: myorg/developers
+  cn=admins,ou=group,dc=mydomain,dc=com:myorg/Owners  
+cn=a:b,ou=group,dc=mydomain,dc=com: other/team
+`)
+	require.NoError(t, err)
+	want := []TeamMapping{
+		{GroupDN: "cn=developers,ou=group,dc=mydomain,dc=com", Org: "myorg", Team: "developers"},
+		{GroupDN: "cn=admins,ou=group,dc=mydomain,dc=com", Org: "myorg", Team: "Owners"},
+		{GroupDN: "cn=a:b,ou=group,dc=mydomain,dc=com", Org: "other", Team: "team"},
+	}
+	assert.Equal(t, want, got)
+
+	tests := []struct {
+		name    string
+		s       string
+		wantErr string
+	}{
+		{name: "no colon", s: "cn=developers,dc=com myorg/developers", wantErr: "line 1: missing colon"},
+		{name: "invalid DN", s: "\ndevelopers: myorg/developers", wantErr: `line 2: invalid group DN "developers"`},
+		{name: "empty DN", s: ": myorg/developers", wantErr: `line 1: invalid group DN ""`},
+		{name: "no team", s: "cn=developers,dc=com: myorg", wantErr: "line 1: team must be"},
+		{name: "nested team", s: "cn=developers,dc=com: myorg/a/b", wantErr: "line 1: team must be"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseTeamMappings(test.s)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"gogs.io/gogs/internal/auth"
+)
+
+// Config contains configuration for OpenID Connect authentication.
+//
+// ⚠️ WARNING: Change to the field name must preserve the INI key name for backward compatibility.
+type Config struct {
+	// The URL of the OpenID Provider, e.g. https://keycloak.example.com/realms/main.
+	// The discovery document is fetched from "/.well-known/openid-configuration"
+	// under it unless the URL already points to the document.
+	DiscoveryURL string `ini:"discovery_url"`
+	ClientID     string `ini:"client_id"`
+	ClientSecret string `ini:",omitempty"`
+	// Space-separated scopes to request in addition to "openid", defaults to
+	// "profile email".
+	Scopes string `ini:",omitempty"`
+
+	// Names of claims to map into the external account, the standard claims are
+	// used when empty.
+	UsernameClaim string `ini:",omitempty"` // Defaults to "preferred_username"
+	EmailClaim    string `ini:",omitempty"` // Defaults to "email"
+	FullNameClaim string `ini:",omitempty"` // Defaults to "name"
+	GroupsClaim   string `ini:",omitempty"` // Defaults to "groups"
+	// Members of the group are prompted as site admins.
+	AdminGroup string `ini:",omitempty"`
+
+	SkipVerify bool
+}
+
+// discovery is the subset of the OpenID Provider metadata that is needed, see
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (c *Config) httpClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.SkipVerify},
+		},
+	}
+}
+
+// getJSON sends the request and decodes the JSON response into v.
+func (c *Config) getJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		var respErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &respErr)
+		if respErr.Error != "" {
+			return errors.Newf("%s: %s %s", resp.Status, respErr.Error, respErr.ErrorDescription)
+		}
+		return errors.Newf("%s", resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (c *Config) discover(ctx context.Context) (*discovery, error) {
+	discoveryURL := c.DiscoveryURL
+	if !strings.HasSuffix(discoveryURL, "/.well-known/openid-configuration") {
+		discoveryURL = strings.TrimSuffix(discoveryURL, "/") + "/.well-known/openid-configuration"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+
+	var d discovery
+	if err = c.getJSON(req, &d); err != nil {
+		return nil, errors.Wrap(err, "fetch discovery document")
+	}
+	if d.Issuer == "" || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("incomplete discovery document")
+	}
+	return &d, nil
+}
+
+// RandomString returns a URL-safe random string that is suitable for the state,
+// nonce and PKCE code verifier.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge returns the S256 PKCE code challenge of the code verifier.
+func codeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (c *Config) scopes() string {
+	scopes := strings.Fields(c.Scopes)
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	return strings.Join(scopes, " ")
+}
+
+// AuthCodeURL returns the URL of the OpenID Provider to redirect the user to for
+// starting the authorization code flow with PKCE. The same state, nonce and code
+// verifier must be passed to Exchange after the user is redirected back.
+func (c *Config) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeVerifier string) (string, error) {
+	d, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "parse authorization endpoint")
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", c.scopes())
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange exchanges the authorization code for tokens, verifies the ID token
+// and returns the external account mapped from its claims, which are merged
+// with claims from the UserInfo endpoint when available.
+func (c *Config) Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (*auth.ExternalAccount, error) {
+	d, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	if c.ClientSecret == "" {
+		form.Set("client_id", c.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err = c.getJSON(req, &token); err != nil {
+		return nil, errors.Wrap(err, "exchange code")
+	} else if token.IDToken == "" {
+		return nil, errors.New("no ID token in the token response")
+	}
+
+	claims, err := c.verifyIDToken(ctx, d, token.IDToken, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "verify ID token")
+	}
+
+	if d.UserinfoEndpoint != "" && token.AccessToken != "" {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, d.UserinfoEndpoint, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "new request")
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		var userinfo map[string]any
+		if err = c.getJSON(req, &userinfo); err != nil {
+			return nil, errors.Wrap(err, "fetch userinfo")
+		}
+		// The UserInfo response must be about the same end-user as the ID token,
+		// see https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse.
+		if userinfo["sub"] != claims["sub"] {
+			return nil, errors.New("subject of userinfo does not match the ID token")
+		}
+		for k, v := range userinfo {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+	return c.externalAccount(claims)
+}
+
+func claimOr(name, defaultName string) string {
+	if name != "" {
+		return name
+	}
+	return defaultName
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	v, _ := claims[name].(string)
+	return strings.TrimSpace(v)
+}
+
+// externalAccount maps claims into an external account. The subject is used as
+// the login because it is the only claim that is guaranteed to be stable.
+func (c *Config) externalAccount(claims map[string]any) (*auth.ExternalAccount, error) {
+	sub := stringClaim(claims, "sub")
+	if sub == "" {
+		return nil, errors.New(`missing "sub" claim`)
+	}
+
+	usernameClaim := claimOr(c.UsernameClaim, "preferred_username")
+	username := stringClaim(claims, usernameClaim)
+	if username == "" {
+		return nil, errors.Newf("missing %q claim", usernameClaim)
+	}
+	emailClaim := claimOr(c.EmailClaim, "email")
+	email := stringClaim(claims, emailClaim)
+	if email == "" {
+		return nil, errors.Newf("missing %q claim", emailClaim)
+	}
+
+	var groups []string
+	switch v := claims[claimOr(c.GroupsClaim, "groups")].(type) {
+	case string:
+		groups = strings.Fields(v)
+	case []any:
+		for _, g := range v {
+			if g, ok := g.(string); ok {
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	return &auth.ExternalAccount{
+		Login:    sub,
+		Name:     username,
+		FullName: stringClaim(claims, claimOr(c.FullNameClaim, "name")),
+		Email:    email,
+		Groups:   groups,
+		Admin:    c.AdminGroup != "" && slices.Contains(groups, c.AdminGroup),
+	}, nil
+}
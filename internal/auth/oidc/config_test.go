@@ -0,0 +1,297 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/auth"
+)
+
+// testIdP is a stand-in OpenID Provider that issues ID tokens for a single
+// pending authorization.
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// The claims of the ID token to be issued, "iss" and "aud" are filled in
+	// when absent.
+	claims map[string]any
+	// The claims returned by the UserInfo endpoint.
+	userinfo map[string]any
+	// The key that signs the ID token, defaults to key.
+	signingKey *rsa.PrivateKey
+
+	code          string
+	codeChallenge string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	mux.HandleFunc("GET /realms/main/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.issuer(),
+			"authorization_endpoint": idp.URL + "/auth",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+			"jwks_uri":               idp.URL + "/certs",
+		})
+	})
+	mux.HandleFunc("GET /certs", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "key-1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "gogs" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != idp.code || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken(t),
+		})
+	})
+	mux.HandleFunc("GET /userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(idp.userinfo)
+	})
+	return idp
+}
+
+func (idp *testIdP) issuer() string {
+	return idp.URL + "/realms/main"
+}
+
+func (idp *testIdP) idToken(t *testing.T) string {
+	claims := map[string]any{
+		"iss": idp.issuer(),
+		"aud": "gogs",
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "key-1", "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	key := idp.signingKey
+	if key == nil {
+		key = idp.key
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize starts the flow and records the pending authorization as if the
+// user has signed in on the OpenID Provider.
+func (idp *testIdP) authorize(t *testing.T, cfg *Config, nonce, codeVerifier string) {
+	authURL, err := cfg.AuthCodeURL(context.Background(), "http://gogs.local/callback", "state", nonce, codeVerifier)
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+
+	q := u.Query()
+	assert.Equal(t, idp.URL+"/auth", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "gogs", q.Get("client_id"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	assert.Equal(t, "state", q.Get("state"))
+	assert.Equal(t, nonce, q.Get("nonce"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	idp.code = "code"
+	idp.codeChallenge = q.Get("code_challenge")
+}
+
+func TestConfig_Exchange(t *testing.T) {
+	idp := newTestIdP(t)
+	cfg := &Config{
+		DiscoveryURL: idp.issuer(),
+		ClientID:     "gogs",
+		ClientSecret: "secret",
+		AdminGroup:   "admins",
+	}
+	ctx := context.Background()
+
+	reset := func() {
+		idp.claims = map[string]any{
+			"sub":                "c0ffee",
+			"nonce":              "nonce",
+			"preferred_username": "alice",
+			"email":              "alice@example.com",
+		}
+		idp.userinfo = map[string]any{
+			"sub":    "c0ffee",
+			"name":   "Alice Liddell",
+			"groups": []string{"developers", "admins"},
+		}
+		idp.signingKey = nil
+	}
+
+	t.Run("success", func(t *testing.T) {
+		reset()
+		idp.authorize(t, cfg, "nonce", "verifier")
+
+		account, err := cfg.Exchange(ctx, "http://gogs.local/callback", "code", "verifier", "nonce")
+		require.NoError(t, err)
+		want := &auth.ExternalAccount{
+			Login:    "c0ffee",
+			Name:     "alice",
+			FullName: "Alice Liddell",
+			Email:    "alice@example.com",
+			Groups:   []string{"developers", "admins"},
+			Admin:    true,
+		}
+		assert.Equal(t, want, account)
+	})
+
+	t.Run("custom claims", func(t *testing.T) {
+		reset()
+		idp.claims["upn"] = "bob"
+		idp.claims["roles"] = []string{"developers"}
+		idp.authorize(t, cfg, "nonce", "verifier")
+
+		cfg := *cfg
+		cfg.UsernameClaim = "upn"
+		cfg.GroupsClaim = "roles"
+		account, err := cfg.Exchange(ctx, "http://gogs.local/callback", "code", "verifier", "nonce")
+		require.NoError(t, err)
+		assert.Equal(t, "bob", account.Name)
+		assert.Equal(t, []string{"developers"}, account.Groups)
+		assert.False(t, account.Admin)
+	})
+
+	tests := []struct {
+		name         string
+		setup        func()
+		codeVerifier string
+		expErr       string
+	}{
+		{
+			name:         "wrong code verifier",
+			codeVerifier: "wrong",
+			expErr:       "invalid_grant",
+		},
+		{
+			name:   "wrong nonce",
+			setup:  func() { idp.claims["nonce"] = "other" },
+			expErr: "nonce mismatch",
+		},
+		{
+			name: "bad signature",
+			setup: func() {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				require.NoError(t, err)
+				idp.signingKey = key
+			},
+			expErr: "verify signature",
+		},
+		{
+			name:   "wrong audience",
+			setup:  func() { idp.claims["aud"] = []string{"other"} },
+			expErr: "token is not issued for this client",
+		},
+		{
+			name:   "wrong issuer",
+			setup:  func() { idp.claims["iss"] = "https://evil.example.com" },
+			expErr: "unexpected issuer",
+		},
+		{
+			name:   "expired",
+			setup:  func() { idp.claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+			expErr: "token has expired",
+		},
+		{
+			name:   "userinfo of another subject",
+			setup:  func() { idp.userinfo["sub"] = "deadbeef" },
+			expErr: "subject of userinfo does not match",
+		},
+		{
+			name:   "missing email",
+			setup:  func() { delete(idp.claims, "email") },
+			expErr: `missing "email" claim`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reset()
+			if test.setup != nil {
+				test.setup()
+			}
+			idp.authorize(t, cfg, "nonce", "verifier")
+
+			codeVerifier := test.codeVerifier
+			if codeVerifier == "" {
+				codeVerifier = "verifier"
+			}
+			_, err := cfg.Exchange(ctx, "http://gogs.local/callback", "code", codeVerifier, "nonce")
+			assert.ErrorContains(t, err, test.expErr)
+		})
+	}
+}
+
+func TestVerifySignature_ECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	const signingInput = "header.payload"
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	assert.NoError(t, verifySignature("ES256", &key.PublicKey, signingInput, signature))
+	assert.Error(t, verifySignature("ES256", &key.PublicKey, "header.tampered", signature))
+	assert.Error(t, verifySignature("RS256", &key.PublicKey, signingInput, signature))
+}
@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// clockSkew is the tolerance of time differences between us and the OpenID
+// Provider when validating time-based claims.
+const clockSkew = time.Minute
+
+// jsonWebKey is a public key in a JSON Web Key Set, see
+// https://datatracker.ietf.org/doc/html/rfc7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode modulus")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode exponent")
+		} else if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Newf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode x")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode y")
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.Newf("unsupported key type %q", k.Kty)
+}
+
+// signingKey fetches the key set of the OpenID Provider and returns the public
+// key that signs tokens with the given key ID.
+func (c *Config) signingKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = c.getJSON(req, &jwks); err != nil {
+		return nil, errors.Wrap(err, "fetch key set")
+	}
+
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		// Tokens without a key ID can only be verified when there is no ambiguity.
+		if key.Kid == kid || (kid == "" && len(jwks.Keys) == 1) {
+			return key.publicKey()
+		}
+	}
+	return nil, errors.Newf("no signing key with ID %q", kid)
+}
+
+// verifySignature verifies the signature of the signing input with the public
+// key using the JWS algorithm, see https://datatracker.ietf.org/doc/html/rfc7518#section-3.1.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.Newf("algorithm %q does not match the key type", alg)
+		}
+		if alg[0] == 'R' {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+		return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.Newf("algorithm %q does not match the key type", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.Newf("unsupported algorithm %q", alg)
+}
+
+// supportedAlgs are the asymmetric JWS algorithms that are accepted for ID
+// tokens. Symmetric algorithms and "none" are deliberately not supported.
+var supportedAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// verifyIDToken verifies the signature and claims of the ID token and returns
+// its claims, see https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation.
+func (c *Config) verifyIDToken(ctx context.Context, d *discovery, idToken, nonce string) (map[string]any, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode header")
+	} else if err = json.Unmarshal(b, &header); err != nil {
+		return nil, errors.Wrap(err, "unmarshal header")
+	} else if !slices.Contains(supportedAlgs, header.Alg) {
+		return nil, errors.Newf("unsupported algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode signature")
+	}
+	key, err := c.signingKey(ctx, d.JWKSURI, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, errors.Wrap(err, "verify signature")
+	}
+
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode payload")
+	}
+	var claims map[string]any
+	if err = json.Unmarshal(b, &claims); err != nil {
+		return nil, errors.Wrap(err, "unmarshal payload")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != d.Issuer {
+		return nil, errors.Newf("unexpected issuer %q", iss)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if a, ok := a.(string); ok {
+				audiences = append(audiences, a)
+			}
+		}
+	}
+	if !slices.Contains(audiences, c.ClientID) {
+		return nil, errors.New("token is not issued for this client")
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New(`missing "exp" claim`)
+	} else if time.Unix(int64(exp), 0).Add(clockSkew).Before(time.Now()) {
+		return nil, errors.New("token has expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).Add(-clockSkew).After(time.Now()) {
+		return nil, errors.New("token is issued in the future")
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
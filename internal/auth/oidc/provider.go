@@ -0,0 +1,39 @@
+package oidc
+
+import (
+	"gogs.io/gogs/internal/auth"
+)
+
+// Provider contains configuration of an OpenID Connect authentication provider.
+type Provider struct {
+	config *Config
+}
+
+// NewProvider creates a new OpenID Connect authentication provider.
+func NewProvider(cfg *Config) auth.Provider {
+	return &Provider{
+		config: cfg,
+	}
+}
+
+// Authenticate always returns auth.ErrBadCredentials because users sign in
+// through the OpenID Provider instead of with a password, see Config.AuthCodeURL.
+func (*Provider) Authenticate(login, _ string) (*auth.ExternalAccount, error) {
+	return nil, auth.ErrBadCredentials{Args: map[string]any{"login": login}}
+}
+
+func (p *Provider) Config() any {
+	return p.config
+}
+
+func (*Provider) HasTLS() bool {
+	return true
+}
+
+func (*Provider) UseTLS() bool {
+	return true
+}
+
+func (p *Provider) SkipTLSVerify() bool {
+	return p.config.SkipVerify
+}
@@ -0,0 +1,303 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Config contains configuration for SAML 2.0 authentication, where Gogs is
+// the service provider.
+//
+// ⚠️ WARNING: Change to the field name must preserve the INI key name for backward compatibility.
+type Config struct {
+	// The path of the metadata file of the identity provider, which contains its
+	// entity ID, single sign-on service and signing certificates.
+	IdPMetadataFile string `ini:"idp_metadata_file"`
+	// The entity ID of Gogs as the service provider, defaults to the URL of its
+	// metadata.
+	EntityID string `ini:"entity_id,omitempty"`
+
+	// The paths of the PEM-encoded certificate and RSA private key for signing
+	// authentication requests. Requests are not signed when empty.
+	SigningCertFile string `ini:",omitempty"`
+	SigningKeyFile  string `ini:",omitempty"`
+	// The paths of the PEM-encoded certificate and RSA private key for
+	// decrypting assertions. Assertions must not be encrypted when empty.
+	EncryptionCertFile string `ini:",omitempty"`
+	EncryptionKeyFile  string `ini:",omitempty"`
+
+	// Names (or friendly names) of attributes to map into the external account.
+	UsernameAttribute string `ini:",omitempty"` // Defaults to the name ID
+	EmailAttribute    string `ini:",omitempty"` // Defaults to "email"
+	FullNameAttribute string `ini:",omitempty"` // Defaults to "displayName"
+	GroupsAttribute   string `ini:",omitempty"` // Defaults to "groups"
+	// Members of the group are made site admins when their accounts are created.
+	AdminGroup string `ini:",omitempty"`
+}
+
+// Endpoints are the URLs of Gogs as the service provider of a login source.
+type Endpoints struct {
+	// The URL of the service provider metadata.
+	MetadataURL string
+	// The URL of the assertion consumer service, where the identity provider
+	// posts SAML responses to.
+	ACSURL string
+}
+
+func (c *Config) entityID(ep Endpoints) string {
+	if c.EntityID != "" {
+		return c.EntityID
+	}
+	return ep.MetadataURL
+}
+
+// NewRequestID returns a random ID for an authentication request.
+func NewRequestID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// IDs must not start with a digit.
+	return "_" + hex.EncodeToString(b), nil
+}
+
+func readPEM(path, typ string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read file")
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.Newf("no %s found in %q", typ, path)
+		} else if strings.HasSuffix(block.Type, typ) {
+			return block, nil
+		}
+	}
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	block, err := readPEM(path, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func loadRSAKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Newf("%q is not an RSA private key", path)
+	}
+	return rsaKey, nil
+}
+
+// idp contains the information of the identity provider from its metadata.
+type idp struct {
+	entityID string
+	// The URL of the single sign-on service with the HTTP-Redirect binding.
+	ssoURL string
+	certs  []*x509.Certificate
+}
+
+// loadIdP loads the identity provider from the metadata file, which is trusted
+// as is. The metadata may be an EntityDescriptor or an EntitiesDescriptor, in
+// which case the first identity provider is used.
+func (c *Config) loadIdP() (*idp, error) {
+	data, err := os.ReadFile(c.IdPMetadataFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read identity provider metadata")
+	}
+	root, err := parseXML(data, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse identity provider metadata")
+	}
+
+	entity := root
+	if root.is(nsMetadata, "EntitiesDescriptor") {
+		entity = nil
+		for _, e := range root.elements(nsMetadata, "EntityDescriptor") {
+			if e.element(nsMetadata, "IDPSSODescriptor") != nil {
+				entity = e
+				break
+			}
+		}
+	}
+	if entity == nil || !entity.is(nsMetadata, "EntityDescriptor") {
+		return nil, errors.New("no entity descriptor of identity provider in metadata")
+	}
+	descriptor := entity.element(nsMetadata, "IDPSSODescriptor")
+	if descriptor == nil {
+		return nil, errors.New("no IDPSSODescriptor in metadata")
+	}
+
+	p := &idp{entityID: entity.attr("entityID")}
+	if p.entityID == "" {
+		return nil, errors.New("no entity ID in metadata")
+	}
+	for _, sso := range descriptor.elements(nsMetadata, "SingleSignOnService") {
+		if sso.attr("Binding") == bindingHTTPRedirect {
+			p.ssoURL = sso.attr("Location")
+			break
+		}
+	}
+	if p.ssoURL == "" {
+		return nil, errors.New("no single sign-on service with HTTP-Redirect binding in metadata")
+	}
+
+	for _, kd := range descriptor.elements(nsMetadata, "KeyDescriptor") {
+		if use := kd.attr("use"); use != "" && use != "signing" {
+			continue
+		}
+		keyInfo := kd.element(nsDSig, "KeyInfo")
+		if keyInfo == nil {
+			continue
+		}
+		for _, data := range keyInfo.elements(nsDSig, "X509Data") {
+			for _, cert := range data.elements(nsDSig, "X509Certificate") {
+				der, err := decodeBase64(cert.text())
+				if err != nil {
+					return nil, errors.Wrap(err, "decode signing certificate")
+				}
+				parsed, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, errors.Wrap(err, "parse signing certificate")
+				}
+				p.certs = append(p.certs, parsed)
+			}
+		}
+	}
+	if len(p.certs) == 0 {
+		return nil, errors.New("no signing certificate in metadata")
+	}
+	return p, nil
+}
+
+const (
+	bindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	nameIDPersistent    = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	nameIDTransient     = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+)
+
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	escapeAttr(&buf, s)
+	return buf.String()
+}
+
+func writeKeyDescriptor(buf *bytes.Buffer, use, certFile string) error {
+	cert, err := loadCertificate(certFile)
+	if err != nil {
+		return errors.Wrapf(err, "load %s certificate", use)
+	}
+	fmt.Fprintf(buf, `<md:KeyDescriptor use="%s"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>`,
+		use, base64.StdEncoding.EncodeToString(cert.Raw))
+	if use == "encryption" {
+		for _, alg := range []string{algAES256GCM, algAES128GCM, algAES256CBC, algAES128CBC, algRSAOAEPMGF1} {
+			fmt.Fprintf(buf, `<md:EncryptionMethod Algorithm="%s"/>`, alg)
+		}
+	}
+	buf.WriteString(`</md:KeyDescriptor>`)
+	return nil
+}
+
+// Metadata returns the service provider metadata to be registered with the
+// identity provider.
+func (c *Config) Metadata(ep Endpoints) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	fmt.Fprintf(&buf, `<md:EntityDescriptor xmlns:md="%s" xmlns:ds="%s" entityID="%s">`, nsMetadata, nsDSig, escapeXML(c.entityID(ep)))
+	fmt.Fprintf(&buf, `<md:SPSSODescriptor AuthnRequestsSigned="%t" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`,
+		c.SigningKeyFile != "", nsProtocol)
+	if c.SigningCertFile != "" {
+		if err := writeKeyDescriptor(&buf, "signing", c.SigningCertFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.EncryptionCertFile != "" {
+		if err := writeKeyDescriptor(&buf, "encryption", c.EncryptionCertFile); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&buf, `<md:NameIDFormat>%s</md:NameIDFormat>`, nameIDPersistent)
+	fmt.Fprintf(&buf, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, bindingHTTPPost, escapeXML(ep.ACSURL))
+	buf.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	return buf.Bytes(), nil
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// AuthnRequestURL returns the URL of the identity provider to redirect the user
+// to for signing in with the HTTP-Redirect binding, see
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf. The
+// same request ID must be passed to ParseResponse.
+func (c *Config) AuthnRequestURL(ep Endpoints, requestID string, now time.Time) (string, error) {
+	p, err := c.loadIdP()
+	if err != nil {
+		return "", err
+	}
+
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`+
+		`<saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy AllowCreate="true"/></samlp:AuthnRequest>`,
+		nsProtocol, nsAssertion, escapeXML(requestID), now.UTC().Format(time.RFC3339), escapeXML(p.ssoURL), escapeXML(ep.ACSURL), bindingHTTPPost,
+		escapeXML(c.entityID(ep)),
+	)
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", errors.Wrap(err, "new flate writer")
+	}
+	_, _ = w.Write([]byte(request))
+	if err = w.Close(); err != nil {
+		return "", errors.Wrap(err, "deflate request")
+	}
+
+	// The signature is computed over the query string exactly as it is sent.
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if c.SigningKeyFile != "" {
+		key, err := loadRSAKey(c.SigningKeyFile)
+		if err != nil {
+			return "", errors.Wrap(err, "load signing key")
+		}
+		query += "&SigAlg=" + url.QueryEscape(algRSASHA256)
+		hashed := sha256.Sum256([]byte(query))
+		signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, hashed[:])
+		if err != nil {
+			return "", errors.Wrap(err, "sign request")
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	if strings.Contains(p.ssoURL, "?") {
+		return p.ssoURL + "&" + query, nil
+	}
+	return p.ssoURL + "?" + query, nil
+}
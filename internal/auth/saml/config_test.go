@@ -0,0 +1,109 @@
+package saml
+
+import (
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_AuthnRequestURL(t *testing.T) {
+	p := newTestIdP(t)
+
+	parseRequest := func(t *testing.T, rawURL string) (*url.URL, *element) {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, "https://idp.example.com/sso", u.Scheme+"://"+u.Host+u.Path)
+		assert.Equal(t, "main", u.Query().Get("tenant"))
+
+		deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+		require.NoError(t, err)
+		data, err := io.ReadAll(flate.NewReader(strings.NewReader(string(deflated))))
+		require.NoError(t, err)
+		request, err := parseXML(data, nil)
+		require.NoError(t, err)
+		require.True(t, request.is(nsProtocol, "AuthnRequest"))
+		return u, request
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		got, err := p.config.AuthnRequestURL(testEndpoints, testRequestID, testNow)
+		require.NoError(t, err)
+
+		u, request := parseRequest(t, got)
+		assert.Empty(t, u.Query().Get("Signature"))
+		assert.Equal(t, testRequestID, request.attr("ID"))
+		assert.Equal(t, "2026-01-02T03:04:05Z", request.attr("IssueInstant"))
+		assert.Equal(t, "https://idp.example.com/sso?tenant=main", request.attr("Destination"))
+		assert.Equal(t, testEndpoints.ACSURL, request.attr("AssertionConsumerServiceURL"))
+		assert.Equal(t, bindingHTTPPost, request.attr("ProtocolBinding"))
+		assert.Equal(t, testEndpoints.MetadataURL, request.element(nsAssertion, "Issuer").text())
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		config := *p.config
+		config.EntityID = "gogs"
+		signingKey, signingCertFile, signingKeyFile := testKeyPair(t, t.TempDir(), "signing")
+		config.SigningCertFile = signingCertFile
+		config.SigningKeyFile = signingKeyFile
+
+		got, err := config.AuthnRequestURL(testEndpoints, testRequestID, testNow)
+		require.NoError(t, err)
+
+		u, request := parseRequest(t, got)
+		assert.Equal(t, "gogs", request.element(nsAssertion, "Issuer").text())
+		assert.Equal(t, algRSASHA256, u.Query().Get("SigAlg"))
+
+		// The signature covers the query parameters of the request as they are sent.
+		signed := u.RawQuery[strings.Index(u.RawQuery, "SAMLRequest="):strings.Index(u.RawQuery, "&Signature=")]
+		hashed := sha256.Sum256([]byte(signed))
+		signature, err := base64.StdEncoding.DecodeString(u.Query().Get("Signature"))
+		require.NoError(t, err)
+		err = rsa.VerifyPKCS1v15(&signingKey.PublicKey, crypto.SHA256, hashed[:], signature)
+		assert.NoError(t, err)
+	})
+
+	t.Run("no metadata", func(t *testing.T) {
+		config := Config{IdPMetadataFile: "404.xml"}
+		_, err := config.AuthnRequestURL(testEndpoints, testRequestID, testNow)
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_Metadata(t *testing.T) {
+	p := newTestIdP(t)
+
+	got, err := p.config.Metadata(testEndpoints)
+	require.NoError(t, err)
+	entity, err := parseXML(got, nil)
+	require.NoError(t, err)
+	require.True(t, entity.is(nsMetadata, "EntityDescriptor"))
+	assert.Equal(t, testEndpoints.MetadataURL, entity.attr("entityID"))
+
+	descriptor := entity.element(nsMetadata, "SPSSODescriptor")
+	require.NotNil(t, descriptor)
+	assert.Equal(t, "false", descriptor.attr("AuthnRequestsSigned"))
+	assert.Equal(t, "true", descriptor.attr("WantAssertionsSigned"))
+
+	keyDescriptors := descriptor.elements(nsMetadata, "KeyDescriptor")
+	require.Len(t, keyDescriptors, 1)
+	assert.Equal(t, "encryption", keyDescriptors[0].attr("use"))
+	der, err := decodeBase64(keyDescriptors[0].element(nsDSig, "KeyInfo").element(nsDSig, "X509Data").element(nsDSig, "X509Certificate").text())
+	require.NoError(t, err)
+	cert, err := loadCertificate(p.config.EncryptionCertFile)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, der)
+
+	acs := descriptor.element(nsMetadata, "AssertionConsumerService")
+	require.NotNil(t, acs)
+	assert.Equal(t, bindingHTTPPost, acs.attr("Binding"))
+	assert.Equal(t, testEndpoints.ACSURL, acs.attr("Location"))
+}
@@ -0,0 +1,40 @@
+package saml
+
+import (
+	"gogs.io/gogs/internal/auth"
+)
+
+// Provider contains configuration of a SAML 2.0 authentication provider.
+type Provider struct {
+	config *Config
+}
+
+// NewProvider creates a new SAML 2.0 authentication provider.
+func NewProvider(cfg *Config) auth.Provider {
+	return &Provider{
+		config: cfg,
+	}
+}
+
+// Authenticate always returns auth.ErrBadCredentials because users sign in
+// through the identity provider instead of with a password, see
+// Config.AuthnRequestURL.
+func (*Provider) Authenticate(login, _ string) (*auth.ExternalAccount, error) {
+	return nil, auth.ErrBadCredentials{Args: map[string]any{"login": login}}
+}
+
+func (p *Provider) Config() any {
+	return p.config
+}
+
+func (*Provider) HasTLS() bool {
+	return false
+}
+
+func (*Provider) UseTLS() bool {
+	return false
+}
+
+func (*Provider) SkipTLSVerify() bool {
+	return false
+}
@@ -0,0 +1,261 @@
+package saml
+
+import (
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"gogs.io/gogs/internal/auth"
+)
+
+// clockSkew is the tolerance of time differences between us and the identity
+// provider when validating time-based conditions.
+const clockSkew = 3 * time.Minute
+
+const (
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	confirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	maxResponseSize    = 1 << 20
+)
+
+// ParseResponse verifies the base64-encoded SAML response that is posted to
+// the assertion consumer service in reply to the authentication request with
+// the given ID, and returns the external account mapped from its assertion,
+// see https://docs.oasis-open.org/security/saml/v2.0/saml-profiles-2.0-os.pdf.
+//
+// Either the response or the assertion must be signed by the identity
+// provider. Encrypted assertions are decrypted with the encryption key.
+func (c *Config) ParseResponse(ep Endpoints, requestID, samlResponse string, now time.Time) (*auth.ExternalAccount, error) {
+	p, err := c.loadIdP()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(samlResponse) > maxResponseSize {
+		return nil, errors.New("response is too large")
+	}
+	data, err := decodeBase64(samlResponse)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	response, err := parseXML(data, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse response")
+	} else if !response.is(nsProtocol, "Response") {
+		return nil, errors.New("not a SAML response")
+	}
+
+	if response.attr("Version") != "2.0" {
+		return nil, errors.Newf("unsupported version %q", response.attr("Version"))
+	} else if requestID == "" || response.attr("InResponseTo") != requestID {
+		return nil, errors.New("response is not in response to the request")
+	} else if dest := response.attr("Destination"); dest != "" && dest != ep.ACSURL {
+		return nil, errors.Newf("unexpected destination %q", dest)
+	} else if issuer := response.element(nsAssertion, "Issuer"); issuer != nil && issuer.text() != p.entityID {
+		return nil, errors.Newf("unexpected issuer %q", issuer.text())
+	}
+
+	var statusCode string
+	if status := response.element(nsProtocol, "Status"); status != nil {
+		if code := status.element(nsProtocol, "StatusCode"); code != nil {
+			statusCode = code.attr("Value")
+		}
+	}
+	if statusCode != statusSuccess {
+		return nil, errors.Newf("unsuccessful status %q", statusCode)
+	}
+
+	responseSigned := true
+	err = verifySignature(response, p.certs)
+	if errors.Is(err, errNotSigned) {
+		responseSigned = false
+	} else if err != nil {
+		return nil, errors.Wrap(err, "verify response signature")
+	}
+
+	assertions := response.elements(nsAssertion, "Assertion")
+	encryptedAssertions := response.elements(nsAssertion, "EncryptedAssertion")
+	if len(assertions)+len(encryptedAssertions) != 1 {
+		return nil, errors.New("response must contain exactly one assertion")
+	}
+
+	var assertion *element
+	if len(assertions) == 1 {
+		assertion = assertions[0]
+	} else {
+		if c.EncryptionKeyFile == "" {
+			return nil, errors.New("assertion is encrypted but no encryption key is configured")
+		}
+		key, err := loadRSAKey(c.EncryptionKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load encryption key")
+		}
+		plaintext, err := decryptElement(encryptedAssertions[0], key)
+		if err != nil {
+			return nil, errors.Wrap(err, "decrypt assertion")
+		}
+		assertion, err = parseXML(plaintext, response)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse decrypted assertion")
+		} else if !assertion.is(nsAssertion, "Assertion") {
+			return nil, errors.New("decrypted data is not an assertion")
+		}
+	}
+
+	err = verifySignature(assertion, p.certs)
+	if errors.Is(err, errNotSigned) {
+		if !responseSigned {
+			return nil, errors.New("neither the response nor the assertion is signed")
+		}
+	} else if err != nil {
+		return nil, errors.Wrap(err, "verify assertion signature")
+	}
+
+	if err = c.validateAssertion(assertion, p, ep, requestID, now); err != nil {
+		return nil, err
+	}
+	return c.externalAccount(assertion)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// validateAssertion validates the issuer, subject confirmation and conditions
+// of the assertion.
+func (c *Config) validateAssertion(assertion *element, p *idp, ep Endpoints, requestID string, now time.Time) error {
+	if assertion.attr("Version") != "2.0" {
+		return errors.Newf("unsupported assertion version %q", assertion.attr("Version"))
+	}
+	issuer := assertion.element(nsAssertion, "Issuer")
+	if issuer == nil || issuer.text() != p.entityID {
+		return errors.New("assertion is not issued by the identity provider")
+	}
+
+	subject := assertion.element(nsAssertion, "Subject")
+	if subject == nil {
+		return errors.New("no subject in assertion")
+	}
+	var confirmed bool
+	for _, confirmation := range subject.elements(nsAssertion, "SubjectConfirmation") {
+		if confirmation.attr("Method") != confirmationBearer {
+			continue
+		}
+		data := confirmation.element(nsAssertion, "SubjectConfirmationData")
+		if data == nil || data.attr("Recipient") != ep.ACSURL {
+			continue
+		} else if inResponseTo := data.attr("InResponseTo"); inResponseTo != "" && inResponseTo != requestID {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data.attr("NotOnOrAfter"))
+		if err != nil || !now.Before(notOnOrAfter.Add(clockSkew)) {
+			continue
+		}
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return errors.New("no valid bearer subject confirmation")
+	}
+
+	conditions := assertion.element(nsAssertion, "Conditions")
+	if conditions == nil {
+		return nil
+	}
+	if v := conditions.attr("NotBefore"); v != "" {
+		notBefore, err := parseTime(v)
+		if err != nil {
+			return errors.Wrap(err, "parse NotBefore")
+		} else if now.Add(clockSkew).Before(notBefore) {
+			return errors.New("assertion is not yet valid")
+		}
+	}
+	if v := conditions.attr("NotOnOrAfter"); v != "" {
+		notOnOrAfter, err := parseTime(v)
+		if err != nil {
+			return errors.Wrap(err, "parse NotOnOrAfter")
+		} else if !now.Before(notOnOrAfter.Add(clockSkew)) {
+			return errors.New("assertion has expired")
+		}
+	}
+	entityID := c.entityID(ep)
+	for _, restriction := range conditions.elements(nsAssertion, "AudienceRestriction") {
+		var found bool
+		for _, audience := range restriction.elements(nsAssertion, "Audience") {
+			if audience.text() == entityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("assertion is not intended for the service provider")
+		}
+	}
+	return nil
+}
+
+func attributeOr(name, defaultName string) string {
+	if name != "" {
+		return name
+	}
+	return defaultName
+}
+
+// externalAccount maps the name ID and attributes of the assertion into an
+// external account. The name ID is used as the login, thus it must be
+// persistent.
+func (c *Config) externalAccount(assertion *element) (*auth.ExternalAccount, error) {
+	nameID := assertion.element(nsAssertion, "Subject").element(nsAssertion, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return nil, errors.New("no name ID in assertion")
+	} else if nameID.attr("Format") == nameIDTransient {
+		return nil, errors.New("transient name ID cannot identify the account")
+	}
+
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.elements(nsAssertion, "AttributeStatement") {
+		for _, attr := range statement.elements(nsAssertion, "Attribute") {
+			var values []string
+			for _, value := range attr.elements(nsAssertion, "AttributeValue") {
+				if v := value.text(); v != "" {
+					values = append(values, v)
+				}
+			}
+			name, friendlyName := attr.attr("Name"), attr.attr("FriendlyName")
+			attributes[name] = append(attributes[name], values...)
+			if friendlyName != "" && friendlyName != name {
+				attributes[friendlyName] = append(attributes[friendlyName], values...)
+			}
+		}
+	}
+	attribute := func(name string) string {
+		if values := attributes[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	username := nameID.text()
+	if c.UsernameAttribute != "" {
+		username = attribute(c.UsernameAttribute)
+		if username == "" {
+			return nil, errors.Newf("missing %q attribute", c.UsernameAttribute)
+		}
+	}
+	emailAttribute := attributeOr(c.EmailAttribute, "email")
+	email := attribute(emailAttribute)
+	if email == "" {
+		return nil, errors.Newf("missing %q attribute", emailAttribute)
+	}
+	groups := attributes[attributeOr(c.GroupsAttribute, "groups")]
+
+	return &auth.ExternalAccount{
+		Login:    nameID.text(),
+		Name:     username,
+		FullName: attribute(attributeOr(c.FullNameAttribute, "displayName")),
+		Email:    email,
+		Groups:   groups,
+		Admin:    c.AdminGroup != "" && slices.Contains(groups, c.AdminGroup),
+	}, nil
+}
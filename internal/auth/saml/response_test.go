@@ -0,0 +1,448 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/auth"
+)
+
+var (
+	testNow       = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	testEndpoints = Endpoints{
+		MetadataURL: "https://gogs.example.com/api/web/user/saml/1/metadata",
+		ACSURL:      "https://gogs.example.com/user/saml/1/acs",
+	}
+)
+
+const (
+	testIdPEntityID = "https://idp.example.com/metadata"
+	testRequestID   = "_c0ffee"
+)
+
+// testKeyPair writes a new RSA private key and a self-signed certificate of it
+// to the directory, and returns the key and the paths of both files.
+func testKeyPair(t *testing.T, dir, name string) (key *rsa.PrivateKey, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader,
+		&x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    testNow.Add(-time.Hour),
+			NotAfter:     testNow.Add(time.Hour),
+		},
+		&x509.Certificate{Subject: pkix.Name{CommonName: name}},
+		&key.PublicKey,
+		key,
+	)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	require.NoError(t, err)
+	keyFile = filepath.Join(dir, name+".key")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600)
+	require.NoError(t, err)
+	return key, certFile, keyFile
+}
+
+type testIdP struct {
+	key *rsa.PrivateKey
+	// The configuration of the service provider that trusts the identity
+	// provider.
+	config *Config
+	// The public key of the service provider to encrypt assertions with.
+	encryptionKey *rsa.PublicKey
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	dir := t.TempDir()
+	idpKey, idpCertFile, _ := testKeyPair(t, dir, "idp")
+	idpCert, err := loadCertificate(idpCertFile)
+	require.NoError(t, err)
+	metadata := fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="%s" xmlns:ds="%s" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="%s">
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>bm90IGEgY2VydGlmaWNhdGU=</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="%s" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="%s" Location="https://idp.example.com/sso?tenant=main"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`,
+		nsMetadata, nsDSig, testIdPEntityID, nsProtocol,
+		base64.StdEncoding.EncodeToString(idpCert.Raw),
+		bindingHTTPPost, bindingHTTPRedirect,
+	)
+	metadataFile := filepath.Join(dir, "idp.xml")
+	err = os.WriteFile(metadataFile, []byte(metadata), 0o600)
+	require.NoError(t, err)
+
+	encryptionKey, encryptionCertFile, encryptionKeyFile := testKeyPair(t, dir, "encryption")
+	return &testIdP{
+		key: idpKey,
+		config: &Config{
+			IdPMetadataFile:    metadataFile,
+			EncryptionCertFile: encryptionCertFile,
+			EncryptionKeyFile:  encryptionKeyFile,
+			GroupsAttribute:    "memberOf",
+			AdminGroup:         "admins",
+		},
+		encryptionKey: &encryptionKey.PublicKey,
+	}
+}
+
+// sign returns the document with an enveloped signature of the element with
+// the given ID, which replaces the "<!--sig:ID-->" comment.
+func (p *testIdP) sign(t *testing.T, doc, id string) string {
+	t.Helper()
+
+	root, err := parseXML([]byte(doc), nil)
+	require.NoError(t, err)
+	var find func(e *element) *element
+	find = func(e *element) *element {
+		if e.attr("ID") == id {
+			return e
+		}
+		for _, child := range e.children {
+			if child, ok := child.(*element); ok {
+				if found := find(child); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+	signed := find(root)
+	require.NotNil(t, signed)
+
+	digest := sha256.Sum256(canonicalize(signed, nil, nil))
+	signedInfo := fmt.Sprintf(`<ds:SignedInfo xmlns:ds="%s"><ds:CanonicalizationMethod Algorithm="%s"/><ds:SignatureMethod Algorithm="%s"/>`+
+		`<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/></ds:Transforms>`+
+		`<ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`,
+		nsDSig, nsExcC14N, algRSASHA256, id, algEnvelopedSignature, nsExcC14N, algSHA256, base64.StdEncoding.EncodeToString(digest[:]),
+	)
+	signedInfoElem, err := parseXML([]byte(signedInfo), nil)
+	require.NoError(t, err)
+	hashed := sha256.Sum256(canonicalize(signedInfoElem, nil, nil))
+	signature, err := rsa.SignPKCS1v15(nil, p.key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+
+	sig := fmt.Sprintf(`<ds:Signature xmlns:ds="%s">%s<ds:SignatureValue>%s</ds:SignatureValue></ds:Signature>`,
+		nsDSig, signedInfo, base64.StdEncoding.EncodeToString(signature))
+	placeholder := "<!--sig:" + id + "-->"
+	require.Contains(t, doc, placeholder)
+	return strings.Replace(doc, placeholder, sig, 1)
+}
+
+// encrypt returns the EncryptedAssertion of the assertion with the given
+// block cipher.
+func (p *testIdP) encrypt(t *testing.T, assertion, alg string) string {
+	t.Helper()
+
+	keySize := blockCiphers[alg].keySize
+	cek := make([]byte, keySize)
+	_, err := rand.Read(cek)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+
+	var ciphertext []byte
+	if blockCiphers[alg].gcm {
+		aead, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+		nonce := make([]byte, aead.NonceSize())
+		_, err = rand.Read(nonce)
+		require.NoError(t, err)
+		ciphertext = aead.Seal(nonce, nonce, []byte(assertion), nil)
+	} else {
+		padding := aes.BlockSize - len(assertion)%aes.BlockSize
+		plaintext := append([]byte(assertion), make([]byte, padding)...)
+		plaintext[len(plaintext)-1] = byte(padding)
+		ciphertext = make([]byte, aes.BlockSize+len(plaintext))
+		_, err = rand.Read(ciphertext[:aes.BlockSize])
+		require.NoError(t, err)
+		cipher.NewCBCEncrypter(block, ciphertext[:aes.BlockSize]).CryptBlocks(ciphertext[aes.BlockSize:], plaintext)
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, p.encryptionKey, cek, nil)
+	require.NoError(t, err)
+	return fmt.Sprintf(`<saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="%s" Type="http://www.w3.org/2001/04/xmlenc#Element">`+
+		`<xenc:EncryptionMethod Algorithm="%s"/><ds:KeyInfo xmlns:ds="%s"><xenc:EncryptedKey><xenc:EncryptionMethod Algorithm="%s"><ds:DigestMethod Algorithm="%s"/></xenc:EncryptionMethod>`+
+		`<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData></xenc:EncryptedKey></ds:KeyInfo>`+
+		`<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData></xenc:EncryptedData></saml:EncryptedAssertion>`,
+		nsXMLEnc, alg, nsDSig, algRSAOAEPMGF1, algSHA1,
+		base64.StdEncoding.EncodeToString(encryptedKey), base64.StdEncoding.EncodeToString(ciphertext),
+	)
+}
+
+// testAssertion returns an unsigned assertion with the "<!--sig:_assertion-->"
+// placeholder, which uses the "saml" prefix declared by the response.
+func testAssertion() string {
+	return `<saml:Assertion ID="_assertion" Version="2.0" IssueInstant="2026-01-02T03:04:00Z">
+  <saml:Issuer>` + testIdPEntityID + `</saml:Issuer><!--sig:_assertion-->
+  <saml:Subject>
+    <saml:NameID Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent">c0ffee</saml:NameID>
+    <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+      <saml:SubjectConfirmationData InResponseTo="` + testRequestID + `" NotOnOrAfter="2026-01-02T03:09:00Z" Recipient="` + testEndpoints.ACSURL + `"/>
+    </saml:SubjectConfirmation>
+  </saml:Subject>
+  <saml:Conditions NotBefore="2026-01-02T03:04:00Z" NotOnOrAfter="2026-01-02T03:09:00Z">
+    <saml:AudienceRestriction><saml:Audience>` + testEndpoints.MetadataURL + `</saml:Audience></saml:AudienceRestriction>
+  </saml:Conditions>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="email"><saml:AttributeValue>alice@example.com</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="displayName"><saml:AttributeValue>Alice &amp; Co</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="memberOf"><saml:AttributeValue>admins</saml:AttributeValue><saml:AttributeValue>developers</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`
+}
+
+// testResponse returns an unsigned response with the "<!--sig:_response-->"
+// placeholder that contains the assertion.
+func testResponse(assertion string) string {
+	return `<samlp:Response xmlns:samlp="` + nsProtocol + `" xmlns:saml="` + nsAssertion + `" ID="_response" Version="2.0" IssueInstant="2026-01-02T03:04:00Z" Destination="` + testEndpoints.ACSURL + `" InResponseTo="` + testRequestID + `">
+  <saml:Issuer>` + testIdPEntityID + `</saml:Issuer><!--sig:_response-->
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  ` + assertion + `
+</samlp:Response>`
+}
+
+func encodeResponse(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestConfig_ParseResponse(t *testing.T) {
+	p := newTestIdP(t)
+	wantAccount := &auth.ExternalAccount{
+		Login:    "c0ffee",
+		Name:     "c0ffee",
+		FullName: "Alice & Co",
+		Email:    "alice@example.com",
+		Groups:   []string{"admins", "developers"},
+		Admin:    true,
+	}
+
+	tests := []struct {
+		name string
+		doc  func(t *testing.T) string
+	}{
+		{
+			name: "signed assertion",
+			doc: func(t *testing.T) string {
+				return p.sign(t, testResponse(testAssertion()), "_assertion")
+			},
+		},
+		{
+			name: "signed response",
+			doc: func(t *testing.T) string {
+				return p.sign(t, testResponse(testAssertion()), "_response")
+			},
+		},
+		{
+			name: "signed response and assertion",
+			doc: func(t *testing.T) string {
+				return p.sign(t, p.sign(t, testResponse(testAssertion()), "_assertion"), "_response")
+			},
+		},
+		{
+			name: "encrypted assertion with AES-GCM",
+			doc: func(t *testing.T) string {
+				assertion := p.sign(t, testResponse(testAssertion()), "_assertion")
+				start := strings.Index(assertion, "<saml:Assertion")
+				end := strings.Index(assertion, "</saml:Assertion>") + len("</saml:Assertion>")
+				return testResponse(p.encrypt(t, assertion[start:end], algAES256GCM))
+			},
+		},
+		{
+			name: "encrypted assertion with AES-CBC in signed response",
+			doc: func(t *testing.T) string {
+				return p.sign(t, testResponse(p.encrypt(t, testAssertion(), algAES128CBC)), "_response")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := p.config.ParseResponse(testEndpoints, testRequestID, encodeResponse(test.doc(t)), testNow)
+			require.NoError(t, err)
+			assert.Equal(t, wantAccount, got)
+		})
+	}
+
+	t.Run("attribute mapping", func(t *testing.T) {
+		config := *p.config
+		config.UsernameAttribute = "email"
+		config.EmailAttribute = "urn:oid:0.9.2342.19200300.100.1.3"
+		config.FullNameAttribute = "cn"
+		config.AdminGroup = "owners"
+		got, err := config.ParseResponse(testEndpoints, testRequestID, encodeResponse(p.sign(t, testResponse(testAssertion()), "_response")), testNow)
+		require.NoError(t, err)
+		assert.Equal(t,
+			&auth.ExternalAccount{
+				Login:  "c0ffee",
+				Name:   "alice@example.com",
+				Email:  "alice@example.com",
+				Groups: []string{"admins", "developers"},
+			},
+			got,
+		)
+	})
+}
+
+func TestConfig_ParseResponse_Errors(t *testing.T) {
+	p := newTestIdP(t)
+	other := newTestIdP(t)
+
+	signedAssertion := func(t *testing.T, replacer *strings.Replacer) string {
+		return p.sign(t, testResponse(replacer.Replace(testAssertion())), "_assertion")
+	}
+
+	tests := []struct {
+		name      string
+		doc       func(t *testing.T) string
+		requestID string
+		now       time.Time
+		wantErr   string
+	}{
+		{
+			name:    "not signed",
+			doc:     func(*testing.T) string { return testResponse(testAssertion()) },
+			wantErr: "neither the response nor the assertion is signed",
+		},
+		{
+			name:    "signed by another identity provider",
+			doc:     func(t *testing.T) string { return other.sign(t, testResponse(testAssertion()), "_response") },
+			wantErr: "signature is not signed by any of the certificates",
+		},
+		{
+			name: "tampered after signing",
+			doc: func(t *testing.T) string {
+				return strings.Replace(p.sign(t, testResponse(testAssertion()), "_assertion"), ">c0ffee<", ">admin<", 1)
+			},
+			wantErr: "digest mismatch",
+		},
+		{
+			name: "signature wrapping",
+			doc: func(t *testing.T) string {
+				// The signed assertion is moved into an extension of the response,
+				// and a forged one takes its place.
+				signed := p.sign(t, testResponse(testAssertion()), "_assertion")
+				start := strings.Index(signed, "<saml:Assertion")
+				end := strings.Index(signed, "</saml:Assertion>") + len("</saml:Assertion>")
+				forged := strings.Replace(testAssertion(), ">c0ffee<", ">admin<", 1)
+				return testResponse("<samlp:Extensions>" + signed[start:end] + "</samlp:Extensions>" + forged)
+			},
+			wantErr: "neither the response nor the assertion is signed",
+		},
+		{
+			name:      "unexpected request ID",
+			doc:       func(t *testing.T) string { return p.sign(t, testResponse(testAssertion()), "_response") },
+			requestID: "_deadbeef",
+			wantErr:   "response is not in response to the request",
+		},
+		{
+			name: "unsuccessful status",
+			doc: func(t *testing.T) string {
+				return strings.Replace(p.sign(t, testResponse(testAssertion()), "_assertion"), "status:Success", "status:Requester", 1)
+			},
+			wantErr: `unsuccessful status "urn:oasis:names:tc:SAML:2.0:status:Requester"`,
+		},
+		{
+			name: "unexpected issuer",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer("<saml:Issuer>"+testIdPEntityID, "<saml:Issuer>https://evil.example.com"))
+			},
+			wantErr: "assertion is not issued by the identity provider",
+		},
+		{
+			name: "unexpected recipient",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer(`Recipient="`+testEndpoints.ACSURL, `Recipient="https://evil.example.com/acs`))
+			},
+			wantErr: "no valid bearer subject confirmation",
+		},
+		{
+			name:    "subject confirmation expired",
+			doc:     func(t *testing.T) string { return p.sign(t, testResponse(testAssertion()), "_response") },
+			now:     testNow.Add(time.Hour),
+			wantErr: "no valid bearer subject confirmation",
+		},
+		{
+			name: "not yet valid",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer(`NotBefore="2026-01-02T03:04:00Z"`, `NotBefore="2026-01-02T03:14:00Z"`))
+			},
+			wantErr: "assertion is not yet valid",
+		},
+		{
+			name: "unexpected audience",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer("<saml:Audience>"+testEndpoints.MetadataURL, "<saml:Audience>https://evil.example.com"))
+			},
+			wantErr: "assertion is not intended for the service provider",
+		},
+		{
+			name: "transient name ID",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer("nameid-format:persistent", "nameid-format:transient"))
+			},
+			wantErr: "transient name ID cannot identify the account",
+		},
+		{
+			name: "missing email",
+			doc: func(t *testing.T) string {
+				return signedAssertion(t, strings.NewReplacer(`FriendlyName="email"`, ""))
+			},
+			wantErr: `missing "email" attribute`,
+		},
+		{
+			name: "multiple assertions",
+			doc: func(t *testing.T) string {
+				return p.sign(t, testResponse(testAssertion()+strings.Replace(testAssertion(), `ID="_assertion"`, `ID="_another"`, 1)), "_response")
+			},
+			wantErr: "response must contain exactly one assertion",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestID := test.requestID
+			if requestID == "" {
+				requestID = testRequestID
+			}
+			now := test.now
+			if now.IsZero() {
+				now = testNow
+			}
+			_, err := p.config.ParseResponse(testEndpoints, requestID, encodeResponse(test.doc(t)), now)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The XML documents of SAML are parsed into a tree of elements that keeps the
+// original namespace prefixes, because the canonical form that signatures are
+// computed over depends on them, see canonicalize.
+
+const (
+	nsXML       = "http://www.w3.org/XML/1998/namespace"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+	nsExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	nsXMLEnc    = "http://www.w3.org/2001/04/xmlenc#"
+	nsXMLEnc11  = "http://www.w3.org/2009/xmlenc11#"
+)
+
+// maxXMLDepth is the maximum nesting depth of elements, which is far more than
+// any SAML message needs.
+const maxXMLDepth = 64
+
+type element struct {
+	prefix string
+	local  string
+	// The attributes as they appear in the document, including namespace
+	// declarations.
+	attrs []xml.Attr
+	// Either *element or string for character data.
+	children []any
+	parent   *element
+}
+
+// parseXML parses the document into a tree of elements and returns the root.
+// The namespace prefixes that are not declared in the document are resolved
+// through the given parent, which may be nil. Comments and processing
+// instructions are dropped, and document type declarations are rejected.
+func parseXML(b []byte, parent *element) (*element, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var root, cur *element
+	depth := 0
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "decode")
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil && cur == nil {
+				return nil, errors.New("multiple root elements")
+			}
+			depth++
+			if depth > maxXMLDepth {
+				return nil, errors.New("elements are nested too deeply")
+			}
+
+			e := &element{
+				prefix: t.Name.Space,
+				local:  t.Name.Local,
+				attrs:  slices.Clone(t.Attr),
+				parent: cur,
+			}
+			if cur == nil {
+				root = e
+				e.parent = parent
+			} else {
+				cur.children = append(cur.children, e)
+			}
+			if _, ok := e.lookupNS(e.prefix); !ok {
+				return nil, errors.Newf("undeclared namespace prefix %q", e.prefix)
+			}
+			for _, attr := range e.attrs {
+				if attr.Name.Space != "" && attr.Name.Space != "xmlns" {
+					if _, ok := e.lookupNS(attr.Name.Space); !ok {
+						return nil, errors.Newf("undeclared namespace prefix %q", attr.Name.Space)
+					}
+				}
+			}
+			cur = e
+
+		case xml.EndElement:
+			if cur == nil || t.Name.Space != cur.prefix || t.Name.Local != cur.local {
+				return nil, errors.Newf("unexpected end element %q", t.Name.Local)
+			}
+			depth--
+			if cur == root {
+				cur = nil
+			} else {
+				cur = cur.parent
+			}
+
+		case xml.CharData:
+			if cur != nil {
+				cur.children = append(cur.children, string(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("character data outside of the root element")
+			}
+
+		case xml.Directive:
+			return nil, errors.New("document type declarations are not allowed")
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	} else if cur != nil {
+		return nil, errors.New("unexpected end of document")
+	}
+	return root, nil
+}
+
+// lookupNS returns the namespace name that the prefix is bound to in the scope
+// of the element. The empty prefix stands for the default namespace.
+func (e *element) lookupNS(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXML, true
+	}
+	for ; e != nil; e = e.parent {
+		for _, attr := range e.attrs {
+			if (prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns") ||
+				(prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix) {
+				return attr.Value, true
+			}
+		}
+	}
+	// Elements without prefix are in no namespace unless declared otherwise.
+	return "", prefix == ""
+}
+
+// space returns the namespace name of the element.
+func (e *element) space() string {
+	ns, _ := e.lookupNS(e.prefix)
+	return ns
+}
+
+// is returns true if the element has the given namespace and local name.
+func (e *element) is(space, local string) bool {
+	return e.local == local && e.space() == space
+}
+
+// elements returns the child elements with the given namespace and local name.
+func (e *element) elements(space, local string) []*element {
+	var elems []*element
+	for _, child := range e.children {
+		if child, ok := child.(*element); ok && child.is(space, local) {
+			elems = append(elems, child)
+		}
+	}
+	return elems
+}
+
+// element returns the first child element with the given namespace and local
+// name, or nil if there is none.
+func (e *element) element(space, local string) *element {
+	for _, child := range e.children {
+		if child, ok := child.(*element); ok && child.is(space, local) {
+			return child
+		}
+	}
+	return nil
+}
+
+// attr returns the value of the attribute that has no namespace.
+func (e *element) attr(local string) string {
+	for _, attr := range e.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// text returns the character data of the element with surrounding whitespace
+// trimmed.
+func (e *element) text() string {
+	var sb strings.Builder
+	for _, child := range e.children {
+		if s, ok := child.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// canonicalize returns the exclusive canonical form without comments of the
+// element, see https://www.w3.org/TR/xml-exc-c14n/. Namespaces with prefixes
+// in inclusivePrefixes ("#default" for the default namespace) are rendered
+// like in the inclusive canonical form. The excluded element and its
+// descendants are left out, which implements the enveloped signature
+// transform.
+func canonicalize(e *element, inclusivePrefixes []string, excluded *element) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, e, inclusivePrefixes, excluded, map[string]string{})
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, e *element, inclusivePrefixes []string, excluded *element, rendered map[string]string) {
+	// Namespaces that are visibly utilized by the element and its attributes.
+	prefixes := []string{e.prefix}
+	for _, attr := range e.attrs {
+		if attr.Name.Space != "" && attr.Name.Space != "xmlns" {
+			prefixes = append(prefixes, attr.Name.Space)
+		}
+	}
+	for _, p := range inclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := e.lookupNS(p); ok {
+			prefixes = append(prefixes, p)
+		}
+	}
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	scope := rendered
+	var nsDecls []xml.Attr
+	for _, p := range prefixes {
+		if p == "xml" {
+			continue
+		}
+		ns, _ := e.lookupNS(p)
+		prev, ok := rendered[p]
+		if (ok && prev == ns) || (!ok && ns == "") {
+			continue
+		}
+		if len(nsDecls) == 0 {
+			scope = make(map[string]string, len(rendered)+1)
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = ns
+		if p == "" {
+			nsDecls = append(nsDecls, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: ns})
+		} else {
+			nsDecls = append(nsDecls, xml.Attr{Name: xml.Name{Space: "xmlns", Local: p}, Value: ns})
+		}
+	}
+
+	type attribute struct {
+		space string
+		attr  xml.Attr
+	}
+	var attrs []attribute
+	for _, attr := range e.attrs {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		var space string
+		if attr.Name.Space != "" {
+			space, _ = e.lookupNS(attr.Name.Space)
+		}
+		attrs = append(attrs, attribute{space: space, attr: attr})
+	}
+	slices.SortFunc(attrs, func(a, b attribute) int {
+		if c := strings.Compare(a.space, b.space); c != 0 {
+			return c
+		}
+		return strings.Compare(a.attr.Name.Local, b.attr.Name.Local)
+	})
+
+	buf.WriteByte('<')
+	writeQName(buf, e.prefix, e.local)
+	for _, attr := range nsDecls {
+		buf.WriteByte(' ')
+		writeQName(buf, attr.Name.Space, attr.Name.Local)
+		buf.WriteString(`="`)
+		escapeAttr(buf, attr.Value)
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteByte(' ')
+		writeQName(buf, a.attr.Name.Space, a.attr.Name.Local)
+		buf.WriteString(`="`)
+		escapeAttr(buf, a.attr.Value)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, child := range e.children {
+		switch child := child.(type) {
+		case *element:
+			if child != excluded {
+				writeCanonical(buf, child, inclusivePrefixes, excluded, scope)
+			}
+		case string:
+			escapeText(buf, child)
+		}
+	}
+
+	buf.WriteString("</")
+	writeQName(buf, e.prefix, e.local)
+	buf.WriteByte('>')
+}
+
+func writeQName(buf *bytes.Buffer, prefix, local string) {
+	if prefix != "" {
+		buf.WriteString(prefix)
+		buf.WriteByte(':')
+	}
+	buf.WriteString(local)
+}
+
+func escapeText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXML(t *testing.T) {
+	root, err := parseXML([]byte(`<?xml version="1.0"?>
+<!-- comment -->
+<a:root xmlns:a="urn:a" xmlns="urn:default"><child>x<!-- comment -->y</child></a:root>`), nil)
+	require.NoError(t, err)
+	assert.True(t, root.is("urn:a", "root"))
+
+	child := root.element("urn:default", "child")
+	require.NotNil(t, child)
+	assert.Equal(t, "xy", child.text())
+	assert.Equal(t, child, root.elements("urn:default", "child")[0])
+	assert.Nil(t, root.element("urn:a", "child"))
+
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{name: "document type declaration", doc: `<!DOCTYPE a [<!ENTITY e "x">]><a/>`, wantErr: "document type declarations are not allowed"},
+		{name: "undeclared element prefix", doc: `<a:root/>`, wantErr: `undeclared namespace prefix "a"`},
+		{name: "undeclared attribute prefix", doc: `<root a:id="1"/>`, wantErr: `undeclared namespace prefix "a"`},
+		{name: "multiple roots", doc: `<a/><b/>`, wantErr: "multiple root elements"},
+		{name: "mismatched end element", doc: `<a></b>`, wantErr: `unexpected end element "b"`},
+		{name: "unexpected end", doc: `<a>`, wantErr: "unexpected end of document"},
+		{name: "text outside of root", doc: `<a/>text`, wantErr: "character data outside of the root element"},
+		{name: "empty", doc: ``, wantErr: "no root element"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseXML([]byte(test.doc), nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name              string
+		doc               string
+		inclusivePrefixes []string
+		want              string
+	}{
+		{
+			name: "unused namespaces are omitted",
+			doc:  `<a:root xmlns:a="urn:a" xmlns:b="urn:b"><a:child/></a:root>`,
+			want: `<a:root xmlns:a="urn:a"><a:child></a:child></a:root>`,
+		},
+		{
+			name: "namespaces are declared where used",
+			doc:  `<root xmlns:a="urn:a"><child><a:x/><a:y/></child></root>`,
+			want: `<root><child><a:x xmlns:a="urn:a"></a:x><a:y xmlns:a="urn:a"></a:y></child></root>`,
+		},
+		{
+			name: "redeclared namespaces are omitted",
+			doc:  `<a:root xmlns:a="urn:a"><a:child xmlns:a="urn:a"/></a:root>`,
+			want: `<a:root xmlns:a="urn:a"><a:child></a:child></a:root>`,
+		},
+		{
+			name: "default namespace is undeclared",
+			doc:  `<root xmlns="urn:default"><child xmlns=""/></root>`,
+			want: `<root xmlns="urn:default"><child xmlns=""></child></root>`,
+		},
+		{
+			name: "attributes are sorted by namespace and local name",
+			doc:  `<root xmlns:b="urn:b" xmlns:a="urn:z" z="1" b:y="2" a:x="3" a="4"/>`,
+			want: `<root xmlns:a="urn:z" xmlns:b="urn:b" a="4" z="1" b:y="2" a:x="3"></root>`,
+		},
+		{
+			name: "special characters are escaped",
+			doc:  "<root attr=\"&lt;&amp;&quot;&#9;&#10;&#13;>\">&lt;&amp;&gt;&#13;\"'</root>",
+			want: "<root attr=\"&lt;&amp;&quot;&#x9;&#xA;&#xD;>\">&lt;&amp;&gt;&#xD;\"'</root>",
+		},
+		{
+			name:              "inclusive namespaces",
+			doc:               `<root xmlns:a="urn:a" xmlns:b="urn:b" xmlns="urn:default"><child/></root>`,
+			inclusivePrefixes: []string{"a", "#default"},
+			want:              `<root xmlns="urn:default" xmlns:a="urn:a"><child></child></root>`,
+		},
+		{
+			name: "xml namespace is not declared",
+			doc:  `<root xml:lang="en"/>`,
+			want: `<root xml:lang="en"></root>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := parseXML([]byte(test.doc), nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, string(canonicalize(root, test.inclusivePrefixes, nil)))
+		})
+	}
+
+	t.Run("subtree inherits namespaces of ancestors", func(t *testing.T) {
+		root, err := parseXML([]byte(`<a:root xmlns:a="urn:a" xmlns:b="urn:b"><a:child ID="1"><b:x/></a:child></a:root>`), nil)
+		require.NoError(t, err)
+		child := root.element("urn:a", "child")
+		assert.Equal(t, `<a:child xmlns:a="urn:a" ID="1"><b:x xmlns:b="urn:b"></b:x></a:child>`, string(canonicalize(child, nil, nil)))
+	})
+
+	t.Run("excluded element", func(t *testing.T) {
+		root, err := parseXML([]byte(`<root>a<sig><x/></sig>b</root>`), nil)
+		require.NoError(t, err)
+		assert.Equal(t, `<root>ab</root>`, string(canonicalize(root, nil, root.element("", "sig"))))
+	})
+}
@@ -0,0 +1,169 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Algorithms of XML Signature, see https://www.w3.org/TR/xmldsig-core1/#sec-AlgID.
+// SHA-1 based algorithms are deliberately not supported.
+const (
+	algEnvelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algSHA256             = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algSHA512             = "http://www.w3.org/2001/04/xmlenc#sha512"
+	algRSASHA256          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA512          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	algECDSASHA256        = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+)
+
+var digestMethods = map[string]crypto.Hash{
+	algSHA256: crypto.SHA256,
+	algSHA512: crypto.SHA512,
+}
+
+var signatureMethods = map[string]crypto.Hash{
+	algRSASHA256:   crypto.SHA256,
+	algRSASHA512:   crypto.SHA512,
+	algECDSASHA256: crypto.SHA256,
+}
+
+var errNotSigned = errors.New("not signed")
+
+// decodeBase64 decodes the content of base64Binary elements, which may contain
+// whitespace.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// inclusivePrefixes returns the prefix list of the InclusiveNamespaces
+// parameter of the exclusive canonicalization algorithm.
+func inclusivePrefixes(method *element) []string {
+	if ns := method.element(nsExcC14N, "InclusiveNamespaces"); ns != nil {
+		return strings.Fields(ns.attr("PrefixList"))
+	}
+	return nil
+}
+
+// verifySignature verifies the enveloped signature of the element, which must
+// be a direct child of it and reference the element by its ID, against the
+// public keys of the certificates. It returns errNotSigned if the element has
+// no signature. The key information in the signature itself is never trusted.
+func verifySignature(e *element, certs []*x509.Certificate) error {
+	sigs := e.elements(nsDSig, "Signature")
+	if len(sigs) == 0 {
+		return errNotSigned
+	} else if len(sigs) > 1 {
+		return errors.New("multiple signatures")
+	}
+	sig := sigs[0]
+
+	signedInfo := sig.element(nsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("no SignedInfo")
+	}
+	c14nMethod := signedInfo.element(nsDSig, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.attr("Algorithm") != nsExcC14N {
+		return errors.New("unsupported canonicalization method")
+	}
+	signatureMethod := signedInfo.element(nsDSig, "SignatureMethod")
+	if signatureMethod == nil {
+		return errors.New("no SignatureMethod")
+	}
+	hash, ok := signatureMethods[signatureMethod.attr("Algorithm")]
+	if !ok {
+		return errors.Newf("unsupported signature method %q", signatureMethod.attr("Algorithm"))
+	}
+
+	refs := signedInfo.elements(nsDSig, "Reference")
+	if len(refs) != 1 {
+		return errors.New("signature must have exactly one reference")
+	}
+	ref := refs[0]
+	id := e.attr("ID")
+	if id == "" || ref.attr("URI") != "#"+id {
+		return errors.New("signature does not reference the signed element")
+	}
+
+	var prefixes []string
+	var canonicalized bool
+	if transforms := ref.element(nsDSig, "Transforms"); transforms != nil {
+		for _, transform := range transforms.elements(nsDSig, "Transform") {
+			switch transform.attr("Algorithm") {
+			case algEnvelopedSignature:
+			case nsExcC14N:
+				prefixes = inclusivePrefixes(transform)
+				canonicalized = true
+			default:
+				return errors.Newf("unsupported transform %q", transform.attr("Algorithm"))
+			}
+		}
+	}
+	if !canonicalized {
+		return errors.New("reference is not canonicalized")
+	}
+
+	digestMethod := ref.element(nsDSig, "DigestMethod")
+	if digestMethod == nil {
+		return errors.New("no DigestMethod")
+	}
+	digestHash, ok := digestMethods[digestMethod.attr("Algorithm")]
+	if !ok {
+		return errors.Newf("unsupported digest method %q", digestMethod.attr("Algorithm"))
+	}
+	digestValue := ref.element(nsDSig, "DigestValue")
+	if digestValue == nil {
+		return errors.New("no DigestValue")
+	}
+	wantDigest, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return errors.Wrap(err, "decode digest")
+	}
+	h := digestHash.New()
+	h.Write(canonicalize(e, prefixes, sig))
+	if subtle.ConstantTimeCompare(h.Sum(nil), wantDigest) != 1 {
+		return errors.New("digest mismatch")
+	}
+
+	signatureValue := sig.element(nsDSig, "SignatureValue")
+	if signatureValue == nil {
+		return errors.New("no SignatureValue")
+	}
+	signature, err := decodeBase64(signatureValue.text())
+	if err != nil {
+		return errors.Wrap(err, "decode signature")
+	}
+	h = hash.New()
+	h.Write(canonicalize(signedInfo, inclusivePrefixes(c14nMethod), nil))
+	hashed := h.Sum(nil)
+	for _, cert := range certs {
+		if verifyHash(cert.PublicKey, hash, hashed, signature) {
+			return nil
+		}
+	}
+	return errors.New("signature is not signed by any of the certificates")
+}
+
+func verifyHash(publicKey any, hash crypto.Hash, hashed, signature []byte) bool {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, hashed, signature) == nil
+	case *ecdsa.PublicKey:
+		// ECDSA signature values are the concatenation of r and s, see
+		// https://www.w3.org/TR/xmldsig-core1/#sec-ECDSA.
+		if len(signature) == 0 || len(signature)%2 != 0 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:len(signature)/2])
+		s := new(big.Int).SetBytes(signature[len(signature)/2:])
+		return ecdsa.Verify(key, hashed, r, s)
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1" // For RSA-OAEP with SHA-1, the default of XML Encryption
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Algorithms of XML Encryption, see https://www.w3.org/TR/xmlenc-core1/#sec-Alg-Block.
+// RSA-v1.5 key transport is deliberately not supported.
+const (
+	algAES128CBC   = "http://www.w3.org/2001/04/xmlenc#aes128-cbc"
+	algAES192CBC   = "http://www.w3.org/2001/04/xmlenc#aes192-cbc"
+	algAES256CBC   = "http://www.w3.org/2001/04/xmlenc#aes256-cbc"
+	algAES128GCM   = "http://www.w3.org/2009/xmlenc11#aes128-gcm"
+	algAES192GCM   = "http://www.w3.org/2009/xmlenc11#aes192-gcm"
+	algAES256GCM   = "http://www.w3.org/2009/xmlenc11#aes256-gcm"
+	algRSAOAEPMGF1 = "http://www.w3.org/2001/04/xmlenc#rsa-oaep-mgf1p"
+	algRSAOAEP     = "http://www.w3.org/2009/xmlenc11#rsa-oaep"
+	algSHA1        = "http://www.w3.org/2000/09/xmldsig#sha1"
+	algMGF1SHA1    = "http://www.w3.org/2009/xmlenc11#mgf1sha1"
+	algMGF1SHA256  = "http://www.w3.org/2009/xmlenc11#mgf1sha256"
+	algMGF1SHA512  = "http://www.w3.org/2009/xmlenc11#mgf1sha512"
+)
+
+var blockCiphers = map[string]struct {
+	keySize int
+	gcm     bool
+}{
+	algAES128CBC: {16, false},
+	algAES192CBC: {24, false},
+	algAES256CBC: {32, false},
+	algAES128GCM: {16, true},
+	algAES192GCM: {24, true},
+	algAES256GCM: {32, true},
+}
+
+var oaepDigests = map[string]crypto.Hash{
+	"":        crypto.SHA1,
+	algSHA1:   crypto.SHA1,
+	algSHA256: crypto.SHA256,
+	algSHA512: crypto.SHA512,
+}
+
+var mgfDigests = map[string]crypto.Hash{
+	"":            crypto.SHA1,
+	algMGF1SHA1:   crypto.SHA1,
+	algMGF1SHA256: crypto.SHA256,
+	algMGF1SHA512: crypto.SHA512,
+}
+
+// decryptElement decrypts the EncryptedData in the element, e.g. an
+// EncryptedAssertion, with the private key and returns the plaintext. The
+// symmetric key is transported in an EncryptedKey either inside the KeyInfo
+// of the EncryptedData or next to it.
+func decryptElement(e *element, key *rsa.PrivateKey) ([]byte, error) {
+	encryptedData := e.element(nsXMLEnc, "EncryptedData")
+	if encryptedData == nil {
+		return nil, errors.New("no EncryptedData")
+	}
+	method := encryptedData.element(nsXMLEnc, "EncryptionMethod")
+	if method == nil {
+		return nil, errors.New("no EncryptionMethod")
+	}
+	blockCipher, ok := blockCiphers[method.attr("Algorithm")]
+	if !ok {
+		return nil, errors.Newf("unsupported encryption method %q", method.attr("Algorithm"))
+	}
+
+	var encryptedKey *element
+	if keyInfo := encryptedData.element(nsDSig, "KeyInfo"); keyInfo != nil {
+		encryptedKey = keyInfo.element(nsXMLEnc, "EncryptedKey")
+	}
+	if encryptedKey == nil {
+		encryptedKey = e.element(nsXMLEnc, "EncryptedKey")
+	}
+	if encryptedKey == nil {
+		return nil, errors.New("no EncryptedKey")
+	}
+	cek, err := decryptKey(encryptedKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt key")
+	} else if len(cek) != blockCipher.keySize {
+		return nil, errors.New("key size does not match the encryption method")
+	}
+
+	ciphertext, err := cipherValue(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, errors.Wrap(err, "new cipher")
+	}
+
+	if blockCipher.gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "new GCM")
+		}
+		if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+			return nil, errors.New("ciphertext is too short")
+		}
+		nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return nil, errors.New("decryption failed")
+		}
+		return plaintext, nil
+	}
+
+	if len(ciphertext) < 2*aes.BlockSize || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+	iv, ciphertext := ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:]
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	// Only the last byte of the padding is significant, see
+	// https://www.w3.org/TR/xmlenc-core1/#sec-Padding.
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("decryption failed")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// decryptKey decrypts the symmetric key that is transported in the
+// EncryptedKey with RSA-OAEP.
+func decryptKey(encryptedKey *element, key *rsa.PrivateKey) ([]byte, error) {
+	method := encryptedKey.element(nsXMLEnc, "EncryptionMethod")
+	if method == nil {
+		return nil, errors.New("no EncryptionMethod")
+	}
+
+	var digestAlg, mgfAlg string
+	if digestMethod := method.element(nsDSig, "DigestMethod"); digestMethod != nil {
+		digestAlg = digestMethod.attr("Algorithm")
+	}
+	switch method.attr("Algorithm") {
+	case algRSAOAEPMGF1:
+		mgfAlg = algMGF1SHA1
+	case algRSAOAEP:
+		if mgf := method.element(nsXMLEnc11, "MGF"); mgf != nil {
+			mgfAlg = mgf.attr("Algorithm")
+		}
+	default:
+		return nil, errors.Newf("unsupported key transport method %q", method.attr("Algorithm"))
+	}
+	hash, ok := oaepDigests[digestAlg]
+	if !ok {
+		return nil, errors.Newf("unsupported digest method %q", digestAlg)
+	}
+	mgfHash, ok := mgfDigests[mgfAlg]
+	if !ok {
+		return nil, errors.Newf("unsupported mask generation function %q", mgfAlg)
+	}
+
+	ciphertext, err := cipherValue(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := key.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{Hash: hash, MGFHash: mgfHash})
+	if err != nil {
+		return nil, errors.New("decryption failed")
+	}
+	return cek, nil
+}
+
+func cipherValue(e *element) ([]byte, error) {
+	cipherData := e.element(nsXMLEnc, "CipherData")
+	if cipherData == nil {
+		return nil, errors.New("no CipherData")
+	}
+	value := cipherData.element(nsXMLEnc, "CipherValue")
+	if value == nil {
+		return nil, errors.New("no CipherValue")
+	}
+	b, err := decodeBase64(value.text())
+	if err != nil {
+		return nil, errors.Wrap(err, "decode cipher value")
+	}
+	return b, nil
+}
@@ -133,6 +133,12 @@ func Init(customConf string) error {
 	}
 	SSH.RootPath = ensureAbs(SSH.RootPath)
 	SSH.KeyTestPath = ensureAbs(SSH.KeyTestPath)
+	if SSH.TrustedUserCAKeys != "" {
+		SSH.TrustedUserCAKeys = ensureAbs(SSH.TrustedUserCAKeys)
+	}
+	if SSH.RevokedKeys != "" {
+		SSH.RevokedKeys = ensureAbs(SSH.RevokedKeys)
+	}
 
 	if !SSH.Disabled {
 		if !SSH.StartBuiltinServer {
@@ -144,6 +150,9 @@ func Init(customConf string) error {
 		} else {
 			SSH.RewriteAuthorizedKeysAtStart = false
 		}
+		if SSH.DisableAuthorizedKeysRewrite {
+			SSH.RewriteAuthorizedKeysAtStart = false
+		}
 
 		// Check if server is eligible for minimum key size check when user choose to enable.
 		// Windows server and OpenSSH version lower than 5.1 are forced to be disabled because
@@ -262,6 +271,9 @@ func Init(customConf string) error {
 		}
 		Auth.TrustedProxyCIDRs = append(Auth.TrustedProxyCIDRs, cidr)
 	}
+	if (Auth.MaxLoginFailuresPerAccount > 0 || Auth.MaxLoginFailuresPerIP > 0) && Auth.LoginLockoutDuration <= 0 {
+		return errors.New("[auth] LOGIN_LOCKOUT_DURATION must be positive when login failures are limited")
+	}
 
 	// *************************
 	// ----- User settings -----
@@ -391,6 +403,15 @@ func Init(customConf string) error {
 	}
 	LFS.ObjectsPath = ensureAbs(LFS.ObjectsPath)
 	LFS.ObjectsTempPath = ensureAbs(LFS.ObjectsTempPath)
+	switch LFS.Storage {
+	case "local":
+	case "s3":
+		if LFS.S3.Endpoint == "" || LFS.S3.Bucket == "" {
+			return errors.New("[lfs.s3] ENDPOINT and BUCKET are required when [lfs] STORAGE is s3")
+		}
+	default:
+		return errors.Newf("unsupported [lfs] STORAGE %q", LFS.Storage)
+	}
 
 	handleDeprecated()
 	if !HookMode {
@@ -427,6 +448,19 @@ func Init(customConf string) error {
 		return errors.Wrap(err, "mapping [other] section")
 	}
 
+	if Webhook.DeliverWorkers <= 0 {
+		Webhook.DeliverWorkers = 1
+	}
+	if Webhook.MaxAttempts <= 0 {
+		Webhook.MaxAttempts = 1
+	}
+	if Webhook.RetryInterval <= 0 {
+		Webhook.RetryInterval = time.Second
+	}
+	if Webhook.MaxRetryInterval < Webhook.RetryInterval {
+		Webhook.MaxRetryInterval = Webhook.RetryInterval
+	}
+
 	HasRobotsTxt = osx.IsFile(filepath.Join(CustomDir(), "robots.txt"))
 	return nil
 }
@@ -76,3 +76,15 @@ func SetMockPicture(t *testing.T, opts PictureOpts) {
 		mockPicture.Unlock()
 	})
 }
+
+var mockWebhook sync.Mutex
+
+func SetMockWebhook(t *testing.T, opts WebhookOpts) {
+	mockWebhook.Lock()
+	before := Webhook
+	Webhook = opts
+	t.Cleanup(func() {
+		Webhook = before
+		mockWebhook.Unlock()
+	})
+}
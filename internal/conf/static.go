@@ -120,14 +120,6 @@ var (
 		DefaultInterval int
 	}
 
-	// Webhook settings
-	Webhook struct {
-		Types          []string
-		DeliverTimeout int
-		SkipTLSVerify  bool `ini:"SKIP_TLS_VERIFY"`
-		PagingNum      int
-	}
-
 	// Markdown settings
 	Markdown struct {
 		EnableHardLineBreak bool
@@ -174,6 +166,33 @@ var (
 			Schedule   string
 			OlderThan  time.Duration
 		} `ini:"cron.repo_archive_cleanup"`
+		LFSGarbageCollection struct {
+			Enabled     bool
+			RunAtStart  bool
+			Schedule    string
+			GracePeriod time.Duration
+		} `ini:"cron.lfs_garbage_collection"`
+		DeleteExpiredAccessTokens struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.delete_expired_access_tokens"`
+		SyncLDAPTeams struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.sync_ldap_teams"`
+		AuditLogCleanup struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+			OlderThan  time.Duration
+		} `ini:"cron.audit_log_cleanup"`
+		LoginSessionCleanup struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.login_session_cleanup"`
 	}
 
 	// Git settings
@@ -218,6 +237,21 @@ var (
 	HasRobotsTxt bool
 )
 
+type WebhookOpts struct {
+	Types                  []string
+	DeliverTimeout         int
+	SkipTLSVerify          bool `ini:"SKIP_TLS_VERIFY"`
+	PagingNum              int
+	DeliverWorkers         int
+	MaxAttempts            int
+	RetryInterval          time.Duration
+	MaxRetryInterval       time.Duration
+	MaxConsecutiveFailures int
+}
+
+// Webhook settings
+var Webhook WebhookOpts
+
 type CacheOptions struct {
 	Adapter  string
 	Interval int
@@ -252,6 +286,11 @@ type AuthOpts struct {
 	TrustedProxyIPs                    []string `ini:"TRUSTED_PROXY_IPS"`
 	CustomLogoutURL                    string   `ini:"CUSTOM_LOGOUT_URL"`
 
+	MaxLoginFailuresPerAccount int
+	MaxLoginFailuresPerIP      int `ini:"MAX_LOGIN_FAILURES_PER_IP"`
+	LoginFailureDelay          time.Duration
+	LoginLockoutDuration       time.Duration
+
 	// Derived from other static values
 	TrustedProxyCIDRs []*net.IPNet `ini:"-"` // Parsed CIDR form of TrustedProxyIPs.
 }
@@ -300,6 +339,9 @@ type SSHOpts struct {
 	MinimumKeySizeCheck          bool
 	MinimumKeySizes              map[string]int `ini:"-"` // Load from [ssh.minimum_key_sizes]
 	RewriteAuthorizedKeysAtStart bool
+	DisableAuthorizedKeysRewrite bool
+	TrustedUserCAKeys            string `ini:"SSH_TRUSTED_USER_CA_KEYS"`
+	RevokedKeys                  string `ini:"SSH_REVOKED_KEYS"`
 
 	StartBuiltinServer bool     `ini:"START_SSH_SERVER"`
 	ListenHost         string   `ini:"SSH_LISTEN_HOST"`
@@ -364,6 +406,18 @@ type LFSOpts struct {
 	Storage         string
 	ObjectsPath     string
 	ObjectsTempPath string
+
+	S3 struct {
+		Endpoint        string
+		Region          string
+		Bucket          string
+		Prefix          string
+		AccessKeyID     string `ini:"ACCESS_KEY_ID"`
+		SecretAccessKey string
+		UsePathStyle    bool
+		PresignedURLs   bool `ini:"PRESIGNED_URLS"`
+		PresignExpiry   time.Duration
+	} `ini:"lfs.s3"`
 }
 
 // LFS settings
@@ -383,10 +437,12 @@ type UIOpts struct {
 	MaxDisplayFileSize int64
 
 	Admin struct {
-		UserPagingNum   int
-		RepoPagingNum   int
-		NoticePagingNum int
-		OrgPagingNum    int
+		UserPagingNum     int
+		RepoPagingNum     int
+		NoticePagingNum   int
+		AuditLogPagingNum int
+		LockoutPagingNum  int
+		OrgPagingNum      int
 	} `ini:"ui.admin"`
 	User UIUserOpts `ini:"ui.user"`
 }
@@ -0,0 +1,80 @@
+package context
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/database"
+)
+
+// ClientIP returns the IP address of the client that sent the request. The
+// "X-Real-IP" and "X-Forwarded-For" headers are only honored for requests from
+// trusted proxies, so clients cannot forge their addresses.
+func ClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !isRequestFromTrustedProxy(req) {
+		return host
+	}
+
+	if ip := strings.TrimSpace(req.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	// The rightmost address is the one appended by the trusted proxy, which is
+	// the only one that cannot be forged by the client.
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ips := strings.Split(forwarded, ",")
+		if ip := strings.TrimSpace(ips[len(ips)-1]); ip != "" {
+			return ip
+		}
+	}
+	return host
+}
+
+// AuditLog records the action performed by the actor in the audit log, the
+// actor ID is zero when the actor is not signed in. Failures are logged instead
+// of failing the request, as the action has already been performed.
+func AuditLog(r *http.Request, actorID int64, actorName string, action database.AuditAction, target database.AuditTarget, details string) {
+	err := database.Handle.AuditLogs().Create(
+		r.Context(),
+		database.CreateAuditLogOptions{
+			ActorID:   actorID,
+			ActorName: actorName,
+			IPAddress: ClientIP(r),
+			Action:    action,
+			Target:    target,
+			Details:   details,
+		},
+	)
+	if err != nil {
+		log.Error("Failed to create audit log of action %q on %s %q: %v", action, target.Type, target.Name, err)
+	}
+}
+
+// AuditLoginFailed records the failed login of the user in the audit log, the
+// user ID is zero when the user is not identified yet.
+func AuditLoginFailed(r *http.Request, userID int64, username, reason string) {
+	if username == "" && userID > 0 {
+		u, err := database.Handle.Users().GetByID(r.Context(), userID)
+		if err == nil {
+			username = u.Name
+		}
+	}
+	target := database.AuditTarget{Type: database.AuditTargetUser, ID: userID, Name: username}
+	AuditLog(r, 0, username, database.AuditActionUserLoginFailed, target, reason)
+}
+
+// AuditLog records the action performed by the signed-in user in the audit
+// log, see AuditLog.
+func (c *Context) AuditLog(action database.AuditAction, target database.AuditTarget, details string) {
+	if c.IsLogged {
+		AuditLog(c.Req.Request, c.User.ID, c.User.Name, action, target, details)
+		return
+	}
+	AuditLog(c.Req.Request, 0, "", action, target, details)
+}
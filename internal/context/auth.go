@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/go-macaron/session"
@@ -86,6 +87,13 @@ func isAPIPath(url string) bool {
 	return strings.HasPrefix(url, "/api/")
 }
 
+// isAPIv1Path returns true if the URL is under the versioned API, which is the
+// only place where access tokens are accepted. Other API paths, e.g. the web
+// API, are meant to be called by the web frontend with the browser session.
+func isAPIv1Path(url string) bool {
+	return strings.HasPrefix(url, "/api/v1/")
+}
+
 func isWebPath(p string) bool {
 	p = strings.TrimPrefix(p, conf.Server.Subpath)
 	switch {
@@ -136,16 +144,18 @@ type AuthStore interface {
 	AuthenticateUser(ctx context.Context, login, password string, loginSourceID int64) (*database.User, error)
 }
 
-// authenticatedUserID returns the ID of the authenticated user, along with a bool value
-// which indicates whether the user uses token authentication.
-func authenticatedUserID(store AuthStore, c *macaron.Context, sess session.Store) (_ int64, isTokenAuth bool) {
+// authenticatedUserID returns the ID of the authenticated user, along with the
+// access token if the user uses token authentication.
+func authenticatedUserID(store Store, c *macaron.Context, sess session.Store) (_ int64, token *database.AccessToken) {
 	// Check access token.
-	if isAPIPath(c.Req.URL.Path) {
+	if isAPIv1Path(c.Req.URL.Path) {
 		var tokenSHA string
 		auHead := c.Req.Header.Get("Authorization")
 		if auHead != "" {
 			auths := strings.Fields(auHead)
-			if len(auths) == 2 && auths[0] == "token" {
+			// Tokens issued to OAuth2 applications are conventionally sent as bearer
+			// tokens, see https://datatracker.ietf.org/doc/html/rfc6750#section-2.1.
+			if len(auths) == 2 && (auths[0] == "token" || strings.EqualFold(auths[0], "bearer")) {
 				tokenSHA = auths[1]
 			}
 		}
@@ -157,36 +167,94 @@ func authenticatedUserID(store AuthStore, c *macaron.Context, sess session.Store
 				if !database.IsErrAccessTokenNotExist(err) {
 					log.Error("GetAccessTokenBySHA: %v", err)
 				}
-				return 0, false
+				return 0, nil
 			}
 			if err = store.TouchAccessTokenByID(c.Req.Context(), t.ID); err != nil {
 				log.Error("Failed to touch access token: %v", err)
 			}
-			return t.UserID, true
+			return t.UserID, t
 		}
 	}
 
 	uid := sess.Get("uid")
 	if uid == nil {
-		return 0, false
+		return 0, nil
 	}
 	if id, ok := uid.(int64); ok {
+		if !verifyLoginSession(store, c.Req.Request, sess, id) {
+			return 0, nil
+		}
+
 		_, err := store.GetUserByID(c.Req.Context(), id)
 		if err != nil {
 			if !database.IsErrUserNotExist(err) {
 				log.Error("Failed to get user by ID: %v", err)
 			}
-			return 0, false
+			return 0, nil
+		}
+		return id, nil
+	}
+	return 0, nil
+}
+
+// loginSessionTouchInterval is the minimum interval between updates of the last
+// seen time of a login session, to avoid writing to the database on every
+// request.
+const loginSessionTouchInterval = time.Minute
+
+// verifyLoginSession returns true if the login session tracked in the session
+// data still exists for the user, and marks it as seen. The session data is
+// cleared when the login session has been revoked.
+func verifyLoginSession(store Store, r *http.Request, sess session.Store, userID int64) bool {
+	key, _ := sess.Get("loginSessionKey").(string)
+	if key == "" {
+		// Sessions signed in before login sessions were tracked are adopted the
+		// first time they are seen, so that they can be listed and revoked like
+		// any other.
+		key, err := store.CreateLoginSession(r.Context(), userID,
+			database.CreateLoginSessionOptions{
+				UserAgent: r.UserAgent(),
+				IPAddress: ClientIP(r),
+			},
+		)
+		if err != nil {
+			log.Error("Failed to create login session: %v", err)
+			return false
+		}
+		if err = sess.Set("loginSessionKey", key); err != nil {
+			log.Error("Failed to set login session key: %v", err)
+			return false
+		}
+		return true
+	}
+
+	s, err := store.GetLoginSessionByKey(r.Context(), key)
+	if err != nil {
+		if !database.IsErrLoginSessionNotExist(err) {
+			log.Error("Failed to get login session by key: %v", err)
+			return false
+		}
+		_ = sess.Flush()
+		return false
+	} else if s.UserID != userID {
+		_ = sess.Flush()
+		return false
+	}
+
+	ip := ClientIP(r)
+	if time.Since(s.LastSeen) >= loginSessionTouchInterval || s.IPAddress != ip {
+		if err = store.TouchLoginSession(r.Context(), s.ID, ip); err != nil {
+			log.Error("Failed to touch login session: %v", err)
 		}
-		return id, false
 	}
-	return 0, false
+	return true
 }
 
-// authenticatedUser returns the user object of the authenticated user, along with two bool values
-// which indicate whether the user uses HTTP Basic Authentication or token authentication respectively.
-func authenticatedUser(store AuthStore, ctx *macaron.Context, sess session.Store) (_ *database.User, isBasicAuth, isTokenAuth bool) {
-	uid, isTokenAuth := authenticatedUserID(store, ctx, sess)
+// authenticatedUser returns the user object of the authenticated user, along with a bool value
+// which indicates whether the user uses HTTP Basic Authentication, and the access token if the
+// user uses token authentication.
+func authenticatedUser(store Store, ctx *macaron.Context, sess session.Store) (_ *database.User, isBasicAuth bool, token *database.AccessToken) {
+	uid, token := authenticatedUserID(store, ctx, sess)
 
 	if uid <= 0 {
 		if conf.Auth.EnableReverseProxyAuthentication && isRequestFromTrustedProxy(ctx.Req.Request) {
@@ -196,7 +264,7 @@ func authenticatedUser(store AuthStore, ctx *macaron.Context, sess session.Store
 				if err != nil {
 					if !database.IsErrUserNotExist(err) {
 						log.Error("Failed to get user by name: %v", err)
-						return nil, false, false
+						return nil, false, nil
 					}
 
 					// Check if enabled auto-registration.
@@ -211,11 +279,11 @@ func authenticatedUser(store AuthStore, ctx *macaron.Context, sess session.Store
 						)
 						if err != nil {
 							log.Error("Failed to create user %q: %v", webAuthUser, err)
-							return nil, false, false
+							return nil, false, nil
 						}
 					}
 				}
-				return user, false, false
+				return user, false, nil
 			}
 		}
 
@@ -226,26 +294,38 @@ func authenticatedUser(store AuthStore, ctx *macaron.Context, sess session.Store
 			if len(auths) == 2 && auths[0] == "Basic" {
 				uname, passwd, _ := tool.BasicAuthDecode(auths[1])
 
+				if wait := ReserveLoginAttempt(ctx.Req.Request, uname); wait > 0 {
+					SetRetryAfter(ctx.Resp.Header(), wait)
+					ctx.JSON(http.StatusTooManyRequests, map[string]string{
+						"message": "Too many failed login attempts, please try again later.",
+					})
+					return nil, false, nil
+				}
+
 				u, err := store.AuthenticateUser(ctx.Req.Context(), uname, passwd, -1)
 				if err != nil {
-					if !auth.IsErrBadCredentials(err) {
+					if auth.IsErrBadCredentials(err) {
+						AuditLoginFailed(ctx.Req.Request, 0, uname, "bad credentials over basic authentication")
+					} else {
+						ReleaseLoginAttempt(ctx.Req.Request, uname)
 						log.Error("Failed to authenticate user: %v", err)
 					}
-					return nil, false, false
+					return nil, false, nil
 				}
+				ResetLoginFailures(ctx.Req.Request, uname)
 
-				return u, true, false
+				return u, true, nil
 			}
 		}
-		return nil, false, false
+		return nil, false, nil
 	}
 
 	u, err := store.GetUserByID(ctx.Req.Context(), uid)
 	if err != nil {
 		log.Error("GetUserByID: %v", err)
-		return nil, false, false
+		return nil, false, nil
 	}
-	return u, false, isTokenAuth
+	return u, false, token
 }
 
 // isRequestFromTrustedProxy reports whether the request's immediate remote
@@ -274,13 +354,17 @@ func isRequestFromTrustedProxy(req *http.Request) bool {
 	return false
 }
 
-// AuthenticateByToken attempts to authenticate a user by the given access
-// token. It returns database.ErrAccessTokenNotExist when the access token does not
-// exist.
-func AuthenticateByToken(store AuthStore, ctx context.Context, token string) (*database.User, error) {
+// AuthenticateByToken attempts to authenticate a user by the given personal
+// access token. It returns database.ErrAccessTokenNotExist when the access
+// token does not exist, has expired, or is issued to an OAuth2 application,
+// which is only allowed to call the API. The access token is returned along
+// with the user, and callers must limit what the user can do with its scopes.
+func AuthenticateByToken(store AuthStore, ctx context.Context, token string) (*database.User, *database.AccessToken, error) {
 	t, err := store.GetAccessTokenBySHA1(ctx, token)
 	if err != nil {
-		return nil, errors.Wrap(err, "get access token by SHA1")
+		return nil, nil, errors.Wrap(err, "get access token by SHA1")
+	} else if t.GrantID > 0 {
+		return nil, nil, database.ErrAccessTokenNotExist{}
 	}
 	if err = store.TouchAccessTokenByID(ctx, t.ID); err != nil {
 		// NOTE: There is no need to fail the auth flow if we can't touch the token.
@@ -289,7 +373,7 @@ func AuthenticateByToken(store AuthStore, ctx context.Context, token string) (*d
 
 	user, err := store.GetUserByID(ctx, t.UserID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "get user by ID [user_id: %d]", t.UserID)
+		return nil, nil, errors.Wrapf(err, "get user by ID [user_id: %d]", t.UserID)
 	}
-	return user, nil
+	return user, t, nil
 }
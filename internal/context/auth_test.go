@@ -1,13 +1,21 @@
 package context
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/go-macaron/session"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/macaron.v1"
 
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
 )
 
 func TestIsRequestFromTrustedProxy(t *testing.T) {
@@ -46,3 +54,172 @@ func TestIsRequestFromTrustedProxy(t *testing.T) {
 		})
 	}
 }
+
+type tokenStore struct {
+	Store
+	token *database.AccessToken
+}
+
+func (s *tokenStore) GetAccessTokenBySHA1(_ context.Context, sha1 string) (*database.AccessToken, error) {
+	if sha1 != s.token.Sha1 {
+		return nil, database.ErrAccessTokenNotExist{}
+	}
+	return s.token, nil
+}
+
+func (*tokenStore) TouchAccessTokenByID(context.Context, int64) error {
+	return nil
+}
+
+func (*tokenStore) GetUserByID(_ context.Context, id int64) (*database.User, error) {
+	return &database.User{ID: id, Name: "alice"}, nil
+}
+
+type emptySession struct {
+	session.Store
+}
+
+func (emptySession) Get(any) any { return nil }
+
+func TestAuthenticatedUser_AccessToken(t *testing.T) {
+	store := &tokenStore{
+		token: &database.AccessToken{
+			ID:     1,
+			UserID: 1,
+			Sha1:   "0123456789abcdef0123456789abcdef01234567",
+			Scopes: string(database.AccessTokenScopeReadUser),
+		},
+	}
+
+	m := macaron.New()
+	m.Use(macaron.Renderer())
+	m.Any("/*", func(c *macaron.Context) {
+		user, _, token := authenticatedUser(store, c, emptySession{})
+		if user == nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, store.token, token)
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{name: "API v1", path: "/api/v1/user", wantCode: http.StatusOK},
+		{name: "web API", path: "/api/web/user/info", wantCode: http.StatusUnauthorized},
+		{name: "web page", path: "/user/settings", wantCode: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			r.Header.Set("Authorization", "token "+store.token.Sha1)
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+			assert.Equal(t, tc.wantCode, rr.Code)
+		})
+	}
+}
+
+func TestAuthenticateByToken(t *testing.T) {
+	store := &tokenStore{
+		token: &database.AccessToken{
+			ID:     1,
+			UserID: 1,
+			Sha1:   "0123456789abcdef0123456789abcdef01234567",
+		},
+	}
+	user, token, err := AuthenticateByToken(store, context.Background(), store.token.Sha1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+	assert.Equal(t, store.token, token)
+
+	// Tokens issued to OAuth2 applications are only accepted by the API.
+	store.token.GrantID = 1
+	_, _, err = AuthenticateByToken(store, context.Background(), store.token.Sha1)
+	assert.True(t, database.IsErrAccessTokenNotExist(err))
+}
+
+type loginSessionStore struct {
+	Store
+	sessions map[string]*database.LoginSession
+}
+
+func (s *loginSessionStore) CreateLoginSession(_ context.Context, userID int64, opts database.CreateLoginSessionOptions) (string, error) {
+	key := "key" + strconv.Itoa(len(s.sessions)+1)
+	s.sessions[key] = &database.LoginSession{
+		ID:        int64(len(s.sessions) + 1),
+		UserID:    userID,
+		IPAddress: opts.IPAddress,
+		LastSeen:  time.Now(),
+	}
+	return key, nil
+}
+
+func (s *loginSessionStore) GetLoginSessionByKey(_ context.Context, key string) (*database.LoginSession, error) {
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, database.ErrLoginSessionNotExist{}
+	}
+	return session, nil
+}
+
+func (*loginSessionStore) TouchLoginSession(context.Context, int64, string) error {
+	return nil
+}
+
+type mapSession struct {
+	session.Store
+	data map[any]any
+}
+
+func (s *mapSession) Get(key any) any { return s.data[key] }
+
+func (s *mapSession) Set(key, value any) error {
+	s.data[key] = value
+	return nil
+}
+
+func (s *mapSession) Flush() error {
+	s.data = make(map[any]any)
+	return nil
+}
+
+func TestVerifyLoginSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		want     bool
+		wantKey  string
+		sessions int
+	}{
+		{name: "tracked", key: "key1", want: true, wantKey: "key1", sessions: 2},
+		{name: "untracked is adopted", key: "", want: true, wantKey: "key3", sessions: 3},
+		{name: "revoked", key: "revoked", want: false, wantKey: "", sessions: 2},
+		{name: "of another user", key: "key2", want: false, wantKey: "", sessions: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &loginSessionStore{
+				sessions: map[string]*database.LoginSession{
+					"key1": {ID: 1, UserID: 1, LastSeen: time.Now()},
+					"key2": {ID: 2, UserID: 2, LastSeen: time.Now()},
+				},
+			}
+			sess := &mapSession{data: map[any]any{"uid": int64(1)}}
+			if test.key != "" {
+				sess.data["loginSessionKey"] = test.key
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			got := verifyLoginSession(store, r, sess, 1)
+			assert.Equal(t, test.want, got)
+
+			key, _ := sess.Get("loginSessionKey").(string)
+			assert.Equal(t, test.wantKey, key)
+			assert.Len(t, store.sessions, test.sessions)
+		})
+	}
+}
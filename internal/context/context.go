@@ -33,6 +33,8 @@ type Context struct {
 	IsLogged    bool
 	IsBasicAuth bool
 	IsTokenAuth bool
+	// The access token the user is authenticated with, if any.
+	AccessToken *database.AccessToken
 
 	Repo *Repository
 	Org  *Organization
@@ -287,7 +289,12 @@ func Contexter(store Store, webHandler http.Handler) macaron.Handler {
 		}
 
 		// Get user from session or header when possible
-		c.User, c.IsBasicAuth, c.IsTokenAuth = authenticatedUser(store, c.Context, c.Session)
+		c.User, c.IsBasicAuth, c.AccessToken = authenticatedUser(store, c.Context, c.Session)
+		c.IsTokenAuth = c.AccessToken != nil
+		if c.Written() {
+			// The request has been rejected, e.g. for too many failed login attempts.
+			return
+		}
 
 		if c.User != nil {
 			c.IsLogged = true
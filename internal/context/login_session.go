@@ -0,0 +1,40 @@
+package context
+
+import (
+	"gogs.io/gogs/internal/database"
+)
+
+// CurrentLoginSession returns the login session that the request is made with,
+// or nil if the user is not signed in through the browser.
+func (c *Context) CurrentLoginSession() (*database.LoginSession, error) {
+	key, _ := c.Session.Get("loginSessionKey").(string)
+	if !c.IsLogged || c.IsBasicAuth || c.IsTokenAuth || key == "" {
+		return nil, nil
+	}
+
+	s, err := database.Handle.LoginSessions().GetByKey(c.Req.Context(), key)
+	if err != nil {
+		if database.IsErrLoginSessionNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// RevokeOtherLoginSessions revokes all login sessions of the signed-in user
+// except the one that the request is made with, e.g. after the user changed
+// their password.
+func (c *Context) RevokeOtherLoginSessions() error {
+	current, err := c.CurrentLoginSession()
+	if err != nil {
+		return err
+	}
+
+	var exceptID int64
+	if current != nil {
+		exceptID = current.ID
+	}
+	_, err = database.Handle.LoginSessions().DeleteByUserID(c.Req.Context(), c.User.ID, exceptID)
+	return err
+}
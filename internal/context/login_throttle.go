@@ -0,0 +1,82 @@
+package context
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
+)
+
+// loginThrottleOptions returns the limits of failed password attempts, and
+// false if none is enabled.
+func loginThrottleOptions() (database.LoginThrottleOptions, bool) {
+	opts := database.LoginThrottleOptions{
+		MaxFailuresPerAccount: conf.Auth.MaxLoginFailuresPerAccount,
+		MaxFailuresPerIP:      conf.Auth.MaxLoginFailuresPerIP,
+		Delay:                 conf.Auth.LoginFailureDelay,
+		LockoutDuration:       conf.Auth.LoginLockoutDuration,
+	}
+	return opts, opts.MaxFailuresPerAccount > 0 || opts.MaxFailuresPerIP > 0
+}
+
+// ReserveLoginAttempt counts the password attempt of the login name from the
+// client as failed before it is made, so that concurrent attempts cannot get
+// past the limits. It returns how long the client has to wait before the
+// attempt is accepted, or zero if it is accepted now. The client is identified
+// by its IP address, see ClientIP.
+//
+// An accepted attempt must be given back with ResetLoginFailures when it
+// succeeds, or with ReleaseLoginAttempt when it turns out not to be a failed
+// password attempt. The attempt is accepted when the counters cannot be
+// updated, so that a database failure does not lock out everyone.
+func ReserveLoginAttempt(req *http.Request, login string) time.Duration {
+	opts, ok := loginThrottleOptions()
+	if !ok {
+		return 0
+	}
+
+	wait, err := database.Handle.LoginFailures().Reserve(req.Context(), opts, login, ClientIP(req))
+	if err != nil {
+		log.Error("Failed to reserve login attempt of %q: %v", login, err)
+		return 0
+	}
+	return wait
+}
+
+// ReleaseLoginAttempt gives back the reserved attempt of the login name from
+// the client that is not a failed password attempt, e.g. the client is
+// authenticated with an access token instead.
+func ReleaseLoginAttempt(req *http.Request, login string) {
+	opts, ok := loginThrottleOptions()
+	if !ok {
+		return
+	}
+
+	ip := ClientIP(req)
+	if err := database.Handle.LoginFailures().Release(req.Context(), opts, login, ip); err != nil {
+		log.Error("Failed to release login attempt of %q from %s: %v", login, ip, err)
+	}
+}
+
+// ResetLoginFailures forgets the failed password attempts of the login name
+// after a successful one.
+func ResetLoginFailures(req *http.Request, login string) {
+	opts, ok := loginThrottleOptions()
+	if !ok {
+		return
+	}
+
+	if err := database.Handle.LoginFailures().Reset(req.Context(), opts, login, ClientIP(req)); err != nil {
+		log.Error("Failed to reset login failures of %q: %v", login, err)
+	}
+}
+
+// SetRetryAfter sets the "Retry-After" header to the given duration, rounded
+// up to whole seconds.
+func SetRetryAfter(h http.Header, wait time.Duration) {
+	h.Set("Retry-After", strconv.FormatInt(int64((wait+time.Second-1)/time.Second), 10))
+}
@@ -228,6 +228,7 @@ func RepoAssignment(pages ...bool) macaron.Handler {
 				return
 			}
 			c.Data["MirrorEnablePrune"] = c.Repo.Mirror.EnablePrune
+			c.Data["MirrorEnableLFS"] = c.Repo.Mirror.EnableLFS
 			c.Data["MirrorInterval"] = c.Repo.Mirror.Interval
 			c.Data["Mirror"] = c.Repo.Mirror
 		}
@@ -42,6 +42,16 @@ type Store interface {
 	// When the "loginSourceID" is positive, it tries to authenticate via given
 	// login source and creates a new user when not yet exists in the database.
 	AuthenticateUser(ctx context.Context, login, password string, loginSourceID int64) (*database.User, error)
+
+	// CreateLoginSession creates a new login session for the user, and returns
+	// the key of the session to be kept in the session data.
+	CreateLoginSession(ctx context.Context, userID int64, opts database.CreateLoginSessionOptions) (string, error)
+	// GetLoginSessionByKey returns the login session with given key. It returns
+	// database.ErrLoginSessionNotExist when not found.
+	GetLoginSessionByKey(ctx context.Context, key string) (*database.LoginSession, error)
+	// TouchLoginSession updates the last seen time of the given login session to
+	// the current time, along with the IP address it is seen from.
+	TouchLoginSession(ctx context.Context, id int64, ipAddress string) error
 }
 
 type store struct{}
@@ -74,3 +84,15 @@ func (*store) CreateUser(ctx context.Context, username, email string, opts datab
 func (*store) AuthenticateUser(ctx context.Context, login, password string, loginSourceID int64) (*database.User, error) {
 	return database.Handle.Users().Authenticate(ctx, login, password, loginSourceID)
 }
+
+func (*store) CreateLoginSession(ctx context.Context, userID int64, opts database.CreateLoginSessionOptions) (string, error) {
+	return database.Handle.LoginSessions().Create(ctx, userID, opts)
+}
+
+func (*store) GetLoginSessionByKey(ctx context.Context, key string) (*database.LoginSession, error) {
+	return database.Handle.LoginSessions().GetByKey(ctx, key)
+}
+
+func (*store) TouchLoginSession(ctx context.Context, id int64, ipAddress string) error {
+	return database.Handle.LoginSessions().Touch(ctx, id, ipAddress)
+}
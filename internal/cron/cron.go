@@ -62,6 +62,61 @@ func NewContext() {
 			go database.DeleteOldRepositoryArchives()
 		}
 	}
+	if conf.Cron.LFSGarbageCollection.Enabled {
+		entry, err = c.AddFunc("LFS garbage collection", conf.Cron.LFSGarbageCollection.Schedule, database.DeleteOrphanedLFSObjects)
+		if err != nil {
+			log.Fatal("Cron.(LFS garbage collection): %v", err)
+		}
+		if conf.Cron.LFSGarbageCollection.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go database.DeleteOrphanedLFSObjects()
+		}
+	}
+	if conf.Cron.DeleteExpiredAccessTokens.Enabled {
+		entry, err = c.AddFunc("Delete expired access tokens", conf.Cron.DeleteExpiredAccessTokens.Schedule, database.DeleteExpiredAccessTokens)
+		if err != nil {
+			log.Fatal("Cron.(delete expired access tokens): %v", err)
+		}
+		if conf.Cron.DeleteExpiredAccessTokens.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go database.DeleteExpiredAccessTokens()
+		}
+	}
+	if conf.Cron.SyncLDAPTeams.Enabled {
+		entry, err = c.AddFunc("Synchronize LDAP teams", conf.Cron.SyncLDAPTeams.Schedule, database.SyncLDAPTeams)
+		if err != nil {
+			log.Fatal("Cron.(synchronize LDAP teams): %v", err)
+		}
+		if conf.Cron.SyncLDAPTeams.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go database.SyncLDAPTeams()
+		}
+	}
+	if conf.Cron.AuditLogCleanup.Enabled {
+		entry, err = c.AddFunc("Clean up audit logs", conf.Cron.AuditLogCleanup.Schedule, database.DeleteOldAuditLogs)
+		if err != nil {
+			log.Fatal("Cron.(clean up audit logs): %v", err)
+		}
+		if conf.Cron.AuditLogCleanup.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go database.DeleteOldAuditLogs()
+		}
+	}
+	if conf.Cron.LoginSessionCleanup.Enabled {
+		entry, err = c.AddFunc("Clean up inactive login sessions", conf.Cron.LoginSessionCleanup.Schedule, database.DeleteInactiveLoginSessions)
+		if err != nil {
+			log.Fatal("Cron.(clean up inactive login sessions): %v", err)
+		}
+		if conf.Cron.LoginSessionCleanup.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go database.DeleteInactiveLoginSessions()
+		}
+	}
 	c.Start()
 }
 
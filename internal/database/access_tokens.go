@@ -3,30 +3,48 @@ package database
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/cryptox"
 	"gogs.io/gogs/internal/errx"
 )
 
-// AccessToken is a personal access token.
+// AccessToken is a personal access token, or an access token issued to an
+// OAuth2 application.
 type AccessToken struct {
 	ID     int64 `gorm:"primarykey"`
 	UserID int64 `xorm:"uid" gorm:"column:uid;index"`
 	Name   string
 	Sha1   string `gorm:"type:VARCHAR(40);unique"`
 	SHA256 string `gorm:"type:VARCHAR(64);unique;not null"`
+	// Space-separated scopes the token is limited to, or empty for all scopes
+	// of its owner.
+	Scopes string `gorm:"type:TEXT"`
+	// Comma-separated IDs of repositories the token is limited to, or empty for
+	// all repositories of its owner.
+	RepoIDs string `gorm:"type:TEXT"`
+	// The ID of the OAuth2 grant the token is issued for, or 0 for personal
+	// access tokens.
+	GrantID            int64  `gorm:"index"`
+	RefreshSHA256      string `gorm:"type:VARCHAR(64);index"`
+	RefreshExpiresUnix int64
 
 	Created           time.Time `gorm:"-" json:"-"`
 	CreatedUnix       int64
 	Updated           time.Time `gorm:"-" json:"-"`
 	UpdatedUnix       int64
-	HasRecentActivity bool `gorm:"-" json:"-"`
-	HasUsed           bool `gorm:"-" json:"-"`
+	Expires           time.Time `gorm:"-" json:"-"`
+	ExpiresUnix       int64     `gorm:"index"` // 0 means never expire.
+	HasRecentActivity bool      `gorm:"-" json:"-"`
+	HasUsed           bool      `gorm:"-" json:"-"`
 }
 
 // BeforeCreate implements the GORM create hook.
@@ -45,9 +63,130 @@ func (t *AccessToken) AfterFind(tx *gorm.DB) error {
 		t.HasUsed = t.Updated.After(t.Created)
 		t.HasRecentActivity = t.Updated.Add(7 * 24 * time.Hour).After(tx.NowFunc())
 	}
+	if t.ExpiresUnix > 0 {
+		t.Expires = time.Unix(t.ExpiresUnix, 0).Local()
+	}
 	return nil
 }
 
+// AccessTokenScope is a scope that limits what an access token can do on
+// behalf of its owner.
+//
+// NOTE: There is no scope for packages because there is no package registry to
+// enforce it on. Release attachments and LFS objects, the closest equivalents,
+// are covered by the repository scopes.
+type AccessTokenScope string
+
+const (
+	AccessTokenScopeReadRepo   AccessTokenScope = "read:repo"
+	AccessTokenScopeWriteRepo  AccessTokenScope = "write:repo"
+	AccessTokenScopeAdminRepo  AccessTokenScope = "admin:repo"
+	AccessTokenScopeReadIssue  AccessTokenScope = "read:issue"
+	AccessTokenScopeWriteIssue AccessTokenScope = "write:issue"
+	AccessTokenScopeReadOrg    AccessTokenScope = "read:org"
+	AccessTokenScopeAdminOrg   AccessTokenScope = "admin:org"
+	AccessTokenScopeReadUser   AccessTokenScope = "read:user"
+	AccessTokenScopeWriteUser  AccessTokenScope = "write:user"
+	AccessTokenScopeSiteAdmin  AccessTokenScope = "site_admin"
+)
+
+// AccessTokenScopes is the list of all valid access token scopes.
+var AccessTokenScopes = []AccessTokenScope{
+	AccessTokenScopeReadRepo,
+	AccessTokenScopeWriteRepo,
+	AccessTokenScopeAdminRepo,
+	AccessTokenScopeReadIssue,
+	AccessTokenScopeWriteIssue,
+	AccessTokenScopeReadOrg,
+	AccessTokenScopeAdminOrg,
+	AccessTokenScopeReadUser,
+	AccessTokenScopeWriteUser,
+	AccessTokenScopeSiteAdmin,
+}
+
+// impliedAccessTokenScopes maps a scope to the scopes it implies.
+var impliedAccessTokenScopes = map[AccessTokenScope][]AccessTokenScope{
+	AccessTokenScopeWriteRepo:  {AccessTokenScopeReadRepo},
+	AccessTokenScopeAdminRepo:  {AccessTokenScopeWriteRepo, AccessTokenScopeReadRepo},
+	AccessTokenScopeWriteIssue: {AccessTokenScopeReadIssue},
+	AccessTokenScopeAdminOrg:   {AccessTokenScopeReadOrg},
+	AccessTokenScopeWriteUser:  {AccessTokenScopeReadUser},
+}
+
+// ScopeList returns the list of scopes the token is limited to, or nil if the
+// token is not limited.
+func (t *AccessToken) ScopeList() []AccessTokenScope {
+	if t.Scopes == "" {
+		return nil
+	}
+
+	fields := strings.Fields(t.Scopes)
+	scopes := make([]AccessTokenScope, 0, len(fields))
+	for _, field := range fields {
+		scopes = append(scopes, AccessTokenScope(field))
+	}
+	return scopes
+}
+
+// HasScope returns true if the token has given scope, either directly or
+// implied by another scope. Tokens not limited to any scopes have all scopes.
+func (t *AccessToken) HasScope(scope AccessTokenScope) bool {
+	if t.Scopes == "" {
+		return true
+	}
+
+	for _, s := range t.ScopeList() {
+		if s == scope || slices.Contains(impliedAccessTokenScopes[s], scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// RepositoryIDs returns the list of IDs of repositories the token is limited
+// to, or nil if the token is not limited.
+func (t *AccessToken) RepositoryIDs() []int64 {
+	if t.RepoIDs == "" {
+		return nil
+	}
+
+	fields := strings.Split(t.RepoIDs, ",")
+	repoIDs := make([]int64, 0, len(fields))
+	for _, field := range fields {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err == nil {
+			repoIDs = append(repoIDs, id)
+		}
+	}
+	return repoIDs
+}
+
+// MaxRepoAccessMode returns the highest access mode the token allows to the
+// repository, regardless of the access mode its owner has. Public
+// repositories are always readable, as they are even without the token.
+func (t *AccessToken) MaxRepoAccessMode(repoID int64, private bool) AccessMode {
+	mode := AccessModeNone
+	if t.RepoIDs == "" || slices.Contains(t.RepositoryIDs(), repoID) {
+		switch {
+		case t.HasScope(AccessTokenScopeAdminRepo):
+			mode = AccessModeOwner
+		case t.HasScope(AccessTokenScopeWriteRepo):
+			mode = AccessModeWrite
+		case t.HasScope(AccessTokenScopeReadRepo):
+			mode = AccessModeRead
+		}
+	}
+	if mode == AccessModeNone && !private {
+		mode = AccessModeRead
+	}
+	return mode
+}
+
+// IsExpired returns true if the token has an expiry time that has passed.
+func (t *AccessToken) IsExpired() bool {
+	return t.ExpiresUnix > 0 && t.ExpiresUnix <= time.Now().Unix()
+}
+
 // AccessTokensStore is the storage layer for access tokens.
 type AccessTokensStore struct {
 	db *gorm.DB
@@ -69,25 +208,79 @@ func (err ErrAccessTokenAlreadyExist) Error() string {
 	return fmt.Sprintf("access token already exists: %v", err.args)
 }
 
+type ErrInvalidAccessTokenScope struct {
+	args errx.Args
+}
+
+func IsErrInvalidAccessTokenScope(err error) bool {
+	return errors.As(err, &ErrInvalidAccessTokenScope{})
+}
+
+func (err ErrInvalidAccessTokenScope) Error() string {
+	return fmt.Sprintf("invalid access token scope: %v", err.args)
+}
+
+type CreateAccessTokenOptions struct {
+	// The scopes to limit the token to, empty for all scopes.
+	Scopes []AccessTokenScope
+	// The full names (i.e. "owner/name") of repositories to limit the token
+	// to, empty for all repositories.
+	Repositories []string
+	// The time the token expires, zero value for never.
+	Expires time.Time
+}
+
 // Create creates a new access token and persist to database. It returns
 // ErrAccessTokenAlreadyExist when an access token with same name already exists
-// for the user.
-func (s *AccessTokensStore) Create(ctx context.Context, userID int64, name string) (*AccessToken, error) {
-	err := s.db.WithContext(ctx).Where("uid = ? AND name = ?", userID, name).First(new(AccessToken)).Error
+// for the user, ErrInvalidAccessTokenScope when any of the scopes is not valid,
+// or ErrRepoNotExist when any of the repositories does not exist or is not
+// readable by the user.
+func (s *AccessTokensStore) Create(ctx context.Context, userID int64, name string, opts CreateAccessTokenOptions) (*AccessToken, error) {
+	err := s.db.WithContext(ctx).Where("uid = ? AND name = ? AND grant_id = 0", userID, name).First(new(AccessToken)).Error
 	if err == nil {
 		return nil, ErrAccessTokenAlreadyExist{args: errx.Args{"userID": userID, "name": name}}
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
+	scopes := make([]string, 0, len(opts.Scopes))
+	for _, scope := range opts.Scopes {
+		if !slices.Contains(AccessTokenScopes, scope) {
+			return nil, ErrInvalidAccessTokenScope{args: errx.Args{"scope": scope}}
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+
+	repoIDs := make([]string, 0, len(opts.Repositories))
+	for _, fullName := range opts.Repositories {
+		repo, err := s.getReadableRepository(ctx, userID, fullName)
+		if err != nil {
+			return nil, err
+		}
+		id := strconv.FormatInt(repo.ID, 10)
+		if !slices.Contains(repoIDs, id) {
+			repoIDs = append(repoIDs, id)
+		}
+	}
+
+	var expiresUnix int64
+	if !opts.Expires.IsZero() {
+		expiresUnix = opts.Expires.Unix()
+	}
+
 	token := cryptox.SHA1(uuid.New().String())
 	sha256 := cryptox.SHA256(token)
 
 	accessToken := &AccessToken{
-		UserID: userID,
-		Name:   name,
-		Sha1:   sha256[:40], // To pass the column unique constraint, keep the length of SHA1.
-		SHA256: sha256,
+		UserID:      userID,
+		Name:        name,
+		Sha1:        sha256[:40], // To pass the column unique constraint, keep the length of SHA1.
+		SHA256:      sha256,
+		Scopes:      strings.Join(scopes, " "),
+		RepoIDs:     strings.Join(repoIDs, ","),
+		ExpiresUnix: expiresUnix,
 	}
 	if err = s.db.WithContext(ctx).Create(accessToken).Error; err != nil {
 		return nil, err
@@ -98,16 +291,52 @@ func (s *AccessTokensStore) Create(ctx context.Context, userID int64, name strin
 	return accessToken, nil
 }
 
-// DeleteByID deletes the access token by given ID.
+// DeleteByID deletes the personal access token by given ID.
 //
 // 🚨 SECURITY: The "userID" is required to prevent attacker deletes arbitrary
 // access token that belongs to another user.
 func (s *AccessTokensStore) DeleteByID(ctx context.Context, userID, id int64) error {
-	return s.db.WithContext(ctx).Where("id = ? AND uid = ?", id, userID).Delete(new(AccessToken)).Error
+	return s.db.WithContext(ctx).Where("id = ? AND uid = ? AND grant_id = 0", id, userID).Delete(new(AccessToken)).Error
 }
 
 var _ errx.NotFound = (*ErrAccessTokenNotExist)(nil)
 
+// getReadableRepository returns the repository with given full name if the
+// user has read access to it. It returns ErrRepoNotExist otherwise, so that
+// private repositories are indistinguishable from nonexistent ones.
+func (s *AccessTokensStore) getReadableRepository(ctx context.Context, userID int64, fullName string) (*Repository, error) {
+	notExist := ErrRepoNotExist{args: errx.Args{"fullName": fullName}}
+	ownerName, repoName, ok := strings.Cut(fullName, "/")
+	if !ok || ownerName == "" || repoName == "" {
+		return nil, notExist
+	}
+
+	owner, err := newUsersStore(s.db).GetByUsername(ctx, ownerName)
+	if err != nil {
+		if IsErrUserNotExist(err) {
+			return nil, notExist
+		}
+		return nil, errors.Wrap(err, "get owner")
+	}
+	repo, err := newReposStore(s.db).GetByName(ctx, owner.ID, repoName)
+	if err != nil {
+		if IsErrRepoNotExist(err) {
+			return nil, notExist
+		}
+		return nil, errors.Wrap(err, "get repository")
+	}
+
+	if !newPermissionsStore(s.db).Authorize(ctx, userID, repo.ID, AccessModeRead,
+		AccessModeOptions{
+			OwnerID: repo.OwnerID,
+			Private: repo.IsPrivate,
+		},
+	) {
+		return nil, notExist
+	}
+	return repo, nil
+}
+
 type ErrAccessTokenNotExist struct {
 	args errx.Args
 }
@@ -127,7 +356,7 @@ func (ErrAccessTokenNotExist) NotFound() bool {
 }
 
 // GetBySHA1 returns the access token with given SHA1. It returns
-// ErrAccessTokenNotExist when not found.
+// ErrAccessTokenNotExist when not found or expired.
 func (s *AccessTokensStore) GetBySHA1(ctx context.Context, sha1 string) (*AccessToken, error) {
 	// No need to waste a query for an empty SHA1.
 	if sha1 == "" {
@@ -136,7 +365,9 @@ func (s *AccessTokensStore) GetBySHA1(ctx context.Context, sha1 string) (*Access
 
 	sha256 := cryptox.SHA256(sha1)
 	token := new(AccessToken)
-	err := s.db.WithContext(ctx).Where("sha256 = ?", sha256).First(token).Error
+	err := s.db.WithContext(ctx).
+		Where("sha256 = ? AND (expires_unix = 0 OR expires_unix > ?)", sha256, s.db.NowFunc().Unix()).
+		First(token).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, ErrAccessTokenNotExist{args: errx.Args{"sha": sha1}}
 	} else if err != nil {
@@ -145,10 +376,10 @@ func (s *AccessTokensStore) GetBySHA1(ctx context.Context, sha1 string) (*Access
 	return token, nil
 }
 
-// List returns all access tokens belongs to given user.
+// List returns all personal access tokens belongs to given user.
 func (s *AccessTokensStore) List(ctx context.Context, userID int64) ([]*AccessToken, error) {
 	var tokens []*AccessToken
-	return tokens, s.db.WithContext(ctx).Where("uid = ?", userID).Order("id ASC").Find(&tokens).Error
+	return tokens, s.db.WithContext(ctx).Where("uid = ? AND grant_id = 0", userID).Order("id ASC").Find(&tokens).Error
 }
 
 // Touch updates the updated time of the given access token to the current time.
@@ -159,3 +390,31 @@ func (s *AccessTokensStore) Touch(ctx context.Context, id int64) error {
 		UpdateColumn("updated_unix", s.db.NowFunc().Unix()).
 		Error
 }
+
+// DeleteExpired deletes all access tokens that have expired and can no longer
+// be refreshed, and returns the number of deleted tokens.
+func (s *AccessTokensStore) DeleteExpired(ctx context.Context) (int64, error) {
+	now := s.db.NowFunc().Unix()
+	result := s.db.WithContext(ctx).
+		Where("expires_unix > 0 AND expires_unix <= ? AND refresh_expires_unix <= ?", now, now).
+		Delete(new(AccessToken))
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredAccessTokens deletes access tokens that have expired.
+func DeleteExpiredAccessTokens() {
+	if taskStatusTable.IsRunning(taskNameDeleteExpiredAccessTokens) {
+		return
+	}
+	taskStatusTable.Start(taskNameDeleteExpiredAccessTokens)
+	defer taskStatusTable.Stop(taskNameDeleteExpiredAccessTokens)
+
+	log.Trace("Doing: DeleteExpiredAccessTokens")
+
+	deleted, err := Handle.AccessTokens().DeleteExpired(context.Background())
+	if err != nil {
+		log.Error("DeleteExpiredAccessTokens: %v", err)
+		return
+	}
+	log.Trace("Deleted %d expired access tokens", deleted)
+}
@@ -87,6 +87,52 @@ func TestAccessToken_AfterFind(t *testing.T) {
 	})
 }
 
+func TestAccessToken_HasScope(t *testing.T) {
+	tests := []struct {
+		scopes string
+		scope  AccessTokenScope
+		want   bool
+	}{
+		{scopes: "", scope: AccessTokenScopeSiteAdmin, want: true},
+		{scopes: "read:repo", scope: AccessTokenScopeReadRepo, want: true},
+		{scopes: "read:repo", scope: AccessTokenScopeWriteRepo, want: false},
+		{scopes: "admin:repo", scope: AccessTokenScopeReadRepo, want: true},
+		{scopes: "write:issue read:user", scope: AccessTokenScopeReadIssue, want: true},
+		{scopes: "write:issue read:user", scope: AccessTokenScopeWriteUser, want: false},
+		{scopes: "admin:org", scope: AccessTokenScopeReadRepo, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.scopes+"/"+string(test.scope), func(t *testing.T) {
+			token := &AccessToken{Scopes: test.scopes}
+			assert.Equal(t, test.want, token.HasScope(test.scope))
+		})
+	}
+}
+
+func TestAccessToken_MaxRepoAccessMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   *AccessToken
+		repoID  int64
+		private bool
+		want    AccessMode
+	}{
+		{name: "unlimited", token: &AccessToken{}, repoID: 1, private: true, want: AccessModeOwner},
+		{name: "read", token: &AccessToken{Scopes: "read:repo"}, repoID: 1, private: true, want: AccessModeRead},
+		{name: "write", token: &AccessToken{Scopes: "write:repo"}, repoID: 1, private: true, want: AccessModeWrite},
+		{name: "no repository scope", token: &AccessToken{Scopes: "read:user"}, repoID: 1, private: true, want: AccessModeNone},
+		{name: "no repository scope but public", token: &AccessToken{Scopes: "read:user"}, repoID: 1, want: AccessModeRead},
+		{name: "listed repository", token: &AccessToken{Scopes: "admin:repo", RepoIDs: "1,2"}, repoID: 2, private: true, want: AccessModeOwner},
+		{name: "unlisted repository", token: &AccessToken{Scopes: "admin:repo", RepoIDs: "1,2"}, repoID: 3, private: true, want: AccessModeNone},
+		{name: "unlisted but public repository", token: &AccessToken{RepoIDs: "1,2"}, repoID: 3, want: AccessModeRead},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.token.MaxRepoAccessMode(test.repoID, test.private))
+		})
+	}
+}
+
 func TestAccessTokens(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -103,7 +149,9 @@ func TestAccessTokens(t *testing.T) {
 		test func(t *testing.T, ctx context.Context, s *AccessTokensStore)
 	}{
 		{"Create", accessTokensCreate},
+		{"CreateWithOptions", accessTokensCreateWithOptions},
 		{"DeleteByID", accessTokensDeleteByID},
+		{"DeleteExpired", accessTokensDeleteExpired},
 		{"GetBySHA1", accessTokensGetBySHA},
 		{"List", accessTokensList},
 		{"Touch", accessTokensTouch},
@@ -123,7 +171,7 @@ func TestAccessTokens(t *testing.T) {
 
 func accessTokensCreate(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 	// Create first access token with name "Test"
-	token, err := s.Create(ctx, 1, "Test")
+	token, err := s.Create(ctx, 1, "Test", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	assert.Equal(t, int64(1), token.UserID)
@@ -136,7 +184,7 @@ func accessTokensCreate(t *testing.T, ctx context.Context, s *AccessTokensStore)
 	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), token.Created.UTC().Format(time.RFC3339))
 
 	// Try create second access token with same name should fail
-	_, err = s.Create(ctx, token.UserID, token.Name)
+	_, err = s.Create(ctx, token.UserID, token.Name, CreateAccessTokenOptions{})
 	wantErr := ErrAccessTokenAlreadyExist{
 		args: errx.Args{
 			"userID": token.UserID,
@@ -146,9 +194,58 @@ func accessTokensCreate(t *testing.T, ctx context.Context, s *AccessTokensStore)
 	assert.Equal(t, wantErr, err)
 }
 
+func accessTokensCreateWithOptions(t *testing.T, ctx context.Context, s *AccessTokensStore) {
+	alice, err := newUsersStore(s.db).Create(ctx, "alice", "alice@example.com", CreateUserOptions{})
+	require.NoError(t, err)
+	bob, err := newUsersStore(s.db).Create(ctx, "bob", "bob@example.com", CreateUserOptions{})
+	require.NoError(t, err)
+	repo, err := newReposStore(s.db).Create(ctx, alice.ID, CreateRepoOptions{Name: "example"})
+	require.NoError(t, err)
+	private, err := newReposStore(s.db).Create(ctx, bob.ID, CreateRepoOptions{Name: "private", Private: true})
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	token, err := s.Create(ctx, alice.ID, "Test",
+		CreateAccessTokenOptions{
+			Scopes:       []AccessTokenScope{AccessTokenScopeWriteRepo, AccessTokenScopeReadUser, AccessTokenScopeWriteRepo},
+			Repositories: []string{"alice/example", "alice/Example"},
+			Expires:      expires,
+		},
+	)
+	require.NoError(t, err)
+
+	token, err = s.GetBySHA1(ctx, token.Sha1)
+	require.NoError(t, err)
+	assert.Equal(t, []AccessTokenScope{AccessTokenScopeWriteRepo, AccessTokenScopeReadUser}, token.ScopeList())
+	assert.Equal(t, []int64{repo.ID}, token.RepositoryIDs())
+	assert.Equal(t, expires.Unix(), token.Expires.Unix())
+
+	t.Run("invalid scope", func(t *testing.T) {
+		_, err := s.Create(ctx, alice.ID, "Invalid scope",
+			CreateAccessTokenOptions{
+				Scopes: []AccessTokenScope{"write:everything"},
+			},
+		)
+		wantErr := ErrInvalidAccessTokenScope{args: errx.Args{"scope": AccessTokenScope("write:everything")}}
+		assert.Equal(t, wantErr, err)
+	})
+
+	t.Run("inaccessible repositories", func(t *testing.T) {
+		for _, fullName := range []string{"alice/nonexistent", "nobody/example", "example", "bob/" + private.Name} {
+			_, err := s.Create(ctx, alice.ID, "Inaccessible repository",
+				CreateAccessTokenOptions{
+					Repositories: []string{fullName},
+				},
+			)
+			wantErr := ErrRepoNotExist{args: errx.Args{"fullName": fullName}}
+			assert.Equal(t, wantErr, err)
+		}
+	})
+}
+
 func accessTokensDeleteByID(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 	// Create an access token with name "Test"
-	token, err := s.Create(ctx, 1, "Test")
+	token, err := s.Create(ctx, 1, "Test", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	// Delete a token with mismatched user ID is noop
@@ -173,9 +270,28 @@ func accessTokensDeleteByID(t *testing.T, ctx context.Context, s *AccessTokensSt
 	assert.Equal(t, wantErr, err)
 }
 
+func accessTokensDeleteExpired(t *testing.T, ctx context.Context, s *AccessTokensStore) {
+	_, err := s.Create(ctx, 1, "Never", CreateAccessTokenOptions{})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, 1, "Later", CreateAccessTokenOptions{Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, 1, "Expired", CreateAccessTokenOptions{Expires: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+
+	deleted, err := s.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	tokens, err := s.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "Never", tokens[0].Name)
+	assert.Equal(t, "Later", tokens[1].Name)
+}
+
 func accessTokensGetBySHA(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 	// Create an access token with name "Test"
-	token, err := s.Create(ctx, 1, "Test")
+	token, err := s.Create(ctx, 1, "Test", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	// We should be able to get it back
@@ -190,17 +306,23 @@ func accessTokensGetBySHA(t *testing.T, ctx context.Context, s *AccessTokensStor
 		},
 	}
 	assert.Equal(t, wantErr, err)
+
+	// Expired tokens are treated as non-existent
+	token, err = s.Create(ctx, 1, "Expired", CreateAccessTokenOptions{Expires: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = s.GetBySHA1(ctx, token.Sha1)
+	assert.True(t, IsErrAccessTokenNotExist(err))
 }
 
 func accessTokensList(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 	// Create two access tokens for user 1
-	_, err := s.Create(ctx, 1, "user1_1")
+	_, err := s.Create(ctx, 1, "user1_1", CreateAccessTokenOptions{})
 	require.NoError(t, err)
-	_, err = s.Create(ctx, 1, "user1_2")
+	_, err = s.Create(ctx, 1, "user1_2", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	// Create one access token for user 2
-	_, err = s.Create(ctx, 2, "user2_1")
+	_, err = s.Create(ctx, 2, "user2_1", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	// List all access tokens for user 1
@@ -217,7 +339,7 @@ func accessTokensList(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 
 func accessTokensTouch(t *testing.T, ctx context.Context, s *AccessTokensStore) {
 	// Create an access token with name "Test"
-	token, err := s.Create(ctx, 1, "Test")
+	token, err := s.Create(ctx, 1, "Test", CreateAccessTokenOptions{})
 	require.NoError(t, err)
 
 	// Updated field is zero now
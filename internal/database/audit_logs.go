@@ -0,0 +1,334 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// AuditAction is the action recorded by an audit log entry, in the form of
+// "<category>.<action>".
+type AuditAction string
+
+const (
+	AuditActionUserLogin                   AuditAction = "user.login"
+	AuditActionUserLoginFailed             AuditAction = "user.login_failed"
+	AuditActionUserTwoFactorEnable         AuditAction = "user.two_factor_enable"
+	AuditActionUserTwoFactorDisable        AuditAction = "user.two_factor_disable"
+	AuditActionUserRecoveryCodesRegenerate AuditAction = "user.recovery_codes_regenerate"
+	AuditActionUserWebAuthnAdd             AuditAction = "user.webauthn_add"
+	AuditActionUserWebAuthnDelete          AuditAction = "user.webauthn_delete"
+	AuditActionUserAccessTokenCreate       AuditAction = "user.access_token_create"
+	AuditActionUserAccessTokenDelete       AuditAction = "user.access_token_delete"
+	AuditActionUserSSHKeyAdd               AuditAction = "user.ssh_key_add"
+	AuditActionUserSSHKeyDelete            AuditAction = "user.ssh_key_delete"
+	AuditActionUserPasswordChange          AuditAction = "user.password_change"
+	AuditActionUserOAuth2ApplicationRevoke AuditAction = "user.oauth2_application_revoke"
+	AuditActionUserSessionRevoke           AuditAction = "user.session_revoke"
+
+	AuditActionRepoDelete                 AuditAction = "repo.delete"
+	AuditActionRepoTransfer               AuditAction = "repo.transfer"
+	AuditActionRepoVisibilityChange       AuditAction = "repo.visibility_change"
+	AuditActionRepoCollaboratorAdd        AuditAction = "repo.collaborator_add"
+	AuditActionRepoCollaboratorRemove     AuditAction = "repo.collaborator_remove"
+	AuditActionRepoCollaboratorModeChange AuditAction = "repo.collaborator_mode_change"
+	AuditActionRepoDeployKeyAdd           AuditAction = "repo.deploy_key_add"
+	AuditActionRepoDeployKeyDelete        AuditAction = "repo.deploy_key_delete"
+	AuditActionRepoProtectedBranchUpdate  AuditAction = "repo.protected_branch_update"
+
+	AuditActionOrgDelete           AuditAction = "org.delete"
+	AuditActionOrgMemberRemove     AuditAction = "org.member_remove"
+	AuditActionOrgTeamCreate       AuditAction = "org.team_create"
+	AuditActionOrgTeamUpdate       AuditAction = "org.team_update"
+	AuditActionOrgTeamDelete       AuditAction = "org.team_delete"
+	AuditActionOrgTeamMemberAdd    AuditAction = "org.team_member_add"
+	AuditActionOrgTeamMemberRemove AuditAction = "org.team_member_remove"
+	AuditActionOrgTeamRepoAdd      AuditAction = "org.team_repo_add"
+	AuditActionOrgTeamRepoRemove   AuditAction = "org.team_repo_remove"
+
+	AuditActionAdminUserCreate       AuditAction = "admin.user_create"
+	AuditActionAdminUserUpdate       AuditAction = "admin.user_update"
+	AuditActionAdminUserDelete       AuditAction = "admin.user_delete"
+	AuditActionAdminAuthSourceCreate AuditAction = "admin.auth_source_create"
+	AuditActionAdminAuthSourceUpdate AuditAction = "admin.auth_source_update"
+	AuditActionAdminAuthSourceDelete AuditAction = "admin.auth_source_delete"
+	AuditActionAdminOperation        AuditAction = "admin.operation"
+	AuditActionAdminLockoutClear     AuditAction = "admin.lockout_clear"
+	AuditActionAdminSessionsRevoke   AuditAction = "admin.sessions_revoke"
+)
+
+// AuditTargetType is the type of the object that an audited action is
+// performed on.
+type AuditTargetType string
+
+const (
+	AuditTargetUser         AuditTargetType = "user"
+	AuditTargetOrganization AuditTargetType = "organization"
+	AuditTargetTeam         AuditTargetType = "team"
+	AuditTargetRepository   AuditTargetType = "repository"
+	AuditTargetLoginSource  AuditTargetType = "login_source"
+	AuditTargetIPAddress    AuditTargetType = "ip_address"
+	AuditTargetSystem       AuditTargetType = "system"
+)
+
+// AuditTarget is the object that an audited action is performed on.
+type AuditTarget struct {
+	Type AuditTargetType
+	ID   int64
+	Name string
+}
+
+// AuditTargetOfUser returns the audit target of the user, which may also be an
+// organization.
+func AuditTargetOfUser(u *User) AuditTarget {
+	typ := AuditTargetUser
+	if u.IsOrganization() {
+		typ = AuditTargetOrganization
+	}
+	return AuditTarget{Type: typ, ID: u.ID, Name: u.Name}
+}
+
+// AuditTargetOfRepository returns the audit target of the repository, whose
+// owner must be loaded.
+func AuditTargetOfRepository(repo *Repository) AuditTarget {
+	return AuditTarget{Type: AuditTargetRepository, ID: repo.ID, Name: repo.FullName()}
+}
+
+// AuditTargetOfTeam returns the audit target of the team in the organization.
+func AuditTargetOfTeam(org *User, team *Team) AuditTarget {
+	return AuditTarget{Type: AuditTargetTeam, ID: team.ID, Name: org.Name + "/" + team.Name}
+}
+
+// AuditTargetOfLoginSource returns the audit target of the login source.
+func AuditTargetOfLoginSource(source *LoginSource) AuditTarget {
+	return AuditTarget{Type: AuditTargetLoginSource, ID: source.ID, Name: source.Name}
+}
+
+// AuditDetailsOfUserUpdate returns the details of the update to the user for
+// the audit log, which only describe changes to privileges and credentials.
+func AuditDetailsOfUserUpdate(u *User, opts UpdateUserOptions) string {
+	var changes []string
+	changeBool := func(name string, old bool, new *bool) {
+		if new != nil && *new != old {
+			changes = append(changes, fmt.Sprintf("%s: %t -> %t", name, old, *new))
+		}
+	}
+	changeBool("admin", u.IsAdmin, opts.IsAdmin)
+	changeBool("active", u.IsActive, opts.IsActivated)
+	changeBool("prohibit login", u.ProhibitLogin, opts.ProhibitLogin)
+	changeBool("allow Git hooks", u.AllowGitHook, opts.AllowGitHook)
+	changeBool("allow local import", u.AllowImportLocal, opts.AllowImportLocal)
+	if opts.LoginSource != nil && *opts.LoginSource != u.LoginSource {
+		changes = append(changes, fmt.Sprintf("login source: %d -> %d", u.LoginSource, *opts.LoginSource))
+	}
+	if opts.Email != nil && *opts.Email != u.Email {
+		changes = append(changes, fmt.Sprintf("email: %s -> %s", u.Email, *opts.Email))
+	}
+	if opts.Password != nil {
+		changes = append(changes, "password changed")
+	}
+	return strings.Join(changes, ", ")
+}
+
+// AuditDetailsOfAccessToken returns the details of the access token for the
+// audit log, which never contain the token itself.
+func AuditDetailsOfAccessToken(t *AccessToken) string {
+	details := fmt.Sprintf("token %q", t.Name)
+	if t.Scopes != "" {
+		details += ", scopes: " + t.Scopes
+	}
+	if t.RepoIDs != "" {
+		details += ", repository IDs: " + t.RepoIDs
+	}
+	if t.ExpiresUnix > 0 {
+		details += ", expires: " + time.Unix(t.ExpiresUnix, 0).UTC().Format(time.RFC3339)
+	}
+	return details
+}
+
+// AuditLog is an entry of the append-only audit log, which records who did
+// what to which object and from where.
+type AuditLog struct {
+	ID int64 `gorm:"primaryKey" json:"id"`
+	// The actor is the user who performed the action. The ID is zero when the
+	// actor is not signed in, e.g. a failed login, and the name is kept after the
+	// user is deleted.
+	ActorID    int64           `gorm:"index" json:"actor_id"`
+	ActorName  string          `gorm:"not null" json:"actor_name"`
+	Action     AuditAction     `gorm:"index;not null" json:"action"`
+	TargetType AuditTargetType `gorm:"not null" json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	TargetName string          `gorm:"not null" json:"target_name"`
+	Details    string          `gorm:"type:TEXT" json:"details,omitempty"`
+	IPAddress  string          `json:"ip_address"`
+
+	Created     time.Time `gorm:"-" json:"-"`
+	CreatedUnix int64     `gorm:"index" json:"created_unix"`
+}
+
+// BeforeCreate implements the GORM create hook.
+func (l *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if l.CreatedUnix == 0 {
+		l.CreatedUnix = tx.NowFunc().Unix()
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (l *AuditLog) AfterFind(_ *gorm.DB) error {
+	l.Created = time.Unix(l.CreatedUnix, 0).Local()
+	return nil
+}
+
+// AuditLogsStore is the storage layer for audit logs. Entries can only be
+// created, and deleted in bulk when they are older than the retention period.
+type AuditLogsStore struct {
+	db *gorm.DB
+}
+
+func newAuditLogsStore(db *gorm.DB) *AuditLogsStore {
+	return &AuditLogsStore{db: db}
+}
+
+type CreateAuditLogOptions struct {
+	ActorID   int64
+	ActorName string
+	IPAddress string
+	Action    AuditAction
+	Target    AuditTarget
+	Details   string
+}
+
+// Create creates a new audit log entry.
+func (s *AuditLogsStore) Create(ctx context.Context, opts CreateAuditLogOptions) error {
+	return s.db.WithContext(ctx).Create(
+		&AuditLog{
+			ActorID:    opts.ActorID,
+			ActorName:  opts.ActorName,
+			Action:     opts.Action,
+			TargetType: opts.Target.Type,
+			TargetID:   opts.Target.ID,
+			TargetName: opts.Target.Name,
+			Details:    opts.Details,
+			IPAddress:  opts.IPAddress,
+		},
+	).Error
+}
+
+type ListAuditLogsOptions struct {
+	// The name of the actor, matched case-insensitively.
+	Actor string
+	// The action, which matches all actions of the category when it ends with a
+	// dot, e.g. "repo.".
+	Action string
+	// The substring of the target name, matched case-insensitively.
+	Target string
+	// The IP address of the actor.
+	IPAddress string
+	// The time range of entries, zero values are unbounded.
+	Since time.Time
+	Until time.Time
+}
+
+func (s *AuditLogsStore) filter(ctx context.Context, opts ListAuditLogsOptions) *gorm.DB {
+	tx := s.db.WithContext(ctx).Model(&AuditLog{})
+	if opts.Actor != "" {
+		tx = tx.Where("LOWER(actor_name) = ?", strings.ToLower(opts.Actor))
+	}
+	if opts.Action != "" {
+		if strings.HasSuffix(opts.Action, ".") {
+			tx = tx.Where("action LIKE ?", opts.Action+"%")
+		} else {
+			tx = tx.Where("action = ?", opts.Action)
+		}
+	}
+	if opts.Target != "" {
+		tx = tx.Where("LOWER(target_name) LIKE ?", "%"+strings.ToLower(opts.Target)+"%")
+	}
+	if opts.IPAddress != "" {
+		tx = tx.Where("ip_address = ?", opts.IPAddress)
+	}
+	if !opts.Since.IsZero() {
+		tx = tx.Where("created_unix >= ?", opts.Since.Unix())
+	}
+	if !opts.Until.IsZero() {
+		tx = tx.Where("created_unix < ?", opts.Until.Unix())
+	}
+	return tx
+}
+
+// List returns a list of audit logs that match the options. Results are
+// paginated by given page and page size, and sorted by primary key (id) in
+// descending order.
+func (s *AuditLogsStore) List(ctx context.Context, opts ListAuditLogsOptions, page, pageSize int) ([]*AuditLog, error) {
+	logs := make([]*AuditLog, 0, pageSize)
+	return logs, s.filter(ctx, opts).
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Order("id DESC").
+		Find(&logs).
+		Error
+}
+
+// Count returns the number of audit logs that match the options.
+func (s *AuditLogsStore) Count(ctx context.Context, opts ListAuditLogsOptions) (int64, error) {
+	var count int64
+	return count, s.filter(ctx, opts).Count(&count).Error
+}
+
+// Iterate calls fn with each audit log that matches the options, in ascending
+// order of primary key (id). It stops at the first error returned by fn.
+func (s *AuditLogsStore) Iterate(ctx context.Context, opts ListAuditLogsOptions, fn func(*AuditLog) error) error {
+	rows, err := s.filter(ctx, opts).Order("id ASC").Rows()
+	if err != nil {
+		return errors.Wrap(err, "iterate audit logs")
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var l AuditLog
+		err = s.db.ScanRows(rows, &l)
+		if err != nil {
+			return errors.Wrap(err, "scan rows")
+		}
+		if err = fn(&l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DeleteOlderThan deletes audit logs that were created before the given time,
+// and returns the number of deleted entries.
+func (s *AuditLogsStore) DeleteOlderThan(ctx context.Context, t time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("created_unix < ?", t.Unix()).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteOldAuditLogs deletes audit logs that are older than the retention
+// period.
+func DeleteOldAuditLogs() {
+	if taskStatusTable.IsRunning(taskNameAuditLogCleanup) {
+		return
+	}
+	taskStatusTable.Start(taskNameAuditLogCleanup)
+	defer taskStatusTable.Stop(taskNameAuditLogCleanup)
+
+	log.Trace("Doing: DeleteOldAuditLogs")
+
+	if conf.Cron.AuditLogCleanup.OlderThan <= 0 {
+		return
+	}
+	deleted, err := Handle.AuditLogs().DeleteOlderThan(context.Background(), time.Now().Add(-conf.Cron.AuditLogCleanup.OlderThan))
+	if err != nil {
+		log.Error("DeleteOldAuditLogs: %v", err)
+		return
+	}
+	log.Trace("Deleted %d old audit logs", deleted)
+}
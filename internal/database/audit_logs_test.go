@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditDetailsOfUserUpdate(t *testing.T) {
+	u := &User{
+		Email:       "alice@example.com",
+		LoginSource: 1,
+		IsActive:    true,
+	}
+	yes, no := true, false
+	sameSource, otherSource := int64(1), int64(2)
+	sameEmail, otherEmail := "alice@example.com", "alice@example.org"
+	password := "password"
+
+	tests := []struct {
+		name string
+		opts UpdateUserOptions
+		want string
+	}{
+		{
+			name: "no changes",
+			opts: UpdateUserOptions{
+				IsActivated: &yes,
+				IsAdmin:     &no,
+				LoginSource: &sameSource,
+				Email:       &sameEmail,
+			},
+			want: "",
+		},
+		{
+			name: "privileges",
+			opts: UpdateUserOptions{
+				IsActivated:   &no,
+				IsAdmin:       &yes,
+				ProhibitLogin: &yes,
+			},
+			want: "admin: false -> true, active: true -> false, prohibit login: false -> true",
+		},
+		{
+			name: "credentials",
+			opts: UpdateUserOptions{
+				LoginSource: &otherSource,
+				Email:       &otherEmail,
+				Password:    &password,
+			},
+			want: "login source: 1 -> 2, email: alice@example.com -> alice@example.org, password changed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, AuditDetailsOfUserUpdate(u, test.opts))
+		})
+	}
+}
+
+func TestAuditDetailsOfAccessToken(t *testing.T) {
+	t.Run("unrestricted", func(t *testing.T) {
+		got := AuditDetailsOfAccessToken(&AccessToken{Name: "Test", Sha1: "secret", SHA256: "secret"})
+		assert.Equal(t, `token "Test"`, got)
+	})
+
+	t.Run("restricted", func(t *testing.T) {
+		got := AuditDetailsOfAccessToken(
+			&AccessToken{
+				Name:        "CI",
+				Scopes:      "read:repo write:repo",
+				RepoIDs:     "1,2",
+				ExpiresUnix: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix(),
+			},
+		)
+		assert.Equal(t, `token "CI", scopes: read:repo write:repo, repository IDs: 1,2, expires: 2026-01-02T03:04:05Z`, got)
+	})
+}
+
+func TestAuditLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &AuditLogsStore{
+		db: newTestDB(t, "AuditLogsStore"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *AuditLogsStore)
+	}{
+		{"Create", auditLogsCreate},
+		{"List", auditLogsList},
+		{"Count", auditLogsCount},
+		{"Iterate", auditLogsIterate},
+		{"DeleteOlderThan", auditLogsDeleteOlderThan},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+func auditLogsCreate(t *testing.T, ctx context.Context, s *AuditLogsStore) {
+	err := s.Create(ctx,
+		CreateAuditLogOptions{
+			ActorID:   1,
+			ActorName: "alice",
+			IPAddress: "127.0.0.1",
+			Action:    AuditActionRepoDelete,
+			Target:    AuditTarget{Type: AuditTargetRepository, ID: 2, Name: "alice/example"},
+			Details:   "details",
+		},
+	)
+	require.NoError(t, err)
+
+	logs, err := s.List(ctx, ListAuditLogsOptions{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+
+	got := logs[0]
+	assert.Equal(t, int64(1), got.ActorID)
+	assert.Equal(t, "alice", got.ActorName)
+	assert.Equal(t, "127.0.0.1", got.IPAddress)
+	assert.Equal(t, AuditActionRepoDelete, got.Action)
+	assert.Equal(t, AuditTargetRepository, got.TargetType)
+	assert.Equal(t, int64(2), got.TargetID)
+	assert.Equal(t, "alice/example", got.TargetName)
+	assert.Equal(t, "details", got.Details)
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), got.Created.UTC().Format(time.RFC3339))
+}
+
+// setupAuditLogs creates audit logs that are one day apart from each other,
+// starting from the given time.
+func setupAuditLogs(t *testing.T, s *AuditLogsStore, start time.Time) {
+	t.Helper()
+
+	logs := []*AuditLog{
+		{ActorID: 1, ActorName: "alice", Action: AuditActionUserLogin, TargetType: AuditTargetUser, TargetID: 1, TargetName: "alice", IPAddress: "10.0.0.1"},
+		{ActorName: "bob", Action: AuditActionUserLoginFailed, TargetType: AuditTargetUser, TargetName: "bob", IPAddress: "10.0.0.2"},
+		{ActorID: 1, ActorName: "alice", Action: AuditActionRepoDelete, TargetType: AuditTargetRepository, TargetID: 1, TargetName: "alice/Example", IPAddress: "10.0.0.1"},
+		{ActorID: 2, ActorName: "Admin", Action: AuditActionRepoTransfer, TargetType: AuditTargetRepository, TargetID: 2, TargetName: "org/example", IPAddress: "10.0.0.3"},
+	}
+	for i, l := range logs {
+		l.CreatedUnix = start.AddDate(0, 0, i).Unix()
+		require.NoError(t, s.db.Create(l).Error)
+	}
+}
+
+func auditLogsList(t *testing.T, ctx context.Context, s *AuditLogsStore) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	setupAuditLogs(t, s, start)
+
+	targetNames := func(logs []*AuditLog) []string {
+		names := make([]string, 0, len(logs))
+		for _, l := range logs {
+			names = append(names, l.TargetName)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name string
+		opts ListAuditLogsOptions
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{"org/example", "alice/Example", "bob", "alice"},
+		},
+		{
+			name: "actor",
+			opts: ListAuditLogsOptions{Actor: "ADMIN"},
+			want: []string{"org/example"},
+		},
+		{
+			name: "action",
+			opts: ListAuditLogsOptions{Action: string(AuditActionUserLogin)},
+			want: []string{"alice"},
+		},
+		{
+			name: "action category",
+			opts: ListAuditLogsOptions{Action: "repo."},
+			want: []string{"org/example", "alice/Example"},
+		},
+		{
+			name: "target",
+			opts: ListAuditLogsOptions{Target: "/example"},
+			want: []string{"org/example", "alice/Example"},
+		},
+		{
+			name: "IP address",
+			opts: ListAuditLogsOptions{IPAddress: "10.0.0.1"},
+			want: []string{"alice/Example", "alice"},
+		},
+		{
+			name: "time range",
+			opts: ListAuditLogsOptions{Since: start.AddDate(0, 0, 1), Until: start.AddDate(0, 0, 3)},
+			want: []string{"alice/Example", "bob"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs, err := s.List(ctx, test.opts, 1, 10)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, targetNames(logs))
+		})
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		logs, err := s.List(ctx, ListAuditLogsOptions{}, 2, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, targetNames(logs))
+	})
+}
+
+func auditLogsCount(t *testing.T, ctx context.Context, s *AuditLogsStore) {
+	setupAuditLogs(t, s, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	count, err := s.Count(ctx, ListAuditLogsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	count, err = s.Count(ctx, ListAuditLogsOptions{Actor: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func auditLogsIterate(t *testing.T, ctx context.Context, s *AuditLogsStore) {
+	setupAuditLogs(t, s, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	var got []string
+	err := s.Iterate(ctx, ListAuditLogsOptions{Action: "user."}, func(l *AuditLog) error {
+		got = append(got, l.TargetName)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, got)
+
+	// Stop at the first error.
+	wantErr := errors.New("stop")
+	got = nil
+	err = s.Iterate(ctx, ListAuditLogsOptions{}, func(l *AuditLog) error {
+		got = append(got, l.TargetName)
+		return wantErr
+	})
+	assert.Equal(t, wantErr, err)
+	assert.Equal(t, []string{"alice"}, got)
+}
+
+func auditLogsDeleteOlderThan(t *testing.T, ctx context.Context, s *AuditLogsStore) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	setupAuditLogs(t, s, start)
+
+	deleted, err := s.DeleteOlderThan(ctx, start.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	count, err := s.Count(ctx, ListAuditLogsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
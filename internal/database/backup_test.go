@@ -27,7 +27,7 @@ func TestDumpAndImport(t *testing.T) {
 	}
 	t.Parallel()
 
-	const wantTables = 8
+	const wantTables = 18
 	if len(Tables) != wantTables {
 		t.Fatalf("New table has added (want %d got %d), please add new tests for the table and update this check", wantTables, len(Tables))
 	}
@@ -85,6 +85,18 @@ func setupDBToDump(t *testing.T, db *gorm.DB) {
 			SHA256:      cryptox.SHA256(cryptox.SHA1("1b2dccd1-a262-470f-bb8c-7fc73192e9bb")),
 			CreatedUnix: 1588568886,
 		},
+		&AccessToken{
+			UserID:             2,
+			Name:               "Dashboard",
+			Sha1:               cryptox.SHA256(cryptox.SHA1("6d2c5f6e-3c0f-4e5d-8a59-0c8f7b0e4f4a"))[:40],
+			SHA256:             cryptox.SHA256(cryptox.SHA1("6d2c5f6e-3c0f-4e5d-8a59-0c8f7b0e4f4a")),
+			Scopes:             "read:repo read:user",
+			GrantID:            1,
+			RefreshSHA256:      cryptox.SHA256("wjJ3bnQ0ZyoVEqW8l8aKyYc7BmxTKxDe1uzzRu2h"),
+			CreatedUnix:        1588568886,
+			ExpiresUnix:        1588572486, // 1 hour later
+			RefreshExpiresUnix: 1591160886, // 30 days later
+		},
 
 		&Action{
 			ID:           1,
@@ -128,6 +140,52 @@ func setupDBToDump(t *testing.T, db *gorm.DB) {
 			CreatedUnix:  1588568886,
 		},
 
+		&AuditLog{
+			ID:          1,
+			ActorID:     1,
+			ActorName:   "alice",
+			Action:      AuditActionRepoCollaboratorAdd,
+			TargetType:  AuditTargetRepository,
+			TargetID:    1,
+			TargetName:  "alice/example",
+			Details:     "user bob",
+			IPAddress:   "127.0.0.1",
+			CreatedUnix: 1588568886,
+		},
+		&AuditLog{
+			ID:          2,
+			ActorName:   "bob",
+			Action:      AuditActionUserLoginFailed,
+			TargetType:  AuditTargetUser,
+			TargetName:  "bob",
+			Details:     "bad credentials",
+			IPAddress:   "::1",
+			CreatedUnix: 1588568886,
+		},
+
+		&CommitStatus{
+			ID:          1,
+			RepoID:      1,
+			SHA:         "085bb3bcb608e1e8451d4b2432f8ecbe6306e7e7",
+			State:       CommitStatusPending,
+			TargetURL:   "https://ci.example.com/builds/1",
+			Description: "The build is running",
+			Context:     "ci/build",
+			CreatorID:   1,
+			CreatedAt:   time.Unix(1588568886, 0).UTC(),
+		},
+		&CommitStatus{
+			ID:          2,
+			RepoID:      1,
+			SHA:         "085bb3bcb608e1e8451d4b2432f8ecbe6306e7e7",
+			State:       CommitStatusSuccess,
+			TargetURL:   "https://ci.example.com/builds/1",
+			Description: "The build succeeded",
+			Context:     "ci/build",
+			CreatorID:   1,
+			CreatedAt:   time.Unix(1588572486, 0).UTC(),
+		},
+
 		&EmailAddress{
 			ID:          1,
 			UserID:      1,
@@ -152,6 +210,14 @@ func setupDBToDump(t *testing.T, db *gorm.DB) {
 			FollowID: 1,
 		},
 
+		&LFSLock{
+			ID:        1,
+			RepoID:    1,
+			OwnerID:   1,
+			Path:      "images/logo.png",
+			CreatedAt: time.Unix(1588568886, 0).UTC(),
+		},
+
 		&LFSObject{
 			RepoID:    1,
 			OID:       "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
@@ -167,6 +233,41 @@ func setupDBToDump(t *testing.T, db *gorm.DB) {
 			CreatedAt: time.Unix(1588568886, 0).UTC(),
 		},
 
+		&LoginFailure{
+			ID:              1,
+			Kind:            LoginFailureAccount,
+			Name:            "alice",
+			Failures:        10,
+			LastFailedUnix:  1588568886,
+			LockedUntilUnix: 1588569786, // 15 minutes later
+		},
+		&LoginFailure{
+			ID:             2,
+			Kind:           LoginFailureIP,
+			Name:           "127.0.0.1",
+			Failures:       3,
+			LastFailedUnix: 1588568886,
+		},
+
+		&LoginSession{
+			ID:           1,
+			UserID:       1,
+			KeySHA256:    "0b2a35f54e5e1a3b9b0b3f7f8bbd7e1b33c6c5d7e5a4b3a2f1e0d9c8b7a69584",
+			UserAgent:    "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0",
+			IPAddress:    "127.0.0.1",
+			CreatedUnix:  1588568886,
+			LastSeenUnix: 1588572486, // 1 hour later
+		},
+		&LoginSession{
+			ID:           2,
+			UserID:       2,
+			KeySHA256:    "5f2b3c8d9e0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071829304",
+			UserAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+			IPAddress:    "::1",
+			CreatedUnix:  1588568886,
+			LastSeenUnix: 1588568886,
+		},
+
 		&LoginSource{
 			Type:      auth.PAM,
 			Name:      "My PAM",
@@ -193,6 +294,79 @@ func setupDBToDump(t *testing.T, db *gorm.DB) {
 			Description: "This is a notice",
 			CreatedUnix: 1588568886,
 		},
+
+		&OAuth2Application{
+			ID:                 1,
+			UserID:             1,
+			Name:               "Dashboard",
+			ClientID:           "Lh4mYf8Pn3zXk2QbTw9RcVj6sAeD7uGo",
+			ClientSecretSHA256: cryptox.SHA256("tq5EJrXQ9FcnWbY2mGk8sLvP3dHa6NuZ1oRy4TeC"),
+			RedirectURIs:       "https://dashboard.example.com/callback",
+			Confidential:       true,
+			CreatedUnix:        1588568886,
+		},
+		&OAuth2Application{
+			ID:                 2,
+			UserID:             2,
+			Name:               "CLI",
+			ClientID:           "Vb3nKs8Wq2HyTf6Lm9PzXr4Dc7JgUa5E",
+			ClientSecretSHA256: cryptox.SHA256("Zk7TfWb2Qn9XsLr4Ep6MaHc3Jv8Yg5Ud1oNi2KwR"),
+			RedirectURIs:       "http://127.0.0.1:8080/callback\nhttp://localhost:8080/callback",
+			CreatedUnix:        1588568886,
+			UpdatedUnix:        1588572486, // 1 hour later
+		},
+
+		&OAuth2AuthorizationCode{
+			ID:            1,
+			GrantID:       1,
+			SHA256:        cryptox.SHA256("Pq2WeR7tYu3IoP9aSd4FgH6jKl8ZxC5vBn1MmQ0w"),
+			Scopes:        "read:repo read:user",
+			RedirectURI:   "https://dashboard.example.com/callback",
+			CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			ExpiresUnix:   1588569486, // 10 minutes later
+		},
+
+		&OAuth2Grant{
+			ID:            1,
+			UserID:        2,
+			ApplicationID: 1,
+			Scopes:        "read:repo read:user",
+			CreatedUnix:   1588568886,
+			UpdatedUnix:   1588572486, // 1 hour later
+		},
+
+		&PushMirror{
+			ID:           1,
+			RepoID:       1,
+			Address:      "https://example.com/alice/example.git",
+			SyncInterval: 8,
+			LastError:    "git push --mirror: remote: Repository not found.",
+			LastSyncUnix: 1588568886,
+			NextSyncUnix: 1588597686, // 8 hours later
+			CreatedUnix:  1588568886,
+		},
+
+		&WebAuthnCredential{
+			ID:                 1,
+			UserID:             1,
+			Name:               "YubiKey",
+			CredentialID:       []byte("yubikey-credential-id"),
+			CredentialIDSHA256: cryptox.SHA256("yubikey-credential-id"),
+			PublicKey:          []byte{0xa5, 0x01, 0x02, 0x03, 0x26},
+			SignCount:          42,
+			CreatedUnix:        1588568886,
+			LastUsedUnix:       1588572486, // 1 hour later
+		},
+		&WebAuthnCredential{
+			ID:                 2,
+			UserID:             1,
+			Name:               "Laptop",
+			CredentialID:       []byte("laptop-credential-id"),
+			CredentialIDSHA256: cryptox.SHA256("laptop-credential-id"),
+			PublicKey:          []byte{0xa4, 0x01, 0x01, 0x03, 0x27},
+			Passwordless:       true,
+			CreatedUnix:        1588568886,
+		},
 	}
 	for _, val := range vals {
 		err := db.Create(val).Error
@@ -33,6 +33,18 @@ const (
 	CommentTypeCommentRef
 	// Reference from a pull request
 	CommentTypePullRef
+	// Merge of a pull request without meeting branch protection conditions
+	CommentTypeOverrideProtection
+	// Submitted review of a pull request
+	CommentTypeReview
+	// Comment on a line of the diff of a pull request, belongs to a review (ReviewID > 0)
+	CommentTypeCode
+	// Removal of a pull request from the merge queue, the reason is in the content
+	CommentTypeMergeQueueEjected
+	// Auto-merge of a pull request is enabled
+	CommentTypeAutoMergeEnabled
+	// Auto-merge of a pull request is canceled, the reason (if any) is in the content
+	CommentTypeAutoMergeCanceled
 )
 
 type CommentTag int
@@ -65,6 +77,12 @@ type Comment struct {
 	// Reference issue in commit message
 	CommitSHA string `xorm:"VARCHAR(40)"`
 
+	// For reviews and code comments of a pull request. The line of a code comment
+	// is negative when it is on the old side of the diff.
+	ReviewID int64   `xorm:"INDEX"`
+	Review   *Review `xorm:"-" json:"-" gorm:"-"`
+	TreePath string
+
 	Attachments []*Attachment `xorm:"-" json:"-" gorm:"-"`
 
 	// For view issue page.
@@ -193,6 +211,8 @@ func createComment(e *xorm.Session, opts *CreateCommentOptions) (_ *Comment, err
 		CommitSHA: opts.CommitSHA,
 		Line:      opts.LineNum,
 		Content:   opts.Content,
+		ReviewID:  opts.ReviewID,
+		TreePath:  opts.TreePath,
 	}
 	if _, err = e.Insert(comment); err != nil {
 		return nil, err
@@ -311,6 +331,8 @@ type CreateCommentOptions struct {
 	CommitID    int64
 	CommitSHA   string
 	LineNum     int64
+	ReviewID    int64
+	TreePath    string
 	Content     string
 	Attachments []string // UUIDs of attachments
 }
@@ -432,7 +454,10 @@ func loadCommentsAttributes(e Engine, comments []*Comment) (err error) {
 
 func getCommentsByIssueIDSince(e Engine, issueID, since int64) ([]*Comment, error) {
 	comments := make([]*Comment, 0, 10)
-	sess := e.Where("issue_id = ?", issueID).Asc("created_unix")
+	sess := e.Where("issue_id = ?", issueID).
+		// Comments created before reviews were introduced have NULL review ID.
+		And("(review_id IS NULL OR review_id NOT IN (SELECT id FROM review WHERE state = ?))", ReviewStatePending).
+		Asc("created_unix")
 	if since > 0 {
 		sess.And("updated_unix >= ?", since)
 	}
@@ -445,7 +470,10 @@ func getCommentsByIssueIDSince(e Engine, issueID, since int64) ([]*Comment, erro
 
 func getCommentsByRepoIDSince(e Engine, repoID, since int64) ([]*Comment, error) {
 	comments := make([]*Comment, 0, 10)
-	sess := e.Where("issue.repo_id = ?", repoID).Join("INNER", "issue", "issue.id = comment.issue_id").Asc("comment.created_unix")
+	sess := e.Where("issue.repo_id = ?", repoID).
+		And("(comment.review_id IS NULL OR comment.review_id NOT IN (SELECT id FROM review WHERE state = ?))", ReviewStatePending).
+		Join("INNER", "issue", "issue.id = comment.issue_id").
+		Asc("comment.created_unix")
 	if since > 0 {
 		sess.And("comment.updated_unix >= ?", since)
 	}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+
+	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+)
+
+// CommitStatusState is the state of a commit status.
+type CommitStatusState string
+
+const (
+	CommitStatusPending CommitStatusState = "pending"
+	CommitStatusSuccess CommitStatusState = "success"
+	CommitStatusError   CommitStatusState = "error"
+	CommitStatusFailure CommitStatusState = "failure"
+)
+
+// IsValid returns true if the state is one of the known states.
+func (s CommitStatusState) IsValid() bool {
+	switch s {
+	case CommitStatusPending, CommitStatusSuccess, CommitStatusError, CommitStatusFailure:
+		return true
+	}
+	return false
+}
+
+// CommitStatus is a status reported by an external system (e.g. a CI service)
+// against a commit.
+type CommitStatus struct {
+	ID          int64             `gorm:"primaryKey"`
+	RepoID      int64             `gorm:"index:idx_commit_status_repo_id_sha;not null"`
+	SHA         string            `gorm:"type:VARCHAR(64);index:idx_commit_status_repo_id_sha;not null"`
+	State       CommitStatusState `gorm:"type:VARCHAR(7);not null"`
+	TargetURL   string            `gorm:"type:TEXT"`
+	Description string            `gorm:"type:TEXT"`
+	Context     string            `gorm:"type:VARCHAR(255);not null"`
+	CreatorID   int64             `gorm:"not null"`
+	Creator     *User             `gorm:"-" json:"-"`
+	CreatedAt   time.Time         `gorm:"not null"`
+}
+
+// CommitStatusesStore is the storage layer for commit statuses.
+type CommitStatusesStore struct {
+	db *gorm.DB
+}
+
+func newCommitStatusesStore(db *gorm.DB) *CommitStatusesStore {
+	return &CommitStatusesStore{db: db}
+}
+
+type CreateCommitStatusOptions struct {
+	State       CommitStatusState
+	TargetURL   string
+	Description string
+	Context     string
+}
+
+// Create creates a new commit status for the commit with given SHA in the
+// repository, and prepares webhooks of the status event. The creator and owner
+// of the repository must be loaded.
+func (s *CommitStatusesStore) Create(ctx context.Context, creator *User, repo *Repository, sha string, opts CreateCommitStatusOptions) (*CommitStatus, error) {
+	if !opts.State.IsValid() {
+		return nil, errors.Errorf("invalid state %q", opts.State)
+	}
+	if opts.Context == "" {
+		opts.Context = "default"
+	}
+
+	status := &CommitStatus{
+		RepoID:      repo.ID,
+		SHA:         sha,
+		State:       opts.State,
+		TargetURL:   opts.TargetURL,
+		Description: opts.Description,
+		Context:     opts.Context,
+		CreatorID:   creator.ID,
+		Creator:     creator,
+	}
+	err := s.db.WithContext(ctx).Create(status).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "create")
+	}
+
+	err = PrepareWebhooks(repo, HookEventTypeStatus, &apiv1types.WebhookStatusPayload{
+		ID:          status.ID,
+		SHA:         status.SHA,
+		State:       apiv1types.CommitStatusState(status.State),
+		TargetURL:   status.TargetURL,
+		Description: status.Description,
+		Context:     status.Context,
+		Created:     status.CreatedAt,
+		Repository:  repo.APIFormat(repo.Owner),
+		Sender:      creator.APIFormat(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare webhooks")
+	}
+	return status, nil
+}
+
+// ListBySHA returns all commit statuses of the commit with given SHA in the
+// repository, in reverse chronological order.
+func (s *CommitStatusesStore) ListBySHA(ctx context.Context, repoID int64, sha string) ([]*CommitStatus, error) {
+	var statuses []*CommitStatus
+	return statuses, s.db.WithContext(ctx).
+		Where("repo_id = ? AND sha = ?", repoID, sha).
+		Order("id DESC").
+		Find(&statuses).
+		Error
+}
+
+// ListLatestBySHA returns the latest commit status of each context for the
+// commit with given SHA in the repository, sorted by context.
+func (s *CommitStatusesStore) ListLatestBySHA(ctx context.Context, repoID int64, sha string) ([]*CommitStatus, error) {
+	statuses, err := s.ListBySHA(ctx, repoID, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(statuses))
+	latest := make([]*CommitStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if seen[status.Context] {
+			continue
+		}
+		seen[status.Context] = true
+		latest = append(latest, status)
+	}
+	sort.Slice(latest, func(i, j int) bool {
+		return latest[i].Context < latest[j].Context
+	})
+	return latest, nil
+}
+
+// LoadCreators loads the creator of each commit status. Creators that no longer
+// exist are replaced by the ghost user.
+func (s *CommitStatusesStore) LoadCreators(ctx context.Context, statuses []*CommitStatus) error {
+	users := make(map[int64]*User)
+	for _, status := range statuses {
+		if status.Creator != nil {
+			continue
+		}
+
+		creator, ok := users[status.CreatorID]
+		if !ok {
+			var err error
+			creator, err = newUsersStore(s.db).GetByID(ctx, status.CreatorID)
+			if IsErrUserNotExist(err) {
+				creator = NewGhostUser()
+			} else if err != nil {
+				return errors.Wrapf(err, "get user [id: %d]", status.CreatorID)
+			}
+			users[status.CreatorID] = creator
+		}
+		status.Creator = creator
+	}
+	return nil
+}
+
+// CombinedCommitStatusState returns the combined state of given latest commit
+// statuses of each context. It is "failure" if any of the statuses is "error"
+// or "failure", "pending" if there is no status or any of the statuses is
+// "pending", and "success" otherwise.
+func CombinedCommitStatusState(statuses []*CommitStatus) CommitStatusState {
+	if len(statuses) == 0 {
+		return CommitStatusPending
+	}
+
+	state := CommitStatusSuccess
+	for _, status := range statuses {
+		switch status.State {
+		case CommitStatusError, CommitStatusFailure:
+			return CommitStatusFailure
+		case CommitStatusPending:
+			state = CommitStatusPending
+		}
+	}
+	return state
+}
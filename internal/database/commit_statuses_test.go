@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func TestCommitStatuses(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &CommitStatusesStore{
+		db: newTestDB(t, "CommitStatusesStore"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *CommitStatusesStore)
+	}{
+		{"Create", commitStatusesCreate},
+		{"ListBySHA", commitStatusesListBySHA},
+		{"ListLatestBySHA", commitStatusesListLatestBySHA},
+		{"LoadCreators", commitStatusesLoadCreators},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+const testCommitStatusSHA = "085bb3bcb608e1e8451d4b2432f8ecbe6306e7e7"
+
+func createCommitStatusTestRepo(t *testing.T, ctx context.Context, s *CommitStatusesStore) (*User, *Repository) {
+	conf.SetMockSSH(t, conf.SSHOpts{})
+
+	alice, err := newUsersStore(s.db).Create(ctx, "alice", "alice@example.com", CreateUserOptions{})
+	require.NoError(t, err)
+	repo, err := newReposStore(s.db).Create(ctx,
+		alice.ID,
+		CreateRepoOptions{
+			Name: "example",
+		},
+	)
+	require.NoError(t, err)
+	repo.Owner = alice
+	return alice, repo
+}
+
+func commitStatusesCreate(t *testing.T, ctx context.Context, s *CommitStatusesStore) {
+	alice, repo := createCommitStatusTestRepo(t, ctx, s)
+
+	status, err := s.Create(ctx, alice, repo, testCommitStatusSHA, CreateCommitStatusOptions{
+		State:     CommitStatusSuccess,
+		TargetURL: "https://ci.example.com/builds/1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "default", status.Context)
+	assert.Equal(t, alice.ID, status.CreatorID)
+	assert.False(t, status.CreatedAt.IsZero())
+
+	_, err = s.Create(ctx, alice, repo, testCommitStatusSHA, CreateCommitStatusOptions{
+		State: "unknown",
+	})
+	assert.Error(t, err)
+}
+
+func commitStatusesListBySHA(t *testing.T, ctx context.Context, s *CommitStatusesStore) {
+	alice, repo := createCommitStatusTestRepo(t, ctx, s)
+
+	for _, state := range []CommitStatusState{CommitStatusPending, CommitStatusSuccess} {
+		_, err := s.Create(ctx, alice, repo, testCommitStatusSHA, CreateCommitStatusOptions{
+			State:   state,
+			Context: "ci/build",
+		})
+		require.NoError(t, err)
+	}
+	_, err := s.Create(ctx, alice, repo, "ca82a6dff817ec66f44342007202690a93763949", CreateCommitStatusOptions{
+		State: CommitStatusFailure,
+	})
+	require.NoError(t, err)
+
+	statuses, err := s.ListBySHA(ctx, repo.ID, testCommitStatusSHA)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, CommitStatusSuccess, statuses[0].State)
+	assert.Equal(t, CommitStatusPending, statuses[1].State)
+
+	statuses, err = s.ListBySHA(ctx, repo.ID+1, testCommitStatusSHA)
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+}
+
+func commitStatusesListLatestBySHA(t *testing.T, ctx context.Context, s *CommitStatusesStore) {
+	alice, repo := createCommitStatusTestRepo(t, ctx, s)
+
+	for _, opts := range []CreateCommitStatusOptions{
+		{State: CommitStatusPending, Context: "ci/test"},
+		{State: CommitStatusPending, Context: "ci/build"},
+		{State: CommitStatusFailure, Context: "ci/test"},
+		{State: CommitStatusSuccess, Context: "ci/build"},
+	} {
+		_, err := s.Create(ctx, alice, repo, testCommitStatusSHA, opts)
+		require.NoError(t, err)
+	}
+
+	statuses, err := s.ListLatestBySHA(ctx, repo.ID, testCommitStatusSHA)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "ci/build", statuses[0].Context)
+	assert.Equal(t, CommitStatusSuccess, statuses[0].State)
+	assert.Equal(t, "ci/test", statuses[1].Context)
+	assert.Equal(t, CommitStatusFailure, statuses[1].State)
+}
+
+func commitStatusesLoadCreators(t *testing.T, ctx context.Context, s *CommitStatusesStore) {
+	alice, repo := createCommitStatusTestRepo(t, ctx, s)
+
+	_, err := s.Create(ctx, alice, repo, testCommitStatusSHA, CreateCommitStatusOptions{
+		State: CommitStatusSuccess,
+	})
+	require.NoError(t, err)
+	err = s.db.Create(&CommitStatus{
+		RepoID:    repo.ID,
+		SHA:       testCommitStatusSHA,
+		State:     CommitStatusSuccess,
+		Context:   "deleted",
+		CreatorID: 404,
+	}).Error
+	require.NoError(t, err)
+
+	statuses, err := s.ListBySHA(ctx, repo.ID, testCommitStatusSHA)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+
+	err = s.LoadCreators(ctx, statuses)
+	require.NoError(t, err)
+	assert.Equal(t, NewGhostUser().Name, statuses[0].Creator.Name)
+	assert.Equal(t, alice.Name, statuses[1].Creator.Name)
+}
+
+func TestCombinedCommitStatusState(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []CommitStatusState
+		want   CommitStatusState
+	}{
+		{
+			name: "no status",
+			want: CommitStatusPending,
+		},
+		{
+			name:   "all success",
+			states: []CommitStatusState{CommitStatusSuccess, CommitStatusSuccess},
+			want:   CommitStatusSuccess,
+		},
+		{
+			name:   "some pending",
+			states: []CommitStatusState{CommitStatusSuccess, CommitStatusPending},
+			want:   CommitStatusPending,
+		},
+		{
+			name:   "some error",
+			states: []CommitStatusState{CommitStatusPending, CommitStatusError},
+			want:   CommitStatusFailure,
+		},
+		{
+			name:   "some failure",
+			states: []CommitStatusState{CommitStatusFailure, CommitStatusSuccess},
+			want:   CommitStatusFailure,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statuses := make([]*CommitStatus, len(test.states))
+			for i, state := range test.states {
+				statuses[i] = &CommitStatus{State: state}
+			}
+			assert.Equal(t, test.want, CombinedCommitStatusState(statuses))
+		})
+	}
+}
@@ -39,11 +39,15 @@ func newLogWriter() (logger.Writer, error) {
 //
 // ⚠️ WARNING: This list is meant to be read-only.
 var Tables = []any{
-	new(Access), new(AccessToken), new(Action),
+	new(Access), new(AccessToken), new(Action), new(AuditLog),
+	new(CommitStatus),
 	new(EmailAddress),
 	new(Follow),
-	new(LFSObject), new(LoginSource),
+	new(LFSLock), new(LFSObject), new(LoginFailure), new(LoginSession), new(LoginSource),
 	new(Notice),
+	new(OAuth2Application), new(OAuth2AuthorizationCode), new(OAuth2Grant),
+	new(PushMirror),
+	new(WebAuthnCredential),
 }
 
 // NewConnection returns a new database connection with the given logger.
@@ -146,6 +150,18 @@ func (db *DB) Actions() *ActionsStore {
 	return newActionsStore(db.db)
 }
 
+func (db *DB) AuditLogs() *AuditLogsStore {
+	return newAuditLogsStore(db.db)
+}
+
+func (db *DB) CommitStatuses() *CommitStatusesStore {
+	return newCommitStatusesStore(db.db)
+}
+
+func (db *DB) PushMirrors() *PushMirrorsStore {
+	return newPushMirrorsStore(db.db)
+}
+
 func (db *DB) LFS() *LFSStore {
 	return newLFSStore(db.db)
 }
@@ -154,6 +170,14 @@ func (db *DB) LFS() *LFSStore {
 // service start.
 var loadedLoginSourceFilesStore loginSourceFilesStore
 
+func (db *DB) LoginFailures() *LoginFailuresStore {
+	return newLoginFailuresStore(db.db)
+}
+
+func (db *DB) LoginSessions() *LoginSessionsStore {
+	return newLoginSessionsStore(db.db)
+}
+
 func (db *DB) LoginSources() *LoginSourcesStore {
 	return newLoginSourcesStore(db.db, loadedLoginSourceFilesStore)
 }
@@ -162,6 +186,10 @@ func (db *DB) Notices() *NoticesStore {
 	return newNoticesStore(db.db)
 }
 
+func (db *DB) OAuth2() *OAuth2Store {
+	return newOAuth2Store(db.db)
+}
+
 func (db *DB) Organizations() *OrganizationsStore {
 	return newOrganizationsStoreStore(db.db)
 }
@@ -185,3 +213,7 @@ func (db *DB) TwoFactors() *TwoFactorsStore {
 func (db *DB) Users() *UsersStore {
 	return newUsersStore(db.db)
 }
+
+func (db *DB) WebAuthnCredentials() *WebAuthnCredentialsStore {
+	return newWebAuthnCredentialsStore(db.db)
+}
@@ -0,0 +1,59 @@
+// Package databasetest provides helpers for testing code of other packages
+// that uses the database package.
+package databasetest
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/dbx"
+)
+
+// SetUp sets up a new SQLite database with all tables the same way as the
+// application does, for testing code that uses the global database handle or
+// the legacy XORM engine. It returns a connection to insert test fixtures, and
+// restores the global database handle after the test is completed.
+//
+// NOTE: The legacy XORM engine is not accessible outside the database package,
+// thus it is left pointing to the test database.
+func SetUp(t *testing.T) *gorm.DB {
+	beforeDatabase, beforeFile, beforeLog, beforeLogger := conf.Database, conf.File, conf.Log, logger.Default
+	beforeSQLite3, beforeMySQL, beforePostgreSQL := conf.UseSQLite3, conf.UseMySQL, conf.UsePostgreSQL
+	beforeHandle := database.Handle
+	t.Cleanup(func() {
+		conf.Database, conf.File, conf.Log, logger.Default = beforeDatabase, beforeFile, beforeLog, beforeLogger
+		conf.UseSQLite3, conf.UseMySQL, conf.UsePostgreSQL = beforeSQLite3, beforeMySQL, beforePostgreSQL
+		database.Handle = beforeHandle
+	})
+
+	dir := t.TempDir()
+	conf.Database = conf.DatabaseOpts{
+		Type: "sqlite3",
+		Path: filepath.Join(dir, "gogs.db"),
+	}
+	conf.UseMySQL, conf.UsePostgreSQL = false, false
+	conf.File = ini.Empty()
+	conf.File.Section("log").Key("ROOT_PATH").SetValue(filepath.Join(dir, "log"))
+	conf.InitLogging(true)
+
+	err := database.NewEngine()
+	require.NoError(t, err)
+
+	db, err := database.NewConnection(&dbx.Logger{Writer: io.Discard})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
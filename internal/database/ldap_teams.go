@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/auth/ldap"
+)
+
+// syncLDAPTeams synchronizes memberships of the user to the organization teams
+// that are mapped from LDAP groups of the login source: the user is added to
+// the teams mapped from the given groups, and removed from all other mapped
+// teams. Teams that are not mapped are left as is.
+func syncLDAPTeams(ctx context.Context, source *LoginSource, userID int64, groups []string) error {
+	mappings, err := source.LDAP().TeamMappings()
+	if err != nil {
+		return errors.Wrap(err, "get team mappings")
+	}
+
+	isMemberOf := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		isMemberOf[strings.ToLower(group)] = true
+	}
+
+	// A team may be mapped from multiple groups, and the user should be a member
+	// of the team when being a member of any of them.
+	type orgTeam struct{ org, team string }
+	var teams []orgTeam
+	wantMember := make(map[orgTeam]bool, len(mappings))
+	for _, m := range mappings {
+		t := orgTeam{org: strings.ToLower(m.Org), team: strings.ToLower(m.Team)}
+		if _, ok := wantMember[t]; !ok {
+			teams = append(teams, t)
+		}
+		wantMember[t] = wantMember[t] || isMemberOf[strings.ToLower(m.GroupDN)]
+	}
+
+	for _, t := range teams {
+		org, err := Handle.Users().GetByUsername(ctx, t.org)
+		if err != nil {
+			if IsErrUserNotExist(err) {
+				log.Warn("LDAP team sync: organization %q of login source %d does not exist", t.org, source.ID)
+				continue
+			}
+			return errors.Wrapf(err, "get organization %q", t.org)
+		} else if !org.IsOrganization() {
+			log.Warn("LDAP team sync: %q of login source %d is not an organization", t.org, source.ID)
+			continue
+		}
+
+		team, err := GetTeamOfOrgByName(org.ID, t.team)
+		if err != nil {
+			if IsErrTeamNotExist(err) {
+				log.Warn("LDAP team sync: team %q of organization %q of login source %d does not exist", t.team, t.org, source.ID)
+				continue
+			}
+			return errors.Wrapf(err, "get team %q of organization %q", t.team, t.org)
+		}
+
+		isMember := IsTeamMember(org.ID, team.ID, userID)
+		switch {
+		case wantMember[t] && !isMember:
+			if err = AddTeamMember(org.ID, team.ID, userID); err != nil {
+				return errors.Wrapf(err, "add user %d to team %q of organization %q", userID, t.team, t.org)
+			}
+			log.Trace("LDAP team sync: added user %d to team %q of organization %q", userID, t.team, t.org)
+		case !wantMember[t] && isMember:
+			if err = RemoveTeamMember(org.ID, team.ID, userID); err != nil {
+				if IsErrLastOrgOwner(err) {
+					log.Warn("LDAP team sync: user %d is the last owner of organization %q and cannot be removed", userID, t.org)
+					continue
+				}
+				return errors.Wrapf(err, "remove user %d from team %q of organization %q", userID, t.team, t.org)
+			}
+			log.Trace("LDAP team sync: removed user %d from team %q of organization %q", userID, t.team, t.org)
+		}
+	}
+	return nil
+}
+
+// SyncLDAPTeams synchronizes memberships of organization teams for all users of
+// activated LDAP login sources that have team synchronization enabled.
+func SyncLDAPTeams() {
+	if taskStatusTable.IsRunning(taskNameSyncLDAPTeams) {
+		return
+	}
+	taskStatusTable.Start(taskNameSyncLDAPTeams)
+	defer taskStatusTable.Stop(taskNameSyncLDAPTeams)
+
+	log.Trace("Doing: SyncLDAPTeams")
+
+	ctx := context.Background()
+	sources, err := Handle.LoginSources().List(ctx, ListLoginSourceOptions{OnlyActivated: true})
+	if err != nil {
+		log.Error("SyncLDAPTeams: list activated login sources: %v", err)
+		return
+	}
+	for _, source := range sources {
+		if !(source.IsLDAP() || source.IsDLDAP()) || !source.LDAP().TeamSyncEnabled {
+			continue
+		}
+		provider, ok := source.Provider.(*ldap.Provider)
+		if !ok {
+			continue
+		}
+
+		var users []*User
+		err = Handle.db.WithContext(ctx).Where("login_source = ?", source.ID).Find(&users).Error
+		if err != nil {
+			log.Error("SyncLDAPTeams: list users of login source %d: %v", source.ID, err)
+			continue
+		}
+
+		var synced int
+		for _, u := range users {
+			login := u.LoginName
+			if login == "" {
+				login = u.Name
+			}
+			groups, err := provider.SearchTeamGroups(login)
+			if err != nil {
+				// Users that cannot be found are skipped rather than removed from all
+				// teams, in case of a temporary failure of the directory.
+				log.Error("SyncLDAPTeams: search groups of user %q of login source %d: %v", u.Name, source.ID, err)
+				continue
+			}
+			if err = syncLDAPTeams(ctx, source, u.ID, groups); err != nil {
+				log.Error("SyncLDAPTeams: sync teams of user %q of login source %d: %v", u.Name, source.ID, err)
+				continue
+			}
+			synced++
+		}
+		log.Trace("Synchronized teams of %d users of login source %d", synced, source.ID)
+	}
+}
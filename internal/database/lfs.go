@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
@@ -85,3 +87,148 @@ func (s *LFSStore) GetObjectsByOIDs(ctx context.Context, repoID int64, oids ...l
 	}
 	return objects, nil
 }
+
+// LFSLock is a lock of a file path in a repository held by a user, see
+// https://github.com/git-lfs/git-lfs/blob/main/docs/api/locking.md.
+type LFSLock struct {
+	ID        int64     `gorm:"primaryKey"`
+	RepoID    int64     `gorm:"uniqueIndex:lfs_lock_repo_path_unique;not null"`
+	OwnerID   int64     `gorm:"index;not null"`
+	Path      string    `gorm:"type:VARCHAR(512);uniqueIndex:lfs_lock_repo_path_unique;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// CleanLFSLockPath returns the path relative to the repository root in its
+// canonical form, which is how paths of LFS locks are stored.
+func CleanLFSLockPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+}
+
+var _ errx.NotFound = (*ErrLFSLockNotExist)(nil)
+
+type ErrLFSLockNotExist struct {
+	args errx.Args
+}
+
+// IsErrLFSLockNotExist returns true if the underlying error has the type
+// ErrLFSLockNotExist.
+func IsErrLFSLockNotExist(err error) bool {
+	return errors.As(errors.Cause(err), &ErrLFSLockNotExist{})
+}
+
+func (err ErrLFSLockNotExist) Error() string {
+	return fmt.Sprintf("LFS lock does not exist: %v", err.args)
+}
+
+func (ErrLFSLockNotExist) NotFound() bool {
+	return true
+}
+
+type ErrLFSLockAlreadyExist struct {
+	args errx.Args
+}
+
+// IsErrLFSLockAlreadyExist returns true if the underlying error has the type
+// ErrLFSLockAlreadyExist.
+func IsErrLFSLockAlreadyExist(err error) bool {
+	return errors.As(errors.Cause(err), &ErrLFSLockAlreadyExist{})
+}
+
+func (err ErrLFSLockAlreadyExist) Error() string {
+	return fmt.Sprintf("LFS lock already exists: %v", err.args)
+}
+
+// CreateLock creates a new lock of the path in the repository held by the
+// owner. It returns ErrLFSLockAlreadyExist when the path is already locked.
+func (s *LFSStore) CreateLock(ctx context.Context, repoID, ownerID int64, path string) (*LFSLock, error) {
+	path = CleanLFSLockPath(path)
+	lock := &LFSLock{
+		RepoID:  repoID,
+		OwnerID: ownerID,
+		Path:    path,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("repo_id = ? AND path = ?", repoID, path).First(new(LFSLock)).Error
+		if err == nil {
+			return ErrLFSLockAlreadyExist{args: errx.Args{"repoID": repoID, "path": path}}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(lock).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// GetLockByID returns the lock with given ID in the repository. It returns
+// ErrLFSLockNotExist when not found.
+func (s *LFSStore) GetLockByID(ctx context.Context, repoID, id int64) (*LFSLock, error) {
+	lock := new(LFSLock)
+	err := s.db.WithContext(ctx).Where("repo_id = ? AND id = ?", repoID, id).First(lock).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLFSLockNotExist{args: errx.Args{"repoID": repoID, "id": id}}
+		}
+		return nil, err
+	}
+	return lock, nil
+}
+
+// GetLockByPath returns the lock of the path in the repository. It returns
+// ErrLFSLockNotExist when not found.
+func (s *LFSStore) GetLockByPath(ctx context.Context, repoID int64, path string) (*LFSLock, error) {
+	path = CleanLFSLockPath(path)
+	lock := new(LFSLock)
+	err := s.db.WithContext(ctx).Where("repo_id = ? AND path = ?", repoID, path).First(lock).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLFSLockNotExist{args: errx.Args{"repoID": repoID, "path": path}}
+		}
+		return nil, err
+	}
+	return lock, nil
+}
+
+type ListLFSLocksOptions struct {
+	// Only returns locks with this ID when set.
+	ID int64
+	// Only returns locks of this path when set.
+	Path string
+	// Only returns locks held by this user when set.
+	OwnerID int64
+	// Only returns locks whose ID is greater than or equal to this value.
+	Cursor int64
+	// The maximum number of locks to return, 0 means no limit.
+	Limit int
+}
+
+// ListLocks returns locks in the repository that satisfy given options in the
+// order of their IDs.
+func (s *LFSStore) ListLocks(ctx context.Context, repoID int64, opts ListLFSLocksOptions) ([]*LFSLock, error) {
+	query := s.db.WithContext(ctx).Where("repo_id = ?", repoID)
+	if opts.ID > 0 {
+		query = query.Where("id = ?", opts.ID)
+	}
+	if opts.Path != "" {
+		query = query.Where("path = ?", CleanLFSLockPath(opts.Path))
+	}
+	if opts.OwnerID > 0 {
+		query = query.Where("owner_id = ?", opts.OwnerID)
+	}
+	if opts.Cursor > 0 {
+		query = query.Where("id >= ?", opts.Cursor)
+	}
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+
+	locks := make([]*LFSLock, 0, opts.Limit)
+	return locks, query.Order("id ASC").Find(&locks).Error
+}
+
+// DeleteLockByID deletes the lock with given ID in the repository.
+func (s *LFSStore) DeleteLockByID(ctx context.Context, repoID, id int64) error {
+	return s.db.WithContext(ctx).Where("repo_id = ? AND id = ?", repoID, id).Delete(new(LFSLock)).Error
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/lfsx"
+	"gogs.io/gogs/internal/repox"
+	"gogs.io/gogs/internal/tool"
+)
+
+// LFSStoragers returns all available storage backends of LFS objects.
+func LFSStoragers() map[lfsx.Storage]lfsx.Storager {
+	storagers := map[lfsx.Storage]lfsx.Storager{
+		lfsx.StorageLocal: &lfsx.LocalStorage{Root: conf.LFS.ObjectsPath, TempDir: conf.LFS.ObjectsTempPath},
+	}
+	if conf.LFS.S3.Endpoint != "" {
+		storagers[lfsx.StorageS3] = &lfsx.S3Storage{
+			Endpoint:        conf.LFS.S3.Endpoint,
+			Region:          conf.LFS.S3.Region,
+			Bucket:          conf.LFS.S3.Bucket,
+			Prefix:          conf.LFS.S3.Prefix,
+			AccessKeyID:     conf.LFS.S3.AccessKeyID,
+			SecretAccessKey: conf.LFS.S3.SecretAccessKey,
+			UsePathStyle:    conf.LFS.S3.UsePathStyle,
+			PresignExpiry:   conf.LFS.S3.PresignExpiry,
+			TempDir:         conf.LFS.ObjectsTempPath,
+		}
+	}
+	return storagers
+}
+
+type CollectLFSGarbageOptions struct {
+	// Objects uploaded within the grace period are never collected, because
+	// they may be referenced by commits that are yet to be pushed.
+	GracePeriod time.Duration
+	// Whether to only report the garbage without deleting anything.
+	DryRun bool
+}
+
+// LFSGarbage is the summary of LFS objects that are no longer referenced.
+type LFSGarbage struct {
+	// The number of object records whose repositories no longer reference them.
+	Records int
+	// The number of objects in storage backends that no repository references.
+	Objects int
+	// The number of objects uploaded via presigned URLs that have never been
+	// verified, thus never committed to their repositories.
+	Uploads int
+	// The total size of Objects and Uploads in bytes, i.e. the reclaimable size.
+	Size int64
+}
+
+// CollectGarbage finds LFS objects that are no longer referenced, and deletes
+// them unless opts.DryRun is true. A repository references an object as long
+// as a pointer file reachable from any of its references points to it, and
+// objects in storage backends are deleted once no repository references them.
+func (s *LFSStore) CollectGarbage(ctx context.Context, storagers map[lfsx.Storage]lfsx.Storager, opts CollectLFSGarbageOptions) (*LFSGarbage, error) {
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	garbage := new(LFSGarbage)
+
+	var repoIDs []int64
+	err := s.db.WithContext(ctx).Model(&LFSObject{}).Distinct("repo_id").Order("repo_id").Pluck("repo_id", &repoIDs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "list repositories")
+	}
+
+	// Objects that are still referenced, by storage and OID.
+	referenced := make(map[lfsx.Storage]map[lfsx.OID]bool)
+	for _, repoID := range repoIDs {
+		var objects []*LFSObject
+		err = s.db.WithContext(ctx).Where("repo_id = ?", repoID).Find(&objects).Error
+		if err != nil {
+			return nil, errors.Wrapf(err, "list objects of repository %d", repoID)
+		}
+
+		pointed, err := s.scanRepositoryPointers(ctx, repoID)
+		if err != nil {
+			// Better to keep garbage than deleting objects that are still in use.
+			log.Warn("Failed to scan LFS pointers of repository %d, skipped: %v", repoID, err)
+		}
+
+		var unreferenced []lfsx.OID
+		for _, object := range objects {
+			if err != nil || pointed[object.OID] || object.CreatedAt.After(cutoff) {
+				if referenced[object.Storage] == nil {
+					referenced[object.Storage] = make(map[lfsx.OID]bool)
+				}
+				referenced[object.Storage][object.OID] = true
+				continue
+			}
+			unreferenced = append(unreferenced, object.OID)
+		}
+		if len(unreferenced) == 0 {
+			continue
+		}
+
+		garbage.Records += len(unreferenced)
+		if opts.DryRun {
+			continue
+		}
+		err = s.db.WithContext(ctx).Where("repo_id = ? AND oid IN (?)", repoID, unreferenced).Delete(&LFSObject{}).Error
+		if err != nil {
+			return nil, errors.Wrapf(err, "delete objects of repository %d", repoID)
+		}
+	}
+
+	storages := make([]lfsx.Storage, 0, len(storagers))
+	for storage := range storagers {
+		storages = append(storages, storage)
+	}
+	sort.Slice(storages, func(i, j int) bool { return storages[i] < storages[j] })
+	for _, storage := range storages {
+		var orphaned []lfsx.ObjectInfo
+		err = storagers[storage].Walk(func(info lfsx.ObjectInfo) error {
+			if !referenced[storage][info.OID] && info.ModTime.Before(cutoff) {
+				orphaned = append(orphaned, info)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "walk storage %q", storage)
+		}
+
+		for _, info := range orphaned {
+			if !opts.DryRun {
+				// The same object may have been uploaded again after the scan.
+				var count int64
+				err = s.db.WithContext(ctx).Model(&LFSObject{}).Where("oid = ? AND storage = ?", info.OID, storage).Count(&count).Error
+				if err != nil {
+					return nil, errors.Wrap(err, "count objects")
+				} else if count > 0 {
+					continue
+				}
+
+				if err = storagers[storage].Delete(info.OID); err != nil {
+					return nil, errors.Wrapf(err, "delete object %q from storage %q", info.OID, storage)
+				}
+			}
+			garbage.Objects++
+			garbage.Size += info.Size
+		}
+
+		presigner, ok := storagers[storage].(lfsx.PresignStorager)
+		if !ok {
+			continue
+		}
+		err = presigner.WalkUploads(func(repoID int64, info lfsx.ObjectInfo) error {
+			if !info.ModTime.Before(cutoff) {
+				return nil
+			}
+
+			if !opts.DryRun {
+				if err := presigner.DeleteUpload(repoID, info.OID); err != nil {
+					return errors.Wrapf(err, "delete upload %q of repository %d", info.OID, repoID)
+				}
+			}
+			garbage.Uploads++
+			garbage.Size += info.Size
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "walk uploads of storage %q", storage)
+		}
+	}
+	return garbage, nil
+}
+
+// scanRepositoryPointers returns the set of OIDs that pointer files of the
+// repository point to. It returns an empty set if the repository no longer
+// exists.
+func (s *LFSStore) scanRepositoryPointers(ctx context.Context, repoID int64) (map[lfsx.OID]bool, error) {
+	repo, err := newReposStore(s.db).GetByID(ctx, repoID)
+	if err != nil {
+		if IsErrRepoNotExist(err) {
+			return map[lfsx.OID]bool{}, nil
+		}
+		return nil, errors.Wrap(err, "get repository")
+	}
+	owner, err := newUsersStore(s.db).GetByID(ctx, repo.OwnerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get owner")
+	}
+
+	pointers, err := lfsx.ScanPointers(ctx, repox.RepositoryPath(owner.Name, repo.Name))
+	if err != nil {
+		return nil, errors.Wrap(err, "scan pointers")
+	}
+	pointed := make(map[lfsx.OID]bool, len(pointers))
+	for _, p := range pointers {
+		pointed[p.OID] = true
+	}
+	return pointed, nil
+}
+
+// DeleteOrphanedLFSObjects deletes LFS objects that are no longer referenced
+// and have been uploaded before the configured grace period.
+func DeleteOrphanedLFSObjects() {
+	if taskStatusTable.IsRunning(taskNameLFSGarbageCollection) {
+		return
+	}
+	taskStatusTable.Start(taskNameLFSGarbageCollection)
+	defer taskStatusTable.Stop(taskNameLFSGarbageCollection)
+
+	log.Trace("Doing: DeleteOrphanedLFSObjects")
+
+	garbage, err := Handle.LFS().CollectGarbage(context.Background(), LFSStoragers(),
+		CollectLFSGarbageOptions{
+			GracePeriod: conf.Cron.LFSGarbageCollection.GracePeriod,
+		},
+	)
+	if err != nil {
+		log.Error("DeleteOrphanedLFSObjects: %v", err)
+		return
+	}
+	log.Info("Deleted %d unreferenced LFS object records, %d orphaned LFS objects, and %d unverified LFS uploads of %s",
+		garbage.Records, garbage.Objects, garbage.Uploads, tool.FileSize(garbage.Size))
+}
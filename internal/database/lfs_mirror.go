@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/lfsx"
+	"gogs.io/gogs/internal/netx"
+)
+
+// LFSFetchResult is the summary of fetching LFS objects from a remote
+// repository.
+type LFSFetchResult struct {
+	// The number of objects that have been fetched.
+	Fetched int
+	// The number of objects that are still missing.
+	Missing int
+}
+
+// FetchObjects fetches objects that pointer files of the repository point to
+// but are not yet stored for the repository from the remote LFS server, then
+// stores them with the storager and records them for the repository. Failing
+// to fetch some of the objects does not stop fetching the others, and the
+// result is always returned along with the error.
+func (s *LFSStore) FetchObjects(ctx context.Context, repoID int64, repoPath string, client *lfsx.Client, storager lfsx.Storager) (*LFSFetchResult, error) {
+	result := new(LFSFetchResult)
+	pointers, err := lfsx.ScanPointers(ctx, repoPath)
+	if err != nil {
+		return result, errors.Wrap(err, "scan pointers")
+	}
+
+	var stored []lfsx.OID
+	err = s.db.WithContext(ctx).Model(&LFSObject{}).Where("repo_id = ?", repoID).Pluck("oid", &stored).Error
+	if err != nil {
+		return result, errors.Wrap(err, "list objects")
+	}
+	isStored := make(map[lfsx.OID]bool, len(stored))
+	for _, oid := range stored {
+		isStored[oid] = true
+	}
+
+	missing := make([]lfsx.Pointer, 0, len(pointers))
+	for _, p := range pointers {
+		if !isStored[p.OID] {
+			missing = append(missing, p)
+		}
+	}
+	result.Missing = len(missing)
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	objects, err := client.BatchDownload(ctx, missing)
+	if err != nil {
+		return result, errors.Wrap(err, "batch")
+	}
+
+	var firstErr error
+	for _, object := range objects {
+		err = s.fetchObject(ctx, repoID, client, storager, object)
+		if err != nil {
+			log.Trace("Failed to fetch LFS object %q of repository %d: %v", object.OID, repoID, err)
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "fetch object %q", object.OID)
+			}
+			continue
+		}
+		result.Fetched++
+		result.Missing--
+	}
+	if firstErr != nil {
+		return result, errors.Wrapf(firstErr, "%d of %d objects are missing", result.Missing, len(missing))
+	}
+	return result, nil
+}
+
+func (s *LFSStore) fetchObject(ctx context.Context, repoID int64, client *lfsx.Client, storager lfsx.Storager, object lfsx.BatchObject) error {
+	if object.Error != nil {
+		return errors.Newf("%d %s", object.Error.Code, object.Error.Message)
+	} else if !lfsx.ValidOID(object.OID) {
+		return lfsx.ErrInvalidOID
+	}
+	action, ok := object.Actions["download"]
+	if !ok {
+		return errors.New("no download action")
+	}
+
+	rc, err := client.Download(ctx, action)
+	if err != nil {
+		return err
+	}
+	// The storager verifies the content matches the OID, so the remote server
+	// can't make us store anything but the object we asked for.
+	written, err := storager.Upload(object.OID, rc)
+	if err != nil {
+		return errors.Wrap(err, "upload")
+	}
+	return s.CreateObject(ctx, repoID, object.OID, written, storager.Storage())
+}
+
+// newMirrorLFSClient returns a client of the LFS server of the remote
+// repository. It refuses to connect to local network addresses that are
+// implicitly blocked and to follow redirects, matching the policy of Git
+// operations against mirror sources.
+func newMirrorLFSClient(remoteAddr string) (*lfsx.Client, error) {
+	endpoint, err := lfsx.RemoteEndpoint(remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		} else if netx.IsBlockedLocalHostname(host, conf.Security.LocalNetworkAllowlist) {
+			return nil, errors.Newf("%q resolves to a local network address that is implicitly blocked", host)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return &lfsx.Client{
+		Endpoint: endpoint,
+		HTTPClient: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, _ []*http.Request) error {
+				return errors.Newf("refusing to follow redirect to %q", req.URL.Redacted())
+			},
+		},
+	}, nil
+}
+
+// fetchMirrorLFSObjects fetches LFS objects of the repository from the remote
+// repository, and stores them with the default storage backend.
+func fetchMirrorLFSObjects(ctx context.Context, repo *Repository, remoteAddr string) (*LFSFetchResult, error) {
+	client, err := newMirrorLFSClient(remoteAddr)
+	if err != nil {
+		return new(LFSFetchResult), err
+	}
+
+	storager, ok := LFSStoragers()[lfsx.Storage(conf.LFS.Storage)]
+	if !ok {
+		return new(LFSFetchResult), errors.Newf("storage backend %q is not available", conf.LFS.Storage)
+	}
+	return Handle.LFS().FetchObjects(ctx, repo.ID, repo.RepoPath(), client, storager)
+}
@@ -1,15 +1,28 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/errx"
 	"gogs.io/gogs/internal/lfsx"
+	"gogs.io/gogs/internal/repox"
 )
 
 func TestLFS(t *testing.T) {
@@ -30,6 +43,13 @@ func TestLFS(t *testing.T) {
 		{"CreateObject", lfsCreateObject},
 		{"GetObjectByOID", lfsGetObjectByOID},
 		{"GetObjectsByOIDs", lfsGetObjectsByOIDs},
+		{"CreateLock", lfsCreateLock},
+		{"GetLock", lfsGetLock},
+		{"ListLocks", lfsListLocks},
+		{"DeleteLockByID", lfsDeleteLockByID},
+		{"CollectGarbage", lfsCollectGarbage},
+		{"CollectGarbageUploads", lfsCollectGarbageUploads},
+		{"FetchObjects", lfsFetchObjects},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Cleanup(func() {
@@ -99,3 +119,405 @@ func lfsGetObjectsByOIDs(t *testing.T, ctx context.Context, s *LFSStore) {
 	assert.Equal(t, repoID, objects[1].RepoID)
 	assert.Equal(t, oid2, objects[1].OID)
 }
+
+func lfsCreateLock(t *testing.T, ctx context.Context, s *LFSStore) {
+	repoID := int64(1)
+	lock, err := s.CreateLock(ctx, repoID, 2, "/images/../images/logo.png")
+	require.NoError(t, err)
+	assert.Equal(t, "images/logo.png", lock.Path)
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), lock.CreatedAt.UTC().Format(time.RFC3339))
+
+	// Try to lock the same path again should fail, regardless of the owner
+	_, err = s.CreateLock(ctx, repoID, 3, "images/logo.png")
+	wantErr := ErrLFSLockAlreadyExist{args: errx.Args{"repoID": repoID, "path": "images/logo.png"}}
+	assert.Equal(t, wantErr, err)
+
+	// The same path in another repository can be locked
+	_, err = s.CreateLock(ctx, 2, 3, "images/logo.png")
+	require.NoError(t, err)
+}
+
+func lfsGetLock(t *testing.T, ctx context.Context, s *LFSStore) {
+	repoID := int64(1)
+	lock, err := s.CreateLock(ctx, repoID, 2, "images/logo.png")
+	require.NoError(t, err)
+
+	got, err := s.GetLockByID(ctx, repoID, lock.ID)
+	require.NoError(t, err)
+	assert.Equal(t, lock.Path, got.Path)
+
+	got, err = s.GetLockByPath(ctx, repoID, "./images/logo.png")
+	require.NoError(t, err)
+	assert.Equal(t, lock.ID, got.ID)
+
+	// Locks of other repositories should not be visible
+	_, err = s.GetLockByID(ctx, 2, lock.ID)
+	wantErr := ErrLFSLockNotExist{args: errx.Args{"repoID": int64(2), "id": lock.ID}}
+	assert.Equal(t, wantErr, err)
+
+	_, err = s.GetLockByPath(ctx, repoID, "images/404.png")
+	wantErr = ErrLFSLockNotExist{args: errx.Args{"repoID": repoID, "path": "images/404.png"}}
+	assert.Equal(t, wantErr, err)
+}
+
+func lfsListLocks(t *testing.T, ctx context.Context, s *LFSStore) {
+	repoID := int64(1)
+	lock1, err := s.CreateLock(ctx, repoID, 2, "a.png")
+	require.NoError(t, err)
+	lock2, err := s.CreateLock(ctx, repoID, 3, "b.png")
+	require.NoError(t, err)
+	lock3, err := s.CreateLock(ctx, repoID, 2, "c.png")
+	require.NoError(t, err)
+	_, err = s.CreateLock(ctx, 2, 2, "d.png")
+	require.NoError(t, err)
+
+	lockIDs := func(locks []*LFSLock) []int64 {
+		ids := make([]int64, 0, len(locks))
+		for _, lock := range locks {
+			ids = append(ids, lock.ID)
+		}
+		return ids
+	}
+
+	locks, err := s.ListLocks(ctx, repoID, ListLFSLocksOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{lock1.ID, lock2.ID, lock3.ID}, lockIDs(locks))
+
+	locks, err = s.ListLocks(ctx, repoID, ListLFSLocksOptions{ID: lock2.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{lock2.ID}, lockIDs(locks))
+
+	locks, err = s.ListLocks(ctx, repoID, ListLFSLocksOptions{Path: "/c.png"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{lock3.ID}, lockIDs(locks))
+
+	locks, err = s.ListLocks(ctx, repoID, ListLFSLocksOptions{OwnerID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{lock1.ID, lock3.ID}, lockIDs(locks))
+
+	locks, err = s.ListLocks(ctx, repoID, ListLFSLocksOptions{Cursor: lock2.ID, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{lock2.ID}, lockIDs(locks))
+}
+
+func lfsDeleteLockByID(t *testing.T, ctx context.Context, s *LFSStore) {
+	repoID := int64(1)
+	lock, err := s.CreateLock(ctx, repoID, 2, "images/logo.png")
+	require.NoError(t, err)
+
+	// Deleting a lock of another repository should be a no-op
+	err = s.DeleteLockByID(ctx, 2, lock.ID)
+	require.NoError(t, err)
+	_, err = s.GetLockByID(ctx, repoID, lock.ID)
+	require.NoError(t, err)
+
+	err = s.DeleteLockByID(ctx, repoID, lock.ID)
+	require.NoError(t, err)
+	_, err = s.GetLockByID(ctx, repoID, lock.ID)
+	assert.True(t, IsErrLFSLockNotExist(err))
+
+	// The path can be locked again
+	_, err = s.CreateLock(ctx, repoID, 3, "images/logo.png")
+	require.NoError(t, err)
+}
+
+func lfsCollectGarbage(t *testing.T, ctx context.Context, s *LFSStore) {
+	conf.SetMockRepository(t, conf.RepositoryOpts{
+		Root: filepath.Join(t.TempDir(), "repositories"),
+	})
+
+	alice, err := newUsersStore(s.db).Create(ctx, "alice", "alice@example.com", CreateUserOptions{})
+	require.NoError(t, err)
+	repo, err := newReposStore(s.db).Create(ctx, alice.ID, CreateRepoOptions{Name: "example"})
+	require.NoError(t, err)
+
+	const (
+		referencedOID   = lfsx.OID("185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969") // "Hello"
+		unreferencedOID = lfsx.OID("c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a") // "Hello world!"
+		orphanedOID     = lfsx.OID("43f497ee7ac09843d631362ef9aca26a0cab437acaea8a98e44afa7ad65a2d41") // "Hello world?"
+		recentOID       = lfsx.OID("6b72e31103c793987886dfe55cd08a8101a4201bc2ecfdf817a8812c61dab403") // "Gogs"
+	)
+
+	// Commit a pointer file of the referenced object to the repository.
+	repoPath := repox.RepositoryPath(alice.Name, repo.Name)
+	output, err := exec.Command("git", "init", "--quiet", repoPath).CombinedOutput()
+	require.NoError(t, err, string(output))
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:" + string(referencedOID) + "\nsize 5\n"
+	err = os.WriteFile(filepath.Join(repoPath, "hello.txt"), []byte(pointer), 0o644)
+	require.NoError(t, err)
+	for _, args := range [][]string{
+		{"add", "hello.txt"},
+		{"-c", "user.name=alice", "-c", "user.email=alice@example.com", "commit", "--quiet", "-m", "add hello"},
+	} {
+		output, err = exec.Command("git", append([]string{"-C", repoPath}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	storage := &lfsx.LocalStorage{
+		Root:    filepath.Join(t.TempDir(), "lfs-objects"),
+		TempDir: filepath.Join(t.TempDir(), "tmp"),
+	}
+	storagers := map[lfsx.Storage]lfsx.Storager{storage.Storage(): storage}
+	oldTime := time.Now().Add(-2 * time.Hour)
+	for oid, content := range map[lfsx.OID]string{
+		referencedOID:   "Hello",
+		unreferencedOID: "Hello world!",
+		orphanedOID:     "Hello world?",
+		recentOID:       "Gogs",
+	} {
+		_, err = storage.Upload(oid, io.NopCloser(strings.NewReader(content)))
+		require.NoError(t, err)
+		if oid != recentOID {
+			fpath := filepath.Join(storage.Root, string(oid[0]), string(oid[1]), string(oid))
+			require.NoError(t, os.Chtimes(fpath, oldTime, oldTime))
+		}
+	}
+
+	// The repository with ID 404 no longer exists.
+	for _, object := range []struct {
+		repoID int64
+		oid    lfsx.OID
+		size   int64
+	}{
+		{repo.ID, referencedOID, 5},
+		{repo.ID, unreferencedOID, 12},
+		{repo.ID, recentOID, 4},
+		{404, referencedOID, 5},
+	} {
+		err = s.CreateObject(ctx, object.repoID, object.oid, object.size, lfsx.StorageLocal)
+		require.NoError(t, err)
+	}
+	err = s.db.Model(&LFSObject{}).Where("oid != ?", recentOID).Update("created_at", oldTime).Error
+	require.NoError(t, err)
+
+	opts := CollectLFSGarbageOptions{
+		GracePeriod: time.Hour,
+		DryRun:      true,
+	}
+	want := &LFSGarbage{
+		Records: 2,
+		Objects: 2,
+		Size:    24,
+	}
+	garbage, err := s.CollectGarbage(ctx, storagers, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, garbage)
+
+	// Nothing should be deleted in dry run
+	var count int64
+	err = s.db.Model(&LFSObject{}).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+	var buf bytes.Buffer
+	err = storage.Download(orphanedOID, &buf)
+	require.NoError(t, err)
+
+	opts.DryRun = false
+	garbage, err = s.CollectGarbage(ctx, storagers, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, garbage)
+
+	_, err = s.GetObjectByOID(ctx, repo.ID, unreferencedOID)
+	assert.True(t, IsErrLFSObjectNotExist(err))
+	_, err = s.GetObjectByOID(ctx, 404, referencedOID)
+	assert.True(t, IsErrLFSObjectNotExist(err))
+	for oid, exists := range map[lfsx.OID]bool{
+		referencedOID:   true,
+		unreferencedOID: false,
+		orphanedOID:     false,
+		recentOID:       true,
+	} {
+		err = storage.Download(oid, io.Discard)
+		if exists {
+			assert.NoError(t, err, oid)
+		} else {
+			assert.Equal(t, lfsx.ErrObjectNotExist, err, oid)
+		}
+	}
+
+	// Nothing left to collect
+	garbage, err = s.CollectGarbage(ctx, storagers, opts)
+	require.NoError(t, err)
+	assert.Equal(t, &LFSGarbage{}, garbage)
+}
+
+var _ lfsx.PresignStorager = (*presignStorage)(nil)
+
+// presignStorage is a LocalStorage that keeps objects uploaded via presigned
+// URLs in memory.
+type presignStorage struct {
+	lfsx.LocalStorage
+	uploads map[int64]map[lfsx.OID]lfsx.ObjectInfo
+}
+
+func (*presignStorage) PresignUpload(int64, lfsx.OID) (string, map[string]string, error) {
+	return "", nil, nil
+}
+
+func (*presignStorage) CommitUpload(int64, lfsx.OID) (int64, error) {
+	return 0, lfsx.ErrObjectNotExist
+}
+
+func (*presignStorage) PresignDownload(lfsx.OID) (string, error) {
+	return "", nil
+}
+
+func (s *presignStorage) WalkUploads(fn func(repoID int64, info lfsx.ObjectInfo) error) error {
+	for repoID, uploads := range s.uploads {
+		for _, info := range uploads {
+			if err := fn(repoID, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *presignStorage) DeleteUpload(repoID int64, oid lfsx.OID) error {
+	delete(s.uploads[repoID], oid)
+	return nil
+}
+
+func lfsCollectGarbageUploads(t *testing.T, ctx context.Context, s *LFSStore) {
+	const (
+		staleOID  = lfsx.OID("185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969") // "Hello"
+		recentOID = lfsx.OID("6b72e31103c793987886dfe55cd08a8101a4201bc2ecfdf817a8812c61dab403") // "Gogs"
+	)
+	storage := &presignStorage{
+		LocalStorage: lfsx.LocalStorage{
+			Root:    filepath.Join(t.TempDir(), "lfs-objects"),
+			TempDir: filepath.Join(t.TempDir(), "tmp"),
+		},
+		uploads: map[int64]map[lfsx.OID]lfsx.ObjectInfo{
+			1: {
+				staleOID:  {OID: staleOID, Size: 5, ModTime: time.Now().Add(-2 * time.Hour)},
+				recentOID: {OID: recentOID, Size: 4, ModTime: time.Now()},
+			},
+		},
+	}
+	storagers := map[lfsx.Storage]lfsx.Storager{storage.Storage(): storage}
+
+	opts := CollectLFSGarbageOptions{
+		GracePeriod: time.Hour,
+		DryRun:      true,
+	}
+	want := &LFSGarbage{
+		Uploads: 1,
+		Size:    5,
+	}
+	garbage, err := s.CollectGarbage(ctx, storagers, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, garbage)
+	assert.Len(t, storage.uploads[1], 2)
+
+	opts.DryRun = false
+	garbage, err = s.CollectGarbage(ctx, storagers, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, garbage)
+	assert.Equal(t, []lfsx.OID{recentOID}, func() []lfsx.OID {
+		var oids []lfsx.OID
+		for oid := range storage.uploads[1] {
+			oids = append(oids, oid)
+		}
+		return oids
+	}())
+}
+
+func lfsFetchObjects(t *testing.T, ctx context.Context, s *LFSStore) {
+	const (
+		storedOID   = lfsx.OID("185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969") // "Hello"
+		fetchedOID  = lfsx.OID("c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a") // "Hello world!"
+		upstreamOID = lfsx.OID("6b72e31103c793987886dfe55cd08a8101a4201bc2ecfdf817a8812c61dab403") // "Gogs"
+	)
+
+	// Commit pointer files of all objects to the repository.
+	repoPath := filepath.Join(t.TempDir(), "example.git")
+	output, err := exec.Command("git", "init", "--quiet", repoPath).CombinedOutput()
+	require.NoError(t, err, string(output))
+	for name, p := range map[string]lfsx.Pointer{
+		"stored.txt":   {OID: storedOID, Size: 5},
+		"fetched.txt":  {OID: fetchedOID, Size: 12},
+		"upstream.txt": {OID: upstreamOID, Size: 4},
+	} {
+		pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", p.OID, p.Size)
+		err = os.WriteFile(filepath.Join(repoPath, name), []byte(pointer), 0o644)
+		require.NoError(t, err)
+	}
+	for _, args := range [][]string{
+		{"add", "."},
+		{"-c", "user.name=alice", "-c", "user.email=alice@example.com", "commit", "--quiet", "-m", "add pointers"},
+	} {
+		output, err = exec.Command("git", append([]string{"-C", repoPath}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	const repoID = 1
+	err = s.CreateObject(ctx, repoID, storedOID, 5, lfsx.StorageLocal)
+	require.NoError(t, err)
+
+	// The upstream is missing one of the objects at first.
+	contents := map[lfsx.OID]string{
+		storedOID:  "Hello",
+		fetchedOID: "Hello world!",
+	}
+	var requested []lfsx.OID
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, contents[lfsx.OID(path.Base(r.URL.Path))])
+			return
+		}
+
+		var req struct {
+			Objects []lfsx.BatchObject `json:"objects"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		for i, object := range req.Objects {
+			requested = append(requested, object.OID)
+			if _, ok := contents[object.OID]; ok {
+				req.Objects[i].Actions = map[string]lfsx.BatchAction{
+					"download": {Href: "http://" + r.Host + "/objects/" + string(object.OID)},
+				}
+			} else {
+				req.Objects[i].Error = &lfsx.BatchError{Code: http.StatusNotFound, Message: "Object does not exist"}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(req)
+	}))
+	defer server.Close()
+
+	storage := &lfsx.LocalStorage{
+		Root:    filepath.Join(t.TempDir(), "lfs-objects"),
+		TempDir: filepath.Join(t.TempDir(), "tmp"),
+	}
+	client := &lfsx.Client{Endpoint: server.URL + "/owner/example.git/info/lfs"}
+	result, err := s.FetchObjects(ctx, repoID, repoPath, client, storage)
+	assert.ErrorContains(t, err, "1 of 2 objects are missing")
+	assert.Equal(t, &LFSFetchResult{Fetched: 1, Missing: 1}, result)
+	assert.ElementsMatch(t, []lfsx.OID{fetchedOID, upstreamOID}, requested)
+
+	object, err := s.GetObjectByOID(ctx, repoID, fetchedOID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), object.Size)
+	assert.Equal(t, lfsx.StorageLocal, object.Storage)
+	var buf bytes.Buffer
+	require.NoError(t, storage.Download(fetchedOID, &buf))
+	assert.Equal(t, "Hello world!", buf.String())
+
+	// Only objects that are still missing are requested on the next fetch.
+	requested = nil
+	contents[upstreamOID] = "Gogs"
+	result, err = s.FetchObjects(ctx, repoID, repoPath, client, storage)
+	require.NoError(t, err)
+	assert.Equal(t, &LFSFetchResult{Fetched: 1, Missing: 0}, result)
+	assert.Equal(t, []lfsx.OID{upstreamOID}, requested)
+
+	// Content that does not match the OID is never stored.
+	err = s.db.Where("oid = ?", upstreamOID).Delete(&LFSObject{}).Error
+	require.NoError(t, err)
+	contents[upstreamOID] = "Not Gogs"
+	result, err = s.FetchObjects(ctx, repoID, repoPath, client, storage)
+	assert.ErrorIs(t, err, lfsx.ErrOIDMismatch)
+	assert.Equal(t, &LFSFetchResult{Fetched: 0, Missing: 1}, result)
+	_, err = s.GetObjectByOID(ctx, repoID, upstreamOID)
+	assert.True(t, IsErrLFSObjectNotExist(err))
+}
@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gogs.io/gogs/internal/errx"
+)
+
+// LoginFailureKind is the kind of the key that failed password attempts are
+// counted against.
+type LoginFailureKind string
+
+const (
+	// LoginFailureAccount counts failed attempts against the login name, whether
+	// or not the user exists.
+	LoginFailureAccount LoginFailureKind = "account"
+	// LoginFailureIP counts failed attempts against the client IP address.
+	LoginFailureIP LoginFailureKind = "ip"
+)
+
+// LoginFailure is the counter of consecutive failed password attempts of an
+// account or from an IP address.
+type LoginFailure struct {
+	ID   int64            `gorm:"primaryKey"`
+	Kind LoginFailureKind `gorm:"type:VARCHAR(16);uniqueIndex:login_failure_kind_name_unique;not null"`
+	// The lowercased login name or the IP address.
+	Name     string `gorm:"uniqueIndex:login_failure_kind_name_unique;not null"`
+	Failures int    `gorm:"not null"`
+
+	LastFailed      time.Time `gorm:"-" json:"-"`
+	LastFailedUnix  int64     `gorm:"index"`
+	LockedUntil     time.Time `gorm:"-" json:"-"`
+	LockedUntilUnix int64     `gorm:"index"`
+}
+
+// AfterFind implements the GORM query hook.
+func (f *LoginFailure) AfterFind(_ *gorm.DB) error {
+	f.LastFailed = time.Unix(f.LastFailedUnix, 0).Local()
+	if f.LockedUntilUnix > 0 {
+		f.LockedUntil = time.Unix(f.LockedUntilUnix, 0).Local()
+	}
+	return nil
+}
+
+// LoginThrottleOptions contains the limits of failed password attempts.
+type LoginThrottleOptions struct {
+	// The maximum number of consecutive failed attempts of an account before it
+	// is locked out, zero means unlimited.
+	MaxFailuresPerAccount int
+	// The maximum number of consecutive failed attempts from an IP address before
+	// it is locked out, zero means unlimited.
+	MaxFailuresPerIP int
+	// The delay added for every consecutive failed attempt before the next
+	// attempt is accepted.
+	Delay time.Duration
+	// The duration of lockouts, which is also how long a counter is kept after
+	// the last failed attempt.
+	LockoutDuration time.Duration
+}
+
+// LoginFailuresStore is the storage layer for counters of failed password
+// attempts.
+type LoginFailuresStore struct {
+	db *gorm.DB
+}
+
+func newLoginFailuresStore(db *gorm.DB) *LoginFailuresStore {
+	return &LoginFailuresStore{db: db}
+}
+
+// loginFailureKeys returns the keys of counters that are enabled for the login
+// name and the IP address.
+func loginFailureKeys(opts LoginThrottleOptions, login, ip string) map[LoginFailureKind]string {
+	keys := make(map[LoginFailureKind]string, 2)
+	if opts.MaxFailuresPerAccount > 0 && login != "" {
+		keys[LoginFailureAccount] = strings.ToLower(login)
+	}
+	if opts.MaxFailuresPerIP > 0 && ip != "" {
+		keys[LoginFailureIP] = ip
+	}
+	return keys
+}
+
+// expired returns true if the counter is no longer locked out and has not been
+// increased for the lockout duration.
+func (f *LoginFailure) expired(opts LoginThrottleOptions, now time.Time) bool {
+	return f.LockedUntilUnix <= now.Unix() &&
+		f.LastFailedUnix+int64(opts.LockoutDuration/time.Second) <= now.Unix()
+}
+
+// wait returns how long the next attempt has to wait for.
+func (f *LoginFailure) wait(opts LoginThrottleOptions, now time.Time) time.Duration {
+	if f.expired(opts, now) {
+		return 0
+	}
+	if f.LockedUntilUnix > now.Unix() {
+		return time.Unix(f.LockedUntilUnix, 0).Sub(now)
+	}
+	delay := min(time.Duration(f.Failures)*opts.Delay, opts.LockoutDuration)
+	return max(time.Unix(f.LastFailedUnix, 0).Add(delay).Sub(now), 0)
+}
+
+// maxFailures returns the maximum number of consecutive failed attempts of the
+// kind of counters.
+func (opts LoginThrottleOptions) maxFailures(kind LoginFailureKind) int {
+	if kind == LoginFailureIP {
+		return opts.MaxFailuresPerIP
+	}
+	return opts.MaxFailuresPerAccount
+}
+
+// Check returns how long the client has to wait before the next password
+// attempt of the login name from the IP address is accepted, or zero if it
+// is accepted now.
+func (s *LoginFailuresStore) Check(ctx context.Context, opts LoginThrottleOptions, login, ip string) (time.Duration, error) {
+	now := s.db.NowFunc()
+	var wait time.Duration
+	for kind, name := range loginFailureKeys(opts, login, ip) {
+		var f LoginFailure
+		err := s.db.WithContext(ctx).Where("kind = ? AND name = ?", kind, name).First(&f).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return 0, errors.Wrapf(err, "get %s counter", kind)
+		}
+		wait = max(wait, f.wait(opts, now))
+	}
+	return wait, nil
+}
+
+// Reserve counts the password attempt of the login name from the IP address
+// as failed before it is made, so that concurrent attempts cannot get past the
+// limits, and locks out the account or the IP address once it reaches the
+// maximum number of consecutive failed attempts. It returns how long the client
+// has to wait instead, without counting the attempt, when the attempt is not
+// accepted now. Expired counters are deleted along the way.
+//
+// The attempt must be given back with Reset when it succeeds, or with Release
+// when it turns out not to be a failed password attempt.
+func (s *LoginFailuresStore) Reserve(ctx context.Context, opts LoginThrottleOptions, login, ip string) (time.Duration, error) {
+	keys := loginFailureKeys(opts, login, ip)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	now := s.db.NowFunc()
+	err := s.db.WithContext(ctx).
+		Where("locked_until_unix <= ? AND last_failed_unix <= ?", now.Unix(), now.Add(-opts.LockoutDuration).Unix()).
+		Delete(&LoginFailure{}).
+		Error
+	if err != nil {
+		return 0, errors.Wrap(err, "delete expired counters")
+	}
+
+	// Check all counters before counting the attempt against any of them.
+	wait, err := s.Check(ctx, opts, login, ip)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	reserved := make(map[LoginFailureKind]string, len(keys))
+	for kind, name := range keys {
+		wait, err = s.reserve(ctx, opts, now, kind, name)
+		if err != nil || wait > 0 {
+			// A concurrent attempt has got in first, give back what has been counted.
+			if releaseErr := s.release(ctx, reserved); releaseErr != nil && err == nil {
+				err = releaseErr
+			}
+			return wait, err
+		}
+		reserved[kind] = name
+	}
+	return 0, nil
+}
+
+// reserve counts the attempt against the counter, which is only updated when
+// it has not been changed by a concurrent attempt since it was read, and is
+// read again otherwise.
+func (s *LoginFailuresStore) reserve(ctx context.Context, opts LoginThrottleOptions, now time.Time, kind LoginFailureKind, name string) (time.Duration, error) {
+	db := s.db.WithContext(ctx)
+	maxFailures := opts.maxFailures(kind)
+	for {
+		var f LoginFailure
+		err := db.Where("kind = ? AND name = ?", kind, name).First(&f).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			f = LoginFailure{
+				Kind:           kind,
+				Name:           name,
+				Failures:       1,
+				LastFailedUnix: now.Unix(),
+			}
+			if f.Failures >= maxFailures {
+				f.LockedUntilUnix = now.Add(opts.LockoutDuration).Unix()
+			}
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&f)
+			if result.Error != nil {
+				return 0, errors.Wrapf(result.Error, "create %s counter", kind)
+			} else if result.RowsAffected > 0 {
+				return 0, nil
+			}
+			continue
+		} else if err != nil {
+			return 0, errors.Wrapf(err, "get %s counter", kind)
+		}
+
+		if wait := f.wait(opts, now); wait > 0 {
+			return wait, nil
+		}
+
+		// A counter that has finished its lockout starts over.
+		failures := f.Failures + 1
+		if f.LockedUntilUnix > 0 || f.expired(opts, now) {
+			failures = 1
+		}
+		var lockedUntilUnix int64
+		if failures >= maxFailures {
+			lockedUntilUnix = now.Add(opts.LockoutDuration).Unix()
+		}
+
+		result := db.Model(&LoginFailure{}).
+			Where("id = ? AND failures = ? AND last_failed_unix = ? AND locked_until_unix = ?",
+				f.ID, f.Failures, f.LastFailedUnix, f.LockedUntilUnix,
+			).
+			Updates(map[string]any{
+				"failures":          failures,
+				"last_failed_unix":  now.Unix(),
+				"locked_until_unix": lockedUntilUnix,
+			})
+		if result.Error != nil {
+			return 0, errors.Wrapf(result.Error, "update %s counter", kind)
+		} else if result.RowsAffected > 0 {
+			return 0, nil
+		}
+	}
+}
+
+// release gives back an attempt reserved against the counters of given keys.
+func (s *LoginFailuresStore) release(ctx context.Context, keys map[LoginFailureKind]string) error {
+	for kind, name := range keys {
+		err := s.db.WithContext(ctx).Model(&LoginFailure{}).
+			Where("kind = ? AND name = ? AND failures > 0", kind, name).
+			Updates(map[string]any{
+				"failures": gorm.Expr("failures - 1"),
+				// Attempts are not accepted during lockouts, thus the counter can only be
+				// locked out by reaching the maximum, which it no longer does.
+				"locked_until_unix": 0,
+			}).
+			Error
+		if err != nil {
+			return errors.Wrapf(err, "update %s counter", kind)
+		}
+	}
+	return nil
+}
+
+// Release gives back the attempt of the login name from the IP address that
+// has been reserved but is not a failed password attempt, e.g. the client is
+// authenticated with an access token instead.
+func (s *LoginFailuresStore) Release(ctx context.Context, opts LoginThrottleOptions, login, ip string) error {
+	return s.release(ctx, loginFailureKeys(opts, login, ip))
+}
+
+// Reset deletes the counter of the login name after a successful password
+// attempt, and gives back the attempt reserved against the IP address. The
+// counter of the IP address is kept otherwise so that owning an account does
+// not help guessing passwords of other accounts.
+func (s *LoginFailuresStore) Reset(ctx context.Context, opts LoginThrottleOptions, login, ip string) error {
+	err := s.db.WithContext(ctx).
+		Where("kind = ? AND name = ?", LoginFailureAccount, strings.ToLower(login)).
+		Delete(&LoginFailure{}).
+		Error
+	if err != nil {
+		return errors.Wrap(err, "delete account counter")
+	}
+
+	keys := loginFailureKeys(opts, "", ip)
+	return s.release(ctx, keys)
+}
+
+// ListLocked returns the accounts and IP addresses that are currently locked
+// out, in descending order of the last failed attempt.
+func (s *LoginFailuresStore) ListLocked(ctx context.Context, page, pageSize int) ([]*LoginFailure, error) {
+	failures := make([]*LoginFailure, 0, pageSize)
+	return failures, s.db.WithContext(ctx).
+		Where("locked_until_unix > ?", s.db.NowFunc().Unix()).
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Order("last_failed_unix DESC, id DESC").
+		Find(&failures).
+		Error
+}
+
+// CountLocked returns the number of accounts and IP addresses that are
+// currently locked out.
+func (s *LoginFailuresStore) CountLocked(ctx context.Context) int64 {
+	var count int64
+	s.db.WithContext(ctx).Model(&LoginFailure{}).Where("locked_until_unix > ?", s.db.NowFunc().Unix()).Count(&count)
+	return count
+}
+
+var _ errx.NotFound = (*ErrLoginFailureNotExist)(nil)
+
+type ErrLoginFailureNotExist struct {
+	args errx.Args
+}
+
+func IsErrLoginFailureNotExist(err error) bool {
+	return errors.As(err, &ErrLoginFailureNotExist{})
+}
+
+func (err ErrLoginFailureNotExist) Error() string {
+	return fmt.Sprintf("login failure does not exist: %v", err.args)
+}
+
+func (ErrLoginFailureNotExist) NotFound() bool {
+	return true
+}
+
+// GetByID returns the counter with given ID. It returns
+// ErrLoginFailureNotExist when not found.
+func (s *LoginFailuresStore) GetByID(ctx context.Context, id int64) (*LoginFailure, error) {
+	var f LoginFailure
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&f).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoginFailureNotExist{args: errx.Args{"id": id}}
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DeleteByID deletes the counter with given ID, which clears the lockout of
+// the account or the IP address.
+func (s *LoginFailuresStore) DeleteByID(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Where("id = ?", id).Delete(&LoginFailure{}).Error
+}
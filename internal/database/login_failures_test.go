@@ -0,0 +1,324 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"gogs.io/gogs/internal/errx"
+)
+
+func TestLoginFailures(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &LoginFailuresStore{
+		db: newTestDB(t, "LoginFailuresStore"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *LoginFailuresStore)
+	}{
+		{"Check", loginFailuresCheck},
+		{"Reserve", loginFailuresReserve},
+		{"ReserveConcurrently", loginFailuresReserveConcurrently},
+		{"Release", loginFailuresRelease},
+		{"Reset", loginFailuresReset},
+		{"ListLocked", loginFailuresListLocked},
+		{"GetByID", loginFailuresGetByID},
+		{"DeleteByID", loginFailuresDeleteByID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+var testLoginThrottleOptions = LoginThrottleOptions{
+	MaxFailuresPerAccount: 3,
+	MaxFailuresPerIP:      5,
+	Delay:                 time.Second,
+	LockoutDuration:       15 * time.Minute,
+}
+
+// loginFailuresStoreAt returns a copy of the store whose current time is
+// shifted by the given duration.
+func loginFailuresStoreAt(s *LoginFailuresStore, shift time.Duration) *LoginFailuresStore {
+	now := s.db.NowFunc().Add(shift)
+	return &LoginFailuresStore{
+		db: s.db.Session(&gorm.Session{NowFunc: func() time.Time { return now }}),
+	}
+}
+
+func loginFailuresCheck(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+
+	wait, err := s.Check(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// Every consecutive failure adds to the delay.
+	for i := 1; i <= 2; i++ {
+		now := loginFailuresStoreAt(s, time.Duration(i-1)*time.Second)
+		wait, err = now.Reserve(ctx, opts, "Alice", "10.0.0.1")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+
+		wait, err = now.Check(ctx, opts, "alice", "10.0.0.2")
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(i)*time.Second, wait)
+	}
+	wait, err = loginFailuresStoreAt(s, 3*time.Second).Check(ctx, opts, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// The counter of the IP address applies to other accounts.
+	wait, err = loginFailuresStoreAt(s, time.Second).Check(ctx, opts, "bob", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, wait)
+
+	// The account is locked out after reaching the maximum failures.
+	wait, err = loginFailuresStoreAt(s, 3*time.Second).Reserve(ctx, opts, "alice", "10.0.0.3")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+	wait, err = loginFailuresStoreAt(s, 3*time.Second+5*time.Minute).Check(ctx, opts, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, wait)
+
+	wait, err = loginFailuresStoreAt(s, 3*time.Second+15*time.Minute).Check(ctx, opts, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// Disabled counters are not checked.
+	wait, err = s.Check(ctx, LoginThrottleOptions{LockoutDuration: opts.LockoutDuration}, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+// getLoginFailure returns the counter of given kind and name.
+func getLoginFailure(t *testing.T, s *LoginFailuresStore, kind LoginFailureKind, name string) *LoginFailure {
+	var f LoginFailure
+	err := s.db.Where("kind = ? AND name = ?", kind, name).First(&f).Error
+	require.NoError(t, err)
+	return &f
+}
+
+func loginFailuresReserve(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+
+	wait, err := s.Reserve(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// Attempts during the delay are refused without being counted.
+	wait, err = s.Reserve(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+	assert.Equal(t, 1, getLoginFailure(t, s, LoginFailureAccount, "alice").Failures)
+	assert.Equal(t, 1, getLoginFailure(t, s, LoginFailureIP, "10.0.0.1").Failures)
+
+	for _, shift := range []time.Duration{time.Second, 3 * time.Second} {
+		wait, err = loginFailuresStoreAt(s, shift).Reserve(ctx, opts, "alice", "10.0.0.1")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+	}
+	lockedUntilUnix := s.db.NowFunc().Add(3*time.Second + opts.LockoutDuration).Unix()
+	account := getLoginFailure(t, s, LoginFailureAccount, "alice")
+	assert.Equal(t, 3, account.Failures)
+	assert.Equal(t, lockedUntilUnix, account.LockedUntilUnix)
+	ip := getLoginFailure(t, s, LoginFailureIP, "10.0.0.1")
+	assert.Equal(t, 3, ip.Failures)
+	assert.Zero(t, ip.LockedUntilUnix)
+
+	// Attempts during the lockout are refused without being counted, and do not
+	// extend it.
+	wait, err = loginFailuresStoreAt(s, 3*time.Second+time.Minute).Reserve(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, opts.LockoutDuration-time.Minute, wait)
+	account = getLoginFailure(t, s, LoginFailureAccount, "alice")
+	assert.Equal(t, 3, account.Failures)
+	assert.Equal(t, lockedUntilUnix, account.LockedUntilUnix)
+	assert.Equal(t, 3, getLoginFailure(t, s, LoginFailureIP, "10.0.0.1").Failures)
+
+	// The counter starts over after the lockout, and expired counters are deleted.
+	wait, err = loginFailuresStoreAt(s, 3*time.Second+opts.LockoutDuration).Reserve(ctx, opts, "alice", "")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	account = getLoginFailure(t, s, LoginFailureAccount, "alice")
+	assert.Equal(t, 1, account.Failures)
+	assert.Zero(t, account.LockedUntilUnix)
+
+	var count int64
+	err = s.db.Model(&LoginFailure{}).Where("kind = ?", LoginFailureIP).Count(&count).Error
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func loginFailuresReserveConcurrently(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := LoginThrottleOptions{
+		MaxFailuresPerAccount: 3,
+		LockoutDuration:       15 * time.Minute,
+	}
+
+	// Concurrent attempts cannot get past the maximum failures.
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := s.Reserve(ctx, opts, "alice", "10.0.0.1")
+			assert.NoError(t, err)
+			if err == nil && wait <= 0 {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(3), accepted.Load())
+	account := getLoginFailure(t, s, LoginFailureAccount, "alice")
+	assert.Equal(t, 3, account.Failures)
+	assert.NotZero(t, account.LockedUntilUnix)
+}
+
+func loginFailuresRelease(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+
+	for _, shift := range []time.Duration{0, time.Second, 3 * time.Second} {
+		wait, err := loginFailuresStoreAt(s, shift).Reserve(ctx, opts, "alice", "10.0.0.1")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+	}
+	assert.NotZero(t, getLoginFailure(t, s, LoginFailureAccount, "alice").LockedUntilUnix)
+
+	// Giving back the attempt that has locked out the account also ends the lockout.
+	now := loginFailuresStoreAt(s, 3*time.Second)
+	err := now.Release(ctx, opts, "Alice", "10.0.0.1")
+	require.NoError(t, err)
+	account := getLoginFailure(t, s, LoginFailureAccount, "alice")
+	assert.Equal(t, 2, account.Failures)
+	assert.Zero(t, account.LockedUntilUnix)
+	assert.Equal(t, 2, getLoginFailure(t, s, LoginFailureIP, "10.0.0.1").Failures)
+
+	wait, err := now.Check(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, wait)
+}
+
+func loginFailuresReset(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+
+	wait, err := s.Reserve(ctx, opts, "bob", "10.0.0.1")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+	wait, err = loginFailuresStoreAt(s, time.Second).Reserve(ctx, opts, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+
+	err = s.Reset(ctx, opts, "ALICE", "10.0.0.1")
+	require.NoError(t, err)
+
+	// The counter of the account is deleted, while the counter of the IP address
+	// only gives back the successful attempt.
+	wait, err = s.Check(ctx, opts, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.Equal(t, 1, getLoginFailure(t, s, LoginFailureIP, "10.0.0.1").Failures)
+	assert.Equal(t, 1, getLoginFailure(t, s, LoginFailureAccount, "bob").Failures)
+}
+
+func loginFailuresListLocked(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+	opts.Delay = 0
+
+	for range 3 {
+		wait, err := s.Reserve(ctx, opts, "alice", "10.0.0.1")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+	}
+	for _, login := range []string{"bob", "bob", "bob", "carol", "carol"} {
+		wait, err := loginFailuresStoreAt(s, time.Minute).Reserve(ctx, opts, login, "10.0.0.2")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+	}
+
+	names := func(failures []*LoginFailure) []string {
+		names := make([]string, 0, len(failures))
+		for _, f := range failures {
+			names = append(names, string(f.Kind)+":"+f.Name)
+		}
+		return names
+	}
+
+	now := loginFailuresStoreAt(s, time.Minute)
+	failures, err := now.ListLocked(ctx, 1, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"account:bob", "ip:10.0.0.2", "account:alice"}, names(failures))
+	assert.Equal(t, int64(3), now.CountLocked(ctx))
+
+	// The lockout of alice has ended.
+	later := loginFailuresStoreAt(s, opts.LockoutDuration)
+	failures, err = later.ListLocked(ctx, 1, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"account:bob", "ip:10.0.0.2"}, names(failures))
+	assert.Equal(t, int64(2), later.CountLocked(ctx))
+
+	failures, err = later.ListLocked(ctx, 2, 1)
+	require.NoError(t, err)
+	assert.Len(t, failures, 1)
+}
+
+func loginFailuresGetByID(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	_, err := s.Reserve(ctx, testLoginThrottleOptions, "alice", "")
+	require.NoError(t, err)
+
+	var f LoginFailure
+	err = s.db.First(&f).Error
+	require.NoError(t, err)
+
+	got, err := s.GetByID(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Name)
+
+	_, err = s.GetByID(ctx, 404)
+	wantErr := ErrLoginFailureNotExist{args: errx.Args{"id": int64(404)}}
+	assert.Equal(t, wantErr, err)
+}
+
+func loginFailuresDeleteByID(t *testing.T, ctx context.Context, s *LoginFailuresStore) {
+	opts := testLoginThrottleOptions
+	opts.Delay = 0
+	for range 3 {
+		_, err := s.Reserve(ctx, opts, "alice", "")
+		require.NoError(t, err)
+	}
+
+	var f LoginFailure
+	err := s.db.First(&f).Error
+	require.NoError(t, err)
+
+	err = s.DeleteByID(ctx, f.ID)
+	require.NoError(t, err)
+
+	wait, err := s.Check(ctx, opts, "alice", "")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/cryptox"
+	"gogs.io/gogs/internal/errx"
+	"gogs.io/gogs/internal/strx"
+)
+
+// LoginSession is a signed-in browser session of a user, which is tracked so
+// that the user can see where they are signed in and revoke sessions.
+type LoginSession struct {
+	ID     int64 `gorm:"primaryKey"`
+	UserID int64 `gorm:"index;not null"`
+	// The SHA256 hash of the random key that is kept in the session data, which
+	// identifies the session regardless of the session provider.
+	KeySHA256 string `gorm:"type:VARCHAR(64);unique;not null"`
+	UserAgent string `gorm:"type:VARCHAR(512);not null"`
+	IPAddress string `gorm:"not null"`
+
+	Created      time.Time `gorm:"-" json:"-"`
+	CreatedUnix  int64
+	LastSeen     time.Time `gorm:"-" json:"-"`
+	LastSeenUnix int64     `gorm:"index"`
+}
+
+// BeforeCreate implements the GORM create hook.
+func (s *LoginSession) BeforeCreate(tx *gorm.DB) error {
+	if s.CreatedUnix == 0 {
+		s.CreatedUnix = tx.NowFunc().Unix()
+	}
+	if s.LastSeenUnix == 0 {
+		s.LastSeenUnix = s.CreatedUnix
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (s *LoginSession) AfterFind(_ *gorm.DB) error {
+	s.Created = time.Unix(s.CreatedUnix, 0).Local()
+	s.LastSeen = time.Unix(s.LastSeenUnix, 0).Local()
+	return nil
+}
+
+// Device returns a short description of the browser and the operating system
+// of the session, derived from the user agent.
+func (s *LoginSession) Device() string {
+	browser := ""
+	for _, b := range []struct{ token, name string }{
+		// Order matters as user agents mention the browsers they are based on.
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(s.UserAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(s.UserAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return strx.Ellipsis(s.UserAgent, 50)
+	}
+}
+
+// LoginSessionsStore is the storage layer for login sessions.
+type LoginSessionsStore struct {
+	db *gorm.DB
+}
+
+func newLoginSessionsStore(db *gorm.DB) *LoginSessionsStore {
+	return &LoginSessionsStore{db: db}
+}
+
+type CreateLoginSessionOptions struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Create creates a new login session for the user, and returns the key of the
+// session to be kept in the session data.
+func (s *LoginSessionsStore) Create(ctx context.Context, userID int64, opts CreateLoginSessionOptions) (string, error) {
+	key, err := strx.RandomChars(40)
+	if err != nil {
+		return "", errors.Wrap(err, "generate key")
+	}
+
+	session := &LoginSession{
+		UserID:    userID,
+		KeySHA256: cryptox.SHA256(key),
+		UserAgent: strx.Truncate(opts.UserAgent, 512),
+		IPAddress: opts.IPAddress,
+	}
+	if err = s.db.WithContext(ctx).Create(session).Error; err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+var _ errx.NotFound = (*ErrLoginSessionNotExist)(nil)
+
+type ErrLoginSessionNotExist struct {
+	args errx.Args
+}
+
+func IsErrLoginSessionNotExist(err error) bool {
+	return errors.As(err, &ErrLoginSessionNotExist{})
+}
+
+func (err ErrLoginSessionNotExist) Error() string {
+	return fmt.Sprintf("login session does not exist: %v", err.args)
+}
+
+func (ErrLoginSessionNotExist) NotFound() bool {
+	return true
+}
+
+// GetByKey returns the login session with given key. It returns
+// ErrLoginSessionNotExist when not found, e.g. the session has been revoked.
+func (s *LoginSessionsStore) GetByKey(ctx context.Context, key string) (*LoginSession, error) {
+	keySHA256 := cryptox.SHA256(key)
+	session := new(LoginSession)
+	err := s.db.WithContext(ctx).Where("key_sha256 = ?", keySHA256).First(session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoginSessionNotExist{args: errx.Args{"keySHA256": keySHA256}}
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// Touch updates the last seen time of the login session to the current time,
+// along with the IP address it is seen from.
+func (s *LoginSessionsStore) Touch(ctx context.Context, id int64, ipAddress string) error {
+	return s.db.WithContext(ctx).
+		Model(new(LoginSession)).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_seen_unix": s.db.NowFunc().Unix(),
+			"ip_address":     ipAddress,
+		}).
+		Error
+}
+
+// List returns all login sessions of the user, in descending order of the last
+// seen time.
+func (s *LoginSessionsStore) List(ctx context.Context, userID int64) ([]*LoginSession, error) {
+	var sessions []*LoginSession
+	return sessions, s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("last_seen_unix DESC, id DESC").
+		Find(&sessions).
+		Error
+}
+
+// DeleteByID revokes the login session of the user with given ID.
+func (s *LoginSessionsStore) DeleteByID(ctx context.Context, userID, id int64) error {
+	return s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(new(LoginSession)).Error
+}
+
+// DeleteByUserID revokes all login sessions of the user except the one with
+// the given ID, which is usually the session the request is made with. It
+// returns the number of revoked sessions.
+func (s *LoginSessionsStore) DeleteByUserID(ctx context.Context, userID, exceptID int64) (int64, error) {
+	result := s.db.WithContext(ctx).Where("user_id = ? AND id != ?", userID, exceptID).Delete(new(LoginSession))
+	return result.RowsAffected, result.Error
+}
+
+// DeleteInactive deletes login sessions that have not been seen since the
+// given time, and returns the number of deleted sessions.
+func (s *LoginSessionsStore) DeleteInactive(ctx context.Context, since time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("last_seen_unix < ?", since.Unix()).Delete(new(LoginSession))
+	return result.RowsAffected, result.Error
+}
+
+// DeleteInactiveLoginSessions deletes login sessions that have been inactive
+// for longer than the lifetime of session data, which must have been deleted
+// by the session provider.
+func DeleteInactiveLoginSessions() {
+	if taskStatusTable.IsRunning(taskNameLoginSessionCleanup) {
+		return
+	}
+	taskStatusTable.Start(taskNameLoginSessionCleanup)
+	defer taskStatusTable.Stop(taskNameLoginSessionCleanup)
+
+	log.Trace("Doing: DeleteInactiveLoginSessions")
+
+	since := time.Now().Add(-time.Duration(conf.Session.MaxLifeTime) * time.Second)
+	deleted, err := Handle.LoginSessions().DeleteInactive(context.Background(), since)
+	if err != nil {
+		log.Error("DeleteInactiveLoginSessions: %v", err)
+		return
+	}
+	log.Trace("Deleted %d inactive login sessions", deleted)
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"gogs.io/gogs/internal/cryptox"
+	"gogs.io/gogs/internal/errx"
+)
+
+func TestLoginSession_Device(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0",
+			want:      "Firefox on Linux",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0",
+			want:      "Edge on Windows",
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iOS",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36",
+			want:      "Chrome on Android",
+		},
+		{
+			userAgent: "curl/8.5.0",
+			want:      "curl/8.5.0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			s := &LoginSession{UserAgent: test.userAgent}
+			assert.Equal(t, test.want, s.Device())
+		})
+	}
+}
+
+func TestLoginSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &LoginSessionsStore{
+		db: newTestDB(t, "LoginSessionsStore"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *LoginSessionsStore)
+	}{
+		{"Create", loginSessionsCreate},
+		{"GetByKey", loginSessionsGetByKey},
+		{"Touch", loginSessionsTouch},
+		{"List", loginSessionsList},
+		{"DeleteByID", loginSessionsDeleteByID},
+		{"DeleteByUserID", loginSessionsDeleteByUserID},
+		{"DeleteInactive", loginSessionsDeleteInactive},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+// loginSessionsStoreAt returns a copy of the store whose current time is
+// shifted by the given duration.
+func loginSessionsStoreAt(s *LoginSessionsStore, shift time.Duration) *LoginSessionsStore {
+	now := s.db.NowFunc().Add(shift)
+	return &LoginSessionsStore{
+		db: s.db.Session(&gorm.Session{NowFunc: func() time.Time { return now }}),
+	}
+}
+
+func loginSessionsCreate(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	key, err := s.Create(ctx, 1, CreateLoginSessionOptions{
+		UserAgent: "curl/8.5.0",
+		IPAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+	assert.Len(t, key, 40)
+
+	// Only the hash of the key is stored.
+	var session LoginSession
+	err = s.db.First(&session).Error
+	require.NoError(t, err)
+	assert.Equal(t, cryptox.SHA256(key), session.KeySHA256)
+	assert.Equal(t, int64(1), session.UserID)
+	assert.Equal(t, "curl/8.5.0", session.UserAgent)
+	assert.Equal(t, "127.0.0.1", session.IPAddress)
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), session.Created.UTC().Format(time.RFC3339))
+	assert.Equal(t, session.Created, session.LastSeen)
+}
+
+func loginSessionsGetByKey(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	key, err := s.Create(ctx, 1, CreateLoginSessionOptions{})
+	require.NoError(t, err)
+
+	session, err := s.GetByKey(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), session.UserID)
+
+	_, err = s.GetByKey(ctx, "404")
+	wantErr := ErrLoginSessionNotExist{args: errx.Args{"keySHA256": cryptox.SHA256("404")}}
+	assert.Equal(t, wantErr, err)
+}
+
+func loginSessionsTouch(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	key, err := s.Create(ctx, 1, CreateLoginSessionOptions{IPAddress: "127.0.0.1"})
+	require.NoError(t, err)
+	session, err := s.GetByKey(ctx, key)
+	require.NoError(t, err)
+
+	err = loginSessionsStoreAt(s, time.Hour).Touch(ctx, session.ID, "10.0.0.1")
+	require.NoError(t, err)
+
+	got, err := s.GetByKey(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, session.Created, got.Created)
+	assert.Equal(t, session.LastSeen.Add(time.Hour), got.LastSeen)
+	assert.Equal(t, "10.0.0.1", got.IPAddress)
+}
+
+func loginSessionsList(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	_, err := s.Create(ctx, 1, CreateLoginSessionOptions{UserAgent: "old"})
+	require.NoError(t, err)
+	_, err = loginSessionsStoreAt(s, time.Hour).Create(ctx, 1, CreateLoginSessionOptions{UserAgent: "new"})
+	require.NoError(t, err)
+	_, err = s.Create(ctx, 2, CreateLoginSessionOptions{UserAgent: "other"})
+	require.NoError(t, err)
+
+	sessions, err := s.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "new", sessions[0].UserAgent)
+	assert.Equal(t, "old", sessions[1].UserAgent)
+}
+
+func loginSessionsDeleteByID(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	key, err := s.Create(ctx, 1, CreateLoginSessionOptions{})
+	require.NoError(t, err)
+	session, err := s.GetByKey(ctx, key)
+	require.NoError(t, err)
+
+	// Sessions of other users are not deleted.
+	err = s.DeleteByID(ctx, 2, session.ID)
+	require.NoError(t, err)
+	_, err = s.GetByKey(ctx, key)
+	require.NoError(t, err)
+
+	err = s.DeleteByID(ctx, 1, session.ID)
+	require.NoError(t, err)
+	_, err = s.GetByKey(ctx, key)
+	assert.True(t, IsErrLoginSessionNotExist(err))
+}
+
+func loginSessionsDeleteByUserID(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	var keys []string
+	for _, userID := range []int64{1, 1, 1, 2} {
+		key, err := s.Create(ctx, userID, CreateLoginSessionOptions{})
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	current, err := s.GetByKey(ctx, keys[0])
+	require.NoError(t, err)
+
+	revoked, err := s.DeleteByUserID(ctx, 1, current.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revoked)
+
+	sessions, err := s.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.ID, sessions[0].ID)
+
+	revoked, err = s.DeleteByUserID(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+
+	_, err = s.GetByKey(ctx, keys[3])
+	require.NoError(t, err)
+}
+
+func loginSessionsDeleteInactive(t *testing.T, ctx context.Context, s *LoginSessionsStore) {
+	oldKey, err := s.Create(ctx, 1, CreateLoginSessionOptions{})
+	require.NoError(t, err)
+	newKey, err := loginSessionsStoreAt(s, 2*time.Hour).Create(ctx, 1, CreateLoginSessionOptions{})
+	require.NoError(t, err)
+
+	deleted, err := s.DeleteInactive(ctx, s.db.NowFunc().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = s.GetByKey(ctx, oldKey)
+	assert.True(t, IsErrLoginSessionNotExist(err))
+	_, err = s.GetByKey(ctx, newKey)
+	require.NoError(t, err)
+}
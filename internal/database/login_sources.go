@@ -13,7 +13,9 @@ import (
 	"gogs.io/gogs/internal/auth"
 	"gogs.io/gogs/internal/auth/github"
 	"gogs.io/gogs/internal/auth/ldap"
+	"gogs.io/gogs/internal/auth/oidc"
 	"gogs.io/gogs/internal/auth/pam"
+	"gogs.io/gogs/internal/auth/saml"
 	"gogs.io/gogs/internal/auth/smtp"
 	"gogs.io/gogs/internal/errx"
 )
@@ -111,6 +113,22 @@ func (s *LoginSource) AfterFind(_ *gorm.DB) error {
 		}
 		s.Provider = github.NewProvider(&cfg)
 
+	case auth.OIDC:
+		var cfg oidc.Config
+		err := json.Unmarshal([]byte(s.Config), &cfg)
+		if err != nil {
+			return err
+		}
+		s.Provider = oidc.NewProvider(&cfg)
+
+	case auth.SAML:
+		var cfg saml.Config
+		err := json.Unmarshal([]byte(s.Config), &cfg)
+		if err != nil {
+			return err
+		}
+		s.Provider = saml.NewProvider(&cfg)
+
 	case auth.Mock:
 		var cfg mockProviderConfig
 		err := json.Unmarshal([]byte(s.Config), &cfg)
@@ -151,6 +169,14 @@ func (s *LoginSource) IsGitHub() bool {
 	return s.Type == auth.GitHub
 }
 
+func (s *LoginSource) IsOIDC() bool {
+	return s.Type == auth.OIDC
+}
+
+func (s *LoginSource) IsSAML() bool {
+	return s.Type == auth.SAML
+}
+
 func (s *LoginSource) LDAP() *ldap.Config {
 	return s.Provider.Config().(*ldap.Config)
 }
@@ -167,6 +193,14 @@ func (s *LoginSource) GitHub() *github.Config {
 	return s.Provider.Config().(*github.Config)
 }
 
+func (s *LoginSource) OIDC() *oidc.Config {
+	return s.Provider.Config().(*oidc.Config)
+}
+
+func (s *LoginSource) SAML() *saml.Config {
+	return s.Provider.Config().(*saml.Config)
+}
+
 // LoginSourcesStore is the storage layer for login sources.
 type LoginSourcesStore struct {
 	db    *gorm.DB
@@ -13,7 +13,9 @@ import (
 	"gogs.io/gogs/internal/auth"
 	"gogs.io/gogs/internal/auth/github"
 	"gogs.io/gogs/internal/auth/ldap"
+	"gogs.io/gogs/internal/auth/oidc"
 	"gogs.io/gogs/internal/auth/pam"
+	"gogs.io/gogs/internal/auth/saml"
 	"gogs.io/gogs/internal/auth/smtp"
 	"gogs.io/gogs/internal/errx"
 )
@@ -133,6 +135,16 @@ func TestLoginSource_AfterFind(t *testing.T) {
 			authType: auth.GitHub,
 			wantType: &github.Provider{},
 		},
+		{
+			name:     "OIDC",
+			authType: auth.OIDC,
+			wantType: &oidc.Provider{},
+		},
+		{
+			name:     "SAML",
+			authType: auth.SAML,
+			wantType: &saml.Provider{},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -3,15 +3,21 @@ package database
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	log "unknwon.dev/clog/v2"
+	"xorm.io/core"
+	"xorm.io/xorm"
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/dbtest"
+	"gogs.io/gogs/internal/dbx"
 	"gogs.io/gogs/internal/testx"
 )
 
@@ -50,6 +56,46 @@ func newTestDB(t *testing.T, suite string) *gorm.DB {
 	return dbtest.NewDB(t, suite, append(Tables, legacyTables...)...)
 }
 
+// setTestDB sets up a new SQLite database with all tables as both the global
+// database handle and the legacy XORM engine, for testing code that uses
+// either of them. It returns the XORM engine to insert test fixtures, and
+// restores the previous state after the test is completed.
+func setTestDB(t *testing.T) *xorm.Engine {
+	beforeDatabase, beforeLogger := conf.Database, logger.Default
+	beforeSQLite3, beforeMySQL, beforePostgreSQL := conf.UseSQLite3, conf.UseMySQL, conf.UsePostgreSQL
+	beforeX, beforeHandle := x, Handle
+	t.Cleanup(func() {
+		conf.Database, logger.Default = beforeDatabase, beforeLogger
+		conf.UseSQLite3, conf.UseMySQL, conf.UsePostgreSQL = beforeSQLite3, beforeMySQL, beforePostgreSQL
+		x, Handle = beforeX, beforeHandle
+	})
+
+	conf.Database = conf.DatabaseOpts{
+		Type: "sqlite3",
+		Path: filepath.Join(t.TempDir(), "gogs.db"),
+	}
+	conf.UseMySQL, conf.UsePostgreSQL = false, false
+
+	engine, err := getEngine()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = engine.Close() })
+	engine.SetMapper(core.GonicMapper{})
+	engine.SetLogger(xorm.DiscardLogger{})
+	err = engine.Sync2(legacyTables...)
+	require.NoError(t, err)
+	x = engine
+
+	db, err := NewConnection(&dbx.Logger{Writer: io.Discard})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return engine
+}
+
 func clearTables(t *testing.T, db *gorm.DB) error {
 	if t.Failed() {
 		return nil
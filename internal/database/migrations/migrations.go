@@ -59,6 +59,10 @@ var migrations = []Migration{
 	// on v22. Let's make a noop v22 to make sure every instance will not miss a
 	// real future migration.
 	NewMigration("noop", func(*gorm.DB) error { return nil }),
+	// v22 -> v23:v0.15.0
+	NewMigration("add scopes, repositories and expiry to access tokens", addScopesAndExpiryToAccessTokens),
+	// v23 -> v24:v0.15.0
+	NewMigration("add OAuth2 grants and refresh tokens to access tokens", addOAuth2GrantToAccessTokens),
 }
 
 var errMigrationSkipped = errors.New("the migration has been skipped")
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+)
+
+func addScopesAndExpiryToAccessTokens(db *gorm.DB) error {
+	type accessToken struct {
+		Scopes      string `gorm:"type:TEXT"`
+		RepoIDs     string `gorm:"type:TEXT"`
+		ExpiresUnix int64  `gorm:"index"`
+	}
+
+	if db.Migrator().HasColumn(&accessToken{}, "ExpiresUnix") {
+		return errMigrationSkipped
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range []string{"Scopes", "RepoIDs", "ExpiresUnix"} {
+			err := tx.Migrator().AddColumn(&accessToken{}, column)
+			if err != nil {
+				return errors.Wrapf(err, "add column %q", column)
+			}
+		}
+
+		// Existing tokens never expire.
+		err := tx.Model(&accessToken{}).Where("expires_unix IS NULL").Update("expires_unix", 0).Error
+		if err != nil {
+			return errors.Wrap(err, "update")
+		}
+		return tx.Migrator().CreateIndex(&accessToken{}, "ExpiresUnix")
+	})
+}
@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/dbtest"
+)
+
+type accessTokenPreV23 struct {
+	ID          int64 `gorm:"primarykey"`
+	UserID      int64 `gorm:"column:uid;index"`
+	Name        string
+	Sha1        string `gorm:"type:VARCHAR(40);unique"`
+	SHA256      string `gorm:"type:VARCHAR(64);unique;not null"`
+	CreatedUnix int64
+	UpdatedUnix int64
+}
+
+func (*accessTokenPreV23) TableName() string {
+	return "access_token"
+}
+
+type accessTokenV23 struct {
+	ID          int64 `gorm:"primarykey"`
+	UserID      int64 `gorm:"column:uid;index"`
+	Name        string
+	Sha1        string `gorm:"type:VARCHAR(40);unique"`
+	SHA256      string `gorm:"type:VARCHAR(64);unique;not null"`
+	Scopes      string `gorm:"type:TEXT"`
+	RepoIDs     string `gorm:"type:TEXT"`
+	CreatedUnix int64
+	UpdatedUnix int64
+	ExpiresUnix int64 `gorm:"index"`
+}
+
+func (*accessTokenV23) TableName() string {
+	return "access_token"
+}
+
+func TestAddScopesAndExpiryToAccessTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	db := dbtest.NewDB(t, "addScopesAndExpiryToAccessTokens", new(accessTokenPreV23))
+	err := db.Create(
+		&accessTokenPreV23{
+			ID:          1,
+			UserID:      1,
+			Name:        "test",
+			Sha1:        "73da7bb9d2a475bbc2ab79da7d4e94940cb9f9d5",
+			SHA256:      "f8f2efd7a7cb5a3e4fc5b2de8e9a9b1c0b8d7ff9ffbfe5ab2e86e4b6ab7a3f0f",
+			CreatedUnix: db.NowFunc().Unix(),
+		},
+	).Error
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasColumn(&accessTokenV23{}, "ExpiresUnix"))
+
+	err = addScopesAndExpiryToAccessTokens(db)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasColumn(&accessTokenV23{}, "Scopes"))
+	assert.True(t, db.Migrator().HasColumn(&accessTokenV23{}, "RepoIDs"))
+	assert.True(t, db.Migrator().HasIndex(&accessTokenV23{}, "ExpiresUnix"))
+
+	var got accessTokenV23
+	err = db.Where("id = ?", 1).First(&got).Error
+	require.NoError(t, err)
+	assert.Equal(t, "test", got.Name)
+	assert.Empty(t, got.Scopes)
+	assert.Zero(t, got.ExpiresUnix)
+
+	// Re-run should be skipped
+	err = addScopesAndExpiryToAccessTokens(db)
+	require.Equal(t, errMigrationSkipped, err)
+}
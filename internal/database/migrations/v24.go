@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+)
+
+func addOAuth2GrantToAccessTokens(db *gorm.DB) error {
+	type accessToken struct {
+		GrantID            int64  `gorm:"index"`
+		RefreshSHA256      string `gorm:"type:VARCHAR(64);index"`
+		RefreshExpiresUnix int64
+	}
+
+	if db.Migrator().HasColumn(&accessToken{}, "GrantID") {
+		return errMigrationSkipped
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range []string{"GrantID", "RefreshSHA256", "RefreshExpiresUnix"} {
+			err := tx.Migrator().AddColumn(&accessToken{}, column)
+			if err != nil {
+				return errors.Wrapf(err, "add column %q", column)
+			}
+		}
+
+		// Existing tokens are all personal access tokens.
+		err := tx.Model(&accessToken{}).Where("grant_id IS NULL").
+			Updates(map[string]any{
+				"grant_id":             0,
+				"refresh_expires_unix": 0,
+			}).Error
+		if err != nil {
+			return errors.Wrap(err, "update")
+		}
+		for _, index := range []string{"GrantID", "RefreshSHA256"} {
+			err = tx.Migrator().CreateIndex(&accessToken{}, index)
+			if err != nil {
+				return errors.Wrapf(err, "create index %q", index)
+			}
+		}
+		return nil
+	})
+}
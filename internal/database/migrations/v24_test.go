@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/dbtest"
+)
+
+type accessTokenV24 struct {
+	ID                 int64 `gorm:"primarykey"`
+	UserID             int64 `gorm:"column:uid;index"`
+	Name               string
+	Sha1               string `gorm:"type:VARCHAR(40);unique"`
+	SHA256             string `gorm:"type:VARCHAR(64);unique;not null"`
+	Scopes             string `gorm:"type:TEXT"`
+	RepoIDs            string `gorm:"type:TEXT"`
+	GrantID            int64  `gorm:"index"`
+	RefreshSHA256      string `gorm:"type:VARCHAR(64);index"`
+	RefreshExpiresUnix int64
+	CreatedUnix        int64
+	UpdatedUnix        int64
+	ExpiresUnix        int64 `gorm:"index"`
+}
+
+func (*accessTokenV24) TableName() string {
+	return "access_token"
+}
+
+func TestAddOAuth2GrantToAccessTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	db := dbtest.NewDB(t, "addOAuth2GrantToAccessTokens", new(accessTokenV23))
+	err := db.Create(
+		&accessTokenV23{
+			ID:          1,
+			UserID:      1,
+			Name:        "test",
+			Sha1:        "73da7bb9d2a475bbc2ab79da7d4e94940cb9f9d5",
+			SHA256:      "f8f2efd7a7cb5a3e4fc5b2de8e9a9b1c0b8d7ff9ffbfe5ab2e86e4b6ab7a3f0f",
+			CreatedUnix: db.NowFunc().Unix(),
+		},
+	).Error
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasColumn(&accessTokenV24{}, "GrantID"))
+
+	err = addOAuth2GrantToAccessTokens(db)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasColumn(&accessTokenV24{}, "RefreshExpiresUnix"))
+	assert.True(t, db.Migrator().HasIndex(&accessTokenV24{}, "GrantID"))
+	assert.True(t, db.Migrator().HasIndex(&accessTokenV24{}, "RefreshSHA256"))
+
+	var got accessTokenV24
+	err = db.Where("id = ? AND grant_id = 0", 1).First(&got).Error
+	require.NoError(t, err)
+	assert.Equal(t, "test", got.Name)
+	assert.Empty(t, got.RefreshSHA256)
+	assert.Zero(t, got.RefreshExpiresUnix)
+
+	// Re-run should be skipped
+	err = addOAuth2GrantToAccessTokens(db)
+	require.Equal(t, errMigrationSkipped, err)
+}
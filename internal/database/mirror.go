@@ -52,6 +52,15 @@ type Mirror struct {
 	NextSync     time.Time `xorm:"-" json:"-" gorm:"-"`
 	NextSyncUnix int64     `xorm:"next_update_unix"`
 
+	// Whether to fetch LFS objects from upstream on every sync.
+	EnableLFS bool
+	// The result of the last fetch of LFS objects from upstream
+	LFSLastSync     time.Time `xorm:"-" json:"-" gorm:"-"`
+	LFSLastSyncUnix int64
+	LFSFetched      int    // The number of objects fetched.
+	LFSMissing      int    // The number of objects still missing.
+	LFSLastError    string `xorm:"TEXT"`
+
 	address string `xorm:"-"`
 }
 
@@ -76,6 +85,8 @@ func (m *Mirror) AfterSet(colName string, _ xorm.Cell) {
 		m.LastSync = time.Unix(m.LastSyncUnix, 0).Local()
 	case "next_update_unix":
 		m.NextSync = time.Unix(m.NextSyncUnix, 0).Local()
+	case "lfs_last_sync_unix":
+		m.LFSLastSync = time.Unix(m.LFSLastSyncUnix, 0).Local()
 	}
 }
 
@@ -324,9 +335,31 @@ func (m *Mirror) runSync() ([]*mirrorSyncResult, bool) {
 		}
 	}
 
+	if m.EnableLFS {
+		m.syncLFS(rawAddr, timeout)
+	}
+
 	return parseRemoteUpdateOutput(output), true
 }
 
+// syncLFS fetches LFS objects from upstream, and records the result to be
+// shown in the mirror settings.
+func (m *Mirror) syncLFS(remoteAddr string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := fetchMirrorLFSObjects(ctx, m.Repo, remoteAddr)
+	m.LFSLastSync = time.Now()
+	m.LFSLastSyncUnix = m.LFSLastSync.Unix()
+	m.LFSFetched = result.Fetched
+	m.LFSMissing = result.Missing
+	m.LFSLastError = ""
+	if err != nil {
+		log.Error("Failed to fetch LFS objects of mirror repository %q: %v", m.Repo.FullName(), err)
+		m.LFSLastError = err.Error()
+	}
+}
+
 func getMirrorByRepoID(e Engine, repoID int64) (*Mirror, error) {
 	m := &Mirror{RepoID: repoID}
 	has, err := e.Get(m)
@@ -379,6 +412,15 @@ func MirrorUpdate() {
 	}); err != nil {
 		log.Error("MirrorUpdate: %v", err)
 	}
+
+	pushMirrors, err := Handle.PushMirrors().ListDue(context.TODO())
+	if err != nil {
+		log.Error("Failed to list due push mirrors: %v", err)
+		return
+	}
+	for _, m := range pushMirrors {
+		PushMirrorQueue.Add(m.ID)
+	}
 }
 
 // SyncMirrors checks and syncs mirrors.
@@ -53,6 +53,7 @@ func init() {
 		new(Repository), new(DeployKey), new(Collaboration), new(Upload),
 		new(Watch), new(Star),
 		new(Issue), new(PullRequest), new(Comment), new(Attachment), new(IssueUser),
+		new(Review), new(ReviewRequest), new(MergeQueueEntry),
 		new(Label), new(IssueLabel), new(Milestone),
 		new(Mirror), new(Release), new(Webhook), new(HookTask),
 		new(ProtectBranch), new(ProtectBranchWhitelist),
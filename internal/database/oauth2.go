@@ -0,0 +1,757 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"gogs.io/gogs/internal/cryptox"
+	"gogs.io/gogs/internal/errx"
+	"gogs.io/gogs/internal/strx"
+)
+
+const (
+	// The lifetime of authorization codes, which are meant to be exchanged for
+	// tokens right after being issued.
+	oauth2AuthorizationCodeLifetime = 10 * time.Minute
+	// The lifetime of access tokens issued to OAuth2 applications.
+	oauth2AccessTokenLifetime = time.Hour
+	// The lifetime of refresh tokens issued to OAuth2 applications.
+	oauth2RefreshTokenLifetime = 30 * 24 * time.Hour
+)
+
+// OAuth2Application is an OAuth2 application registered by a user, which can
+// be granted access to accounts of users on their behalf.
+type OAuth2Application struct {
+	ID                 int64  `gorm:"primaryKey"`
+	UserID             int64  `gorm:"index;not null"`
+	Name               string `gorm:"not null"`
+	ClientID           string `gorm:"type:VARCHAR(32);unique;not null"`
+	ClientSecretSHA256 string `gorm:"type:VARCHAR(64);not null"`
+	// Newline-separated redirect URIs, one of which must exactly match the
+	// redirect URI of an authorization request.
+	RedirectURIs string `gorm:"type:TEXT;not null"`
+	// Whether the application is able to keep the client secret confidential.
+	// Public applications (e.g. native and single-page applications) are not
+	// required to authenticate with the client secret, but must use PKCE.
+	Confidential bool `gorm:"not null"`
+
+	Created     time.Time `gorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `gorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (*OAuth2Application) TableName() string {
+	return "oauth2_application"
+}
+
+// BeforeCreate implements the GORM create hook.
+func (a *OAuth2Application) BeforeCreate(tx *gorm.DB) error {
+	if a.CreatedUnix == 0 {
+		a.CreatedUnix = tx.NowFunc().Unix()
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (a *OAuth2Application) AfterFind(_ *gorm.DB) error {
+	a.Created = time.Unix(a.CreatedUnix, 0).Local()
+	if a.UpdatedUnix > 0 {
+		a.Updated = time.Unix(a.UpdatedUnix, 0).Local()
+	}
+	return nil
+}
+
+// RedirectURIList returns the list of redirect URIs of the application.
+func (a *OAuth2Application) RedirectURIList() []string {
+	return strings.Fields(a.RedirectURIs)
+}
+
+// HasRedirectURI returns true if the redirect URI is registered for the
+// application.
+func (a *OAuth2Application) HasRedirectURI(redirectURI string) bool {
+	return slices.Contains(a.RedirectURIList(), redirectURI)
+}
+
+// VerifyClientSecret returns true if the client secret is the one of the
+// application.
+func (a *OAuth2Application) VerifyClientSecret(clientSecret string) bool {
+	return subtle.ConstantTimeCompare([]byte(cryptox.SHA256(clientSecret)), []byte(a.ClientSecretSHA256)) == 1
+}
+
+// OAuth2Grant is the access a user has granted to an OAuth2 application.
+type OAuth2Grant struct {
+	ID            int64 `gorm:"primaryKey"`
+	UserID        int64 `gorm:"uniqueIndex:oauth2_grant_user_application_unique;not null"`
+	ApplicationID int64 `gorm:"uniqueIndex:oauth2_grant_user_application_unique;index;not null"`
+	// Space-separated scopes that have been granted.
+	Scopes string `gorm:"type:TEXT;not null"`
+
+	// The application of the grant, only loaded by OAuth2Store.ListGrants.
+	Application *OAuth2Application `gorm:"-" json:"-"`
+
+	Created     time.Time `gorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `gorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (*OAuth2Grant) TableName() string {
+	return "oauth2_grant"
+}
+
+// BeforeCreate implements the GORM create hook.
+func (g *OAuth2Grant) BeforeCreate(tx *gorm.DB) error {
+	if g.CreatedUnix == 0 {
+		g.CreatedUnix = tx.NowFunc().Unix()
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (g *OAuth2Grant) AfterFind(_ *gorm.DB) error {
+	g.Created = time.Unix(g.CreatedUnix, 0).Local()
+	if g.UpdatedUnix > 0 {
+		g.Updated = time.Unix(g.UpdatedUnix, 0).Local()
+	}
+	return nil
+}
+
+// ScopeList returns the list of scopes that have been granted.
+func (g *OAuth2Grant) ScopeList() []AccessTokenScope {
+	return (&AccessToken{Scopes: g.Scopes}).ScopeList()
+}
+
+// HasScopes returns true if all the scopes have been granted.
+func (g *OAuth2Grant) HasScopes(scopes []AccessTokenScope) bool {
+	granted := g.ScopeList()
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuth2AuthorizationCode is an authorization code issued to an OAuth2
+// application, which is exchanged for tokens by the application.
+type OAuth2AuthorizationCode struct {
+	ID      int64  `gorm:"primaryKey"`
+	GrantID int64  `gorm:"index;not null"`
+	SHA256  string `gorm:"type:VARCHAR(64);unique;not null"`
+	// Space-separated scopes of tokens to be issued for the code.
+	Scopes      string `gorm:"type:TEXT;not null"`
+	RedirectURI string `gorm:"type:TEXT;not null"`
+	// The S256 PKCE code challenge, see https://datatracker.ietf.org/doc/html/rfc7636.
+	CodeChallenge string `gorm:"type:VARCHAR(64)"`
+	ExpiresUnix   int64  `gorm:"index;not null"`
+	// The ID of the access token issued for the code, or 0 if the code has not
+	// been exchanged. It follows the access token when it is refreshed, so that
+	// the tokens can be revoked if the code is presented again.
+	AccessTokenID int64 `gorm:"index;not null;default:0"`
+}
+
+func (*OAuth2AuthorizationCode) TableName() string {
+	return "oauth2_authorization_code"
+}
+
+// OAuth2Store is the storage layer for OAuth2 applications and grants.
+type OAuth2Store struct {
+	db *gorm.DB
+}
+
+func newOAuth2Store(db *gorm.DB) *OAuth2Store {
+	return &OAuth2Store{db: db}
+}
+
+type ErrInvalidOAuth2Application struct {
+	args errx.Args
+}
+
+// IsErrInvalidOAuth2Application returns true if the underlying error has the
+// type ErrInvalidOAuth2Application.
+func IsErrInvalidOAuth2Application(err error) bool {
+	return errors.As(err, &ErrInvalidOAuth2Application{})
+}
+
+func (err ErrInvalidOAuth2Application) Error() string {
+	return fmt.Sprintf("invalid OAuth2 application: %v", err.args)
+}
+
+// parseRedirectURIs validates and normalizes the list of redirect URIs. Each
+// redirect URI must be an absolute URL without a fragment.
+func parseRedirectURIs(redirectURIs []string) (string, error) {
+	uris := make([]string, 0, len(redirectURIs))
+	for _, uri := range redirectURIs {
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			continue
+		}
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Host == "" && u.Scheme != "urn" || u.Fragment != "" || strings.ContainsAny(uri, " \t") {
+			return "", ErrInvalidOAuth2Application{args: errx.Args{"redirectURI": uri}}
+		}
+		if !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 {
+		return "", ErrInvalidOAuth2Application{args: errx.Args{"redirectURIs": redirectURIs}}
+	}
+	return strings.Join(uris, "\n"), nil
+}
+
+type CreateOAuth2ApplicationOptions struct {
+	Name         string
+	RedirectURIs []string
+	Confidential bool
+}
+
+// CreateApplication creates a new OAuth2 application for the user, and returns
+// it along with its client secret, which is not stored in plain text and can
+// only be obtained at this time. It returns ErrInvalidOAuth2Application when
+// any of the redirect URIs is not valid.
+func (s *OAuth2Store) CreateApplication(ctx context.Context, userID int64, opts CreateOAuth2ApplicationOptions) (_ *OAuth2Application, clientSecret string, _ error) {
+	redirectURIs, err := parseRedirectURIs(opts.RedirectURIs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	clientID, err := strx.RandomChars(32)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "generate client ID")
+	}
+	clientSecret, err = strx.RandomChars(40)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "generate client secret")
+	}
+
+	app := &OAuth2Application{
+		UserID:             userID,
+		Name:               opts.Name,
+		ClientID:           clientID,
+		ClientSecretSHA256: cryptox.SHA256(clientSecret),
+		RedirectURIs:       redirectURIs,
+		Confidential:       opts.Confidential,
+	}
+	if err = s.db.WithContext(ctx).Create(app).Error; err != nil {
+		return nil, "", err
+	}
+	return app, clientSecret, nil
+}
+
+var _ errx.NotFound = (*ErrOAuth2ApplicationNotExist)(nil)
+
+type ErrOAuth2ApplicationNotExist struct {
+	args errx.Args
+}
+
+// IsErrOAuth2ApplicationNotExist returns true if the underlying error has the
+// type ErrOAuth2ApplicationNotExist.
+func IsErrOAuth2ApplicationNotExist(err error) bool {
+	return errors.As(errors.Cause(err), &ErrOAuth2ApplicationNotExist{})
+}
+
+func (err ErrOAuth2ApplicationNotExist) Error() string {
+	return fmt.Sprintf("OAuth2 application does not exist: %v", err.args)
+}
+
+func (ErrOAuth2ApplicationNotExist) NotFound() bool {
+	return true
+}
+
+// GetApplicationByID returns the OAuth2 application with given ID of the user.
+// It returns ErrOAuth2ApplicationNotExist when not found.
+func (s *OAuth2Store) GetApplicationByID(ctx context.Context, userID, id int64) (*OAuth2Application, error) {
+	app := new(OAuth2Application)
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(app).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOAuth2ApplicationNotExist{args: errx.Args{"userID": userID, "id": id}}
+	} else if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// GetApplicationByClientID returns the OAuth2 application with given client ID.
+// It returns ErrOAuth2ApplicationNotExist when not found.
+func (s *OAuth2Store) GetApplicationByClientID(ctx context.Context, clientID string) (*OAuth2Application, error) {
+	if clientID == "" {
+		return nil, ErrOAuth2ApplicationNotExist{args: errx.Args{"clientID": clientID}}
+	}
+
+	app := new(OAuth2Application)
+	err := s.db.WithContext(ctx).Where("client_id = ?", clientID).First(app).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOAuth2ApplicationNotExist{args: errx.Args{"clientID": clientID}}
+	} else if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// ListApplications returns all OAuth2 applications of the user.
+func (s *OAuth2Store) ListApplications(ctx context.Context, userID int64) ([]*OAuth2Application, error) {
+	var apps []*OAuth2Application
+	return apps, s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&apps).Error
+}
+
+type UpdateOAuth2ApplicationOptions struct {
+	Name         string
+	RedirectURIs []string
+	Confidential bool
+}
+
+// UpdateApplication updates the OAuth2 application with given ID of the user.
+// It returns ErrInvalidOAuth2Application when any of the redirect URIs is not
+// valid.
+func (s *OAuth2Store) UpdateApplication(ctx context.Context, userID, id int64, opts UpdateOAuth2ApplicationOptions) error {
+	redirectURIs, err := parseRedirectURIs(opts.RedirectURIs)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).
+		Model(new(OAuth2Application)).
+		Where("id = ? AND user_id = ?", id, userID).
+		Updates(map[string]any{
+			"name":          opts.Name,
+			"redirect_uris": redirectURIs,
+			"confidential":  opts.Confidential,
+			"updated_unix":  s.db.NowFunc().Unix(),
+		}).
+		Error
+}
+
+// RegenerateClientSecret generates a new client secret for the OAuth2
+// application with given ID of the user, and returns the new client secret.
+// The old client secret stops working immediately.
+func (s *OAuth2Store) RegenerateClientSecret(ctx context.Context, userID, id int64) (string, error) {
+	app, err := s.GetApplicationByID(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+
+	clientSecret, err := strx.RandomChars(40)
+	if err != nil {
+		return "", errors.Wrap(err, "generate client secret")
+	}
+	err = s.db.WithContext(ctx).
+		Model(new(OAuth2Application)).
+		Where("id = ?", app.ID).
+		Updates(map[string]any{
+			"client_secret_sha256": cryptox.SHA256(clientSecret),
+			"updated_unix":         s.db.NowFunc().Unix(),
+		}).
+		Error
+	if err != nil {
+		return "", err
+	}
+	return clientSecret, nil
+}
+
+// deleteGrants deletes grants that match the query along with authorization
+// codes and tokens issued for them.
+func deleteGrants(tx *gorm.DB, query any, args ...any) error {
+	var grantIDs []int64
+	err := tx.Model(new(OAuth2Grant)).Where(query, args...).Pluck("id", &grantIDs).Error
+	if err != nil {
+		return errors.Wrap(err, "list grants")
+	} else if len(grantIDs) == 0 {
+		return nil
+	}
+
+	for _, table := range []any{new(OAuth2AuthorizationCode), new(AccessToken)} {
+		err = tx.Where("grant_id IN (?)", grantIDs).Delete(table).Error
+		if err != nil {
+			return errors.Wrapf(err, "delete %T", table)
+		}
+	}
+	return tx.Where("id IN (?)", grantIDs).Delete(new(OAuth2Grant)).Error
+}
+
+// DeleteApplication deletes the OAuth2 application with given ID of the user,
+// and revokes all access granted to it.
+func (s *OAuth2Store) DeleteApplication(ctx context.Context, userID, id int64) error {
+	app, err := s.GetApplicationByID(ctx, userID, id)
+	if err != nil {
+		if IsErrOAuth2ApplicationNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := deleteGrants(tx, "application_id = ?", app.ID)
+		if err != nil {
+			return errors.Wrap(err, "delete grants")
+		}
+		return tx.Where("id = ?", app.ID).Delete(new(OAuth2Application)).Error
+	})
+}
+
+var _ errx.NotFound = (*ErrOAuth2GrantNotExist)(nil)
+
+type ErrOAuth2GrantNotExist struct {
+	args errx.Args
+}
+
+// IsErrOAuth2GrantNotExist returns true if the underlying error has the type
+// ErrOAuth2GrantNotExist.
+func IsErrOAuth2GrantNotExist(err error) bool {
+	return errors.As(errors.Cause(err), &ErrOAuth2GrantNotExist{})
+}
+
+func (err ErrOAuth2GrantNotExist) Error() string {
+	return fmt.Sprintf("OAuth2 grant does not exist: %v", err.args)
+}
+
+func (ErrOAuth2GrantNotExist) NotFound() bool {
+	return true
+}
+
+// GetGrant returns the access the user has granted to the OAuth2 application.
+// It returns ErrOAuth2GrantNotExist when not found.
+func (s *OAuth2Store) GetGrant(ctx context.Context, userID, applicationID int64) (*OAuth2Grant, error) {
+	grant := new(OAuth2Grant)
+	err := s.db.WithContext(ctx).Where("user_id = ? AND application_id = ?", userID, applicationID).First(grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOAuth2GrantNotExist{args: errx.Args{"userID": userID, "applicationID": applicationID}}
+	} else if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// ListGrants returns all access the user has granted to OAuth2 applications,
+// with their applications loaded.
+func (s *OAuth2Store) ListGrants(ctx context.Context, userID int64) ([]*OAuth2Grant, error) {
+	var grants []*OAuth2Grant
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&grants).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "list grants")
+	}
+
+	appIDs := make([]int64, 0, len(grants))
+	for _, grant := range grants {
+		appIDs = append(appIDs, grant.ApplicationID)
+	}
+	var apps []*OAuth2Application
+	if len(appIDs) > 0 {
+		err = s.db.WithContext(ctx).Where("id IN (?)", appIDs).Find(&apps).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "list applications")
+		}
+	}
+	appsByID := make(map[int64]*OAuth2Application, len(apps))
+	for _, app := range apps {
+		appsByID[app.ID] = app
+	}
+
+	loaded := grants[:0]
+	for _, grant := range grants {
+		grant.Application = appsByID[grant.ApplicationID]
+		if grant.Application != nil {
+			loaded = append(loaded, grant)
+		}
+	}
+	return loaded, nil
+}
+
+// RevokeGrant revokes the access the user has granted with given grant ID, and
+// deletes all tokens issued for it.
+func (s *OAuth2Store) RevokeGrant(ctx context.Context, userID, grantID int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteGrants(tx, "id = ? AND user_id = ?", grantID, userID)
+	})
+}
+
+type CreateOAuth2AuthorizationCodeOptions struct {
+	// The scopes the user has consented to, which are added to the grant.
+	Scopes []AccessTokenScope
+	// The redirect URI of the authorization request, which must be presented
+	// again when exchanging the code.
+	RedirectURI string
+	// The S256 PKCE code challenge, empty if the application does not use PKCE.
+	CodeChallenge string
+}
+
+// CreateAuthorizationCode grants the scopes to the OAuth2 application on behalf
+// of the user, and returns a new authorization code for the application to
+// exchange for tokens. It returns ErrInvalidAccessTokenScope when any of the
+// scopes is not valid.
+func (s *OAuth2Store) CreateAuthorizationCode(ctx context.Context, userID int64, app *OAuth2Application, opts CreateOAuth2AuthorizationCodeOptions) (string, error) {
+	if len(opts.Scopes) == 0 {
+		return "", ErrInvalidAccessTokenScope{args: errx.Args{"scopes": opts.Scopes}}
+	}
+	scopes := make([]string, 0, len(opts.Scopes))
+	for _, scope := range opts.Scopes {
+		if !slices.Contains(AccessTokenScopes, scope) {
+			return "", ErrInvalidAccessTokenScope{args: errx.Args{"scope": scope}}
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+
+	code, err := strx.RandomChars(40)
+	if err != nil {
+		return "", errors.Wrap(err, "generate code")
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := tx.NowFunc()
+		grant := new(OAuth2Grant)
+		err := tx.Where("user_id = ? AND application_id = ?", userID, app.ID).First(grant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			grant = &OAuth2Grant{
+				UserID:        userID,
+				ApplicationID: app.ID,
+				Scopes:        strings.Join(scopes, " "),
+			}
+			err = tx.Create(grant).Error
+			if err != nil {
+				return errors.Wrap(err, "create grant")
+			}
+		} else if err != nil {
+			return errors.Wrap(err, "get grant")
+		} else {
+			granted := strings.Fields(grant.Scopes)
+			for _, scope := range scopes {
+				if !slices.Contains(granted, scope) {
+					granted = append(granted, scope)
+				}
+			}
+			err = tx.Model(grant).Updates(map[string]any{
+				"scopes":       strings.Join(granted, " "),
+				"updated_unix": now.Unix(),
+			}).Error
+			if err != nil {
+				return errors.Wrap(err, "update grant")
+			}
+		}
+
+		// Clean up codes that are never exchanged along the way.
+		err = tx.Where("expires_unix <= ?", now.Unix()).Delete(new(OAuth2AuthorizationCode)).Error
+		if err != nil {
+			return errors.Wrap(err, "delete expired codes")
+		}
+		return tx.Create(&OAuth2AuthorizationCode{
+			GrantID:       grant.ID,
+			SHA256:        cryptox.SHA256(code),
+			Scopes:        strings.Join(scopes, " "),
+			RedirectURI:   opts.RedirectURI,
+			CodeChallenge: opts.CodeChallenge,
+			ExpiresUnix:   now.Add(oauth2AuthorizationCodeLifetime).Unix(),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+type ErrInvalidOAuth2Grant struct {
+	args errx.Args
+}
+
+// IsErrInvalidOAuth2Grant returns true if the underlying error has the type
+// ErrInvalidOAuth2Grant.
+func IsErrInvalidOAuth2Grant(err error) bool {
+	return errors.As(err, &ErrInvalidOAuth2Grant{})
+}
+
+func (err ErrInvalidOAuth2Grant) Error() string {
+	return fmt.Sprintf("invalid OAuth2 grant: %v", err.args)
+}
+
+// OAuth2Token is a pair of access token and refresh token issued to an OAuth2
+// application.
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	Scopes       string
+
+	id int64 // The ID of the stored access token.
+}
+
+// createToken issues a new pair of access token and refresh token for the
+// grant.
+func createOAuth2Token(tx *gorm.DB, grant *OAuth2Grant, app *OAuth2Application, scopes string) (*OAuth2Token, error) {
+	accessToken := cryptox.SHA1(uuid.New().String())
+	refreshToken, err := strx.RandomChars(40)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate refresh token")
+	}
+
+	now := tx.NowFunc()
+	sha256 := cryptox.SHA256(accessToken)
+	t := &AccessToken{
+		UserID:             grant.UserID,
+		Name:               app.Name,
+		Sha1:               sha256[:40], // To pass the column unique constraint, keep the length of SHA1.
+		SHA256:             sha256,
+		Scopes:             scopes,
+		GrantID:            grant.ID,
+		RefreshSHA256:      cryptox.SHA256(refreshToken),
+		ExpiresUnix:        now.Add(oauth2AccessTokenLifetime).Unix(),
+		RefreshExpiresUnix: now.Add(oauth2RefreshTokenLifetime).Unix(),
+	}
+	err = tx.Create(t).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "create access token")
+	}
+	return &OAuth2Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    oauth2AccessTokenLifetime,
+		Scopes:       scopes,
+		id:           t.ID,
+	}, nil
+}
+
+// verifyCodeChallenge returns true if the code verifier matches the S256 PKCE
+// code challenge.
+func verifyCodeChallenge(codeChallenge, codeVerifier string) bool {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(codeChallenge)) == 1
+}
+
+// ExchangeAuthorizationCode exchanges the authorization code issued to the
+// OAuth2 application for tokens. The code can only be exchanged once, and the
+// tokens issued for it are revoked when it is presented again, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2. It returns
+// ErrInvalidOAuth2Grant when the code is not valid, has been used, has
+// expired, or the redirect URI or the PKCE code verifier does not match.
+func (s *OAuth2Store) ExchangeAuthorizationCode(ctx context.Context, app *OAuth2Application, code, redirectURI, codeVerifier string) (*OAuth2Token, error) {
+	var token *OAuth2Token
+	reused := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		authCode := new(OAuth2AuthorizationCode)
+		err := tx.Where("sha256 = ?", cryptox.SHA256(code)).First(authCode).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code does not exist"}}
+		} else if err != nil {
+			return errors.Wrap(err, "get code")
+		}
+
+		if authCode.AccessTokenID > 0 {
+			// The code may have been intercepted, revoke the tokens issued for it
+			// and keep the code to revoke the tokens issued later.
+			reused = true
+			return tx.Where("id = ?", authCode.AccessTokenID).Delete(new(AccessToken)).Error
+		}
+
+		grant := new(OAuth2Grant)
+		err = tx.Where("id = ?", authCode.GrantID).First(grant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "grant has been revoked"}}
+		} else if err != nil {
+			return errors.Wrap(err, "get grant")
+		}
+
+		switch {
+		case grant.ApplicationID != app.ID:
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code is issued to another application"}}
+		case authCode.ExpiresUnix <= tx.NowFunc().Unix():
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code has expired"}}
+		case authCode.RedirectURI != redirectURI:
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "redirect URI mismatch"}}
+		case authCode.CodeChallenge != "" && !verifyCodeChallenge(authCode.CodeChallenge, codeVerifier):
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code verifier mismatch"}}
+		}
+
+		token, err = createOAuth2Token(tx, grant, app, authCode.Scopes)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(authCode).
+			Where("access_token_id = 0").
+			Update("access_token_id", token.id)
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "mark code as used")
+		} else if result.RowsAffected == 0 {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code has been used"}}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if reused {
+		return nil, ErrInvalidOAuth2Grant{args: errx.Args{"reason": "code has been used"}}
+	}
+	return token, nil
+}
+
+// RefreshToken exchanges the refresh token issued to the OAuth2 application for
+// a new pair of tokens, and revokes the old pair. It returns
+// ErrInvalidOAuth2Grant when the refresh token is not valid or has expired.
+func (s *OAuth2Store) RefreshToken(ctx context.Context, app *OAuth2Application, refreshToken string) (*OAuth2Token, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidOAuth2Grant{args: errx.Args{"reason": "empty refresh token"}}
+	}
+
+	var token *OAuth2Token
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := new(AccessToken)
+		err := tx.Where("grant_id > 0 AND refresh_sha256 = ?", cryptox.SHA256(refreshToken)).First(old).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "refresh token does not exist"}}
+		} else if err != nil {
+			return errors.Wrap(err, "get access token")
+		}
+
+		grant := new(OAuth2Grant)
+		err = tx.Where("id = ?", old.GrantID).First(grant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "grant has been revoked"}}
+		} else if err != nil {
+			return errors.Wrap(err, "get grant")
+		}
+
+		switch {
+		case grant.ApplicationID != app.ID:
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "refresh token is issued to another application"}}
+		case old.RefreshExpiresUnix <= tx.NowFunc().Unix():
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "refresh token has expired"}}
+		}
+
+		result := tx.Where("id = ?", old.ID).Delete(new(AccessToken))
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "delete access token")
+		} else if result.RowsAffected == 0 {
+			return ErrInvalidOAuth2Grant{args: errx.Args{"reason": "refresh token has been used"}}
+		}
+
+		token, err = createOAuth2Token(tx, grant, app, old.Scopes)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(new(OAuth2AuthorizationCode)).
+			Where("access_token_id = ?", old.ID).
+			Update("access_token_id", token.id).
+			Error
+		if err != nil {
+			return errors.Wrap(err, "update code")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,396 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/errx"
+)
+
+func TestOAuth2Grant_HasScopes(t *testing.T) {
+	grant := &OAuth2Grant{Scopes: "read:repo read:user"}
+	assert.True(t, grant.HasScopes([]AccessTokenScope{AccessTokenScopeReadRepo}))
+	assert.True(t, grant.HasScopes([]AccessTokenScope{AccessTokenScopeReadUser, AccessTokenScopeReadRepo}))
+	assert.False(t, grant.HasScopes([]AccessTokenScope{AccessTokenScopeReadRepo, AccessTokenScopeWriteRepo}))
+}
+
+func TestOAuth2(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &OAuth2Store{
+		db: newTestDB(t, "OAuth2Store"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *OAuth2Store)
+	}{
+		{"CreateApplication", oauth2CreateApplication},
+		{"UpdateApplication", oauth2UpdateApplication},
+		{"RegenerateClientSecret", oauth2RegenerateClientSecret},
+		{"DeleteApplication", oauth2DeleteApplication},
+		{"CreateAuthorizationCode", oauth2CreateAuthorizationCode},
+		{"ExchangeAuthorizationCode", oauth2ExchangeAuthorizationCode},
+		{"RefreshToken", oauth2RefreshToken},
+		{"RevokeGrant", oauth2RevokeGrant},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+func oauth2CreateApplication(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, clientSecret, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "Dashboard",
+		RedirectURIs: []string{" https://dashboard.example.com/callback", "", "http://127.0.0.1:8080/callback"},
+		Confidential: true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, app.ClientID, 32)
+	assert.Len(t, clientSecret, 40)
+	assert.True(t, app.VerifyClientSecret(clientSecret))
+	assert.False(t, app.VerifyClientSecret("bad_secret"))
+
+	got, err := s.GetApplicationByClientID(ctx, app.ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, "Dashboard", got.Name)
+	assert.Equal(t, []string{"https://dashboard.example.com/callback", "http://127.0.0.1:8080/callback"}, got.RedirectURIList())
+	assert.True(t, got.HasRedirectURI("http://127.0.0.1:8080/callback"))
+	assert.False(t, got.HasRedirectURI("http://127.0.0.1:8080/callback/evil"))
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), got.Created.UTC().Format(time.RFC3339))
+
+	// Applications are only visible to their owners
+	_, err = s.GetApplicationByID(ctx, 2, app.ID)
+	wantErr := ErrOAuth2ApplicationNotExist{args: errx.Args{"userID": int64(2), "id": app.ID}}
+	assert.Equal(t, wantErr, err)
+
+	apps, err := s.ListApplications(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, apps, 1)
+
+	for _, redirectURIs := range [][]string{
+		nil,
+		{"/callback"},
+		{"https://dashboard.example.com/callback#fragment"},
+	} {
+		_, _, err = s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{Name: "Bad", RedirectURIs: redirectURIs})
+		assert.True(t, IsErrInvalidOAuth2Application(err), "redirect URIs %v", redirectURIs)
+	}
+}
+
+func oauth2UpdateApplication(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, _, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "Dashboard",
+		RedirectURIs: []string{"https://dashboard.example.com/callback"},
+	})
+	require.NoError(t, err)
+
+	// Updating with a wrong owner does nothing
+	err = s.UpdateApplication(ctx, 2, app.ID, UpdateOAuth2ApplicationOptions{
+		Name:         "Evil",
+		RedirectURIs: []string{"https://evil.example.com/callback"},
+	})
+	require.NoError(t, err)
+
+	err = s.UpdateApplication(ctx, 1, app.ID, UpdateOAuth2ApplicationOptions{
+		Name:         "CI dashboard",
+		RedirectURIs: []string{"https://ci.example.com/callback"},
+		Confidential: true,
+	})
+	require.NoError(t, err)
+
+	got, err := s.GetApplicationByID(ctx, 1, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "CI dashboard", got.Name)
+	assert.Equal(t, []string{"https://ci.example.com/callback"}, got.RedirectURIList())
+	assert.True(t, got.Confidential)
+}
+
+func oauth2RegenerateClientSecret(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, oldSecret, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "Dashboard",
+		RedirectURIs: []string{"https://dashboard.example.com/callback"},
+	})
+	require.NoError(t, err)
+
+	newSecret, err := s.RegenerateClientSecret(ctx, 1, app.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldSecret, newSecret)
+
+	got, err := s.GetApplicationByID(ctx, 1, app.ID)
+	require.NoError(t, err)
+	assert.False(t, got.VerifyClientSecret(oldSecret))
+	assert.True(t, got.VerifyClientSecret(newSecret))
+}
+
+// oauth2Authorize creates an application for user 1, authorizes it on behalf
+// of user 2 and exchanges the code for tokens.
+func oauth2Authorize(t *testing.T, ctx context.Context, s *OAuth2Store) (*OAuth2Application, *OAuth2Token) {
+	app, _, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "Dashboard",
+		RedirectURIs: []string{"https://dashboard.example.com/callback"},
+		Confidential: true,
+	})
+	require.NoError(t, err)
+
+	code, err := s.CreateAuthorizationCode(ctx, 2, app, CreateOAuth2AuthorizationCodeOptions{
+		Scopes:      []AccessTokenScope{AccessTokenScopeReadRepo},
+		RedirectURI: "https://dashboard.example.com/callback",
+	})
+	require.NoError(t, err)
+	token, err := s.ExchangeAuthorizationCode(ctx, app, code, "https://dashboard.example.com/callback", "")
+	require.NoError(t, err)
+	return app, token
+}
+
+func oauth2DeleteApplication(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, token := oauth2Authorize(t, ctx, s)
+
+	err := s.DeleteApplication(ctx, 1, app.ID)
+	require.NoError(t, err)
+
+	_, err = s.GetApplicationByID(ctx, 1, app.ID)
+	assert.True(t, IsErrOAuth2ApplicationNotExist(err))
+	_, err = newAccessTokensStore(s.db).GetBySHA1(ctx, token.AccessToken)
+	assert.True(t, IsErrAccessTokenNotExist(err))
+	grants, err := s.ListGrants(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	// Deleting a nonexistent application is a no-op
+	err = s.DeleteApplication(ctx, 1, app.ID)
+	require.NoError(t, err)
+}
+
+func oauth2CreateAuthorizationCode(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, _, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "Dashboard",
+		RedirectURIs: []string{"https://dashboard.example.com/callback"},
+	})
+	require.NoError(t, err)
+
+	_, err = s.CreateAuthorizationCode(ctx, 2, app, CreateOAuth2AuthorizationCodeOptions{
+		Scopes: []AccessTokenScope{"write:everything"},
+	})
+	assert.True(t, IsErrInvalidAccessTokenScope(err))
+	_, err = s.CreateAuthorizationCode(ctx, 2, app, CreateOAuth2AuthorizationCodeOptions{})
+	assert.True(t, IsErrInvalidAccessTokenScope(err))
+
+	// Scopes are added to the existing grant
+	for _, scope := range []AccessTokenScope{AccessTokenScopeReadRepo, AccessTokenScopeReadUser, AccessTokenScopeReadRepo} {
+		_, err = s.CreateAuthorizationCode(ctx, 2, app, CreateOAuth2AuthorizationCodeOptions{
+			Scopes: []AccessTokenScope{scope},
+		})
+		require.NoError(t, err)
+	}
+
+	grant, err := s.GetGrant(ctx, 2, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "read:repo read:user", grant.Scopes)
+
+	grants, err := s.ListGrants(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "Dashboard", grants[0].Application.Name)
+
+	_, err = s.GetGrant(ctx, 3, app.ID)
+	assert.True(t, IsErrOAuth2GrantNotExist(err))
+}
+
+func oauth2ExchangeAuthorizationCode(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, token := oauth2Authorize(t, ctx, s)
+	assert.Len(t, token.AccessToken, 40)
+	assert.NotEmpty(t, token.RefreshToken)
+	assert.Equal(t, time.Hour, token.ExpiresIn)
+	assert.Equal(t, "read:repo", token.Scopes)
+
+	// The access token works like a personal access token limited to the
+	// granted scopes, but is not listed along with them.
+	accessTokensStore := newAccessTokensStore(s.db)
+	accessToken, err := accessTokensStore.GetBySHA1(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), accessToken.UserID)
+	assert.True(t, accessToken.HasScope(AccessTokenScopeReadRepo))
+	assert.False(t, accessToken.HasScope(AccessTokenScopeWriteRepo))
+	assert.False(t, accessToken.IsExpired())
+	tokens, err := accessTokensStore.List(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	other, _, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "CLI",
+		RedirectURIs: []string{"http://127.0.0.1:8080/callback"},
+	})
+	require.NoError(t, err)
+
+	codeVerifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	newCode := func() string {
+		code, err := s.CreateAuthorizationCode(ctx, 2, other, CreateOAuth2AuthorizationCodeOptions{
+			Scopes:        []AccessTokenScope{AccessTokenScopeReadUser},
+			RedirectURI:   "http://127.0.0.1:8080/callback",
+			CodeChallenge: codeChallenge,
+		})
+		require.NoError(t, err)
+		return code
+	}
+
+	t.Run("success", func(t *testing.T) {
+		code := newCode()
+		token, err := s.ExchangeAuthorizationCode(ctx, other, code, "http://127.0.0.1:8080/callback", codeVerifier)
+		require.NoError(t, err)
+		assert.Equal(t, "read:user", token.Scopes)
+
+		// The code can only be used once, and the tokens issued for it are revoked
+		// when it is used again.
+		_, err = s.ExchangeAuthorizationCode(ctx, other, code, "http://127.0.0.1:8080/callback", codeVerifier)
+		assert.True(t, IsErrInvalidOAuth2Grant(err))
+		_, err = accessTokensStore.GetBySHA1(ctx, token.AccessToken)
+		assert.True(t, IsErrAccessTokenNotExist(err))
+	})
+
+	t.Run("reused after refresh", func(t *testing.T) {
+		code := newCode()
+		token, err := s.ExchangeAuthorizationCode(ctx, other, code, "http://127.0.0.1:8080/callback", codeVerifier)
+		require.NoError(t, err)
+		refreshed, err := s.RefreshToken(ctx, other, token.RefreshToken)
+		require.NoError(t, err)
+
+		_, err = s.ExchangeAuthorizationCode(ctx, other, code, "http://127.0.0.1:8080/callback", codeVerifier)
+		assert.True(t, IsErrInvalidOAuth2Grant(err))
+		_, err = accessTokensStore.GetBySHA1(ctx, refreshed.AccessToken)
+		assert.True(t, IsErrAccessTokenNotExist(err))
+		_, err = s.RefreshToken(ctx, other, refreshed.RefreshToken)
+		assert.True(t, IsErrInvalidOAuth2Grant(err))
+	})
+
+	tests := []struct {
+		name         string
+		app          *OAuth2Application
+		redirectURI  string
+		codeVerifier string
+		setup        func(code string)
+	}{
+		{
+			name:         "another application",
+			app:          app,
+			redirectURI:  "http://127.0.0.1:8080/callback",
+			codeVerifier: codeVerifier,
+		},
+		{
+			name:         "redirect URI mismatch",
+			app:          other,
+			redirectURI:  "http://127.0.0.1:8080/other",
+			codeVerifier: codeVerifier,
+		},
+		{
+			name:         "code verifier mismatch",
+			app:          other,
+			redirectURI:  "http://127.0.0.1:8080/callback",
+			codeVerifier: "wrong",
+		},
+		{
+			name:         "expired",
+			app:          other,
+			redirectURI:  "http://127.0.0.1:8080/callback",
+			codeVerifier: codeVerifier,
+			setup: func(string) {
+				err := s.db.Model(new(OAuth2AuthorizationCode)).Where("grant_id > 0").Update("expires_unix", 1).Error
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := newCode()
+			if test.setup != nil {
+				test.setup(code)
+			}
+			_, err := s.ExchangeAuthorizationCode(ctx, test.app, code, test.redirectURI, test.codeVerifier)
+			assert.True(t, IsErrInvalidOAuth2Grant(err), "got %v", err)
+		})
+	}
+}
+
+func oauth2RefreshToken(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, token := oauth2Authorize(t, ctx, s)
+
+	refreshed, err := s.RefreshToken(ctx, app, token.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.AccessToken, refreshed.AccessToken)
+	assert.NotEqual(t, token.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, "read:repo", refreshed.Scopes)
+
+	// The old pair is revoked
+	accessTokensStore := newAccessTokensStore(s.db)
+	_, err = accessTokensStore.GetBySHA1(ctx, token.AccessToken)
+	assert.True(t, IsErrAccessTokenNotExist(err))
+	_, err = s.RefreshToken(ctx, app, token.RefreshToken)
+	assert.True(t, IsErrInvalidOAuth2Grant(err))
+	_, err = accessTokensStore.GetBySHA1(ctx, refreshed.AccessToken)
+	require.NoError(t, err)
+
+	// Refresh tokens are bound to their applications
+	other, _, err := s.CreateApplication(ctx, 1, CreateOAuth2ApplicationOptions{
+		Name:         "CLI",
+		RedirectURIs: []string{"http://127.0.0.1:8080/callback"},
+	})
+	require.NoError(t, err)
+	_, err = s.RefreshToken(ctx, other, refreshed.RefreshToken)
+	assert.True(t, IsErrInvalidOAuth2Grant(err))
+
+	// Expired access tokens are kept until the refresh token expires
+	err = s.db.Model(new(AccessToken)).Where("grant_id > 0").Update("expires_unix", 1).Error
+	require.NoError(t, err)
+	deleted, err := accessTokensStore.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	refreshed, err = s.RefreshToken(ctx, app, refreshed.RefreshToken)
+	require.NoError(t, err)
+
+	err = s.db.Model(new(AccessToken)).Where("grant_id > 0").Update("refresh_expires_unix", 1).Error
+	require.NoError(t, err)
+	_, err = s.RefreshToken(ctx, app, refreshed.RefreshToken)
+	assert.True(t, IsErrInvalidOAuth2Grant(err))
+}
+
+func oauth2RevokeGrant(t *testing.T, ctx context.Context, s *OAuth2Store) {
+	app, token := oauth2Authorize(t, ctx, s)
+	grant, err := s.GetGrant(ctx, 2, app.ID)
+	require.NoError(t, err)
+
+	// Only the user who has granted the access can revoke it
+	err = s.RevokeGrant(ctx, 1, grant.ID)
+	require.NoError(t, err)
+	_, err = s.GetGrant(ctx, 2, app.ID)
+	require.NoError(t, err)
+
+	err = s.RevokeGrant(ctx, 2, grant.ID)
+	require.NoError(t, err)
+	_, err = s.GetGrant(ctx, 2, app.ID)
+	assert.True(t, IsErrOAuth2GrantNotExist(err))
+	_, err = newAccessTokensStore(s.db).GetBySHA1(ctx, token.AccessToken)
+	assert.True(t, IsErrAccessTokenNotExist(err))
+	_, err = s.RefreshToken(ctx, app, token.RefreshToken)
+	assert.True(t, IsErrInvalidOAuth2Grant(err))
+}
@@ -25,8 +25,13 @@ func authorizedKeysPath() string {
 }
 
 // RewriteAuthorizedKeys rewrites the "authorized_keys" file under the SSH root
-// path with all public keys stored in the database.
+// path with all public keys stored in the database. It does nothing when
+// rewriting is disabled.
 func (s *PublicKeysStore) RewriteAuthorizedKeys() error {
+	if conf.SSH.DisableAuthorizedKeysRewrite {
+		return nil
+	}
+
 	sshOpLocker.Lock()
 	defer sshOpLocker.Unlock()
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
@@ -64,6 +66,14 @@ type PullRequest struct {
 	Merger         *User     `xorm:"-" json:"-" gorm:"-"`
 	Merged         time.Time `xorm:"-" json:"-" gorm:"-"`
 	MergedUnix     int64
+
+	// The user who enabled auto-merge, the pull request is merged by the user
+	// with AutoMergeStyle once it becomes mergeable.
+	AutoMergeUserID int64
+	AutoMergeStyle  MergeStyle `xorm:"VARCHAR(20)" gorm:"type:VARCHAR(20)"`
+	// The head commit when auto-merge was enabled, the pull request is not
+	// auto-merged if its head branch points to any other commit.
+	AutoMergeHeadCommitID string `xorm:"VARCHAR(40)" gorm:"type:VARCHAR(40)"`
 }
 
 func (pr *PullRequest) BeforeUpdate() {
@@ -181,22 +191,250 @@ func (pr *PullRequest) CanAutoMerge() bool {
 	return pr.Status == PullRequestStatusMergeable
 }
 
+// ProtectBranchCheck is the result of checking a pull request against the
+// protection options of its base branch.
+type ProtectBranchCheck struct {
+	protectBranch *ProtectBranch
+
+	// MissingContexts are the required status contexts that have not succeeded
+	// on the head commit.
+	MissingContexts   []string
+	Approvals         int
+	RequiredApprovals int
+}
+
+// Passed returns true if all protection conditions are met.
+func (c *ProtectBranchCheck) Passed() bool {
+	return len(c.MissingContexts) == 0 && c.Approvals >= c.RequiredApprovals
+}
+
+// CanOverride returns true if the user is in the whitelist of the protected
+// branch and thus allowed to merge without meeting the conditions.
+func (c *ProtectBranchCheck) CanOverride(u *User) bool {
+	return c.protectBranch.EnableWhitelist &&
+		IsUserInProtectBranchWhitelist(c.protectBranch.RepoID, u.ID, c.protectBranch.Name)
+}
+
+// String returns a summary of unmet conditions.
+func (c *ProtectBranchCheck) String() string {
+	var lines []string
+	if len(c.MissingContexts) > 0 {
+		lines = append(lines, "Required status checks not passed: "+strings.Join(c.MissingContexts, ", "))
+	}
+	if c.Approvals < c.RequiredApprovals {
+		lines = append(lines, fmt.Sprintf("Approvals: %d of %d", c.Approvals, c.RequiredApprovals))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckProtectBranch checks the pull request against the protection options of
+// its base branch, with required status checks of the given head commit. It
+// returns nil if the base branch is not protected.
+func (pr *PullRequest) CheckProtectBranch(headCommitID string) (*ProtectBranchCheck, error) {
+	protectBranch, err := GetProtectBranchOfRepoByName(pr.BaseRepoID, pr.BaseBranch)
+	if err != nil {
+		if IsErrBranchNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Newf("GetProtectBranchOfRepoByName: %v", err)
+	} else if !protectBranch.Protected {
+		return nil, nil
+	}
+
+	check := &ProtectBranchCheck{
+		protectBranch:     protectBranch,
+		RequiredApprovals: protectBranch.RequiredApprovals,
+	}
+
+	contexts := protectBranch.RequiredContexts()
+	if len(contexts) > 0 {
+		succeeded := make(map[string]bool)
+		if headCommitID != "" {
+			statuses, err := Handle.CommitStatuses().ListLatestBySHA(context.TODO(), pr.BaseRepoID, headCommitID)
+			if err != nil {
+				return nil, errors.Newf("list latest commit statuses: %v", err)
+			}
+			for _, status := range statuses {
+				succeeded[status.Context] = status.State == CommitStatusSuccess
+			}
+		}
+
+		for _, name := range contexts {
+			if !succeeded[name] {
+				check.MissingContexts = append(check.MissingContexts, name)
+			}
+		}
+	}
+
+	if check.RequiredApprovals > 0 {
+		if err = pr.LoadAttributes(); err != nil {
+			return nil, errors.Newf("LoadAttributes: %v", err)
+		} else if err = pr.LoadIssue(); err != nil {
+			return nil, errors.Newf("LoadIssue: %v", err)
+		}
+		check.Approvals, err = countApprovals(x, pr.BaseRepo, pr.Issue)
+		if err != nil {
+			return nil, errors.Newf("count approvals: %v", err)
+		}
+	}
+	return check, nil
+}
+
 // MergeStyle represents the approach to merge commits into base branch.
 type MergeStyle string
 
 const (
-	MergeStyleRegular MergeStyle = "create_merge_commit"
-	MergeStyleRebase  MergeStyle = "rebase_before_merging"
+	MergeStyleRegular         MergeStyle = "create_merge_commit"
+	MergeStyleRebase          MergeStyle = "rebase_before_merging"
+	MergeStyleSquash          MergeStyle = "squash"
+	MergeStyleFastForwardOnly MergeStyle = "fast_forward_only"
 )
 
-// Merge merges pull request to base repository.
-// FIXME: add repoWorkingPull make sure two merges does not happen at same time.
-func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle MergeStyle, commitDescription string) (err error) {
+type ErrMergeStyleNotAllowed struct {
+	args errx.Args
+}
+
+func IsErrMergeStyleNotAllowed(err error) bool {
+	return errors.As(err, &ErrMergeStyleNotAllowed{})
+}
+
+func (err ErrMergeStyleNotAllowed) Error() string {
+	return fmt.Sprintf("merge style is not allowed: %v", err.args)
+}
+
+type ErrNotFastForward struct {
+	args errx.Args
+}
+
+func IsErrNotFastForward(err error) bool {
+	return errors.As(err, &ErrNotFastForward{})
+}
+
+func (err ErrNotFastForward) Error() string {
+	return fmt.Sprintf("head branch is not a descendant of base branch: %v", err.args)
+}
+
+type ErrHeadCommitChanged struct {
+	args errx.Args
+}
+
+func IsErrHeadCommitChanged(err error) bool {
+	return errors.As(err, &ErrHeadCommitChanged{})
+}
+
+func (err ErrHeadCommitChanged) Error() string {
+	return fmt.Sprintf("head branch does not point to the expected commit: %v", err.args)
+}
+
+// SquashCommitMessage returns the default commit message for squashing given
+// commits of the pull request, which consists of the title and description of
+// the pull request, followed by a co-author trailer for each distinct author of
+// the commits other than the poster. Commits are expected in reverse
+// chronological order, and the issue must be loaded.
+func (pr *PullRequest) SquashCommitMessage(commits []*git.Commit) string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%s (#%d)", pr.Issue.Title, pr.Index))
+	if content := strings.TrimSpace(pr.Issue.Content); content != "" {
+		buf.WriteString("\n\n")
+		buf.WriteString(content)
+	}
+
+	seen := make(map[string]bool)
+	if pr.Issue.Poster != nil {
+		seen[strings.ToLower(pr.Issue.Poster.Email)] = true
+	}
+	coAuthors := make([]string, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		author := commits[i].Author
+		if author == nil || seen[strings.ToLower(author.Email)] {
+			continue
+		}
+		seen[strings.ToLower(author.Email)] = true
+		coAuthors = append(coAuthors, fmt.Sprintf("Co-authored-by: %s <%s>", author.Name, author.Email))
+	}
+	if len(coAuthors) > 0 {
+		buf.WriteString("\n\n")
+		buf.WriteString(strings.Join(coAuthors, "\n"))
+	}
+	return buf.String()
+}
+
+type ErrPullRequestHasMerged struct {
+	args errx.Args
+}
+
+func IsErrPullRequestHasMerged(err error) bool {
+	return errors.As(err, &ErrPullRequestHasMerged{})
+}
+
+func (err ErrPullRequestHasMerged) Error() string {
+	return fmt.Sprintf("pull request has already been merged: %v", err.args)
+}
+
+type ErrProtectBranchNotSatisfied struct {
+	args errx.Args
+}
+
+func IsErrProtectBranchNotSatisfied(err error) bool {
+	return errors.As(err, &ErrProtectBranchNotSatisfied{})
+}
+
+func (err ErrProtectBranchNotSatisfied) Error() string {
+	return fmt.Sprintf("protect branch conditions are not satisfied: %v", err.args)
+}
+
+// Merge merges pull request to base repository. It refuses to merge when the
+// protection conditions of the base branch are not met, unless overrideProtection
+// is true and the doer is in the whitelist of the branch, in which case the
+// override is recorded as a comment.
+//
+// Only the given head commit is merged, and ErrHeadCommitChanged is returned
+// when the head branch points to any other commit. The current head commit is
+// used when headCommitID is empty.
+//
+// The commitDescription is appended to the message of the merge commit for
+// MergeStyleRegular, and is the whole commit message for MergeStyleSquash,
+// which defaults to SquashCommitMessage when empty.
+//
+// Merges into the same base branch are serialized.
+func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle MergeStyle, commitDescription, headCommitID string, overrideProtection bool) (err error) {
 	ctx := context.TODO()
 
+	identity := baseBranchIdentity(pr.BaseRepoID, pr.BaseBranch)
+	pullMergePool.CheckIn(identity)
+	defer pullMergePool.CheckOut(identity)
+
+	// The pull request may have been merged by another merge while waiting.
+	merged, err := x.Where("id = ? AND has_merged = ?", pr.ID, true).Exist(new(PullRequest))
+	if err != nil {
+		return errors.Newf("check merged: %v", err)
+	} else if merged {
+		return ErrPullRequestHasMerged{args: errx.Args{"pullRequestID": pr.ID}}
+	}
+
+	if !pr.BaseRepo.IsMergeStyleAllowed(mergeStyle) {
+		return ErrMergeStyleNotAllowed{args: errx.Args{"repoID": pr.BaseRepoID, "style": mergeStyle}}
+	}
+
+	if headCommitID == "" {
+		headCommitID, err = pr.headCommitID()
+		if err != nil {
+			return errors.Newf("get head commit ID: %v", err)
+		}
+	}
+
+	check, err := pr.CheckProtectBranch(headCommitID)
+	if err != nil {
+		return errors.Newf("check protect branch: %v", err)
+	}
+	overridden := check != nil && !check.Passed()
+	if overridden && (!overrideProtection || !check.CanOverride(doer)) {
+		return ErrProtectBranchNotSatisfied{args: errx.Args{"pullRequestID": pr.ID, "branch": pr.BaseBranch}}
+	}
+
 	defer func() {
 		go HookQueue.Add(pr.BaseRepo.ID)
-		go AddTestPullRequestTask(doer, pr.BaseRepo.ID, pr.BaseBranch, false)
+		go AddTestPullRequestTask(doer, pr.BaseRepo.ID, pr.BaseBranch, "", false)
 	}()
 
 	sess := x.NewSession()
@@ -209,6 +447,18 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		return errors.Newf("Issue.changeStatus: %v", err)
 	}
 
+	if overridden {
+		if _, err = createComment(sess, &CreateCommentOptions{
+			Type:    CommentTypeOverrideProtection,
+			Doer:    doer,
+			Repo:    pr.Issue.Repo,
+			Issue:   pr.Issue,
+			Content: check.String(),
+		}); err != nil {
+			return errors.Newf("create override protection comment: %v", err)
+		}
+	}
+
 	headRepoPath := RepoPath(pr.HeadUserName, pr.HeadRepo.Name)
 	headGitRepo, err := git.Open(headRepoPath)
 	if err != nil {
@@ -249,11 +499,17 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		return errors.Newf("git fetch [%s -> %s]: %s", headRepoPath, tmpBasePath, stderr)
 	}
 
+	// The head branch may have been moved since the protection conditions were
+	// checked against the head commit.
 	remoteHeadBranch := "head_repo/" + pr.HeadBranch
-
-	// Check if merge style is allowed, reset to default style if not
-	if mergeStyle == MergeStyleRebase && !pr.BaseRepo.PullsAllowRebase {
-		mergeStyle = MergeStyleRegular
+	var stdout string
+	if stdout, stderr, err = process.ExecDir(-1, tmpBasePath,
+		fmt.Sprintf("PullRequest.Merge (git rev-parse): %s", tmpBasePath),
+		"git", "rev-parse", "--verify", "refs/remotes/"+remoteHeadBranch); err != nil {
+		return errors.Newf("git rev-parse [%s]: %v - %s", tmpBasePath, err, stderr)
+	}
+	if strings.TrimSpace(stdout) != headCommitID {
+		return ErrHeadCommitChanged{args: errx.Args{"pullRequestID": pr.ID, "commitID": headCommitID}}
 	}
 
 	switch mergeStyle {
@@ -306,6 +562,55 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 			return errors.Newf("git merge [%s]: %v - %s", tmpBasePath, err, stderr)
 		}
 
+	case MergeStyleSquash: // Squash all changes into a single commit
+
+		if commitDescription == "" {
+			commits, err := headGitRepo.RevList([]string{pr.MergeBase + "..." + headCommitID})
+			if err != nil {
+				return errors.Newf("list commits: %v", err)
+			}
+			commitDescription = pr.SquashCommitMessage(commits)
+		}
+
+		// Stage changes from head branch without creating any commit.
+		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
+			fmt.Sprintf("PullRequest.Merge (git merge --squash): %s", tmpBasePath),
+			"git", "merge", "--squash", "--end-of-options", remoteHeadBranch); err != nil {
+			return errors.Newf("git merge --squash [%s]: %v - %s", tmpBasePath, err, stderr)
+		}
+
+		// The squashed commit is authored by the poster of the pull request, who
+		// has made the changes.
+		author := doer
+		if pr.Issue.Poster != nil && pr.Issue.Poster.ID > 0 {
+			author = pr.Issue.Poster
+		}
+		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
+			fmt.Sprintf("PullRequest.Merge (git commit): %s", tmpBasePath),
+			"git", "commit", fmt.Sprintf("--author=%s <%s>", author.DisplayName(), author.Email),
+			"-m", commitDescription); err != nil {
+			return errors.Newf("git commit [%s]: %v - %s", tmpBasePath, err, stderr)
+		}
+
+	case MergeStyleFastForwardOnly: // Fast-forward base branch to head branch
+
+		// Exit code 1 means the base branch is not an ancestor of the head branch.
+		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
+			fmt.Sprintf("PullRequest.Merge (git merge-base --is-ancestor): %s", tmpBasePath),
+			"git", "merge-base", "--is-ancestor", "--end-of-options", pr.BaseBranch, remoteHeadBranch); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+				return ErrNotFastForward{args: errx.Args{"pullRequestID": pr.ID, "branch": pr.BaseBranch}}
+			}
+			return errors.Newf("git merge-base --is-ancestor [%s]: %v - %s", tmpBasePath, err, stderr)
+		}
+
+		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
+			fmt.Sprintf("PullRequest.Merge (git merge --ff-only): %s", tmpBasePath),
+			"git", "merge", "--ff-only", "--end-of-options", remoteHeadBranch); err != nil {
+			return errors.Newf("git merge --ff-only [%s]: %v - %s", tmpBasePath, err, stderr)
+		}
+
 	default:
 		return errors.Newf("unknown merge style: %s", mergeStyle)
 	}
@@ -315,11 +620,7 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		return errors.Newf("git push: %v", err)
 	}
 
-	pr.MergedCommitID, err = headGitRepo.BranchCommitID(pr.HeadBranch)
-	if err != nil {
-		return errors.Newf("get head branch %q commit ID: %v", pr.HeadBranch, err)
-	}
-
+	pr.MergedCommitID = headCommitID
 	pr.HasMerged = true
 	pr.Merged = time.Now()
 	pr.MergerID = doer.ID
@@ -365,8 +666,11 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		log.Error("Failed to get base branch %q commit: %v", pr.BaseBranch, err)
 		return nil
 	}
-	if mergeStyle == MergeStyleRegular {
+	switch mergeStyle {
+	case MergeStyleRegular:
 		commits = append([]*git.Commit{mergeCommit}, commits...)
+	case MergeStyleSquash:
+		commits = []*git.Commit{mergeCommit}
 	}
 
 	pcs, err := CommitsToPushCommits(commits).APIFormat(ctx, Handle.Users(), pr.BaseRepo.RepoPath(), pr.BaseRepo.HTMLURL())
@@ -770,8 +1074,9 @@ func addHeadRepoTasks(prs []*PullRequest) {
 }
 
 // AddTestPullRequestTask adds new test tasks by given head/base repository and head/base branch,
-// and generate new patch for testing as needed.
-func AddTestPullRequestTask(doer *User, repoID int64, branch string, isSync bool) {
+// and generate new patch for testing as needed. The newCommitID is the commit
+// pushed by the doer to the branch when isSync is true.
+func AddTestPullRequestTask(doer *User, repoID int64, branch, newCommitID string, isSync bool) {
 	log.Trace("AddTestPullRequestTask [head_repo_id: %d, head_branch: %s]: finding pull requests", repoID, branch)
 	prs, err := GetUnmergedPullRequestsByHeadInfo(repoID, branch)
 	if err != nil {
@@ -803,6 +1108,19 @@ func AddTestPullRequestTask(doer *User, repoID int64, branch string, isSync bool
 				}
 			}
 		}
+
+		// Auto-merge must not merge commits that the user who enabled it has
+		// not seen.
+		for _, pr := range prs {
+			if !pr.IsAutoMergeEnabled() {
+				continue
+			}
+			if pr.AutoMergeUserID != doer.ID {
+				pr.cancelAutoMerge(doer, "New commits were pushed by another user.")
+			} else {
+				pr.updateAutoMergeHeadCommit(newCommitID)
+			}
+		}
 	}
 
 	addHeadRepoTasks(prs)
@@ -860,6 +1178,7 @@ func TestPullRequests() {
 	// Update pull request status.
 	for _, pr := range prs {
 		pr.checkAndUpdateStatus()
+		pr.autoMerge()
 	}
 
 	// Start listening on new test requests.
@@ -878,6 +1197,7 @@ func TestPullRequests() {
 		}
 
 		pr.checkAndUpdateStatus()
+		pr.autoMerge()
 	}
 }
 
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/errx"
+)
+
+// IsAutoMergeEnabled returns true if auto-merge of the pull request is enabled.
+func (pr *PullRequest) IsAutoMergeEnabled() bool {
+	return pr.AutoMergeUserID != 0
+}
+
+// headCommitID returns the commit ID that the head branch currently points to.
+func (pr *PullRequest) headCommitID() (string, error) {
+	if pr.HeadRepo == nil {
+		return "", ErrRepoNotExist{args: errx.Args{"repoID": pr.HeadRepoID}}
+	}
+
+	headGitRepo, err := git.Open(pr.HeadRepo.RepoPath())
+	if err != nil {
+		return "", errors.Newf("open repository: %v", err)
+	}
+	return headGitRepo.BranchCommitID(pr.HeadBranch)
+}
+
+// CanBeMergedBy returns true if the user is allowed to have the pull request
+// merged automatically, i.e. the user has write access to the base repository,
+// or is the poster of the pull request and the base branch requires approvals
+// from users with write access, which the merge then waits for.
+func (pr *PullRequest) CanBeMergedBy(u *User) (bool, error) {
+	if err := pr.LoadAttributes(); err != nil {
+		return false, errors.Newf("load attributes: %v", err)
+	}
+	if Handle.Permissions().Authorize(context.TODO(), u.ID, pr.BaseRepoID, AccessModeWrite,
+		AccessModeOptions{
+			OwnerID: pr.BaseRepo.OwnerID,
+			Private: pr.BaseRepo.IsPrivate,
+		},
+	) {
+		return true, nil
+	}
+
+	if err := pr.LoadIssue(); err != nil {
+		return false, errors.Newf("load issue: %v", err)
+	} else if !pr.Issue.IsPoster(u.ID) {
+		return false, nil
+	}
+
+	protectBranch, err := GetProtectBranchOfRepoByName(pr.BaseRepoID, pr.BaseBranch)
+	if err != nil {
+		if IsErrBranchNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Newf("get protect branch: %v", err)
+	}
+	return protectBranch.Protected && protectBranch.RequiredApprovals > 0, nil
+}
+
+// EnableAutoMerge enables auto-merge of the pull request, which is then merged
+// by the doer with given merge style once it becomes mergeable and protection
+// conditions of the base branch are met. Only the current head commit is
+// merged, new commits pushed by other users cancel auto-merge.
+func (pr *PullRequest) EnableAutoMerge(doer *User, mergeStyle MergeStyle) (err error) {
+	if err = pr.LoadAttributes(); err != nil {
+		return errors.Newf("load attributes: %v", err)
+	} else if err = pr.LoadIssue(); err != nil {
+		return errors.Newf("load issue: %v", err)
+	}
+	if !pr.BaseRepo.IsMergeStyleAllowed(mergeStyle) {
+		return ErrMergeStyleNotAllowed{args: errx.Args{"repoID": pr.BaseRepoID, "style": mergeStyle}}
+	}
+
+	headCommitID, err := pr.headCommitID()
+	if err != nil {
+		return errors.Newf("get head commit ID: %v", err)
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	pr.AutoMergeUserID = doer.ID
+	pr.AutoMergeStyle = mergeStyle
+	pr.AutoMergeHeadCommitID = headCommitID
+	if _, err = sess.ID(pr.ID).Cols("auto_merge_user_id", "auto_merge_style", "auto_merge_head_commit_id").Update(pr); err != nil {
+		return errors.Newf("update pull request: %v", err)
+	}
+
+	if _, err = createComment(sess, &CreateCommentOptions{
+		Type:    CommentTypeAutoMergeEnabled,
+		Doer:    doer,
+		Repo:    pr.BaseRepo,
+		Issue:   pr.Issue,
+		Content: string(mergeStyle),
+	}); err != nil {
+		return errors.Newf("create comment: %v", err)
+	}
+
+	if err = sess.Commit(); err != nil {
+		return err
+	}
+
+	// Test the pull request right away in case it is already mergeable.
+	pr.AddToTaskQueue()
+	return nil
+}
+
+// CancelAutoMerge cancels auto-merge of the pull request, and leaves a comment
+// with the reason (if any) on the pull request.
+func (pr *PullRequest) CancelAutoMerge(doer *User, reason string) (err error) {
+	if !pr.IsAutoMergeEnabled() {
+		return nil
+	}
+
+	if err = pr.LoadAttributes(); err != nil {
+		return errors.Newf("load attributes: %v", err)
+	} else if err = pr.LoadIssue(); err != nil {
+		return errors.Newf("load issue: %v", err)
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	pr.AutoMergeUserID = 0
+	pr.AutoMergeStyle = ""
+	pr.AutoMergeHeadCommitID = ""
+	if _, err = sess.ID(pr.ID).Cols("auto_merge_user_id", "auto_merge_style", "auto_merge_head_commit_id").Update(pr); err != nil {
+		return errors.Newf("update pull request: %v", err)
+	}
+
+	if _, err = createComment(sess, &CreateCommentOptions{
+		Type:    CommentTypeAutoMergeCanceled,
+		Doer:    doer,
+		Repo:    pr.BaseRepo,
+		Issue:   pr.Issue,
+		Content: reason,
+	}); err != nil {
+		return errors.Newf("create comment: %v", err)
+	}
+
+	return sess.Commit()
+}
+
+// updateAutoMergeHeadCommit records the head commit of the pull request with
+// auto-merge enabled, after it was pushed by the user who enabled auto-merge.
+// The head branch may have been moved again by other users since, so the
+// pushed commit is recorded rather than the current head commit.
+func (pr *PullRequest) updateAutoMergeHeadCommit(headCommitID string) {
+	// Only update when auto-merge is still enabled by the same user.
+	if _, err := x.Where("id = ? AND auto_merge_user_id = ?", pr.ID, pr.AutoMergeUserID).
+		Cols("auto_merge_head_commit_id").
+		Update(&PullRequest{AutoMergeHeadCommitID: headCommitID}); err != nil {
+		log.Error("Failed to update auto-merge head commit of pull request %d: %v", pr.ID, err)
+		return
+	}
+	pr.AutoMergeHeadCommitID = headCommitID
+}
+
+// AddAutoMergeTasks adds open pull requests of the base repository that have
+// auto-merge enabled to the test task queue, so that they are merged once the
+// protection conditions of the base branch are met, e.g. after a commit status
+// is reported.
+func AddAutoMergeTasks(baseRepoID int64) {
+	prs := make([]*PullRequest, 0, 2)
+	err := x.Where("base_repo_id=? AND has_merged=? AND auto_merge_user_id!=0 AND issue.is_closed=?",
+		baseRepoID, false, false).
+		Join("INNER", "issue", "issue.id=pull_request.issue_id").Find(&prs)
+	if err != nil {
+		log.Error("Failed to find auto-merge pull requests [base_repo_id: %d]: %v", baseRepoID, err)
+		return
+	}
+	for _, pr := range prs {
+		pr.AddToTaskQueue()
+	}
+}
+
+// cancelAutoMerge is like CancelAutoMerge but only logs the error.
+func (pr *PullRequest) cancelAutoMerge(doer *User, reason string) {
+	log.Trace("PullRequest[%d] auto-merge canceled: %s", pr.ID, reason)
+	if err := pr.CancelAutoMerge(doer, reason); err != nil {
+		log.Error("Failed to cancel auto-merge of pull request %d: %v", pr.ID, err)
+	}
+}
+
+// autoMerge merges the pull request if auto-merge is enabled and it is ready to
+// be merged, i.e. it is mergeable and protection conditions of the base branch
+// are met. The pull request is added to the merge queue instead when the merge
+// queue is enabled for the base repository.
+func (pr *PullRequest) autoMerge() {
+	if !pr.IsAutoMergeEnabled() || !pr.CanAutoMerge() || PullRequestQueue.Exist(pr.ID) {
+		return
+	}
+
+	if err := pr.LoadIssue(); err != nil {
+		log.Error("Failed to load issue of pull request %d: %v", pr.ID, err)
+		return
+	} else if err = pr.LoadAttributes(); err != nil {
+		log.Error("Failed to load attributes of pull request %d: %v", pr.ID, err)
+		return
+	} else if err = pr.BaseRepo.GetOwner(); err != nil {
+		log.Error("Failed to get owner of repository %d: %v", pr.BaseRepoID, err)
+		return
+	}
+	if pr.HasMerged || pr.Issue.IsClosed {
+		return
+	}
+	pr.Issue.Repo = pr.BaseRepo
+
+	doer, err := getUserByID(x, pr.AutoMergeUserID)
+	if err != nil {
+		if IsErrUserNotExist(err) {
+			pr.cancelAutoMerge(NewGhostUser(), "The user who enabled auto-merge no longer exists.")
+		} else {
+			log.Error("Failed to get user %d: %v", pr.AutoMergeUserID, err)
+		}
+		return
+	}
+
+	canMerge, err := pr.CanBeMergedBy(doer)
+	if err != nil {
+		log.Error("Failed to check if pull request %d can be merged by user %d: %v", pr.ID, doer.ID, err)
+		return
+	} else if !canMerge {
+		pr.cancelAutoMerge(doer, "The user who enabled auto-merge is no longer allowed to merge this pull request.")
+		return
+	} else if pr.HeadRepo == nil {
+		pr.cancelAutoMerge(doer, "The head repository of this pull request no longer exists.")
+		return
+	}
+
+	// Cancellation of auto-merge for new commits pushed by other users happens
+	// asynchronously, and the user who enabled auto-merge may have pushed new
+	// commits that are yet to be recorded, so keep waiting for the push to be
+	// processed if the head branch has moved.
+	headCommitID, err := pr.headCommitID()
+	if err != nil {
+		log.Error("Failed to get head commit ID of pull request %d: %v", pr.ID, err)
+		return
+	} else if headCommitID != pr.AutoMergeHeadCommitID {
+		log.Trace("PullRequest[%d] auto-merge waiting: head commit changed from %s to %s", pr.ID, pr.AutoMergeHeadCommitID, headCommitID)
+		return
+	}
+
+	// Keep waiting until protection conditions are met, e.g. required status
+	// checks have succeeded.
+	check, err := pr.CheckProtectBranch(headCommitID)
+	if err != nil {
+		log.Error("Failed to check protect branch of pull request %d: %v", pr.ID, err)
+		return
+	} else if check != nil && !check.Passed() {
+		return
+	}
+
+	if pr.BaseRepo.PullsEnableMergeQueue {
+		// The merge queue only merges the recorded head commit as well.
+		if err = AddToMergeQueue(doer, pr, pr.AutoMergeStyle, "", pr.AutoMergeHeadCommitID); err != nil {
+			if IsErrMergeStyleNotAllowed(err) {
+				pr.cancelAutoMerge(doer, "The selected merge style is no longer allowed in this repository.")
+			} else {
+				log.Error("Failed to add pull request %d to merge queue: %v", pr.ID, err)
+			}
+			return
+		}
+
+		pr.AutoMergeUserID = 0
+		pr.AutoMergeStyle = ""
+		pr.AutoMergeHeadCommitID = ""
+		if err = pr.UpdateCols("auto_merge_user_id", "auto_merge_style", "auto_merge_head_commit_id"); err != nil {
+			log.Error("Failed to disable auto-merge of pull request %d: %v", pr.ID, err)
+		}
+		return
+	}
+
+	baseGitRepo, err := git.Open(pr.BaseRepo.RepoPath())
+	if err != nil {
+		log.Error("Failed to open repository %d: %v", pr.BaseRepoID, err)
+		return
+	}
+
+	// The head branch may still be moved after the check above, so the merge
+	// itself is also restricted to the recorded head commit.
+	err = pr.Merge(doer, baseGitRepo, pr.AutoMergeStyle, "", pr.AutoMergeHeadCommitID, false)
+	switch {
+	case err == nil:
+		log.Trace("PullRequest[%d] auto-merged", pr.ID)
+	case IsErrPullRequestHasMerged(err), IsErrProtectBranchNotSatisfied(err), IsErrHeadCommitChanged(err):
+	case IsErrMergeStyleNotAllowed(err):
+		pr.cancelAutoMerge(doer, "The selected merge style is no longer allowed in this repository.")
+	case IsErrNotFastForward(err):
+		pr.cancelAutoMerge(doer, "The base branch cannot be fast-forwarded to the head branch of this pull request.")
+	default:
+		log.Error("Failed to auto-merge pull request %d: %v", pr.ID, err)
+		pr.cancelAutoMerge(doer, "This pull request failed to be merged.")
+	}
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// runGit runs the Git command in the directory and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=alice", "-c", "user.email=alice@example.com", "-c", "init.defaultBranch=main"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+type autoMergeTest struct {
+	engine *xorm.Engine
+	// The working tree that pushes to the repository.
+	work  string
+	alice *User
+	bob   *User
+	pr    *PullRequest
+	// Another open pull request into the same base branch, which is added to
+	// the test task queue after a merge.
+	other *PullRequest
+}
+
+// setupAutoMergeTest creates a repository owned by alice with a mergeable pull
+// request from "feature" to "main".
+func setupAutoMergeTest(t *testing.T) *autoMergeTest {
+	root := t.TempDir()
+	conf.SetMockRepository(t, conf.RepositoryOpts{Root: root})
+	conf.SetMockServer(t, conf.ServerOpts{AppDataPath: t.TempDir()})
+	t.Setenv("GIT_COMMITTER_NAME", "gogs")
+	t.Setenv("GIT_COMMITTER_EMAIL", "gogs@example.com")
+
+	engine := setTestDB(t)
+	alice := &User{LowerName: "alice", Name: "alice", Email: "alice@example.com"}
+	bob := &User{LowerName: "bob", Name: "bob", Email: "bob@example.com"}
+	_, err := engine.Insert(alice, bob)
+	require.NoError(t, err)
+	repo := &Repository{
+		OwnerID:               alice.ID,
+		LowerName:             "repo",
+		Name:                  "repo",
+		PullsAllowMergeCommit: true,
+	}
+	_, err = engine.Insert(repo)
+	require.NoError(t, err)
+
+	work := t.TempDir()
+	runGit(t, work, "init")
+	runGit(t, work, "commit", "--allow-empty", "-m", "initial")
+	runGit(t, work, "checkout", "-b", "feature")
+	// The patch of the pull request must not be empty to be mergeable.
+	err = os.WriteFile(filepath.Join(work, "feature.txt"), []byte("feature"), 0o644)
+	require.NoError(t, err)
+	runGit(t, work, "add", "feature.txt")
+	runGit(t, work, "commit", "-m", "feature")
+	runGit(t, work, "checkout", "-b", "other", "main")
+	runGit(t, work, "commit", "--allow-empty", "-m", "other")
+	repoPath := filepath.Join(root, "alice", "repo.git")
+	runGit(t, work, "clone", "--bare", work, repoPath)
+	runGit(t, work, "remote", "add", "origin", repoPath)
+
+	newPullRequest := func(index int64, headBranch string) *PullRequest {
+		issue := &Issue{RepoID: repo.ID, Index: index, PosterID: alice.ID, IsPull: true}
+		_, err := engine.Insert(issue)
+		require.NoError(t, err)
+		pr := &PullRequest{
+			Status:       PullRequestStatusMergeable,
+			IssueID:      issue.ID,
+			Index:        index,
+			HeadRepoID:   repo.ID,
+			BaseRepoID:   repo.ID,
+			HeadUserName: alice.Name,
+			HeadBranch:   headBranch,
+			BaseBranch:   "main",
+		}
+		_, err = engine.Insert(pr)
+		require.NoError(t, err)
+		return pr
+	}
+	return &autoMergeTest{
+		engine: engine,
+		work:   work,
+		alice:  alice,
+		bob:    bob,
+		pr:     newPullRequest(1, "feature"),
+		other:  newPullRequest(2, "other"),
+	}
+}
+
+// push pushes a new commit to the head branch of the pull request, and returns
+// the commit ID.
+func (s *autoMergeTest) push(t *testing.T) string {
+	runGit(t, s.work, "checkout", "feature")
+	runGit(t, s.work, "commit", "--allow-empty", "-m", "more")
+	runGit(t, s.work, "push", "origin", "feature")
+	return runGit(t, s.work, "rev-parse", "HEAD")
+}
+
+// reload returns the pull request as stored in the database.
+func (s *autoMergeTest) reload(t *testing.T) *PullRequest {
+	pr, err := GetPullRequestByID(s.pr.ID)
+	require.NoError(t, err)
+	return pr
+}
+
+// waitTaskQueue waits for the pull request to be added to the test task queue
+// by a background task, and removes it from the queue.
+func waitTaskQueue(t *testing.T, pr *PullRequest) {
+	assert.Eventually(t, func() bool {
+		return PullRequestQueue.Exist(pr.ID)
+	}, 10*time.Second, 10*time.Millisecond)
+	PullRequestQueue.Remove(pr.ID)
+}
+
+func TestPullRequest_AutoMerge(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Run("enable records the head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		headCommitID := runGit(t, s.work, "rev-parse", "feature")
+
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		got := s.reload(t)
+		assert.Equal(t, s.alice.ID, got.AutoMergeUserID)
+		assert.Equal(t, MergeStyleRegular, got.AutoMergeStyle)
+		assert.Equal(t, headCommitID, got.AutoMergeHeadCommitID)
+
+		has, err := s.engine.Where("type = ?", CommentTypeAutoMergeEnabled).Exist(new(Comment))
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+
+	t.Run("push by another user cancels", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		headCommitID := s.push(t)
+		AddTestPullRequestTask(s.bob, s.pr.HeadRepoID, s.pr.HeadBranch, headCommitID, true)
+		waitTaskQueue(t, s.pr)
+
+		got := s.reload(t)
+		assert.False(t, got.IsAutoMergeEnabled())
+		assert.Empty(t, got.AutoMergeHeadCommitID)
+
+		has, err := s.engine.Where("type = ?", CommentTypeAutoMergeCanceled).Exist(new(Comment))
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+
+	t.Run("push by the same user updates the head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		headCommitID := s.push(t)
+		AddTestPullRequestTask(s.alice, s.pr.HeadRepoID, s.pr.HeadBranch, headCommitID, true)
+		waitTaskQueue(t, s.pr)
+
+		got := s.reload(t)
+		assert.True(t, got.IsAutoMergeEnabled())
+		assert.Equal(t, headCommitID, got.AutoMergeHeadCommitID)
+	})
+
+	t.Run("push records the pushed commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		// The head branch has been moved again before the push is processed.
+		headCommitID := s.push(t)
+		s.push(t)
+		AddTestPullRequestTask(s.alice, s.pr.HeadRepoID, s.pr.HeadBranch, headCommitID, true)
+		waitTaskQueue(t, s.pr)
+
+		got := s.reload(t)
+		assert.True(t, got.IsAutoMergeEnabled())
+		assert.Equal(t, headCommitID, got.AutoMergeHeadCommitID)
+	})
+
+	t.Run("merge queue records the head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		_, err := s.engine.ID(s.pr.BaseRepoID).Cols("pulls_enable_merge_queue").Update(&Repository{PullsEnableMergeQueue: true})
+		require.NoError(t, err)
+		err = s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		pr := s.reload(t)
+		headCommitID := pr.AutoMergeHeadCommitID
+		pr.Status = PullRequestStatusMergeable
+		pr.autoMerge()
+		identity := baseBranchIdentity(pr.BaseRepoID, pr.BaseBranch)
+		assert.Eventually(t, func() bool {
+			return MergeQueue.Exist(identity)
+		}, 5*time.Second, 10*time.Millisecond)
+		MergeQueue.Remove(identity)
+
+		entry := &MergeQueueEntry{PullRequestID: pr.ID}
+		has, err := s.engine.Get(entry)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, headCommitID, entry.HeadCommitID)
+		assert.False(t, s.reload(t).IsAutoMergeEnabled())
+	})
+
+	t.Run("merge on success", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		pr := s.reload(t)
+		pr.Status = PullRequestStatusMergeable
+		pr.autoMerge()
+		// Wait for the background task triggered by the merge to finish.
+		waitTaskQueue(t, s.other)
+
+		got := s.reload(t)
+		assert.True(t, got.HasMerged)
+		assert.Equal(t, pr.AutoMergeHeadCommitID, got.MergedCommitID)
+	})
+
+	t.Run("changed head commit is not merged", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		err := s.pr.EnableAutoMerge(s.alice, MergeStyleRegular)
+		require.NoError(t, err)
+		waitTaskQueue(t, s.pr)
+
+		// The push has not been processed yet.
+		s.push(t)
+
+		pr := s.reload(t)
+		pr.Status = PullRequestStatusMergeable
+		pr.autoMerge()
+
+		got := s.reload(t)
+		assert.False(t, got.HasMerged)
+		assert.True(t, got.IsAutoMergeEnabled())
+	})
+}
+
+func TestPullRequest_CanBeMergedBy(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	tests := []struct {
+		name              string
+		poster            func(s *autoMergeTest) *User
+		user              func(s *autoMergeTest) *User
+		requiredApprovals int
+		want              bool
+	}{
+		{
+			name:   "user with write access",
+			poster: func(s *autoMergeTest) *User { return s.bob },
+			user:   func(s *autoMergeTest) *User { return s.alice },
+			want:   true,
+		},
+		{
+			name:   "poster without write access",
+			poster: func(s *autoMergeTest) *User { return s.bob },
+			user:   func(s *autoMergeTest) *User { return s.bob },
+			want:   false,
+		},
+		{
+			name:              "poster without write access when approvals are required",
+			poster:            func(s *autoMergeTest) *User { return s.bob },
+			user:              func(s *autoMergeTest) *User { return s.bob },
+			requiredApprovals: 1,
+			want:              true,
+		},
+		{
+			name:              "other user without write access",
+			poster:            func(s *autoMergeTest) *User { return s.alice },
+			user:              func(s *autoMergeTest) *User { return s.bob },
+			requiredApprovals: 1,
+			want:              false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := setupAutoMergeTest(t)
+			_, err := s.engine.ID(s.pr.IssueID).Cols("poster_id").Update(&Issue{PosterID: test.poster(s).ID})
+			require.NoError(t, err)
+			if test.requiredApprovals > 0 {
+				_, err = s.engine.Insert(&ProtectBranch{
+					RepoID:            s.pr.BaseRepoID,
+					Name:              s.pr.BaseBranch,
+					Protected:         true,
+					RequiredApprovals: test.requiredApprovals,
+				})
+				require.NoError(t, err)
+			}
+
+			got, err := s.reload(t).CanBeMergedBy(test.user(s))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
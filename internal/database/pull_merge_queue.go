@@ -0,0 +1,390 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/errx"
+	"gogs.io/gogs/internal/sync"
+)
+
+// MergeQueue is a queue of base branches which have pull requests waiting in
+// the merge queue to be processed.
+var MergeQueue = sync.NewUniqueQueue(1000)
+
+// pullMergePool makes sure only one merge into the same base branch happens at
+// a time.
+var pullMergePool = sync.NewExclusivePool()
+
+// baseBranchIdentity returns the identity of the base branch with given name in
+// the repository, which is used for both MergeQueue and pullMergePool.
+func baseBranchIdentity(repoID int64, branch string) string {
+	return strconv.FormatInt(repoID, 10) + "/" + branch
+}
+
+// MergeQueueEntry is a pull request waiting in the merge queue of its base
+// branch. Entries of the same base branch are processed in the order of IDs.
+type MergeQueueEntry struct {
+	ID            int64
+	RepoID        int64  `xorm:"INDEX(s)"`
+	BaseBranch    string `xorm:"INDEX(s)"`
+	PullRequestID int64  `xorm:"UNIQUE"`
+	// The user who added the pull request to the merge queue, who is also
+	// the merger of the pull request.
+	DoerID        int64
+	MergeStyle    MergeStyle `xorm:"VARCHAR(20)"`
+	CommitMessage string     `xorm:"TEXT"`
+	// The head commit of the pull request when it was added to the merge queue,
+	// which is the only commit to be merged.
+	HeadCommitID string `xorm:"VARCHAR(40)"`
+	// The number of attempts to process the entry that failed due to transient
+	// errors, e.g. the database is temporarily unavailable.
+	Attempts int `xorm:"NOT NULL DEFAULT 0"`
+
+	Created     time.Time `xorm:"-" json:"-" gorm:"-"`
+	CreatedUnix int64
+}
+
+func (e *MergeQueueEntry) BeforeInsert() {
+	e.CreatedUnix = time.Now().Unix()
+}
+
+func (e *MergeQueueEntry) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		e.Created = time.Unix(e.CreatedUnix, 0).Local()
+	}
+}
+
+type ErrMergeQueueDisabled struct {
+	args errx.Args
+}
+
+func IsErrMergeQueueDisabled(err error) bool {
+	return errors.As(err, &ErrMergeQueueDisabled{})
+}
+
+func (err ErrMergeQueueDisabled) Error() string {
+	return fmt.Sprintf("merge queue is disabled: %v", err.args)
+}
+
+// AddToMergeQueue adds the pull request to the merge queue of its base branch,
+// to be merged by the doer with given merge style and commit description (see
+// Merge) once all pull requests ahead of it are processed. Only the given head
+// commit is merged, which defaults to the current head commit when empty. It
+// does nothing if the pull request is already in the merge queue.
+func AddToMergeQueue(doer *User, pr *PullRequest, mergeStyle MergeStyle, commitDescription, headCommitID string) (err error) {
+	if err = pr.LoadAttributes(); err != nil {
+		return errors.Newf("load attributes: %v", err)
+	}
+	if !pr.BaseRepo.PullsEnableMergeQueue {
+		return ErrMergeQueueDisabled{args: errx.Args{"repoID": pr.BaseRepoID}}
+	}
+	if !pr.BaseRepo.IsMergeStyleAllowed(mergeStyle) {
+		return ErrMergeStyleNotAllowed{args: errx.Args{"repoID": pr.BaseRepoID, "style": mergeStyle}}
+	}
+	if headCommitID == "" {
+		headCommitID, err = pr.headCommitID()
+		if err != nil {
+			return errors.Newf("get head commit ID: %v", err)
+		}
+	}
+
+	has, err := x.Get(&MergeQueueEntry{PullRequestID: pr.ID})
+	if err != nil {
+		return errors.Newf("get entry: %v", err)
+	} else if has {
+		return nil
+	}
+
+	if _, err = x.Insert(&MergeQueueEntry{
+		RepoID:        pr.BaseRepoID,
+		BaseBranch:    pr.BaseBranch,
+		PullRequestID: pr.ID,
+		DoerID:        doer.ID,
+		MergeStyle:    mergeStyle,
+		CommitMessage: commitDescription,
+		HeadCommitID:  headCommitID,
+	}); err != nil {
+		return errors.Newf("insert entry: %v", err)
+	}
+
+	go MergeQueue.Add(baseBranchIdentity(pr.BaseRepoID, pr.BaseBranch))
+	return nil
+}
+
+// RemoveFromMergeQueue removes the pull request with given ID from the merge
+// queue.
+func RemoveFromMergeQueue(pullRequestID int64) error {
+	_, err := x.Delete(&MergeQueueEntry{PullRequestID: pullRequestID})
+	return err
+}
+
+// GetMergeQueuePosition returns the 1-based position of the pull request in the
+// merge queue of its base branch, or 0 if it is not in the merge queue.
+func GetMergeQueuePosition(pr *PullRequest) (int64, error) {
+	entry := new(MergeQueueEntry)
+	has, err := x.Where("pull_request_id = ?", pr.ID).Get(entry)
+	if err != nil {
+		return 0, errors.Newf("get entry: %v", err)
+	} else if !has {
+		return 0, nil
+	}
+
+	return x.Where("repo_id = ? AND base_branch = ? AND id <= ?", entry.RepoID, entry.BaseBranch, entry.ID).Count(new(MergeQueueEntry))
+}
+
+// ejectFromMergeQueue removes the entry from the merge queue, and leaves a
+// comment with the reason on the pull request.
+func ejectFromMergeQueue(entry *MergeQueueEntry, doer *User, pr *PullRequest, reason string) error {
+	log.Trace("PullRequest[%d] ejected from merge queue: %s", pr.ID, reason)
+	if err := RemoveFromMergeQueue(pr.ID); err != nil {
+		return errors.Newf("remove from merge queue: %v", err)
+	}
+	createMergeQueueEjectedComment(entry, doer, pr, reason)
+	return nil
+}
+
+// createMergeQueueEjectedComment leaves a comment with the reason of ejection on
+// the pull request of the entry.
+func createMergeQueueEjectedComment(entry *MergeQueueEntry, doer *User, pr *PullRequest, reason string) {
+	if _, err := CreateComment(&CreateCommentOptions{
+		Type:    CommentTypeMergeQueueEjected,
+		Doer:    doer,
+		Repo:    pr.BaseRepo,
+		Issue:   pr.Issue,
+		Content: reason,
+	}); err != nil {
+		log.Error("Failed to create merge queue ejection comment [entry_id: %d]: %v", entry.ID, err)
+	}
+}
+
+// processMergeQueueEntry re-tests the pull request of the entry against the
+// latest base branch, and merges it when there is no conflict. Entries that
+// cannot be merged are ejected from the merge queue. It returns an error if the
+// entry could not be processed due to a transient failure, in which case the
+// entry is still in the merge queue and should be retried later.
+func processMergeQueueEntry(entry *MergeQueueEntry) error {
+	pr, err := GetPullRequestByID(entry.PullRequestID)
+	if err != nil {
+		if !IsErrPullRequestNotExist(err) {
+			return errors.Newf("get pull request: %v", err)
+		}
+
+		if err = RemoveFromMergeQueue(entry.PullRequestID); err != nil {
+			return errors.Newf("remove from merge queue: %v", err)
+		}
+		return nil
+	}
+
+	if err = pr.LoadIssue(); err != nil {
+		return errors.Newf("load issue: %v", err)
+	} else if err = pr.LoadAttributes(); err != nil {
+		return errors.Newf("load attributes: %v", err)
+	} else if err = pr.BaseRepo.GetOwner(); err != nil {
+		return errors.Newf("get owner of base repository: %v", err)
+	}
+	pr.Issue.Repo = pr.BaseRepo
+
+	// Pull requests that have been merged or closed by other means are simply
+	// dropped.
+	if pr.HasMerged || pr.Issue.IsClosed {
+		if err = RemoveFromMergeQueue(pr.ID); err != nil {
+			return errors.Newf("remove from merge queue: %v", err)
+		}
+		return nil
+	}
+
+	doer, err := getUserByID(x, entry.DoerID)
+	if err != nil {
+		if !IsErrUserNotExist(err) {
+			return errors.Newf("get doer: %v", err)
+		}
+		return ejectFromMergeQueue(entry, NewGhostUser(), pr, "The user who added this pull request to the merge queue no longer exists.")
+	}
+
+	// Pull requests with auto-merge enabled by their posters are added to the
+	// merge queue on behalf of the posters.
+	canMerge, err := pr.CanBeMergedBy(doer)
+	if err != nil {
+		return errors.Newf("check if can be merged by doer: %v", err)
+	} else if !canMerge {
+		return ejectFromMergeQueue(entry, doer, pr, "The user who added this pull request to the merge queue is no longer allowed to merge it.")
+	} else if pr.HeadRepo == nil {
+		return ejectFromMergeQueue(entry, doer, pr, "The head repository of this pull request no longer exists.")
+	}
+
+	// Commits pushed after the pull request was added to the merge queue have not
+	// been reviewed by the doer. Entries added before the head commit was
+	// recorded have none.
+	headCommitID, err := pr.headCommitID()
+	if err != nil {
+		return errors.Newf("get head commit ID: %v", err)
+	} else if entry.HeadCommitID != "" && headCommitID != entry.HeadCommitID {
+		return ejectFromMergeQueue(entry, doer, pr, "New commits were pushed after this pull request was added to the merge queue.")
+	}
+
+	if err = pr.UpdatePatch(); err != nil {
+		log.Error("Failed to update patch of pull request %d: %v", pr.ID, err)
+		return ejectFromMergeQueue(entry, doer, pr, "The head branch of this pull request cannot be compared with the latest base branch.")
+	} else if err = pr.testPatch(); err != nil {
+		return errors.Newf("test patch: %v", err)
+	}
+	pr.checkAndUpdateStatus()
+	if pr.Status == PullRequestStatusConflict {
+		return ejectFromMergeQueue(entry, doer, pr, "This pull request conflicts with the latest base branch.")
+	}
+
+	baseGitRepo, err := git.Open(pr.BaseRepo.RepoPath())
+	if err != nil {
+		return errors.Newf("open base repository: %v", err)
+	}
+
+	// The merge checks the protection conditions of the base branch against the
+	// head commit, and refuses to merge if the head branch has moved since.
+	err = pr.Merge(doer, baseGitRepo, entry.MergeStyle, entry.CommitMessage, headCommitID, false)
+	switch {
+	case err == nil, IsErrPullRequestHasMerged(err):
+	case IsErrProtectBranchNotSatisfied(err):
+		return ejectFromMergeQueue(entry, doer, pr, "This pull request does not meet the branch protection requirements of the base branch.")
+	case IsErrHeadCommitChanged(err):
+		return ejectFromMergeQueue(entry, doer, pr, "New commits were pushed after this pull request was added to the merge queue.")
+	case IsErrMergeStyleNotAllowed(err):
+		return ejectFromMergeQueue(entry, doer, pr, fmt.Sprintf("The merge style %q is no longer allowed in this repository.", entry.MergeStyle))
+	case IsErrNotFastForward(err):
+		return ejectFromMergeQueue(entry, doer, pr, "The base branch cannot be fast-forwarded to the head branch of this pull request.")
+	default:
+		log.Error("Failed to merge pull request %d: %v", pr.ID, err)
+		return ejectFromMergeQueue(entry, doer, pr, "This pull request failed to be merged.")
+	}
+
+	log.Trace("PullRequest[%d] merged from merge queue", pr.ID)
+	if err = RemoveFromMergeQueue(pr.ID); err != nil {
+		return errors.Newf("remove from merge queue: %v", err)
+	}
+	return nil
+}
+
+const (
+	// mergeQueueMaxAttempts is the maximum number of attempts to process an
+	// entry that keeps failing due to transient errors, before the entry is
+	// ejected from the merge queue.
+	mergeQueueMaxAttempts = 5
+	// mergeQueueMaxRetryDelay is the maximum delay before the next attempt.
+	mergeQueueMaxRetryDelay = time.Hour
+)
+
+// mergeQueueRetryDelay is the delay before the second attempt to process an
+// entry, which is doubled for every following attempt.
+var mergeQueueRetryDelay = time.Minute
+
+// retryMergeQueue schedules the merge queue of the base branch to be processed
+// again after the delay.
+func retryMergeQueue(repoID int64, branch string, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		MergeQueue.Add(baseBranchIdentity(repoID, branch))
+	})
+}
+
+// retryMergeQueueEntry schedules the merge queue of the base branch of the
+// entry that failed to be processed to be processed again after a backoff
+// delay. The entry is ejected once it has failed too many times, so that it
+// does not block the merge queue forever.
+func retryMergeQueueEntry(entry *MergeQueueEntry, cause error) {
+	entry.Attempts++
+	if entry.Attempts < mergeQueueMaxAttempts {
+		delay := mergeQueueRetryDelay << (entry.Attempts - 1)
+		if delay > mergeQueueMaxRetryDelay {
+			delay = mergeQueueMaxRetryDelay
+		}
+		log.Warn("Failed to process pull request %d in merge queue (attempt %d), retrying in %s: %v", entry.PullRequestID, entry.Attempts, delay, cause)
+
+		if _, err := x.ID(entry.ID).Cols("attempts").Update(entry); err != nil {
+			log.Error("Failed to update attempts of merge queue entry %d: %v", entry.ID, err)
+		}
+		retryMergeQueue(entry.RepoID, entry.BaseBranch, delay)
+		return
+	}
+
+	log.Error("Failed to process pull request %d in merge queue after %d attempts: %v", entry.PullRequestID, entry.Attempts, cause)
+	if err := RemoveFromMergeQueue(entry.PullRequestID); err != nil {
+		log.Error("Failed to remove pull request %d from merge queue: %v", entry.PullRequestID, err)
+		retryMergeQueue(entry.RepoID, entry.BaseBranch, mergeQueueMaxRetryDelay)
+		return
+	}
+
+	// The comment is left on a best-effort basis as the failure may prevent the
+	// pull request from being loaded at all.
+	pr, err := GetPullRequestByID(entry.PullRequestID)
+	if err == nil {
+		err = pr.LoadIssue()
+	}
+	if err == nil {
+		err = pr.LoadAttributes()
+	}
+	if err != nil {
+		log.Error("Failed to load pull request %d ejected from merge queue: %v", entry.PullRequestID, err)
+		return
+	}
+	doer, err := getUserByID(x, entry.DoerID)
+	if err != nil {
+		doer = NewGhostUser()
+	}
+	createMergeQueueEjectedComment(entry, doer, pr, "This pull request failed to be processed repeatedly.")
+}
+
+// processMergeQueue processes entries of the merge queue of the base branch one
+// at a time until the merge queue is empty.
+func processMergeQueue(repoID int64, branch string) {
+	for {
+		entry := new(MergeQueueEntry)
+		has, err := x.Where("repo_id = ? AND base_branch = ?", repoID, branch).Asc("id").Get(entry)
+		if err != nil {
+			log.Error("Failed to get merge queue entry [repo_id: %d, base_branch: %s]: %v", repoID, branch, err)
+			retryMergeQueue(repoID, branch, mergeQueueRetryDelay)
+			return
+		} else if !has {
+			return
+		}
+
+		// Entries behind the failed one must wait for it to be retried to keep
+		// the order of the merge queue.
+		if err = processMergeQueueEntry(entry); err != nil {
+			retryMergeQueueEntry(entry, err)
+			return
+		}
+	}
+}
+
+// ProcessMergeQueues processes merge queues of base branches.
+func ProcessMergeQueues() {
+	// Resume merge queues which were not finished before the last shutdown.
+	entries := make([]*MergeQueueEntry, 0, 10)
+	if err := x.Cols("repo_id", "base_branch").Distinct("repo_id", "base_branch").Find(&entries); err != nil {
+		log.Error("Failed to find merge queue entries: %v", err)
+	}
+	for _, entry := range entries {
+		go MergeQueue.Add(baseBranchIdentity(entry.RepoID, entry.BaseBranch))
+	}
+
+	for identity := range MergeQueue.Queue() {
+		log.Trace("ProcessMergeQueues[%s]: processing merge queue", identity)
+		MergeQueue.Remove(identity)
+
+		repoID, branch, _ := strings.Cut(identity, "/")
+		id, _ := strconv.ParseInt(repoID, 10, 64)
+		processMergeQueue(id, branch)
+	}
+}
+
+func InitMergeQueues() {
+	go ProcessMergeQueues()
+}
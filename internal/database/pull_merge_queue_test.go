@@ -0,0 +1,217 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"xorm.io/xorm"
+)
+
+// setupMergeQueueEntry creates a pull request from "feature" to "main" in a
+// new repository, and adds it to the merge queue of the base branch.
+func setupMergeQueueEntry(t *testing.T, engine *xorm.Engine, pr *PullRequest) *MergeQueueEntry {
+	owner := &User{LowerName: "alice", Name: "alice", Email: "alice@example.com"}
+	_, err := engine.Insert(owner)
+	require.NoError(t, err)
+	repo := &Repository{OwnerID: owner.ID, LowerName: "repo", Name: "repo", PullsEnableMergeQueue: true}
+	_, err = engine.Insert(repo)
+	require.NoError(t, err)
+	issue := &Issue{RepoID: repo.ID, Index: 1, PosterID: owner.ID, IsPull: true}
+	_, err = engine.Insert(issue)
+	require.NoError(t, err)
+
+	pr.IssueID = issue.ID
+	pr.Index = issue.Index
+	if pr.HeadRepoID == 0 {
+		pr.HeadRepoID = repo.ID
+	}
+	pr.BaseRepoID = repo.ID
+	pr.HeadBranch = "feature"
+	pr.BaseBranch = "main"
+	_, err = engine.Insert(pr)
+	require.NoError(t, err)
+
+	entry := &MergeQueueEntry{
+		RepoID:        repo.ID,
+		BaseBranch:    pr.BaseBranch,
+		PullRequestID: pr.ID,
+		DoerID:        owner.ID,
+		MergeStyle:    MergeStyleRegular,
+	}
+	_, err = engine.Insert(entry)
+	require.NoError(t, err)
+	return entry
+}
+
+func TestProcessMergeQueue(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	before := mergeQueueRetryDelay
+	mergeQueueRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		mergeQueueRetryDelay = before
+	})
+
+	t.Run("merged pull request is dropped", func(t *testing.T) {
+		engine := setTestDB(t)
+		entry := setupMergeQueueEntry(t, engine, &PullRequest{HasMerged: true})
+
+		processMergeQueue(entry.RepoID, entry.BaseBranch)
+
+		count, err := engine.Count(new(MergeQueueEntry))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("permanent failure ejects the pull request", func(t *testing.T) {
+		engine := setTestDB(t)
+		entry := setupMergeQueueEntry(t, engine, &PullRequest{HeadRepoID: 404})
+
+		processMergeQueue(entry.RepoID, entry.BaseBranch)
+
+		count, err := engine.Count(new(MergeQueueEntry))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		comment := new(Comment)
+		has, err := engine.Where("type = ?", CommentTypeMergeQueueEjected).Get(comment)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, "The head repository of this pull request no longer exists.", comment.Content)
+	})
+
+	t.Run("doer without write access is ejected", func(t *testing.T) {
+		engine := setTestDB(t)
+		entry := setupMergeQueueEntry(t, engine, &PullRequest{})
+		bob := &User{LowerName: "bob", Name: "bob", Email: "bob@example.com"}
+		_, err := engine.Insert(bob)
+		require.NoError(t, err)
+		_, err = engine.ID(entry.ID).Cols("doer_id").Update(&MergeQueueEntry{DoerID: bob.ID})
+		require.NoError(t, err)
+
+		processMergeQueue(entry.RepoID, entry.BaseBranch)
+
+		count, err := engine.Count(new(MergeQueueEntry))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		comment := new(Comment)
+		has, err := engine.Where("type = ?", CommentTypeMergeQueueEjected).Get(comment)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, "The user who added this pull request to the merge queue is no longer allowed to merge it.", comment.Content)
+	})
+
+	t.Run("transient failure is retried", func(t *testing.T) {
+		engine := setTestDB(t)
+		entry := setupMergeQueueEntry(t, engine, &PullRequest{})
+		identity := baseBranchIdentity(entry.RepoID, entry.BaseBranch)
+		t.Cleanup(func() {
+			MergeQueue.Remove(identity)
+		})
+
+		// Make loading the pull request fail.
+		_, err := engine.Exec("DROP TABLE pull_request")
+		require.NoError(t, err)
+
+		processMergeQueue(entry.RepoID, entry.BaseBranch)
+
+		got := new(MergeQueueEntry)
+		has, err := engine.ID(entry.ID).Get(got)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, 1, got.Attempts)
+
+		assert.Eventually(t, func() bool {
+			return MergeQueue.Exist(identity)
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("pull request is ejected after too many attempts", func(t *testing.T) {
+		engine := setTestDB(t)
+		entry := setupMergeQueueEntry(t, engine, &PullRequest{})
+		_, err := engine.ID(entry.ID).Cols("attempts").Update(&MergeQueueEntry{Attempts: mergeQueueMaxAttempts - 1})
+		require.NoError(t, err)
+
+		_, err = engine.Exec("DROP TABLE pull_request")
+		require.NoError(t, err)
+
+		processMergeQueue(entry.RepoID, entry.BaseBranch)
+
+		count, err := engine.Count(new(MergeQueueEntry))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestAddToMergeQueue(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	// addToMergeQueue adds the pull request to the merge queue of its base
+	// branch with the head commit, and waits for the base branch to be
+	// scheduled for processing.
+	addToMergeQueue := func(t *testing.T, s *autoMergeTest, headCommitID string) {
+		_, err := s.engine.ID(s.pr.BaseRepoID).Cols("pulls_enable_merge_queue").Update(&Repository{PullsEnableMergeQueue: true})
+		require.NoError(t, err)
+
+		pr := s.loadForMerge(t)
+		err = AddToMergeQueue(s.alice, pr, MergeStyleRegular, "", headCommitID)
+		require.NoError(t, err)
+
+		identity := baseBranchIdentity(pr.BaseRepoID, pr.BaseBranch)
+		assert.Eventually(t, func() bool {
+			return MergeQueue.Exist(identity)
+		}, 5*time.Second, 10*time.Millisecond)
+		MergeQueue.Remove(identity)
+	}
+
+	t.Run("record the current head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		addToMergeQueue(t, s, "")
+
+		entry := &MergeQueueEntry{PullRequestID: s.pr.ID}
+		has, err := s.engine.Get(entry)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, runGit(t, s.work, "rev-parse", "feature"), entry.HeadCommitID)
+	})
+
+	t.Run("merge the recorded head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		headCommitID := runGit(t, s.work, "rev-parse", "feature")
+		addToMergeQueue(t, s, headCommitID)
+
+		processMergeQueue(s.pr.BaseRepoID, s.pr.BaseBranch)
+		// Wait for the background task triggered by the merge to finish.
+		waitTaskQueue(t, s.other)
+
+		got := s.reload(t)
+		assert.True(t, got.HasMerged)
+		assert.Equal(t, headCommitID, got.MergedCommitID)
+	})
+
+	t.Run("changed head commit is ejected", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		addToMergeQueue(t, s, runGit(t, s.work, "rev-parse", "feature"))
+		s.push(t)
+
+		processMergeQueue(s.pr.BaseRepoID, s.pr.BaseBranch)
+
+		assert.False(t, s.reload(t).HasMerged)
+		count, err := s.engine.Count(new(MergeQueueEntry))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		comment := new(Comment)
+		has, err := s.engine.Where("type = ?", CommentTypeMergeQueueEjected).Get(comment)
+		require.NoError(t, err)
+		require.True(t, has)
+		assert.Equal(t, "New commits were pushed after this pull request was added to the merge queue.", comment.Content)
+	})
+}
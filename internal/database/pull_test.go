@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogs/git-module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtectBranchCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		check      *ProtectBranchCheck
+		wantPassed bool
+		wantString string
+	}{
+		{
+			name:       "no conditions",
+			check:      &ProtectBranchCheck{},
+			wantPassed: true,
+		},
+		{
+			name: "all met",
+			check: &ProtectBranchCheck{
+				Approvals:         2,
+				RequiredApprovals: 1,
+			},
+			wantPassed: true,
+		},
+		{
+			name: "missing contexts",
+			check: &ProtectBranchCheck{
+				MissingContexts: []string{"ci/build", "ci/test"},
+			},
+			wantPassed: false,
+			wantString: "Required status checks not passed: ci/build, ci/test",
+		},
+		{
+			name: "missing contexts and approvals",
+			check: &ProtectBranchCheck{
+				MissingContexts:   []string{"ci/build"},
+				Approvals:         1,
+				RequiredApprovals: 2,
+			},
+			wantPassed: false,
+			wantString: "Required status checks not passed: ci/build\nApprovals: 1 of 2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.wantPassed, test.check.Passed())
+			assert.Equal(t, test.wantString, test.check.String())
+		})
+	}
+}
+
+func TestPullRequest_SquashCommitMessage(t *testing.T) {
+	pr := &PullRequest{
+		Index: 7,
+		Issue: &Issue{
+			Title:   "Add squash merge",
+			Content: "Squash all the things.\n",
+			Poster:  &User{Email: "alice@example.com"},
+		},
+	}
+
+	t.Run("no co-authors", func(t *testing.T) {
+		commits := []*git.Commit{
+			{Author: &git.Signature{Name: "Alice", Email: "Alice@example.com"}},
+		}
+		want := "Add squash merge (#7)\n\nSquash all the things."
+		assert.Equal(t, want, pr.SquashCommitMessage(commits))
+	})
+
+	t.Run("co-authors in chronological order", func(t *testing.T) {
+		commits := []*git.Commit{
+			{Author: &git.Signature{Name: "Carol", Email: "carol@example.com"}},
+			{Author: &git.Signature{Name: "Bob", Email: "bob@example.com"}},
+			{Author: &git.Signature{Name: "Carol", Email: "carol@example.com"}},
+			{Author: &git.Signature{Name: "Alice", Email: "alice@example.com"}},
+		}
+		want := `Add squash merge (#7)
+
+Squash all the things.
+
+Co-authored-by: Carol <carol@example.com>
+Co-authored-by: Bob <bob@example.com>`
+		assert.Equal(t, want, pr.SquashCommitMessage(commits))
+	})
+}
+
+// loadForMerge returns the pull request as stored in the database with
+// attributes required by the merge loaded.
+func (s *autoMergeTest) loadForMerge(t *testing.T) *PullRequest {
+	pr := s.reload(t)
+	require.NoError(t, pr.LoadIssue())
+	require.NoError(t, pr.LoadAttributes())
+	require.NoError(t, pr.BaseRepo.GetOwner())
+	pr.Issue.Repo = pr.BaseRepo
+	return pr
+}
+
+func TestPullRequest_Merge(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	merge := func(t *testing.T, s *autoMergeTest, headCommitID string) error {
+		pr := s.loadForMerge(t)
+		baseGitRepo, err := git.Open(pr.BaseRepo.RepoPath())
+		require.NoError(t, err)
+		return pr.Merge(s.alice, baseGitRepo, MergeStyleRegular, "", headCommitID, false)
+	}
+
+	// requireStatus protects the base branch with the required status check
+	// "ci", which has succeeded on the commit.
+	requireStatus := func(t *testing.T, s *autoMergeTest, commitID string) {
+		_, err := s.engine.Insert(&ProtectBranch{
+			RepoID:                 s.pr.BaseRepoID,
+			Name:                   s.pr.BaseBranch,
+			Protected:              true,
+			RequiredStatusContexts: "ci",
+		})
+		require.NoError(t, err)
+
+		pr := s.loadForMerge(t)
+		_, err = Handle.CommitStatuses().Create(context.Background(), s.alice, pr.BaseRepo, commitID,
+			CreateCommitStatusOptions{State: CommitStatusSuccess, Context: "ci"},
+		)
+		require.NoError(t, err)
+	}
+
+	t.Run("changed head commit is not merged", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		headCommitID := runGit(t, s.work, "rev-parse", "feature")
+		s.push(t)
+
+		err := merge(t, s, headCommitID)
+		assert.True(t, IsErrHeadCommitChanged(err), "%v", err)
+		// Wait for the background task triggered by the merge to finish.
+		waitTaskQueue(t, s.pr)
+		waitTaskQueue(t, s.other)
+		assert.False(t, s.reload(t).HasMerged)
+	})
+
+	t.Run("status checks are required on the current head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		requireStatus(t, s, runGit(t, s.work, "rev-parse", "feature"))
+		s.push(t)
+
+		err := merge(t, s, "")
+		assert.True(t, IsErrProtectBranchNotSatisfied(err), "%v", err)
+		assert.False(t, s.reload(t).HasMerged)
+	})
+
+	t.Run("merge the given head commit", func(t *testing.T) {
+		s := setupAutoMergeTest(t)
+		headCommitID := runGit(t, s.work, "rev-parse", "feature")
+		requireStatus(t, s, headCommitID)
+
+		err := merge(t, s, headCommitID)
+		require.NoError(t, err)
+		// Wait for the background task triggered by the merge to finish.
+		waitTaskQueue(t, s.other)
+
+		got := s.reload(t)
+		assert.True(t, got.HasMerged)
+		assert.Equal(t, headCommitID, got.MergedCommitID)
+	})
+}
@@ -0,0 +1,332 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/cryptox"
+	"gogs.io/gogs/internal/errx"
+	"gogs.io/gogs/internal/netx"
+	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/sync"
+)
+
+// PushMirrorQueue is a queue of push mirrors to be pushed to.
+var PushMirrorQueue = sync.NewUniqueQueue(1000)
+
+// PushMirror is a remote repository that a repository is pushed to with "git
+// push --mirror" after every push and periodically.
+type PushMirror struct {
+	ID     int64 `gorm:"primaryKey"`
+	RepoID int64 `gorm:"index;not null"`
+	// The URL of the remote repository without credentials.
+	Address string `gorm:"type:TEXT;not null"`
+	// The "username:password" of the remote repository encrypted with the
+	// secret key and encoded in base64.
+	Credentials string `gorm:"type:TEXT"`
+	// Hours between periodical pushes, 0 disables periodical pushes.
+	SyncInterval int    `gorm:"not null"`
+	LastError    string `gorm:"type:TEXT"`
+
+	LastSync     time.Time `gorm:"-" json:"-"`
+	LastSyncUnix int64
+	NextSyncUnix int64     `gorm:"index"`
+	Created      time.Time `gorm:"-" json:"-"`
+	CreatedUnix  int64
+}
+
+// BeforeCreate implements the GORM create hook.
+func (m *PushMirror) BeforeCreate(tx *gorm.DB) error {
+	if m.CreatedUnix == 0 {
+		m.CreatedUnix = tx.NowFunc().Unix()
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (m *PushMirror) AfterFind(_ *gorm.DB) error {
+	m.Created = time.Unix(m.CreatedUnix, 0).Local()
+	if m.LastSyncUnix > 0 {
+		m.LastSync = time.Unix(m.LastSyncUnix, 0).Local()
+	}
+	return nil
+}
+
+// Username returns the username of the credentials of the push mirror, or an
+// empty string if there are none or they cannot be decrypted.
+func (m *PushMirror) Username() string {
+	username, _, err := m.credentials()
+	if err != nil {
+		log.Error("Failed to decrypt credentials of push mirror %d: %v", m.ID, err)
+		return ""
+	}
+	return username
+}
+
+func (m *PushMirror) credentials() (username, password string, err error) {
+	if m.Credentials == "" {
+		return "", "", nil
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(m.Credentials)
+	if err != nil {
+		return "", "", errors.Wrap(err, "decode")
+	}
+	decrypted, err := cryptox.AESGCMDecrypt(cryptox.MD5Bytes(conf.Security.SecretKey), encrypted)
+	if err != nil {
+		return "", "", errors.Wrap(err, "decrypt")
+	}
+	username, password, _ = strings.Cut(string(decrypted), ":")
+	return username, password, nil
+}
+
+// remoteURL returns the URL of the remote repository with credentials.
+func (m *PushMirror) remoteURL() (string, error) {
+	u, err := url.Parse(m.Address)
+	if err != nil {
+		return "", errors.Wrap(err, "parse address")
+	}
+
+	username, password, err := m.credentials()
+	if err != nil {
+		return "", errors.Wrap(err, "get credentials")
+	}
+	if username != "" || password != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return u.String(), nil
+}
+
+// PushMirrorsStore is the storage layer for push mirrors.
+type PushMirrorsStore struct {
+	db *gorm.DB
+}
+
+func newPushMirrorsStore(db *gorm.DB) *PushMirrorsStore {
+	return &PushMirrorsStore{db: db}
+}
+
+type CreatePushMirrorOptions struct {
+	Address      string
+	Username     string
+	Password     string
+	SyncInterval int
+}
+
+// parsePushMirrorAddress validates the address of a push mirror, and returns
+// the address without credentials along with credentials it contains.
+func parsePushMirrorAddress(address string) (addr, username, password string, err error) {
+	u, err := url.Parse(strings.TrimSpace(address))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", "", ErrInvalidCloneAddr{IsURLError: true}
+	} else if netx.IsBlockedLocalHostname(u.Hostname(), conf.Security.LocalNetworkAllowlist) {
+		return "", "", "", ErrInvalidCloneAddr{IsBlockedLocalAddress: true}
+	}
+
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+		u.User = nil
+	}
+	return u.String(), username, password, nil
+}
+
+// Create creates a new push mirror for the repository. Credentials contained in
+// the address are used when username and password are not given. It returns
+// ErrInvalidCloneAddr when the address is not a valid HTTP or HTTPS URL.
+func (s *PushMirrorsStore) Create(ctx context.Context, repoID int64, opts CreatePushMirrorOptions) (*PushMirror, error) {
+	address, username, password, err := parsePushMirrorAddress(opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Username != "" || opts.Password != "" {
+		username = opts.Username
+		password = opts.Password
+	}
+	if opts.SyncInterval < 0 {
+		opts.SyncInterval = 0
+	}
+
+	m := &PushMirror{
+		RepoID:       repoID,
+		Address:      address,
+		SyncInterval: opts.SyncInterval,
+	}
+	if username != "" || password != "" {
+		encrypted, err := cryptox.AESGCMEncrypt(cryptox.MD5Bytes(conf.Security.SecretKey), []byte(username+":"+password))
+		if err != nil {
+			return nil, errors.Wrap(err, "encrypt credentials")
+		}
+		m.Credentials = base64.StdEncoding.EncodeToString(encrypted)
+	}
+	if m.SyncInterval > 0 {
+		m.NextSyncUnix = s.db.NowFunc().Add(time.Duration(m.SyncInterval) * time.Hour).Unix()
+	}
+	return m, s.db.WithContext(ctx).Create(m).Error
+}
+
+var _ errx.NotFound = (*ErrPushMirrorNotExist)(nil)
+
+type ErrPushMirrorNotExist struct {
+	args errx.Args
+}
+
+// IsErrPushMirrorNotExist returns true if the underlying error has the type
+// ErrPushMirrorNotExist.
+func IsErrPushMirrorNotExist(err error) bool {
+	return errors.As(errors.Cause(err), &ErrPushMirrorNotExist{})
+}
+
+func (err ErrPushMirrorNotExist) Error() string {
+	return fmt.Sprintf("push mirror does not exist: %v", err.args)
+}
+
+func (ErrPushMirrorNotExist) NotFound() bool {
+	return true
+}
+
+// GetByID returns the push mirror with given ID of the repository. It returns
+// ErrPushMirrorNotExist when not found.
+func (s *PushMirrorsStore) GetByID(ctx context.Context, repoID, id int64) (*PushMirror, error) {
+	m := new(PushMirror)
+	err := s.db.WithContext(ctx).Where("id = ? AND repo_id = ?", id, repoID).First(m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPushMirrorNotExist{args: errx.Args{"repoID": repoID, "id": id}}
+	} else if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// List returns all push mirrors of the repository.
+func (s *PushMirrorsStore) List(ctx context.Context, repoID int64) ([]*PushMirror, error) {
+	var mirrors []*PushMirror
+	return mirrors, s.db.WithContext(ctx).Where("repo_id = ?", repoID).Order("id ASC").Find(&mirrors).Error
+}
+
+// ListDue returns all push mirrors whose next periodical push is due.
+func (s *PushMirrorsStore) ListDue(ctx context.Context) ([]*PushMirror, error) {
+	var mirrors []*PushMirror
+	return mirrors, s.db.WithContext(ctx).
+		Where("sync_interval > 0 AND next_sync_unix <= ?", s.db.NowFunc().Unix()).
+		Order("id ASC").
+		Find(&mirrors).
+		Error
+}
+
+// DeleteByID deletes the push mirror with given ID of the repository.
+func (s *PushMirrorsStore) DeleteByID(ctx context.Context, repoID, id int64) error {
+	return s.db.WithContext(ctx).Where("id = ? AND repo_id = ?", id, repoID).Delete(new(PushMirror)).Error
+}
+
+// UpdateSyncResult records the result of a push to the push mirror, and
+// schedules the next periodical push.
+func (s *PushMirrorsStore) UpdateSyncResult(ctx context.Context, m *PushMirror, lastError string) error {
+	now := s.db.NowFunc()
+	m.LastError = lastError
+	m.LastSync = now
+	m.LastSyncUnix = now.Unix()
+	m.NextSyncUnix = 0
+	if m.SyncInterval > 0 {
+		m.NextSyncUnix = now.Add(time.Duration(m.SyncInterval) * time.Hour).Unix()
+	}
+	return s.db.WithContext(ctx).
+		Model(new(PushMirror)).
+		Where("id = ?", m.ID).
+		Updates(map[string]any{
+			"last_error":     m.LastError,
+			"last_sync_unix": m.LastSyncUnix,
+			"next_sync_unix": m.NextSyncUnix,
+		}).
+		Error
+}
+
+// push pushes all references of the repository to the push mirror.
+func (m *PushMirror) push(repo *Repository) error {
+	// The address was validated when the push mirror was created, but DNS for
+	// that hostname may have changed in the meantime to point at an internal
+	// host.
+	u, err := url.Parse(m.Address)
+	if err != nil {
+		return errors.Wrap(err, "parse address")
+	} else if netx.IsBlockedLocalHostname(u.Hostname(), conf.Security.LocalNetworkAllowlist) {
+		return errors.Newf("address resolves to a blocked local address: %s", m.Address)
+	}
+
+	remoteURL, err := m.remoteURL()
+	if err != nil {
+		return err
+	}
+
+	repoPath := repo.RepoPath()
+	args := append(mirrorGitArgs(), "push", "--mirror", "--end-of-options", remoteURL)
+	_, stderr, err := process.ExecDirEnv(
+		time.Duration(conf.Git.Timeout.Mirror)*time.Second, repoPath, mirrorGitEnv(),
+		fmt.Sprintf("PushMirror.push: %s", repoPath),
+		"git", args...)
+	if err != nil {
+		// Make sure credentials are never leaked through the error message.
+		return errors.Newf("git push --mirror: %s", strings.ReplaceAll(stderr, remoteURL, m.Address))
+	}
+	return nil
+}
+
+// AddPushMirrorTasks adds all push mirrors of the repository to the push
+// mirror queue.
+func AddPushMirrorTasks(repoID int64) {
+	mirrors, err := Handle.PushMirrors().List(context.TODO(), repoID)
+	if err != nil {
+		log.Error("Failed to list push mirrors [repo_id: %d]: %v", repoID, err)
+		return
+	}
+	for _, m := range mirrors {
+		PushMirrorQueue.Add(m.ID)
+	}
+}
+
+// SyncPushMirrors pushes to push mirrors in the push mirror queue.
+func SyncPushMirrors() {
+	ctx := context.Background()
+	for mirrorID := range PushMirrorQueue.Queue() {
+		log.Trace("SyncPushMirrors [push_mirror_id: %s]", mirrorID)
+		PushMirrorQueue.Remove(mirrorID)
+
+		id, _ := strconv.ParseInt(mirrorID, 10, 64)
+		m := new(PushMirror)
+		if err := Handle.db.WithContext(ctx).First(m, id).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Error("Failed to get push mirror %d: %v", id, err)
+			}
+			continue
+		}
+
+		repo, err := GetRepositoryByID(m.RepoID)
+		if err != nil {
+			log.Error("Failed to get repository %d: %v", m.RepoID, err)
+			continue
+		}
+
+		var lastError string
+		if err = m.push(repo); err != nil {
+			lastError = err.Error()
+			log.Error("Failed to push to push mirror %d of repository %d: %v", m.ID, m.RepoID, err)
+		}
+		if err = Handle.PushMirrors().UpdateSyncResult(ctx, m, lastError); err != nil {
+			log.Error("Failed to update push mirror %d: %v", m.ID, err)
+		}
+	}
+}
+
+func InitSyncPushMirrors() {
+	go SyncPushMirrors()
+}
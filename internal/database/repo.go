@@ -203,20 +203,24 @@ type Repository struct {
 	*Mirror  `xorm:"-" gorm:"-" json:"-"`
 
 	// Advanced settings
-	EnableWiki            bool `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
-	AllowPublicWiki       bool
-	EnableExternalWiki    bool
-	ExternalWikiURL       string
-	EnableIssues          bool `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
-	AllowPublicIssues     bool
-	EnableExternalTracker bool
-	ExternalTrackerURL    string
-	ExternalTrackerFormat string
-	ExternalTrackerStyle  string
-	ExternalMetas         map[string]string `xorm:"-" gorm:"-" json:"-"`
-	EnablePulls           bool              `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
-	PullsIgnoreWhitespace bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
-	PullsAllowRebase      bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
+	EnableWiki                bool `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
+	AllowPublicWiki           bool
+	EnableExternalWiki        bool
+	ExternalWikiURL           string
+	EnableIssues              bool `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
+	AllowPublicIssues         bool
+	EnableExternalTracker     bool
+	ExternalTrackerURL        string
+	ExternalTrackerFormat     string
+	ExternalTrackerStyle      string
+	ExternalMetas             map[string]string `xorm:"-" gorm:"-" json:"-"`
+	EnablePulls               bool              `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
+	PullsIgnoreWhitespace     bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
+	PullsAllowMergeCommit     bool              `xorm:"NOT NULL DEFAULT true" gorm:"not null;default:TRUE"`
+	PullsAllowRebase          bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
+	PullsAllowSquash          bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
+	PullsAllowFastForwardOnly bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
+	PullsEnableMergeQueue     bool              `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
 
 	IsFork   bool `xorm:"NOT NULL DEFAULT false" gorm:"not null;default:FALSE"`
 	ForkID   int64
@@ -635,6 +639,34 @@ func (r *Repository) AllowsPulls() bool {
 	return r.CanEnablePulls() && r.EnablePulls
 }
 
+// IsMergeStyleAllowed returns true if pull requests of the repository can be
+// merged with given style.
+func (r *Repository) IsMergeStyleAllowed(style MergeStyle) bool {
+	switch style {
+	case MergeStyleRegular:
+		return r.PullsAllowMergeCommit
+	case MergeStyleRebase:
+		return r.PullsAllowRebase
+	case MergeStyleSquash:
+		return r.PullsAllowSquash
+	case MergeStyleFastForwardOnly:
+		return r.PullsAllowFastForwardOnly
+	}
+	return false
+}
+
+// AllowedMergeStyles returns the list of merge styles allowed for pull requests
+// of the repository, the first one is the default.
+func (r *Repository) AllowedMergeStyles() []MergeStyle {
+	styles := make([]MergeStyle, 0, 4)
+	for _, style := range []MergeStyle{MergeStyleRegular, MergeStyleRebase, MergeStyleSquash, MergeStyleFastForwardOnly} {
+		if r.IsMergeStyleAllowed(style) {
+			styles = append(styles, style)
+		}
+	}
+	return styles
+}
+
 func (r *Repository) IsBranchRequirePullRequest(name string) bool {
 	return IsBranchOfRepoRequirePullRequest(r.ID, name)
 }
@@ -776,6 +808,9 @@ type MigrateRepoOptions struct {
 	IsUnlisted  bool
 	IsMirror    bool
 	RemoteAddr  string
+	// Whether to fetch LFS objects from the remote repository, and on every
+	// sync when the repository is a mirror.
+	EnableLFS bool
 }
 
 /*
@@ -875,12 +910,18 @@ func MigrateRepository(doer, owner *User, opts MigrateRepoOptions) (*Repository,
 	}
 
 	if opts.IsMirror {
-		if _, err = x.InsertOne(&Mirror{
+		m := &Mirror{
 			RepoID:      repo.ID,
+			Repo:        repo,
 			Interval:    conf.Mirror.DefaultInterval,
 			EnablePrune: true,
+			EnableLFS:   opts.EnableLFS,
 			NextSync:    time.Now().Add(time.Duration(conf.Mirror.DefaultInterval) * time.Hour),
-		}); err != nil {
+		}
+		if m.EnableLFS {
+			m.syncLFS(opts.RemoteAddr, migrateTimeout)
+		}
+		if _, err = x.InsertOne(m); err != nil {
 			return repo, errors.Newf("InsertOne: %v", err)
 		}
 
@@ -888,6 +929,19 @@ func MigrateRepository(doer, owner *User, opts MigrateRepoOptions) (*Repository,
 		return repo, UpdateRepository(repo, false)
 	}
 
+	if opts.EnableLFS {
+		ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+		_, err = fetchMirrorLFSObjects(ctx, repo, opts.RemoteAddr)
+		cancel()
+		if err != nil {
+			desc := fmt.Sprintf("Failed to fetch LFS objects of migrated repository '%s': %v", repo.FullName(), err)
+			log.Error("MigrateRepository: %s", desc)
+			if err = Handle.Notices().Create(context.TODO(), NoticeTypeRepository, desc); err != nil {
+				log.Error("CreateRepositoryNotice: %v", err)
+			}
+		}
+	}
+
 	return CleanUpMigrateInfo(repo)
 }
 
@@ -1238,6 +1292,8 @@ func CreateRepository(doer, owner *User, opts CreateRepoOptionsLegacy) (_ *Repos
 		EnableWiki:   true,
 		EnableIssues: true,
 		EnablePulls:  true,
+
+		PullsAllowMergeCommit: true,
 	}
 
 	sess := x.NewSession()
@@ -1700,11 +1756,15 @@ func DeleteRepository(ownerID, repoID int64) error {
 		&Webhook{RepoID: repoID},
 		&HookTask{RepoID: repoID},
 		&LFSObject{RepoID: repoID},
+		&LFSLock{RepoID: repoID},
+		&CommitStatus{RepoID: repoID},
+		&MergeQueueEntry{RepoID: repoID},
+		&PushMirror{RepoID: repoID},
 	); err != nil {
 		return errors.Newf("deleteBeans: %v", err)
 	}
 
-	// Delete comments and attachments.
+	// Delete comments, reviews and attachments.
 	issues := make([]*Issue, 0, 25)
 	attachmentPaths := make([]string, 0, len(issues))
 	if err = sess.Where("repo_id=?", repoID).Find(&issues); err != nil {
@@ -1714,6 +1774,12 @@ func DeleteRepository(ownerID, repoID int64) error {
 		if _, err = sess.Delete(&Comment{IssueID: issues[i].ID}); err != nil {
 			return err
 		}
+		if _, err = sess.Delete(&Review{IssueID: issues[i].ID}); err != nil {
+			return err
+		}
+		if _, err = sess.Delete(&ReviewRequest{IssueID: issues[i].ID}); err != nil {
+			return err
+		}
 
 		attachments := make([]*Attachment, 0, 5)
 		if err = sess.Where("issue_id=?", issues[i].ID).Find(&attachments); err != nil {
@@ -2079,6 +2145,12 @@ const (
 	taskNameGitFSCK          = "git_fsck"
 	taskNameCheckRepoStats   = "check_repos_stats"
 	taskNameCleanOldArchives = "clean_old_archives"
+
+	taskNameLFSGarbageCollection      = "lfs_garbage_collection"
+	taskNameDeleteExpiredAccessTokens = "delete_expired_access_tokens"
+	taskNameSyncLDAPTeams             = "sync_ldap_teams"
+	taskNameAuditLogCleanup           = "audit_log_cleanup"
+	taskNameLoginSessionCleanup       = "login_session_cleanup"
 )
 
 // GitFsck calls 'git fsck' to check repository health.
@@ -2565,6 +2637,8 @@ func ForkRepository(doer, owner *User, baseRepo *Repository, name, desc string)
 		IsUnlisted:    baseRepo.IsUnlisted,
 		IsFork:        true,
 		ForkID:        baseRepo.ID,
+
+		PullsAllowMergeCommit: true,
 	}
 
 	sess := x.NewSession()
@@ -107,6 +107,22 @@ type ProtectBranch struct {
 	EnableWhitelist    bool
 	WhitelistUserIDs   string `xorm:"TEXT"`
 	WhitelistTeamIDs   string `xorm:"TEXT"`
+	// Newline-separated status contexts that must succeed before merging.
+	RequiredStatusContexts string `xorm:"TEXT"`
+	RequiredApprovals      int
+}
+
+// RequiredContexts returns the list of status contexts that must succeed on the
+// head commit of a pull request before it can be merged into the branch.
+func (pb *ProtectBranch) RequiredContexts() []string {
+	var contexts []string
+	for _, name := range strings.Split(pb.RequiredStatusContexts, "\n") {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(contexts, name) {
+			contexts = append(contexts, name)
+		}
+	}
+	return contexts
 }
 
 // GetProtectBranchOfRepoByName returns *ProtectBranch by branch name in given repository.
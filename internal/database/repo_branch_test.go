@@ -0,0 +1,37 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtectBranch_RequiredContexts(t *testing.T) {
+	tests := []struct {
+		name     string
+		contexts string
+		want     []string
+	}{
+		{
+			name:     "empty",
+			contexts: "",
+			want:     nil,
+		},
+		{
+			name:     "trim spaces and blank lines",
+			contexts: " ci/build \r\n\n ci/test\n",
+			want:     []string{"ci/build", "ci/test"},
+		},
+		{
+			name:     "duplicates",
+			contexts: "ci/build\nci/build",
+			want:     []string{"ci/build"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pb := &ProtectBranch{RequiredStatusContexts: test.contexts}
+			assert.Equal(t, test.want, pb.RequiredContexts())
+		})
+	}
+}
@@ -58,6 +58,45 @@ func TestRepository_ComposeMetas(t *testing.T) {
 	})
 }
 
+func TestRepository_AllowedMergeStyles(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *Repository
+		want []MergeStyle
+	}{
+		{
+			name: "none",
+			repo: &Repository{},
+			want: []MergeStyle{},
+		},
+		{
+			name: "merge commit only",
+			repo: &Repository{PullsAllowMergeCommit: true},
+			want: []MergeStyle{MergeStyleRegular},
+		},
+		{
+			name: "squash is default when merge commit is disallowed",
+			repo: &Repository{PullsAllowSquash: true, PullsAllowFastForwardOnly: true},
+			want: []MergeStyle{MergeStyleSquash, MergeStyleFastForwardOnly},
+		},
+		{
+			name: "all",
+			repo: &Repository{
+				PullsAllowMergeCommit:     true,
+				PullsAllowRebase:          true,
+				PullsAllowSquash:          true,
+				PullsAllowFastForwardOnly: true,
+			},
+			want: []MergeStyle{MergeStyleRegular, MergeStyleRebase, MergeStyleSquash, MergeStyleFastForwardOnly},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.repo.AllowedMergeStyles())
+		})
+	}
+}
+
 func Test_CreateRepository_PreventDeletion(t *testing.T) {
 	tempRepositoryRoot := filepath.Join(os.TempDir(), "createRepository-tempRepositoryRoot")
 	conf.SetMockRepository(
@@ -145,6 +145,8 @@ func (s *RepositoriesStore) Create(ctx context.Context, ownerID int64, opts Crea
 		EnablePulls:   opts.EnablePulls,
 		IsFork:        opts.Fork,
 		ForkID:        opts.ForkID,
+
+		PullsAllowMergeCommit: true,
 	}
 	return repo, s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		err = tx.Create(repo).Error
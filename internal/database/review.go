@@ -0,0 +1,594 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/email"
+	"gogs.io/gogs/internal/errx"
+	"gogs.io/gogs/internal/markup"
+	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+)
+
+// ReviewState is the state of a pull request review.
+type ReviewState string
+
+const (
+	// The review has not been submitted yet, code comments of a pending review
+	// are only visible to the reviewer.
+	ReviewStatePending          ReviewState = "pending"
+	ReviewStateComment          ReviewState = "commented"
+	ReviewStateApproved         ReviewState = "approved"
+	ReviewStateChangesRequested ReviewState = "changes_requested"
+)
+
+// IsDecisive returns true if the state approves or requests changes.
+func (s ReviewState) IsDecisive() bool {
+	return s == ReviewStateApproved || s == ReviewStateChangesRequested
+}
+
+// Review is a set of code comments of a pull request that are submitted
+// together with an overall verdict.
+type Review struct {
+	ID         int64
+	IssueID    int64       `xorm:"INDEX"`
+	Issue      *Issue      `xorm:"-" json:"-" gorm:"-"`
+	ReviewerID int64       `xorm:"INDEX"`
+	Reviewer   *User       `xorm:"-" json:"-" gorm:"-"`
+	State      ReviewState `xorm:"VARCHAR(20)"`
+	Content    string      `xorm:"TEXT"`
+	// The head commit of the pull request when the review was submitted.
+	CommitSHA string `xorm:"VARCHAR(40)"`
+
+	CodeComments []*Comment `xorm:"-" json:"-" gorm:"-"`
+
+	Created     time.Time `xorm:"-" json:"-" gorm:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-" gorm:"-"`
+	UpdatedUnix int64
+}
+
+func (r *Review) BeforeInsert() {
+	r.CreatedUnix = time.Now().Unix()
+	r.UpdatedUnix = r.CreatedUnix
+}
+
+func (r *Review) BeforeUpdate() {
+	r.UpdatedUnix = time.Now().Unix()
+}
+
+func (r *Review) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		r.Created = time.Unix(r.CreatedUnix, 0).Local()
+	case "updated_unix":
+		r.Updated = time.Unix(r.UpdatedUnix, 0).Local()
+	}
+}
+
+func (r *Review) loadAttributes(e Engine) (err error) {
+	if r.Reviewer == nil {
+		r.Reviewer, err = getUserByID(e, r.ReviewerID)
+		if err != nil {
+			if !IsErrUserNotExist(err) {
+				return errors.Newf("getUserByID.(Reviewer) [%d]: %v", r.ReviewerID, err)
+			}
+			r.ReviewerID = -1
+			r.Reviewer = NewGhostUser()
+		}
+	}
+	return nil
+}
+
+// This method assumes following fields have been assigned with valid values:
+// Required - Reviewer
+func (r *Review) APIFormat() *apiv1types.PullRequestReview {
+	return &apiv1types.PullRequestReview{
+		ID:        r.ID,
+		Reviewer:  r.Reviewer.APIFormat(),
+		State:     apiv1types.PullRequestReviewState(r.State),
+		Body:      r.Content,
+		CommitID:  r.CommitSHA,
+		Submitted: r.Created,
+	}
+}
+
+// reviewStateText returns the human-readable form of the review state used in
+// webhook messages, e.g. "changes requested".
+func reviewStateText(state apiv1types.PullRequestReviewState) string {
+	return strings.ReplaceAll(string(state), "_", " ")
+}
+
+// ReviewRequest is a request to a user for reviewing a pull request, which is
+// fulfilled once the user submits a review.
+type ReviewRequest struct {
+	ID          int64
+	IssueID     int64 `xorm:"UNIQUE(s)"`
+	ReviewerID  int64 `xorm:"UNIQUE(s)"`
+	RequesterID int64
+	CreatedUnix int64
+}
+
+func (r *ReviewRequest) BeforeInsert() {
+	r.CreatedUnix = time.Now().Unix()
+}
+
+var _ errx.NotFound = (*ErrReviewNotExist)(nil)
+
+type ErrReviewNotExist struct {
+	args errx.Args
+}
+
+func IsErrReviewNotExist(err error) bool {
+	return errors.As(err, &ErrReviewNotExist{})
+}
+
+func (err ErrReviewNotExist) Error() string {
+	return fmt.Sprintf("review does not exist: %v", err.args)
+}
+
+func (ErrReviewNotExist) NotFound() bool {
+	return true
+}
+
+type ErrReviewInvalid struct {
+	args errx.Args
+}
+
+func IsErrReviewInvalid(err error) bool {
+	return errors.As(err, &ErrReviewInvalid{})
+}
+
+func (err ErrReviewInvalid) Error() string {
+	return fmt.Sprintf("review is invalid: %v", err.args)
+}
+
+func getPendingReview(e Engine, issueID, reviewerID int64) (*Review, error) {
+	review := new(Review)
+	has, err := e.Where("issue_id = ? AND reviewer_id = ? AND state = ?", issueID, reviewerID, ReviewStatePending).Get(review)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, ErrReviewNotExist{args: errx.Args{"issueID": issueID, "reviewerID": reviewerID}}
+	}
+	return review, nil
+}
+
+// GetPendingReview returns the pending review of the reviewer on the issue.
+func GetPendingReview(issueID, reviewerID int64) (*Review, error) {
+	return getPendingReview(x, issueID, reviewerID)
+}
+
+// CreateCodeComment creates a code comment on a line of the diff of the pull
+// request. The comment belongs to the pending review of the doer, which is
+// created if not exists, and stays invisible to others until the review is
+// submitted.
+func CreateCodeComment(doer *User, repo *Repository, issue *Issue, treePath string, line int64, commitSHA, content string) (*Comment, error) {
+	if !issue.IsPull {
+		return nil, ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "not a pull request"}}
+	} else if treePath == "" || line == 0 {
+		return nil, ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "no line to comment on"}}
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, err
+	}
+
+	review, err := getPendingReview(sess, issue.ID, doer.ID)
+	if err != nil {
+		if !IsErrReviewNotExist(err) {
+			return nil, errors.Newf("get pending review: %v", err)
+		}
+
+		review = &Review{
+			IssueID:    issue.ID,
+			ReviewerID: doer.ID,
+			State:      ReviewStatePending,
+		}
+		if _, err = sess.Insert(review); err != nil {
+			return nil, errors.Newf("insert review: %v", err)
+		}
+	}
+
+	comment, err := createComment(sess, &CreateCommentOptions{
+		Type:      CommentTypeCode,
+		Doer:      doer,
+		Repo:      repo,
+		Issue:     issue,
+		CommitSHA: commitSHA,
+		LineNum:   line,
+		ReviewID:  review.ID,
+		TreePath:  treePath,
+		Content:   content,
+	})
+	if err != nil {
+		return nil, errors.Newf("create comment: %v", err)
+	}
+	return comment, sess.Commit()
+}
+
+// SubmitReview submits the pending review of the doer with given state and
+// content, or creates a new review if the doer has no pending review. The
+// review is recorded in the timeline of the pull request, fulfills the review
+// request to the doer and notifies participants and webhooks.
+func SubmitReview(doer *User, repo *Repository, issue *Issue, state ReviewState, commitSHA, content string) (_ *Review, err error) {
+	switch state {
+	case ReviewStateComment, ReviewStateApproved, ReviewStateChangesRequested:
+	default:
+		return nil, ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "unknown state " + string(state)}}
+	}
+	if state.IsDecisive() && issue.IsPoster(doer.ID) {
+		return nil, ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "poster cannot approve or request changes"}}
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return nil, err
+	}
+
+	review, err := getPendingReview(sess, issue.ID, doer.ID)
+	if err != nil {
+		if !IsErrReviewNotExist(err) {
+			return nil, errors.Newf("get pending review: %v", err)
+		}
+		review = &Review{
+			IssueID:    issue.ID,
+			ReviewerID: doer.ID,
+		}
+	}
+
+	codeComments := make([]*Comment, 0, 5)
+	if review.ID > 0 {
+		if err = sess.Where("review_id = ?", review.ID).Asc("id").Find(&codeComments); err != nil {
+			return nil, errors.Newf("find code comments: %v", err)
+		}
+	}
+	if state == ReviewStateComment && content == "" && len(codeComments) == 0 {
+		return nil, ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "empty review"}}
+	}
+
+	review.State = state
+	review.Content = content
+	review.CommitSHA = commitSHA
+	if review.ID == 0 {
+		_, err = sess.Insert(review)
+	} else {
+		// Submission time is what matters to others, rather than when the first
+		// code comment was drafted.
+		review.CreatedUnix = time.Now().Unix()
+		_, err = sess.ID(review.ID).AllCols().Update(review)
+	}
+	if err != nil {
+		return nil, errors.Newf("save review: %v", err)
+	}
+
+	for _, c := range codeComments {
+		c.CreatedUnix = review.CreatedUnix
+		if _, err = sess.ID(c.ID).Cols("created_unix").Update(c); err != nil {
+			return nil, errors.Newf("update code comment [%d]: %v", c.ID, err)
+		}
+	}
+
+	_, err = createComment(sess, &CreateCommentOptions{
+		Type:     CommentTypeReview,
+		Doer:     doer,
+		Repo:     repo,
+		Issue:    issue,
+		ReviewID: review.ID,
+		Content:  content,
+	})
+	if err != nil {
+		return nil, errors.Newf("create comment: %v", err)
+	}
+
+	if _, err = sess.Delete(&ReviewRequest{IssueID: issue.ID, ReviewerID: doer.ID}); err != nil {
+		return nil, errors.Newf("delete review request: %v", err)
+	}
+
+	if err = sess.Commit(); err != nil {
+		return nil, errors.Newf("commit: %v", err)
+	}
+
+	review.Created = time.Unix(review.CreatedUnix, 0).Local()
+	review.Reviewer = doer
+	review.CodeComments = codeComments
+	if err = review.afterSubmit(issue); err != nil {
+		log.Error("Failed to notify submitted review [id: %d]: %v", review.ID, err)
+	}
+
+	// The approval may be the last missing condition for auto-merge.
+	if state == ReviewStateApproved && issue.PullRequest != nil && issue.PullRequest.IsAutoMergeEnabled() {
+		issue.PullRequest.AddToTaskQueue()
+	}
+	return review, nil
+}
+
+// afterSubmit notifies participants and webhooks of the submitted review.
+func (r *Review) afterSubmit(issue *Issue) error {
+	if err := issue.loadAttributes(x); err != nil {
+		return errors.Newf("load issue attributes: %v", err)
+	}
+	if err := issue.PullRequest.LoadAttributes(); err != nil {
+		return errors.Newf("load pull request attributes: %v", err)
+	}
+
+	mentions := markup.FindAllMentions(r.Content)
+	if err := updateIssueMentions(x, issue.ID, mentions); err != nil {
+		return errors.Newf("update issue mentions: %v", err)
+	}
+
+	// The mail body is the issue content, use the review summary instead.
+	mailIssue := *issue
+	switch r.State {
+	case ReviewStateApproved:
+		mailIssue.Content = "Approved these changes"
+	case ReviewStateChangesRequested:
+		mailIssue.Content = "Requested changes"
+	default:
+		mailIssue.Content = "Reviewed these changes"
+	}
+	if len(r.CodeComments) > 0 {
+		mailIssue.Content += fmt.Sprintf(" with %d comment(s) on the code", len(r.CodeComments))
+	}
+	if r.Content != "" {
+		mailIssue.Content += "\n\n" + r.Content
+	}
+	if err := mailIssueCommentToParticipants(&mailIssue, r.Reviewer, mentions); err != nil {
+		log.Error("mailIssueCommentToParticipants: %v", err)
+	}
+
+	return PrepareWebhooks(issue.Repo, HookEventTypePullRequestReview, &apiv1types.WebhookPullRequestReviewPayload{
+		Action:      apiv1types.WebhookPullRequestReviewSubmitted,
+		Index:       issue.Index,
+		Review:      r.APIFormat(),
+		PullRequest: issue.PullRequest.APIFormat(),
+		Repository:  issue.Repo.APIFormatLegacy(nil),
+		Sender:      r.Reviewer.APIFormat(),
+	})
+}
+
+// GetReviewsByIssueID returns all submitted reviews of the issue in
+// chronological order.
+func GetReviewsByIssueID(issueID int64) ([]*Review, error) {
+	reviews := make([]*Review, 0, 5)
+	err := x.Where("issue_id = ? AND state != ?", issueID, ReviewStatePending).Asc("created_unix").Asc("id").Find(&reviews)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reviews {
+		if err = reviews[i].loadAttributes(x); err != nil {
+			return nil, errors.Newf("loadAttributes [%d]: %v", reviews[i].ID, err)
+		}
+	}
+	return reviews, nil
+}
+
+// LoadReviews loads the review and its code comments of each review comment in
+// the list, which is expected to contain the code comments as well.
+func LoadReviews(comments []*Comment) error {
+	codeComments := make(map[int64][]*Comment)
+	for _, c := range comments {
+		if c.Type == CommentTypeCode {
+			codeComments[c.ReviewID] = append(codeComments[c.ReviewID], c)
+		}
+	}
+
+	for _, c := range comments {
+		if c.Type != CommentTypeReview {
+			continue
+		}
+
+		review := new(Review)
+		has, err := x.ID(c.ReviewID).Get(review)
+		if err != nil {
+			return errors.Newf("get review [%d]: %v", c.ReviewID, err)
+		} else if !has {
+			continue
+		}
+		review.Reviewer = c.Poster
+		review.CodeComments = codeComments[review.ID]
+		c.Review = review
+	}
+	return nil
+}
+
+// ReviewerState is the review state of a reviewer on a pull request.
+type ReviewerState struct {
+	Reviewer *User
+	// The state of the latest decisive review, or the latest review if the
+	// reviewer has never approved or requested changes. It is empty when the
+	// reviewer has been requested but not reviewed yet.
+	State ReviewState
+	// Whether a review is requested and not submitted yet.
+	IsRequested bool
+}
+
+// GetReviewerStates returns the review state of each reviewer of the issue,
+// including users who are requested to review.
+func GetReviewerStates(issueID int64) ([]*ReviewerState, error) {
+	reviews, err := GetReviewsByIssueID(issueID)
+	if err != nil {
+		return nil, errors.Newf("get reviews: %v", err)
+	}
+
+	states := make([]*ReviewerState, 0, len(reviews))
+	stateOf := make(map[int64]*ReviewerState, len(reviews))
+	for _, r := range reviews {
+		state, ok := stateOf[r.ReviewerID]
+		if !ok {
+			state = &ReviewerState{Reviewer: r.Reviewer}
+			stateOf[r.ReviewerID] = state
+			states = append(states, state)
+		}
+		if r.State.IsDecisive() || !state.State.IsDecisive() {
+			state.State = r.State
+		}
+	}
+
+	requests := make([]*ReviewRequest, 0, 5)
+	if err = x.Where("issue_id = ?", issueID).Asc("id").Find(&requests); err != nil {
+		return nil, errors.Newf("find review requests: %v", err)
+	}
+	for _, req := range requests {
+		state, ok := stateOf[req.ReviewerID]
+		if !ok {
+			reviewer, err := getUserByID(x, req.ReviewerID)
+			if err != nil {
+				if IsErrUserNotExist(err) {
+					continue
+				}
+				return nil, errors.Newf("getUserByID [%d]: %v", req.ReviewerID, err)
+			}
+			state = &ReviewerState{Reviewer: reviewer}
+			stateOf[req.ReviewerID] = state
+			states = append(states, state)
+		}
+		state.IsRequested = true
+	}
+	return states, nil
+}
+
+// countApprovals returns the number of reviewers with write access to the
+// repository whose latest decisive review approves the pull request, excluding
+// those who have been requested to review again.
+func countApprovals(e Engine, repo *Repository, issue *Issue) (int, error) {
+	reviews := make([]*Review, 0, 5)
+	err := e.Where("issue_id = ? AND reviewer_id != ?", issue.ID, issue.PosterID).
+		In("state", ReviewStateApproved, ReviewStateChangesRequested).
+		Asc("created_unix").Asc("id").
+		Find(&reviews)
+	if err != nil {
+		return 0, err
+	}
+
+	latest := make(map[int64]ReviewState)
+	for _, r := range reviews {
+		latest[r.ReviewerID] = r.State
+	}
+
+	requests := make([]*ReviewRequest, 0, 5)
+	if err = e.Where("issue_id = ?", issue.ID).Find(&requests); err != nil {
+		return 0, err
+	}
+	for _, req := range requests {
+		delete(latest, req.ReviewerID)
+	}
+
+	approvals := 0
+	for reviewerID, state := range latest {
+		if state != ReviewStateApproved {
+			continue
+		}
+		if !Handle.Permissions().Authorize(context.TODO(), reviewerID, repo.ID, AccessModeWrite,
+			AccessModeOptions{
+				OwnerID: repo.OwnerID,
+				Private: repo.IsPrivate,
+			},
+		) {
+			continue
+		}
+		approvals++
+	}
+	return approvals, nil
+}
+
+// RequestReview requests the reviewer to review the pull request, typically
+// again after new commits have been pushed. The reviewer is notified by email.
+func RequestReview(doer *User, repo *Repository, issue *Issue, reviewer *User) error {
+	if !issue.IsPull {
+		return ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "not a pull request"}}
+	} else if issue.IsPoster(reviewer.ID) {
+		return ErrReviewInvalid{args: errx.Args{"issueID": issue.ID, "reason": "poster cannot be a reviewer"}}
+	}
+
+	has, err := x.Get(&ReviewRequest{IssueID: issue.ID, ReviewerID: reviewer.ID})
+	if err != nil {
+		return errors.Newf("get review request: %v", err)
+	} else if has {
+		return nil
+	}
+
+	if _, err = x.Insert(&ReviewRequest{
+		IssueID:     issue.ID,
+		ReviewerID:  reviewer.ID,
+		RequesterID: doer.ID,
+	}); err != nil {
+		return errors.Newf("insert review request: %v", err)
+	}
+
+	if reviewer.ID == doer.ID || !reviewer.IsActive || reviewer.IsOrganization() {
+		return nil
+	}
+	issue.Repo = repo
+	if err = email.SendReviewRequestMail(NewMailerIssue(issue), NewMailerRepo(repo), NewMailerUser(doer), []string{reviewer.Email}); err != nil {
+		log.Error("Failed to send review request mail to user [id: %d]: %v", reviewer.ID, err)
+	}
+	return nil
+}
+
+// CodeComments is a set of code comments grouped by tree path and line.
+type CodeComments map[string]map[int64][]*Comment
+
+// Right returns code comments on the line of the file on the new side of a
+// diff.
+func (cc CodeComments) Right(treePath string, line int) []*Comment {
+	return cc[treePath][int64(line)]
+}
+
+// Left returns code comments on the line of the file on the old side of a
+// diff.
+func (cc CodeComments) Left(treePath string, line int) []*Comment {
+	return cc[treePath][-int64(line)]
+}
+
+// GetCodeComments returns submitted code comments of the issue, and pending
+// code comments of the viewer when viewerID is not zero.
+func GetCodeComments(issueID, viewerID int64) (CodeComments, error) {
+	comments := make([]*Comment, 0, 10)
+	err := x.Where("comment.issue_id = ? AND comment.type = ?", issueID, CommentTypeCode).
+		And("(review.state != ? OR review.reviewer_id = ?)", ReviewStatePending, viewerID).
+		Join("INNER", "review", "review.id = comment.review_id").
+		Asc("comment.created_unix").Asc("comment.id").
+		Find(&comments)
+	if err != nil {
+		return nil, err
+	}
+	if err = loadCommentsAttributes(x, comments); err != nil {
+		return nil, errors.Newf("load comments attributes: %v", err)
+	}
+
+	var pending *Review
+	if viewerID > 0 {
+		pending, err = getPendingReview(x, issueID, viewerID)
+		if err != nil && !IsErrReviewNotExist(err) {
+			return nil, errors.Newf("get pending review: %v", err)
+		}
+	}
+
+	cc := make(CodeComments)
+	for _, c := range comments {
+		if pending != nil && c.ReviewID == pending.ID {
+			c.Review = pending
+		}
+		if cc[c.TreePath] == nil {
+			cc[c.TreePath] = make(map[int64][]*Comment)
+		}
+		cc[c.TreePath][c.Line] = append(cc[c.TreePath][c.Line], c)
+	}
+	return cc, nil
+}
+
+// IsPending returns true if the comment belongs to a review that has not been
+// submitted. It is only meaningful for code comments returned by GetCodeComments.
+func (c *Comment) IsPending() bool {
+	return c.Review != nil && c.Review.State == ReviewStatePending
+}
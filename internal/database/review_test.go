@@ -0,0 +1,93 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewState_IsDecisive(t *testing.T) {
+	tests := []struct {
+		state ReviewState
+		want  bool
+	}{
+		{state: ReviewStatePending, want: false},
+		{state: ReviewStateComment, want: false},
+		{state: ReviewStateApproved, want: true},
+		{state: ReviewStateChangesRequested, want: true},
+	}
+	for _, test := range tests {
+		t.Run(string(test.state), func(t *testing.T) {
+			assert.Equal(t, test.want, test.state.IsDecisive())
+		})
+	}
+}
+
+func TestCodeComments(t *testing.T) {
+	added := &Comment{ID: 1, TreePath: "main.go", Line: 12}
+	removed := &Comment{ID: 2, TreePath: "main.go", Line: -12}
+	cc := CodeComments{
+		"main.go": {
+			12:  {added},
+			-12: {removed},
+		},
+	}
+
+	assert.Equal(t, []*Comment{added}, cc.Right("main.go", 12))
+	assert.Equal(t, []*Comment{removed}, cc.Left("main.go", 12))
+	assert.Empty(t, cc.Right("main.go", 13))
+	assert.Empty(t, cc.Left("README.md", 12))
+}
+
+func TestGetCommentsByIssueID_PendingReview(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	engine := setTestDB(t)
+
+	owner := &User{LowerName: "alice", Name: "alice", Email: "alice@example.com"}
+	_, err := engine.Insert(owner)
+	require.NoError(t, err)
+	repo := &Repository{OwnerID: owner.ID, LowerName: "repo", Name: "repo"}
+	_, err = engine.Insert(repo)
+	require.NoError(t, err)
+	issue := &Issue{RepoID: repo.ID, Index: 1, IsPull: true}
+	_, err = engine.Insert(issue)
+	require.NoError(t, err)
+
+	pending := &Review{IssueID: issue.ID, State: ReviewStatePending}
+	submitted := &Review{IssueID: issue.ID, State: ReviewStateApproved}
+	_, err = engine.Insert(pending, submitted)
+	require.NoError(t, err)
+
+	legacy := &Comment{IssueID: issue.ID, Content: "legacy"}
+	plain := &Comment{IssueID: issue.ID, Content: "plain"}
+	draft := &Comment{IssueID: issue.ID, ReviewID: pending.ID, Content: "draft"}
+	published := &Comment{IssueID: issue.ID, ReviewID: submitted.ID, Content: "published"}
+	_, err = engine.Insert(legacy, plain, draft, published)
+	require.NoError(t, err)
+
+	// Comments created before reviews were introduced have NULL review ID.
+	_, err = engine.Exec("UPDATE comment SET review_id = NULL WHERE id = ?", legacy.ID)
+	require.NoError(t, err)
+
+	wantContents := []string{"legacy", "plain", "published"}
+
+	comments, err := GetCommentsByIssueID(issue.ID)
+	require.NoError(t, err)
+	var got []string
+	for _, c := range comments {
+		got = append(got, c.Content)
+	}
+	assert.ElementsMatch(t, wantContents, got)
+
+	comments, err = GetCommentsByRepoIDSince(repo.ID, -1)
+	require.NoError(t, err)
+	got = nil
+	for _, c := range comments {
+		got = append(got, c.Content)
+	}
+	assert.ElementsMatch(t, wantContents, got)
+}
@@ -380,8 +380,9 @@ func addKey(e Engine, key *PublicKey) (err error) {
 		return err
 	}
 
-	// Don't need to rewrite this file if builtin SSH server is enabled.
-	if conf.SSH.StartBuiltinServer {
+	// Don't need to rewrite this file if builtin SSH server is enabled, or keys
+	// are looked up by the "keys" command.
+	if conf.SSH.StartBuiltinServer || conf.SSH.DisableAuthorizedKeysRewrite {
 		return nil
 	}
 	return appendAuthorizedKeysToFile(key)
@@ -434,6 +435,19 @@ func GetPublicKeyByID(keyID int64) (*PublicKey, error) {
 	return key, nil
 }
 
+// GetPublicKeyByFingerprint returns the public key with given fingerprint. It
+// returns ErrKeyNotExist if no such key exists.
+func GetPublicKeyByFingerprint(fingerprint string) (*PublicKey, error) {
+	key := new(PublicKey)
+	has, err := x.Where("fingerprint = ?", fingerprint).Get(key)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, ErrKeyNotExist{}
+	}
+	return key, nil
+}
+
 // SearchPublicKeyByContent searches a public key using the content as prefix
 // (i.e. ignore the email part). It returns ErrKeyNotExist if no such key
 // exists.
@@ -511,6 +525,11 @@ func RewriteAuthorizedKeys() error {
 	sshOpLocker.Lock()
 	defer sshOpLocker.Unlock()
 
+	if conf.SSH.DisableAuthorizedKeysRewrite {
+		log.Trace("Skipped RewriteAuthorizedKeys because rewriting is disabled")
+		return nil
+	}
+
 	log.Trace("Doing: RewriteAuthorizedKeys")
 
 	_ = os.MkdirAll(conf.SSH.RootPath, os.ModePerm)
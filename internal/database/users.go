@@ -123,14 +123,59 @@ func (s *UsersStore) Authenticate(ctx context.Context, login, password string, l
 		return nil, err
 	}
 
-	if !createNewUser {
+	if createNewUser {
+		user, err = s.Create(ctx, extAccount.Name, extAccount.Email,
+			CreateUserOptions{
+				FullName:    extAccount.FullName,
+				LoginSource: authSourceID,
+				LoginName:   extAccount.Login,
+				Location:    extAccount.Location,
+				Website:     extAccount.Website,
+				Activated:   true,
+				Admin:       extAccount.Admin,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (source.IsLDAP() || source.IsDLDAP()) && source.LDAP().TeamSyncEnabled {
+		// Failing to synchronize teams should not prevent the user from signing in.
+		if err = syncLDAPTeams(ctx, source, user.ID, extAccount.Groups); err != nil {
+			log.Error("Failed to synchronize LDAP teams of user %q: %v", user.Name, err)
+		}
+	}
+	return user, nil
+}
+
+// AuthenticateByExternalAccount returns the user that is associated with the
+// external account of the given login source, and creates the user when the
+// account signs in for the first time. It is used by login sources that
+// authenticate users without a password, e.g. OpenID Connect, where the
+// external account has already been authenticated by the login source.
+func (s *UsersStore) AuthenticateByExternalAccount(ctx context.Context, loginSourceID int64, extAccount *auth.ExternalAccount) (*User, error) {
+	source, err := newLoginSourcesStore(s.db, loadedLoginSourceFilesStore).GetByID(ctx, loginSourceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get login source")
+	} else if !source.IsActived {
+		return nil, errors.Newf("login source %d is not activated", source.ID)
+	}
+
+	user := new(User)
+	err = s.db.WithContext(ctx).
+		Where("login_source = ? AND login_name = ?", loginSourceID, extAccount.Login).
+		First(user).Error
+	if err == nil {
 		return user, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "get user")
 	}
 
 	return s.Create(ctx, extAccount.Name, extAccount.Email,
 		CreateUserOptions{
 			FullName:    extAccount.FullName,
-			LoginSource: authSourceID,
+			LoginSource: loginSourceID,
 			LoginName:   extAccount.Login,
 			Location:    extAccount.Location,
 			Website:     extAccount.Website,
@@ -508,6 +553,13 @@ func (s *UsersStore) DeleteByID(ctx context.Context, userID int64, skipRewriteAu
 			return errors.Wrap(err, "clear assignees")
 		}
 
+		err = deleteGrants(tx, "user_id = ? OR application_id IN (?)", userID,
+			tx.Select("id").Table("oauth2_application").Where("user_id = ?", userID),
+		)
+		if err != nil {
+			return errors.Wrap(err, "delete OAuth2 grants")
+		}
+
 		for _, t := range []struct {
 			table any
 			where string
@@ -518,6 +570,9 @@ func (s *UsersStore) DeleteByID(ctx context.Context, userID int64, skipRewriteAu
 			{&PublicKey{}, "owner_id = @userID"},
 
 			{&AccessToken{}, "uid = @userID"},
+			{&OAuth2Application{}, "user_id = @userID"},
+			{&WebAuthnCredential{}, "user_id = @userID"},
+			{&LoginSession{}, "user_id = @userID"},
 			{&Collaboration{}, "user_id = @userID"},
 			{&Access{}, "user_id = @userID"},
 			{&Action{}, "user_id = @userID"},
@@ -1472,6 +1527,7 @@ var (
 		"debug":    {},
 		"raw":      {},
 		"install":  {},
+		"login":    {},
 		"api":      {},
 		"avatar":   {},
 		"user":     {},
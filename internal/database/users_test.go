@@ -89,6 +89,7 @@ func TestUsers(t *testing.T) {
 		test func(t *testing.T, ctx context.Context, s *UsersStore)
 	}{
 		{"Authenticate", usersAuthenticate},
+		{"AuthenticateByExternalAccount", usersAuthenticateByExternalAccount},
 		{"ChangeUsername", usersChangeUsername},
 		{"Count", usersCount},
 		{"Create", usersCreate},
@@ -226,6 +227,61 @@ func usersAuthenticate(t *testing.T, ctx context.Context, s *UsersStore) {
 	})
 }
 
+func usersAuthenticateByExternalAccount(t *testing.T, ctx context.Context, s *UsersStore) {
+	loginSourcesStore := newLoginSourcesStore(s.db, NewMockLoginSourceFilesStore())
+	loginSource, err := loginSourcesStore.Create(
+		ctx,
+		CreateLoginSourceOptions{
+			Type:      auth.Mock,
+			Name:      "mock",
+			Activated: true,
+			Config: mockProviderConfig{
+				ExternalAccount: &auth.ExternalAccount{},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	extAccount := &auth.ExternalAccount{
+		Login:    "c0ffee",
+		Name:     "alice",
+		FullName: "Alice",
+		Email:    "alice@example.com",
+		Admin:    true,
+	}
+	alice, err := s.AuthenticateByExternalAccount(ctx, loginSource.ID, extAccount)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", alice.Name)
+	assert.Equal(t, "Alice", alice.FullName)
+	assert.Equal(t, loginSource.ID, alice.LoginSource)
+	assert.Equal(t, "c0ffee", alice.LoginName)
+	assert.True(t, alice.IsActive)
+	assert.True(t, alice.IsAdmin)
+
+	// The same external account is matched by its login even if other claims
+	// have been changed.
+	user, err := s.AuthenticateByExternalAccount(ctx, loginSource.ID,
+		&auth.ExternalAccount{
+			Login: "c0ffee",
+			Name:  "alice2",
+			Email: "alice2@example.com",
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	// A different external account with a taken username is not matched to the
+	// existing user.
+	_, err = s.AuthenticateByExternalAccount(ctx, loginSource.ID,
+		&auth.ExternalAccount{
+			Login: "deadbeef",
+			Name:  "alice",
+			Email: "another@example.com",
+		},
+	)
+	assert.True(t, IsErrUserAlreadyExist(err))
+}
+
 func usersChangeUsername(t *testing.T, ctx context.Context, s *UsersStore) {
 	alice, err := s.Create(
 		ctx,
@@ -548,6 +604,10 @@ func usersDeleteByID(t *testing.T, ctx context.Context, s *UsersStore) {
 	// Mock random entries in related tables
 	for _, table := range []any{
 		&AccessToken{UserID: testUser.ID},
+		&OAuth2Application{UserID: testUser.ID},
+		&OAuth2Grant{UserID: testUser.ID},
+		&WebAuthnCredential{UserID: testUser.ID, CredentialID: []byte("id"), PublicKey: []byte("key")},
+		&LoginSession{UserID: testUser.ID, KeySHA256: "key"},
 		&Collaboration{UserID: testUser.ID},
 		&Access{UserID: testUser.ID},
 		&Action{UserID: testUser.ID},
@@ -603,6 +663,10 @@ func usersDeleteByID(t *testing.T, ctx context.Context, s *UsersStore) {
 		&Follow{UserID: testUser.ID},
 		&PublicKey{OwnerID: testUser.ID},
 		&AccessToken{UserID: testUser.ID},
+		&OAuth2Application{UserID: testUser.ID},
+		&OAuth2Grant{UserID: testUser.ID},
+		&WebAuthnCredential{UserID: testUser.ID},
+		&LoginSession{UserID: testUser.ID},
 		&Collaboration{UserID: testUser.ID},
 		&Access{UserID: testUser.ID},
 		&Action{UserID: testUser.ID},
@@ -651,6 +715,10 @@ func usersDeleteByID(t *testing.T, ctx context.Context, s *UsersStore) {
 		&Follow{UserID: testUser.ID},
 		&PublicKey{OwnerID: testUser.ID},
 		&AccessToken{UserID: testUser.ID},
+		&OAuth2Application{UserID: testUser.ID},
+		&OAuth2Grant{UserID: testUser.ID},
+		&WebAuthnCredential{UserID: testUser.ID},
+		&LoginSession{UserID: testUser.ID},
 		&Collaboration{UserID: testUser.ID},
 		&Access{UserID: testUser.ID},
 		&Action{UserID: testUser.ID},
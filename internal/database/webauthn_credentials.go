@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/cryptox"
+	"gogs.io/gogs/internal/errx"
+)
+
+// WebAuthnCredential is a WebAuthn security key or passkey that a user has
+// registered as a second factor.
+type WebAuthnCredential struct {
+	ID     int64  `gorm:"primaryKey"`
+	UserID int64  `gorm:"index;not null"`
+	Name   string `gorm:"not null"`
+	// The credential ID, which is assigned by the authenticator and can be up to
+	// 1023 bytes long. Lookups go through its SHA256 hash instead.
+	CredentialID       []byte `gorm:"not null"`
+	CredentialIDSHA256 string `gorm:"type:VARCHAR(64);unique;not null"`
+	// The credential public key in the COSE_Key format.
+	PublicKey []byte `gorm:"not null"`
+	SignCount int64  `gorm:"not null"`
+	// Whether the credential can be used to sign in without a password.
+	Passwordless bool `gorm:"not null"`
+
+	Created      time.Time `gorm:"-" json:"-"`
+	CreatedUnix  int64
+	LastUsed     time.Time `gorm:"-" json:"-"`
+	LastUsedUnix int64
+}
+
+// BeforeCreate implements the GORM create hook.
+func (c *WebAuthnCredential) BeforeCreate(tx *gorm.DB) error {
+	if c.CreatedUnix == 0 {
+		c.CreatedUnix = tx.NowFunc().Unix()
+	}
+	return nil
+}
+
+// AfterFind implements the GORM query hook.
+func (c *WebAuthnCredential) AfterFind(_ *gorm.DB) error {
+	c.Created = time.Unix(c.CreatedUnix, 0).Local()
+	if c.LastUsedUnix > 0 {
+		c.LastUsed = time.Unix(c.LastUsedUnix, 0).Local()
+	}
+	return nil
+}
+
+// WebAuthnCredentialsStore is the storage layer for WebAuthn credentials.
+type WebAuthnCredentialsStore struct {
+	db *gorm.DB
+}
+
+func newWebAuthnCredentialsStore(db *gorm.DB) *WebAuthnCredentialsStore {
+	return &WebAuthnCredentialsStore{db: db}
+}
+
+type ErrWebAuthnCredentialAlreadyExist struct {
+	args errx.Args
+}
+
+func IsErrWebAuthnCredentialAlreadyExist(err error) bool {
+	return errors.As(err, &ErrWebAuthnCredentialAlreadyExist{})
+}
+
+func (err ErrWebAuthnCredentialAlreadyExist) Error() string {
+	return fmt.Sprintf("WebAuthn credential already exists: %v", err.args)
+}
+
+type CreateWebAuthnCredentialOptions struct {
+	Name         string
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Passwordless bool
+}
+
+// Create creates a new WebAuthn credential for the given user. It returns
+// ErrWebAuthnCredentialAlreadyExist when a credential with same name already
+// exists for the user, or the credential has already been registered.
+func (s *WebAuthnCredentialsStore) Create(ctx context.Context, userID int64, opts CreateWebAuthnCredentialOptions) (*WebAuthnCredential, error) {
+	credentialIDSHA256 := cryptox.SHA256(string(opts.CredentialID))
+	err := s.db.WithContext(ctx).
+		Where("(user_id = ? AND name = ?) OR credential_id_sha256 = ?", userID, opts.Name, credentialIDSHA256).
+		First(new(WebAuthnCredential)).Error
+	if err == nil {
+		return nil, ErrWebAuthnCredentialAlreadyExist{args: errx.Args{"userID": userID, "name": opts.Name}}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	cred := &WebAuthnCredential{
+		UserID:             userID,
+		Name:               opts.Name,
+		CredentialID:       opts.CredentialID,
+		CredentialIDSHA256: credentialIDSHA256,
+		PublicKey:          opts.PublicKey,
+		SignCount:          int64(opts.SignCount),
+		Passwordless:       opts.Passwordless,
+	}
+	return cred, s.db.WithContext(ctx).Create(cred).Error
+}
+
+var _ errx.NotFound = (*ErrWebAuthnCredentialNotExist)(nil)
+
+type ErrWebAuthnCredentialNotExist struct {
+	args errx.Args
+}
+
+func IsErrWebAuthnCredentialNotExist(err error) bool {
+	return errors.As(err, &ErrWebAuthnCredentialNotExist{})
+}
+
+func (err ErrWebAuthnCredentialNotExist) Error() string {
+	return fmt.Sprintf("WebAuthn credential does not exist: %v", err.args)
+}
+
+func (ErrWebAuthnCredentialNotExist) NotFound() bool {
+	return true
+}
+
+// GetByCredentialID returns the WebAuthn credential with the given credential
+// ID. It returns ErrWebAuthnCredentialNotExist when not found.
+func (s *WebAuthnCredentialsStore) GetByCredentialID(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error) {
+	cred := new(WebAuthnCredential)
+	err := s.db.WithContext(ctx).Where("credential_id_sha256 = ?", cryptox.SHA256(string(credentialID))).First(cred).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnCredentialNotExist{args: errx.Args{"credentialID": fmt.Sprintf("%x", credentialID)}}
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+// List returns all WebAuthn credentials of the given user, in the order of
+// registration.
+func (s *WebAuthnCredentialsStore) List(ctx context.Context, userID int64) ([]*WebAuthnCredential, error) {
+	var creds []*WebAuthnCredential
+	return creds, s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&creds).Error
+}
+
+// IsEnabled returns true if the user has registered any WebAuthn credential.
+func (s *WebAuthnCredentialsStore) IsEnabled(ctx context.Context, userID int64) bool {
+	var count int64
+	err := s.db.WithContext(ctx).Model(new(WebAuthnCredential)).Where("user_id = ?", userID).Count(&count).Error
+	if err != nil {
+		log.Error("Failed to count WebAuthn credentials [user_id: %d]: %v", userID, err)
+	}
+	return count > 0
+}
+
+// Use records a successful authentication with the WebAuthn credential, which
+// updates its signature counter and last used time.
+func (s *WebAuthnCredentialsStore) Use(ctx context.Context, id int64, signCount uint32) error {
+	return s.db.WithContext(ctx).
+		Model(new(WebAuthnCredential)).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"sign_count":     int64(signCount),
+			"last_used_unix": s.db.NowFunc().Unix(),
+		}).
+		Error
+}
+
+// DeleteByID deletes the WebAuthn credential by given ID. It does nothing when
+// the credential does not exist or does not belong to the user.
+func (s *WebAuthnCredentialsStore) DeleteByID(ctx context.Context, userID, id int64) error {
+	return s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(new(WebAuthnCredential)).Error
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWebAuthnCredential_AfterFind(t *testing.T) {
+	now := time.Now()
+	db := &gorm.DB{
+		Config: &gorm.Config{
+			SkipDefaultTransaction: true,
+			NowFunc: func() time.Time {
+				return now
+			},
+		},
+	}
+
+	t.Run("never used", func(t *testing.T) {
+		cred := &WebAuthnCredential{
+			CreatedUnix: now.Unix(),
+		}
+		_ = cred.AfterFind(db)
+		assert.Equal(t, cred.CreatedUnix, cred.Created.Unix())
+		assert.True(t, cred.LastUsed.IsZero())
+	})
+
+	t.Run("has been used", func(t *testing.T) {
+		cred := &WebAuthnCredential{
+			CreatedUnix:  now.Unix(),
+			LastUsedUnix: now.Add(time.Hour).Unix(),
+		}
+		_ = cred.AfterFind(db)
+		assert.Equal(t, cred.LastUsedUnix, cred.LastUsed.Unix())
+	})
+}
+
+func TestWebAuthnCredentials(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &WebAuthnCredentialsStore{
+		db: newTestDB(t, "WebAuthnCredentialsStore"),
+	}
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore)
+	}{
+		{"Create", webAuthnCredentialsCreate},
+		{"GetByCredentialID", webAuthnCredentialsGetByCredentialID},
+		{"List", webAuthnCredentialsList},
+		{"IsEnabled", webAuthnCredentialsIsEnabled},
+		{"Use", webAuthnCredentialsUse},
+		{"DeleteByID", webAuthnCredentialsDeleteByID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := clearTables(t, s.db)
+				require.NoError(t, err)
+			})
+			tc.test(t, ctx, s)
+		})
+		if t.Failed() {
+			break
+		}
+	}
+}
+
+func webAuthnCredentialsCreate(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	cred, err := s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+			SignCount:    3,
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "YubiKey", cred.Name)
+	assert.Equal(t, int64(3), cred.SignCount)
+
+	// Get it back and check the Created field
+	cred, err = s.GetByCredentialID(ctx, []byte("credential-1"))
+	require.NoError(t, err)
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), cred.Created.UTC().Format(time.RFC3339))
+
+	// Try to create another one with the same name
+	_, err = s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-2"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	assert.True(t, IsErrWebAuthnCredentialAlreadyExist(err))
+
+	// Try to register the same credential again, even for another user
+	_, err = s.Create(ctx, 2,
+		CreateWebAuthnCredentialOptions{
+			Name:         "Laptop",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	assert.True(t, IsErrWebAuthnCredentialAlreadyExist(err))
+
+	// The same name is fine for another user
+	_, err = s.Create(ctx, 2,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-3"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	require.NoError(t, err)
+}
+
+func webAuthnCredentialsGetByCredentialID(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	_, err := s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+			Passwordless: true,
+		},
+	)
+	require.NoError(t, err)
+
+	cred, err := s.GetByCredentialID(ctx, []byte("credential-1"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cred.UserID)
+	assert.Equal(t, []byte("credential-1"), cred.CredentialID)
+	assert.Equal(t, []byte("public-key"), cred.PublicKey)
+	assert.True(t, cred.Passwordless)
+
+	_, err = s.GetByCredentialID(ctx, []byte("404"))
+	assert.True(t, IsErrWebAuthnCredentialNotExist(err))
+}
+
+func webAuthnCredentialsList(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	for i, name := range []string{"YubiKey", "Laptop"} {
+		_, err := s.Create(ctx, 1,
+			CreateWebAuthnCredentialOptions{
+				Name:         name,
+				CredentialID: []byte{byte(i)},
+				PublicKey:    []byte("public-key"),
+			},
+		)
+		require.NoError(t, err)
+	}
+
+	creds, err := s.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	assert.Equal(t, "YubiKey", creds[0].Name)
+	assert.Equal(t, "Laptop", creds[1].Name)
+
+	creds, err = s.List(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, creds)
+}
+
+func webAuthnCredentialsIsEnabled(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	_, err := s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	require.NoError(t, err)
+
+	assert.True(t, s.IsEnabled(ctx, 1))
+	assert.False(t, s.IsEnabled(ctx, 2))
+}
+
+func webAuthnCredentialsUse(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	cred, err := s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	require.NoError(t, err)
+	assert.Zero(t, cred.LastUsedUnix)
+
+	err = s.Use(ctx, cred.ID, 7)
+	require.NoError(t, err)
+
+	cred, err = s.GetByCredentialID(ctx, []byte("credential-1"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), cred.SignCount)
+	assert.Equal(t, s.db.NowFunc().Format(time.RFC3339), cred.LastUsed.UTC().Format(time.RFC3339))
+}
+
+func webAuthnCredentialsDeleteByID(t *testing.T, ctx context.Context, s *WebAuthnCredentialsStore) {
+	cred, err := s.Create(ctx, 1,
+		CreateWebAuthnCredentialOptions{
+			Name:         "YubiKey",
+			CredentialID: []byte("credential-1"),
+			PublicKey:    []byte("public-key"),
+		},
+	)
+	require.NoError(t, err)
+
+	// Deleting the credential of another user does nothing
+	err = s.DeleteByID(ctx, 2, cred.ID)
+	require.NoError(t, err)
+	_, err = s.GetByCredentialID(ctx, []byte("credential-1"))
+	require.NoError(t, err)
+
+	err = s.DeleteByID(ctx, 1, cred.ID)
+	require.NoError(t, err)
+	_, err = s.GetByCredentialID(ctx, []byte("credential-1"))
+	assert.True(t, IsErrWebAuthnCredentialNotExist(err))
+}
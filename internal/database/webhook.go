@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/tls"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -63,14 +65,16 @@ func IsValidHookContentType(name string) bool {
 }
 
 type HookEvents struct {
-	Create       bool `json:"create"`
-	Delete       bool `json:"delete"`
-	Fork         bool `json:"fork"`
-	Push         bool `json:"push"`
-	Issues       bool `json:"issues"`
-	PullRequest  bool `json:"pull_request"`
-	IssueComment bool `json:"issue_comment"`
-	Release      bool `json:"release"`
+	Create            bool `json:"create"`
+	Delete            bool `json:"delete"`
+	Fork              bool `json:"fork"`
+	Push              bool `json:"push"`
+	Issues            bool `json:"issues"`
+	PullRequest       bool `json:"pull_request"`
+	IssueComment      bool `json:"issue_comment"`
+	Release           bool `json:"release"`
+	Status            bool `json:"status"`
+	PullRequestReview bool `json:"pull_request_review"`
 }
 
 // HookEvent represents events that will delivery hook.
@@ -105,6 +109,8 @@ type Webhook struct {
 	HookTaskType HookTaskType
 	Meta         string     `xorm:"TEXT"` // store hook-specific attributes
 	LastStatus   HookStatus // Last delivery status
+	// The number of deliveries in a row that failed after exhausting all attempts.
+	ConsecutiveFailures int
 
 	Created     time.Time `xorm:"-" json:"-" gorm:"-"`
 	CreatedUnix int64
@@ -204,13 +210,25 @@ func (w *Webhook) HasReleaseEvent() bool {
 		(w.ChooseEvents && w.Release)
 }
 
+// HasStatusEvent returns true if hook enabled status event.
+func (w *Webhook) HasStatusEvent() bool {
+	return w.SendEverything ||
+		(w.ChooseEvents && w.Status)
+}
+
+// HasPullRequestReviewEvent returns true if hook enabled pull request review event.
+func (w *Webhook) HasPullRequestReviewEvent() bool {
+	return w.SendEverything ||
+		(w.ChooseEvents && w.PullRequestReview)
+}
+
 type eventChecker struct {
 	checker func() bool
 	typ     HookEventType
 }
 
 func (w *Webhook) EventsArray() []string {
-	events := make([]string, 0, 8)
+	events := make([]string, 0, 10)
 	eventCheckers := []eventChecker{
 		{w.HasCreateEvent, HookEventTypeCreate},
 		{w.HasDeleteEvent, HookEventTypeDelete},
@@ -220,6 +238,8 @@ func (w *Webhook) EventsArray() []string {
 		{w.HasPullRequestEvent, HookEventTypePullRequest},
 		{w.HasIssueCommentEvent, HookEventTypeIssueComment},
 		{w.HasReleaseEvent, HookEventTypeRelease},
+		{w.HasStatusEvent, HookEventTypeStatus},
+		{w.HasPullRequestReviewEvent, HookEventTypePullRequestReview},
 	}
 	for _, c := range eventCheckers {
 		if c.checker() {
@@ -406,14 +426,16 @@ func IsValidHookTaskType(name string) bool {
 type HookEventType string
 
 const (
-	HookEventTypeCreate       HookEventType = "create"
-	HookEventTypeDelete       HookEventType = "delete"
-	HookEventTypeFork         HookEventType = "fork"
-	HookEventTypePush         HookEventType = "push"
-	HookEventTypeIssues       HookEventType = "issues"
-	HookEventTypePullRequest  HookEventType = "pull_request"
-	HookEventTypeIssueComment HookEventType = "issue_comment"
-	HookEventTypeRelease      HookEventType = "release"
+	HookEventTypeCreate            HookEventType = "create"
+	HookEventTypeDelete            HookEventType = "delete"
+	HookEventTypeFork              HookEventType = "fork"
+	HookEventTypePush              HookEventType = "push"
+	HookEventTypeIssues            HookEventType = "issues"
+	HookEventTypePullRequest       HookEventType = "pull_request"
+	HookEventTypeIssueComment      HookEventType = "issue_comment"
+	HookEventTypeRelease           HookEventType = "release"
+	HookEventTypeStatus            HookEventType = "status"
+	HookEventTypePullRequestReview HookEventType = "pull_request_review"
 )
 
 // HookRequest represents hook task request information.
@@ -446,6 +468,11 @@ type HookTask struct {
 	Delivered                   int64
 	DeliveredString             string `xorm:"-" json:"-" gorm:"-"`
 
+	// Retry info.
+	Attempts        int
+	NextAttemptUnix int64
+	NextAttempt     time.Time `xorm:"-" json:"-" gorm:"-"`
+
 	// History info.
 	IsSucceed       bool
 	RequestContent  string        `xorm:"TEXT"`
@@ -469,6 +496,9 @@ func (t *HookTask) AfterSet(colName string, _ xorm.Cell) {
 	case "delivered":
 		t.DeliveredString = time.Unix(0, t.Delivered).Format("2006-01-02 15:04:05 MST")
 
+	case "next_attempt_unix":
+		t.NextAttempt = time.Unix(t.NextAttemptUnix, 0).Local()
+
 	case "request_content":
 		if t.RequestContent == "" {
 			return
@@ -491,6 +521,12 @@ func (t *HookTask) AfterSet(colName string, _ xorm.Cell) {
 	}
 }
 
+// IsRetrying returns true if the hook task has failed at least once and is
+// waiting for its next attempt.
+func (t *HookTask) IsRetrying() bool {
+	return !t.IsDelivered && t.Attempts > 0
+}
+
 func (t *HookTask) ToJSON(v any) string {
 	p, err := json.Marshal(v)
 	if err != nil {
@@ -599,6 +635,14 @@ func prepareHookTasks(e Engine, repo *Repository, event HookEventType, p apiv1ty
 			if !w.HasReleaseEvent() {
 				continue
 			}
+		case HookEventTypeStatus:
+			if !w.HasStatusEvent() {
+				continue
+			}
+		case HookEventTypePullRequestReview:
+			if !w.HasPullRequestReviewEvent() {
+				continue
+			}
 		}
 
 		// Use separate objects so modifications won't be made on payload on non-Gogs type hooks.
@@ -692,19 +736,71 @@ func TestWebhook(repo *Repository, event HookEventType, p apiv1types.WebhookPayl
 	return prepareHookTasks(x, repo, event, p, []*Webhook{webhook})
 }
 
+// hookTaskBackoff returns the delay before the next attempt of a hook task that
+// has failed the given number of attempts. The delay starts at the configured
+// retry interval and doubles on every subsequent attempt, capped by the
+// configured maximum retry interval.
+func hookTaskBackoff(attempts int) time.Duration {
+	backoff := conf.Webhook.RetryInterval
+	for i := 1; i < attempts && backoff < conf.Webhook.MaxRetryInterval; i++ {
+		backoff *= 2
+	}
+	if backoff > conf.Webhook.MaxRetryInterval {
+		return conf.Webhook.MaxRetryInterval
+	}
+	return backoff
+}
+
+// deliver makes a single delivery attempt of the hook task. The task is marked
+// as delivered when the attempt succeeds or the maximum number of attempts has
+// been reached, otherwise the next attempt is scheduled with exponential
+// backoff.
 func (t *HookTask) deliver() {
+	t.Attempts++
+	t.IsSucceed = false
+	t.RequestInfo = &HookRequest{
+		Headers: map[string]string{},
+	}
+	t.ResponseInfo = &HookResponse{
+		Headers: map[string]string{},
+	}
+
+	defer func() {
+		now := time.Now()
+		t.Delivered = now.UnixNano()
+		t.DeliveredString = now.Format("2006-01-02 15:04:05 MST")
+		if t.IsSucceed {
+			t.IsDelivered = true
+			log.Trace("Hook delivered: %s", t.UUID)
+		} else if t.Attempts >= conf.Webhook.MaxAttempts {
+			t.IsDelivered = true
+			log.Trace("Hook delivery failed after %d attempt(s): %s", t.Attempts, t.UUID)
+		} else {
+			t.IsDelivered = false
+			t.NextAttempt = now.Add(hookTaskBackoff(t.Attempts))
+			t.NextAttemptUnix = t.NextAttempt.Unix()
+			log.Trace("Hook delivery failed (attempt %d), retry at %s: %s", t.Attempts, t.NextAttempt, t.UUID)
+		}
+
+		// Only settled deliveries affect the status of the webhook, a pending retry
+		// is neither a success nor a failure yet.
+		if t.IsDelivered {
+			if err := updateWebhookDeliveryStatus(t.HookID, t.IsSucceed); err != nil {
+				log.Error("Failed to update delivery status of webhook [id: %d]: %v", t.HookID, err)
+			}
+		}
+	}()
+
 	payloadURL, err := url.Parse(t.URL)
 	if err != nil {
-		t.ResponseContent = fmt.Sprintf(`{"body": "Cannot parse payload URL: %v"}`, err)
+		t.ResponseInfo.Body = fmt.Sprintf("Cannot parse payload URL: %v", err)
 		return
 	}
 	if netx.IsBlockedLocalHostname(payloadURL.Hostname(), conf.Security.LocalNetworkAllowlist) {
-		t.ResponseContent = `{"body": "Payload URL resolved to a local network address that is implicitly blocked."}`
+		t.ResponseInfo.Body = "Payload URL resolved to a local network address that is implicitly blocked."
 		return
 	}
 
-	t.IsDelivered = true
-
 	timeout := time.Duration(conf.Webhook.DeliverTimeout) * time.Second
 	req := httplib.Post(t.URL).SetTimeout(timeout, timeout).
 		Header("X-Github-Delivery", t.UUID).
@@ -712,6 +808,7 @@ func (t *HookTask) deliver() {
 		Header("X-Gogs-Delivery", t.UUID).
 		Header("X-Gogs-Signature", t.Signature).
 		Header("X-Gogs-Event", string(t.EventType)).
+		Header("X-Gogs-Delivery-Attempt", strconv.Itoa(t.Attempts)).
 		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: conf.Webhook.SkipTLSVerify}).
 		SetCheckRedirect(func(req *http.Request, _ []*http.Request) error {
 			// The webhook target is explicitly configured by the user, so any
@@ -728,42 +825,10 @@ func (t *HookTask) deliver() {
 	}
 
 	// Record delivery information.
-	t.RequestInfo = &HookRequest{
-		Headers: map[string]string{},
-	}
 	for k, vals := range req.Headers() {
 		t.RequestInfo.Headers[k] = strings.Join(vals, ",")
 	}
 
-	t.ResponseInfo = &HookResponse{
-		Headers: map[string]string{},
-	}
-
-	defer func() {
-		t.Delivered = time.Now().UnixNano()
-		if t.IsSucceed {
-			log.Trace("Hook delivered: %s", t.UUID)
-		} else {
-			log.Trace("Hook delivery failed: %s", t.UUID)
-		}
-
-		// Update webhook last delivery status.
-		w, err := GetWebhookByID(t.HookID)
-		if err != nil {
-			log.Error("GetWebhookByID: %v", err)
-			return
-		}
-		if t.IsSucceed {
-			w.LastStatus = HookStatusSucceed
-		} else {
-			w.LastStatus = HookStatusFailed
-		}
-		if err = UpdateWebhook(w); err != nil {
-			log.Error("UpdateWebhook: %v", err)
-			return
-		}
-	}()
-
 	resp, err := req.Response()
 	if err != nil {
 		t.ResponseInfo.Body = fmt.Sprintf("Delivery: %v", err)
@@ -786,41 +851,155 @@ func (t *HookTask) deliver() {
 	t.ResponseInfo.Body = string(p)
 }
 
-// DeliverHooks checks and delivers undelivered hooks.
-// TODO: shoot more hooks at same time.
-func DeliverHooks() {
-	tasks := make([]*HookTask, 0, 10)
-	_ = x.Where("is_delivered = ?", false).Iterate(new(HookTask),
-		func(idx int, bean any) error {
-			t := bean.(*HookTask)
-			t.deliver()
-			tasks = append(tasks, t)
-			return nil
-		})
+// updateWebhookDeliveryStatus records the outcome of a settled delivery to the
+// webhook. The webhook is deactivated once the number of consecutive failed
+// deliveries reaches the configured limit.
+func updateWebhookDeliveryStatus(webhookID int64, succeed bool) error {
+	w, err := GetWebhookByID(webhookID)
+	if err != nil {
+		return errors.Newf("GetWebhookByID: %v", err)
+	}
+
+	if succeed {
+		w.LastStatus = HookStatusSucceed
+		w.ConsecutiveFailures = 0
+	} else {
+		w.LastStatus = HookStatusFailed
+		w.ConsecutiveFailures++
+	}
+
+	deactivated := false
+	if !succeed && w.IsActive &&
+		conf.Webhook.MaxConsecutiveFailures > 0 &&
+		w.ConsecutiveFailures >= conf.Webhook.MaxConsecutiveFailures {
+		deactivated = true
+		w.IsActive = false
+		// Start over when the webhook is activated again by the user.
+		w.ConsecutiveFailures = 0
+	}
 
-	// Update hook task status.
+	_, err = x.ID(w.ID).Cols("last_status", "consecutive_failures", "is_active").Update(w)
+	if err != nil {
+		return errors.Newf("update webhook: %v", err)
+	}
+
+	if deactivated {
+		desc := fmt.Sprintf("Webhook [id: %d, repo_id: %d, org_id: %d] has been deactivated after %d consecutive failed deliveries",
+			w.ID, w.RepoID, w.OrgID, conf.Webhook.MaxConsecutiveFailures)
+		log.Warn("%s", desc)
+		if err = Handle.Notices().Create(context.TODO(), NoticeTypeRepository, desc); err != nil {
+			log.Error("CreateRepositoryNotice: %v", err)
+		}
+	}
+	return nil
+}
+
+// hookTaskRetryPollInterval is how often undelivered hook tasks are checked for
+// a due retry when there is no new delivery request.
+const hookTaskRetryPollInterval = 10 * time.Second
+
+// deliveringWebhooks tracks webhooks that currently have a delivery in
+// progress. Tasks of the same webhook are always delivered by a single worker
+// to keep them in order.
+var deliveringWebhooks = sync.NewStatusTable()
+
+// dispatchHookTasks hands out webhooks that have undelivered hook tasks to the
+// delivery workers, one worker per webhook. When repoID is non-zero, only
+// webhooks with tasks of that repository are dispatched. Tasks of inactive
+// webhooks are left undelivered. It blocks when all workers are busy.
+func dispatchHookTasks(workers chan struct{}, repoID int64) {
+	sess := x.Table("hook_task").Cols("hook_task.hook_id").
+		Join("INNER", "webhook", "webhook.id = hook_task.hook_id").
+		Where("hook_task.is_delivered = ?", false).
+		And("webhook.is_active = ?", true)
+	if repoID > 0 {
+		sess = sess.And("hook_task.repo_id = ?", repoID)
+	}
+	webhookIDs := make([]int64, 0, 10)
+	if err := sess.Distinct("hook_task.hook_id").Asc("hook_task.hook_id").Find(&webhookIDs); err != nil {
+		log.Error("Failed to get webhooks with undelivered hook tasks [repo_id: %d]: %v", repoID, err)
+		return
+	}
+
+	for _, webhookID := range webhookIDs {
+		name := strconv.FormatInt(webhookID, 10)
+		if deliveringWebhooks.IsRunning(name) {
+			// Remaining tasks are picked up by the next dispatch.
+			continue
+		}
+
+		// The webhook is marked as delivering before its tasks are loaded, so that
+		// no other worker can deliver the same tasks.
+		deliveringWebhooks.Start(name)
+		workers <- struct{}{}
+		go func(webhookID int64) {
+			defer func() {
+				<-workers
+				deliveringWebhooks.Stop(name)
+			}()
+
+			tasks := make([]*HookTask, 0, 10)
+			err := x.Where("hook_id = ? AND is_delivered = ?", webhookID, false).Asc("id").Find(&tasks)
+			if err != nil {
+				log.Error("Failed to get undelivered hook tasks [hook_id: %d]: %v", webhookID, err)
+				return
+			}
+			deliverHookTasks(tasks)
+		}(webhookID)
+	}
+}
+
+// deliverHookTasks delivers the given tasks of a single webhook in order. It
+// stops at the first task that is not due or fails to be delivered, so that a
+// later event never overtakes an earlier one.
+func deliverHookTasks(tasks []*HookTask) {
 	for _, t := range tasks {
+		if t.NextAttemptUnix > time.Now().Unix() {
+			return
+		}
+
+		t.deliver()
 		if err := UpdateHookTask(t); err != nil {
 			log.Error("UpdateHookTask [%d]: %v", t.ID, err)
+			return
+		}
+		if !t.IsDelivered {
+			return
 		}
 	}
+}
 
-	// Start listening on new hook requests.
-	for repoID := range HookQueue.Queue() {
-		log.Trace("DeliverHooks [repo_id: %v]", repoID)
-		HookQueue.Remove(repoID)
+// DeliverHooks checks and delivers undelivered hooks. Deliveries of different
+// webhooks are sent concurrently by a bounded number of workers, and failed
+// deliveries are retried with exponential backoff.
+func DeliverHooks() {
+	size := conf.Webhook.DeliverWorkers
+	if size <= 0 {
+		size = 1
+	}
+	workers := make(chan struct{}, size)
 
-		tasks = make([]*HookTask, 0, 5)
-		if err := x.Where("repo_id = ?", repoID).And("is_delivered = ?", false).Find(&tasks); err != nil {
-			log.Error("Get repository [%s] hook tasks: %v", repoID, err)
-			continue
-		}
-		for _, t := range tasks {
-			t.deliver()
-			if err := UpdateHookTask(t); err != nil {
-				log.Error("UpdateHookTask [%d]: %v", t.ID, err)
+	dispatchHookTasks(workers, 0)
+
+	ticker := time.NewTicker(hookTaskRetryPollInterval)
+	defer ticker.Stop()
+
+	// Start listening on new hook requests and due retries.
+	for {
+		select {
+		case repoID := <-HookQueue.Queue():
+			log.Trace("DeliverHooks [repo_id: %v]", repoID)
+			HookQueue.Remove(repoID)
+
+			id, err := strconv.ParseInt(repoID, 10, 64)
+			if err != nil {
+				log.Error("Failed to parse repository ID %q: %v", repoID, err)
 				continue
 			}
+			dispatchHookTasks(workers, id)
+
+		case <-ticker.C:
+			dispatchHookTasks(workers, 0)
 		}
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gogs/git-module"
 
 	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+	"gogs.io/gogs/internal/tool"
 )
 
 const (
@@ -71,8 +72,12 @@ func GetDingtalkPayload(p apiv1types.WebhookPayloader, event HookEventType) (pay
 		payload = getDingtalkIssueCommentPayload(p.(*apiv1types.WebhookIssueCommentPayload))
 	case HookEventTypePullRequest:
 		payload = getDingtalkPullRequestPayload(p.(*apiv1types.WebhookPullRequestPayload))
+	case HookEventTypePullRequestReview:
+		payload = getDingtalkPullRequestReviewPayload(p.(*apiv1types.WebhookPullRequestReviewPayload))
 	case HookEventTypeRelease:
 		payload = getDingtalkReleasePayload(p.(*apiv1types.WebhookReleasePayload))
+	case HookEventTypeStatus:
+		payload = getDingtalkStatusPayload(p.(*apiv1types.WebhookStatusPayload))
 	default:
 		return nil, errors.Errorf("unexpected event %q", event)
 	}
@@ -241,6 +246,23 @@ func getDingtalkPullRequestPayload(p *apiv1types.WebhookPullRequestPayload) *Din
 	}
 }
 
+func getDingtalkPullRequestReviewPayload(p *apiv1types.WebhookPullRequestReviewPayload) *DingtalkPayload {
+	pullRequestURL := fmt.Sprintf("%s/pulls/%d", p.Repository.HTMLURL, p.Index)
+
+	actionCard := NewDingtalkActionCard("View Pull Request", pullRequestURL)
+	actionCard.Text += "# Pull Request Review " + strings.Title(reviewStateText(p.Review.State))
+	actionCard.Text += "\n- PR: " + MarkdownLinkFormatter(pullRequestURL, fmt.Sprintf("#%d %s", p.Index, p.PullRequest.Title))
+	actionCard.Text += "\n- Reviewer: **" + p.Sender.UserName + "**"
+	if p.Review.Body != "" {
+		actionCard.Text += "\n> " + p.Review.Body
+	}
+
+	return &DingtalkPayload{
+		MsgType:    "actionCard",
+		ActionCard: actionCard,
+	}
+}
+
 func getDingtalkReleasePayload(p *apiv1types.WebhookReleasePayload) *DingtalkPayload {
 	releaseURL := p.Repository.HTMLURL + "/src/" + p.Release.TagName
 
@@ -272,3 +294,24 @@ func getDingtalkReleasePayload(p *apiv1types.WebhookReleasePayload) *DingtalkPay
 func MarkdownLinkFormatter(link, text string) string {
 	return "[" + text + "](" + link + ")"
 }
+
+func getDingtalkStatusPayload(p *apiv1types.WebhookStatusPayload) *DingtalkPayload {
+	commitURL := p.Repository.HTMLURL + "/commit/" + p.SHA
+	actionCard := NewDingtalkActionCard("View Commit", commitURL)
+	actionCard.Text += "# Commit Status Event"
+	actionCard.Text += "\n- Repo: **" + MarkdownLinkFormatter(p.Repository.HTMLURL, p.Repository.Name) + "**"
+	actionCard.Text += "\n- Commit: **" + MarkdownLinkFormatter(commitURL, tool.ShortSHA1(p.SHA)) + "**"
+	actionCard.Text += "\n- Context: **" + p.Context + "**"
+	actionCard.Text += "\n- State: **" + string(p.State) + "**"
+	if p.Description != "" {
+		actionCard.Text += "\n- Description: " + p.Description
+	}
+	if p.TargetURL != "" {
+		actionCard.Text += "\n- Details: " + MarkdownLinkFormatter(p.TargetURL, p.TargetURL)
+	}
+
+	return &DingtalkPayload{
+		MsgType:    "actionCard",
+		ActionCard: actionCard,
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+	"gogs.io/gogs/internal/tool"
 )
 
 type DiscordEmbedFooterObject struct {
@@ -351,6 +352,30 @@ func getDiscordPullRequestPayload(p *apiv1types.WebhookPullRequestPayload, slack
 	}
 }
 
+func getDiscordPullRequestReviewPayload(p *apiv1types.WebhookPullRequestReviewPayload, slack *SlackMeta) *DiscordPayload {
+	url := fmt.Sprintf("%s/pulls/%d", p.Repository.HTMLURL, p.Index)
+	title := fmt.Sprintf("Pull request review %s: #%d %s", reviewStateText(p.Review.State), p.Index, p.PullRequest.Title)
+
+	color, _ := strconv.ParseInt(strings.TrimLeft(slack.Color, "#"), 16, 32)
+	return &DiscordPayload{
+		Username:  slack.Username,
+		AvatarURL: slack.IconURL,
+		Embeds: []*DiscordEmbedObject{{
+			Title:       title,
+			Description: p.Review.Body,
+			URL:         url,
+			Color:       int(color),
+			Footer: &DiscordEmbedFooterObject{
+				Text: p.Repository.FullName,
+			},
+			Author: &DiscordEmbedAuthorObject{
+				Name:    p.Sender.UserName,
+				IconURL: p.Sender.AvatarURL,
+			},
+		}},
+	}
+}
+
 func getDiscordReleasePayload(p *apiv1types.WebhookReleasePayload) *DiscordPayload {
 	repoLink := DiscordLinkFormatter(p.Repository.HTMLURL, p.Repository.Name)
 	refLink := DiscordLinkFormatter(p.Repository.HTMLURL+"/src/"+p.Release.TagName, p.Release.TagName)
@@ -367,6 +392,25 @@ func getDiscordReleasePayload(p *apiv1types.WebhookReleasePayload) *DiscordPaylo
 	}
 }
 
+func getDiscordStatusPayload(p *apiv1types.WebhookStatusPayload) *DiscordPayload {
+	repoLink := DiscordLinkFormatter(p.Repository.HTMLURL, p.Repository.Name)
+	commitLink := DiscordSHALinkFormatter(p.Repository.HTMLURL+"/commit/"+p.SHA, tool.ShortSHA1(p.SHA))
+	content := fmt.Sprintf("%s: %s on commit %s of %s", p.Context, p.State, commitLink, repoLink)
+	if p.Description != "" {
+		content += "\n" + p.Description
+	}
+	return &DiscordPayload{
+		Embeds: []*DiscordEmbedObject{{
+			Description: content,
+			URL:         p.TargetURL,
+			Author: &DiscordEmbedAuthorObject{
+				Name:    p.Sender.UserName,
+				IconURL: p.Sender.AvatarURL,
+			},
+		}},
+	}
+}
+
 func GetDiscordPayload(p apiv1types.WebhookPayloader, event HookEventType, meta string) (payload *DiscordPayload, err error) {
 	slack := &SlackMeta{}
 	if err := json.Unmarshal([]byte(meta), slack); err != nil {
@@ -388,8 +432,12 @@ func GetDiscordPayload(p apiv1types.WebhookPayloader, event HookEventType, meta
 		payload = getDiscordIssueCommentPayload(p.(*apiv1types.WebhookIssueCommentPayload), slack)
 	case HookEventTypePullRequest:
 		payload = getDiscordPullRequestPayload(p.(*apiv1types.WebhookPullRequestPayload), slack)
+	case HookEventTypePullRequestReview:
+		payload = getDiscordPullRequestReviewPayload(p.(*apiv1types.WebhookPullRequestReviewPayload), slack)
 	case HookEventTypeRelease:
 		payload = getDiscordReleasePayload(p.(*apiv1types.WebhookReleasePayload))
+	case HookEventTypeStatus:
+		payload = getDiscordStatusPayload(p.(*apiv1types.WebhookStatusPayload))
 	default:
 		return nil, errors.Errorf("unexpected event %q", event)
 	}
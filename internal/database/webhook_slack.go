@@ -10,6 +10,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+	"gogs.io/gogs/internal/tool"
 )
 
 type SlackMeta struct {
@@ -274,6 +275,24 @@ func getSlackPullRequestPayload(p *apiv1types.WebhookPullRequestPayload, slack *
 	}
 }
 
+func getSlackPullRequestReviewPayload(p *apiv1types.WebhookPullRequestReviewPayload, slack *SlackMeta) *SlackPayload {
+	senderLink := SlackLinkFormatter(conf.Server.ExternalURL+p.Sender.UserName, p.Sender.UserName)
+	titleLink := SlackLinkFormatter(fmt.Sprintf("%s/pulls/%d", p.Repository.HTMLURL, p.Index),
+		fmt.Sprintf("#%d %s", p.Index, p.PullRequest.Title))
+	text := fmt.Sprintf("[%s] Pull request review %s: %s by %s", p.Repository.FullName, reviewStateText(p.Review.State), titleLink, senderLink)
+
+	return &SlackPayload{
+		Channel:  slack.Channel,
+		Text:     text,
+		Username: slack.Username,
+		IconURL:  slack.IconURL,
+		Attachments: []*SlackAttachment{{
+			Color: slack.Color,
+			Text:  SlackTextFormatter(p.Review.Body),
+		}},
+	}
+}
+
 func getSlackReleasePayload(p *apiv1types.WebhookReleasePayload) *SlackPayload {
 	repoLink := SlackLinkFormatter(p.Repository.HTMLURL, p.Repository.Name)
 	refLink := SlackLinkFormatter(p.Repository.HTMLURL+"/src/"+p.Release.TagName, p.Release.TagName)
@@ -283,6 +302,21 @@ func getSlackReleasePayload(p *apiv1types.WebhookReleasePayload) *SlackPayload {
 	}
 }
 
+func getSlackStatusPayload(p *apiv1types.WebhookStatusPayload, slack *SlackMeta) *SlackPayload {
+	repoLink := SlackLinkFormatter(p.Repository.HTMLURL, p.Repository.Name)
+	commitLink := SlackLinkFormatter(p.Repository.HTMLURL+"/commit/"+p.SHA, tool.ShortSHA1(p.SHA))
+	text := fmt.Sprintf("[%s] %s: %s on commit %s", repoLink, SlackTextFormatter(p.Context), p.State, commitLink)
+	if p.TargetURL != "" {
+		text += " " + SlackLinkFormatter(p.TargetURL, "details")
+	}
+	return &SlackPayload{
+		Channel:  slack.Channel,
+		Text:     text,
+		Username: slack.Username,
+		IconURL:  slack.IconURL,
+	}
+}
+
 func GetSlackPayload(p apiv1types.WebhookPayloader, event HookEventType, meta string) (payload *SlackPayload, err error) {
 	slack := &SlackMeta{}
 	if err := json.Unmarshal([]byte(meta), slack); err != nil {
@@ -304,8 +338,12 @@ func GetSlackPayload(p apiv1types.WebhookPayloader, event HookEventType, meta st
 		payload = getSlackIssueCommentPayload(p.(*apiv1types.WebhookIssueCommentPayload), slack)
 	case HookEventTypePullRequest:
 		payload = getSlackPullRequestPayload(p.(*apiv1types.WebhookPullRequestPayload), slack)
+	case HookEventTypePullRequestReview:
+		payload = getSlackPullRequestReviewPayload(p.(*apiv1types.WebhookPullRequestReviewPayload), slack)
 	case HookEventTypeRelease:
 		payload = getSlackReleasePayload(p.(*apiv1types.WebhookReleasePayload))
+	case HookEventTypeStatus:
+		payload = getSlackStatusPayload(p.(*apiv1types.WebhookStatusPayload), slack)
 	default:
 		return nil, errors.Errorf("unexpected event %q", event)
 	}
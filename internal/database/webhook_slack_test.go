@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apiv1types "gogs.io/gogs/internal/route/api/v1/types"
+)
+
+func TestGetSlackStatusPayload(t *testing.T) {
+	slack := &SlackMeta{
+		Channel:  "#builds",
+		Username: "gogs",
+		IconURL:  "https://gogs.example.com/icon.png",
+	}
+
+	tests := []struct {
+		name string
+		sha  string
+		want string
+	}{
+		{
+			name: "full SHA",
+			sha:  "f1d2d2f924e986ac86fdf7b36c94bcdf32beec15",
+			want: "[<https://gogs.example.com/alice/repo|repo>] ci: success on commit <https://gogs.example.com/alice/repo/commit/f1d2d2f924e986ac86fdf7b36c94bcdf32beec15|f1d2d2f924>",
+		},
+		{
+			name: "short SHA",
+			sha:  "f1d2",
+			want: "[<https://gogs.example.com/alice/repo|repo>] ci: success on commit <https://gogs.example.com/alice/repo/commit/f1d2|f1d2>",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &apiv1types.WebhookStatusPayload{
+				SHA:     test.sha,
+				State:   apiv1types.CommitStatusSuccess,
+				Context: "ci",
+				Repository: &apiv1types.Repository{
+					Name:    "repo",
+					HTMLURL: "https://gogs.example.com/alice/repo",
+				},
+			}
+			got := getSlackStatusPayload(p, slack)
+			assert.Equal(t, test.want, got.Text)
+			assert.Equal(t, slack.Channel, got.Channel)
+			assert.Equal(t, slack.Username, got.Username)
+			assert.Equal(t, slack.IconURL, got.IconURL)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func TestHookTaskBackoff(t *testing.T) {
+	conf.SetMockWebhook(t,
+		conf.WebhookOpts{
+			RetryInterval:    30 * time.Second,
+			MaxRetryInterval: 5 * time.Minute,
+		},
+	)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 5, want: 5 * time.Minute},
+		{attempts: 100, want: 5 * time.Minute},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, hookTaskBackoff(test.attempts), "attempts %d", test.attempts)
+	}
+}
+
+func TestDispatchHookTasks(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	engine := setTestDB(t)
+	conf.SetMockWebhook(t,
+		conf.WebhookOpts{
+			DeliverTimeout:   5,
+			MaxAttempts:      3,
+			RetryInterval:    time.Minute,
+			MaxRetryInterval: time.Hour,
+		},
+	)
+	beforeSecurity := conf.Security
+	conf.Security.LocalNetworkAllowlist = []string{"127.0.0.1"}
+	t.Cleanup(func() { conf.Security = beforeSecurity })
+
+	var mu sync.Mutex
+	deliveries := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Slow deliveries leave time for dispatches to race with the workers.
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		deliveries[r.Header.Get("X-Gogs-Delivery")]++
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	var taskIDs []int64
+	webhooks := map[string]*Webhook{
+		"active-1": {RepoID: 1, URL: server.URL, ContentType: JSON, IsActive: true},
+		"active-2": {RepoID: 2, URL: server.URL, ContentType: JSON, IsActive: true},
+		"inactive": {RepoID: 1, URL: server.URL, ContentType: JSON, IsActive: false},
+	}
+	for _, w := range webhooks {
+		_, err := engine.Insert(w)
+		require.NoError(t, err)
+
+		for range 3 {
+			task := &HookTask{
+				RepoID:         w.RepoID,
+				HookID:         w.ID,
+				UUID:           strconv.FormatInt(int64(len(taskIDs)), 10),
+				URL:            w.URL,
+				PayloadContent: "{}",
+				ContentType:    JSON,
+			}
+			_, err = engine.Insert(task)
+			require.NoError(t, err)
+			taskIDs = append(taskIDs, task.ID)
+		}
+	}
+
+	// Dispatches keep coming while earlier tasks are being delivered.
+	workers := make(chan struct{}, 2)
+	for range 20 {
+		dispatchHookTasks(workers, 0)
+		time.Sleep(time.Millisecond)
+	}
+	// Wait for all workers to finish.
+	for range cap(workers) {
+		workers <- struct{}{}
+	}
+
+	for _, id := range taskIDs {
+		task := new(HookTask)
+		_, err := engine.ID(id).Get(task)
+		require.NoError(t, err)
+
+		if task.HookID == webhooks["inactive"].ID {
+			assert.False(t, task.IsDelivered, "task %d", id)
+			assert.Zero(t, deliveries[task.UUID], "task %d", id)
+			continue
+		}
+		assert.True(t, task.IsSucceed, "task %d", id)
+		assert.Equal(t, 1, deliveries[task.UUID], "task %d", id)
+	}
+}
@@ -34,8 +34,9 @@ const (
 	tmplAuthResetPassword  = "auth/reset_passwd"
 	tmplAuthRegisterNotify = "auth/register_notify"
 
-	tmplIssueComment = "issue/comment"
-	tmplIssueMention = "issue/mention"
+	tmplIssueComment       = "issue/comment"
+	tmplIssueMention       = "issue/mention"
+	tmplIssueReviewRequest = "issue/review_request"
 
 	tmplNotifyCollaborator = "notify/collaborator"
 )
@@ -345,3 +346,17 @@ func SendIssueMentionMail(issue Issue, repo Repository, doer User, tos []string)
 	send(msg)
 	return nil
 }
+
+// SendReviewRequestMail composes and sends review request emails of a pull
+// request to target receivers.
+func SendReviewRequestMail(issue Issue, repo Repository, doer User, tos []string) error {
+	if len(tos) == 0 {
+		return nil
+	}
+	msg, err := composeIssueMessage(issue, repo, doer, tmplIssueReviewRequest, tos, "review request")
+	if err != nil {
+		return errors.Wrap(err, "compose issue message")
+	}
+	send(msg)
+	return nil
+}
@@ -6,38 +6,60 @@ import (
 )
 
 type Authentication struct {
-	ID                int64
-	Type              int    `binding:"Range(2,6)"`
-	Name              string `binding:"Required;MaxSize(30)"`
-	Host              string
-	Port              int
-	BindDN            string
-	BindPassword      string
-	UserBase          string
-	UserDN            string
-	AttributeUsername string
-	AttributeName     string
-	AttributeSurname  string
-	AttributeMail     string
-	AttributesInBind  bool
-	Filter            string
-	AdminFilter       string
-	GroupEnabled      bool
-	GroupDN           string
-	GroupFilter       string
-	GroupMemberUID    string
-	UserUID           string
-	IsActive          bool
-	IsDefault         bool
-	SMTPAuth          string
-	SMTPHost          string
-	SMTPPort          int
-	AllowedDomains    string
-	SecurityProtocol  int `binding:"Range(0,2)"`
-	TLS               bool
-	SkipVerify        bool
-	PAMServiceName    string
-	GitHubAPIEndpoint string `form:"github_api_endpoint" binding:"Url"`
+	ID                     int64
+	Type                   int    `binding:"Range(2,8)"`
+	Name                   string `binding:"Required;MaxSize(30)"`
+	Host                   string
+	Port                   int
+	BindDN                 string
+	BindPassword           string
+	UserBase               string
+	UserDN                 string
+	AttributeUsername      string
+	AttributeName          string
+	AttributeSurname       string
+	AttributeMail          string
+	AttributesInBind       bool
+	Filter                 string
+	AdminFilter            string
+	GroupEnabled           bool
+	GroupDN                string
+	GroupFilter            string
+	GroupMemberUID         string
+	UserUID                string
+	TeamSyncEnabled        bool
+	TeamGroupMap           string
+	IsActive               bool
+	IsDefault              bool
+	SMTPAuth               string
+	SMTPHost               string
+	SMTPPort               int
+	AllowedDomains         string
+	SecurityProtocol       int `binding:"Range(0,2)"`
+	TLS                    bool
+	SkipVerify             bool
+	PAMServiceName         string
+	GitHubAPIEndpoint      string `form:"github_api_endpoint" binding:"Url"`
+	OIDCDiscoveryURL       string `form:"oidc_discovery_url" binding:"Url"`
+	OIDCClientID           string `form:"oidc_client_id"`
+	OIDCClientSecret       string `form:"oidc_client_secret"`
+	OIDCScopes             string `form:"oidc_scopes"`
+	OIDCUsernameClaim      string `form:"oidc_username_claim"`
+	OIDCEmailClaim         string `form:"oidc_email_claim"`
+	OIDCFullNameClaim      string `form:"oidc_full_name_claim"`
+	OIDCGroupsClaim        string `form:"oidc_groups_claim"`
+	OIDCAdminGroup         string `form:"oidc_admin_group"`
+	SAMLIdPMetadataFile    string `form:"saml_idp_metadata_file"`
+	SAMLEntityID           string `form:"saml_entity_id"`
+	SAMLSigningCertFile    string `form:"saml_signing_cert_file"`
+	SAMLSigningKeyFile     string `form:"saml_signing_key_file"`
+	SAMLEncryptionCertFile string `form:"saml_encryption_cert_file"`
+	SAMLEncryptionKeyFile  string `form:"saml_encryption_key_file"`
+	SAMLUsernameAttribute  string `form:"saml_username_attribute"`
+	SAMLEmailAttribute     string `form:"saml_email_attribute"`
+	SAMLFullNameAttribute  string `form:"saml_full_name_attribute"`
+	SAMLGroupsAttribute    string `form:"saml_groups_attribute"`
+	SAMLAdminGroup         string `form:"saml_admin_group"`
 }
 
 func (f *Authentication) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
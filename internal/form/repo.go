@@ -43,6 +43,7 @@ type MigrateRepo struct {
 	UID          int64  `json:"uid" binding:"Required"`
 	RepoName     string `json:"repo_name" binding:"Required;AlphaDashDot;MaxSize(100)"`
 	Mirror       bool   `json:"mirror"`
+	LFS          bool   `json:"lfs"`
 	Private      bool   `json:"private"`
 	Unlisted     bool   `json:"unlisted"`
 	Description  string `json:"description" binding:"MaxSize(512)"`
@@ -106,21 +107,26 @@ type RepoSetting struct {
 	Private       bool
 	Unlisted      bool
 	EnablePrune   bool
+	EnableLFS     bool
 
 	// Advanced settings
-	EnableWiki            bool
-	AllowPublicWiki       bool
-	EnableExternalWiki    bool
-	ExternalWikiURL       string
-	EnableIssues          bool
-	AllowPublicIssues     bool
-	EnableExternalTracker bool
-	ExternalTrackerURL    string
-	TrackerURLFormat      string
-	TrackerIssueStyle     string
-	EnablePulls           bool
-	PullsIgnoreWhitespace bool
-	PullsAllowRebase      bool
+	EnableWiki                bool
+	AllowPublicWiki           bool
+	EnableExternalWiki        bool
+	ExternalWikiURL           string
+	EnableIssues              bool
+	AllowPublicIssues         bool
+	EnableExternalTracker     bool
+	ExternalTrackerURL        string
+	TrackerURLFormat          string
+	TrackerIssueStyle         string
+	EnablePulls               bool
+	PullsIgnoreWhitespace     bool
+	PullsAllowMergeCommit     bool
+	PullsAllowRebase          bool
+	PullsAllowSquash          bool
+	PullsAllowFastForwardOnly bool
+	PullsEnableMergeQueue     bool
 }
 
 func (f *RepoSetting) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -135,17 +141,30 @@ func (f *RepoSetting) Validate(ctx *macaron.Context, errs binding.Errors) bindin
 //         \/             \/     \/     \/     \/
 
 type ProtectBranch struct {
-	Protected          bool
-	RequirePullRequest bool
-	EnableWhitelist    bool
-	WhitelistUsers     string
-	WhitelistTeams     string
+	Protected              bool
+	RequirePullRequest     bool
+	RequiredStatusContexts string
+	RequiredApprovals      int
+	EnableWhitelist        bool
+	WhitelistUsers         string
+	WhitelistTeams         string
 }
 
 func (f *ProtectBranch) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type AddPushMirror struct {
+	Address  string `binding:"Required"`
+	Username string
+	Password string
+	Interval int
+}
+
+func (f *AddPushMirror) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 //  __      __      ___.   .__    .__            __
 // /  \    /  \ ____\_ |__ |  |__ |  |__   ____ |  | __
 // \   \/\/   // __ \| __ \|  |  \|  |  \ /  _ \|  |/ /
@@ -154,16 +173,18 @@ func (f *ProtectBranch) Validate(ctx *macaron.Context, errs binding.Errors) bind
 //        \/       \/    \/     \/     \/            \/
 
 type Webhook struct {
-	Events       string
-	Create       bool
-	Delete       bool
-	Fork         bool
-	Push         bool
-	Issues       bool
-	IssueComment bool
-	PullRequest  bool
-	Release      bool
-	Active       bool
+	Events            string
+	Create            bool
+	Delete            bool
+	Fork              bool
+	Push              bool
+	Issues            bool
+	IssueComment      bool
+	PullRequest       bool
+	PullRequestReview bool
+	Release           bool
+	Status            bool
+	Active            bool
 }
 
 func (f Webhook) PushOnly() bool {
@@ -253,6 +274,27 @@ func (f *CreateComment) Validate(ctx *macaron.Context, errs binding.Errors) bind
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type CreateCodeComment struct {
+	TreePath string `binding:"Required"`
+	Line     int64  `binding:"Required"`
+	CommitID string `binding:"Required;MaxSize(40)"`
+	Content  string `binding:"Required"`
+}
+
+func (f *CreateCodeComment) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+type SubmitReview struct {
+	State    string `binding:"Required;In(commented,approved,changes_requested)"`
+	CommitID string `binding:"MaxSize(40)"`
+	Content  string
+}
+
+func (f *SubmitReview) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 //    _____  .__.__                   __
 //   /     \ |__|  |   ____   _______/  |_  ____   ____   ____
 //  /  \ /  \|  |  | _/ __ \ /  ___/\   __\/  _ \ /    \_/ __ \
@@ -69,9 +69,22 @@ func (f *AddSSHKey) Validate(ctx *macaron.Context, errs binding.Errors) binding.
 }
 
 type NewAccessToken struct {
-	Name string `binding:"Required"`
+	Name         string `binding:"Required"`
+	Scopes       []string
+	Repositories string
+	ExpiresIn    int `binding:"Range(0,365)"` // In days, 0 means never expire.
 }
 
 func (f *NewAccessToken) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
+
+type OAuth2Application struct {
+	Name         string `binding:"Required;MaxSize(255)" locale:"settings.oauth2_application_name"`
+	RedirectURIs string `binding:"Required" locale:"settings.oauth2_redirect_uris"` // Separated by new lines.
+	Confidential bool
+}
+
+func (f *OAuth2Application) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
@@ -0,0 +1,184 @@
+package lfsx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// mediaType is the media type of requests and responses of the batch API.
+const mediaType = "application/vnd.git-lfs+json"
+
+// maxBatchSize is the maximum number of objects in a single batch request,
+// which matches the default of the Git LFS client.
+const maxBatchSize = 100
+
+// Client is a client of the batch API of a remote LFS server, see
+// https://github.com/git-lfs/git-lfs/blob/main/docs/api/batch.md.
+type Client struct {
+	// The URL of the LFS server, e.g. "https://example.com/owner/repo.git/info/lfs".
+	// Credentials in the URL are used for all requests to the same host.
+	Endpoint string
+	// The HTTP client for sending requests, http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+}
+
+// RemoteEndpoint returns the URL of the LFS server of the Git remote, which
+// must be an HTTP or HTTPS URL.
+func RemoteEndpoint(remoteURL string) (string, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("LFS objects can only be fetched from HTTP or HTTPS remotes")
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	u.Path += "/info/lfs"
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// BatchAction is an action of an object in a batch response.
+type BatchAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+// BatchError is the error of an object in a batch response.
+type BatchError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// BatchObject is an object in a batch response.
+type BatchObject struct {
+	OID     OID                    `json:"oid"`
+	Size    int64                  `json:"size"`
+	Actions map[string]BatchAction `json:"actions,omitempty"`
+	Error   *BatchError            `json:"error,omitempty"`
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// BatchDownload requests download actions of objects that given pointers point
+// to. Objects the server is unable to serve come with an error instead of the
+// "download" action.
+func (c *Client) BatchDownload(ctx context.Context, pointers []Pointer) ([]BatchObject, error) {
+	objects := make([]BatchObject, 0, len(pointers))
+	for start := 0; start < len(pointers); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(pointers))
+		batch, err := c.batchDownload(ctx, pointers[start:end])
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, batch...)
+	}
+	return objects, nil
+}
+
+func (c *Client) batchDownload(ctx context.Context, pointers []Pointer) ([]BatchObject, error) {
+	type requestObject struct {
+		OID  OID   `json:"oid"`
+		Size int64 `json:"size"`
+	}
+	body := struct {
+		Operation string          `json:"operation"`
+		Transfers []string        `json:"transfers"`
+		Objects   []requestObject `json:"objects"`
+		HashAlgo  string          `json:"hash_algo"`
+	}{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   make([]requestObject, 0, len(pointers)),
+		HashAlgo:  "sha256",
+	}
+	for _, p := range pointers {
+		body.Objects = append(body.Objects, requestObject{OID: p.OID, Size: p.Size})
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.Endpoint, "/")+"/objects/batch", bytes.NewReader(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "send request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("batch request: %s", responseError(resp))
+	}
+
+	var batch struct {
+		Objects []BatchObject `json:"objects"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	return batch.Objects, nil
+}
+
+// Download returns the content of an object from the href of its "download"
+// action. It is caller's responsibility to close the returned reader.
+func (c *Client) Download(ctx context.Context, action BatchAction) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.Href, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	for k, v := range action.Header {
+		req.Header.Set(k, v)
+	}
+
+	// Servers may expect the same credentials as the batch request instead of
+	// handing out dedicated ones in headers of the action.
+	endpoint, err := url.Parse(c.Endpoint)
+	if err == nil && endpoint.User != nil && req.Header.Get("Authorization") == "" &&
+		req.URL.User == nil && req.URL.Host == endpoint.Host {
+		req.URL.User = endpoint.User
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "send request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, errors.Newf("download: %s", responseError(resp))
+	}
+	return resp.Body, nil
+}
+
+// responseError returns a description of the unexpected response, including
+// the message in the body if the server sent one.
+func responseError(resp *http.Response) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body)
+	if body.Message != "" {
+		return fmt.Sprintf("%s: %s", resp.Status, body.Message)
+	}
+	return resp.Status
+}
@@ -0,0 +1,177 @@
+package lfsx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// maxPointerSize is the maximum size of a pointer file, see
+// https://github.com/git-lfs/git-lfs/blob/main/docs/spec.md#the-pointer.
+const maxPointerSize = 1024
+
+const pointerVersionLine = "version https://git-lfs.github.com/spec/v1"
+
+// Pointer is a pointer file committed to a Git repository in place of the
+// content of an LFS object.
+type Pointer struct {
+	OID  OID
+	Size int64
+}
+
+// ParsePointer parses the content of a pointer file. It returns false if the
+// content is not a valid pointer file.
+func ParsePointer(content []byte) (Pointer, bool) {
+	if len(content) > maxPointerSize {
+		return Pointer{}, false
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 3 || lines[0] != pointerVersionLine {
+		return Pointer{}, false
+	}
+
+	var p Pointer
+	size := ""
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return Pointer{}, false
+		}
+		switch key {
+		case "oid":
+			p.OID = OID(strings.TrimPrefix(value, "sha256:"))
+		case "size":
+			size = value
+		}
+	}
+
+	var err error
+	p.Size, err = strconv.ParseInt(size, 10, 64)
+	if err != nil || p.Size < 0 || !ValidOID(p.OID) {
+		return Pointer{}, false
+	}
+	return p, true
+}
+
+// ScanPointers returns pointer files of all blobs reachable from any reference
+// of the Git repository at given path. Each object appears only once even if
+// it is referenced by multiple pointer files.
+func ScanPointers(ctx context.Context, repoPath string) ([]Pointer, error) {
+	blobs, err := listPointerCandidates(ctx, repoPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "list candidates")
+	} else if len(blobs) == 0 {
+		return []Pointer{}, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(strings.Join(blobs, "\n") + "\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "read blobs: %s", stderr.String())
+	}
+
+	// Each blob is written as "<sha> <type> <size>\n<content>\n".
+	seen := make(map[OID]bool)
+	pointers := make([]Pointer, 0, len(blobs))
+	r := bufio.NewReader(&stdout)
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "read header")
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, errors.Newf("unexpected header %q", header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errors.Newf("unexpected header %q", header)
+		}
+
+		content := make([]byte, size+1)
+		if _, err = io.ReadFull(r, content); err != nil {
+			return nil, errors.Wrap(err, "read content")
+		}
+
+		p, ok := ParsePointer(content[:size])
+		if ok && !seen[p.OID] {
+			seen[p.OID] = true
+			pointers = append(pointers, p)
+		}
+	}
+	return pointers, nil
+}
+
+// listPointerCandidates returns IDs of blobs that are reachable from any
+// reference and small enough to be pointer files.
+func listPointerCandidates(ctx context.Context, repoPath string) ([]string, error) {
+	var revListStderr, batchCheckStderr bytes.Buffer
+	revList := exec.CommandContext(ctx, "git", "rev-list", "--objects", "--all")
+	revList.Dir = repoPath
+	revList.Stderr = &revListStderr
+	objects, err := revList.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "get stdout of rev-list")
+	}
+
+	// NOTE: With "%(rest)" in the format, "git cat-file" only takes the first
+	// field of each line from "git rev-list" as the object name.
+	batchCheck := exec.CommandContext(ctx, "git", "cat-file", "--batch-check=%(objectname) %(objecttype) %(objectsize) %(rest)")
+	batchCheck.Dir = repoPath
+	batchCheck.Stdin = objects
+	batchCheck.Stderr = &batchCheckStderr
+	output, err := batchCheck.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "get stdout of cat-file")
+	}
+
+	if err = revList.Start(); err != nil {
+		return nil, errors.Wrap(err, "start rev-list")
+	}
+	if err = batchCheck.Start(); err != nil {
+		_ = revList.Process.Kill()
+		_ = revList.Wait()
+		return nil, errors.Wrap(err, "start cat-file")
+	}
+
+	var blobs []string
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "blob" {
+			continue
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size > maxPointerSize {
+			continue
+		}
+		blobs = append(blobs, fields[0])
+	}
+	scanErr := scanner.Err()
+
+	revListErr := revList.Wait()
+	batchCheckErr := batchCheck.Wait()
+	switch {
+	case scanErr != nil:
+		return nil, errors.Wrap(scanErr, "read objects")
+	case revListErr != nil:
+		return nil, errors.Wrapf(revListErr, "rev-list: %s", revListStderr.String())
+	case batchCheckErr != nil:
+		return nil, errors.Wrapf(batchCheckErr, "cat-file: %s", batchCheckStderr.String())
+	}
+	return blobs, nil
+}
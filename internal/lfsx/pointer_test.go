@@ -0,0 +1,132 @@
+package lfsx
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		expPointer Pointer
+		expOK      bool
+	}{
+		{
+			name: "valid pointer",
+			content: `version https://git-lfs.github.com/spec/v1
+oid sha256:ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f
+size 12345
+`,
+			expPointer: Pointer{OID: "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", Size: 12345},
+			expOK:      true,
+		},
+		{
+			name: "valid pointer with extension",
+			content: `version https://git-lfs.github.com/spec/v1
+ext-0-foo sha256:c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a
+oid sha256:ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f
+size 12
+`,
+			expPointer: Pointer{OID: "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", Size: 12},
+			expOK:      true,
+		},
+		{
+			name:    "not a pointer",
+			content: "Hello world!",
+		},
+		{
+			name: "unknown version",
+			content: `version https://example.com/spec/v2
+oid sha256:ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f
+size 12
+`,
+		},
+		{
+			name: "invalid oid",
+			content: `version https://git-lfs.github.com/spec/v1
+oid sha256:bad_oid
+size 12
+`,
+		},
+		{
+			name: "missing size",
+			content: `version https://git-lfs.github.com/spec/v1
+oid sha256:ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, ok := ParsePointer([]byte(test.content))
+			assert.Equal(t, test.expPointer, p)
+			assert.Equal(t, test.expOK, ok)
+		})
+	}
+}
+
+func TestScanPointers(t *testing.T) {
+	repoPath := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "alice")
+	t.Setenv("GIT_AUTHOR_EMAIL", "alice@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "alice")
+	t.Setenv("GIT_COMMITTER_EMAIL", "alice@example.com")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	writeFile := func(name, content string) {
+		t.Helper()
+		err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0o644)
+		require.NoError(t, err)
+	}
+	pointer := func(oid OID, size string) string {
+		return pointerVersionLine + "\noid sha256:" + string(oid) + "\nsize " + size + "\n"
+	}
+
+	git("init", "--quiet")
+
+	// An empty repository has no pointers.
+	pointers, err := ScanPointers(context.Background(), repoPath)
+	require.NoError(t, err)
+	assert.Empty(t, pointers)
+
+	const oid1 = OID("ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f")
+	const oid2 = OID("c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a")
+	const oid3 = OID("185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969")
+	writeFile("a.bin", pointer(oid1, "100"))
+	writeFile("b.bin", pointer(oid1, "100"))
+	writeFile("README.md", "Hello world!")
+	writeFile("large.txt", strings.Repeat("x", 2*maxPointerSize))
+	git("add", ".")
+	git("commit", "--quiet", "-m", "first")
+
+	// Pointers in history and on other branches are referenced as well.
+	writeFile("a.bin", pointer(oid2, "200"))
+	git("commit", "--quiet", "-am", "second")
+	git("checkout", "--quiet", "-b", "feature")
+	writeFile("c.bin", pointer(oid3, "300"))
+	git("add", "c.bin")
+	git("commit", "--quiet", "-m", "third")
+
+	pointers, err = ScanPointers(context.Background(), repoPath)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Pointer{{OID: oid1, Size: 100}, {OID: oid2, Size: 200}, {OID: oid3, Size: 300}}, pointers)
+
+	// Pointers that are no longer reachable are not referenced.
+	git("checkout", "--quiet", "-")
+	git("branch", "--quiet", "-D", "feature")
+	pointers, err = ScanPointers(context.Background(), repoPath)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Pointer{{OID: oid1, Size: 100}, {OID: oid2, Size: 200}}, pointers)
+}
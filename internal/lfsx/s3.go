@@ -0,0 +1,570 @@
+package lfsx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+var _ PresignStorager = (*S3Storage)(nil)
+
+// S3Storage is a LFS storage backend on an S3-compatible object storage, e.g.
+// Amazon S3 and MinIO. Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	// The endpoint URL of the object storage, e.g. "https://s3.amazonaws.com".
+	Endpoint string
+	Region   string
+	Bucket   string
+	// The prefix of object keys, e.g. "lfs/".
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Whether to address the bucket in the path ("<endpoint>/<bucket>/<key>")
+	// instead of the host ("<bucket>.<endpoint>/<key>").
+	UsePathStyle bool
+	// How long presigned URLs are valid for.
+	PresignExpiry time.Duration
+	// The path for storing temporary files during upload verification.
+	TempDir string
+
+	// The HTTP client for sending requests, http.DefaultClient is used when nil.
+	Client *http.Client
+	// The function to return the current time, time.Now is used when nil.
+	now func() time.Time
+}
+
+func (*S3Storage) Storage() Storage {
+	return StorageS3
+}
+
+func (s *S3Storage) objectKey(oid OID) string {
+	if len(oid) < 2 {
+		return ""
+	}
+	return s.Prefix + string(oid[0]) + "/" + string(oid[1]) + "/" + string(oid)
+}
+
+// stagingPrefix returns the prefix of keys of objects uploaded via presigned
+// URLs but not yet committed.
+func (s *S3Storage) stagingPrefix() string {
+	return s.Prefix + "staging/"
+}
+
+// stagingKey returns the key of the object uploaded to the repository via a
+// presigned URL but not yet committed.
+func (s *S3Storage) stagingKey(repoID int64, oid OID) string {
+	return s.stagingPrefix() + strconv.FormatInt(repoID, 10) + "/" + string(oid)
+}
+
+func (s *S3Storage) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *S3Storage) timeNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// objectURL returns the URL of the object with given key.
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse endpoint")
+	}
+
+	if s.UsePathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return u, nil
+}
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty payload.
+var emptyPayloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// uriEncode encodes the string as required by AWS Signature Version 4, which
+// differs from url.PathEscape and url.QueryEscape in the set of unreserved
+// characters.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalHeaders returns the canonical headers and the signed headers of the
+// host and given headers.
+func canonicalHeaders(host string, header http.Header) (canonical, signed string) {
+	headers := map[string]string{"host": host}
+	for name, values := range header {
+		trimmed := make([]string, len(values))
+		for i := range values {
+			trimmed[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery returns the canonical query string of given query.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signature returns the credential scope and the signature of the canonical
+// request signed at the time.
+func (s *S3Storage) signature(canonicalRequest string, t time.Time) (scope, signature string) {
+	date := t.Format(s3DateFormat)
+	scope = date + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := s3Algorithm + "\n" + t.Format(s3TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signRequest signs the request with the Authorization header. All headers of
+// the request are signed.
+func (s *S3Storage) signRequest(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers, signedHeaders := canonicalHeaders(req.URL.Host, req.Header)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope, signature := s.signature(canonicalRequest, t)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// presign returns the presigned URL of the object with given key for the
+// method, which is valid for the duration. Given headers are signed and must be
+// sent as is along with the request.
+func (s *S3Storage) presign(method, key string, header http.Header, expires time.Duration, t time.Time) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	payloadHash := s3UnsignedPayload
+	if v := header.Get("X-Amz-Content-Sha256"); v != "" {
+		payloadHash = v
+	}
+
+	t = t.UTC()
+	headers, signedHeaders := canonicalHeaders(u.Host, header)
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3Algorithm},
+		"X-Amz-Credential":    {s.AccessKeyID + "/" + t.Format(s3DateFormat) + "/" + s.Region + "/s3/aws4_request"},
+		"X-Amz-Date":          {t.Format(s3TimeFormat)},
+		"X-Amz-Expires":       {strconv.FormatInt(int64(expires/time.Second), 10)},
+		"X-Amz-SignedHeaders": {signedHeaders},
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	_, signature := s.signature(canonicalRequest, t)
+
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// do sends a signed request for the object with given key. The body must be
+// nil if payloadHash is empty.
+func (s *S3Storage) do(method, key string, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.ContentLength = size
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+	}
+	s.signRequest(req, payloadHash, s.timeNow())
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "send request")
+	}
+	return resp, nil
+}
+
+// s3Error returns an error for the unexpected response, and closes the body.
+func s3Error(resp *http.Response) error {
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return errors.Newf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// stat returns the size of the object with given key. ErrObjectNotExist is
+// returned if the object does not exist.
+func (s *S3Storage) stat(key string) (int64, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, 0, "")
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, ErrObjectNotExist
+	default:
+		return 0, errors.Newf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func (s *S3Storage) delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Upload(oid OID, rc io.ReadCloser) (int64, error) {
+	if !ValidOID(oid) {
+		return 0, ErrInvalidOID
+	}
+
+	defer rc.Close()
+
+	// Like LocalStorage, the client must prove it has the original bytes even
+	// if the object already exists, and the object is only published after
+	// its content hash is verified.
+	tmpPath, written, err := writeVerifiedTempFile(s.TempDir, oid, rc)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	key := s.objectKey(oid)
+	if _, err = s.stat(key); err == nil {
+		return written, nil
+	} else if err != ErrObjectNotExist {
+		return 0, errors.Wrap(err, "stat object")
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return 0, errors.Wrap(err, "open temp file")
+	}
+	defer f.Close()
+
+	// The OID is the SHA-256 hash of the content, thus the object storage
+	// verifies the content again.
+	resp, err := s.do(http.MethodPut, key, nil, f, written, string(oid))
+	if err != nil {
+		return 0, errors.Wrap(err, "put object")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Wrap(s3Error(resp), "put object")
+	}
+	_ = resp.Body.Close()
+	return written, nil
+}
+
+func (s *S3Storage) Download(oid OID, w io.Writer) error {
+	resp, err := s.do(http.MethodGet, s.objectKey(oid), nil, nil, 0, "")
+	if err != nil {
+		return errors.Wrap(err, "get object")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return ErrObjectNotExist
+	} else if resp.StatusCode != http.StatusOK {
+		return errors.Wrap(s3Error(resp), "get object")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "copy object")
+	}
+	return nil
+}
+
+func (s *S3Storage) Walk(fn func(info ObjectInfo) error) error {
+	var continuationToken string
+	for {
+		result, err := s.list(s.Prefix, continuationToken)
+		if err != nil {
+			return errors.Wrap(err, "list objects")
+		}
+
+		for _, content := range result.Contents {
+			oid := OID(path.Base(content.Key))
+			// Skip staging objects and anything else that is not an object.
+			if !ValidOID(oid) || content.Key != s.objectKey(oid) {
+				continue
+			}
+
+			err = fn(ObjectInfo{
+				OID:     oid,
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// s3ListResult is the response payload of listing objects, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Storage) WalkUploads(fn func(repoID int64, info ObjectInfo) error) error {
+	var continuationToken string
+	for {
+		result, err := s.list(s.stagingPrefix(), continuationToken)
+		if err != nil {
+			return errors.Wrap(err, "list objects")
+		}
+
+		for _, content := range result.Contents {
+			// Keys are in the form of "<prefix>staging/<repoID>/<oid>".
+			repoIDStr, oid, _ := strings.Cut(strings.TrimPrefix(content.Key, s.stagingPrefix()), "/")
+			repoID, err := strconv.ParseInt(repoIDStr, 10, 64)
+			if err != nil || !ValidOID(OID(oid)) {
+				continue
+			}
+
+			err = fn(repoID, ObjectInfo{
+				OID:     OID(oid),
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// list returns a page of objects under the prefix, starting from the
+// continuation token returned by the previous page.
+func (s *S3Storage) list(prefix, continuationToken string) (*s3ListResult, error) {
+	u, err := s.objectURL("")
+	if err != nil {
+		return nil, err
+	}
+	// The bucket itself is requested rather than an object.
+	if s.UsePathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = uriEncode(u.Path, false)
+	}
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+	}
+	if continuationToken != "" {
+		query.Set("continuation-token", continuationToken)
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	s.signRequest(req, emptyPayloadHash, s.timeNow())
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "send request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result s3ListResult
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	return &result, nil
+}
+
+func (s *S3Storage) Delete(oid OID) error {
+	if !ValidOID(oid) {
+		return nil
+	}
+	return s.delete(s.objectKey(oid))
+}
+
+func (s *S3Storage) PresignUpload(repoID int64, oid OID) (string, map[string]string, error) {
+	if !ValidOID(oid) {
+		return "", nil, ErrInvalidOID
+	}
+
+	// Objects are uploaded to a staging key of the repository, and the signed
+	// payload hash makes the object storage reject content that does not match
+	// the OID. Therefore a client must have the original bytes to commit an
+	// object to the repository even if the object already exists.
+	header := map[string]string{
+		"X-Amz-Content-Sha256": string(oid),
+	}
+	href, err := s.presign(http.MethodPut, s.stagingKey(repoID, oid), http.Header{
+		"X-Amz-Content-Sha256": {string(oid)},
+	}, s.PresignExpiry, s.timeNow())
+	if err != nil {
+		return "", nil, err
+	}
+	return href, header, nil
+}
+
+func (s *S3Storage) CommitUpload(repoID int64, oid OID) (int64, error) {
+	if !ValidOID(oid) {
+		return 0, ErrInvalidOID
+	}
+
+	stagingKey := s.stagingKey(repoID, oid)
+	size, err := s.stat(stagingKey)
+	if err != nil {
+		return 0, err
+	}
+
+	// NOTE: A single copy request supports objects up to 5 GiB.
+	resp, err := s.do(http.MethodPut, s.objectKey(oid), http.Header{
+		"X-Amz-Copy-Source": {uriEncode("/"+s.Bucket+"/"+stagingKey, false)},
+	}, nil, 0, "")
+	if err != nil {
+		return 0, errors.Wrap(err, "copy object")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Wrap(s3Error(resp), "copy object")
+	}
+	_ = resp.Body.Close()
+
+	if err = s.delete(stagingKey); err != nil {
+		return 0, errors.Wrap(err, "delete staging object")
+	}
+	return size, nil
+}
+
+func (s *S3Storage) DeleteUpload(repoID int64, oid OID) error {
+	if !ValidOID(oid) {
+		return nil
+	}
+	return s.delete(s.stagingKey(repoID, oid))
+}
+
+func (s *S3Storage) PresignDownload(oid OID) (string, error) {
+	if !ValidOID(oid) {
+		return "", ErrInvalidOID
+	}
+	return s.presign(http.MethodGet, s.objectKey(oid), nil, s.PresignExpiry, s.timeNow())
+}
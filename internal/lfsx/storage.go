@@ -4,8 +4,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/cockroachdb/errors"
 
@@ -29,6 +31,45 @@ type Storager interface {
 	// responsibility the close the writer when needed. ErrObjectNotExist is
 	// returned if the given oid does not exist.
 	Download(oid OID, w io.Writer) error
+	// Walk calls fn for each object stored in the storage backend, in no
+	// particular order. It stops at the first error returned by fn.
+	Walk(fn func(info ObjectInfo) error) error
+	// Delete deletes the object of given oid. It is not an error if the object
+	// does not exist.
+	Delete(oid OID) error
+}
+
+// ObjectInfo is the information of an object stored in a storage backend.
+type ObjectInfo struct {
+	OID  OID
+	Size int64
+	// The time when the object was last modified, i.e. uploaded.
+	ModTime time.Time
+}
+
+// PresignStorager is a Storager that is able to hand out presigned URLs for
+// clients to upload and download objects directly without going through the
+// server.
+type PresignStorager interface {
+	Storager
+	// PresignUpload returns the URL along with headers to be set for uploading
+	// content of given oid to the repository. The uploaded object is not
+	// available for download until CommitUpload is called.
+	PresignUpload(repoID int64, oid OID) (href string, header map[string]string, err error)
+	// CommitUpload makes the object of given oid uploaded to the repository via
+	// the presigned URL available for download, and returns its size.
+	// ErrObjectNotExist is returned if no such object has been uploaded.
+	CommitUpload(repoID int64, oid OID) (int64, error)
+	// PresignDownload returns the URL for downloading content of given oid.
+	PresignDownload(oid OID) (string, error)
+	// WalkUploads calls fn for each object uploaded to a repository via the
+	// presigned URL that has not been committed, in no particular order. It
+	// stops at the first error returned by fn.
+	WalkUploads(fn func(repoID int64, info ObjectInfo) error) error
+	// DeleteUpload deletes the object of given oid uploaded to the repository
+	// via the presigned URL that has not been committed. It is not an error if
+	// the object does not exist.
+	DeleteUpload(repoID int64, oid OID) error
 }
 
 // Storage is the storage type of an LFS object.
@@ -36,6 +77,7 @@ type Storage string
 
 const (
 	StorageLocal Storage = "local"
+	StorageS3    Storage = "s3"
 )
 
 var _ Storager = (*LocalStorage)(nil)
@@ -92,33 +134,47 @@ func (s *LocalStorage) Upload(oid OID, rc io.ReadCloser) (int64, error) {
 	// Write to a temp file and verify the content hash before publishing.
 	// This ensures the final path always contains a complete, hash-verified
 	// file, even when concurrent uploads of the same OID race.
-	if err := os.MkdirAll(s.TempDir, os.ModePerm); err != nil {
-		return 0, errors.Wrap(err, "create temp directory")
+	tmpPath, written, err := writeVerifiedTempFile(s.TempDir, oid, rc)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Rename(tmpPath, fpath); err != nil && !os.IsExist(err) {
+		return 0, errors.Wrap(err, "publish object file")
 	}
-	tmp, err := os.CreateTemp(s.TempDir, "upload-*")
+	return written, nil
+}
+
+// writeVerifiedTempFile writes content from the io.Reader to a new temporary
+// file in the directory, and verifies the content hash matches the oid. It
+// returns the path of the temporary file, which is the caller's responsibility
+// to remove. ErrOIDMismatch is returned if the content hash does not match.
+func writeVerifiedTempFile(dir string, oid OID, r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", 0, errors.Wrap(err, "create temp directory")
+	}
+	tmp, err := os.CreateTemp(dir, "upload-*")
 	if err != nil {
-		return 0, errors.Wrap(err, "create temp file")
+		return "", 0, errors.Wrap(err, "create temp file")
 	}
 	tmpPath := tmp.Name()
-	defer os.Remove(tmpPath)
 
 	hash := sha256.New()
-	written, err := io.Copy(tmp, io.TeeReader(rc, hash))
+	written, err := io.Copy(tmp, io.TeeReader(r, hash))
 	if closeErr := tmp.Close(); err == nil && closeErr != nil {
 		err = closeErr
 	}
 	if err != nil {
-		return 0, errors.Wrap(err, "write object file")
+		_ = os.Remove(tmpPath)
+		return "", 0, errors.Wrap(err, "write object file")
 	}
 
 	if computed := hex.EncodeToString(hash.Sum(nil)); computed != string(oid) {
-		return 0, ErrOIDMismatch
+		_ = os.Remove(tmpPath)
+		return "", 0, ErrOIDMismatch
 	}
-
-	if err := os.Rename(tmpPath, fpath); err != nil && !os.IsExist(err) {
-		return 0, errors.Wrap(err, "publish object file")
-	}
-	return written, nil
+	return tmpPath, written, nil
 }
 
 func (s *LocalStorage) Download(oid OID, w io.Writer) error {
@@ -139,3 +195,46 @@ func (s *LocalStorage) Download(oid OID, w io.Writer) error {
 	}
 	return nil
 }
+
+func (s *LocalStorage) Walk(fn func(info ObjectInfo) error) error {
+	if !osx.IsDir(s.Root) {
+		return nil
+	}
+
+	return filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		oid := OID(d.Name())
+		if d.IsDir() || !ValidOID(oid) || path != s.storagePath(oid) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // The object was deleted in the meantime
+			}
+			return errors.Wrap(err, "stat file")
+		}
+		return fn(ObjectInfo{
+			OID:     oid,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+	})
+}
+
+func (s *LocalStorage) Delete(oid OID) error {
+	fpath := s.storagePath(oid)
+	if fpath == "" {
+		return nil
+	}
+
+	err := os.Remove(fpath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove file")
+	}
+	return nil
+}
@@ -147,3 +147,41 @@ func TestLocalStorage_Download(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalStorage_WalkAndDelete(t *testing.T) {
+	s := &LocalStorage{
+		Root: filepath.Join(t.TempDir(), "lfs-objects"),
+	}
+
+	// Walking a storage without any object
+	err := s.Walk(func(ObjectInfo) error {
+		t.Fatal("unexpected object")
+		return nil
+	})
+	require.NoError(t, err)
+
+	oid := OID("c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a")
+	fpath := s.storagePath(oid)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fpath), os.ModePerm))
+	require.NoError(t, os.WriteFile(fpath, []byte("Hello world!"), os.ModePerm))
+	// Files that are not objects should be skipped
+	require.NoError(t, os.WriteFile(filepath.Join(s.Root, "c", "README"), []byte("Hello"), os.ModePerm))
+
+	var infos []ObjectInfo
+	err = s.Walk(func(info ObjectInfo) error {
+		infos = append(infos, info)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, oid, infos[0].OID)
+	assert.Equal(t, int64(12), infos[0].Size)
+
+	err = s.Delete(oid)
+	require.NoError(t, err)
+	assert.False(t, osx.IsFile(fpath))
+
+	// Deleting an object that does not exist should not fail
+	err = s.Delete(oid)
+	require.NoError(t, err)
+}
@@ -0,0 +1,82 @@
+package lfsx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Token is a short-lived token that grants a user access to LFS objects of a
+// repository over HTTP. It is handed out to SSH users via the
+// "git-lfs-authenticate" command.
+type Token struct {
+	UserID int64
+	RepoID int64
+	// The operation the token is granted for, either "upload" or "download".
+	Operation string
+	ExpiresAt time.Time
+}
+
+func (t *Token) payload() string {
+	return fmt.Sprintf("%d:%d:%s:%d", t.UserID, t.RepoID, t.Operation, t.ExpiresAt.Unix())
+}
+
+func signToken(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Sign returns the string representation of the token signed with the secret.
+func (t *Token) Sign(secret string) string {
+	payload := t.payload()
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(signToken(secret, payload))
+}
+
+// ParseToken parses and verifies the signed token. It returns ErrInvalidToken
+// when the token is malformed, not signed with the secret or has expired.
+func ParseToken(secret, signed string) (*Token, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signToken(secret, string(payload))) {
+		return nil, ErrInvalidToken
+	}
+
+	fields := strings.Split(string(payload), ":")
+	if len(fields) != 4 {
+		return nil, ErrInvalidToken
+	}
+	userID, err1 := strconv.ParseInt(fields[0], 10, 64)
+	repoID, err2 := strconv.ParseInt(fields[1], 10, 64)
+	expiresAt, err3 := strconv.ParseInt(fields[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, ErrInvalidToken
+	}
+
+	t := &Token{
+		UserID:    userID,
+		RepoID:    repoID,
+		Operation: fields[2],
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
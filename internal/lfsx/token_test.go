@@ -0,0 +1,56 @@
+package lfsx
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	token := &Token{
+		UserID:    1,
+		RepoID:    2,
+		Operation: "upload",
+		ExpiresAt: time.Now().Add(time.Minute).Truncate(time.Second),
+	}
+	signed := token.Sign("secret")
+
+	got, err := ParseToken("secret", signed)
+	require.NoError(t, err)
+	assert.Equal(t, token.UserID, got.UserID)
+	assert.Equal(t, token.RepoID, got.RepoID)
+	assert.Equal(t, token.Operation, got.Operation)
+	assert.True(t, token.ExpiresAt.Equal(got.ExpiresAt))
+
+	t.Run("wrong secret", func(t *testing.T) {
+		_, err := ParseToken("another secret", signed)
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := (&Token{UserID: 1, RepoID: 3, Operation: "upload", ExpiresAt: token.ExpiresAt}).Sign("secret")
+		_, signature, _ := strings.Cut(signed, ".")
+		payload, _, _ := strings.Cut(tampered, ".")
+		_, err := ParseToken("secret", payload+"."+signature)
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := ParseToken("secret", "not-a-token")
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := &Token{
+			UserID:    1,
+			RepoID:    2,
+			Operation: "download",
+			ExpiresAt: time.Now().Add(-time.Second),
+		}
+		_, err := ParseToken("secret", expired.Sign("secret"))
+		assert.Equal(t, ErrInvalidToken, err)
+	})
+}
@@ -136,36 +136,47 @@ const (
 
 func Operation(c *context.Context) {
 	var err error
-	var success string
+	var success, name string
 	switch AdminOperation(c.QueryInt("op")) {
 	case CleanInactivateUser:
 		success = c.Tr("admin.dashboard.delete_inactivate_accounts_success")
+		name = "delete inactive accounts"
 		err = database.Handle.Users().DeleteInactivated()
 	case CleanRepoArchives:
 		success = c.Tr("admin.dashboard.delete_repo_archives_success")
+		name = "delete repository archives"
 		err = database.DeleteRepositoryArchives()
 	case CleanMissingRepos:
 		success = c.Tr("admin.dashboard.delete_missing_repos_success")
+		name = "delete missing repositories"
 		err = database.DeleteMissingRepositories()
 	case GitGCRepos:
 		success = c.Tr("admin.dashboard.git_gc_repos_success")
+		name = "garbage collect repositories"
 		err = database.GitGcRepos()
 	case SyncSSHAuthorizedKey:
 		success = c.Tr("admin.dashboard.resync_all_sshkeys_success")
+		name = "rewrite authorized keys"
 		err = database.RewriteAuthorizedKeys()
 	case SyncRepositoryHooks:
 		success = c.Tr("admin.dashboard.resync_all_hooks_success")
+		name = "resync repository hooks"
 		err = database.SyncRepositoryHooks()
 	case ReinitMissingRepository:
 		success = c.Tr("admin.dashboard.reinit_missing_repos_success")
+		name = "reinitialize missing repositories"
 		err = database.ReinitMissingRepositories()
 	}
 
 	if err != nil {
 		c.Flash.Error(err.Error())
+		name += " (failed)"
 	} else {
 		c.Flash.Success(success)
 	}
+	if success != "" {
+		c.AuditLog(database.AuditActionAdminOperation, database.AuditTarget{Type: database.AuditTargetSystem}, name)
+	}
 	c.RedirectSubpath("/admin")
 }
 
@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/unknwon/paginater"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+)
+
+const (
+	AUDIT_LOGS = "admin/audit_log"
+)
+
+// auditLogDateLayout is the layout of dates in the filters of audit logs.
+const auditLogDateLayout = "2006-01-02"
+
+// parseAuditLogsOptions parses the filters of audit logs from the query. The
+// "since" and "until" dates are both inclusive.
+func parseAuditLogsOptions(c *context.Context) (database.ListAuditLogsOptions, bool) {
+	opts := database.ListAuditLogsOptions{
+		Actor:     strings.TrimSpace(c.Query("actor")),
+		Action:    strings.TrimSpace(c.Query("action")),
+		Target:    strings.TrimSpace(c.Query("target")),
+		IPAddress: strings.TrimSpace(c.Query("ip")),
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.ParseInLocation(auditLogDateLayout, since, time.Local)
+		if err != nil {
+			return opts, false
+		}
+		opts.Since = t
+	}
+	if until := c.Query("until"); until != "" {
+		t, err := time.ParseInLocation(auditLogDateLayout, until, time.Local)
+		if err != nil {
+			return opts, false
+		}
+		opts.Until = t.AddDate(0, 0, 1)
+	}
+	return opts, true
+}
+
+func AuditLogs(c *context.Context) {
+	c.Title("admin.audit_logs")
+	c.Data["PageIsAdmin"] = true
+	c.Data["PageIsAdminAuditLogs"] = true
+
+	// Keep the filters in the form and the links of pagination.
+	filters := make(map[string]string)
+	query := make(url.Values)
+	for _, name := range []string{"actor", "action", "target", "ip", "since", "until"} {
+		v := c.Query(name)
+		filters[name] = v
+		if v != "" {
+			query.Set(name, v)
+		}
+	}
+	c.Data["Filters"] = filters
+	c.Data["Query"] = template.URL(query.Encode())
+
+	opts, ok := parseAuditLogsOptions(c)
+	if !ok {
+		c.Data["Total"] = 0
+		c.RenderWithErr(c.Tr("admin.audit_logs.invalid_date"), http.StatusBadRequest, AUDIT_LOGS, nil)
+		return
+	}
+
+	total, err := database.Handle.AuditLogs().Count(c.Req.Context(), opts)
+	if err != nil {
+		c.Error(err, "count audit logs")
+		return
+	}
+	page := max(c.QueryInt("page"), 1)
+	c.Data["Page"] = paginater.New(int(total), conf.UI.Admin.AuditLogPagingNum, page, 5)
+
+	logs, err := database.Handle.AuditLogs().List(c.Req.Context(), opts, page, conf.UI.Admin.AuditLogPagingNum)
+	if err != nil {
+		c.Error(err, "list audit logs")
+		return
+	}
+	c.Data["AuditLogs"] = logs
+
+	c.Data["Total"] = total
+	c.Success(AUDIT_LOGS)
+}
+
+// ExportAuditLogs streams audit logs that match the filters as JSON lines, in
+// ascending order of creation.
+func ExportAuditLogs(c *context.Context) {
+	opts, ok := parseAuditLogsOptions(c)
+	if !ok {
+		c.PlainText(http.StatusBadRequest, c.Tr("admin.audit_logs.invalid_date"))
+		return
+	}
+
+	c.Resp.Header().Set("Content-Type", "application/jsonl; charset=utf-8")
+	c.Resp.Header().Set("Content-Disposition", `attachment; filename="audit_logs.jsonl"`)
+	c.Resp.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(c.Resp)
+	err := database.Handle.AuditLogs().Iterate(c.Req.Context(), opts, func(l *database.AuditLog) error {
+		return enc.Encode(l)
+	})
+	if err != nil {
+		// The response has been started, the error can only be logged.
+		log.Error("Failed to export audit logs: %v", err)
+		return
+	}
+	log.Trace("Audit logs exported by admin (%s)", c.User.Name)
+}
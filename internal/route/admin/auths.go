@@ -11,7 +11,9 @@ import (
 	"gogs.io/gogs/internal/auth"
 	"gogs.io/gogs/internal/auth/github"
 	"gogs.io/gogs/internal/auth/ldap"
+	"gogs.io/gogs/internal/auth/oidc"
 	"gogs.io/gogs/internal/auth/pam"
+	"gogs.io/gogs/internal/auth/saml"
 	"gogs.io/gogs/internal/auth/smtp"
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
@@ -53,6 +55,8 @@ var (
 		{auth.Name(auth.SMTP), auth.SMTP},
 		{auth.Name(auth.PAM), auth.PAM},
 		{auth.Name(auth.GitHub), auth.GitHub},
+		{auth.Name(auth.OIDC), auth.OIDC},
+		{auth.Name(auth.SAML), auth.SAML},
 	}
 	securityProtocols = []dropdownItem{
 		{ldap.SecurityProtocolName(ldap.SecurityProtocolUnencrypted), ldap.SecurityProtocolUnencrypted},
@@ -99,6 +103,8 @@ func parseLDAPConfig(f form.Authentication) *ldap.Config {
 		GroupFilter:       f.GroupFilter,
 		GroupMemberUID:    f.GroupMemberUID,
 		UserUID:           f.UserUID,
+		TeamSyncEnabled:   f.TeamSyncEnabled,
+		TeamGroupMap:      f.TeamGroupMap,
 		AdminFilter:       f.AdminFilter,
 	}
 }
@@ -114,6 +120,37 @@ func parseSMTPConfig(f form.Authentication) *smtp.Config {
 	}
 }
 
+func parseOIDCConfig(f form.Authentication) *oidc.Config {
+	return &oidc.Config{
+		DiscoveryURL:  f.OIDCDiscoveryURL,
+		ClientID:      f.OIDCClientID,
+		ClientSecret:  f.OIDCClientSecret,
+		Scopes:        f.OIDCScopes,
+		UsernameClaim: f.OIDCUsernameClaim,
+		EmailClaim:    f.OIDCEmailClaim,
+		FullNameClaim: f.OIDCFullNameClaim,
+		GroupsClaim:   f.OIDCGroupsClaim,
+		AdminGroup:    f.OIDCAdminGroup,
+		SkipVerify:    f.SkipVerify,
+	}
+}
+
+func parseSAMLConfig(f form.Authentication) *saml.Config {
+	return &saml.Config{
+		IdPMetadataFile:    f.SAMLIdPMetadataFile,
+		EntityID:           f.SAMLEntityID,
+		SigningCertFile:    f.SAMLSigningCertFile,
+		SigningKeyFile:     f.SAMLSigningKeyFile,
+		EncryptionCertFile: f.SAMLEncryptionCertFile,
+		EncryptionKeyFile:  f.SAMLEncryptionKeyFile,
+		UsernameAttribute:  f.SAMLUsernameAttribute,
+		EmailAttribute:     f.SAMLEmailAttribute,
+		FullNameAttribute:  f.SAMLFullNameAttribute,
+		GroupsAttribute:    f.SAMLGroupsAttribute,
+		AdminGroup:         f.SAMLAdminGroup,
+	}
+}
+
 func NewAuthSourcePost(c *context.Context, f form.Authentication) {
 	c.Title("admin.auths.new")
 	c.PageIs("Admin")
@@ -144,6 +181,11 @@ func NewAuthSourcePost(c *context.Context, f form.Authentication) {
 			SkipVerify:  f.SkipVerify,
 		}
 		hasTLS = true
+	case auth.OIDC:
+		config = parseOIDCConfig(f)
+		hasTLS = true
+	case auth.SAML:
+		config = parseSAMLConfig(f)
 	default:
 		c.Status(http.StatusBadRequest)
 		return
@@ -155,6 +197,12 @@ func NewAuthSourcePost(c *context.Context, f form.Authentication) {
 		return
 	}
 
+	if _, err := ldap.ParseTeamMappings(f.TeamGroupMap); f.TeamSyncEnabled && err != nil {
+		c.FormErr("TeamGroupMap")
+		c.RenderWithErr(c.Tr("admin.auths.invalid_team_group_map", err), http.StatusBadRequest, tmplAdminAuthNew, f)
+		return
+	}
+
 	source, err := database.Handle.LoginSources().Create(c.Req.Context(),
 		database.CreateLoginSourceOptions{
 			Type:      auth.Type(f.Type),
@@ -183,6 +231,7 @@ func NewAuthSourcePost(c *context.Context, f form.Authentication) {
 	}
 
 	log.Trace("Authentication created by admin(%s): %s", c.User.Name, f.Name)
+	c.AuditLog(database.AuditActionAdminAuthSourceCreate, database.AuditTargetOfLoginSource(source), "")
 
 	c.Flash.Success(c.Tr("admin.auths.new_success", f.Name))
 	c.Redirect(conf.Server.Subpath + "/admin/auths")
@@ -227,6 +276,12 @@ func EditAuthSourcePost(c *context.Context, f form.Authentication) {
 		return
 	}
 
+	if _, err := ldap.ParseTeamMappings(f.TeamGroupMap); f.TeamSyncEnabled && err != nil {
+		c.FormErr("TeamGroupMap")
+		c.RenderWithErr(c.Tr("admin.auths.invalid_team_group_map", err), http.StatusBadRequest, tmplAdminAuthEdit, f)
+		return
+	}
+
 	var provider auth.Provider
 	switch auth.Type(f.Type) {
 	case auth.LDAP:
@@ -244,6 +299,10 @@ func EditAuthSourcePost(c *context.Context, f form.Authentication) {
 			APIEndpoint: strings.TrimSuffix(f.GitHubAPIEndpoint, "/") + "/",
 			SkipVerify:  f.SkipVerify,
 		})
+	case auth.OIDC:
+		provider = oidc.NewProvider(parseOIDCConfig(f))
+	case auth.SAML:
+		provider = saml.NewProvider(parseSAMLConfig(f))
 	default:
 		c.Status(http.StatusBadRequest)
 		return
@@ -267,6 +326,7 @@ func EditAuthSourcePost(c *context.Context, f form.Authentication) {
 	}
 
 	log.Trace("Authentication changed by admin '%s': %d", c.User.Name, source.ID)
+	c.AuditLog(database.AuditActionAdminAuthSourceUpdate, database.AuditTargetOfLoginSource(source), "")
 
 	c.Flash.Success(c.Tr("admin.auths.update_success"))
 	c.Redirect(conf.Server.Subpath + "/admin/auths/" + strconv.FormatInt(f.ID, 10))
@@ -274,6 +334,12 @@ func EditAuthSourcePost(c *context.Context, f form.Authentication) {
 
 func DeleteAuthSource(c *context.Context) {
 	id := c.ParamsInt64(":authid")
+	source, err := database.Handle.LoginSources().GetByID(c.Req.Context(), id)
+	if err != nil {
+		c.NotFoundOrError(err, "get login source by ID")
+		return
+	}
+
 	if err := database.Handle.LoginSources().DeleteByID(c.Req.Context(), id); err != nil {
 		if database.IsErrLoginSourceInUse(err) {
 			c.Flash.Error(c.Tr("admin.auths.still_in_used"))
@@ -286,6 +352,7 @@ func DeleteAuthSource(c *context.Context) {
 		return
 	}
 	log.Trace("Authentication deleted by admin(%s): %d", c.User.Name, id)
+	c.AuditLog(database.AuditActionAdminAuthSourceDelete, database.AuditTargetOfLoginSource(source), "")
 
 	c.Flash.Success(c.Tr("admin.auths.deletion_success"))
 	c.JSONSuccess(map[string]any{
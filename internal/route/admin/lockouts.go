@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"github.com/unknwon/paginater"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+)
+
+const (
+	LOCKOUTS = "admin/lockout"
+)
+
+func Lockouts(c *context.Context) {
+	c.Title("admin.lockouts")
+	c.Data["PageIsAdmin"] = true
+	c.Data["PageIsAdminLockouts"] = true
+
+	total := database.Handle.LoginFailures().CountLocked(c.Req.Context())
+	page := max(c.QueryInt("page"), 1)
+	c.Data["Page"] = paginater.New(int(total), conf.UI.Admin.LockoutPagingNum, page, 5)
+
+	lockouts, err := database.Handle.LoginFailures().ListLocked(c.Req.Context(), page, conf.UI.Admin.LockoutPagingNum)
+	if err != nil {
+		c.Error(err, "list lockouts")
+		return
+	}
+	c.Data["Lockouts"] = lockouts
+
+	c.Data["Total"] = total
+	c.Success(LOCKOUTS)
+}
+
+func ClearLockout(c *context.Context) {
+	f, err := database.Handle.LoginFailures().GetByID(c.Req.Context(), c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get login failure by ID")
+		return
+	}
+
+	if err = database.Handle.LoginFailures().DeleteByID(c.Req.Context(), f.ID); err != nil {
+		c.Error(err, "delete login failure by ID")
+		return
+	}
+	log.Trace("Lockout of %s %q cleared by admin (%s)", f.Kind, f.Name, c.User.Name)
+
+	target := database.AuditTarget{Type: database.AuditTargetUser, Name: f.Name}
+	if f.Kind == database.LoginFailureIP {
+		target.Type = database.AuditTargetIPAddress
+	}
+	c.AuditLog(database.AuditActionAdminLockoutClear, target, "")
+
+	c.Flash.Success(c.Tr("admin.lockouts.clear_success", f.Name))
+	c.Redirect(conf.Server.Subpath + "/admin/lockouts")
+}
@@ -75,6 +75,7 @@ func DeleteRepo(c *context.Context) {
 		return
 	}
 	log.Trace("Repository deleted: %s/%s", repo.MustOwner().Name, repo.Name)
+	c.AuditLog(database.AuditActionRepoDelete, database.AuditTargetOfRepository(repo), "")
 
 	c.Flash.Success(c.Tr("repo.settings.deletion_success"))
 	c.JSONSuccess(map[string]any{
@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -103,6 +104,7 @@ func NewUserPost(c *context.Context, f form.AdminCrateUser) {
 		return
 	}
 	log.Trace("Account %q created by admin %q", user.Name, c.User.Name)
+	c.AuditLog(database.AuditActionAdminUserCreate, database.AuditTargetOfUser(user), "")
 
 	// Send email notification.
 	if f.SendNotify && conf.Email.Enabled {
@@ -213,11 +215,45 @@ func EditUserPost(c *context.Context, f form.AdminEditUser) {
 		return
 	}
 	log.Trace("Account updated by admin %q: %s", c.User.Name, u.Name)
+	c.AuditLog(database.AuditActionAdminUserUpdate, database.AuditTargetOfUser(u), database.AuditDetailsOfUserUpdate(u, opts))
+
+	// Sign the user out everywhere when the password has been reset or the
+	// account has been suspended.
+	if opts.Password != nil || !f.Active || f.ProhibitLogin {
+		if u.ID == c.User.ID {
+			err = c.RevokeOtherLoginSessions()
+		} else {
+			_, err = database.Handle.LoginSessions().DeleteByUserID(c.Req.Context(), u.ID, 0)
+		}
+		if err != nil {
+			c.Error(err, "revoke login sessions")
+			return
+		}
+	}
 
 	c.Flash.Success(c.Tr("admin.users.update_profile_success"))
 	c.Redirect(conf.Server.Subpath + "/admin/users/" + c.Params(":userid"))
 }
 
+func RevokeUserSessions(c *context.Context) {
+	u, err := database.Handle.Users().GetByID(c.Req.Context(), c.ParamsInt64(":userid"))
+	if err != nil {
+		c.NotFoundOrError(err, "get user by ID")
+		return
+	}
+
+	revoked, err := database.Handle.LoginSessions().DeleteByUserID(c.Req.Context(), u.ID, 0)
+	if err != nil {
+		c.Error(err, "revoke login sessions")
+		return
+	}
+	log.Trace("Sessions of account revoked by admin (%s): %s", c.User.Name, u.Name)
+	c.AuditLog(database.AuditActionAdminSessionsRevoke, database.AuditTargetOfUser(u), fmt.Sprintf("%d sessions", revoked))
+
+	c.Flash.Success(c.Tr("admin.users.revoke_sessions_success", revoked))
+	c.Redirect(conf.Server.Subpath + "/admin/users/" + c.Params(":userid"))
+}
+
 func DeleteUser(c *context.Context) {
 	u, err := database.Handle.Users().GetByID(c.Req.Context(), c.ParamsInt64(":userid"))
 	if err != nil {
@@ -243,6 +279,7 @@ func DeleteUser(c *context.Context) {
 		return
 	}
 	log.Trace("Account deleted by admin (%s): %s", c.User.Name, u.Name)
+	c.AuditLog(database.AuditActionAdminUserDelete, database.AuditTargetOfUser(u), "")
 
 	c.Flash.Success(c.Tr("admin.users.deletion_success"))
 	c.JSONSuccess(map[string]any{
@@ -10,6 +10,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/gitx"
 	"gogs.io/gogs/internal/markup"
 	"gogs.io/gogs/internal/route/api/v1/types"
 )
@@ -211,6 +212,101 @@ func toIssue(issue *database.Issue) *types.Issue {
 	return apiIssue
 }
 
+// toPullRequest converts a database pull request to an API pull request.
+// It assumes the following fields have been assigned with valid values:
+// Required - Issue, BaseRepo
+// Optional - HeadRepo, Merger
+func toPullRequest(pr *database.PullRequest) *types.PullRequest {
+	// The head repository may have been deleted after the pull request was sent.
+	headRepo := &types.Repository{Name: "deleted"}
+	if pr.HeadRepo != nil {
+		headRepo = toRepository(pr.HeadRepo, nil)
+	}
+
+	apiIssue := toIssue(pr.Issue)
+	apiPullRequest := &types.PullRequest{
+		ID:         pr.ID,
+		Index:      pr.Index,
+		Poster:     apiIssue.Poster,
+		Title:      apiIssue.Title,
+		Body:       apiIssue.Body,
+		Labels:     apiIssue.Labels,
+		Milestone:  apiIssue.Milestone,
+		Assignee:   apiIssue.Assignee,
+		State:      apiIssue.State,
+		Comments:   apiIssue.Comments,
+		HeadBranch: pr.HeadBranch,
+		HeadRepo:   headRepo,
+		BaseBranch: pr.BaseBranch,
+		BaseRepo:   toRepository(pr.BaseRepo, nil),
+		HTMLURL:    pr.Issue.HTMLURL(),
+		HasMerged:  pr.HasMerged,
+	}
+
+	if !pr.IsChecking() {
+		mergeable := pr.CanAutoMerge()
+		apiPullRequest.Mergeable = &mergeable
+	}
+	if pr.HasMerged {
+		apiPullRequest.Merged = &pr.Merged
+		apiPullRequest.MergedCommitID = &pr.MergedCommitID
+		apiPullRequest.MergedBy = toUser(pr.Merger)
+	}
+	return apiPullRequest
+}
+
+// toPullRequestFile converts a file of the pull request diff to an API pull
+// request file.
+func toPullRequestFile(f *gitx.DiffFile) *types.PullRequestFile {
+	file := &types.PullRequestFile{
+		Filename:  f.Name,
+		Additions: f.NumAdditions(),
+		Deletions: f.NumDeletions(),
+		Changes:   f.NumAdditions() + f.NumDeletions(),
+	}
+	switch f.Type {
+	case git.DiffFileAdd:
+		file.Status = types.PullRequestFileAdded
+	case git.DiffFileDelete:
+		file.Status = types.PullRequestFileRemoved
+	case git.DiffFileRename:
+		file.Status = types.PullRequestFileRenamed
+		file.PreviousFilename = f.OldName()
+	default:
+		file.Status = types.PullRequestFileModified
+	}
+	return file
+}
+
+// toCommitStatus converts a database commit status to an API commit status.
+// It assumes the Creator field has been loaded.
+func toCommitStatus(s *database.CommitStatus) *types.CommitStatus {
+	return &types.CommitStatus{
+		ID:          s.ID,
+		State:       types.CommitStatusState(s.State),
+		TargetURL:   s.TargetURL,
+		Description: s.Description,
+		Context:     s.Context,
+		Creator:     toUser(s.Creator),
+		Created:     s.CreatedAt,
+	}
+}
+
+func toPushMirror(m *database.PushMirror) *types.PushMirror {
+	apiMirror := &types.PushMirror{
+		ID:        m.ID,
+		Address:   m.Address,
+		Username:  m.Username(),
+		Interval:  m.SyncInterval,
+		LastError: m.LastError,
+		Created:   m.Created,
+	}
+	if m.LastSyncUnix > 0 {
+		apiMirror.LastSync = &m.LastSync
+	}
+	return apiMirror
+}
+
 func toIssueComment(c *database.Comment) *types.IssueComment {
 	return &types.IssueComment{
 		ID:      c.ID,
@@ -23,6 +23,7 @@ func adminAddTeamRepository(c *context.APIContext) {
 		c.Error(err, "add repository")
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamRepoAdd, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "repository "+repo.Name)
 
 	c.NoContent()
 }
@@ -36,6 +37,7 @@ func adminRemoveTeamRepository(c *context.APIContext) {
 		c.Error(err, "remove repository")
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamRepoRemove, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "repository "+repo.Name)
 
 	c.NoContent()
 }
@@ -29,6 +29,7 @@ func adminCreateTeam(c *context.APIContext, form adminCreateTeamRequest) {
 		}
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamCreate, database.AuditTargetOfTeam(c.Org.Organization, team), "permission "+team.Authorize.String())
 
 	c.JSON(http.StatusCreated, toOrganizationTeam(team))
 }
@@ -42,6 +43,7 @@ func adminAddTeamMember(c *context.APIContext) {
 		c.Error(err, "add member")
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamMemberAdd, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "user "+u.Name)
 
 	c.NoContent()
 }
@@ -56,6 +58,7 @@ func adminRemoveTeamMember(c *context.APIContext) {
 		c.Error(err, "remove member")
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamMemberRemove, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "user "+u.Name)
 
 	c.NoContent()
 }
@@ -66,6 +66,7 @@ func adminCreateUser(c *context.APIContext, form adminCreateUserRequest) {
 		return
 	}
 	log.Trace("Account %q created by admin %q", u.Name, c.User.Name)
+	c.AuditLog(database.AuditActionAdminUserCreate, database.AuditTargetOfUser(u), "")
 
 	// Send email notification.
 	if form.SendNotify && conf.Email.Enabled {
@@ -89,6 +90,7 @@ type adminEditUserRequest struct {
 	Admin            *bool  `json:"admin"`
 	AllowGitHook     *bool  `json:"allow_git_hook"`
 	AllowImportLocal *bool  `json:"allow_import_local"`
+	ProhibitLogin    *bool  `json:"prohibit_login"`
 	MaxRepoCreation  *int   `json:"max_repo_creation"`
 }
 
@@ -114,7 +116,7 @@ func adminEditUser(c *context.APIContext, form adminEditUserRequest) {
 		IsAdmin:          form.Admin,
 		AllowGitHook:     form.AllowGitHook,
 		AllowImportLocal: form.AllowImportLocal,
-		ProhibitLogin:    nil, // TODO: Add this option to API
+		ProhibitLogin:    form.ProhibitLogin,
 	}
 
 	if form.Password != "" {
@@ -135,6 +137,16 @@ func adminEditUser(c *context.APIContext, form adminEditUserRequest) {
 		return
 	}
 	log.Trace("Account updated by admin %q: %s", c.User.Name, u.Name)
+	c.AuditLog(database.AuditActionAdminUserUpdate, database.AuditTargetOfUser(u), database.AuditDetailsOfUserUpdate(u, opts))
+
+	// Sign the user out everywhere when the password has been reset or the
+	// account has been suspended.
+	if opts.Password != nil || (form.Active != nil && !*form.Active) || (form.ProhibitLogin != nil && *form.ProhibitLogin) {
+		if _, err = database.Handle.LoginSessions().DeleteByUserID(c.Req.Context(), u.ID, 0); err != nil {
+			c.Error(err, "revoke login sessions")
+			return
+		}
+	}
 
 	u, err = database.Handle.Users().GetByID(c.Req.Context(), u.ID)
 	if err != nil {
@@ -160,6 +172,7 @@ func adminDeleteUser(c *context.APIContext) {
 		return
 	}
 	log.Trace("Account deleted by admin(%s): %s", c.User.Name, u.Name)
+	c.AuditLog(database.AuditActionAdminUserDelete, database.AuditTargetOfUser(u), "")
 
 	c.NoContent()
 }
@@ -169,5 +182,5 @@ func adminCreatePublicKey(c *context.APIContext, form createPublicKeyRequest) {
 	if c.Written() {
 		return
 	}
-	createUserPublicKey(c, form, u.ID)
+	createUserPublicKey(c, form, u)
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/cockroachdb/errors"
 	"github.com/go-macaron/binding"
 	"gopkg.in/macaron.v1"
 
@@ -43,7 +44,7 @@ func repoAssignment() macaron.Handler {
 			return
 		}
 
-		if c.IsTokenAuth && c.User.IsAdmin {
+		if c.IsTokenAuth && c.User.IsAdmin && c.AccessToken.HasScope(database.AccessTokenScopeSiteAdmin) {
 			c.Repo.AccessMode = database.AccessModeOwner
 		} else {
 			c.Repo.AccessMode = database.Handle.Permissions().AccessMode(c.Req.Context(), c.UserID(), repo.ID,
@@ -53,6 +54,10 @@ func repoAssignment() macaron.Handler {
 				},
 			)
 		}
+		// The access token may grant less than what the user has.
+		if c.AccessToken != nil {
+			c.Repo.AccessMode = min(c.Repo.AccessMode, c.AccessToken.MaxRepoAccessMode(repo.ID, repo.IsPrivate))
+		}
 
 		if !c.Repo.HasAccess() {
 			c.NotFound()
@@ -107,6 +112,26 @@ func reqToken() macaron.Handler {
 	}
 }
 
+// reqTokenScope makes sure the access token has the read scope for safe
+// methods, and the write scope for others, when the context user is authorized
+// via access token.
+func reqTokenScope(read, write database.AccessTokenScope) macaron.Handler {
+	return func(c *context.APIContext) {
+		if c.AccessToken == nil {
+			return
+		}
+
+		scope := write
+		if c.Req.Method == http.MethodGet || c.Req.Method == http.MethodHead {
+			scope = read
+		}
+		if !c.AccessToken.HasScope(scope) {
+			c.ErrorStatus(http.StatusForbidden, errors.Newf("access token does not have the %q scope", scope))
+			return
+		}
+	}
+}
+
 // reqBasicAuth makes sure the context user is authorized via HTTP Basic Auth.
 func reqBasicAuth() macaron.Handler {
 	return func(c *context.Context) {
@@ -120,7 +145,8 @@ func reqBasicAuth() macaron.Handler {
 // reqAdmin makes sure the context user is a site admin.
 func reqAdmin() macaron.Handler {
 	return func(c *context.Context) {
-		if !c.IsLogged || !c.User.IsAdmin {
+		if !c.IsLogged || !c.User.IsAdmin ||
+			(c.AccessToken != nil && !c.AccessToken.HasScope(database.AccessTokenScopeSiteAdmin)) {
 			c.Status(http.StatusForbidden)
 			return
 		}
@@ -168,6 +194,9 @@ func mustEnableIssues(c *context.APIContext) {
 // FIXME: custom form error response
 func RegisterRoutes(m *macaron.Macaron) {
 	bind := binding.Bind
+	reqRepoScope := reqTokenScope(database.AccessTokenScopeReadRepo, database.AccessTokenScopeWriteRepo)
+	reqIssueScope := reqTokenScope(database.AccessTokenScopeReadIssue, database.AccessTokenScopeWriteIssue)
+	reqOrgScope := reqTokenScope(database.AccessTokenScopeReadOrg, database.AccessTokenScopeAdminOrg)
 
 	m.Group("/v1", func() {
 		// Handle preflight OPTIONS request
@@ -203,7 +232,7 @@ func RegisterRoutes(m *macaron.Macaron) {
 					m.Get("/:target", checkFollowing)
 				})
 			})
-		}, reqToken())
+		}, reqToken(), reqTokenScope(database.AccessTokenScopeReadUser, database.AccessTokenScopeWriteUser))
 
 		m.Group("/user", func() {
 			m.Get("", getAuthenticatedUser)
@@ -229,17 +258,16 @@ func RegisterRoutes(m *macaron.Macaron) {
 					Get(getPublicKey).
 					Delete(deletePublicKey)
 			})
-
-			m.Get("/issues", listUserIssues)
-		}, reqToken())
+		}, reqToken(), reqTokenScope(database.AccessTokenScopeReadUser, database.AccessTokenScopeWriteUser))
+		m.Get("/user/issues", reqToken(), reqIssueScope, listUserIssues)
 
 		// Repositories
-		m.Get("/users/:username/repos", reqToken(), listUserRepositories)
-		m.Get("/orgs/:org/repos", reqToken(), listOrgRepositories)
-		m.Combo("/user/repos", reqToken()).
+		m.Get("/users/:username/repos", reqToken(), reqRepoScope, listUserRepositories)
+		m.Get("/orgs/:org/repos", reqToken(), reqRepoScope, listOrgRepositories)
+		m.Combo("/user/repos", reqToken(), reqRepoScope).
 			Get(listMyRepos).
 			Post(bind(createRepoRequest{}), createRepo)
-		m.Post("/org/:org/repos", reqToken(), bind(createRepoRequest{}), createOrgRepo)
+		m.Post("/org/:org/repos", reqToken(), reqRepoScope, bind(createRepoRequest{}), createOrgRepo)
 
 		m.Group("/repos", func() {
 			m.Get("/search", searchRepos)
@@ -249,7 +277,7 @@ func RegisterRoutes(m *macaron.Macaron) {
 		})
 
 		m.Group("/repos", func() {
-			m.Post("/migrate", bind(form.MigrateRepo{}), migrate)
+			m.Post("/migrate", reqRepoScope, bind(form.MigrateRepo{}), migrate)
 			m.Delete("/:username/:reponame", repoAssignment(), reqRepoOwner(), deleteRepo)
 
 			m.Group("/:username/:reponame", func() {
@@ -296,7 +324,12 @@ func RegisterRoutes(m *macaron.Macaron) {
 					m.Get("/:sha", getSingleCommit)
 					m.Get("", getAllCommits)
 					m.Get("/*", getReferenceSHA)
+					m.Get("/:ref/status", getCombinedCommitStatus)
+					m.Get("/:ref/statuses", listCommitStatusesByRef)
 				})
+				m.Combo("/statuses/:sha").
+					Get(listCommitStatusesBySHA).
+					Post(reqRepoWriter(), bind(createCommitStatusRequest{}), createCommitStatus)
 
 				m.Group("/keys", func() {
 					m.Combo("").
@@ -307,6 +340,16 @@ func RegisterRoutes(m *macaron.Macaron) {
 						Delete(deleteDeploykey)
 				}, reqRepoAdmin())
 
+				m.Group("/push_mirrors", func() {
+					m.Combo("").
+						Get(listPushMirrors).
+						Post(bind(createPushMirrorRequest{}), createPushMirror)
+					m.Combo("/:id").
+						Get(getPushMirror).
+						Delete(deletePushMirror)
+					m.Post("/:id/sync", syncPushMirror)
+				}, reqRepoAdmin())
+
 				m.Group("/issues", func() {
 					m.Combo("").
 						Get(listIssues).
@@ -338,29 +381,45 @@ func RegisterRoutes(m *macaron.Macaron) {
 							m.Delete("/:id", deleteIssueLabel)
 						}, reqRepoWriter())
 					})
-				}, mustEnableIssues)
+				}, mustEnableIssues, reqIssueScope)
+
+				m.Group("/pulls", func() {
+					m.Combo("").
+						Get(listPullRequests).
+						Post(bind(createPullRequestRequest{}), createPullRequest)
+					m.Group("/:index", func() {
+						m.Combo("").
+							Get(getPullRequest).
+							Patch(bind(editIssueRequest{}), editPullRequest)
+						m.Combo("/merge").
+							Get(isPullRequestMerged).
+							Put(reqRepoWriter(), bind(mergePullRequestRequest{}), mergePullRequest)
+						m.Get("/files", listPullRequestFiles)
+						m.Get("/commits", listPullRequestCommits)
+					})
+				}, mustAllowPulls, reqIssueScope)
 
 				m.Group("/labels", func() {
 					m.Get("", listLabels)
 					m.Get("/:id", getLabel)
-				})
+				}, reqIssueScope)
 				m.Group("/labels", func() {
 					m.Post("", bind(createLabelRequest{}), createLabel)
 					m.Combo("/:id").
 						Patch(bind(editLabelRequest{}), editLabel).
 						Delete(deleteLabel)
-				}, reqRepoWriter())
+				}, reqRepoWriter(), reqIssueScope)
 
 				m.Group("/milestones", func() {
 					m.Get("", listMilestones)
 					m.Get("/:id", getMilestone)
-				})
+				}, reqIssueScope)
 				m.Group("/milestones", func() {
 					m.Post("", bind(createMilestoneRequest{}), createMilestone)
 					m.Combo("/:id").
 						Patch(bind(editMilestoneRequest{}), editMilestone).
 						Delete(deleteMilestone)
-				}, reqRepoWriter())
+				}, reqRepoWriter(), reqIssueScope)
 
 				m.Patch("/issue-tracker", reqRepoAdmin(), bind(editIssueTrackerRequest{}), issueTracker)
 				m.Patch("/wiki", reqRepoAdmin(), bind(editWikiRequest{}), wiki)
@@ -369,20 +428,20 @@ func RegisterRoutes(m *macaron.Macaron) {
 			}, repoAssignment())
 		}, reqToken())
 
-		m.Get("/issues", reqToken(), listUserIssues)
+		m.Get("/issues", reqToken(), reqIssueScope, listUserIssues)
 
 		// Organizations
-		m.Combo("/user/orgs", reqToken()).
+		m.Combo("/user/orgs", reqToken(), reqOrgScope).
 			Get(listMyOrgs).
 			Post(bind(createOrgRequest{}), createMyOrg)
 
-		m.Get("/users/:username/orgs", listUserOrgs)
+		m.Get("/users/:username/orgs", reqOrgScope, listUserOrgs)
 		m.Group("/orgs/:orgname", func() {
 			m.Combo("").
 				Get(getOrg).
 				Patch(bind(editOrgRequest{}), editOrg)
 			m.Get("/teams", listTeams)
-		}, reqToken(), orgAssignment(true))
+		}, reqToken(), reqOrgScope, orgAssignment(true))
 
 		m.Group("/admin", func() {
 			m.Group("/users", func() {
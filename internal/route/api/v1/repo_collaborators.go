@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 
 	"gogs.io/gogs/internal/context"
@@ -41,12 +42,15 @@ func addCollaborator(c *context.APIContext, form addCollaboratorRequest) {
 		c.Error(err, "add collaborator")
 		return
 	}
+	c.AuditLog(database.AuditActionRepoCollaboratorAdd, database.AuditTargetOfRepository(c.Repo.Repository), "user "+collaborator.Name)
 
 	if form.Permission != nil {
-		if err := c.Repo.Repository.ChangeCollaborationAccessMode(c.Repo.AccessMode, collaborator.ID, database.ParseAccessMode(*form.Permission)); err != nil {
+		mode := database.ParseAccessMode(*form.Permission)
+		if err := c.Repo.Repository.ChangeCollaborationAccessMode(c.Repo.AccessMode, collaborator.ID, mode); err != nil {
 			c.Error(err, "change collaboration access mode")
 			return
 		}
+		c.AuditLog(database.AuditActionRepoCollaboratorModeChange, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("user %s, mode %s", collaborator.Name, mode))
 	}
 
 	c.NoContent()
@@ -85,6 +89,7 @@ func deleteCollaborator(c *context.APIContext) {
 		c.Error(err, "delete collaboration")
 		return
 	}
+	c.AuditLog(database.AuditActionRepoCollaboratorRemove, database.AuditTargetOfRepository(c.Repo.Repository), "user "+collaborator.Name)
 
 	c.NoContent()
 }
@@ -62,14 +62,16 @@ func createHook(c *context.APIContext, form createHookRequest) {
 		HookEvent: &database.HookEvent{
 			ChooseEvents: true,
 			HookEvents: database.HookEvents{
-				Create:       slices.Contains(form.Events, string(database.HookEventTypeCreate)),
-				Delete:       slices.Contains(form.Events, string(database.HookEventTypeDelete)),
-				Fork:         slices.Contains(form.Events, string(database.HookEventTypeFork)),
-				Push:         slices.Contains(form.Events, string(database.HookEventTypePush)),
-				Issues:       slices.Contains(form.Events, string(database.HookEventTypeIssues)),
-				IssueComment: slices.Contains(form.Events, string(database.HookEventTypeIssueComment)),
-				PullRequest:  slices.Contains(form.Events, string(database.HookEventTypePullRequest)),
-				Release:      slices.Contains(form.Events, string(database.HookEventTypeRelease)),
+				Create:            slices.Contains(form.Events, string(database.HookEventTypeCreate)),
+				Delete:            slices.Contains(form.Events, string(database.HookEventTypeDelete)),
+				Fork:              slices.Contains(form.Events, string(database.HookEventTypeFork)),
+				Push:              slices.Contains(form.Events, string(database.HookEventTypePush)),
+				Issues:            slices.Contains(form.Events, string(database.HookEventTypeIssues)),
+				IssueComment:      slices.Contains(form.Events, string(database.HookEventTypeIssueComment)),
+				PullRequest:       slices.Contains(form.Events, string(database.HookEventTypePullRequest)),
+				PullRequestReview: slices.Contains(form.Events, string(database.HookEventTypePullRequestReview)),
+				Release:           slices.Contains(form.Events, string(database.HookEventTypeRelease)),
+				Status:            slices.Contains(form.Events, string(database.HookEventTypeStatus)),
 			},
 		},
 		IsActive:     form.Active,
@@ -162,7 +164,9 @@ func editHook(c *context.APIContext, form editHookRequest) {
 	w.Issues = slices.Contains(form.Events, string(database.HookEventTypeIssues))
 	w.IssueComment = slices.Contains(form.Events, string(database.HookEventTypeIssueComment))
 	w.PullRequest = slices.Contains(form.Events, string(database.HookEventTypePullRequest))
+	w.PullRequestReview = slices.Contains(form.Events, string(database.HookEventTypePullRequestReview))
 	w.Release = slices.Contains(form.Events, string(database.HookEventTypeRelease))
+	w.Status = slices.Contains(form.Events, string(database.HookEventTypeStatus))
 	if err = w.UpdateEvent(); err != nil {
 		c.Errorf(err, "update event")
 		return
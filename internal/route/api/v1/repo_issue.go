@@ -146,6 +146,23 @@ func editIssue(c *context.APIContext, form editIssueRequest) {
 		return
 	}
 
+	if !updateIssue(c, issue, form) {
+		return
+	}
+
+	// Refetch from database to assign some automatic values
+	issue, err = database.GetIssueByID(issue.ID)
+	if err != nil {
+		c.Error(err, "get issue by ID")
+		return
+	}
+	c.JSON(http.StatusCreated, toIssue(issue))
+}
+
+// updateIssue applies changes of the edit request to the issue. It returns
+// false when a response has already been written.
+func updateIssue(c *context.APIContext, issue *database.Issue, form editIssueRequest) bool {
+	var err error
 	if len(form.Title) > 0 {
 		issue.Title = form.Title
 	}
@@ -165,14 +182,14 @@ func editIssue(c *context.APIContext, form editIssueRequest) {
 				} else {
 					c.Error(err, "get user by name")
 				}
-				return
+				return false
 			}
 			issue.AssigneeID = assignee.ID
 		}
 
 		if err = database.UpdateIssueUserByAssignee(issue); err != nil {
 			c.Error(err, "update issue user by assignee")
-			return
+			return false
 		}
 	}
 	if c.Repo.IsWriter() && form.Milestone != nil &&
@@ -181,26 +198,19 @@ func editIssue(c *context.APIContext, form editIssueRequest) {
 		issue.MilestoneID = *form.Milestone
 		if err = database.ChangeMilestoneAssign(c.User, issue, oldMilestoneID); err != nil {
 			c.Error(err, "change milestone assign")
-			return
+			return false
 		}
 	}
 
 	if err = database.UpdateIssue(issue); err != nil {
 		c.Error(err, "update issue")
-		return
+		return false
 	}
 	if form.State != nil {
 		if err = issue.ChangeStatus(c.User, c.Repo.Repository, types.IssueStateClosed == types.IssueStateType(*form.State)); err != nil {
 			c.Error(err, "change status")
-			return
+			return false
 		}
 	}
-
-	// Refetch from database to assign some automatic values
-	issue, err = database.GetIssueByID(issue.ID)
-	if err != nil {
-		c.Error(err, "get issue by ID")
-		return
-	}
-	c.JSON(http.StatusCreated, toIssue(issue))
+	return true
 }
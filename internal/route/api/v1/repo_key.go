@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/cockroachdb/errors"
@@ -95,6 +96,7 @@ func createDeployKey(c *context.APIContext, form createDeployKeyRequest) {
 		handleAddKeyError(c, err)
 		return
 	}
+	c.AuditLog(database.AuditActionRepoDeployKeyAdd, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("key %q (%s)", key.Name, key.Fingerprint))
 
 	key.Content = content
 	apiLink := composeDeployKeysAPILink(c.Repo.Owner.Name + "/" + c.Repo.Repository.Name)
@@ -122,6 +124,7 @@ func deleteDeploykey(c *context.APIContext) {
 		}
 		return
 	}
+	c.AuditLog(database.AuditActionRepoDeployKeyDelete, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("key %q (%s)", key.Name, key.Fingerprint))
 
 	c.NoContent()
 }
@@ -0,0 +1,507 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/gitx"
+	"gogs.io/gogs/internal/route/api/v1/types"
+)
+
+func mustAllowPulls(c *context.APIContext) {
+	if !c.Repo.Repository.AllowsPulls() {
+		c.NotFound()
+		return
+	}
+}
+
+func listPullRequests(c *context.APIContext) {
+	opts := &database.IssuesOptions{
+		RepoID:   c.Repo.Repository.ID,
+		Page:     c.QueryInt("page"),
+		IsClosed: types.IssueStateType(c.Query("state")) == types.IssueStateClosed,
+		IsPull:   true,
+	}
+
+	issues, err := database.Issues(opts)
+	if err != nil {
+		c.Error(err, "list issues")
+		return
+	}
+
+	count, err := database.IssuesCount(opts)
+	if err != nil {
+		c.Error(err, "count issues")
+		return
+	}
+
+	apiPullRequests := make([]*types.PullRequest, len(issues))
+	for i := range issues {
+		if err = issues[i].LoadAttributes(); err != nil {
+			c.Error(err, "load attributes")
+			return
+		}
+		issues[i].PullRequest.Issue = issues[i]
+		apiPullRequests[i] = toPullRequest(issues[i].PullRequest)
+	}
+
+	c.SetLinkHeader(int(count), conf.UI.IssuePagingNum)
+	c.JSONSuccess(&apiPullRequests)
+}
+
+// getPullRequestByIndex returns the pull request of the repository with the
+// ":index" URL parameter, its issue is loaded and assigned.
+func getPullRequestByIndex(c *context.APIContext) *database.PullRequest {
+	issue, err := database.GetIssueByIndex(c.Repo.Repository.ID, c.ParamsInt64(":index"))
+	if err != nil {
+		c.NotFoundOrError(err, "get issue by index")
+		return nil
+	} else if !issue.IsPull || issue.PullRequest == nil {
+		c.NotFound()
+		return nil
+	}
+
+	issue.PullRequest.Issue = issue
+	return issue.PullRequest
+}
+
+func getPullRequest(c *context.APIContext) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toPullRequest(pr))
+}
+
+type createPullRequestRequest struct {
+	Title string `json:"title" binding:"Required"`
+	Body  string `json:"body"`
+	// The branch that contains the changes, in the form of "<branch>", or
+	// "<username>:<branch>" for a branch of a fork.
+	Head      string  `json:"head" binding:"Required"`
+	Base      string  `json:"base" binding:"Required"`
+	Assignee  string  `json:"assignee"`
+	Milestone int64   `json:"milestone"`
+	Labels    []int64 `json:"labels"`
+}
+
+func createPullRequest(c *context.APIContext, form createPullRequestRequest) {
+	baseRepo := c.Repo.Repository
+
+	baseGitRepo, err := git.Open(baseRepo.RepoPath())
+	if err != nil {
+		c.Error(err, "open repository")
+		return
+	}
+	if !baseGitRepo.HasBranch(form.Base) {
+		c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("base branch does not exist: %s", form.Base))
+		return
+	}
+
+	headUser := c.Repo.Owner
+	headBranch := form.Head
+	if i := strings.Index(form.Head, ":"); i > -1 {
+		headUser, err = database.Handle.Users().GetByUsername(c.Req.Context(), form.Head[:i])
+		if err != nil {
+			if database.IsErrUserNotExist(err) {
+				c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("head user does not exist: %s", form.Head[:i]))
+			} else {
+				c.Error(err, "get user by name")
+			}
+			return
+		}
+		headBranch = form.Head[i+1:]
+	}
+
+	headRepo := baseRepo
+	if headUser.ID != baseRepo.OwnerID {
+		var has bool
+		headRepo, has, err = database.HasForkedRepo(headUser.ID, baseRepo.ID)
+		if err != nil {
+			c.Error(err, "get forked repository")
+			return
+		} else if !has {
+			c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("user %q does not have a fork of the repository", headUser.Name))
+			return
+		}
+	}
+
+	if !c.User.IsAdmin && !database.Handle.Permissions().Authorize(
+		c.Req.Context(),
+		c.User.ID,
+		headRepo.ID,
+		database.AccessModeWrite,
+		database.AccessModeOptions{
+			OwnerID: headRepo.OwnerID,
+			Private: headRepo.IsPrivate,
+		},
+	) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	headGitRepo, err := git.Open(database.RepoPath(headUser.Name, headRepo.Name))
+	if err != nil {
+		c.Error(err, "open repository")
+		return
+	}
+	if !headGitRepo.HasBranch(headBranch) {
+		c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("head branch does not exist: %s", headBranch))
+		return
+	}
+
+	pr, err := database.GetUnmergedPullRequest(headRepo.ID, baseRepo.ID, headBranch, form.Base)
+	if err == nil {
+		c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("pull request already exists: #%d", pr.Index))
+		return
+	} else if !database.IsErrPullRequestNotExist(err) {
+		c.Error(err, "get unmerged pull request")
+		return
+	}
+
+	meta, err := gitx.Module.PullRequestMeta(headGitRepo.Path(), baseRepo.RepoPath(), headBranch, form.Base)
+	if err != nil {
+		if gitx.IsErrNoMergeBase(err) {
+			c.ErrorStatus(http.StatusUnprocessableEntity, errors.New("head and base branches have no common history"))
+		} else {
+			c.Error(err, "get pull request meta")
+		}
+		return
+	} else if len(meta.Commits) == 0 {
+		c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("no commits between %s and %s", form.Base, form.Head))
+		return
+	}
+
+	patch, err := headGitRepo.DiffBinary(meta.MergeBase, headBranch)
+	if err != nil {
+		c.Error(err, "get patch")
+		return
+	}
+
+	pullIssue := &database.Issue{
+		RepoID:   baseRepo.ID,
+		Index:    baseRepo.NextIssueIndex(),
+		Title:    form.Title,
+		PosterID: c.User.ID,
+		Poster:   c.User,
+		IsPull:   true,
+		Content:  form.Body,
+	}
+	if c.Repo.IsWriter() {
+		if len(form.Assignee) > 0 {
+			assignee, err := database.Handle.Users().GetByUsername(c.Req.Context(), form.Assignee)
+			if err != nil {
+				if database.IsErrUserNotExist(err) {
+					c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("assignee does not exist: [name: %s]", form.Assignee))
+				} else {
+					c.Error(err, "get user by name")
+				}
+				return
+			}
+			pullIssue.AssigneeID = assignee.ID
+		}
+		pullIssue.MilestoneID = form.Milestone
+	} else {
+		form.Labels = nil
+	}
+
+	pr = &database.PullRequest{
+		HeadRepoID:   headRepo.ID,
+		BaseRepoID:   baseRepo.ID,
+		HeadUserName: headUser.Name,
+		HeadBranch:   headBranch,
+		BaseBranch:   form.Base,
+		HeadRepo:     headRepo,
+		BaseRepo:     baseRepo,
+		MergeBase:    meta.MergeBase,
+		Type:         database.PullRequestTypeGogs,
+	}
+	if err = database.NewPullRequest(baseRepo, pullIssue, form.Labels, nil, pr, patch); err != nil {
+		c.Error(err, "new pull request")
+		return
+	} else if err = pr.PushToBaseRepo(); err != nil {
+		c.Error(err, "push to base repository")
+		return
+	}
+
+	// Refetch from database to assign some automatic values
+	pullIssue, err = database.GetIssueByID(pullIssue.ID)
+	if err != nil {
+		c.Error(err, "get issue by ID")
+		return
+	}
+	pullIssue.PullRequest.Issue = pullIssue
+	c.JSON(http.StatusCreated, toPullRequest(pullIssue.PullRequest))
+}
+
+func editPullRequest(c *context.APIContext, form editIssueRequest) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+	issue := pr.Issue
+
+	if !issue.IsPoster(c.User.ID) && !c.Repo.IsWriter() {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	var reopen bool
+	if form.State != nil {
+		isClosed := types.IssueStateType(*form.State) == types.IssueStateClosed
+		if pr.HasMerged && !isClosed {
+			c.ErrorStatus(http.StatusUnprocessableEntity, errors.New("cannot reopen a merged pull request"))
+			return
+		}
+
+		// Duplication check should apply to reopen pull request.
+		reopen = issue.IsClosed && !isClosed
+		if reopen {
+			unmerged, err := database.GetUnmergedPullRequest(pr.HeadRepoID, pr.BaseRepoID, pr.HeadBranch, pr.BaseBranch)
+			if err == nil {
+				c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("an open pull request for the same branches already exists: #%d", unmerged.Index))
+				return
+			} else if !database.IsErrPullRequestNotExist(err) {
+				c.Error(err, "get unmerged pull request")
+				return
+			}
+		}
+	}
+
+	if !updateIssue(c, issue, form) {
+		return
+	}
+
+	// Conflict check of the reopened pull request only runs after the state has
+	// been changed successfully.
+	if reopen {
+		if err := pr.UpdatePatch(); err != nil {
+			c.Error(err, "update patch")
+			return
+		}
+		pr.AddToTaskQueue()
+	}
+
+	// Refetch from database to assign some automatic values
+	issue, err := database.GetIssueByID(issue.ID)
+	if err != nil {
+		c.Error(err, "get issue by ID")
+		return
+	}
+	issue.PullRequest.Issue = issue
+	c.JSONSuccess(toPullRequest(issue.PullRequest))
+}
+
+// isPullRequestMerged responds with 204 if the pull request has been merged,
+// and 404 otherwise.
+func isPullRequestMerged(c *context.APIContext) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+
+	if !pr.HasMerged {
+		c.NotFound()
+		return
+	}
+	c.NoContent()
+}
+
+type mergePullRequestRequest struct {
+	MergeStyle         string `json:"merge_style"`
+	CommitDescription  string `json:"commit_description"`
+	CommitMessage      string `json:"commit_message"`
+	HeadCommitID       string `json:"head_commit_id"`
+	OverrideProtection bool   `json:"override_protection"`
+}
+
+func mergePullRequest(c *context.APIContext, form mergePullRequestRequest) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+
+	if pr.HasMerged {
+		c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("pull request has already been merged"))
+		return
+	} else if pr.Issue.IsClosed {
+		c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("pull request is closed"))
+		return
+	} else if pr.HeadRepo == nil {
+		c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("head repository has been deleted"))
+		return
+	} else if !pr.CanAutoMerge() {
+		c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("pull request is not mergeable"))
+		return
+	}
+
+	mergeStyle := database.MergeStyle(form.MergeStyle)
+	switch mergeStyle {
+	case "":
+		styles := c.Repo.Repository.AllowedMergeStyles()
+		if len(styles) == 0 {
+			c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("no merge style is allowed"))
+			return
+		}
+		mergeStyle = styles[0]
+	case database.MergeStyleRegular, database.MergeStyleRebase, database.MergeStyleSquash, database.MergeStyleFastForwardOnly:
+		if !c.Repo.Repository.IsMergeStyleAllowed(mergeStyle) {
+			c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("merge style is not allowed: %s", mergeStyle))
+			return
+		}
+	default:
+		c.ErrorStatus(http.StatusUnprocessableEntity, errors.Newf("unknown merge style: %s", mergeStyle))
+		return
+	}
+
+	commitDescription := form.CommitDescription
+	if mergeStyle == database.MergeStyleSquash {
+		commitDescription = form.CommitMessage
+	}
+
+	baseGitRepo, err := git.Open(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.Error(err, "open repository")
+		return
+	}
+
+	pr.Issue.Repo = c.Repo.Repository
+	if err = pr.Merge(c.User, baseGitRepo, mergeStyle, commitDescription, form.HeadCommitID, form.OverrideProtection); err != nil {
+		if database.IsErrPullRequestHasMerged(err) {
+			c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("pull request has already been merged"))
+			return
+		} else if database.IsErrProtectBranchNotSatisfied(err) {
+			c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("branch protection conditions are not satisfied"))
+			return
+		} else if database.IsErrNotFastForward(err) {
+			c.ErrorStatus(http.StatusMethodNotAllowed, errors.New("head branch is not a descendant of base branch"))
+			return
+		} else if database.IsErrHeadCommitChanged(err) {
+			c.ErrorStatus(http.StatusConflict, errors.New("head branch does not point to the expected commit"))
+			return
+		}
+		c.Error(err, "merge")
+		return
+	}
+
+	issue, err := database.GetIssueByID(pr.IssueID)
+	if err != nil {
+		c.Error(err, "get issue by ID")
+		return
+	}
+	issue.PullRequest.Issue = issue
+	c.JSONSuccess(toPullRequest(issue.PullRequest))
+}
+
+// pullRequestRange returns the Git repository and the range of commits that
+// contains changes of the pull request. It returns nil Git repository when a
+// response has already been written.
+func pullRequestRange(c *context.APIContext, pr *database.PullRequest) (gitRepo *git.Repository, startCommitID, endCommitID string) {
+	if pr.HasMerged {
+		baseGitRepo, err := git.Open(c.Repo.Repository.RepoPath())
+		if err != nil {
+			c.Error(err, "open repository")
+			return nil, "", ""
+		}
+
+		// The stored merge base is not necessarily an ancestor of the merged head
+		// commit, e.g. when the head branch has been force-pushed, so the range is
+		// started at their common ancestor to only include commits of the head
+		// branch.
+		mergeBase, err := baseGitRepo.MergeBase(pr.MergeBase, pr.MergedCommitID)
+		if err != nil {
+			c.Error(err, "get merge base")
+			return nil, "", ""
+		}
+		return baseGitRepo, mergeBase, pr.MergedCommitID
+	}
+
+	if pr.HeadRepo == nil {
+		c.NotFound()
+		return nil, "", ""
+	}
+
+	headGitRepo, err := git.Open(pr.HeadRepo.RepoPath())
+	if err != nil {
+		c.Error(err, "open repository")
+		return nil, "", ""
+	} else if !headGitRepo.HasBranch(pr.HeadBranch) {
+		c.NotFound()
+		return nil, "", ""
+	}
+
+	meta, err := gitx.Module.PullRequestMeta(headGitRepo.Path(), c.Repo.Repository.RepoPath(), pr.HeadBranch, pr.BaseBranch)
+	if err != nil {
+		c.NotFoundOrError(gitx.NewError(err), "get pull request meta")
+		return nil, "", ""
+	}
+
+	headCommitID, err := headGitRepo.BranchCommitID(pr.HeadBranch)
+	if err != nil {
+		c.Error(err, "get head branch commit ID")
+		return nil, "", ""
+	}
+	return headGitRepo, meta.MergeBase, headCommitID
+}
+
+func listPullRequestFiles(c *context.APIContext) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+
+	gitRepo, startCommitID, endCommitID := pullRequestRange(c, pr)
+	if gitRepo == nil {
+		return
+	}
+
+	diff, err := gitx.RepoDiff(gitRepo,
+		endCommitID, conf.Git.MaxDiffFiles, conf.Git.MaxDiffLines, conf.Git.MaxDiffLineChars,
+		git.DiffOptions{Base: startCommitID, Timeout: time.Duration(conf.Git.Timeout.Diff) * time.Second},
+	)
+	if err != nil {
+		c.Error(err, "get diff")
+		return
+	}
+
+	apiFiles := make([]*types.PullRequestFile, len(diff.Files))
+	for i := range diff.Files {
+		apiFiles[i] = toPullRequestFile(diff.Files[i])
+	}
+	c.JSONSuccess(&apiFiles)
+}
+
+func listPullRequestCommits(c *context.APIContext) {
+	pr := getPullRequestByIndex(c)
+	if c.Written() {
+		return
+	}
+
+	gitRepo, startCommitID, endCommitID := pullRequestRange(c, pr)
+	if gitRepo == nil {
+		return
+	}
+
+	commits, err := gitRepo.RevList([]string{startCommitID + ".." + endCommitID})
+	if err != nil {
+		c.Error(err, "list commits")
+		return
+	}
+
+	apiCommits := make([]*types.Commit, len(commits))
+	for i := range commits {
+		apiCommits[i], err = gitCommitToAPICommit(commits[i], c)
+		if err != nil {
+			c.Error(err, "convert git commit to api commit")
+			return
+		}
+	}
+	c.JSONSuccess(&apiCommits)
+}
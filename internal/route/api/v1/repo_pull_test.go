@@ -0,0 +1,250 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaron.v1"
+	"gorm.io/gorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/database/databasetest"
+	"gogs.io/gogs/internal/route/api/v1/types"
+)
+
+// insertPullRequest inserts the pull request #1 of the repository "alice/repo"
+// from "feature" to "main", and returns the repository.
+func insertPullRequest(t *testing.T, db *gorm.DB, pr *database.PullRequest, isClosed bool) *database.Repository {
+	owner := &database.User{
+		LowerName: "alice",
+		Name:      "alice",
+		Email:     "alice@example.com",
+	}
+	err := db.Create(owner).Error
+	require.NoError(t, err)
+
+	repo := &database.Repository{
+		OwnerID:   owner.ID,
+		Owner:     owner,
+		LowerName: "repo",
+		Name:      "repo",
+	}
+	err = db.Omit("Owner").Create(repo).Error
+	require.NoError(t, err)
+
+	// The title of an issue is stored in the "name" column, which is unknown to
+	// the GORM model.
+	err = db.Table("issue").Create(map[string]any{
+		"repo_id":   repo.ID,
+		"index":     1,
+		"poster_id": owner.ID,
+		"name":      "Add feature",
+		"is_pull":   true,
+		"is_closed": isClosed,
+	}).Error
+	require.NoError(t, err)
+	err = db.Table("issue").Where("repo_id = ? AND `index` = 1", repo.ID).Pluck("id", &pr.IssueID).Error
+	require.NoError(t, err)
+
+	pr.Index = 1
+	pr.HeadRepoID = repo.ID
+	pr.BaseRepoID = repo.ID
+	pr.HeadUserName = owner.Name
+	pr.HeadBranch = "feature"
+	pr.BaseBranch = "main"
+	err = db.Select("IssueID", "Index", "HeadRepoID", "BaseRepoID", "HeadUserName", "HeadBranch", "BaseBranch", "HasMerged", "MergeBase", "MergedCommitID").Create(pr).Error
+	require.NoError(t, err)
+	return repo
+}
+
+func TestEditPullRequest(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	conf.SetMockRepository(t, conf.RepositoryOpts{Root: t.TempDir()})
+
+	tests := []struct {
+		name          string
+		pr            *database.PullRequest
+		isClosed      bool
+		body          string
+		expStatusCode int
+		expIsClosed   bool
+	}{
+		{
+			name:          "state is unchanged when the edit fails",
+			pr:            &database.PullRequest{},
+			isClosed:      true,
+			body:          `{"state": "open", "assignee": "nobody"}`,
+			expStatusCode: http.StatusUnprocessableEntity,
+			expIsClosed:   true,
+		},
+		{
+			name:          "cannot reopen a merged pull request",
+			pr:            &database.PullRequest{HasMerged: true},
+			isClosed:      true,
+			body:          `{"state": "open"}`,
+			expStatusCode: http.StatusUnprocessableEntity,
+			expIsClosed:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := databasetest.SetUp(t)
+			repo := insertPullRequest(t, db, test.pr, test.isClosed)
+
+			m := macaron.New()
+			m.Use(macaron.Renderer())
+			m.Patch("/pulls/:index", func(mc *macaron.Context) {
+				var form editIssueRequest
+				err := json.NewDecoder(mc.Req.Body().ReadCloser()).Decode(&form)
+				require.NoError(t, err)
+
+				editPullRequest(
+					&context.APIContext{
+						Context: &context.Context{
+							Context: mc,
+							User:    repo.Owner,
+							Repo: &context.Repository{
+								AccessMode: database.AccessModeWrite,
+								Owner:      repo.Owner,
+								Repository: repo,
+							},
+						},
+					},
+					form,
+				)
+			})
+
+			r, err := http.NewRequest(http.MethodPatch, "/pulls/1", bytes.NewBufferString(test.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			resp := rr.Result()
+			assert.Equal(t, test.expStatusCode, resp.StatusCode)
+
+			issue, err := database.GetIssueByIndex(repo.ID, 1)
+			require.NoError(t, err)
+			assert.Equal(t, test.expIsClosed, issue.IsClosed)
+		})
+	}
+}
+
+// runGit runs the Git command in the directory and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=alice", "-c", "user.email=alice@example.com", "-c", "init.defaultBranch=main"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func TestListPullRequestCommits(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	conf.SetMockServer(t, conf.ServerOpts{
+		ExternalURL: "https://gogs.example.com/",
+	})
+	root := t.TempDir()
+	conf.SetMockRepository(t, conf.RepositoryOpts{Root: root})
+
+	// The base branch has moved on after the head branch was created.
+	work := t.TempDir()
+	runGit(t, work, "init")
+	runGit(t, work, "commit", "--allow-empty", "-m", "initial")
+	initial := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "checkout", "-b", "feature")
+	runGit(t, work, "commit", "--allow-empty", "-m", "feature 1")
+	feature1 := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "commit", "--allow-empty", "-m", "feature 2")
+	feature2 := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "checkout", "main")
+	runGit(t, work, "commit", "--allow-empty", "-m", "unrelated")
+	unrelated := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "clone", "--bare", work, filepath.Join(root, "alice", "repo.git"))
+
+	tests := []struct {
+		name       string
+		pr         *database.PullRequest
+		expCommits []string
+	}{
+		{
+			name: "merged",
+			pr: &database.PullRequest{
+				HasMerged:      true,
+				MergeBase:      initial,
+				MergedCommitID: feature2,
+			},
+			expCommits: []string{feature2, feature1},
+		},
+		{
+			// The stored merge base is a commit of the base branch that is not an
+			// ancestor of the merged head commit.
+			name: "merged with a stale merge base",
+			pr: &database.PullRequest{
+				HasMerged:      true,
+				MergeBase:      unrelated,
+				MergedCommitID: feature2,
+			},
+			expCommits: []string{feature2, feature1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := databasetest.SetUp(t)
+			repo := insertPullRequest(t, db, test.pr, false)
+
+			m := macaron.New()
+			m.Use(macaron.Renderer())
+			m.Get("/pulls/:index/commits", func(mc *macaron.Context) {
+				listPullRequestCommits(&context.APIContext{
+					Context: &context.Context{
+						Context: mc,
+						Link:    mc.Req.URL.Path,
+						User:    repo.Owner,
+						Repo: &context.Repository{
+							AccessMode: database.AccessModeWrite,
+							Owner:      repo.Owner,
+							Repository: repo,
+						},
+					},
+					BaseURL: conf.Server.ExternalURL + "api/v1",
+				})
+			})
+
+			r, err := http.NewRequest(http.MethodGet, "/pulls/1/commits", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			resp := rr.Result()
+			require.Equal(t, http.StatusOK, resp.StatusCode, rr.Body.String())
+
+			var commits []*types.Commit
+			err = json.Unmarshal(rr.Body.Bytes(), &commits)
+			require.NoError(t, err)
+
+			var got []string
+			for _, commit := range commits {
+				got = append(got, commit.SHA)
+			}
+			assert.Equal(t, test.expCommits, got)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/route/api/v1/types"
+)
+
+func listPushMirrors(c *context.APIContext) {
+	mirrors, err := database.Handle.PushMirrors().List(c.Req.Context(), c.Repo.Repository.ID)
+	if err != nil {
+		c.Error(err, "list push mirrors")
+		return
+	}
+
+	apiMirrors := make([]*types.PushMirror, len(mirrors))
+	for i := range mirrors {
+		apiMirrors[i] = toPushMirror(mirrors[i])
+	}
+	c.JSONSuccess(&apiMirrors)
+}
+
+func getPushMirror(c *context.APIContext) {
+	m, err := database.Handle.PushMirrors().GetByID(c.Req.Context(), c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get push mirror by ID")
+		return
+	}
+	c.JSONSuccess(toPushMirror(m))
+}
+
+type createPushMirrorRequest struct {
+	Address  string `json:"address" binding:"Required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Interval int    `json:"interval"`
+}
+
+func createPushMirror(c *context.APIContext, form createPushMirrorRequest) {
+	m, err := database.Handle.PushMirrors().Create(c.Req.Context(), c.Repo.Repository.ID,
+		database.CreatePushMirrorOptions{
+			Address:      form.Address,
+			Username:     form.Username,
+			Password:     form.Password,
+			SyncInterval: form.Interval,
+		},
+	)
+	if err != nil {
+		if database.IsErrInvalidCloneAddr(err) {
+			if err.(database.ErrInvalidCloneAddr).IsBlockedLocalAddress {
+				c.ErrorStatus(http.StatusUnprocessableEntity, errors.New("Address resolves to a blocked local address"))
+			} else {
+				c.ErrorStatus(http.StatusUnprocessableEntity, errors.New("Address must be a valid HTTP or HTTPS URL"))
+			}
+		} else {
+			c.Error(err, "create push mirror")
+		}
+		return
+	}
+
+	go database.PushMirrorQueue.Add(m.ID)
+	c.JSON(http.StatusCreated, toPushMirror(m))
+}
+
+func deletePushMirror(c *context.APIContext) {
+	m, err := database.Handle.PushMirrors().GetByID(c.Req.Context(), c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get push mirror by ID")
+		return
+	}
+
+	if err = database.Handle.PushMirrors().DeleteByID(c.Req.Context(), c.Repo.Repository.ID, m.ID); err != nil {
+		c.Error(err, "delete push mirror")
+		return
+	}
+	c.NoContent()
+}
+
+func syncPushMirror(c *context.APIContext) {
+	m, err := database.Handle.PushMirrors().GetByID(c.Req.Context(), c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get push mirror by ID")
+		return
+	}
+
+	go database.PushMirrorQueue.Add(m.ID)
+	c.Status(http.StatusAccepted)
+}
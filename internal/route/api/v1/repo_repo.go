@@ -59,9 +59,12 @@ func searchRepos(c *context.APIContext) {
 		return
 	}
 
-	results := make([]*types.Repository, len(repos))
-	for i := range repos {
-		results[i] = toRepository(repos[i], nil)
+	results := make([]*types.Repository, 0, len(repos))
+	for _, repo := range repos {
+		if tokenAccessMode(c, repo, database.AccessModeOwner) == database.AccessModeNone {
+			continue
+		}
+		results = append(results, toRepository(repo, nil))
 	}
 
 	c.SetLinkHeader(int(count), opts.PageSize)
@@ -71,6 +74,15 @@ func searchRepos(c *context.APIContext) {
 	})
 }
 
+// tokenAccessMode returns the access mode to the repository capped by what the
+// access token of the context user allows, if the user is authorized via one.
+func tokenAccessMode(c *context.APIContext, repo *database.Repository, mode database.AccessMode) database.AccessMode {
+	if c.AccessToken == nil {
+		return mode
+	}
+	return min(mode, c.AccessToken.MaxRepoAccessMode(repo.ID, repo.IsPrivate))
+}
+
 func listReposOfUser(c *context.APIContext, username string) {
 	user, err := database.Handle.Users().GetByUsername(c.Req.Context(), username)
 	if err != nil {
@@ -103,9 +115,12 @@ func listReposOfUser(c *context.APIContext, username string) {
 
 	// Early return for querying other user's repositories
 	if c.User.ID != user.ID {
-		repos := make([]*types.Repository, len(ownRepos))
-		for i := range ownRepos {
-			repos[i] = toRepository(ownRepos[i], &types.RepositoryPermission{Admin: true, Push: true, Pull: true})
+		repos := make([]*types.Repository, 0, len(ownRepos))
+		for _, r := range ownRepos {
+			if tokenAccessMode(c, r, database.AccessModeOwner) == database.AccessModeNone {
+				continue
+			}
+			repos = append(repos, toRepository(r, &types.RepositoryPermission{Admin: true, Push: true, Pull: true}))
 		}
 		c.JSONSuccess(&repos)
 		return
@@ -129,10 +144,24 @@ func listReposOfUser(c *context.APIContext, username string) {
 	numOwnRepos := len(ownRepos)
 	repos := make([]*types.Repository, 0, numOwnRepos+len(accessibleReposWithAccessMode))
 	for _, r := range ownRepos {
-		repos = append(repos, toRepository(r, &types.RepositoryPermission{Admin: true, Push: true, Pull: true}))
+		access := tokenAccessMode(c, r, database.AccessModeOwner)
+		if access == database.AccessModeNone {
+			continue
+		}
+		repos = append(repos,
+			toRepository(r, &types.RepositoryPermission{
+				Admin: access >= database.AccessModeAdmin,
+				Push:  access >= database.AccessModeWrite,
+				Pull:  true,
+			}),
+		)
 	}
 
 	for repo, access := range accessibleReposWithAccessMode {
+		access = tokenAccessMode(c, repo, access)
+		if access == database.AccessModeNone {
+			continue
+		}
 		repos = append(repos,
 			toRepository(repo, &types.RepositoryPermission{
 				Admin: access >= database.AccessModeAdmin,
@@ -284,6 +313,7 @@ func migrate(c *context.APIContext, f form.MigrateRepo) {
 		IsPrivate:   f.Private || conf.Repository.ForcePrivate,
 		IsMirror:    f.Mirror,
 		RemoteAddr:  remoteAddr,
+		EnableLFS:   f.LFS,
 	})
 	if err != nil {
 		if repo != nil {
@@ -355,6 +385,7 @@ func deleteRepo(c *context.APIContext) {
 	}
 
 	log.Trace("Repository deleted: %s/%s", owner.Name, repo.Name)
+	c.AuditLog(database.AuditActionRepoDelete, database.AuditTarget{Type: database.AuditTargetRepository, ID: repo.ID, Name: owner.Name + "/" + repo.Name}, "")
 	c.NoContent()
 }
 
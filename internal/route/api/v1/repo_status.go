@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/gitx"
+	"gogs.io/gogs/internal/route/api/v1/types"
+)
+
+// resolveCommitID returns the full commit ID of the given reference, which can
+// be a branch, a tag or a commit SHA. It returns an empty string when a
+// response has already been written.
+func resolveCommitID(c *context.APIContext, ref string) string {
+	gitRepo, err := git.Open(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.Error(err, "open repository")
+		return ""
+	}
+
+	commit, err := gitRepo.CatFileCommit(ref)
+	if err != nil {
+		c.NotFoundOrError(gitx.NewError(err), "get commit")
+		return ""
+	}
+	return commit.ID.String()
+}
+
+type createCommitStatusRequest struct {
+	State       string `json:"state" binding:"Required;In(pending,success,error,failure)"`
+	TargetURL   string `json:"target_url" binding:"Url"`
+	Description string `json:"description"`
+	Context     string `json:"context" binding:"MaxSize(255)"`
+}
+
+func createCommitStatus(c *context.APIContext, form createCommitStatusRequest) {
+	sha := resolveCommitID(c, c.Params(":sha"))
+	if c.Written() {
+		return
+	}
+
+	status, err := database.Handle.CommitStatuses().Create(
+		c.Req.Context(),
+		c.User,
+		c.Repo.Repository,
+		sha,
+		database.CreateCommitStatusOptions{
+			State:       database.CommitStatusState(form.State),
+			TargetURL:   form.TargetURL,
+			Description: form.Description,
+			Context:     form.Context,
+		},
+	)
+	if err != nil {
+		c.Error(err, "create commit status")
+		return
+	}
+
+	if status.State == database.CommitStatusSuccess {
+		go database.AddAutoMergeTasks(c.Repo.Repository.ID)
+	}
+	c.JSON(http.StatusCreated, toCommitStatus(status))
+}
+
+func listCommitStatuses(c *context.APIContext, ref string) {
+	sha := resolveCommitID(c, ref)
+	if c.Written() {
+		return
+	}
+
+	statuses, err := database.Handle.CommitStatuses().ListBySHA(c.Req.Context(), c.Repo.Repository.ID, sha)
+	if err != nil {
+		c.Error(err, "list commit statuses")
+		return
+	}
+	if err = database.Handle.CommitStatuses().LoadCreators(c.Req.Context(), statuses); err != nil {
+		c.Error(err, "load creators")
+		return
+	}
+
+	apiStatuses := make([]*types.CommitStatus, len(statuses))
+	for i := range statuses {
+		apiStatuses[i] = toCommitStatus(statuses[i])
+	}
+	c.JSONSuccess(&apiStatuses)
+}
+
+func listCommitStatusesBySHA(c *context.APIContext) {
+	listCommitStatuses(c, c.Params(":sha"))
+}
+
+func listCommitStatusesByRef(c *context.APIContext) {
+	listCommitStatuses(c, c.Params(":ref"))
+}
+
+func getCombinedCommitStatus(c *context.APIContext) {
+	sha := resolveCommitID(c, c.Params(":ref"))
+	if c.Written() {
+		return
+	}
+
+	statuses, err := database.Handle.CommitStatuses().ListLatestBySHA(c.Req.Context(), c.Repo.Repository.ID, sha)
+	if err != nil {
+		c.Error(err, "list latest commit statuses")
+		return
+	}
+	if err = database.Handle.CommitStatuses().LoadCreators(c.Req.Context(), statuses); err != nil {
+		c.Error(err, "load creators")
+		return
+	}
+
+	apiStatuses := make([]*types.CommitStatus, len(statuses))
+	for i := range statuses {
+		apiStatuses[i] = toCommitStatus(statuses[i])
+	}
+	c.JSONSuccess(&types.CombinedCommitStatus{
+		State:      types.CommitStatusState(database.CombinedCommitStatusState(statuses)),
+		SHA:        sha,
+		TotalCount: len(statuses),
+		Statuses:   apiStatuses,
+		Repository: toRepository(c.Repo.Repository, nil),
+	})
+}
@@ -0,0 +1,30 @@
+package types
+
+import "time"
+
+type CommitStatusState string
+
+const (
+	CommitStatusPending CommitStatusState = "pending"
+	CommitStatusSuccess CommitStatusState = "success"
+	CommitStatusError   CommitStatusState = "error"
+	CommitStatusFailure CommitStatusState = "failure"
+)
+
+type CommitStatus struct {
+	ID          int64             `json:"id"`
+	State       CommitStatusState `json:"state"`
+	TargetURL   string            `json:"target_url"`
+	Description string            `json:"description"`
+	Context     string            `json:"context"`
+	Creator     *User             `json:"creator"`
+	Created     time.Time         `json:"created_at"`
+}
+
+type CombinedCommitStatus struct {
+	State      CommitStatusState `json:"state"`
+	SHA        string            `json:"sha"`
+	TotalCount int               `json:"total_count"`
+	Statuses   []*CommitStatus   `json:"statuses"`
+	Repository *Repository       `json:"repository"`
+}
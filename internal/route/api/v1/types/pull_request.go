@@ -24,3 +24,38 @@ type PullRequest struct {
 	MergedCommitID *string         `json:"merge_commit_sha"`
 	MergedBy       *User           `json:"merged_by"`
 }
+
+type PullRequestFileStatus string
+
+const (
+	PullRequestFileAdded    PullRequestFileStatus = "added"
+	PullRequestFileModified PullRequestFileStatus = "modified"
+	PullRequestFileRemoved  PullRequestFileStatus = "removed"
+	PullRequestFileRenamed  PullRequestFileStatus = "renamed"
+)
+
+type PullRequestFile struct {
+	Filename         string                `json:"filename"`
+	PreviousFilename string                `json:"previous_filename,omitempty"`
+	Status           PullRequestFileStatus `json:"status"`
+	Additions        int                   `json:"additions"`
+	Deletions        int                   `json:"deletions"`
+	Changes          int                   `json:"changes"`
+}
+
+type PullRequestReviewState string
+
+const (
+	PullRequestReviewCommented        PullRequestReviewState = "commented"
+	PullRequestReviewApproved         PullRequestReviewState = "approved"
+	PullRequestReviewChangesRequested PullRequestReviewState = "changes_requested"
+)
+
+type PullRequestReview struct {
+	ID        int64                  `json:"id"`
+	Reviewer  *User                  `json:"user"`
+	State     PullRequestReviewState `json:"state"`
+	Body      string                 `json:"body"`
+	CommitID  string                 `json:"commit_id"`
+	Submitted time.Time              `json:"submitted_at"`
+}
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+type PushMirror struct {
+	ID       int64  `json:"id"`
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	// Hours between periodical pushes, 0 means only pushing after every push.
+	Interval  int        `json:"interval"`
+	LastError string     `json:"last_error"`
+	LastSync  *time.Time `json:"last_sync_at"`
+	Created   time.Time  `json:"created_at"`
+}
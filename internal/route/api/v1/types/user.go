@@ -18,8 +18,10 @@ type UserEmail struct {
 }
 
 type UserAccessToken struct {
-	Name string `json:"name"`
-	Sha1 string `json:"sha1"`
+	Name      string     `json:"name"`
+	Sha1      string     `json:"sha1"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
 type UserPublicKey struct {
@@ -148,6 +148,21 @@ type WebhookPullRequestPayload struct {
 
 func (p *WebhookPullRequestPayload) JSONPayload() ([]byte, error) { return jsonPayload(p) }
 
+type WebhookPullRequestReviewAction string
+
+const WebhookPullRequestReviewSubmitted WebhookPullRequestReviewAction = "submitted"
+
+type WebhookPullRequestReviewPayload struct {
+	Action      WebhookPullRequestReviewAction `json:"action"`
+	Index       int64                          `json:"number"`
+	Review      *PullRequestReview             `json:"review"`
+	PullRequest *PullRequest                   `json:"pull_request"`
+	Repository  *Repository                    `json:"repository"`
+	Sender      *User                          `json:"sender"`
+}
+
+func (p *WebhookPullRequestReviewPayload) JSONPayload() ([]byte, error) { return jsonPayload(p) }
+
 type WebhookReleasePayload struct {
 	Action     WebhookReleaseAction `json:"action"`
 	Release    *RepositoryRelease   `json:"release"`
@@ -156,3 +171,17 @@ type WebhookReleasePayload struct {
 }
 
 func (p *WebhookReleasePayload) JSONPayload() ([]byte, error) { return jsonPayload(p) }
+
+type WebhookStatusPayload struct {
+	ID          int64             `json:"id"`
+	SHA         string            `json:"sha"`
+	State       CommitStatusState `json:"state"`
+	TargetURL   string            `json:"target_url"`
+	Description string            `json:"description"`
+	Context     string            `json:"context"`
+	Created     time.Time         `json:"created_at"`
+	Repository  *Repository       `json:"repository"`
+	Sender      *User             `json:"sender"`
+}
+
+func (p *WebhookStatusPayload) JSONPayload() ([]byte, error) { return jsonPayload(p) }
@@ -3,6 +3,9 @@ package v1
 import (
 	gocontext "context"
 	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
 
 	"gopkg.in/macaron.v1"
 
@@ -34,34 +37,63 @@ func (h *accessTokensHandler) List() macaron.Handler {
 
 		apiTokens := make([]*types.UserAccessToken, len(tokens))
 		for i := range tokens {
-			apiTokens[i] = &types.UserAccessToken{
-				Name: tokens[i].Name,
-				Sha1: tokens[i].Sha1,
-			}
+			apiTokens[i] = toUserAccessToken(tokens[i])
 		}
 		c.JSONSuccess(&apiTokens)
 	}
 }
 
+func toUserAccessToken(t *database.AccessToken) *types.UserAccessToken {
+	apiToken := &types.UserAccessToken{
+		Name:   t.Name,
+		Sha1:   t.Sha1,
+		Scopes: make([]string, 0),
+	}
+	for _, scope := range t.ScopeList() {
+		apiToken.Scopes = append(apiToken.Scopes, string(scope))
+	}
+	if !t.Expires.IsZero() {
+		apiToken.ExpiresAt = &t.Expires
+	}
+	return apiToken
+}
+
 type createAccessTokenRequest struct {
-	Name string `json:"name" binding:"Required"`
+	Name         string     `json:"name" binding:"Required"`
+	Scopes       []string   `json:"scopes"`
+	Repositories []string   `json:"repositories"`
+	ExpiresAt    *time.Time `json:"expires_at"`
 }
 
 func (h *accessTokensHandler) Create() macaron.Handler {
 	return func(c *context.APIContext, form createAccessTokenRequest) {
-		t, err := h.store.CreateAccessToken(c.Req.Context(), c.User.ID, form.Name)
+		opts := database.CreateAccessTokenOptions{
+			Repositories: form.Repositories,
+		}
+		for _, scope := range form.Scopes {
+			opts.Scopes = append(opts.Scopes, database.AccessTokenScope(scope))
+		}
+		if form.ExpiresAt != nil {
+			if !form.ExpiresAt.After(time.Now()) {
+				c.ErrorStatus(http.StatusUnprocessableEntity, errors.New("expires_at must be in the future"))
+				return
+			}
+			opts.Expires = *form.ExpiresAt
+		}
+
+		t, err := h.store.CreateAccessToken(c.Req.Context(), c.User.ID, form.Name, opts)
 		if err != nil {
-			if database.IsErrAccessTokenAlreadyExist(err) {
+			if database.IsErrAccessTokenAlreadyExist(err) ||
+				database.IsErrInvalidAccessTokenScope(err) ||
+				database.IsErrRepoNotExist(err) {
 				c.ErrorStatus(http.StatusUnprocessableEntity, err)
 			} else {
 				c.Error(err, "new access token")
 			}
 			return
 		}
-		c.JSON(http.StatusCreated, &types.UserAccessToken{
-			Name: t.Name,
-			Sha1: t.Sha1,
-		})
+		c.AuditLog(database.AuditActionUserAccessTokenCreate, database.AuditTargetOfUser(c.User), database.AuditDetailsOfAccessToken(t))
+		c.JSON(http.StatusCreated, toUserAccessToken(t))
 	}
 }
 
@@ -71,8 +103,10 @@ func (h *accessTokensHandler) Create() macaron.Handler {
 type AccessTokensStore interface {
 	// CreateAccessToken creates a new access token and persist to database. It
 	// returns database.ErrAccessTokenAlreadyExist when an access token with same
-	// name already exists for the user.
-	CreateAccessToken(ctx gocontext.Context, userID int64, name string) (*database.AccessToken, error)
+	// name already exists for the user, database.ErrInvalidAccessTokenScope when
+	// any of the scopes is not valid, or database.ErrRepoNotExist when any of the
+	// repositories does not exist or is not readable by the user.
+	CreateAccessToken(ctx gocontext.Context, userID int64, name string, opts database.CreateAccessTokenOptions) (*database.AccessToken, error)
 	// ListAccessTokens returns all access tokens belongs to given user.
 	ListAccessTokens(ctx gocontext.Context, userID int64) ([]*database.AccessToken, error)
 }
@@ -85,8 +119,8 @@ func newAccessTokensStore() AccessTokensStore {
 	return &accessTokensStore{}
 }
 
-func (*accessTokensStore) CreateAccessToken(ctx gocontext.Context, userID int64, name string) (*database.AccessToken, error) {
-	return database.Handle.AccessTokens().Create(ctx, userID, name)
+func (*accessTokensStore) CreateAccessToken(ctx gocontext.Context, userID int64, name string, opts database.CreateAccessTokenOptions) (*database.AccessToken, error) {
+	return database.Handle.AccessTokens().Create(ctx, userID, name, opts)
 }
 
 func (*accessTokensStore) ListAccessTokens(ctx gocontext.Context, userID int64) ([]*database.AccessToken, error) {
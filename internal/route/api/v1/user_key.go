@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/cockroachdb/errors"
@@ -72,24 +73,25 @@ type createPublicKeyRequest struct {
 	Key   string `json:"key" binding:"Required"`
 }
 
-func createUserPublicKey(c *context.APIContext, form createPublicKeyRequest, uid int64) {
+func createUserPublicKey(c *context.APIContext, form createPublicKeyRequest, u *database.User) {
 	content, err := database.CheckPublicKeyString(form.Key)
 	if err != nil {
 		handleCheckKeyStringError(c, err)
 		return
 	}
 
-	key, err := database.AddPublicKey(uid, form.Title, content)
+	key, err := database.AddPublicKey(u.ID, form.Title, content)
 	if err != nil {
 		handleAddKeyError(c, err)
 		return
 	}
+	c.AuditLog(database.AuditActionUserSSHKeyAdd, database.AuditTargetOfUser(u), fmt.Sprintf("key %q (%s)", key.Name, key.Fingerprint))
 	apiLink := composePublicKeysAPILink()
 	c.JSON(http.StatusCreated, toUserPublicKey(apiLink, key))
 }
 
 func createPublicKey(c *context.APIContext, form createPublicKeyRequest) {
-	createUserPublicKey(c, form, c.User.ID)
+	createUserPublicKey(c, form, c.User)
 }
 
 func deletePublicKey(c *context.APIContext) {
@@ -101,6 +103,7 @@ func deletePublicKey(c *context.APIContext) {
 		}
 		return
 	}
+	c.AuditLog(database.AuditActionUserSSHKeyDelete, database.AuditTargetOfUser(c.User), fmt.Sprintf("key ID %d", c.ParamsInt64(":id")))
 
 	c.NoContent()
 }
@@ -26,6 +26,8 @@ type basicHandler struct {
 	defaultStorage lfsx.Storage
 	// The list of available storage backends to access objects.
 	storagers map[lfsx.Storage]lfsx.Storager
+	// Whether to hand out presigned URLs for storage backends that support them.
+	presignedURLs bool
 }
 
 // DefaultStorager returns the default storage backend.
@@ -38,6 +40,16 @@ func (h *basicHandler) Storager(storage lfsx.Storage) lfsx.Storager {
 	return h.storagers[storage]
 }
 
+// PresignStorager returns the given storage backend if presigned URLs should
+// be handed out for it, or nil otherwise.
+func (h *basicHandler) PresignStorager(storage lfsx.Storage) lfsx.PresignStorager {
+	if !h.presignedURLs {
+		return nil
+	}
+	s, _ := h.storagers[storage].(lfsx.PresignStorager)
+	return s
+}
+
 // GET /{owner}/{repo}.git/info/lfs/object/basic/{oid}
 func (h *basicHandler) serveDownload(c *macaron.Context, repo *database.Repository, oid lfsx.OID) {
 	object, err := h.store.GetLFSObjectByOID(c.Req.Context(), repo.ID, oid)
@@ -138,15 +150,18 @@ func (h *basicHandler) serveVerify(c *macaron.Context, repo *database.Repository
 
 	object, err := h.store.GetLFSObjectByOID(c.Req.Context(), repo.ID, request.Oid)
 	if err != nil {
-		if database.IsErrLFSObjectNotExist(err) {
-			responseJSON(c.Resp, http.StatusNotFound, responseError{
-				Message: "Object does not exist",
-			})
-		} else {
+		if !database.IsErrLFSObjectNotExist(err) {
 			internalServerError(c.Resp)
 			log.Error("Failed to get object [repo_id: %d, oid: %s]: %v", repo.ID, request.Oid, err)
+			return
+		}
+
+		// The object may have been uploaded via a presigned URL, which is
+		// committed upon verification.
+		object = h.commitPresignedUpload(c, repo, request.Oid)
+		if object == nil {
+			return
 		}
-		return
 	}
 
 	if object.Size != request.Size {
@@ -158,6 +173,47 @@ func (h *basicHandler) serveVerify(c *macaron.Context, repo *database.Repository
 	c.Status(http.StatusOK)
 }
 
+// commitPresignedUpload commits the object uploaded to the repository via a
+// presigned URL, and creates the object in the database. It returns nil when a
+// response has already been written.
+func (h *basicHandler) commitPresignedUpload(c *macaron.Context, repo *database.Repository, oid lfsx.OID) *database.LFSObject {
+	s := h.PresignStorager(h.defaultStorage)
+	if s == nil {
+		responseJSON(c.Resp, http.StatusNotFound, responseError{
+			Message: "Object does not exist",
+		})
+		return nil
+	}
+
+	size, err := s.CommitUpload(repo.ID, oid)
+	if err != nil {
+		if err == lfsx.ErrObjectNotExist {
+			responseJSON(c.Resp, http.StatusNotFound, responseError{
+				Message: "Object does not exist",
+			})
+		} else {
+			internalServerError(c.Resp)
+			log.Error("Failed to commit upload [storage: %s, repo_id: %d, oid: %s]: %v", s.Storage(), repo.ID, oid, err)
+		}
+		return nil
+	}
+
+	err = h.store.CreateLFSObject(c.Req.Context(), repo.ID, oid, size, s.Storage())
+	if err != nil {
+		internalServerError(c.Resp)
+		log.Error("Failed to create object [repo_id: %d, oid: %s]: %v", repo.ID, oid, err)
+		return nil
+	}
+
+	log.Trace("[LFS] Object created %q", oid)
+	return &database.LFSObject{
+		RepoID:  repo.ID,
+		OID:     oid,
+		Size:    size,
+		Storage: s.Storage(),
+	}
+}
+
 type basicVerifyRequest struct {
 	Oid  lfsx.OID `json:"oid"`
 	Size int64    `json:"size"`
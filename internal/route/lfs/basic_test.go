@@ -37,6 +37,58 @@ func (s *mockStorage) Download(_ lfsx.OID, w io.Writer) error {
 	return err
 }
 
+func (*mockStorage) Walk(func(lfsx.ObjectInfo) error) error {
+	return nil
+}
+
+func (*mockStorage) Delete(lfsx.OID) error {
+	return nil
+}
+
+var _ lfsx.PresignStorager = (*mockPresignStorage)(nil)
+
+// mockPresignStorage is an in-memory storage for LFS objects that hands out
+// presigned URLs.
+type mockPresignStorage struct {
+	mockStorage
+	// The sizes of objects uploaded via presigned URLs.
+	uploaded map[lfsx.OID]int64
+}
+
+func (*mockPresignStorage) Storage() lfsx.Storage {
+	return "presign"
+}
+
+func (*mockPresignStorage) PresignUpload(_ int64, oid lfsx.OID) (string, map[string]string, error) {
+	return "https://storage.example.com/upload/" + string(oid), map[string]string{"X-Amz-Content-Sha256": string(oid)}, nil
+}
+
+func (s *mockPresignStorage) CommitUpload(_ int64, oid lfsx.OID) (int64, error) {
+	size, ok := s.uploaded[oid]
+	if !ok {
+		return 0, lfsx.ErrObjectNotExist
+	}
+	return size, nil
+}
+
+func (*mockPresignStorage) PresignDownload(oid lfsx.OID) (string, error) {
+	return "https://storage.example.com/" + string(oid), nil
+}
+
+func (s *mockPresignStorage) WalkUploads(fn func(repoID int64, info lfsx.ObjectInfo) error) error {
+	for oid, size := range s.uploaded {
+		if err := fn(1, lfsx.ObjectInfo{OID: oid, Size: size}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mockPresignStorage) DeleteUpload(_ int64, oid lfsx.OID) error {
+	delete(s.uploaded, oid)
+	return nil
+}
+
 func TestBasicHandler_serveDownload(t *testing.T) {
 	s := &mockStorage{}
 	basic := &basicHandler{
@@ -274,3 +326,75 @@ func TestBasicHandler_serveVerify(t *testing.T) {
 		})
 	}
 }
+
+func TestBasicHandler_serveVerify_presigned(t *testing.T) {
+	s := &mockPresignStorage{
+		uploaded: map[lfsx.OID]int64{
+			"ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f": 12,
+		},
+	}
+	basic := &basicHandler{
+		defaultStorage: s.Storage(),
+		storagers: map[lfsx.Storage]lfsx.Storager{
+			s.Storage(): s,
+		},
+		presignedURLs: true,
+	}
+
+	m := macaron.New()
+	m.Use(macaron.Renderer())
+	m.Use(func(c *macaron.Context) {
+		c.Map(&database.Repository{ID: 1, Name: "repo"})
+	})
+	m.Post("/", basic.serveVerify)
+
+	tests := []struct {
+		name          string
+		body          string
+		expStatusCode int
+		expBody       string
+	}{
+		{
+			name:          "object not uploaded",
+			body:          `{"oid":"5cac0a318669fadfee734fb340a5f5b70b428ac57a9f4b109cb6e150b2ba7e57", "size":12}`,
+			expStatusCode: http.StatusNotFound,
+			expBody:       `{"message":"Object does not exist"}` + "\n",
+		},
+		{
+			name:          "object uploaded",
+			body:          `{"oid":"ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size":12}`,
+			expStatusCode: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := NewMockStore()
+			mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(nil, database.ErrLFSObjectNotExist{})
+			basic.store = mockStore
+
+			r, err := http.NewRequest("POST", "/", strings.NewReader(test.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			resp := rr.Result()
+			assert.Equal(t, test.expStatusCode, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expBody, string(body))
+
+			calls := mockStore.CreateLFSObjectFunc.History()
+			if test.expStatusCode == http.StatusOK {
+				require.Len(t, calls, 1)
+				assert.Equal(t, int64(1), calls[0].Arg1)
+				assert.Equal(t, lfsx.OID("ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f"), calls[0].Arg2)
+				assert.Equal(t, int64(12), calls[0].Arg3)
+				assert.Equal(t, s.Storage(), calls[0].Arg4)
+			} else {
+				assert.Empty(t, calls)
+			}
+		})
+	}
+}
@@ -15,8 +15,8 @@ import (
 )
 
 // POST /{owner}/{repo}.git/info/lfs/object/batch
-func serveBatch(store Store) macaron.Handler {
-	return func(c *macaron.Context, owner *database.User, repo *database.Repository) {
+func serveBatch(store Store, basic *basicHandler) macaron.Handler {
+	return func(c *macaron.Context, actor authenticatedUser, owner *database.User, repo *database.Repository) {
 		var request batchRequest
 		defer func() { _ = c.Req.Request.Body.Close() }()
 		err := json.NewDecoder(c.Req.Request.Body).Decode(&request)
@@ -27,7 +27,8 @@ func serveBatch(store Store) macaron.Handler {
 			return
 		}
 
-		// NOTE: We only support basic transfer as of now.
+		// NOTE: We only support basic transfer as of now, which works with
+		// presigned URLs as well.
 		transfer := transferBasic
 		// Example: https://try.gogs.io/gogs/gogs.git/info/lfs/object/basic
 		baseHref := fmt.Sprintf("%s%s/%s.git/info/lfs/objects/basic", conf.Server.ExternalURL, owner.Name, repo.Name)
@@ -35,9 +36,43 @@ func serveBatch(store Store) macaron.Handler {
 		objects := make([]batchObject, 0, len(request.Objects))
 		switch request.Operation {
 		case basicOperationUpload:
+			// The batch endpoint only requires read access, but objects can only be
+			// uploaded with write access.
+			canUpload := authorizeAccess(c.Req.Context(), store, actor, repo, database.AccessModeWrite)
+			presigner := basic.PresignStorager(basic.defaultStorage)
 			for _, obj := range request.Objects {
 				var actions batchActions
-				if lfsx.ValidOID(obj.Oid) {
+				if !lfsx.ValidOID(obj.Oid) {
+					actions = batchActions{
+						Error: &batchError{
+							Code:    http.StatusUnprocessableEntity,
+							Message: "Object has invalid oid",
+						},
+					}
+				} else if !canUpload {
+					actions = batchActions{
+						Error: &batchError{
+							Code:    http.StatusForbidden,
+							Message: "Write access required",
+						},
+					}
+				} else if presigner != nil {
+					href, header, err := presigner.PresignUpload(repo.ID, obj.Oid)
+					if err != nil {
+						internalServerError(c.Resp)
+						log.Error("Failed to presign upload [repo_id: %d, oid: %s]: %v", repo.ID, obj.Oid, err)
+						return
+					}
+					actions = batchActions{
+						Upload: &batchAction{
+							Href:   href,
+							Header: header,
+						},
+						Verify: &batchAction{
+							Href: fmt.Sprintf("%s/verify", baseHref),
+						},
+					}
+				} else {
 					actions = batchActions{
 						Upload: &batchAction{
 							Href: fmt.Sprintf("%s/%s", baseHref, obj.Oid),
@@ -51,13 +86,6 @@ func serveBatch(store Store) macaron.Handler {
 							Href: fmt.Sprintf("%s/verify", baseHref),
 						},
 					}
-				} else {
-					actions = batchActions{
-						Error: &batchError{
-							Code:    http.StatusUnprocessableEntity,
-							Message: "Object has invalid oid",
-						},
-					}
 				}
 
 				objects = append(objects, batchObject{
@@ -91,6 +119,16 @@ func serveBatch(store Store) macaron.Handler {
 							Code:    http.StatusUnprocessableEntity,
 							Message: "Object size mismatch",
 						}
+					} else if presigner := basic.PresignStorager(stored.Storage); presigner != nil {
+						href, err := presigner.PresignDownload(obj.Oid)
+						if err != nil {
+							internalServerError(c.Resp)
+							log.Error("Failed to presign download [repo_id: %d, oid: %s]: %v", repo.ID, obj.Oid, err)
+							return
+						}
+						actions.Download = &batchAction{
+							Href: href,
+						}
 					} else {
 						actions.Download = &batchAction{
 							Href: fmt.Sprintf("%s/%s", baseHref, obj.Oid),
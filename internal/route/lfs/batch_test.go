@@ -14,6 +14,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/lfsx"
 )
 
 func TestServeBatch(t *testing.T) {
@@ -21,10 +22,19 @@ func TestServeBatch(t *testing.T) {
 		ExternalURL: "https://gogs.example.com/",
 	})
 
+	// The actor has write access to the repository unless the mock store
+	// overrides.
+	writableStore := func() *MockStore {
+		mockStore := NewMockStore()
+		mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+		return mockStore
+	}
+
 	tests := []struct {
 		name          string
 		body          string
 		mockStore     func() *MockStore
+		token         *lfsx.Token
 		expStatusCode int
 		expBody       string
 	}{
@@ -42,6 +52,7 @@ func TestServeBatch(t *testing.T) {
 	{"oid": "bad_oid", "size": 123},
 	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123}
 ]}`,
+			mockStore:     writableStore,
 			expStatusCode: http.StatusOK,
 			expBody: `{
 	"transfer": "basic",
@@ -61,6 +72,50 @@ func TestServeBatch(t *testing.T) {
 			}
 		}
 	]
+}` + "\n",
+		},
+		{
+			name: "upload: no write access",
+			body: `{
+"operation": "upload",
+"objects": [
+	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123}
+]}`,
+			expStatusCode: http.StatusOK,
+			expBody: `{
+	"transfer": "basic",
+	"objects": [
+		{
+			"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+			"size": 123,
+			"actions": {"error": {"code": 403, "message": "Write access required"}}
+		}
+	]
+}` + "\n",
+		},
+		{
+			name: "upload: token granted for download",
+			body: `{
+"operation": "upload",
+"objects": [
+	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123}
+]}`,
+			mockStore: writableStore,
+			token: &lfsx.Token{
+				UserID:    1,
+				RepoID:    1,
+				Operation: basicOperationDownload,
+			},
+			expStatusCode: http.StatusOK,
+			expBody: `{
+	"transfer": "basic",
+	"objects": [
+		{
+			"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+			"size": 123,
+			"actions": {"error": {"code": 403, "message": "Write access required"}}
+		}
+	]
 }` + "\n",
 		},
 		{
@@ -120,10 +175,11 @@ func TestServeBatch(t *testing.T) {
 
 			m := macaron.New()
 			m.Use(func(c *macaron.Context) {
+				c.Map(authenticatedUser{User: &database.User{ID: 1}, Token: test.token})
 				c.Map(&database.User{Name: "owner"})
-				c.Map(&database.Repository{Name: "repo"})
+				c.Map(&database.Repository{ID: 1, Name: "repo"})
 			})
-			m.Post("/", serveBatch(mockStore))
+			m.Post("/", serveBatch(mockStore, &basicHandler{}))
 
 			r, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
 			require.NoError(t, err)
@@ -149,3 +205,164 @@ func TestServeBatch(t *testing.T) {
 		})
 	}
 }
+
+func TestServeBatch_presigned(t *testing.T) {
+	conf.SetMockServer(t, conf.ServerOpts{
+		ExternalURL: "https://gogs.example.com/",
+	})
+
+	s := &mockPresignStorage{}
+	basic := &basicHandler{
+		defaultStorage: s.Storage(),
+		storagers: map[lfsx.Storage]lfsx.Storager{
+			s.Storage():       s,
+			lfsx.StorageLocal: &mockStorage{},
+		},
+		presignedURLs: true,
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		mockStore func() *MockStore
+		expBody   string
+	}{
+		{
+			name: "upload: no write access",
+			body: `{
+"operation": "upload",
+"objects": [
+	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123}
+]}`,
+			expBody: `{
+	"transfer": "basic",
+	"objects": [
+		{
+			"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+			"size": 123,
+			"actions": {"error": {"code": 403, "message": "Write access required"}}
+		}
+	]
+}` + "\n",
+		},
+		{
+			name: "upload",
+			body: `{
+"operation": "upload",
+"objects": [
+	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123}
+]}`,
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				return mockStore
+			},
+			expBody: `{
+	"transfer": "basic",
+	"objects": [
+		{
+			"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+			"size": 123,
+			"actions": {
+				"upload": {
+					"href": "https://storage.example.com/upload/ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+					"header": {"X-Amz-Content-Sha256": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f"}
+				},
+				"verify": {
+					"href": "https://gogs.example.com/owner/repo.git/info/lfs/objects/basic/verify"
+				}
+			}
+		}
+	]
+}` + "\n",
+		},
+		{
+			name: "download: objects in different storage backends",
+			body: `{
+"operation": "download",
+"objects": [
+	{"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", "size": 123},
+	{"oid": "5cac0a318669fadfee734fb340a5f5b70b428ac57a9f4b109cb6e150b2ba7e57", "size": 456}
+]}`,
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectsByOIDsFunc.SetDefaultReturn(
+					[]*database.LFSObject{
+						{
+							OID:     "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+							Size:    123,
+							Storage: s.Storage(),
+						}, {
+							OID:     "5cac0a318669fadfee734fb340a5f5b70b428ac57a9f4b109cb6e150b2ba7e57",
+							Size:    456,
+							Storage: lfsx.StorageLocal,
+						},
+					},
+					nil,
+				)
+				return mockStore
+			},
+			expBody: `{
+	"transfer": "basic",
+	"objects": [
+		{
+			"oid": "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f",
+			"size": 123,
+			"actions": {
+				"download": {
+					"href": "https://storage.example.com/ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f"
+				}
+			}
+		},
+		{
+			"oid": "5cac0a318669fadfee734fb340a5f5b70b428ac57a9f4b109cb6e150b2ba7e57",
+			"size": 456,
+			"actions": {
+				"download": {
+					"href": "https://gogs.example.com/owner/repo.git/info/lfs/objects/basic/5cac0a318669fadfee734fb340a5f5b70b428ac57a9f4b109cb6e150b2ba7e57"
+				}
+			}
+		}
+	]
+}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := NewMockStore()
+			if test.mockStore != nil {
+				mockStore = test.mockStore()
+			}
+
+			m := macaron.New()
+			m.Use(func(c *macaron.Context) {
+				c.Map(authenticatedUser{User: &database.User{ID: 1}})
+				c.Map(&database.User{Name: "owner"})
+				c.Map(&database.Repository{ID: 1, Name: "repo"})
+			})
+			m.Post("/", serveBatch(mockStore, basic))
+
+			r, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			resp := rr.Result()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			var expBody bytes.Buffer
+			err = json.Indent(&expBody, []byte(test.expBody), "", "  ")
+			require.NoError(t, err)
+
+			var gotBody bytes.Buffer
+			err = json.Indent(&gotBody, body, "", "  ")
+			require.NoError(t, err)
+
+			assert.Equal(t, expBody.String(), gotBody.String())
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package lfs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/macaron.v1"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/strx"
+)
+
+// maxLocksPerPage is the maximum number of locks returned in a single response
+// of listing and verifying locks.
+const maxLocksPerPage = 100
+
+// POST /{owner}/{repo}.git/info/lfs/locks
+func serveCreateLock(store Store) macaron.Handler {
+	return func(c *macaron.Context, actor authenticatedUser, repo *database.Repository) {
+		var request createLockRequest
+		if !decodeLockRequest(c, &request) {
+			return
+		}
+
+		path := database.CleanLFSLockPath(request.Path)
+		if path == "" {
+			responseJSON(c.Resp, http.StatusUnprocessableEntity, responseError{
+				Message: "Path is required",
+			})
+			return
+		}
+
+		created, err := store.CreateLFSLock(c.Req.Context(), repo.ID, actor.ID, path)
+		if err != nil {
+			if !database.IsErrLFSLockAlreadyExist(err) {
+				internalServerError(c.Resp)
+				log.Error("Failed to create lock [repo_id: %d, path: %s]: %v", repo.ID, path, err)
+				return
+			}
+
+			existing, err := store.GetLFSLockByPath(c.Req.Context(), repo.ID, path)
+			if err != nil {
+				internalServerError(c.Resp)
+				log.Error("Failed to get lock [repo_id: %d, path: %s]: %v", repo.ID, path, err)
+				return
+			}
+			locks, err := toLocks(c.Req.Context(), store, existing)
+			if err != nil {
+				internalServerError(c.Resp)
+				log.Error("Failed to convert lock [id: %d]: %v", existing.ID, err)
+				return
+			}
+			responseJSON(c.Resp, http.StatusConflict, createLockResponse{
+				Lock:    locks[0],
+				Message: "Lock already exists",
+			})
+			return
+		}
+
+		responseJSON(c.Resp, http.StatusCreated, createLockResponse{
+			Lock: toLock(created, actor.User),
+		})
+	}
+}
+
+// GET /{owner}/{repo}.git/info/lfs/locks
+func serveListLocks(store Store) macaron.Handler {
+	return func(c *macaron.Context, repo *database.Repository) {
+		opts, message := parseLocksPage(c.Query("cursor"), c.Query("limit"))
+		if message != "" {
+			responseJSON(c.Resp, http.StatusBadRequest, responseError{
+				Message: message,
+			})
+			return
+		}
+		opts.Path = c.Query("path")
+		if id := c.Query("id"); id != "" {
+			opts.ID, _ = strconv.ParseInt(id, 10, 64)
+			if opts.ID <= 0 {
+				responseJSON(c.Resp, http.StatusOK, listLocksResponse{Locks: []*lock{}})
+				return
+			}
+		}
+
+		locks, nextCursor, err := listLocks(c.Req.Context(), store, repo.ID, opts)
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to list locks [repo_id: %d]: %v", repo.ID, err)
+			return
+		}
+		converted, err := toLocks(c.Req.Context(), store, locks...)
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to convert locks [repo_id: %d]: %v", repo.ID, err)
+			return
+		}
+
+		responseJSON(c.Resp, http.StatusOK, listLocksResponse{
+			Locks:      converted,
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+// POST /{owner}/{repo}.git/info/lfs/locks/verify
+func serveVerifyLocks(store Store) macaron.Handler {
+	return func(c *macaron.Context, actor authenticatedUser, repo *database.Repository) {
+		var request verifyLocksRequest
+		if !decodeLockRequest(c, &request) {
+			return
+		}
+
+		var limit string
+		if request.Limit > 0 {
+			limit = strconv.Itoa(request.Limit)
+		}
+		opts, message := parseLocksPage(request.Cursor, limit)
+		if message != "" {
+			responseJSON(c.Resp, http.StatusBadRequest, responseError{
+				Message: message,
+			})
+			return
+		}
+
+		locks, nextCursor, err := listLocks(c.Req.Context(), store, repo.ID, opts)
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to list locks [repo_id: %d]: %v", repo.ID, err)
+			return
+		}
+
+		var ours, theirs []*database.LFSLock
+		for _, l := range locks {
+			if l.OwnerID == actor.ID {
+				ours = append(ours, l)
+			} else {
+				theirs = append(theirs, l)
+			}
+		}
+
+		resp := verifyLocksResponse{
+			NextCursor: nextCursor,
+		}
+		resp.Ours, err = toLocks(c.Req.Context(), store, ours...)
+		if err == nil {
+			resp.Theirs, err = toLocks(c.Req.Context(), store, theirs...)
+		}
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to convert locks [repo_id: %d]: %v", repo.ID, err)
+			return
+		}
+		responseJSON(c.Resp, http.StatusOK, resp)
+	}
+}
+
+// POST /{owner}/{repo}.git/info/lfs/locks/{id}/unlock
+func serveUnlock(store Store) macaron.Handler {
+	return func(c *macaron.Context, actor authenticatedUser, repo *database.Repository) {
+		var request unlockRequest
+		if !decodeLockRequest(c, &request) {
+			return
+		}
+
+		id, _ := strconv.ParseInt(c.Params(":id"), 10, 64)
+		existing, err := store.GetLFSLockByID(c.Req.Context(), repo.ID, id)
+		if err != nil {
+			if database.IsErrLFSLockNotExist(err) {
+				responseJSON(c.Resp, http.StatusNotFound, responseError{
+					Message: "Lock does not exist",
+				})
+			} else {
+				internalServerError(c.Resp)
+				log.Error("Failed to get lock [repo_id: %d, id: %d]: %v", repo.ID, id, err)
+			}
+			return
+		}
+
+		if message := checkUnlock(c.Req.Context(), store, actor.User, repo, existing, request.Force); message != "" {
+			responseJSON(c.Resp, http.StatusForbidden, responseError{
+				Message: message,
+			})
+			return
+		}
+
+		locks, err := toLocks(c.Req.Context(), store, existing)
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to convert lock [id: %d]: %v", existing.ID, err)
+			return
+		}
+
+		err = store.DeleteLFSLockByID(c.Req.Context(), repo.ID, existing.ID)
+		if err != nil {
+			internalServerError(c.Resp)
+			log.Error("Failed to delete lock [repo_id: %d, id: %d]: %v", repo.ID, existing.ID, err)
+			return
+		}
+
+		log.Trace("[LFS] User %q unlocked %q [repo_id: %d, force: %v]", actor.Name, existing.Path, repo.ID, request.Force)
+		responseJSON(c.Resp, http.StatusOK, unlockResponse{
+			Lock: locks[0],
+		})
+	}
+}
+
+// checkUnlock returns the reason why the actor is not allowed to delete the
+// lock, or an empty string if it is allowed. Only repository administrators
+// can force deleting locks owned by other users.
+func checkUnlock(ctx context.Context, store Store, actor *database.User, repo *database.Repository, l *database.LFSLock, force bool) string {
+	if l.OwnerID == actor.ID {
+		return ""
+	} else if !force {
+		return "Lock is owned by another user"
+	}
+
+	if !store.AuthorizeRepositoryAccess(ctx, actor.ID, repo.ID, database.AccessModeAdmin,
+		database.AccessModeOptions{
+			OwnerID: repo.OwnerID,
+			Private: repo.IsPrivate,
+		},
+	) {
+		return "Admin access is required to force unlock a lock owned by another user"
+	}
+	return ""
+}
+
+// decodeLockRequest decodes the request body into v. An empty body is allowed
+// as all fields of lock requests are optional. It returns false and writes the
+// response when the body is malformed.
+func decodeLockRequest(c *macaron.Context, v any) bool {
+	defer func() { _ = c.Req.Request.Body.Close() }()
+	err := json.NewDecoder(c.Req.Request.Body).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		responseJSON(c.Resp, http.StatusBadRequest, responseError{
+			Message: strx.ToUpperFirst(err.Error()),
+		})
+		return false
+	}
+	return true
+}
+
+// parseLocksPage parses the pagination parameters of listing and verifying
+// locks. It returns the reason when any of them is invalid.
+func parseLocksPage(cursor, limit string) (opts database.ListLFSLocksOptions, message string) {
+	opts.Limit = maxLocksPerPage
+	if cursor != "" {
+		var err error
+		opts.Cursor, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || opts.Cursor <= 0 {
+			return opts, "Invalid cursor"
+		}
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return opts, "Invalid limit"
+		}
+		opts.Limit = min(n, maxLocksPerPage)
+	}
+	return opts, ""
+}
+
+// listLocks returns a page of locks that satisfy given options, and the cursor
+// of the next page if there is one.
+func listLocks(ctx context.Context, store Store, repoID int64, opts database.ListLFSLocksOptions) ([]*database.LFSLock, string, error) {
+	// Fetch one more lock to know whether there is a next page.
+	limit := opts.Limit
+	opts.Limit++
+	locks, err := store.ListLFSLocks(ctx, repoID, opts)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "list locks")
+	}
+
+	var nextCursor string
+	if len(locks) > limit {
+		nextCursor = strconv.FormatInt(locks[limit].ID, 10)
+		locks = locks[:limit]
+	}
+	return locks, nextCursor, nil
+}
+
+// toLocks converts locks to their API representations, along with their
+// owners.
+func toLocks(ctx context.Context, store Store, locks ...*database.LFSLock) ([]*lock, error) {
+	owners := make(map[int64]*database.User)
+	converted := make([]*lock, 0, len(locks))
+	for _, l := range locks {
+		owner, ok := owners[l.OwnerID]
+		if !ok {
+			var err error
+			owner, err = store.GetUserByID(ctx, l.OwnerID)
+			if err != nil {
+				if !database.IsErrUserNotExist(err) {
+					return nil, errors.Wrapf(err, "get user %d", l.OwnerID)
+				}
+				owner = database.NewGhostUser()
+			}
+			owners[l.OwnerID] = owner
+		}
+		converted = append(converted, toLock(l, owner))
+	}
+	return converted, nil
+}
+
+func toLock(l *database.LFSLock, owner *database.User) *lock {
+	return &lock{
+		ID:       strconv.FormatInt(l.ID, 10),
+		Path:     l.Path,
+		LockedAt: l.CreatedAt.UTC().Format(time.RFC3339),
+		Owner: lockOwner{
+			Name: owner.Name,
+		},
+	}
+}
+
+type lockRef struct {
+	Name string `json:"name"`
+}
+
+// createLockRequest defines the request payload for creating a lock.
+type createLockRequest struct {
+	Path string   `json:"path"`
+	Ref  *lockRef `json:"ref,omitempty"`
+}
+
+type lockOwner struct {
+	Name string `json:"name"`
+}
+
+type lock struct {
+	ID       string    `json:"id"`
+	Path     string    `json:"path"`
+	LockedAt string    `json:"locked_at"`
+	Owner    lockOwner `json:"owner"`
+}
+
+// createLockResponse defines the response payload for creating a lock.
+type createLockResponse struct {
+	Lock    *lock  `json:"lock"`
+	Message string `json:"message,omitempty"`
+}
+
+// listLocksResponse defines the response payload for listing locks.
+type listLocksResponse struct {
+	Locks      []*lock `json:"locks"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// verifyLocksRequest defines the request payload for verifying locks.
+type verifyLocksRequest struct {
+	Cursor string   `json:"cursor"`
+	Limit  int      `json:"limit"`
+	Ref    *lockRef `json:"ref,omitempty"`
+}
+
+// verifyLocksResponse defines the response payload for verifying locks.
+type verifyLocksResponse struct {
+	Ours       []*lock `json:"ours"`
+	Theirs     []*lock `json:"theirs"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// unlockRequest defines the request payload for deleting a lock.
+type unlockRequest struct {
+	Force bool     `json:"force"`
+	Ref   *lockRef `json:"ref,omitempty"`
+}
+
+// unlockResponse defines the response payload for deleting a lock.
+type unlockResponse struct {
+	Lock *lock `json:"lock"`
+}
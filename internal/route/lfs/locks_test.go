@@ -0,0 +1,314 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/macaron.v1"
+
+	"gogs.io/gogs/internal/database"
+)
+
+func newLockTestStore() *MockStore {
+	mockStore := NewMockStore()
+	mockStore.GetUserByIDFunc.SetDefaultHook(func(_ context.Context, id int64) (*database.User, error) {
+		switch id {
+		case 1:
+			return &database.User{ID: 1, Name: "alice"}, nil
+		case 2:
+			return &database.User{ID: 2, Name: "bob"}, nil
+		}
+		return nil, database.ErrUserNotExist{}
+	})
+	return mockStore
+}
+
+func serveLockTest(t *testing.T, method, pattern, url, body string, h macaron.Handler) (int, string) {
+	t.Helper()
+
+	m := macaron.New()
+	m.Use(func(c *macaron.Context) {
+		c.Map(authenticatedUser{User: &database.User{ID: 1, Name: "alice"}})
+		c.Map(&database.Repository{ID: 1, Name: "repo"})
+	})
+	m.Route(pattern, method, h)
+
+	r, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, r)
+
+	resp := rr.Result()
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var gotBody bytes.Buffer
+	err = json.Indent(&gotBody, bytes.TrimSpace(got), "", "  ")
+	require.NoError(t, err)
+	return resp.StatusCode, gotBody.String()
+}
+
+func indentJSON(t *testing.T, s string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := json.Indent(&buf, []byte(s), "", "  ")
+	require.NoError(t, err)
+	return buf.String()
+}
+
+var lockCreatedAt = time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+func TestServeCreateLock(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		mockStore     func() *MockStore
+		expStatusCode int
+		expBody       string
+	}{
+		{
+			name:          "empty path",
+			body:          `{"path": "/"}`,
+			expStatusCode: http.StatusUnprocessableEntity,
+			expBody:       `{"message": "Path is required"}`,
+		},
+		{
+			name: "already locked",
+			body: `{"path": "images/logo.png"}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.CreateLFSLockFunc.SetDefaultReturn(nil, database.ErrLFSLockAlreadyExist{})
+				mockStore.GetLFSLockByPathFunc.SetDefaultReturn(&database.LFSLock{ID: 7, OwnerID: 2, Path: "images/logo.png", CreatedAt: lockCreatedAt}, nil)
+				return mockStore
+			},
+			expStatusCode: http.StatusConflict,
+			expBody: `{
+	"lock": {"id": "7", "path": "images/logo.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "bob"}},
+	"message": "Lock already exists"
+}`,
+		},
+		{
+			name: "success",
+			body: `{"path": "./images/logo.png", "ref": {"name": "refs/heads/main"}}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.CreateLFSLockFunc.SetDefaultHook(func(_ context.Context, repoID, ownerID int64, path string) (*database.LFSLock, error) {
+					return &database.LFSLock{ID: 8, RepoID: repoID, OwnerID: ownerID, Path: path, CreatedAt: lockCreatedAt}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusCreated,
+			expBody: `{
+	"lock": {"id": "8", "path": "images/logo.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "alice"}}
+}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := newLockTestStore()
+			if test.mockStore != nil {
+				mockStore = test.mockStore()
+			}
+
+			statusCode, body := serveLockTest(t, http.MethodPost, "/locks", "/locks", test.body, serveCreateLock(mockStore))
+			assert.Equal(t, test.expStatusCode, statusCode)
+			assert.Equal(t, indentJSON(t, test.expBody), body)
+		})
+	}
+}
+
+func TestServeListLocks(t *testing.T) {
+	locks := []*database.LFSLock{
+		{ID: 1, OwnerID: 1, Path: "a.png", CreatedAt: lockCreatedAt},
+		{ID: 2, OwnerID: 3, Path: "b.png", CreatedAt: lockCreatedAt},
+		{ID: 3, OwnerID: 2, Path: "c.png", CreatedAt: lockCreatedAt},
+	}
+
+	tests := []struct {
+		name          string
+		url           string
+		expOptions    *database.ListLFSLocksOptions
+		expStatusCode int
+		expBody       string
+	}{
+		{
+			name:          "invalid cursor",
+			url:           "/locks?cursor=abc",
+			expStatusCode: http.StatusBadRequest,
+			expBody:       `{"message": "Invalid cursor"}`,
+		},
+		{
+			name:          "invalid limit",
+			url:           "/locks?limit=-1",
+			expStatusCode: http.StatusBadRequest,
+			expBody:       `{"message": "Invalid limit"}`,
+		},
+		{
+			name:          "invalid id",
+			url:           "/locks?id=abc",
+			expStatusCode: http.StatusOK,
+			expBody:       `{"locks": []}`,
+		},
+		{
+			name:          "next page",
+			url:           "/locks?path=a.png&limit=2&cursor=1",
+			expOptions:    &database.ListLFSLocksOptions{Path: "a.png", Cursor: 1, Limit: 3},
+			expStatusCode: http.StatusOK,
+			expBody: `{
+	"locks": [
+		{"id": "1", "path": "a.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "alice"}},
+		{"id": "2", "path": "b.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "Ghost"}}
+	],
+	"next_cursor": "3"
+}`,
+		},
+		{
+			name:          "last page",
+			url:           "/locks?limit=1000",
+			expOptions:    &database.ListLFSLocksOptions{Limit: maxLocksPerPage + 1},
+			expStatusCode: http.StatusOK,
+			expBody: `{
+	"locks": [
+		{"id": "1", "path": "a.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "alice"}},
+		{"id": "2", "path": "b.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "Ghost"}},
+		{"id": "3", "path": "c.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "bob"}}
+	]
+}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := newLockTestStore()
+			mockStore.ListLFSLocksFunc.SetDefaultHook(func(_ context.Context, _ int64, opts database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+				return locks[:min(opts.Limit, len(locks))], nil
+			})
+
+			statusCode, body := serveLockTest(t, http.MethodGet, "/locks", test.url, "", serveListLocks(mockStore))
+			assert.Equal(t, test.expStatusCode, statusCode)
+			assert.Equal(t, indentJSON(t, test.expBody), body)
+
+			if test.expOptions != nil {
+				calls := mockStore.ListLFSLocksFunc.History()
+				require.Len(t, calls, 1)
+				assert.Equal(t, *test.expOptions, calls[0].Arg2)
+			}
+		})
+	}
+}
+
+func TestServeVerifyLocks(t *testing.T) {
+	mockStore := newLockTestStore()
+	mockStore.ListLFSLocksFunc.SetDefaultReturn(
+		[]*database.LFSLock{
+			{ID: 1, OwnerID: 1, Path: "a.png", CreatedAt: lockCreatedAt},
+			{ID: 2, OwnerID: 2, Path: "b.png", CreatedAt: lockCreatedAt},
+		},
+		nil,
+	)
+
+	statusCode, body := serveLockTest(t, http.MethodPost, "/locks/verify", "/locks/verify", `{"ref": {"name": "refs/heads/main"}}`, serveVerifyLocks(mockStore))
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, indentJSON(t, `{
+	"ours": [{"id": "1", "path": "a.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "alice"}}],
+	"theirs": [{"id": "2", "path": "b.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "bob"}}]
+}`), body)
+}
+
+func TestServeUnlock(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		mockStore     func() *MockStore
+		expStatusCode int
+		expBody       string
+		expDeleted    bool
+	}{
+		{
+			name: "not found",
+			body: `{}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(nil, database.ErrLFSLockNotExist{})
+				return mockStore
+			},
+			expStatusCode: http.StatusNotFound,
+			expBody:       `{"message": "Lock does not exist"}`,
+		},
+		{
+			name: "owned by another user",
+			body: `{"force": false}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				return mockStore
+			},
+			expStatusCode: http.StatusForbidden,
+			expBody:       `{"message": "Lock is owned by another user"}`,
+		},
+		{
+			name: "force without admin access",
+			body: `{"force": true}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(false)
+				return mockStore
+			},
+			expStatusCode: http.StatusForbidden,
+			expBody:       `{"message": "Admin access is required to force unlock a lock owned by another user"}`,
+		},
+		{
+			name: "force with admin access",
+			body: `{"force": true}`,
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultHook(func(_ context.Context, _, _ int64, desired database.AccessMode, _ database.AccessModeOptions) bool {
+					return desired == database.AccessModeAdmin
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusOK,
+			expBody:       `{"lock": {"id": "1", "path": "a.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "bob"}}}`,
+			expDeleted:    true,
+		},
+		{
+			name: "owned by self",
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 1, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				return mockStore
+			},
+			expStatusCode: http.StatusOK,
+			expBody:       `{"lock": {"id": "1", "path": "a.png", "locked_at": "2026-10-01T08:00:00Z", "owner": {"name": "alice"}}}`,
+			expDeleted:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := test.mockStore()
+
+			statusCode, body := serveLockTest(t, http.MethodPost, "/locks/:id/unlock", "/locks/1/unlock", test.body, serveUnlock(mockStore))
+			assert.Equal(t, test.expStatusCode, statusCode)
+			assert.Equal(t, indentJSON(t, test.expBody), body)
+
+			calls := mockStore.DeleteLFSLockByIDFunc.History()
+			if test.expDeleted {
+				require.Len(t, calls, 1)
+				assert.Equal(t, int64(1), calls[0].Arg2)
+			} else {
+				assert.Empty(t, calls)
+			}
+		})
+	}
+}
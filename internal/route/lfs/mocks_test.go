@@ -24,15 +24,27 @@ type MockStore struct {
 	// object controlling the behavior of the method
 	// AuthorizeRepositoryAccess.
 	AuthorizeRepositoryAccessFunc *StoreAuthorizeRepositoryAccessFunc
+	// CreateLFSLockFunc is an instance of a mock function object
+	// controlling the behavior of the method CreateLFSLock.
+	CreateLFSLockFunc *StoreCreateLFSLockFunc
 	// CreateLFSObjectFunc is an instance of a mock function object
 	// controlling the behavior of the method CreateLFSObject.
 	CreateLFSObjectFunc *StoreCreateLFSObjectFunc
 	// CreateUserFunc is an instance of a mock function object controlling
 	// the behavior of the method CreateUser.
 	CreateUserFunc *StoreCreateUserFunc
+	// DeleteLFSLockByIDFunc is an instance of a mock function object
+	// controlling the behavior of the method DeleteLFSLockByID.
+	DeleteLFSLockByIDFunc *StoreDeleteLFSLockByIDFunc
 	// GetAccessTokenBySHA1Func is an instance of a mock function object
 	// controlling the behavior of the method GetAccessTokenBySHA1.
 	GetAccessTokenBySHA1Func *StoreGetAccessTokenBySHA1Func
+	// GetLFSLockByIDFunc is an instance of a mock function object
+	// controlling the behavior of the method GetLFSLockByID.
+	GetLFSLockByIDFunc *StoreGetLFSLockByIDFunc
+	// GetLFSLockByPathFunc is an instance of a mock function object
+	// controlling the behavior of the method GetLFSLockByPath.
+	GetLFSLockByPathFunc *StoreGetLFSLockByPathFunc
 	// GetLFSObjectByOIDFunc is an instance of a mock function object
 	// controlling the behavior of the method GetLFSObjectByOID.
 	GetLFSObjectByOIDFunc *StoreGetLFSObjectByOIDFunc
@@ -51,6 +63,9 @@ type MockStore struct {
 	// IsTwoFactorEnabledFunc is an instance of a mock function object
 	// controlling the behavior of the method IsTwoFactorEnabled.
 	IsTwoFactorEnabledFunc *StoreIsTwoFactorEnabledFunc
+	// ListLFSLocksFunc is an instance of a mock function object controlling
+	// the behavior of the method ListLFSLocks.
+	ListLFSLocksFunc *StoreListLFSLocksFunc
 	// TouchAccessTokenByIDFunc is an instance of a mock function object
 	// controlling the behavior of the method TouchAccessTokenByID.
 	TouchAccessTokenByIDFunc *StoreTouchAccessTokenByIDFunc
@@ -70,6 +85,11 @@ func NewMockStore() *MockStore {
 				return
 			},
 		},
+		CreateLFSLockFunc: &StoreCreateLFSLockFunc{
+			defaultHook: func(context.Context, int64, int64, string) (r0 *database.LFSLock, r1 error) {
+				return
+			},
+		},
 		CreateLFSObjectFunc: &StoreCreateLFSObjectFunc{
 			defaultHook: func(context.Context, int64, lfsx.OID, int64, lfsx.Storage) (r0 error) {
 				return
@@ -80,11 +100,26 @@ func NewMockStore() *MockStore {
 				return
 			},
 		},
+		DeleteLFSLockByIDFunc: &StoreDeleteLFSLockByIDFunc{
+			defaultHook: func(context.Context, int64, int64) (r0 error) {
+				return
+			},
+		},
 		GetAccessTokenBySHA1Func: &StoreGetAccessTokenBySHA1Func{
 			defaultHook: func(context.Context, string) (r0 *database.AccessToken, r1 error) {
 				return
 			},
 		},
+		GetLFSLockByIDFunc: &StoreGetLFSLockByIDFunc{
+			defaultHook: func(context.Context, int64, int64) (r0 *database.LFSLock, r1 error) {
+				return
+			},
+		},
+		GetLFSLockByPathFunc: &StoreGetLFSLockByPathFunc{
+			defaultHook: func(context.Context, int64, string) (r0 *database.LFSLock, r1 error) {
+				return
+			},
+		},
 		GetLFSObjectByOIDFunc: &StoreGetLFSObjectByOIDFunc{
 			defaultHook: func(context.Context, int64, lfsx.OID) (r0 *database.LFSObject, r1 error) {
 				return
@@ -115,6 +150,11 @@ func NewMockStore() *MockStore {
 				return
 			},
 		},
+		ListLFSLocksFunc: &StoreListLFSLocksFunc{
+			defaultHook: func(context.Context, int64, database.ListLFSLocksOptions) (r0 []*database.LFSLock, r1 error) {
+				return
+			},
+		},
 		TouchAccessTokenByIDFunc: &StoreTouchAccessTokenByIDFunc{
 			defaultHook: func(context.Context, int64) (r0 error) {
 				return
@@ -137,6 +177,11 @@ func NewStrictMockStore() *MockStore {
 				panic("unexpected invocation of MockStore.AuthorizeRepositoryAccess")
 			},
 		},
+		CreateLFSLockFunc: &StoreCreateLFSLockFunc{
+			defaultHook: func(context.Context, int64, int64, string) (*database.LFSLock, error) {
+				panic("unexpected invocation of MockStore.CreateLFSLock")
+			},
+		},
 		CreateLFSObjectFunc: &StoreCreateLFSObjectFunc{
 			defaultHook: func(context.Context, int64, lfsx.OID, int64, lfsx.Storage) error {
 				panic("unexpected invocation of MockStore.CreateLFSObject")
@@ -147,11 +192,26 @@ func NewStrictMockStore() *MockStore {
 				panic("unexpected invocation of MockStore.CreateUser")
 			},
 		},
+		DeleteLFSLockByIDFunc: &StoreDeleteLFSLockByIDFunc{
+			defaultHook: func(context.Context, int64, int64) error {
+				panic("unexpected invocation of MockStore.DeleteLFSLockByID")
+			},
+		},
 		GetAccessTokenBySHA1Func: &StoreGetAccessTokenBySHA1Func{
 			defaultHook: func(context.Context, string) (*database.AccessToken, error) {
 				panic("unexpected invocation of MockStore.GetAccessTokenBySHA1")
 			},
 		},
+		GetLFSLockByIDFunc: &StoreGetLFSLockByIDFunc{
+			defaultHook: func(context.Context, int64, int64) (*database.LFSLock, error) {
+				panic("unexpected invocation of MockStore.GetLFSLockByID")
+			},
+		},
+		GetLFSLockByPathFunc: &StoreGetLFSLockByPathFunc{
+			defaultHook: func(context.Context, int64, string) (*database.LFSLock, error) {
+				panic("unexpected invocation of MockStore.GetLFSLockByPath")
+			},
+		},
 		GetLFSObjectByOIDFunc: &StoreGetLFSObjectByOIDFunc{
 			defaultHook: func(context.Context, int64, lfsx.OID) (*database.LFSObject, error) {
 				panic("unexpected invocation of MockStore.GetLFSObjectByOID")
@@ -182,6 +242,11 @@ func NewStrictMockStore() *MockStore {
 				panic("unexpected invocation of MockStore.IsTwoFactorEnabled")
 			},
 		},
+		ListLFSLocksFunc: &StoreListLFSLocksFunc{
+			defaultHook: func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+				panic("unexpected invocation of MockStore.ListLFSLocks")
+			},
+		},
 		TouchAccessTokenByIDFunc: &StoreTouchAccessTokenByIDFunc{
 			defaultHook: func(context.Context, int64) error {
 				panic("unexpected invocation of MockStore.TouchAccessTokenByID")
@@ -200,15 +265,27 @@ func NewMockStoreFrom(i Store) *MockStore {
 		AuthorizeRepositoryAccessFunc: &StoreAuthorizeRepositoryAccessFunc{
 			defaultHook: i.AuthorizeRepositoryAccess,
 		},
+		CreateLFSLockFunc: &StoreCreateLFSLockFunc{
+			defaultHook: i.CreateLFSLock,
+		},
 		CreateLFSObjectFunc: &StoreCreateLFSObjectFunc{
 			defaultHook: i.CreateLFSObject,
 		},
 		CreateUserFunc: &StoreCreateUserFunc{
 			defaultHook: i.CreateUser,
 		},
+		DeleteLFSLockByIDFunc: &StoreDeleteLFSLockByIDFunc{
+			defaultHook: i.DeleteLFSLockByID,
+		},
 		GetAccessTokenBySHA1Func: &StoreGetAccessTokenBySHA1Func{
 			defaultHook: i.GetAccessTokenBySHA1,
 		},
+		GetLFSLockByIDFunc: &StoreGetLFSLockByIDFunc{
+			defaultHook: i.GetLFSLockByID,
+		},
+		GetLFSLockByPathFunc: &StoreGetLFSLockByPathFunc{
+			defaultHook: i.GetLFSLockByPath,
+		},
 		GetLFSObjectByOIDFunc: &StoreGetLFSObjectByOIDFunc{
 			defaultHook: i.GetLFSObjectByOID,
 		},
@@ -227,6 +304,9 @@ func NewMockStoreFrom(i Store) *MockStore {
 		IsTwoFactorEnabledFunc: &StoreIsTwoFactorEnabledFunc{
 			defaultHook: i.IsTwoFactorEnabled,
 		},
+		ListLFSLocksFunc: &StoreListLFSLocksFunc{
+			defaultHook: i.ListLFSLocks,
+		},
 		TouchAccessTokenByIDFunc: &StoreTouchAccessTokenByIDFunc{
 			defaultHook: i.TouchAccessTokenByID,
 		},
@@ -463,6 +543,119 @@ func (c StoreAuthorizeRepositoryAccessFuncCall) Results() []interface{} {
 	return []interface{}{c.Result0}
 }
 
+// StoreCreateLFSLockFunc describes the behavior when the CreateLFSLock
+// method of the parent MockStore instance is invoked.
+type StoreCreateLFSLockFunc struct {
+	defaultHook func(context.Context, int64, int64, string) (*database.LFSLock, error)
+	hooks       []func(context.Context, int64, int64, string) (*database.LFSLock, error)
+	history     []StoreCreateLFSLockFuncCall
+	mutex       sync.Mutex
+}
+
+// CreateLFSLock delegates to the next hook function in the queue and stores
+// the parameter and result values of this invocation.
+func (m *MockStore) CreateLFSLock(v0 context.Context, v1 int64, v2 int64, v3 string) (*database.LFSLock, error) {
+	r0, r1 := m.CreateLFSLockFunc.nextHook()(v0, v1, v2, v3)
+	m.CreateLFSLockFunc.appendCall(StoreCreateLFSLockFuncCall{v0, v1, v2, v3, r0, r1})
+	return r0, r1
+}
+
+// SetDefaultHook sets function that is called when the CreateLFSLock method
+// of the parent MockStore instance is invoked and the hook queue is empty.
+func (f *StoreCreateLFSLockFunc) SetDefaultHook(hook func(context.Context, int64, int64, string) (*database.LFSLock, error)) {
+	f.defaultHook = hook
+}
+
+// PushHook adds a function to the end of hook queue. Each invocation of the
+// CreateLFSLock method of the parent MockStore instance invokes the hook at
+// the front of the queue and discards it. After the queue is empty, the
+// default hook function is invoked for any future action.
+func (f *StoreCreateLFSLockFunc) PushHook(hook func(context.Context, int64, int64, string) (*database.LFSLock, error)) {
+	f.mutex.Lock()
+	f.hooks = append(f.hooks, hook)
+	f.mutex.Unlock()
+}
+
+// SetDefaultReturn calls SetDefaultHook with a function that returns the
+// given values.
+func (f *StoreCreateLFSLockFunc) SetDefaultReturn(r0 *database.LFSLock, r1 error) {
+	f.SetDefaultHook(func(context.Context, int64, int64, string) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+// PushReturn calls PushHook with a function that returns the given values.
+func (f *StoreCreateLFSLockFunc) PushReturn(r0 *database.LFSLock, r1 error) {
+	f.PushHook(func(context.Context, int64, int64, string) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+func (f *StoreCreateLFSLockFunc) nextHook() func(context.Context, int64, int64, string) (*database.LFSLock, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.hooks) == 0 {
+		return f.defaultHook
+	}
+
+	hook := f.hooks[0]
+	f.hooks = f.hooks[1:]
+	return hook
+}
+
+func (f *StoreCreateLFSLockFunc) appendCall(r0 StoreCreateLFSLockFuncCall) {
+	f.mutex.Lock()
+	f.history = append(f.history, r0)
+	f.mutex.Unlock()
+}
+
+// History returns a sequence of StoreCreateLFSLockFuncCall objects
+// describing the invocations of this function.
+func (f *StoreCreateLFSLockFunc) History() []StoreCreateLFSLockFuncCall {
+	f.mutex.Lock()
+	history := make([]StoreCreateLFSLockFuncCall, len(f.history))
+	copy(history, f.history)
+	f.mutex.Unlock()
+
+	return history
+}
+
+// StoreCreateLFSLockFuncCall is an object that describes an invocation of
+// method CreateLFSLock on an instance of MockStore.
+type StoreCreateLFSLockFuncCall struct {
+	// Arg0 is the value of the 1st argument passed to this method
+	// invocation.
+	Arg0 context.Context
+	// Arg1 is the value of the 2nd argument passed to this method
+	// invocation.
+	Arg1 int64
+	// Arg2 is the value of the 3rd argument passed to this method
+	// invocation.
+	Arg2 int64
+	// Arg3 is the value of the 4th argument passed to this method
+	// invocation.
+	Arg3 string
+	// Result0 is the value of the 1st result returned from this method
+	// invocation.
+	Result0 *database.LFSLock
+	// Result1 is the value of the 2nd result returned from this method
+	// invocation.
+	Result1 error
+}
+
+// Args returns an interface slice containing the arguments of this
+// invocation.
+func (c StoreCreateLFSLockFuncCall) Args() []interface{} {
+	return []interface{}{c.Arg0, c.Arg1, c.Arg2, c.Arg3}
+}
+
+// Results returns an interface slice containing the results of this
+// invocation.
+func (c StoreCreateLFSLockFuncCall) Results() []interface{} {
+	return []interface{}{c.Result0, c.Result1}
+}
+
 // StoreCreateLFSObjectFunc describes the behavior when the CreateLFSObject
 // method of the parent MockStore instance is invoked.
 type StoreCreateLFSObjectFunc struct {
@@ -690,6 +883,114 @@ func (c StoreCreateUserFuncCall) Results() []interface{} {
 	return []interface{}{c.Result0, c.Result1}
 }
 
+// StoreDeleteLFSLockByIDFunc describes the behavior when the
+// DeleteLFSLockByID method of the parent MockStore instance is invoked.
+type StoreDeleteLFSLockByIDFunc struct {
+	defaultHook func(context.Context, int64, int64) error
+	hooks       []func(context.Context, int64, int64) error
+	history     []StoreDeleteLFSLockByIDFuncCall
+	mutex       sync.Mutex
+}
+
+// DeleteLFSLockByID delegates to the next hook function in the queue and
+// stores the parameter and result values of this invocation.
+func (m *MockStore) DeleteLFSLockByID(v0 context.Context, v1 int64, v2 int64) error {
+	r0 := m.DeleteLFSLockByIDFunc.nextHook()(v0, v1, v2)
+	m.DeleteLFSLockByIDFunc.appendCall(StoreDeleteLFSLockByIDFuncCall{v0, v1, v2, r0})
+	return r0
+}
+
+// SetDefaultHook sets function that is called when the DeleteLFSLockByID
+// method of the parent MockStore instance is invoked and the hook queue is
+// empty.
+func (f *StoreDeleteLFSLockByIDFunc) SetDefaultHook(hook func(context.Context, int64, int64) error) {
+	f.defaultHook = hook
+}
+
+// PushHook adds a function to the end of hook queue. Each invocation of the
+// DeleteLFSLockByID method of the parent MockStore instance invokes the
+// hook at the front of the queue and discards it. After the queue is empty,
+// the default hook function is invoked for any future action.
+func (f *StoreDeleteLFSLockByIDFunc) PushHook(hook func(context.Context, int64, int64) error) {
+	f.mutex.Lock()
+	f.hooks = append(f.hooks, hook)
+	f.mutex.Unlock()
+}
+
+// SetDefaultReturn calls SetDefaultHook with a function that returns the
+// given values.
+func (f *StoreDeleteLFSLockByIDFunc) SetDefaultReturn(r0 error) {
+	f.SetDefaultHook(func(context.Context, int64, int64) error {
+		return r0
+	})
+}
+
+// PushReturn calls PushHook with a function that returns the given values.
+func (f *StoreDeleteLFSLockByIDFunc) PushReturn(r0 error) {
+	f.PushHook(func(context.Context, int64, int64) error {
+		return r0
+	})
+}
+
+func (f *StoreDeleteLFSLockByIDFunc) nextHook() func(context.Context, int64, int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.hooks) == 0 {
+		return f.defaultHook
+	}
+
+	hook := f.hooks[0]
+	f.hooks = f.hooks[1:]
+	return hook
+}
+
+func (f *StoreDeleteLFSLockByIDFunc) appendCall(r0 StoreDeleteLFSLockByIDFuncCall) {
+	f.mutex.Lock()
+	f.history = append(f.history, r0)
+	f.mutex.Unlock()
+}
+
+// History returns a sequence of StoreDeleteLFSLockByIDFuncCall objects
+// describing the invocations of this function.
+func (f *StoreDeleteLFSLockByIDFunc) History() []StoreDeleteLFSLockByIDFuncCall {
+	f.mutex.Lock()
+	history := make([]StoreDeleteLFSLockByIDFuncCall, len(f.history))
+	copy(history, f.history)
+	f.mutex.Unlock()
+
+	return history
+}
+
+// StoreDeleteLFSLockByIDFuncCall is an object that describes an invocation
+// of method DeleteLFSLockByID on an instance of MockStore.
+type StoreDeleteLFSLockByIDFuncCall struct {
+	// Arg0 is the value of the 1st argument passed to this method
+	// invocation.
+	Arg0 context.Context
+	// Arg1 is the value of the 2nd argument passed to this method
+	// invocation.
+	Arg1 int64
+	// Arg2 is the value of the 3rd argument passed to this method
+	// invocation.
+	Arg2 int64
+	// Result0 is the value of the 1st result returned from this method
+	// invocation.
+	Result0 error
+}
+
+// Args returns an interface slice containing the arguments of this
+// invocation.
+func (c StoreDeleteLFSLockByIDFuncCall) Args() []interface{} {
+	return []interface{}{c.Arg0, c.Arg1, c.Arg2}
+}
+
+// Results returns an interface slice containing the results of this
+// invocation.
+func (c StoreDeleteLFSLockByIDFuncCall) Results() []interface{} {
+	return []interface{}{c.Result0}
+}
+
 // StoreGetAccessTokenBySHA1Func describes the behavior when the
 // GetAccessTokenBySHA1 method of the parent MockStore instance is invoked.
 type StoreGetAccessTokenBySHA1Func struct {
@@ -798,6 +1099,228 @@ func (c StoreGetAccessTokenBySHA1FuncCall) Results() []interface{} {
 	return []interface{}{c.Result0, c.Result1}
 }
 
+// StoreGetLFSLockByIDFunc describes the behavior when the GetLFSLockByID
+// method of the parent MockStore instance is invoked.
+type StoreGetLFSLockByIDFunc struct {
+	defaultHook func(context.Context, int64, int64) (*database.LFSLock, error)
+	hooks       []func(context.Context, int64, int64) (*database.LFSLock, error)
+	history     []StoreGetLFSLockByIDFuncCall
+	mutex       sync.Mutex
+}
+
+// GetLFSLockByID delegates to the next hook function in the queue and
+// stores the parameter and result values of this invocation.
+func (m *MockStore) GetLFSLockByID(v0 context.Context, v1 int64, v2 int64) (*database.LFSLock, error) {
+	r0, r1 := m.GetLFSLockByIDFunc.nextHook()(v0, v1, v2)
+	m.GetLFSLockByIDFunc.appendCall(StoreGetLFSLockByIDFuncCall{v0, v1, v2, r0, r1})
+	return r0, r1
+}
+
+// SetDefaultHook sets function that is called when the GetLFSLockByID
+// method of the parent MockStore instance is invoked and the hook queue is
+// empty.
+func (f *StoreGetLFSLockByIDFunc) SetDefaultHook(hook func(context.Context, int64, int64) (*database.LFSLock, error)) {
+	f.defaultHook = hook
+}
+
+// PushHook adds a function to the end of hook queue. Each invocation of the
+// GetLFSLockByID method of the parent MockStore instance invokes the hook
+// at the front of the queue and discards it. After the queue is empty, the
+// default hook function is invoked for any future action.
+func (f *StoreGetLFSLockByIDFunc) PushHook(hook func(context.Context, int64, int64) (*database.LFSLock, error)) {
+	f.mutex.Lock()
+	f.hooks = append(f.hooks, hook)
+	f.mutex.Unlock()
+}
+
+// SetDefaultReturn calls SetDefaultHook with a function that returns the
+// given values.
+func (f *StoreGetLFSLockByIDFunc) SetDefaultReturn(r0 *database.LFSLock, r1 error) {
+	f.SetDefaultHook(func(context.Context, int64, int64) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+// PushReturn calls PushHook with a function that returns the given values.
+func (f *StoreGetLFSLockByIDFunc) PushReturn(r0 *database.LFSLock, r1 error) {
+	f.PushHook(func(context.Context, int64, int64) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+func (f *StoreGetLFSLockByIDFunc) nextHook() func(context.Context, int64, int64) (*database.LFSLock, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.hooks) == 0 {
+		return f.defaultHook
+	}
+
+	hook := f.hooks[0]
+	f.hooks = f.hooks[1:]
+	return hook
+}
+
+func (f *StoreGetLFSLockByIDFunc) appendCall(r0 StoreGetLFSLockByIDFuncCall) {
+	f.mutex.Lock()
+	f.history = append(f.history, r0)
+	f.mutex.Unlock()
+}
+
+// History returns a sequence of StoreGetLFSLockByIDFuncCall objects
+// describing the invocations of this function.
+func (f *StoreGetLFSLockByIDFunc) History() []StoreGetLFSLockByIDFuncCall {
+	f.mutex.Lock()
+	history := make([]StoreGetLFSLockByIDFuncCall, len(f.history))
+	copy(history, f.history)
+	f.mutex.Unlock()
+
+	return history
+}
+
+// StoreGetLFSLockByIDFuncCall is an object that describes an invocation of
+// method GetLFSLockByID on an instance of MockStore.
+type StoreGetLFSLockByIDFuncCall struct {
+	// Arg0 is the value of the 1st argument passed to this method
+	// invocation.
+	Arg0 context.Context
+	// Arg1 is the value of the 2nd argument passed to this method
+	// invocation.
+	Arg1 int64
+	// Arg2 is the value of the 3rd argument passed to this method
+	// invocation.
+	Arg2 int64
+	// Result0 is the value of the 1st result returned from this method
+	// invocation.
+	Result0 *database.LFSLock
+	// Result1 is the value of the 2nd result returned from this method
+	// invocation.
+	Result1 error
+}
+
+// Args returns an interface slice containing the arguments of this
+// invocation.
+func (c StoreGetLFSLockByIDFuncCall) Args() []interface{} {
+	return []interface{}{c.Arg0, c.Arg1, c.Arg2}
+}
+
+// Results returns an interface slice containing the results of this
+// invocation.
+func (c StoreGetLFSLockByIDFuncCall) Results() []interface{} {
+	return []interface{}{c.Result0, c.Result1}
+}
+
+// StoreGetLFSLockByPathFunc describes the behavior when the
+// GetLFSLockByPath method of the parent MockStore instance is invoked.
+type StoreGetLFSLockByPathFunc struct {
+	defaultHook func(context.Context, int64, string) (*database.LFSLock, error)
+	hooks       []func(context.Context, int64, string) (*database.LFSLock, error)
+	history     []StoreGetLFSLockByPathFuncCall
+	mutex       sync.Mutex
+}
+
+// GetLFSLockByPath delegates to the next hook function in the queue and
+// stores the parameter and result values of this invocation.
+func (m *MockStore) GetLFSLockByPath(v0 context.Context, v1 int64, v2 string) (*database.LFSLock, error) {
+	r0, r1 := m.GetLFSLockByPathFunc.nextHook()(v0, v1, v2)
+	m.GetLFSLockByPathFunc.appendCall(StoreGetLFSLockByPathFuncCall{v0, v1, v2, r0, r1})
+	return r0, r1
+}
+
+// SetDefaultHook sets function that is called when the GetLFSLockByPath
+// method of the parent MockStore instance is invoked and the hook queue is
+// empty.
+func (f *StoreGetLFSLockByPathFunc) SetDefaultHook(hook func(context.Context, int64, string) (*database.LFSLock, error)) {
+	f.defaultHook = hook
+}
+
+// PushHook adds a function to the end of hook queue. Each invocation of the
+// GetLFSLockByPath method of the parent MockStore instance invokes the hook
+// at the front of the queue and discards it. After the queue is empty, the
+// default hook function is invoked for any future action.
+func (f *StoreGetLFSLockByPathFunc) PushHook(hook func(context.Context, int64, string) (*database.LFSLock, error)) {
+	f.mutex.Lock()
+	f.hooks = append(f.hooks, hook)
+	f.mutex.Unlock()
+}
+
+// SetDefaultReturn calls SetDefaultHook with a function that returns the
+// given values.
+func (f *StoreGetLFSLockByPathFunc) SetDefaultReturn(r0 *database.LFSLock, r1 error) {
+	f.SetDefaultHook(func(context.Context, int64, string) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+// PushReturn calls PushHook with a function that returns the given values.
+func (f *StoreGetLFSLockByPathFunc) PushReturn(r0 *database.LFSLock, r1 error) {
+	f.PushHook(func(context.Context, int64, string) (*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+func (f *StoreGetLFSLockByPathFunc) nextHook() func(context.Context, int64, string) (*database.LFSLock, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.hooks) == 0 {
+		return f.defaultHook
+	}
+
+	hook := f.hooks[0]
+	f.hooks = f.hooks[1:]
+	return hook
+}
+
+func (f *StoreGetLFSLockByPathFunc) appendCall(r0 StoreGetLFSLockByPathFuncCall) {
+	f.mutex.Lock()
+	f.history = append(f.history, r0)
+	f.mutex.Unlock()
+}
+
+// History returns a sequence of StoreGetLFSLockByPathFuncCall objects
+// describing the invocations of this function.
+func (f *StoreGetLFSLockByPathFunc) History() []StoreGetLFSLockByPathFuncCall {
+	f.mutex.Lock()
+	history := make([]StoreGetLFSLockByPathFuncCall, len(f.history))
+	copy(history, f.history)
+	f.mutex.Unlock()
+
+	return history
+}
+
+// StoreGetLFSLockByPathFuncCall is an object that describes an invocation
+// of method GetLFSLockByPath on an instance of MockStore.
+type StoreGetLFSLockByPathFuncCall struct {
+	// Arg0 is the value of the 1st argument passed to this method
+	// invocation.
+	Arg0 context.Context
+	// Arg1 is the value of the 2nd argument passed to this method
+	// invocation.
+	Arg1 int64
+	// Arg2 is the value of the 3rd argument passed to this method
+	// invocation.
+	Arg2 string
+	// Result0 is the value of the 1st result returned from this method
+	// invocation.
+	Result0 *database.LFSLock
+	// Result1 is the value of the 2nd result returned from this method
+	// invocation.
+	Result1 error
+}
+
+// Args returns an interface slice containing the arguments of this
+// invocation.
+func (c StoreGetLFSLockByPathFuncCall) Args() []interface{} {
+	return []interface{}{c.Arg0, c.Arg1, c.Arg2}
+}
+
+// Results returns an interface slice containing the results of this
+// invocation.
+func (c StoreGetLFSLockByPathFuncCall) Results() []interface{} {
+	return []interface{}{c.Result0, c.Result1}
+}
+
 // StoreGetLFSObjectByOIDFunc describes the behavior when the
 // GetLFSObjectByOID method of the parent MockStore instance is invoked.
 type StoreGetLFSObjectByOIDFunc struct {
@@ -1458,6 +1981,116 @@ func (c StoreIsTwoFactorEnabledFuncCall) Results() []interface{} {
 	return []interface{}{c.Result0}
 }
 
+// StoreListLFSLocksFunc describes the behavior when the ListLFSLocks method
+// of the parent MockStore instance is invoked.
+type StoreListLFSLocksFunc struct {
+	defaultHook func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error)
+	hooks       []func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error)
+	history     []StoreListLFSLocksFuncCall
+	mutex       sync.Mutex
+}
+
+// ListLFSLocks delegates to the next hook function in the queue and stores
+// the parameter and result values of this invocation.
+func (m *MockStore) ListLFSLocks(v0 context.Context, v1 int64, v2 database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+	r0, r1 := m.ListLFSLocksFunc.nextHook()(v0, v1, v2)
+	m.ListLFSLocksFunc.appendCall(StoreListLFSLocksFuncCall{v0, v1, v2, r0, r1})
+	return r0, r1
+}
+
+// SetDefaultHook sets function that is called when the ListLFSLocks method
+// of the parent MockStore instance is invoked and the hook queue is empty.
+func (f *StoreListLFSLocksFunc) SetDefaultHook(hook func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error)) {
+	f.defaultHook = hook
+}
+
+// PushHook adds a function to the end of hook queue. Each invocation of the
+// ListLFSLocks method of the parent MockStore instance invokes the hook at
+// the front of the queue and discards it. After the queue is empty, the
+// default hook function is invoked for any future action.
+func (f *StoreListLFSLocksFunc) PushHook(hook func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error)) {
+	f.mutex.Lock()
+	f.hooks = append(f.hooks, hook)
+	f.mutex.Unlock()
+}
+
+// SetDefaultReturn calls SetDefaultHook with a function that returns the
+// given values.
+func (f *StoreListLFSLocksFunc) SetDefaultReturn(r0 []*database.LFSLock, r1 error) {
+	f.SetDefaultHook(func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+// PushReturn calls PushHook with a function that returns the given values.
+func (f *StoreListLFSLocksFunc) PushReturn(r0 []*database.LFSLock, r1 error) {
+	f.PushHook(func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+		return r0, r1
+	})
+}
+
+func (f *StoreListLFSLocksFunc) nextHook() func(context.Context, int64, database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.hooks) == 0 {
+		return f.defaultHook
+	}
+
+	hook := f.hooks[0]
+	f.hooks = f.hooks[1:]
+	return hook
+}
+
+func (f *StoreListLFSLocksFunc) appendCall(r0 StoreListLFSLocksFuncCall) {
+	f.mutex.Lock()
+	f.history = append(f.history, r0)
+	f.mutex.Unlock()
+}
+
+// History returns a sequence of StoreListLFSLocksFuncCall objects
+// describing the invocations of this function.
+func (f *StoreListLFSLocksFunc) History() []StoreListLFSLocksFuncCall {
+	f.mutex.Lock()
+	history := make([]StoreListLFSLocksFuncCall, len(f.history))
+	copy(history, f.history)
+	f.mutex.Unlock()
+
+	return history
+}
+
+// StoreListLFSLocksFuncCall is an object that describes an invocation of
+// method ListLFSLocks on an instance of MockStore.
+type StoreListLFSLocksFuncCall struct {
+	// Arg0 is the value of the 1st argument passed to this method
+	// invocation.
+	Arg0 context.Context
+	// Arg1 is the value of the 2nd argument passed to this method
+	// invocation.
+	Arg1 int64
+	// Arg2 is the value of the 3rd argument passed to this method
+	// invocation.
+	Arg2 database.ListLFSLocksOptions
+	// Result0 is the value of the 1st result returned from this method
+	// invocation.
+	Result0 []*database.LFSLock
+	// Result1 is the value of the 2nd result returned from this method
+	// invocation.
+	Result1 error
+}
+
+// Args returns an interface slice containing the arguments of this
+// invocation.
+func (c StoreListLFSLocksFuncCall) Args() []interface{} {
+	return []interface{}{c.Arg0, c.Arg1, c.Arg2}
+}
+
+// Results returns an interface slice containing the results of this
+// invocation.
+func (c StoreListLFSLocksFuncCall) Results() []interface{} {
+	return []interface{}{c.Result0, c.Result1}
+}
+
 // StoreTouchAccessTokenByIDFunc describes the behavior when the
 // TouchAccessTokenByID method of the parent MockStore instance is invoked.
 type StoreTouchAccessTokenByIDFunc struct {
@@ -1,6 +1,7 @@
 package lfs
 
 import (
+	gocontext "context"
 	"net/http"
 	"strings"
 
@@ -23,24 +24,37 @@ func RegisterRoutes(r *macaron.Router) {
 	verifyContentTypeStream := verifyHeader("Content-Type", "application/octet-stream", http.StatusBadRequest)
 
 	store := NewStore()
+	basic := newBasicHandler(store)
+
 	r.Group("", func() {
-		r.Post("/objects/batch", authorize(store, database.AccessModeRead), verifyAccept, verifyContentTypeJSON, serveBatch(store))
+		r.Post("/objects/batch", authorize(store, database.AccessModeRead), verifyAccept, verifyContentTypeJSON, serveBatch(store, basic))
 		r.Group("/objects/basic", func() {
-			basic := &basicHandler{
-				store:          store,
-				defaultStorage: lfsx.Storage(conf.LFS.Storage),
-				storagers: map[lfsx.Storage]lfsx.Storager{
-					lfsx.StorageLocal: &lfsx.LocalStorage{Root: conf.LFS.ObjectsPath, TempDir: conf.LFS.ObjectsTempPath},
-				},
-			}
 			r.Combo("/:oid", verifyOID()).
 				Get(authorize(store, database.AccessModeRead), basic.serveDownload).
 				Put(authorize(store, database.AccessModeWrite), verifyContentTypeStream, basic.serveUpload)
 			r.Post("/verify", authorize(store, database.AccessModeWrite), verifyAccept, verifyContentTypeJSON, basic.serveVerify)
 		})
+		r.Group("/locks", func() {
+			r.Combo("").
+				Get(authorize(store, database.AccessModeRead), verifyAccept, serveListLocks(store)).
+				Post(authorize(store, database.AccessModeWrite), verifyAccept, verifyContentTypeJSON, serveCreateLock(store))
+			r.Post("/verify", authorize(store, database.AccessModeWrite), verifyAccept, verifyContentTypeJSON, serveVerifyLocks(store))
+			r.Post("/:id/unlock", authorize(store, database.AccessModeWrite), verifyAccept, verifyContentTypeJSON, serveUnlock(store))
+		})
 	}, authenticate(store))
 }
 
+// newBasicHandler returns a new basic transfer handler with storage backends
+// configured.
+func newBasicHandler(store Store) *basicHandler {
+	return &basicHandler{
+		store:          store,
+		defaultStorage: lfsx.Storage(conf.LFS.Storage),
+		storagers:      database.LFSStoragers(),
+		presignedURLs:  conf.LFS.S3.PresignedURLs,
+	}
+}
+
 // authenticate tries to authenticate user via HTTP Basic Auth. It first tries to authenticate
 // as plain username and password, then use username as access token if previous step failed.
 func authenticate(store Store) macaron.Handler {
@@ -52,56 +66,121 @@ func authenticate(store Store) macaron.Handler {
 	}
 
 	return func(c *macaron.Context) {
+		// Tokens handed out via SSH by "git-lfs-authenticate" are sent as bearer
+		// tokens.
+		if fields := strings.Fields(c.Req.Header.Get("Authorization")); len(fields) == 2 && fields[0] == "Bearer" {
+			token, err := lfsx.ParseToken(conf.Security.SecretKey, fields[1])
+			if err != nil {
+				askCredentials(c.Resp)
+				return
+			}
+
+			user, err := store.GetUserByID(c.Req.Context(), token.UserID)
+			if err != nil {
+				if database.IsErrUserNotExist(err) {
+					askCredentials(c.Resp)
+				} else {
+					internalServerError(c.Resp)
+					log.Error("Failed to get user [id: %d]: %v", token.UserID, err)
+				}
+				return
+			}
+
+			log.Trace("[LFS] Authenticated user via token: %s", user.Name)
+
+			c.Map(user)
+			c.Map(authenticatedUser{User: user, Token: token})
+			return
+		}
+
 		username, password := authx.DecodeBasic(c.Req.Header)
 		if username == "" {
 			askCredentials(c.Resp)
 			return
 		}
 
-		user, err := store.AuthenticateUser(c.Req.Context(), username, password, -1)
-		if err != nil && !auth.IsErrBadCredentials(err) {
-			internalServerError(c.Resp)
-			log.Error("Failed to authenticate user [name: %s]: %v", username, err)
-			return
-		}
+		// Password attempts are rejected while throttled, but access tokens are still
+		// accepted.
+		var user *database.User
+		var err error
+		wait := context.ReserveLoginAttempt(c.Req.Request, username)
+		if wait <= 0 {
+			user, err = store.AuthenticateUser(c.Req.Context(), username, password, -1)
+			if err != nil && !auth.IsErrBadCredentials(err) {
+				context.ReleaseLoginAttempt(c.Req.Request, username)
+				internalServerError(c.Resp)
+				log.Error("Failed to authenticate user [name: %s]: %v", username, err)
+				return
+			}
 
-		if err == nil && store.IsTwoFactorEnabled(c.Req.Context(), user.ID) {
-			c.Error(http.StatusBadRequest, "Users with 2FA enabled are not allowed to authenticate via username and password.")
-			return
+			if err == nil {
+				if store.IsTwoFactorEnabled(c.Req.Context(), user.ID) {
+					context.ReleaseLoginAttempt(c.Req.Request, username)
+					c.Error(http.StatusBadRequest, "Users with 2FA enabled are not allowed to authenticate via username and password.")
+					return
+				}
+				context.ResetLoginFailures(c.Req.Request, username)
+			}
 		}
 
 		// If username and password combination failed, try again using either username
 		// or password as the token.
-		if auth.IsErrBadCredentials(err) {
-			user, err = context.AuthenticateByToken(store, c.Req.Context(), username)
+		var accessToken *database.AccessToken
+		if user == nil {
+			user, accessToken, err = context.AuthenticateByToken(store, c.Req.Context(), username)
 			if err != nil && !database.IsErrAccessTokenNotExist(err) {
 				internalServerError(c.Resp)
 				log.Error("Failed to authenticate by access token via username: %v", err)
 				return
 			} else if database.IsErrAccessTokenNotExist(err) {
 				// Try again using the password field as the token.
-				user, err = context.AuthenticateByToken(store, c.Req.Context(), password)
+				user, accessToken, err = context.AuthenticateByToken(store, c.Req.Context(), password)
 				if err != nil {
-					if database.IsErrAccessTokenNotExist(err) {
-						askCredentials(c.Resp)
-					} else {
+					if !database.IsErrAccessTokenNotExist(err) {
 						c.Status(http.StatusInternalServerError)
 						log.Error("Failed to authenticate by access token via password: %v", err)
+					} else if wait > 0 {
+						context.SetRetryAfter(c.Resp.Header(), wait)
+						responseJSON(c.Resp, http.StatusTooManyRequests, responseError{
+							Message: "Too many failed login attempts, please try again later",
+						})
+					} else {
+						context.AuditLoginFailed(c.Req.Request, 0, username, "bad credentials over Git LFS")
+						askCredentials(c.Resp)
 					}
 					return
 				}
 			}
+
+			// The password attempt is not a failure when the token is sent in its place.
+			if wait <= 0 {
+				context.ReleaseLoginAttempt(c.Req.Request, username)
+			}
 		}
 
 		log.Trace("[LFS] Authenticated user: %s", user.Name)
 
 		c.Map(user)
+		c.Map(authenticatedUser{User: user, AccessToken: accessToken})
 	}
 }
 
+// authenticatedUser is the authenticated user of the request, which remains
+// available to handlers after authorize overrides the mapped *database.User
+// with the repository owner.
+type authenticatedUser struct {
+	*database.User
+	// The token the user is authenticated with, if any. It limits access to a
+	// single repository and operation.
+	Token *lfsx.Token
+	// The personal access token the user is authenticated with, if any. It
+	// limits access with its scopes.
+	AccessToken *database.AccessToken
+}
+
 // authorize tries to authorize the user to the context repository with given access mode.
 func authorize(store Store, mode database.AccessMode) macaron.Handler {
-	return func(c *macaron.Context, actor *database.User) {
+	return func(c *macaron.Context, actor authenticatedUser) {
 		username := c.Params(":username")
 		reponame := strings.TrimSuffix(c.Params(":reponame"), ".git")
 
@@ -127,12 +206,7 @@ func authorize(store Store, mode database.AccessMode) macaron.Handler {
 			return
 		}
 
-		if !store.AuthorizeRepositoryAccess(c.Req.Context(), actor.ID, repo.ID, mode,
-			database.AccessModeOptions{
-				OwnerID: repo.OwnerID,
-				Private: repo.IsPrivate,
-			},
-		) {
+		if !authorizeAccess(c.Req.Context(), store, actor, repo, mode) {
 			c.Status(http.StatusNotFound)
 			return
 		}
@@ -144,6 +218,25 @@ func authorize(store Store, mode database.AccessMode) macaron.Handler {
 	}
 }
 
+// authorizeAccess returns true if the actor has given access mode to the
+// repository, within the limits of the token it is authenticated with.
+func authorizeAccess(ctx gocontext.Context, store Store, actor authenticatedUser, repo *database.Repository, mode database.AccessMode) bool {
+	if actor.Token != nil &&
+		(actor.Token.RepoID != repo.ID || (mode > database.AccessModeRead && actor.Token.Operation != basicOperationUpload)) {
+		return false
+	}
+	if actor.AccessToken != nil && actor.AccessToken.MaxRepoAccessMode(repo.ID, repo.IsPrivate) < mode {
+		return false
+	}
+
+	return store.AuthorizeRepositoryAccess(ctx, actor.ID, repo.ID, mode,
+		database.AccessModeOptions{
+			OwnerID: repo.OwnerID,
+			Private: repo.IsPrivate,
+		},
+	)
+}
+
 // verifyHeader checks if the HTTP header contains given value.
 // When not, response given "failCode" as status code.
 func verifyHeader(key, value string, failCode int) macaron.Handler {
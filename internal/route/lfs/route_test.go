@@ -7,16 +7,29 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/macaron.v1"
 
 	"gogs.io/gogs/internal/auth"
+	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/database/databasetest"
 	"gogs.io/gogs/internal/lfsx"
 )
 
 func TestAuthenticate(t *testing.T) {
+	// Failed logins are recorded in the audit log.
+	databasetest.SetUp(t)
+
+	token := &lfsx.Token{
+		UserID:    1,
+		RepoID:    1,
+		Operation: "download",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
 	tests := []struct {
 		name          string
 		header        http.Header
@@ -120,6 +133,32 @@ func TestAuthenticate(t *testing.T) {
 			expHeader:     http.Header{},
 			expBody:       "ID: 1, Name: unknwon",
 		},
+		{
+			name: "token signed with another secret",
+			header: http.Header{
+				"Authorization": []string{"Bearer " + token.Sign("another secret")},
+			},
+			expStatusCode: http.StatusUnauthorized,
+			expHeader: http.Header{
+				"Lfs-Authenticate": []string{`Basic realm="Git LFS"`},
+				"Content-Type":     []string{"application/vnd.git-lfs+json"},
+			},
+			expBody: `{"message":"Credentials needed"}` + "\n",
+		},
+		{
+			name: "authenticate by token",
+			header: http.Header{
+				"Authorization": []string{"Bearer " + token.Sign(conf.Security.SecretKey)},
+			},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetUserByIDFunc.SetDefaultReturn(&database.User{ID: 1, Name: "unknwon"}, nil)
+				return mockStore
+			},
+			expStatusCode: http.StatusOK,
+			expHeader:     http.Header{},
+			expBody:       "ID: 1, Name: unknwon",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -153,12 +192,21 @@ func TestAuthenticate(t *testing.T) {
 			assert.Equal(t, test.expBody, string(body))
 		})
 	}
+
+	failures, err := database.Handle.AuditLogs().Count(
+		context.Background(),
+		database.ListAuditLogsOptions{Action: string(database.AuditActionUserLoginFailed)},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
 }
 
 func TestAuthorize(t *testing.T) {
 	tests := []struct {
 		name          string
 		accessMode    database.AccessMode
+		token         *lfsx.Token
+		accessToken   *database.AccessToken
 		mockStore     func() *MockStore
 		expStatusCode int
 		expBody       string
@@ -204,6 +252,92 @@ func TestAuthorize(t *testing.T) {
 			},
 			expStatusCode: http.StatusNotFound,
 		},
+		{
+			name:       "token is for another repository",
+			accessMode: database.AccessModeRead,
+			token:      &lfsx.Token{RepoID: 2, Operation: "download"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				mockStore.GetRepositoryByNameFunc.SetDefaultHook(func(ctx context.Context, ownerID int64, name string) (*database.Repository, error) {
+					return &database.Repository{ID: 1, Name: name}, nil
+				})
+				mockStore.GetUserByUsernameFunc.SetDefaultHook(func(ctx context.Context, username string) (*database.User, error) {
+					return &database.User{Name: username}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:       "token is for download only",
+			accessMode: database.AccessModeWrite,
+			token:      &lfsx.Token{RepoID: 1, Operation: "download"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				mockStore.GetRepositoryByNameFunc.SetDefaultHook(func(ctx context.Context, ownerID int64, name string) (*database.Repository, error) {
+					return &database.Repository{ID: 1, Name: name}, nil
+				})
+				mockStore.GetUserByUsernameFunc.SetDefaultHook(func(ctx context.Context, username string) (*database.User, error) {
+					return &database.User{Name: username}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:        "access token does not have the scope",
+			accessMode:  database.AccessModeWrite,
+			accessToken: &database.AccessToken{Scopes: "read:repo"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				mockStore.GetRepositoryByNameFunc.SetDefaultHook(func(ctx context.Context, ownerID int64, name string) (*database.Repository, error) {
+					return &database.Repository{ID: 1, Name: name, IsPrivate: true}, nil
+				})
+				mockStore.GetUserByUsernameFunc.SetDefaultHook(func(ctx context.Context, username string) (*database.User, error) {
+					return &database.User{Name: username}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:        "access token is for other repositories",
+			accessMode:  database.AccessModeRead,
+			accessToken: &database.AccessToken{Scopes: "write:repo", RepoIDs: "2"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				mockStore.GetRepositoryByNameFunc.SetDefaultHook(func(ctx context.Context, ownerID int64, name string) (*database.Repository, error) {
+					return &database.Repository{ID: 1, Name: name, IsPrivate: true}, nil
+				})
+				mockStore.GetUserByUsernameFunc.SetDefaultHook(func(ctx context.Context, username string) (*database.User, error) {
+					return &database.User{Name: username}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:        "access token has the scope",
+			accessMode:  database.AccessModeWrite,
+			accessToken: &database.AccessToken{Scopes: "write:repo", RepoIDs: "1"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				mockStore.GetRepositoryByNameFunc.SetDefaultHook(func(ctx context.Context, ownerID int64, name string) (*database.Repository, error) {
+					return &database.Repository{ID: 1, Name: name, IsPrivate: true}, nil
+				})
+				mockStore.GetUserByUsernameFunc.SetDefaultHook(func(ctx context.Context, username string) (*database.User, error) {
+					return &database.User{Name: username}, nil
+				})
+				return mockStore
+			},
+			expStatusCode: http.StatusOK,
+			expBody:       "owner.Name: owner, repo.Name: repo",
+		},
 
 		{
 			name:       "actor is authorized",
@@ -235,7 +369,7 @@ func TestAuthorize(t *testing.T) {
 			m := macaron.New()
 			m.Use(macaron.Renderer())
 			m.Use(func(c *macaron.Context) {
-				c.Map(&database.User{})
+				c.Map(authenticatedUser{User: &database.User{}, Token: test.token, AccessToken: test.accessToken})
 			})
 			m.Get(
 				"/:username/:reponame",
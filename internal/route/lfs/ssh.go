@@ -0,0 +1,568 @@
+package lfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/lfsx"
+	"gogs.io/gogs/internal/strx"
+)
+
+// sshTokenExpiry is how long tokens handed out by "git-lfs-authenticate" are
+// valid for.
+const sshTokenExpiry = 10 * time.Minute
+
+// ServeSSHAuthenticate serves the "git-lfs-authenticate" command, which hands
+// out a short-lived token for the actor to access LFS objects of the repository
+// over HTTP with given operation.
+func ServeSSHAuthenticate(w io.Writer, actor *database.User, repo *database.Repository, operation string) error {
+	token := &lfsx.Token{
+		UserID:    actor.ID,
+		RepoID:    repo.ID,
+		Operation: operation,
+		ExpiresAt: time.Now().Add(sshTokenExpiry),
+	}
+	return json.NewEncoder(w).Encode(sshAuthenticateResponse{
+		Href: fmt.Sprintf("%s%s.git/info/lfs", conf.Server.ExternalURL, repo.FullName()),
+		Header: map[string]string{
+			"Authorization": "Bearer " + token.Sign(conf.Security.SecretKey),
+		},
+		ExpiresIn: int64(sshTokenExpiry / time.Second),
+	})
+}
+
+// sshAuthenticateResponse defines the response payload of the
+// "git-lfs-authenticate" command.
+type sshAuthenticateResponse struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header"`
+	ExpiresIn int64             `json:"expires_in"`
+}
+
+// ServeSSHTransfer serves the "git-lfs-transfer" command, which transfers LFS
+// objects and manages locks of the repository entirely over SSH, see
+// https://github.com/git-lfs/git-lfs/blob/main/docs/proposals/ssh_adapter.md.
+func ServeSSHTransfer(ctx context.Context, r io.Reader, w io.Writer, actor *database.User, repo *database.Repository, operation string) error {
+	store := NewStore()
+	t := &sshTransfer{
+		store:     store,
+		basic:     newBasicHandler(store),
+		r:         bufio.NewReader(r),
+		w:         w,
+		actor:     actor,
+		repo:      repo,
+		operation: operation,
+	}
+	return t.serve(ctx)
+}
+
+// The maximum length of data in a single pkt-line.
+const maxPktDataLen = 65516
+
+var (
+	errPktFlush = errors.New("flush packet")
+	errPktDelim = errors.New("delimiter packet")
+)
+
+type sshTransfer struct {
+	store Store
+	basic *basicHandler
+	r     *bufio.Reader
+	w     io.Writer
+
+	actor *database.User
+	repo  *database.Repository
+	// The operation of the session, either "upload" or "download".
+	operation string
+}
+
+// readPkt reads a single pkt-line. It returns errPktFlush and errPktDelim for
+// flush and delimiter packets respectively.
+func (t *sshTransfer) readPkt() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(t.r, header[:]); err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, errors.Newf("invalid pkt-line length %q", header)
+	}
+	switch {
+	case n == 0:
+		return nil, errPktFlush
+	case n == 1:
+		return nil, errPktDelim
+	case n < 4:
+		return nil, errors.Newf("invalid pkt-line length %d", n)
+	}
+
+	data := make([]byte, n-4)
+	if _, err = io.ReadFull(t.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readLines reads text lines until a flush or delimiter packet, and returns
+// the error of which one ended the lines.
+func (t *sshTransfer) readLines() ([]string, error) {
+	var lines []string
+	for {
+		data, err := t.readPkt()
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, strings.TrimSuffix(string(data), "\n"))
+	}
+}
+
+func (t *sshTransfer) writePkt(data []byte) error {
+	_, err := fmt.Fprintf(t.w, "%04x%s", len(data)+4, data)
+	return err
+}
+
+// Write implements io.Writer to write data in as many packets as needed.
+func (t *sshTransfer) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); i += maxPktDataLen {
+		if err := t.writePkt(p[i:min(i+maxPktDataLen, len(p))]); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+// sshTransferDataReader reads data of packets until a flush packet.
+type sshTransferDataReader struct {
+	t   *sshTransfer
+	buf []byte
+	eof bool
+}
+
+func (r *sshTransferDataReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		data, err := r.t.readPkt()
+		if err == errPktFlush {
+			r.eof = true
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		r.buf = data
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// respond writes a response with given status, arguments and text lines.
+func (t *sshTransfer) respond(status int, args []string, lines []string) error {
+	if err := t.writePkt([]byte(fmt.Sprintf("status %d\n", status))); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := t.writePkt([]byte(arg + "\n")); err != nil {
+			return err
+		}
+	}
+	if lines != nil {
+		if _, err := io.WriteString(t.w, "0001"); err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if err := t.writePkt([]byte(line + "\n")); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(t.w, "0000")
+	return err
+}
+
+func (t *sshTransfer) respondError(status int, message string) error {
+	return t.respond(status, nil, []string{message})
+}
+
+func (t *sshTransfer) internalServerError() error {
+	return t.respondError(http.StatusInternalServerError, "Internal server error")
+}
+
+// parseArgs parses "key=value" arguments.
+func parseArgs(lines []string) map[string]string {
+	args := make(map[string]string, len(lines))
+	for _, line := range lines {
+		key, value, _ := strings.Cut(line, "=")
+		args[key] = value
+	}
+	return args
+}
+
+func (t *sshTransfer) serve(ctx context.Context) error {
+	// Advertise capabilities and negotiate the protocol version.
+	if err := t.writePkt([]byte("version=1\n")); err != nil {
+		return err
+	} else if _, err = io.WriteString(t.w, "0000"); err != nil {
+		return err
+	}
+	lines, err := t.readLines()
+	if err != errPktFlush {
+		return errors.Wrap(err, "read version")
+	} else if len(lines) == 0 || lines[0] != "version 1" {
+		return t.respondError(http.StatusBadRequest, "Unsupported version")
+	}
+	if err = t.respond(http.StatusOK, nil, nil); err != nil {
+		return err
+	}
+
+	for {
+		lines, err := t.readLines()
+		if err == io.EOF && len(lines) == 0 {
+			return nil
+		} else if err != errPktFlush && err != errPktDelim {
+			return errors.Wrap(err, "read request")
+		} else if len(lines) == 0 {
+			if err = t.respondError(http.StatusBadRequest, "Missing command"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		command, arg, _ := strings.Cut(lines[0], " ")
+		args := parseArgs(lines[1:])
+		hasData := err == errPktDelim
+		switch command {
+		case "batch":
+			err = t.serveBatch(ctx, args, hasData)
+		case "put-object":
+			err = t.servePutObject(ctx, lfsx.OID(arg), args, hasData)
+		case "verify-object":
+			err = t.serveVerifyObject(ctx, lfsx.OID(arg), args)
+		case "get-object":
+			err = t.serveGetObject(ctx, lfsx.OID(arg))
+		case "lock":
+			err = t.serveLock(ctx, args)
+		case "list-lock":
+			err = t.serveListLock(ctx, args)
+		case "unlock":
+			err = t.serveUnlock(ctx, arg, args)
+		case "quit":
+			return t.respond(http.StatusOK, nil, nil)
+		default:
+			if hasData {
+				// Skip the data to stay in sync with the client.
+				if _, err = io.Copy(io.Discard, &sshTransferDataReader{t: t}); err != nil {
+					return err
+				}
+			}
+			err = t.respondError(http.StatusBadRequest, "Unknown command")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// requireUpload responds an error for commands that are only allowed in
+// sessions of the upload operation, which requires write access.
+func (t *sshTransfer) requireUpload() error {
+	return t.respondError(http.StatusForbidden, messageWriteRequired)
+}
+
+const messageWriteRequired = "Write access is required for this command"
+
+func (t *sshTransfer) serveBatch(ctx context.Context, args map[string]string, hasData bool) error {
+	var lines []string
+	if hasData {
+		var err error
+		lines, err = t.readLines()
+		if err != errPktFlush {
+			return errors.Wrap(err, "read objects")
+		}
+	}
+	if algo := args["hash-algo"]; algo != "" && algo != "sha256" {
+		return t.respondError(http.StatusConflict, "Unsupported hash algorithm")
+	}
+
+	oids := make([]lfsx.OID, 0, len(lines))
+	sizes := make([]string, 0, len(lines))
+	for _, line := range lines {
+		oid, size, _ := strings.Cut(line, " ")
+		if !lfsx.ValidOID(lfsx.OID(oid)) {
+			return t.respondError(http.StatusBadRequest, "Object has invalid oid")
+		}
+		oids = append(oids, lfsx.OID(oid))
+		sizes = append(sizes, size)
+	}
+
+	stored, err := t.store.GetLFSObjectsByOIDs(ctx, t.repo.ID, oids...)
+	if err != nil {
+		log.Error("Failed to get objects [repo_id: %d, oids: %v]: %v", t.repo.ID, oids, err)
+		return t.internalServerError()
+	}
+	storedSet := make(map[lfsx.OID]bool, len(stored))
+	for _, obj := range stored {
+		storedSet[obj.OID] = true
+	}
+
+	results := make([]string, 0, len(oids))
+	for i, oid := range oids {
+		action := basicOperationDownload
+		if t.operation == basicOperationUpload {
+			action = basicOperationUpload
+			if storedSet[oid] {
+				action = "noop"
+			}
+		}
+		results = append(results, fmt.Sprintf("%s %s %s", oid, sizes[i], action))
+	}
+	return t.respond(http.StatusOK, nil, results)
+}
+
+func (t *sshTransfer) servePutObject(ctx context.Context, oid lfsx.OID, args map[string]string, hasData bool) error {
+	data := &sshTransferDataReader{t: t, eof: !hasData}
+	respondError := func(status int, message string) error {
+		// Skip the rest of data to stay in sync with the client.
+		if _, err := io.Copy(io.Discard, data); err != nil {
+			return err
+		}
+		return t.respondError(status, message)
+	}
+
+	if t.operation != basicOperationUpload {
+		return respondError(http.StatusForbidden, messageWriteRequired)
+	} else if !lfsx.ValidOID(oid) {
+		return respondError(http.StatusBadRequest, "Invalid oid")
+	}
+	size, err := strconv.ParseInt(args["size"], 10, 64)
+	if err != nil {
+		return respondError(http.StatusBadRequest, "Invalid size")
+	}
+
+	// NOTE: LFS client will retry upload the same object if there was a partial
+	// failure, therefore we would like to skip ones that already exist.
+	_, err = t.store.GetLFSObjectByOID(ctx, t.repo.ID, oid)
+	if err == nil {
+		if _, err = io.Copy(io.Discard, data); err != nil {
+			return err
+		}
+		return t.respond(http.StatusOK, nil, nil)
+	} else if !database.IsErrLFSObjectNotExist(err) {
+		log.Error("Failed to get object [repo_id: %d, oid: %s]: %v", t.repo.ID, oid, err)
+		return respondError(http.StatusInternalServerError, "Internal server error")
+	}
+
+	s := t.basic.DefaultStorager()
+	written, err := s.Upload(oid, io.NopCloser(data))
+	if err != nil {
+		if err == lfsx.ErrInvalidOID || err == lfsx.ErrOIDMismatch {
+			return respondError(http.StatusBadRequest, strx.ToUpperFirst(err.Error()))
+		}
+		log.Error("Failed to upload object [storage: %s, oid: %s]: %v", s.Storage(), oid, err)
+		return respondError(http.StatusInternalServerError, "Internal server error")
+	}
+	if _, err = io.Copy(io.Discard, data); err != nil {
+		return err
+	} else if written != size {
+		return t.respondError(http.StatusBadRequest, "Object size mismatch")
+	}
+
+	err = t.store.CreateLFSObject(ctx, t.repo.ID, oid, written, s.Storage())
+	if err != nil {
+		log.Error("Failed to create object [repo_id: %d, oid: %s]: %v", t.repo.ID, oid, err)
+		return t.internalServerError()
+	}
+
+	log.Trace("[LFS] Object created %q", oid)
+	return t.respond(http.StatusOK, nil, nil)
+}
+
+func (t *sshTransfer) serveVerifyObject(ctx context.Context, oid lfsx.OID, args map[string]string) error {
+	if t.operation != basicOperationUpload {
+		return t.requireUpload()
+	}
+
+	object, err := t.store.GetLFSObjectByOID(ctx, t.repo.ID, oid)
+	if err != nil {
+		if database.IsErrLFSObjectNotExist(err) {
+			return t.respondError(http.StatusNotFound, "Object does not exist")
+		}
+		log.Error("Failed to get object [repo_id: %d, oid: %s]: %v", t.repo.ID, oid, err)
+		return t.internalServerError()
+	}
+
+	if strconv.FormatInt(object.Size, 10) != args["size"] {
+		return t.respondError(http.StatusBadRequest, "Object size mismatch")
+	}
+	return t.respond(http.StatusOK, nil, nil)
+}
+
+func (t *sshTransfer) serveGetObject(ctx context.Context, oid lfsx.OID) error {
+	object, err := t.store.GetLFSObjectByOID(ctx, t.repo.ID, oid)
+	if err != nil {
+		if database.IsErrLFSObjectNotExist(err) {
+			return t.respondError(http.StatusNotFound, "Object does not exist")
+		}
+		log.Error("Failed to get object [repo_id: %d, oid: %s]: %v", t.repo.ID, oid, err)
+		return t.internalServerError()
+	}
+
+	s := t.basic.Storager(object.Storage)
+	if s == nil {
+		log.Error("Failed to locate the object [repo_id: %d, oid: %s]: storage %q not found", object.RepoID, object.OID, object.Storage)
+		return t.internalServerError()
+	}
+
+	if err = t.writePkt([]byte(fmt.Sprintf("status %d\n", http.StatusOK))); err != nil {
+		return err
+	} else if err = t.writePkt([]byte(fmt.Sprintf("size=%d\n", object.Size))); err != nil {
+		return err
+	} else if _, err = io.WriteString(t.w, "0001"); err != nil {
+		return err
+	}
+	// NOTE: The status has been sent, thus there is no way to tell the client
+	// about a failure other than closing the connection.
+	if err = s.Download(object.OID, t); err != nil {
+		return errors.Wrapf(err, "download object %q", object.OID)
+	}
+	_, err = io.WriteString(t.w, "0000")
+	return err
+}
+
+// lockArgs returns the arguments of the lock in responses of creating and
+// deleting a lock.
+func lockArgs(l *lock) []string {
+	return []string{
+		"id=" + l.ID,
+		"path=" + l.Path,
+		"locked-at=" + l.LockedAt,
+		"ownername=" + l.Owner.Name,
+	}
+}
+
+func (t *sshTransfer) serveLock(ctx context.Context, args map[string]string) error {
+	if t.operation != basicOperationUpload {
+		return t.requireUpload()
+	}
+
+	path := database.CleanLFSLockPath(args["path"])
+	if path == "" {
+		return t.respondError(http.StatusBadRequest, "Path is required")
+	}
+
+	created, err := t.store.CreateLFSLock(ctx, t.repo.ID, t.actor.ID, path)
+	if err == nil {
+		return t.respond(http.StatusCreated, lockArgs(toLock(created, t.actor)), nil)
+	} else if !database.IsErrLFSLockAlreadyExist(err) {
+		log.Error("Failed to create lock [repo_id: %d, path: %s]: %v", t.repo.ID, path, err)
+		return t.internalServerError()
+	}
+
+	existing, err := t.store.GetLFSLockByPath(ctx, t.repo.ID, path)
+	if err != nil {
+		log.Error("Failed to get lock [repo_id: %d, path: %s]: %v", t.repo.ID, path, err)
+		return t.internalServerError()
+	}
+	locks, err := toLocks(ctx, t.store, existing)
+	if err != nil {
+		log.Error("Failed to convert lock [id: %d]: %v", existing.ID, err)
+		return t.internalServerError()
+	}
+	return t.respond(http.StatusConflict, lockArgs(locks[0]), []string{"Lock already exists"})
+}
+
+func (t *sshTransfer) serveListLock(ctx context.Context, args map[string]string) error {
+	opts, message := parseLocksPage(args["cursor"], args["limit"])
+	if message != "" {
+		return t.respondError(http.StatusBadRequest, message)
+	}
+	opts.Path = args["path"]
+	if id := args["id"]; id != "" {
+		opts.ID, _ = strconv.ParseInt(id, 10, 64)
+		if opts.ID <= 0 {
+			return t.respond(http.StatusOK, nil, []string{})
+		}
+	}
+
+	locks, nextCursor, err := listLocks(ctx, t.store, t.repo.ID, opts)
+	if err != nil {
+		log.Error("Failed to list locks [repo_id: %d]: %v", t.repo.ID, err)
+		return t.internalServerError()
+	}
+	converted, err := toLocks(ctx, t.store, locks...)
+	if err != nil {
+		log.Error("Failed to convert locks [repo_id: %d]: %v", t.repo.ID, err)
+		return t.internalServerError()
+	}
+
+	var respArgs []string
+	if nextCursor != "" {
+		respArgs = append(respArgs, "next-cursor="+nextCursor)
+	}
+	lines := make([]string, 0, len(converted)*5)
+	for i, l := range converted {
+		owner := "theirs"
+		if locks[i].OwnerID == t.actor.ID {
+			owner = "ours"
+		}
+		lines = append(lines,
+			"lock "+l.ID,
+			"path "+l.ID+" "+l.Path,
+			"locked-at "+l.ID+" "+l.LockedAt,
+			"ownername "+l.ID+" "+l.Owner.Name,
+			"owner "+l.ID+" "+owner,
+		)
+	}
+	return t.respond(http.StatusOK, respArgs, lines)
+}
+
+func (t *sshTransfer) serveUnlock(ctx context.Context, arg string, args map[string]string) error {
+	if t.operation != basicOperationUpload {
+		return t.requireUpload()
+	}
+
+	id, _ := strconv.ParseInt(arg, 10, 64)
+	existing, err := t.store.GetLFSLockByID(ctx, t.repo.ID, id)
+	if err != nil {
+		if database.IsErrLFSLockNotExist(err) {
+			return t.respondError(http.StatusNotFound, "Lock does not exist")
+		}
+		log.Error("Failed to get lock [repo_id: %d, id: %d]: %v", t.repo.ID, id, err)
+		return t.internalServerError()
+	}
+
+	force := args["force"] == "true"
+	if message := checkUnlock(ctx, t.store, t.actor, t.repo, existing, force); message != "" {
+		return t.respondError(http.StatusForbidden, message)
+	}
+
+	locks, err := toLocks(ctx, t.store, existing)
+	if err != nil {
+		log.Error("Failed to convert lock [id: %d]: %v", existing.ID, err)
+		return t.internalServerError()
+	}
+
+	err = t.store.DeleteLFSLockByID(ctx, t.repo.ID, existing.ID)
+	if err != nil {
+		log.Error("Failed to delete lock [repo_id: %d, id: %d]: %v", t.repo.ID, existing.ID, err)
+		return t.internalServerError()
+	}
+
+	log.Trace("[LFS] User %q unlocked %q [repo_id: %d, force: %v]", t.actor.Name, existing.Path, t.repo.ID, force)
+	return t.respond(http.StatusOK, lockArgs(locks[0]), nil)
+}
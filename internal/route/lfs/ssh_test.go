@@ -0,0 +1,316 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/lfsx"
+)
+
+func TestServeSSHAuthenticate(t *testing.T) {
+	conf.SetMockServer(t, conf.ServerOpts{
+		ExternalURL: "https://gogs.example.com/",
+	})
+
+	var buf bytes.Buffer
+	err := ServeSSHAuthenticate(
+		&buf,
+		&database.User{ID: 1, Name: "alice"},
+		&database.Repository{ID: 2, Name: "repo", Owner: &database.User{Name: "owner"}},
+		basicOperationUpload,
+	)
+	require.NoError(t, err)
+
+	var resp sshAuthenticateResponse
+	err = json.Unmarshal(buf.Bytes(), &resp)
+	require.NoError(t, err)
+	assert.Equal(t, "https://gogs.example.com/owner/repo.git/info/lfs", resp.Href)
+	assert.Equal(t, int64(600), resp.ExpiresIn)
+
+	signed, ok := strings.CutPrefix(resp.Header["Authorization"], "Bearer ")
+	require.True(t, ok)
+	token, err := lfsx.ParseToken(conf.Security.SecretKey, signed)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), token.UserID)
+	assert.Equal(t, int64(2), token.RepoID)
+	assert.Equal(t, basicOperationUpload, token.Operation)
+}
+
+// encodePkts encodes lines into pkt-lines, where "0000" and "0001" are
+// written as flush and delimiter packets respectively.
+func encodePkts(lines ...string) string {
+	var buf strings.Builder
+	for _, line := range lines {
+		if line == "0000" || line == "0001" {
+			buf.WriteString(line)
+			continue
+		}
+		_, _ = fmt.Fprintf(&buf, "%04x%s", len(line)+4, line)
+	}
+	return buf.String()
+}
+
+// decodePkts decodes pkt-lines into lines, where flush and delimiter packets
+// are returned as "0000" and "0001" respectively.
+func decodePkts(t *testing.T, s string) []string {
+	t.Helper()
+
+	var lines []string
+	for s != "" {
+		require.GreaterOrEqual(t, len(s), 4)
+		n, err := strconv.ParseUint(s[:4], 16, 16)
+		require.NoError(t, err)
+		if n <= 1 {
+			lines = append(lines, s[:4])
+			s = s[4:]
+			continue
+		}
+		lines = append(lines, s[4:n])
+		s = s[n:]
+	}
+	return lines
+}
+
+func serveSSHTransferTest(t *testing.T, mockStore *MockStore, s *mockStorage, operation string, request ...string) []string {
+	t.Helper()
+
+	transfer := &sshTransfer{
+		store: mockStore,
+		basic: &basicHandler{
+			defaultStorage: s.Storage(),
+			storagers: map[lfsx.Storage]lfsx.Storager{
+				s.Storage(): s,
+			},
+		},
+		r:         bufio.NewReader(strings.NewReader(encodePkts(append([]string{"version 1\n", "0000"}, request...)...))),
+		actor:     &database.User{ID: 1, Name: "alice"},
+		repo:      &database.Repository{ID: 1, Name: "repo"},
+		operation: operation,
+	}
+	var buf bytes.Buffer
+	transfer.w = &buf
+	err := transfer.serve(context.Background())
+	require.NoError(t, err)
+
+	lines := decodePkts(t, buf.String())
+	// Strip the handshake.
+	require.Equal(t, []string{"version=1\n", "0000", "status 200\n", "0000"}, lines[:4])
+	return lines[4:]
+}
+
+func TestSSHTransfer(t *testing.T) {
+	const oid = lfsx.OID("ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f")
+	const content = "Hello world!"
+
+	tests := []struct {
+		name      string
+		operation string
+		request   []string
+		mockStore func() *MockStore
+		storage   *mockStorage
+		expLines  []string
+	}{
+		{
+			name:      "unknown command",
+			operation: basicOperationDownload,
+			request:   []string{"fetch\n", "0000"},
+			expLines:  []string{"status 400\n", "0001", "Unknown command\n", "0000"},
+		},
+		{
+			name:      "quit",
+			operation: basicOperationDownload,
+			request:   []string{"quit\n", "0000", "batch\n", "0000"},
+			expLines:  []string{"status 200\n", "0000"},
+		},
+		{
+			name:      "batch upload",
+			operation: basicOperationUpload,
+			request: []string{
+				"batch\n", "hash-algo=sha256\n", "0001",
+				string(oid) + " 12\n",
+				"bf1d4d4bd1a9d2eab1f9fcdc3c2dd7d8c1a1bcdf8bf1e1a3b0d5bb1d3a9e3cb1 6\n",
+				"0000",
+			},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectsByOIDsFunc.SetDefaultReturn([]*database.LFSObject{{OID: oid}}, nil)
+				return mockStore
+			},
+			expLines: []string{
+				"status 200\n", "0001",
+				string(oid) + " 12 noop\n",
+				"bf1d4d4bd1a9d2eab1f9fcdc3c2dd7d8c1a1bcdf8bf1e1a3b0d5bb1d3a9e3cb1 6 upload\n",
+				"0000",
+			},
+		},
+		{
+			name:      "batch with invalid oid",
+			operation: basicOperationDownload,
+			request:   []string{"batch\n", "0001", "bad-oid 12\n", "0000"},
+			expLines:  []string{"status 400\n", "0001", "Object has invalid oid\n", "0000"},
+		},
+		{
+			name:      "put object in download session",
+			operation: basicOperationDownload,
+			request:   []string{"put-object " + string(oid) + "\n", "size=12\n", "0001", content, "0000", "quit\n", "0000"},
+			expLines: []string{
+				"status 403\n", "0001", "Write access is required for this command\n", "0000",
+				"status 200\n", "0000",
+			},
+		},
+		{
+			name:      "put object",
+			operation: basicOperationUpload,
+			request:   []string{"put-object " + string(oid) + "\n", "size=12\n", "0001", content[:5], content[5:], "0000"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(nil, database.ErrLFSObjectNotExist{})
+				return mockStore
+			},
+			storage:  &mockStorage{buf: &bytes.Buffer{}},
+			expLines: []string{"status 200\n", "0000"},
+		},
+		{
+			name:      "verify object with size mismatch",
+			operation: basicOperationUpload,
+			request:   []string{"verify-object " + string(oid) + "\n", "size=13\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(&database.LFSObject{OID: oid, Size: 12}, nil)
+				return mockStore
+			},
+			expLines: []string{"status 400\n", "0001", "Object size mismatch\n", "0000"},
+		},
+		{
+			name:      "get object",
+			operation: basicOperationDownload,
+			request:   []string{"get-object " + string(oid) + "\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(&database.LFSObject{OID: oid, Size: 12, Storage: "memory"}, nil)
+				return mockStore
+			},
+			storage:  &mockStorage{buf: bytes.NewBufferString(content)},
+			expLines: []string{"status 200\n", "size=12\n", "0001", content, "0000"},
+		},
+		{
+			name:      "get object that does not exist",
+			operation: basicOperationDownload,
+			request:   []string{"get-object " + string(oid) + "\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := NewMockStore()
+				mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(nil, database.ErrLFSObjectNotExist{})
+				return mockStore
+			},
+			expLines: []string{"status 404\n", "0001", "Object does not exist\n", "0000"},
+		},
+		{
+			name:      "lock already exists",
+			operation: basicOperationUpload,
+			request:   []string{"lock\n", "path=images/logo.png\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.CreateLFSLockFunc.SetDefaultReturn(nil, database.ErrLFSLockAlreadyExist{})
+				mockStore.GetLFSLockByPathFunc.SetDefaultReturn(&database.LFSLock{ID: 7, OwnerID: 2, Path: "images/logo.png", CreatedAt: lockCreatedAt}, nil)
+				return mockStore
+			},
+			expLines: []string{
+				"status 409\n",
+				"id=7\n", "path=images/logo.png\n", "locked-at=2026-10-01T08:00:00Z\n", "ownername=bob\n",
+				"0001", "Lock already exists\n", "0000",
+			},
+		},
+		{
+			name:      "list locks",
+			operation: basicOperationDownload,
+			request:   []string{"list-lock\n", "limit=1\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.ListLFSLocksFunc.SetDefaultReturn([]*database.LFSLock{
+					{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt},
+					{ID: 2, OwnerID: 1, Path: "b.png", CreatedAt: lockCreatedAt},
+				}, nil)
+				return mockStore
+			},
+			expLines: []string{
+				"status 200\n", "next-cursor=2\n", "0001",
+				"lock 1\n", "path 1 a.png\n", "locked-at 1 2026-10-01T08:00:00Z\n", "ownername 1 bob\n", "owner 1 theirs\n",
+				"0000",
+			},
+		},
+		{
+			name:      "unlock owned by another user",
+			operation: basicOperationUpload,
+			request:   []string{"unlock 1\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				return mockStore
+			},
+			expLines: []string{"status 403\n", "0001", "Lock is owned by another user\n", "0000"},
+		},
+		{
+			name:      "force unlock",
+			operation: basicOperationUpload,
+			request:   []string{"unlock 1\n", "force=true\n", "0000"},
+			mockStore: func() *MockStore {
+				mockStore := newLockTestStore()
+				mockStore.GetLFSLockByIDFunc.SetDefaultReturn(&database.LFSLock{ID: 1, OwnerID: 2, Path: "a.png", CreatedAt: lockCreatedAt}, nil)
+				mockStore.AuthorizeRepositoryAccessFunc.SetDefaultReturn(true)
+				return mockStore
+			},
+			expLines: []string{
+				"status 200\n",
+				"id=1\n", "path=a.png\n", "locked-at=2026-10-01T08:00:00Z\n", "ownername=bob\n",
+				"0000",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockStore := NewMockStore()
+			if test.mockStore != nil {
+				mockStore = test.mockStore()
+			}
+			s := test.storage
+			if s == nil {
+				s = &mockStorage{}
+			}
+
+			lines := serveSSHTransferTest(t, mockStore, s, test.operation, test.request...)
+			assert.Equal(t, test.expLines, lines)
+		})
+	}
+}
+
+func TestSSHTransfer_putObject(t *testing.T) {
+	const oid = lfsx.OID("ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f")
+
+	mockStore := NewMockStore()
+	mockStore.GetLFSObjectByOIDFunc.SetDefaultReturn(nil, database.ErrLFSObjectNotExist{})
+	s := &mockStorage{buf: &bytes.Buffer{}}
+
+	content := strings.Repeat("x", maxPktDataLen+10)
+	lines := serveSSHTransferTest(t, mockStore, s, basicOperationUpload,
+		"put-object "+string(oid)+"\n", "size="+strconv.Itoa(len(content))+"\n", "0001",
+		content[:maxPktDataLen], content[maxPktDataLen:], "0000",
+	)
+	assert.Equal(t, []string{"status 200\n", "0000"}, lines)
+	assert.Equal(t, content, s.buf.String())
+
+	calls := mockStore.CreateLFSObjectFunc.History()
+	require.Len(t, calls, 1)
+	assert.Equal(t, oid, calls[0].Arg2)
+	assert.Equal(t, int64(len(content)), calls[0].Arg3)
+}
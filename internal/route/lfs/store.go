@@ -27,6 +27,22 @@ type Store interface {
 	// list could have fewer elements if some oids were not found.
 	GetLFSObjectsByOIDs(ctx context.Context, repoID int64, oids ...lfsx.OID) ([]*database.LFSObject, error)
 
+	// CreateLFSLock creates a new lock of the path in the repository held by the
+	// owner. It returns database.ErrLFSLockAlreadyExist when the path is already
+	// locked.
+	CreateLFSLock(ctx context.Context, repoID, ownerID int64, path string) (*database.LFSLock, error)
+	// GetLFSLockByID returns the lock with given ID in the repository. It returns
+	// database.ErrLFSLockNotExist when not found.
+	GetLFSLockByID(ctx context.Context, repoID, id int64) (*database.LFSLock, error)
+	// GetLFSLockByPath returns the lock of the path in the repository. It returns
+	// database.ErrLFSLockNotExist when not found.
+	GetLFSLockByPath(ctx context.Context, repoID int64, path string) (*database.LFSLock, error)
+	// ListLFSLocks returns locks in the repository that satisfy given options in
+	// the order of their IDs.
+	ListLFSLocks(ctx context.Context, repoID int64, opts database.ListLFSLocksOptions) ([]*database.LFSLock, error)
+	// DeleteLFSLockByID deletes the lock with given ID in the repository.
+	DeleteLFSLockByID(ctx context.Context, repoID, id int64) error
+
 	// AuthorizeRepositoryAccess returns true if the user has as good as desired
 	// access mode to the repository.
 	AuthorizeRepositoryAccess(ctx context.Context, userID, repoID int64, desired database.AccessMode, opts database.AccessModeOptions) bool
@@ -35,7 +51,8 @@ type Store interface {
 	// returns database.ErrRepoNotExist when not found.
 	GetRepositoryByName(ctx context.Context, ownerID int64, name string) (*database.Repository, error)
 
-	// IsTwoFactorEnabled returns true if the user has enabled 2FA.
+	// IsTwoFactorEnabled returns true if the user has enabled 2FA, which is
+	// either a TOTP token or WebAuthn credentials.
 	IsTwoFactorEnabled(ctx context.Context, userID int64) bool
 
 	// GetUserByID returns the user with given ID. It returns
@@ -92,6 +109,26 @@ func (*store) GetLFSObjectsByOIDs(ctx context.Context, repoID int64, oids ...lfs
 	return database.Handle.LFS().GetObjectsByOIDs(ctx, repoID, oids...)
 }
 
+func (*store) CreateLFSLock(ctx context.Context, repoID, ownerID int64, path string) (*database.LFSLock, error) {
+	return database.Handle.LFS().CreateLock(ctx, repoID, ownerID, path)
+}
+
+func (*store) GetLFSLockByID(ctx context.Context, repoID, id int64) (*database.LFSLock, error) {
+	return database.Handle.LFS().GetLockByID(ctx, repoID, id)
+}
+
+func (*store) GetLFSLockByPath(ctx context.Context, repoID int64, path string) (*database.LFSLock, error) {
+	return database.Handle.LFS().GetLockByPath(ctx, repoID, path)
+}
+
+func (*store) ListLFSLocks(ctx context.Context, repoID int64, opts database.ListLFSLocksOptions) ([]*database.LFSLock, error) {
+	return database.Handle.LFS().ListLocks(ctx, repoID, opts)
+}
+
+func (*store) DeleteLFSLockByID(ctx context.Context, repoID, id int64) error {
+	return database.Handle.LFS().DeleteLockByID(ctx, repoID, id)
+}
+
 func (*store) AuthorizeRepositoryAccess(ctx context.Context, userID, repoID int64, desired database.AccessMode, opts database.AccessModeOptions) bool {
 	return database.Handle.Permissions().Authorize(ctx, userID, repoID, desired, opts)
 }
@@ -101,7 +138,8 @@ func (*store) GetRepositoryByName(ctx context.Context, ownerID int64, name strin
 }
 
 func (*store) IsTwoFactorEnabled(ctx context.Context, userID int64) bool {
-	return database.Handle.TwoFactors().IsEnabled(ctx, userID)
+	return database.Handle.TwoFactors().IsEnabled(ctx, userID) ||
+		database.Handle.WebAuthnCredentials().IsEnabled(ctx, userID)
 }
 
 func (*store) GetUserByID(ctx context.Context, id int64) (*database.User, error) {
@@ -1,6 +1,7 @@
 package org
 
 import (
+	"fmt"
 	"strconv"
 
 	log "unknwon.dev/clog/v2"
@@ -80,6 +81,11 @@ func MembersAction(c *context.Context) {
 		return
 	}
 
+	switch c.Params(":action") {
+	case "remove", "leave":
+		c.AuditLog(database.AuditActionOrgMemberRemove, database.AuditTargetOfUser(org), fmt.Sprintf("user ID %d", uid))
+	}
+
 	if c.Params(":action") != "leave" {
 		c.Redirect(c.Org.OrgLink + "/members")
 	} else {
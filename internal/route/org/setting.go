@@ -118,6 +118,7 @@ func SettingsDelete(c *context.Context) {
 			}
 		} else {
 			log.Trace("Organization deleted: %s", org.Name)
+			c.AuditLog(database.AuditActionOrgDelete, database.AuditTargetOfUser(org), "")
 			c.Redirect(conf.Server.Subpath + "/")
 		}
 		return
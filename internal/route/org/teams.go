@@ -1,6 +1,7 @@
 package org
 
 import (
+	"fmt"
 	"net/http"
 	"path"
 	"strconv"
@@ -43,7 +44,11 @@ func TeamsAction(c *context.Context) {
 	}
 
 	page := c.Query("page")
-	var err error
+	var (
+		err         error
+		auditAction database.AuditAction
+		auditUser   string
+	)
 	switch c.Params(":action") {
 	case "join":
 		if !c.Org.IsOwner {
@@ -51,14 +56,17 @@ func TeamsAction(c *context.Context) {
 			return
 		}
 		err = c.Org.Team.AddMember(c.User.ID)
+		auditAction, auditUser = database.AuditActionOrgTeamMemberAdd, c.User.Name
 	case "leave":
 		err = c.Org.Team.RemoveMember(c.User.ID)
+		auditAction, auditUser = database.AuditActionOrgTeamMemberRemove, c.User.Name
 	case "remove":
 		if !c.Org.IsOwner {
 			c.NotFound()
 			return
 		}
 		err = c.Org.Team.RemoveMember(uid)
+		auditAction, auditUser = database.AuditActionOrgTeamMemberRemove, fmt.Sprintf("ID %d", uid)
 		page = "team"
 	case "add":
 		if !c.Org.IsOwner {
@@ -79,6 +87,7 @@ func TeamsAction(c *context.Context) {
 		}
 
 		err = c.Org.Team.AddMember(u.ID)
+		auditAction, auditUser = database.AuditActionOrgTeamMemberAdd, u.Name
 		page = "team"
 	}
 
@@ -93,6 +102,8 @@ func TeamsAction(c *context.Context) {
 			})
 			return
 		}
+	} else if auditAction != "" {
+		c.AuditLog(auditAction, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "user "+auditUser)
 	}
 
 	switch page {
@@ -126,9 +137,15 @@ func TeamsRepoAction(c *context.Context) {
 			return
 		}
 		err = c.Org.Team.AddRepository(repo)
+		if err == nil {
+			c.AuditLog(database.AuditActionOrgTeamRepoAdd, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "repository "+repo.Name)
+		}
 	case "remove":
 		repoID, _ := strconv.ParseInt(c.Query("repoid"), 10, 64)
 		err = c.Org.Team.RemoveRepository(repoID)
+		if err == nil {
+			c.AuditLog(database.AuditActionOrgTeamRepoRemove, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), fmt.Sprintf("repository ID %d", repoID))
+		}
 	}
 
 	if err != nil {
@@ -177,6 +194,7 @@ func NewTeamPost(c *context.Context, f form.CreateTeam) {
 		return
 	}
 	log.Trace("Team created: %s/%s", c.Org.Organization.Name, t.Name)
+	c.AuditLog(database.AuditActionOrgTeamCreate, database.AuditTargetOfTeam(c.Org.Organization, t), "permission "+t.Authorize.String())
 	c.Redirect(c.Org.OrgLink + "/teams/" + t.LowerName)
 }
 
@@ -252,6 +270,7 @@ func EditTeamPost(c *context.Context, f form.CreateTeam) {
 		}
 		return
 	}
+	c.AuditLog(database.AuditActionOrgTeamUpdate, database.AuditTargetOfTeam(c.Org.Organization, t), "permission "+t.Authorize.String())
 	c.Redirect(c.Org.OrgLink + "/teams/" + t.LowerName)
 }
 
@@ -259,6 +278,7 @@ func DeleteTeam(c *context.Context) {
 	if err := database.DeleteTeam(c.Org.Team); err != nil {
 		c.Flash.Error("DeleteTeam: " + err.Error())
 	} else {
+		c.AuditLog(database.AuditActionOrgTeamDelete, database.AuditTargetOfTeam(c.Org.Organization, c.Org.Team), "")
 		c.Flash.Success(c.Tr("org.teams.delete_team_success"))
 	}
 
@@ -22,6 +22,9 @@ type Branch struct {
 	Name        string
 	Commit      *git.Commit
 	IsProtected bool
+	// CommitStatus is the combined state of commit statuses of the latest
+	// commit, empty when no status has been reported.
+	CommitStatus database.CommitStatusState
 }
 
 func loadBranches(c *context.Context) []*Branch {
@@ -50,6 +53,15 @@ func loadBranches(c *context.Context) []*Branch {
 			Commit: commit,
 		}
 
+		statuses, err := database.Handle.CommitStatuses().ListLatestBySHA(c.Req.Context(), c.Repo.Repository.ID, commit.ID.String())
+		if err != nil {
+			c.Error(err, "list latest commit statuses")
+			return nil
+		}
+		if len(statuses) > 0 {
+			branches[i].CommitStatus = database.CombinedCommitStatusState(statuses)
+		}
+
 		for j := range protectBranches {
 			if branches[i].Name == protectBranches[j].Name {
 				branches[i].IsProtected = true
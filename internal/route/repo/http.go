@@ -143,38 +143,58 @@ func HTTPContexter(store Store) macaron.Handler {
 			return
 		}
 
-		authUser, err := store.AuthenticateUser(c.Req.Context(), authUsername, authPassword, -1)
-		if err != nil && !auth.IsErrBadCredentials(err) {
-			c.Status(http.StatusInternalServerError)
-			log.Error("Failed to authenticate user [name: %s]: %v", authUsername, err)
-			return
+		// Password attempts are rejected while throttled, but access tokens are still
+		// accepted.
+		var authUser *database.User
+		wait := context.ReserveLoginAttempt(c.Req.Request, authUsername)
+		if wait <= 0 {
+			authUser, err = store.AuthenticateUser(c.Req.Context(), authUsername, authPassword, -1)
+			if err != nil && !auth.IsErrBadCredentials(err) {
+				context.ReleaseLoginAttempt(c.Req.Request, authUsername)
+				c.Status(http.StatusInternalServerError)
+				log.Error("Failed to authenticate user [name: %s]: %v", authUsername, err)
+				return
+			}
 		}
 
 		// If username and password combination failed, try again using either username
 		// or password as the token.
+		var accessToken *database.AccessToken
 		if authUser == nil {
-			authUser, err = context.AuthenticateByToken(store, c.Req.Context(), authUsername)
+			authUser, accessToken, err = context.AuthenticateByToken(store, c.Req.Context(), authUsername)
 			if err != nil && !database.IsErrAccessTokenNotExist(err) {
 				c.Status(http.StatusInternalServerError)
 				log.Error("Failed to authenticate by access token via username: %v", err)
 				return
 			} else if database.IsErrAccessTokenNotExist(err) {
 				// Try again using the password field as the token.
-				authUser, err = context.AuthenticateByToken(store, c.Req.Context(), authPassword)
+				authUser, accessToken, err = context.AuthenticateByToken(store, c.Req.Context(), authPassword)
 				if err != nil {
-					if database.IsErrAccessTokenNotExist(err) {
-						askCredentials(c, http.StatusUnauthorized, "")
-					} else {
+					if !database.IsErrAccessTokenNotExist(err) {
 						c.Status(http.StatusInternalServerError)
 						log.Error("Failed to authenticate by access token via password: %v", err)
+					} else if wait > 0 {
+						context.SetRetryAfter(c.Header(), wait)
+						c.Error(http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+					} else {
+						context.AuditLoginFailed(c.Req.Request, 0, authUsername, "bad credentials over Git HTTP")
+						askCredentials(c, http.StatusUnauthorized, "")
 					}
 					return
 				}
 			}
+
+			// The password attempt is not a failure when the token is sent in its place.
+			if wait <= 0 {
+				context.ReleaseLoginAttempt(c.Req.Request, authUsername)
+			}
 		} else if store.IsTwoFactorEnabled(c.Req.Context(), authUser.ID) {
+			context.ReleaseLoginAttempt(c.Req.Request, authUsername)
 			askCredentials(c, http.StatusUnauthorized, `User with two-factor authentication enabled cannot perform HTTP/HTTPS operations via plain username and password
 Please create and use personal access token on user settings page`)
 			return
+		} else {
+			context.ResetLoginFailures(c.Req.Request, authUsername)
 		}
 
 		log.Trace("[Git] Authenticated user: %s", authUser.Name)
@@ -183,6 +203,10 @@ Please create and use personal access token on user settings page`)
 		if isPull {
 			mode = database.AccessModeRead
 		}
+		if accessToken != nil && accessToken.MaxRepoAccessMode(repo.ID, repo.IsPrivate) < mode {
+			askCredentials(c, http.StatusForbidden, "Access token does not have the required scope for the repository")
+			return
+		}
 		if !database.Handle.Permissions().Authorize(c.Req.Context(), authUser.ID, repo.ID, mode,
 			database.AccessModeOptions{
 				OwnerID: repo.OwnerID,
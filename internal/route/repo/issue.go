@@ -537,7 +537,19 @@ func viewIssue(c *context.Context, isPullList bool) {
 			c.Data["DisableStatusChange"] = issue.PullRequest.HasMerged
 			PrepareMergedViewPullInfo(c, issue)
 		} else {
-			PrepareViewPullInfo(c, issue)
+			prMeta := PrepareViewPullInfo(c, issue)
+			var headCommitID string
+			if prMeta != nil && len(prMeta.Commits) > 0 {
+				headCommitID = prMeta.Commits[0].ID.String()
+				c.Data["HeadCommitID"] = headCommitID
+				prepareCommitStatuses(c, headCommitID)
+			}
+			if !c.Written() && prMeta != nil {
+				prepareMergeStyles(c, issue, prMeta)
+			}
+			if !c.Written() && !issue.IsClosed {
+				prepareProtectBranchCheck(c, issue.PullRequest, headCommitID)
+			}
 		}
 		if c.Written() {
 			return
@@ -592,7 +604,9 @@ func viewIssue(c *context.Context, isPullList bool) {
 	// Render comments and fetch participants.
 	participants[0] = issue.Poster
 	for _, comment = range issue.Comments {
-		if comment.Type == database.CommentTypeComment {
+		if comment.Type == database.CommentTypeComment ||
+			comment.Type == database.CommentTypeReview ||
+			comment.Type == database.CommentTypeCode {
 			comment.RenderedContent = string(markup.Markdown(comment.Content, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
 
 			// Check tag.
@@ -629,6 +643,17 @@ func viewIssue(c *context.Context, isPullList bool) {
 		}
 	}
 
+	if issue.IsPull {
+		if err = database.LoadReviews(issue.Comments); err != nil {
+			c.Error(err, "load reviews")
+			return
+		}
+		prepareReviews(c, issue)
+		if c.Written() {
+			return
+		}
+	}
+
 	if issue.IsPull && issue.PullRequest.HasMerged {
 		pull := issue.PullRequest
 		branchProtected := false
@@ -246,6 +246,83 @@ func PrepareViewPullInfo(c *context.Context, issue *database.Issue) *gitx.PullRe
 	return prMeta
 }
 
+// prepareCommitStatuses loads the latest commit statuses of each context for
+// the commit with given SHA in the current repository.
+func prepareCommitStatuses(c *context.Context, sha string) {
+	statuses, err := database.Handle.CommitStatuses().ListLatestBySHA(c.Req.Context(), c.Repo.Repository.ID, sha)
+	if err != nil {
+		c.Error(err, "list latest commit statuses")
+		return
+	}
+	if len(statuses) == 0 {
+		return
+	}
+
+	c.Data["CommitStatuses"] = statuses
+	c.Data["CommitStatus"] = database.CombinedCommitStatusState(statuses)
+}
+
+// prepareProtectBranchCheck checks the pull request at the head commit against
+// the protection options of its base branch, and whether the current user can
+// override them.
+func prepareProtectBranchCheck(c *context.Context, pull *database.PullRequest, headCommitID string) {
+	check, err := pull.CheckProtectBranch(headCommitID)
+	if err != nil {
+		c.Error(err, "check protect branch")
+		return
+	} else if check == nil || check.Passed() {
+		return
+	}
+
+	c.Data["ProtectBranchCheck"] = check
+	c.Data["CanOverrideProtectBranch"] = c.IsLogged && check.CanOverride(c.User)
+}
+
+// prepareMergeStyles sets the merge styles allowed for the pull request, along
+// with the default squash commit message and whether the base branch can be
+// fast-forwarded to the head branch.
+func prepareMergeStyles(c *context.Context, issue *database.Issue, prMeta *gitx.PullRequestMeta) {
+	c.Data["MergeStyles"] = c.Repo.Repository.AllowedMergeStyles()
+
+	pull := issue.PullRequest
+	pull.Issue = issue
+	c.Data["SquashCommitMessage"] = pull.SquashCommitMessage(prMeta.Commits)
+
+	baseCommitID, err := c.Repo.GitRepo.BranchCommitID(pull.BaseBranch)
+	if err != nil {
+		c.Error(err, "get base branch commit ID")
+		return
+	}
+	c.Data["CanFastForward"] = prMeta.MergeBase == baseCommitID
+
+	if c.Repo.Repository.PullsEnableMergeQueue {
+		position, err := database.GetMergeQueuePosition(pull)
+		if err != nil {
+			c.Error(err, "get merge queue position")
+			return
+		}
+		c.Data["MergeQueuePosition"] = position
+	}
+
+	if pull.IsAutoMergeEnabled() {
+		autoMergeUser, err := database.Handle.Users().GetByID(c.Req.Context(), pull.AutoMergeUserID)
+		if err != nil && !database.IsErrUserNotExist(err) {
+			c.Error(err, "get auto-merge user")
+			return
+		} else if err != nil {
+			autoMergeUser = database.NewGhostUser()
+		}
+		c.Data["AutoMergeUser"] = autoMergeUser
+	} else if c.IsLogged {
+		canEnableAutoMerge, err := pull.CanBeMergedBy(c.User)
+		if err != nil {
+			c.Error(err, "check if can be merged by user")
+			return
+		}
+		c.Data["CanEnableAutoMerge"] = canEnableAutoMerge
+	}
+}
+
 func ViewPullCommits(c *context.Context) {
 	c.Data["PageIsPullList"] = true
 	c.Data["PageIsPullCommits"] = true
@@ -297,6 +374,11 @@ func ViewPullCommits(c *context.Context) {
 	c.Data["Commits"] = matchUsersWithCommitEmails(c.Req.Context(), commits)
 	c.Data["CommitsCount"] = len(commits)
 
+	prepareReviews(c, issue)
+	if c.Written() {
+		return
+	}
+
 	c.Success(tmplRepoPullsCommits)
 }
 
@@ -378,6 +460,15 @@ func ViewPullFiles(c *context.Context) {
 		return
 	}
 
+	prepareReviews(c, issue)
+	if c.Written() {
+		return
+	}
+	prepareCodeComments(c, issue, endCommitID)
+	if c.Written() {
+		return
+	}
+
 	c.Data["IsSplitStyle"] = c.Query("style") == "split"
 	c.Data["IsImageFile"] = commit.IsImageFile
 	c.Data["IsImageFileByIndex"] = commit.IsImageFileByIndex
@@ -398,6 +489,22 @@ func ViewPullFiles(c *context.Context) {
 	c.Success(tmplRepoPullsFiles)
 }
 
+// parseMergeForm returns the merge style, the commit description and the head
+// commit ID submitted by the merge form.
+func parseMergeForm(c *context.Context) (database.MergeStyle, string, string) {
+	mergeStyle := database.MergeStyle(c.Query("merge_style"))
+	if mergeStyle == "" {
+		if styles := c.Repo.Repository.AllowedMergeStyles(); len(styles) > 0 {
+			mergeStyle = styles[0]
+		}
+	}
+	commitDescription := c.Query("commit_description")
+	if mergeStyle == database.MergeStyleSquash {
+		commitDescription = c.Query("commit_message")
+	}
+	return mergeStyle, commitDescription, c.Query("head_commit_id")
+}
+
 func MergePullRequest(c *context.Context) {
 	issue := checkPullInfo(c)
 	if c.Written() {
@@ -419,10 +526,25 @@ func MergePullRequest(c *context.Context) {
 		return
 	}
 
+	mergeStyle, commitDescription, headCommitID := parseMergeForm(c)
 	pr.Issue = issue
 	pr.Issue.Repo = c.Repo.Repository
-	if err = pr.Merge(c.User, c.Repo.GitRepo, database.MergeStyle(c.Query("merge_style")), c.Query("commit_description")); err != nil {
-		c.Error(err, "merge")
+	if err = pr.Merge(c.User, c.Repo.GitRepo, mergeStyle, commitDescription, headCommitID, c.QueryBool("override_protection")); err != nil {
+		switch {
+		case database.IsErrPullRequestHasMerged(err):
+		case database.IsErrProtectBranchNotSatisfied(err):
+			c.Flash.Error(c.Tr("repo.pulls.protect_branch_not_satisfied"))
+		case database.IsErrHeadCommitChanged(err):
+			c.Flash.Error(c.Tr("repo.pulls.head_commit_changed"))
+		case database.IsErrMergeStyleNotAllowed(err):
+			c.Flash.Error(c.Tr("repo.pulls.merge_style_not_allowed"))
+		case database.IsErrNotFastForward(err):
+			c.Flash.Error(c.Tr("repo.pulls.cannot_fast_forward_desc"))
+		default:
+			c.Error(err, "merge")
+			return
+		}
+		c.Redirect(c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(pr.Index, 10))
 		return
 	}
 
@@ -430,6 +552,129 @@ func MergePullRequest(c *context.Context) {
 	c.Redirect(c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(pr.Index, 10))
 }
 
+func AddToMergeQueue(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	if issue.IsClosed || !c.Repo.Repository.PullsEnableMergeQueue {
+		c.NotFound()
+		return
+	}
+
+	pr := issue.PullRequest
+	if !pr.CanAutoMerge() || pr.HasMerged {
+		c.NotFound()
+		return
+	}
+
+	mergeStyle, commitDescription, headCommitID := parseMergeForm(c)
+	redirectTo := c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(pr.Index, 10)
+	pr.Issue = issue
+	check, err := pr.CheckProtectBranch(headCommitID)
+	if err != nil {
+		c.Error(err, "check protect branch")
+		return
+	} else if check != nil && !check.Passed() {
+		c.Flash.Error(c.Tr("repo.pulls.protect_branch_not_satisfied"))
+		c.Redirect(redirectTo)
+		return
+	}
+
+	if err = database.AddToMergeQueue(c.User, pr, mergeStyle, commitDescription, headCommitID); err != nil {
+		if database.IsErrMergeStyleNotAllowed(err) {
+			c.Flash.Error(c.Tr("repo.pulls.merge_style_not_allowed"))
+			c.Redirect(redirectTo)
+			return
+		}
+		c.Error(err, "add to merge queue")
+		return
+	}
+
+	log.Trace("Pull request added to merge queue: %d", pr.ID)
+	c.Flash.Success(c.Tr("repo.pulls.merge_queue_added"))
+	c.Redirect(redirectTo)
+}
+
+func EnableAutoMerge(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	pr := issue.PullRequest
+	if issue.IsClosed || pr.HasMerged {
+		c.NotFound()
+		return
+	}
+
+	// Posters of pull requests may also enable auto-merge when the base branch
+	// requires approvals.
+	pr.Issue = issue
+	canEnable, err := pr.CanBeMergedBy(c.User)
+	if err != nil {
+		c.Error(err, "check if can be merged by user")
+		return
+	} else if !canEnable {
+		c.NotFound()
+		return
+	}
+
+	redirectTo := c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(pr.Index, 10)
+	mergeStyle, _, _ := parseMergeForm(c)
+	if err = pr.EnableAutoMerge(c.User, mergeStyle); err != nil {
+		if database.IsErrMergeStyleNotAllowed(err) {
+			c.Flash.Error(c.Tr("repo.pulls.merge_style_not_allowed"))
+			c.Redirect(redirectTo)
+			return
+		}
+		c.Error(err, "enable auto-merge")
+		return
+	}
+
+	log.Trace("Auto-merge enabled: %d", pr.ID)
+	c.Flash.Success(c.Tr("repo.pulls.auto_merge_enabled"))
+	c.Redirect(redirectTo)
+}
+
+func CancelAutoMerge(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+
+	if !c.Repo.IsWriter() && !issue.IsPoster(c.User.ID) {
+		c.NotFound()
+		return
+	}
+
+	pr := issue.PullRequest
+	pr.Issue = issue
+	if err := pr.CancelAutoMerge(c.User, ""); err != nil {
+		c.Error(err, "cancel auto-merge")
+		return
+	}
+
+	log.Trace("Auto-merge canceled: %d", pr.ID)
+	c.Flash.Success(c.Tr("repo.pulls.auto_merge_canceled"))
+	c.Redirect(c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(pr.Index, 10))
+}
+
+func RemoveFromMergeQueue(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+
+	if err := database.RemoveFromMergeQueue(issue.PullRequest.ID); err != nil {
+		c.Error(err, "remove from merge queue")
+		return
+	}
+
+	log.Trace("Pull request removed from merge queue: %d", issue.PullRequest.ID)
+	c.Flash.Success(c.Tr("repo.pulls.merge_queue_removed"))
+	c.Redirect(c.Repo.RepoLink + "/pulls/" + strconv.FormatInt(issue.Index, 10))
+}
+
 func ParseCompareInfo(c *context.Context) (*database.User, *database.Repository, *git.Repository, *gitx.PullRequestMeta, string, string) {
 	baseRepo := c.Repo.Repository
 
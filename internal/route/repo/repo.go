@@ -205,6 +205,7 @@ func MigratePost(c *context.Context, f form.MigrateRepo) {
 		IsUnlisted:  f.Unlisted,
 		IsMirror:    f.Mirror,
 		RemoteAddr:  remoteAddr,
+		EnableLFS:   f.LFS,
 	})
 	if err == nil {
 		log.Trace("Repository migrated [%d]: %s/%s", repo.ID, ctxUser.Name, f.RepoName)
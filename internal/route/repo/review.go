@@ -0,0 +1,164 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/form"
+	"gogs.io/gogs/internal/markup"
+)
+
+// prepareReviews loads the review state of each reviewer of the pull request,
+// which is displayed in the header of all pull request pages.
+func prepareReviews(c *context.Context, issue *database.Issue) {
+	states, err := database.GetReviewerStates(issue.ID)
+	if err != nil {
+		c.Error(err, "get reviewer states")
+		return
+	}
+	c.Data["ReviewerStates"] = states
+	c.Data["CanRequestReview"] = !issue.IsClosed && (c.Repo.IsWriter() || (c.IsLogged && issue.IsPoster(c.User.ID)))
+}
+
+// prepareCodeComments loads code comments of the pull request for the diff,
+// including pending ones of the current user.
+func prepareCodeComments(c *context.Context, issue *database.Issue, commitID string) {
+	var viewerID int64
+	if c.IsLogged {
+		viewerID = c.User.ID
+	}
+	codeComments, err := database.GetCodeComments(issue.ID, viewerID)
+	if err != nil {
+		c.Error(err, "get code comments")
+		return
+	}
+
+	numPending := 0
+	for _, lines := range codeComments {
+		for _, comments := range lines {
+			for _, comment := range comments {
+				comment.RenderedContent = string(markup.Markdown(comment.Content, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
+				if comment.IsPending() {
+					numPending++
+				}
+			}
+		}
+	}
+	c.Data["CodeComments"] = codeComments
+	c.Data["NumPendingCodeComments"] = numPending
+	c.Data["CanReview"] = c.IsLogged && !issue.IsClosed
+	c.Data["ReviewCommitID"] = commitID
+}
+
+func CreateCodeComment(c *context.Context, f form.CreateCodeComment) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	if issue.IsClosed {
+		c.NotFound()
+		return
+	}
+
+	location := url.URL{
+		Path: fmt.Sprintf("pulls/%d/files", issue.Index),
+	}
+	if c.HasError() {
+		c.Flash.Error(c.Data["ErrorMsg"].(string))
+		c.RawRedirect(c.Repo.MakeURL(location))
+		return
+	}
+
+	comment, err := database.CreateCodeComment(c.User, c.Repo.Repository, issue, f.TreePath, f.Line, f.CommitID, f.Content)
+	if err != nil {
+		if database.IsErrReviewInvalid(err) {
+			c.Flash.Error(c.Tr("repo.pulls.review_invalid"))
+			c.RawRedirect(c.Repo.MakeURL(location))
+			return
+		}
+		c.Error(err, "create code comment")
+		return
+	}
+
+	log.Trace("Code comment created: %d/%d/%d", c.Repo.Repository.ID, issue.ID, comment.ID)
+	location.Fragment = comment.HashTag()
+	c.RawRedirect(c.Repo.MakeURL(location))
+}
+
+func SubmitReview(c *context.Context, f form.SubmitReview) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	if issue.IsClosed {
+		c.NotFound()
+		return
+	}
+
+	if c.HasError() {
+		c.Flash.Error(c.Data["ErrorMsg"].(string))
+		c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("pulls/%d/files", issue.Index)))
+		return
+	}
+
+	review, err := database.SubmitReview(c.User, c.Repo.Repository, issue, database.ReviewState(f.State), f.CommitID, f.Content)
+	if err != nil {
+		if database.IsErrReviewInvalid(err) {
+			c.Flash.Error(c.Tr("repo.pulls.review_invalid"))
+			c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("pulls/%d/files", issue.Index)))
+			return
+		}
+		c.Error(err, "submit review")
+		return
+	}
+
+	log.Trace("Review submitted: %d/%d/%d", c.Repo.Repository.ID, issue.ID, review.ID)
+	c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("pulls/%d", issue.Index)))
+}
+
+func RequestReview(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	if issue.IsClosed || !(c.Repo.IsWriter() || issue.IsPoster(c.User.ID)) {
+		c.NotFound()
+		return
+	}
+
+	redirectTo := c.Repo.MakeURL(fmt.Sprintf("pulls/%d", issue.Index))
+	reviewer, err := database.Handle.Users().GetByID(c.Req.Context(), c.QueryInt64("reviewer_id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get user by ID")
+		return
+	}
+
+	repo := c.Repo.Repository
+	if !database.Handle.Permissions().Authorize(c.Req.Context(), reviewer.ID, repo.ID, database.AccessModeRead,
+		database.AccessModeOptions{
+			OwnerID: repo.OwnerID,
+			Private: repo.IsPrivate,
+		},
+	) {
+		c.Flash.Error(c.Tr("repo.pulls.review_request_no_access", reviewer.Name))
+		c.RawRedirect(redirectTo)
+		return
+	}
+
+	if err = database.RequestReview(c.User, repo, issue, reviewer); err != nil {
+		if database.IsErrReviewInvalid(err) {
+			c.Flash.Error(c.Tr("repo.pulls.review_invalid"))
+			c.RawRedirect(redirectTo)
+			return
+		}
+		c.Error(err, "request review")
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.pulls.review_requested", reviewer.Name))
+	c.RawRedirect(redirectTo)
+}
@@ -31,6 +31,7 @@ const (
 	tmplRepoSettingsGithooks        = "repo/settings/githooks"
 	tmplRepoSettingsGithookEdit     = "repo/settings/githook_edit"
 	tmplRepoSettingsDeployKeys      = "repo/settings/deploy_keys"
+	tmplRepoSettingsPushMirrors     = "repo/settings/push_mirrors"
 )
 
 func Settings(c *context.Context) {
@@ -96,6 +97,9 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			return
 		}
 		log.Trace("Repository basic settings updated: %s/%s", c.Repo.Owner.Name, repo.Name)
+		if visibilityChanged {
+			c.AuditLog(database.AuditActionRepoVisibilityChange, database.AuditTargetOfRepository(repo), fmt.Sprintf("private: %t, unlisted: %t", repo.IsPrivate, repo.IsUnlisted))
+		}
 
 		if isNameChanged {
 			if err := database.Handle.Actions().RenameRepo(c.Req.Context(), c.User, repo.MustOwner(), oldRepoName, repo); err != nil {
@@ -114,6 +118,7 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 
 		if f.Interval > 0 {
 			c.Repo.Mirror.EnablePrune = f.EnablePrune
+			c.Repo.Mirror.EnableLFS = f.EnableLFS
 			c.Repo.Mirror.Interval = f.Interval
 			c.Repo.Mirror.NextSync = time.Now().Add(time.Duration(f.Interval) * time.Hour)
 			if err := database.UpdateMirror(c.Repo.Mirror); err != nil {
@@ -167,6 +172,12 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		c.Redirect(repo.Link() + "/settings")
 
 	case "advanced":
+		if f.EnablePulls && !(f.PullsAllowMergeCommit || f.PullsAllowRebase || f.PullsAllowSquash || f.PullsAllowFastForwardOnly) {
+			c.Flash.Error(c.Tr("repo.settings.pulls.no_merge_style"))
+			c.Redirect(c.Repo.RepoLink + "/settings")
+			return
+		}
+
 		repo.EnableWiki = f.EnableWiki
 		repo.AllowPublicWiki = f.AllowPublicWiki
 		repo.EnableExternalWiki = f.EnableExternalWiki
@@ -179,7 +190,11 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		repo.ExternalTrackerStyle = f.TrackerIssueStyle
 		repo.EnablePulls = f.EnablePulls
 		repo.PullsIgnoreWhitespace = f.PullsIgnoreWhitespace
+		repo.PullsAllowMergeCommit = f.PullsAllowMergeCommit
 		repo.PullsAllowRebase = f.PullsAllowRebase
+		repo.PullsAllowSquash = f.PullsAllowSquash
+		repo.PullsAllowFastForwardOnly = f.PullsAllowFastForwardOnly
+		repo.PullsEnableMergeQueue = f.PullsEnableMergeQueue
 
 		if !repo.EnableWiki || repo.EnableExternalWiki {
 			repo.AllowPublicWiki = false
@@ -254,6 +269,7 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			return
 		}
 
+		target := database.AuditTargetOfRepository(repo)
 		if err := database.TransferOwnership(c.User, newOwner, repo); err != nil {
 			if database.IsErrRepoAlreadyExist(err) {
 				c.RenderWithErr(c.Tr("repo.settings.new_owner_has_same_repo"), http.StatusUnprocessableEntity, tmplRepoSettingsOptions, nil)
@@ -263,6 +279,7 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			return
 		}
 		log.Trace("Repository transferred: %s/%s -> %s", c.Repo.Owner.Name, repo.Name, newOwner)
+		c.AuditLog(database.AuditActionRepoTransfer, target, "to "+newOwner)
 		c.Flash.Success(c.Tr("repo.settings.transfer_succeed"))
 		c.Redirect(conf.Server.Subpath + "/" + newOwner + "/" + repo.Name)
 
@@ -288,6 +305,7 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			return
 		}
 		log.Trace("Repository deleted: %s/%s", c.Repo.Owner.Name, repo.Name)
+		c.AuditLog(database.AuditActionRepoDelete, database.AuditTargetOfRepository(repo), "")
 
 		c.Flash.Success(c.Tr("repo.settings.deletion_success"))
 		c.Redirect(userx.DashboardURLPath(c.Repo.Owner.Name, c.Repo.Owner.IsOrganization()))
@@ -426,6 +444,7 @@ func SettingsCollaborationPost(c *context.Context) {
 		c.Error(err, "add collaborator")
 		return
 	}
+	c.AuditLog(database.AuditActionRepoCollaboratorAdd, database.AuditTargetOfRepository(c.Repo.Repository), "user "+u.Name)
 
 	if conf.User.EnableEmailNotification {
 		if err := email.SendCollaboratorMail(database.NewMailerUser(u), database.NewMailerUser(c.User), database.NewMailerRepo(c.Repo.Repository)); err != nil {
@@ -438,13 +457,15 @@ func SettingsCollaborationPost(c *context.Context) {
 }
 
 func ChangeCollaborationAccessMode(c *context.Context) {
+	mode := database.AccessMode(c.QueryInt("mode"))
 	if err := c.Repo.Repository.ChangeCollaborationAccessMode(
 		c.Repo.AccessMode,
 		c.QueryInt64("uid"),
-		database.AccessMode(c.QueryInt("mode"))); err != nil {
+		mode); err != nil {
 		log.Error("ChangeCollaborationAccessMode: %v", err)
 		return
 	}
+	c.AuditLog(database.AuditActionRepoCollaboratorModeChange, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("user ID %d, mode %s", c.QueryInt64("uid"), mode))
 
 	c.Status(204)
 }
@@ -453,6 +474,7 @@ func DeleteCollaboration(c *context.Context) {
 	if err := c.Repo.Repository.DeleteCollaboration(c.QueryInt64("id")); err != nil {
 		c.Flash.Error("DeleteCollaboration: " + err.Error())
 	} else {
+		c.AuditLog(database.AuditActionRepoCollaboratorRemove, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("user ID %d", c.QueryInt64("id")))
 		c.Flash.Success(c.Tr("repo.settings.remove_collaborator_success"))
 	}
 
@@ -580,6 +602,8 @@ func SettingsProtectedBranchPost(c *context.Context, f form.ProtectBranch) {
 
 	protectBranch.Protected = f.Protected
 	protectBranch.RequirePullRequest = f.RequirePullRequest
+	protectBranch.RequiredStatusContexts = f.RequiredStatusContexts
+	protectBranch.RequiredApprovals = max(f.RequiredApprovals, 0)
 	protectBranch.EnableWhitelist = f.EnableWhitelist
 	if c.Repo.Owner.IsOrganization() {
 		err = database.UpdateOrgProtectBranch(c.Repo.Repository, protectBranch, f.WhitelistUsers, f.WhitelistTeams)
@@ -590,11 +614,29 @@ func SettingsProtectedBranchPost(c *context.Context, f form.ProtectBranch) {
 		c.Error(err, "update protect branch")
 		return
 	}
+	c.AuditLog(database.AuditActionRepoProtectedBranchUpdate, database.AuditTargetOfRepository(c.Repo.Repository), protectBranchDetails(protectBranch))
 
 	c.Flash.Success(c.Tr("repo.settings.update_protect_branch_success"))
 	c.Redirect(fmt.Sprintf("%s/settings/branches/%s", c.Repo.RepoLink, branch))
 }
 
+// protectBranchDetails returns the description of the protect branch options
+// for the audit log.
+func protectBranchDetails(b *database.ProtectBranch) string {
+	details := fmt.Sprintf("branch %q, protected: %t", b.Name, b.Protected)
+	if !b.Protected {
+		return details
+	}
+	details += fmt.Sprintf(", require pull request: %t, required approvals: %d", b.RequirePullRequest, b.RequiredApprovals)
+	if contexts := b.RequiredContexts(); len(contexts) > 0 {
+		details += ", required status checks: " + strings.Join(contexts, " ")
+	}
+	if b.EnableWhitelist {
+		details += ", whitelist enabled"
+	}
+	return details
+}
+
 func SettingsGitHooks(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.settings.githooks")
 	c.Data["PageIsSettingsGitHooks"] = true
@@ -712,6 +754,7 @@ func SettingsDeployKeysPost(c *context.Context, f form.AddSSHKey) {
 	}
 
 	log.Trace("Deploy key added: %d", c.Repo.Repository.ID)
+	c.AuditLog(database.AuditActionRepoDeployKeyAdd, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("key %q (%s)", key.Name, key.Fingerprint))
 	c.Flash.Success(c.Tr("repo.settings.add_key_success", key.Name))
 	c.Redirect(c.Repo.RepoLink + "/settings/keys")
 }
@@ -720,6 +763,7 @@ func DeleteDeployKey(c *context.Context) {
 	if err := database.DeleteDeployKey(c.User, c.QueryInt64("id")); err != nil {
 		c.Flash.Error("DeleteDeployKey: " + err.Error())
 	} else {
+		c.AuditLog(database.AuditActionRepoDeployKeyDelete, database.AuditTargetOfRepository(c.Repo.Repository), fmt.Sprintf("deploy key ID %d", c.QueryInt64("id")))
 		c.Flash.Success(c.Tr("repo.settings.deploy_key_deletion_success"))
 	}
 
@@ -727,3 +771,86 @@ func DeleteDeployKey(c *context.Context) {
 		"redirect": c.Repo.RepoLink + "/settings/keys",
 	})
 }
+
+func SettingsPushMirrors(c *context.Context) {
+	c.Data["Title"] = c.Tr("repo.settings.push_mirrors")
+	c.Data["PageIsSettingsPushMirrors"] = true
+
+	mirrors, err := database.Handle.PushMirrors().List(c.Req.Context(), c.Repo.Repository.ID)
+	if err != nil {
+		c.Error(err, "list push mirrors")
+		return
+	}
+	c.Data["PushMirrors"] = mirrors
+
+	c.Success(tmplRepoSettingsPushMirrors)
+}
+
+func SettingsPushMirrorsPost(c *context.Context, f form.AddPushMirror) {
+	c.Data["Title"] = c.Tr("repo.settings.push_mirrors")
+	c.Data["PageIsSettingsPushMirrors"] = true
+
+	mirrors, err := database.Handle.PushMirrors().List(c.Req.Context(), c.Repo.Repository.ID)
+	if err != nil {
+		c.Error(err, "list push mirrors")
+		return
+	}
+	c.Data["PushMirrors"] = mirrors
+
+	if c.HasError() {
+		c.HTML(http.StatusBadRequest, tmplRepoSettingsPushMirrors)
+		return
+	}
+
+	m, err := database.Handle.PushMirrors().Create(c.Req.Context(), c.Repo.Repository.ID,
+		database.CreatePushMirrorOptions{
+			Address:      f.Address,
+			Username:     f.Username,
+			Password:     f.Password,
+			SyncInterval: f.Interval,
+		},
+	)
+	if err != nil {
+		if database.IsErrInvalidCloneAddr(err) {
+			c.Data["HasError"] = true
+			c.FormErr("Address")
+			if err.(database.ErrInvalidCloneAddr).IsBlockedLocalAddress {
+				c.RenderWithErr(c.Tr("repo.migrate.clone_address_resolved_to_blocked_local_address"), http.StatusForbidden, tmplRepoSettingsPushMirrors, &f)
+			} else {
+				c.RenderWithErr(c.Tr("repo.settings.push_mirror_address")+c.Tr("form.url_error"), http.StatusBadRequest, tmplRepoSettingsPushMirrors, &f)
+			}
+		} else {
+			c.Error(err, "create push mirror")
+		}
+		return
+	}
+
+	log.Trace("Push mirror added: %d", c.Repo.Repository.ID)
+	go database.PushMirrorQueue.Add(m.ID)
+	c.Flash.Success(c.Tr("repo.settings.add_push_mirror_success", m.Address))
+	c.Redirect(c.Repo.RepoLink + "/settings/push_mirrors")
+}
+
+func DeletePushMirror(c *context.Context) {
+	if err := database.Handle.PushMirrors().DeleteByID(c.Req.Context(), c.Repo.Repository.ID, c.QueryInt64("id")); err != nil {
+		c.Flash.Error("DeletePushMirror: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.push_mirror_deletion_success"))
+	}
+
+	c.JSONSuccess(map[string]any{
+		"redirect": c.Repo.RepoLink + "/settings/push_mirrors",
+	})
+}
+
+func SyncPushMirror(c *context.Context) {
+	m, err := database.Handle.PushMirrors().GetByID(c.Req.Context(), c.Repo.Repository.ID, c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get push mirror")
+		return
+	}
+
+	go database.PushMirrorQueue.Add(m.ID)
+	c.Flash.Info(c.Tr("repo.settings.push_mirror_sync_in_progress"))
+	c.Redirect(c.Repo.RepoLink + "/settings/push_mirrors")
+}
@@ -21,7 +21,8 @@ type Store interface {
 	// returns database.ErrRepoNotExist when not found.
 	GetRepositoryByName(ctx context.Context, ownerID int64, name string) (*database.Repository, error)
 
-	// IsTwoFactorEnabled returns true if the user has enabled 2FA.
+	// IsTwoFactorEnabled returns true if the user has enabled 2FA, which is
+	// either a TOTP token or WebAuthn credentials.
 	IsTwoFactorEnabled(ctx context.Context, userID int64) bool
 
 	// GetUserByID returns the user with given ID. It returns
@@ -71,7 +72,8 @@ func (*store) GetRepositoryByName(ctx context.Context, ownerID int64, name strin
 }
 
 func (*store) IsTwoFactorEnabled(ctx context.Context, userID int64) bool {
-	return database.Handle.TwoFactors().IsEnabled(ctx, userID)
+	return database.Handle.TwoFactors().IsEnabled(ctx, userID) ||
+		database.Handle.WebAuthnCredentials().IsEnabled(ctx, userID)
 }
 
 func (*store) GetUserByID(ctx context.Context, id int64) (*database.User, error) {
@@ -12,10 +12,11 @@ import (
 
 func TriggerTask(c *macaron.Context) {
 	branch := c.Query("branch")
+	commitID := c.Query("commit")
 	pusherID := c.QueryInt64("pusher")
 	secret := c.Query("secret")
-	if branch == "" || pusherID <= 0 || secret == "" {
-		c.Error(http.StatusBadRequest, "Incomplete branch, pusher or secret")
+	if branch == "" || commitID == "" || pusherID <= 0 || secret == "" {
+		c.Error(http.StatusBadRequest, "Incomplete branch, commit, pusher or secret")
 		return
 	}
 
@@ -62,9 +63,10 @@ func TriggerTask(c *macaron.Context) {
 		return
 	}
 
-	log.Trace("TriggerTask: %s/%s@%s by %q", owner.Name, repo.Name, branch, pusher.Name)
+	log.Trace("TriggerTask: %s/%s@%s(%s) by %q", owner.Name, repo.Name, branch, commitID, pusher.Name)
 
 	go database.HookQueue.Add(repo.ID)
-	go database.AddTestPullRequestTask(pusher, repo.ID, branch, true)
+	go database.AddTestPullRequestTask(pusher, repo.ID, branch, commitID, true)
+	go database.AddPushMirrorTasks(repo.ID)
 	c.Status(http.StatusAccepted)
 }
@@ -163,14 +163,16 @@ func toHookEvent(f form.Webhook) *database.HookEvent {
 		SendEverything: f.SendEverything(),
 		ChooseEvents:   f.ChooseEvents(),
 		HookEvents: database.HookEvents{
-			Create:       f.Create,
-			Delete:       f.Delete,
-			Fork:         f.Fork,
-			Push:         f.Push,
-			Issues:       f.Issues,
-			IssueComment: f.IssueComment,
-			PullRequest:  f.PullRequest,
-			Release:      f.Release,
+			Create:            f.Create,
+			Delete:            f.Delete,
+			Fork:              f.Fork,
+			Push:              f.Push,
+			Issues:            f.Issues,
+			IssueComment:      f.IssueComment,
+			PullRequest:       f.PullRequest,
+			PullRequestReview: f.PullRequestReview,
+			Release:           f.Release,
+			Status:            f.Status,
 		},
 	}
 }
@@ -564,6 +566,8 @@ func RedeliveryWebhook(c *context.Context) {
 	}
 
 	hookTask.IsDelivered = false
+	hookTask.Attempts = 0
+	hookTask.NextAttemptUnix = 0
 	if err = database.UpdateHookTask(hookTask); err != nil {
 		c.Error(err, "update hook task")
 		return
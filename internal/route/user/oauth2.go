@@ -0,0 +1,475 @@
+package user
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/macaron.v1"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/database"
+	"gogs.io/gogs/internal/form"
+	"gogs.io/gogs/internal/strx"
+)
+
+const (
+	tmplUserAuthOAuth2Authorize        = "user/auth/oauth2_authorize"
+	tmplUserSettingsOAuth2Applications = "user/settings/oauth2_applications"
+	tmplUserSettingsOAuth2Application  = "user/settings/oauth2_application"
+)
+
+// oauth2AuthorizeRequest is a validated authorization request, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.1.
+type oauth2AuthorizeRequest struct {
+	app *database.OAuth2Application
+	// The redirect URI to send the user back to.
+	redirectURI string
+	// The redirect URI as presented in the request, which may be omitted when
+	// the application has only one redirect URI. The application must present
+	// the same value when exchanging the code.
+	requestedRedirectURI string
+	scopes               []database.AccessTokenScope
+	state                string
+	codeChallenge        string
+}
+
+// redirect sends the user back to the application with given parameters.
+func (r *oauth2AuthorizeRequest) redirect(c *context.Context, params url.Values) {
+	// The redirect URI has been validated when the application was registered.
+	u, _ := url.Parse(r.redirectURI)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if r.state != "" {
+		q.Set("state", r.state)
+	}
+	u.RawQuery = q.Encode()
+	c.RawRedirect(u.String())
+}
+
+// redirectError sends the user back to the application with an error, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1.
+func (r *oauth2AuthorizeRequest) redirectError(c *context.Context, code, description string) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	r.redirect(c, params)
+}
+
+// renderOAuth2Error renders the error of an authorization request to the user
+// instead of the application, which is used when the application can't be
+// trusted to receive the error.
+func renderOAuth2Error(c *context.Context, msg string) {
+	c.Title("auth.oauth2_authorize_error")
+	c.Data["OAuth2Error"] = msg
+	c.HTML(http.StatusBadRequest, tmplUserAuthOAuth2Authorize)
+}
+
+// parseOAuth2AuthorizeRequest validates the authorization request. Errors
+// that happen before the application and the redirect URI are verified are
+// rendered to the user, and the rest are sent back to the application.
+func parseOAuth2AuthorizeRequest(c *context.Context) (_ *oauth2AuthorizeRequest, ok bool) {
+	app, err := database.Handle.OAuth2().GetApplicationByClientID(c.Req.Context(), c.Query("client_id"))
+	if err != nil {
+		if database.IsErrOAuth2ApplicationNotExist(err) {
+			renderOAuth2Error(c, c.Tr("auth.oauth2_invalid_client"))
+		} else {
+			c.Error(err, "get application by client ID")
+		}
+		return nil, false
+	}
+
+	req := &oauth2AuthorizeRequest{
+		app:                  app,
+		redirectURI:          c.Query("redirect_uri"),
+		requestedRedirectURI: c.Query("redirect_uri"),
+		state:                c.Query("state"),
+		codeChallenge:        c.Query("code_challenge"),
+	}
+	if redirectURIs := app.RedirectURIList(); req.redirectURI == "" && len(redirectURIs) == 1 {
+		req.redirectURI = redirectURIs[0]
+	}
+	if !app.HasRedirectURI(req.redirectURI) {
+		renderOAuth2Error(c, c.Tr("auth.oauth2_invalid_redirect_uri"))
+		return nil, false
+	}
+
+	if c.Query("response_type") != "code" {
+		req.redirectError(c, "unsupported_response_type", `Only the "code" response type is supported.`)
+		return nil, false
+	}
+
+	scope := c.Query("scope")
+	if scope == "" {
+		scope = string(database.AccessTokenScopeReadUser)
+	}
+	for _, field := range strings.Fields(scope) {
+		s := database.AccessTokenScope(field)
+		if !slices.Contains(database.AccessTokenScopes, s) {
+			req.redirectError(c, "invalid_scope", "Unknown scope "+strconv.Quote(field)+".")
+			return nil, false
+		}
+		if !slices.Contains(req.scopes, s) {
+			req.scopes = append(req.scopes, s)
+		}
+	}
+
+	// Public applications can't keep the client secret confidential, so they
+	// must prove that they are the one who started the flow with PKCE, see
+	// https://datatracker.ietf.org/doc/html/rfc7636.
+	switch {
+	case req.codeChallenge == "" && !app.Confidential:
+		req.redirectError(c, "invalid_request", "PKCE is required for public applications.")
+		return nil, false
+	case req.codeChallenge != "" && c.Query("code_challenge_method") != "S256":
+		req.redirectError(c, "invalid_request", `Only the "S256" code challenge method is supported.`)
+		return nil, false
+	case req.codeChallenge != "" && len(req.codeChallenge) != 43:
+		req.redirectError(c, "invalid_request", "Malformed code challenge.")
+		return nil, false
+	}
+	return req, true
+}
+
+// issueOAuth2AuthorizationCode grants the requested scopes to the application
+// and sends the user back to the application with an authorization code.
+func issueOAuth2AuthorizationCode(c *context.Context, req *oauth2AuthorizeRequest) {
+	code, err := database.Handle.OAuth2().CreateAuthorizationCode(
+		c.Req.Context(),
+		c.User.ID,
+		req.app,
+		database.CreateOAuth2AuthorizationCodeOptions{
+			Scopes:        req.scopes,
+			RedirectURI:   req.requestedRedirectURI,
+			CodeChallenge: req.codeChallenge,
+		},
+	)
+	if err != nil {
+		c.Error(err, "create authorization code")
+		return
+	}
+	req.redirect(c, url.Values{"code": {code}})
+}
+
+// OAuth2Authorize asks the user for consent to grant the requested scopes to
+// the application. The consent is skipped when the user has already granted
+// all of the scopes.
+func OAuth2Authorize(c *context.Context) {
+	req, ok := parseOAuth2AuthorizeRequest(c)
+	if !ok {
+		return
+	}
+
+	grant, err := database.Handle.OAuth2().GetGrant(c.Req.Context(), c.User.ID, req.app.ID)
+	if err == nil && grant.HasScopes(req.scopes) {
+		issueOAuth2AuthorizationCode(c, req)
+		return
+	} else if err != nil && !database.IsErrOAuth2GrantNotExist(err) {
+		c.Error(err, "get grant")
+		return
+	}
+
+	owner, err := database.Handle.Users().GetByID(c.Req.Context(), req.app.UserID)
+	if err != nil {
+		c.Error(err, "get application owner")
+		return
+	}
+
+	// The consent token makes sure the consent is given on this page, rather
+	// than by a form submitted from elsewhere on behalf of the user.
+	consentToken, err := strx.RandomChars(40)
+	if err != nil {
+		c.Error(err, "generate consent token")
+		return
+	}
+	_ = c.Session.Set("oauth2ConsentToken", consentToken)
+
+	c.RawTitle(c.Tr("auth.oauth2_authorize", req.app.Name))
+	c.Data["Application"] = req.app
+	c.Data["Owner"] = owner
+	c.Data["Scopes"] = req.scopes
+	c.Data["ConsentToken"] = consentToken
+	c.Data["ClientID"] = req.app.ClientID
+	c.Data["RedirectURI"] = req.requestedRedirectURI
+	c.Data["RedirectHost"] = redirectHost(req.redirectURI)
+	c.Data["Scope"] = c.Query("scope")
+	c.Data["State"] = req.state
+	c.Data["CodeChallenge"] = req.codeChallenge
+	c.Data["CodeChallengeMethod"] = c.Query("code_challenge_method")
+
+	// Prevent clickjacking the consent.
+	c.Resp.Header().Set("X-Frame-Options", "DENY")
+	c.Success(tmplUserAuthOAuth2Authorize)
+}
+
+func redirectHost(redirectURI string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Host == "" {
+		return redirectURI
+	}
+	return u.Host
+}
+
+// OAuth2AuthorizePost handles the decision of the user on the consent page.
+func OAuth2AuthorizePost(c *context.Context) {
+	req, ok := parseOAuth2AuthorizeRequest(c)
+	if !ok {
+		return
+	}
+
+	consentToken, _ := c.Session.Get("oauth2ConsentToken").(string)
+	_ = c.Session.Delete("oauth2ConsentToken")
+	if consentToken == "" || subtle.ConstantTimeCompare([]byte(consentToken), []byte(c.Query("consent_token"))) != 1 {
+		renderOAuth2Error(c, c.Tr("auth.oauth2_consent_expired"))
+		return
+	}
+
+	if c.Query("authorize") != "1" {
+		req.redirectError(c, "access_denied", "The user denied the request.")
+		return
+	}
+	issueOAuth2AuthorizationCode(c, req)
+}
+
+// writeOAuth2JSON writes the response of the token endpoint, which must not be
+// cached, see https://datatracker.ietf.org/doc/html/rfc6749#section-5.1.
+func writeOAuth2JSON(c *macaron.Context, status int, v any) {
+	c.Resp.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	c.Resp.Header().Set("Cache-Control", "no-store")
+	c.Resp.Header().Set("Pragma", "no-cache")
+	c.Resp.WriteHeader(status)
+	err := json.NewEncoder(c.Resp).Encode(v)
+	if err != nil {
+		log.Error("Failed to encode OAuth2 response: %v", err)
+	}
+}
+
+// writeOAuth2Error writes an error response of the token endpoint, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2.
+func writeOAuth2Error(c *macaron.Context, status int, code, description string) {
+	resp := map[string]string{"error": code}
+	if description != "" {
+		resp["error_description"] = description
+	}
+	writeOAuth2JSON(c, status, resp)
+}
+
+// OAuth2AccessToken serves the token endpoint, where applications exchange
+// authorization codes and refresh tokens for access tokens, see
+// https://datatracker.ietf.org/doc/html/rfc6749#section-3.2.
+func OAuth2AccessToken(c *macaron.Context) {
+	clientID, clientSecret, isBasicAuth := c.Req.BasicAuth()
+	if isBasicAuth {
+		// Credentials in the header are form-encoded, see
+		// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1.
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = c.Req.PostFormValue("client_id")
+		clientSecret = c.Req.PostFormValue("client_secret")
+	}
+
+	invalidClient := func() {
+		if isBasicAuth {
+			c.Resp.Header().Set("WWW-Authenticate", `Basic realm="`+conf.App.BrandName+`"`)
+		}
+		writeOAuth2Error(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+	}
+
+	ctx := c.Req.Context()
+	app, err := database.Handle.OAuth2().GetApplicationByClientID(ctx, clientID)
+	if err != nil {
+		if database.IsErrOAuth2ApplicationNotExist(err) {
+			invalidClient()
+			return
+		}
+		log.Error("Failed to get OAuth2 application by client ID: %v", err)
+		writeOAuth2Error(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	// Public applications are not required to authenticate, but those which
+	// do must present the right secret.
+	if (app.Confidential || clientSecret != "") && !app.VerifyClientSecret(clientSecret) {
+		invalidClient()
+		return
+	}
+
+	var token *database.OAuth2Token
+	switch grantType := c.Req.PostFormValue("grant_type"); grantType {
+	case "authorization_code":
+		token, err = database.Handle.OAuth2().ExchangeAuthorizationCode(
+			ctx,
+			app,
+			c.Req.PostFormValue("code"),
+			c.Req.PostFormValue("redirect_uri"),
+			c.Req.PostFormValue("code_verifier"),
+		)
+	case "refresh_token":
+		token, err = database.Handle.OAuth2().RefreshToken(ctx, app, c.Req.PostFormValue("refresh_token"))
+	default:
+		writeOAuth2Error(c, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type "+strconv.Quote(grantType)+".")
+		return
+	}
+	if err != nil {
+		if database.IsErrInvalidOAuth2Grant(err) {
+			writeOAuth2Error(c, http.StatusBadRequest, "invalid_grant", "The grant is invalid, expired or revoked.")
+			return
+		}
+		log.Error("Failed to issue OAuth2 token: %v", err)
+		writeOAuth2Error(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	writeOAuth2JSON(c, http.StatusOK, map[string]any{
+		"access_token":  token.AccessToken,
+		"token_type":    "bearer",
+		"expires_in":    int64(token.ExpiresIn.Seconds()),
+		"refresh_token": token.RefreshToken,
+		"scope":         token.Scopes,
+	})
+}
+
+func SettingsOAuth2Applications(c *context.Context) {
+	c.Title("settings.oauth2_applications")
+	c.PageIs("SettingsOAuth2Applications")
+
+	apps, err := database.Handle.OAuth2().ListApplications(c.Req.Context(), c.User.ID)
+	if err != nil {
+		c.Errorf(err, "list applications")
+		return
+	}
+	c.Data["Applications"] = apps
+
+	c.Success(tmplUserSettingsOAuth2Applications)
+}
+
+func SettingsOAuth2ApplicationsPost(c *context.Context, f form.OAuth2Application) {
+	c.Title("settings.oauth2_applications")
+	c.PageIs("SettingsOAuth2Applications")
+
+	apps, err := database.Handle.OAuth2().ListApplications(c.Req.Context(), c.User.ID)
+	if err != nil {
+		c.Errorf(err, "list applications")
+		return
+	}
+	c.Data["Applications"] = apps
+
+	if c.HasError() {
+		c.HTML(http.StatusBadRequest, tmplUserSettingsOAuth2Applications)
+		return
+	}
+
+	app, clientSecret, err := database.Handle.OAuth2().CreateApplication(
+		c.Req.Context(),
+		c.User.ID,
+		database.CreateOAuth2ApplicationOptions{
+			Name:         f.Name,
+			RedirectURIs: strings.Fields(f.RedirectURIs),
+			Confidential: f.Confidential,
+		},
+	)
+	if err != nil {
+		if database.IsErrInvalidOAuth2Application(err) {
+			c.Data["Err_RedirectURIs"] = true
+			c.RenderWithErr(c.Tr("settings.oauth2_invalid_redirect_uris"), http.StatusBadRequest, tmplUserSettingsOAuth2Applications, &f)
+		} else {
+			c.Errorf(err, "create application")
+		}
+		return
+	}
+
+	c.Flash.Success(c.Tr("settings.oauth2_application_created"))
+	c.Flash.Info(clientSecret)
+	c.RedirectSubpath("/user/settings/oauth2/" + strconv.FormatInt(app.ID, 10))
+}
+
+func SettingsOAuth2Application(c *context.Context) {
+	c.Title("settings.oauth2_applications")
+	c.PageIs("SettingsOAuth2Applications")
+
+	app, err := database.Handle.OAuth2().GetApplicationByID(c.Req.Context(), c.User.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get application")
+		return
+	}
+	c.Data["Application"] = app
+	c.Data["name"] = app.Name
+	c.Data["redirect_uris"] = app.RedirectURIs
+	c.Data["confidential"] = app.Confidential
+
+	c.Success(tmplUserSettingsOAuth2Application)
+}
+
+func SettingsOAuth2ApplicationPost(c *context.Context, f form.OAuth2Application) {
+	c.Title("settings.oauth2_applications")
+	c.PageIs("SettingsOAuth2Applications")
+
+	app, err := database.Handle.OAuth2().GetApplicationByID(c.Req.Context(), c.User.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrError(err, "get application")
+		return
+	}
+	c.Data["Application"] = app
+
+	if c.HasError() {
+		c.HTML(http.StatusBadRequest, tmplUserSettingsOAuth2Application)
+		return
+	}
+
+	err = database.Handle.OAuth2().UpdateApplication(
+		c.Req.Context(),
+		c.User.ID,
+		app.ID,
+		database.UpdateOAuth2ApplicationOptions{
+			Name:         f.Name,
+			RedirectURIs: strings.Fields(f.RedirectURIs),
+			Confidential: f.Confidential,
+		},
+	)
+	if err != nil {
+		if database.IsErrInvalidOAuth2Application(err) {
+			c.Data["Err_RedirectURIs"] = true
+			c.RenderWithErr(c.Tr("settings.oauth2_invalid_redirect_uris"), http.StatusBadRequest, tmplUserSettingsOAuth2Application, &f)
+		} else {
+			c.Errorf(err, "update application")
+		}
+		return
+	}
+
+	c.Flash.Success(c.Tr("settings.oauth2_application_updated"))
+	c.RedirectSubpath("/user/settings/oauth2/" + strconv.FormatInt(app.ID, 10))
+}
+
+func SettingsOAuth2ApplicationRegenerateSecret(c *context.Context) {
+	id := c.ParamsInt64(":id")
+	clientSecret, err := database.Handle.OAuth2().RegenerateClientSecret(c.Req.Context(), c.User.ID, id)
+	if err != nil {
+		c.NotFoundOrError(err, "regenerate client secret")
+		return
+	}
+
+	c.Flash.Success(c.Tr("settings.oauth2_client_secret_regenerated"))
+	c.Flash.Info(clientSecret)
+	c.RedirectSubpath("/user/settings/oauth2/" + strconv.FormatInt(id, 10))
+}
+
+func SettingsDeleteOAuth2Application(c *context.Context) {
+	if err := database.Handle.OAuth2().DeleteApplication(c.Req.Context(), c.User.ID, c.QueryInt64("id")); err != nil {
+		c.Flash.Error("DeleteApplication: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("settings.oauth2_application_deleted"))
+	}
+
+	c.JSONSuccess(map[string]any{
+		"redirect": conf.Server.Subpath + "/user/settings/oauth2",
+	})
+}
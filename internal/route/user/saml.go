@@ -0,0 +1,27 @@
+package user
+
+import (
+	"net/http"
+
+	"gopkg.in/macaron.v1"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+const tmplUserAuthSAMLPost = "user/auth/saml_post"
+
+// SAMLAssertionConsumerService relays the SAML response that the identity
+// provider posts cross-site to the web API within the same site. The session
+// cookie is only sent along with same-site POST requests, so the request
+// tracked in the session can only be matched by the relayed request.
+func SAMLAssertionConsumerService(c *macaron.Context) {
+	c.Req.Request.Body = http.MaxBytesReader(c.Resp, c.Req.Request.Body, 2*1024*1024) // 2 MiB
+	if err := c.Req.ParseForm(); err != nil {
+		http.Error(c.Resp, "Failed to parse form.", http.StatusBadRequest)
+		return
+	}
+
+	c.Data["Action"] = conf.Server.Subpath + "/api/web/user/saml/" + c.Params(":id") + "/acs"
+	c.Data["SAMLResponse"] = c.Req.PostFormValue("SAMLResponse")
+	c.HTML(http.StatusOK, tmplUserAuthSAMLPost)
+}
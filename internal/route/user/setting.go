@@ -11,6 +11,9 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/cockroachdb/errors"
 	"github.com/pquerna/otp"
@@ -48,6 +51,7 @@ const (
 	tmplUserSettingsEmail                  = "user/settings/email"
 	tmplUserSettingsSSHKeys                = "user/settings/sshkeys"
 	tmplUserSettingsSecurity               = "user/settings/security"
+	tmplUserSettingsSessions               = "user/settings/sessions"
 	tmplUserSettingsTwoFactorEnable        = "user/settings/two_factor_enable"
 	tmplUserSettingsTwoFactorRecoveryCodes = "user/settings/two_factor_recovery_codes"
 	tmplUserSettingsRepositories           = "user/settings/repositories"
@@ -222,6 +226,11 @@ func SettingsPasswordPost(c *context.Context, f form.ChangePassword) {
 			c.Errorf(err, "update user")
 			return
 		}
+		if err = c.RevokeOtherLoginSessions(); err != nil {
+			c.Errorf(err, "revoke other login sessions")
+			return
+		}
+		c.AuditLog(database.AuditActionUserPasswordChange, database.AuditTargetOfUser(c.User), "")
 		c.Flash.Success(c.Tr("settings.change_password_success"))
 	}
 
@@ -408,7 +417,8 @@ func SettingsSSHKeysPost(c *context.Context, f form.AddSSHKey) {
 		}
 	}
 
-	if _, err = database.AddPublicKey(c.User.ID, f.Title, content); err != nil {
+	key, err := database.AddPublicKey(c.User.ID, f.Title, content)
+	if err != nil {
 		c.Data["HasError"] = true
 		switch {
 		case database.IsErrKeyAlreadyExist(err):
@@ -423,6 +433,7 @@ func SettingsSSHKeysPost(c *context.Context, f form.AddSSHKey) {
 		return
 	}
 
+	c.AuditLog(database.AuditActionUserSSHKeyAdd, database.AuditTargetOfUser(c.User), fmt.Sprintf("key %q (%s)", key.Name, key.Fingerprint))
 	c.Flash.Success(c.Tr("settings.add_key_success", f.Title))
 	c.RedirectSubpath("/user/settings/ssh")
 }
@@ -431,6 +442,7 @@ func DeleteSSHKey(c *context.Context) {
 	if err := database.DeletePublicKey(c.User, c.QueryInt64("id")); err != nil {
 		c.Flash.Error("DeletePublicKey: " + err.Error())
 	} else {
+		c.AuditLog(database.AuditActionUserSSHKeyDelete, database.AuditTargetOfUser(c.User), fmt.Sprintf("key ID %d", c.QueryInt64("id")))
 		c.Flash.Success(c.Tr("settings.ssh_key_deletion_success"))
 	}
 
@@ -450,6 +462,13 @@ func SettingsSecurity(c *context.Context) {
 	}
 	c.Data["TwoFactor"] = t
 
+	creds, err := database.Handle.WebAuthnCredentials().List(c.Req.Context(), c.UserID())
+	if err != nil {
+		c.Errorf(err, "list WebAuthn credentials")
+		return
+	}
+	c.Data["WebAuthnCredentials"] = creds
+
 	c.Success(tmplUserSettingsSecurity)
 }
 
@@ -519,6 +538,11 @@ func SettingsTwoFactorEnablePost(c *context.Context) {
 
 	_ = c.Session.Delete("twoFactorSecret")
 	_ = c.Session.Delete("twoFactorURL")
+	if err := c.RevokeOtherLoginSessions(); err != nil {
+		c.Errorf(err, "revoke other login sessions")
+		return
+	}
+	c.AuditLog(database.AuditActionUserTwoFactorEnable, database.AuditTargetOfUser(c.User), "")
 	c.Flash.Success(c.Tr("settings.two_factor_enable_success"))
 	c.RedirectSubpath("/user/settings/security/two_factor_recovery_codes")
 }
@@ -551,6 +575,11 @@ func SettingsTwoFactorRecoveryCodesPost(c *context.Context) {
 	if err := database.RegenerateRecoveryCodes(c.UserID()); err != nil {
 		c.Flash.Error(c.Tr("settings.two_factor_regenerate_recovery_codes_error", err))
 	} else {
+		if err = c.RevokeOtherLoginSessions(); err != nil {
+			c.Errorf(err, "revoke other login sessions")
+			return
+		}
+		c.AuditLog(database.AuditActionUserRecoveryCodesRegenerate, database.AuditTargetOfUser(c.User), "")
 		c.Flash.Success(c.Tr("settings.two_factor_regenerate_recovery_codes_success"))
 	}
 
@@ -567,6 +596,11 @@ func SettingsTwoFactorDisable(c *context.Context) {
 		c.Errorf(err, "delete two factor")
 		return
 	}
+	if err := c.RevokeOtherLoginSessions(); err != nil {
+		c.Errorf(err, "revoke other login sessions")
+		return
+	}
+	c.AuditLog(database.AuditActionUserTwoFactorDisable, database.AuditTargetOfUser(c.User), "")
 
 	c.Flash.Success(c.Tr("settings.two_factor_disable_success"))
 	c.JSONSuccess(map[string]any{
@@ -574,6 +608,68 @@ func SettingsTwoFactorDisable(c *context.Context) {
 	})
 }
 
+func SettingsWebAuthnCredentialDelete(c *context.Context) {
+	if err := database.Handle.WebAuthnCredentials().DeleteByID(c.Req.Context(), c.UserID(), c.QueryInt64("id")); err != nil {
+		c.Errorf(err, "delete WebAuthn credential")
+		return
+	}
+	if err := c.RevokeOtherLoginSessions(); err != nil {
+		c.Errorf(err, "revoke other login sessions")
+		return
+	}
+	c.AuditLog(database.AuditActionUserWebAuthnDelete, database.AuditTargetOfUser(c.User), fmt.Sprintf("credential ID %d", c.QueryInt64("id")))
+
+	c.Flash.Success(c.Tr("settings.webauthn_credential_deleted"))
+	c.RedirectSubpath("/user/settings/security")
+}
+
+func SettingsSessions(c *context.Context) {
+	c.Title("settings.sessions")
+	c.PageIs("SettingsSessions")
+
+	sessions, err := database.Handle.LoginSessions().List(c.Req.Context(), c.User.ID)
+	if err != nil {
+		c.Errorf(err, "list login sessions")
+		return
+	}
+	c.Data["Sessions"] = sessions
+
+	current, err := c.CurrentLoginSession()
+	if err != nil {
+		c.Errorf(err, "get current login session")
+		return
+	}
+	var currentID int64
+	if current != nil {
+		currentID = current.ID
+	}
+	c.Data["CurrentSessionID"] = currentID
+
+	c.Success(tmplUserSettingsSessions)
+}
+
+func SettingsSessionRevoke(c *context.Context) {
+	if err := database.Handle.LoginSessions().DeleteByID(c.Req.Context(), c.User.ID, c.QueryInt64("id")); err != nil {
+		c.Errorf(err, "delete login session")
+		return
+	}
+	c.AuditLog(database.AuditActionUserSessionRevoke, database.AuditTargetOfUser(c.User), fmt.Sprintf("session ID %d", c.QueryInt64("id")))
+
+	c.Flash.Success(c.Tr("settings.session_revoked"))
+	c.RedirectSubpath("/user/settings/sessions")
+}
+
+func SettingsSessionsRevokeOthers(c *context.Context) {
+	if err := c.RevokeOtherLoginSessions(); err != nil {
+		c.Errorf(err, "revoke other login sessions")
+		return
+	}
+	c.AuditLog(database.AuditActionUserSessionRevoke, database.AuditTargetOfUser(c.User), "all other sessions")
+
+	c.Flash.Success(c.Tr("settings.other_sessions_revoked"))
+	c.RedirectSubpath("/user/settings/sessions")
+}
+
 func SettingsRepos(c *context.Context) {
 	c.Title("settings.repos")
 	c.PageIs("SettingsRepositories")
@@ -650,6 +746,14 @@ func (h *SettingsHandler) Applications() macaron.Handler {
 			return
 		}
 		c.Data["Tokens"] = tokens
+		c.Data["AccessTokenScopes"] = database.AccessTokenScopes
+
+		grants, err := h.store.ListOAuth2Grants(c.Req.Context(), c.User.ID)
+		if err != nil {
+			c.Errorf(err, "list OAuth2 grants")
+			return
+		}
+		c.Data["Grants"] = grants
 
 		c.Success(tmplUserSettingsApplications)
 	}
@@ -667,22 +771,50 @@ func (h *SettingsHandler) ApplicationsPost() macaron.Handler {
 				return
 			}
 
+			grants, err := h.store.ListOAuth2Grants(c.Req.Context(), c.User.ID)
+			if err != nil {
+				c.Errorf(err, "list OAuth2 grants")
+				return
+			}
+
 			c.Data["Tokens"] = tokens
+			c.Data["AccessTokenScopes"] = database.AccessTokenScopes
+			c.Data["Grants"] = grants
 			c.HTML(http.StatusBadRequest, tmplUserSettingsApplications)
 			return
 		}
 
-		t, err := h.store.CreateAccessToken(c.Req.Context(), c.User.ID, f.Name)
+		opts := database.CreateAccessTokenOptions{
+			Repositories: strings.FieldsFunc(f.Repositories, func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			}),
+		}
+		for _, scope := range f.Scopes {
+			opts.Scopes = append(opts.Scopes, database.AccessTokenScope(scope))
+		}
+		if f.ExpiresIn > 0 {
+			opts.Expires = time.Now().AddDate(0, 0, f.ExpiresIn)
+		}
+
+		t, err := h.store.CreateAccessToken(c.Req.Context(), c.User.ID, f.Name, opts)
 		if err != nil {
-			if database.IsErrAccessTokenAlreadyExist(err) {
+			switch {
+			case database.IsErrAccessTokenAlreadyExist(err):
 				c.Flash.Error(c.Tr("settings.token_name_exists"))
 				c.RedirectSubpath("/user/settings/applications")
-			} else {
+			case database.IsErrInvalidAccessTokenScope(err):
+				c.Flash.Error(c.Tr("settings.token_invalid_scope"))
+				c.RedirectSubpath("/user/settings/applications")
+			case database.IsErrRepoNotExist(err):
+				c.Flash.Error(c.Tr("settings.token_repo_not_exist"))
+				c.RedirectSubpath("/user/settings/applications")
+			default:
 				c.Errorf(err, "new access token")
 			}
 			return
 		}
 
+		c.AuditLog(database.AuditActionUserAccessTokenCreate, database.AuditTargetOfUser(c.User), database.AuditDetailsOfAccessToken(t))
 		c.Flash.Success(c.Tr("settings.generate_token_succees"))
 		c.Flash.Info(t.Sha1)
 		c.RedirectSubpath("/user/settings/applications")
@@ -694,6 +826,7 @@ func (h *SettingsHandler) DeleteApplication() macaron.Handler {
 		if err := h.store.DeleteAccessTokenByID(c.Req.Context(), c.User.ID, c.QueryInt64("id")); err != nil {
 			c.Flash.Error("DeleteAccessTokenByID: " + err.Error())
 		} else {
+			c.AuditLog(database.AuditActionUserAccessTokenDelete, database.AuditTargetOfUser(c.User), fmt.Sprintf("token ID %d", c.QueryInt64("id")))
 			c.Flash.Success(c.Tr("settings.delete_token_success"))
 		}
 
@@ -703,6 +836,19 @@ func (h *SettingsHandler) DeleteApplication() macaron.Handler {
 	}
 }
 
+func (h *SettingsHandler) RevokeOAuth2Grant() macaron.Handler {
+	return func(c *context.Context) {
+		if err := h.store.RevokeOAuth2Grant(c.Req.Context(), c.User.ID, c.QueryInt64("id")); err != nil {
+			c.Errorf(err, "revoke OAuth2 grant")
+			return
+		}
+		c.AuditLog(database.AuditActionUserOAuth2ApplicationRevoke, database.AuditTargetOfUser(c.User), fmt.Sprintf("grant ID %d", c.QueryInt64("id")))
+
+		c.Flash.Success(c.Tr("settings.oauth2_grant_revoked"))
+		c.RedirectSubpath("/user/settings/applications")
+	}
+}
+
 func SettingsDelete(c *context.Context) {
 	c.Title("settings.delete")
 	c.PageIs("SettingsDelete")
@@ -744,8 +890,10 @@ func SettingsDelete(c *context.Context) {
 type SettingsStore interface {
 	// CreateAccessToken creates a new access token and persist to database. It
 	// returns database.ErrAccessTokenAlreadyExist when an access token with same
-	// name already exists for the user.
-	CreateAccessToken(ctx gocontext.Context, userID int64, name string) (*database.AccessToken, error)
+	// name already exists for the user, database.ErrInvalidAccessTokenScope when
+	// any of the scopes is not valid, or database.ErrRepoNotExist when any of the
+	// repositories does not exist or is not readable by the user.
+	CreateAccessToken(ctx gocontext.Context, userID int64, name string, opts database.CreateAccessTokenOptions) (*database.AccessToken, error)
 	// GetAccessTokenBySHA1 returns the access token with given SHA1. It returns
 	// database.ErrAccessTokenNotExist when not found.
 	GetAccessTokenBySHA1(ctx gocontext.Context, sha1 string) (*database.AccessToken, error)
@@ -756,6 +904,12 @@ type SettingsStore interface {
 	ListAccessTokens(ctx gocontext.Context, userID int64) ([]*database.AccessToken, error)
 	// DeleteAccessTokenByID deletes the access token by given ID.
 	DeleteAccessTokenByID(ctx gocontext.Context, userID, id int64) error
+	// ListOAuth2Grants returns all access the user has granted to OAuth2
+	// applications, with their applications loaded.
+	ListOAuth2Grants(ctx gocontext.Context, userID int64) ([]*database.OAuth2Grant, error)
+	// RevokeOAuth2Grant revokes the access the user has granted with given grant
+	// ID, and deletes all tokens issued for it.
+	RevokeOAuth2Grant(ctx gocontext.Context, userID, grantID int64) error
 }
 
 type settingsStore struct{}
@@ -766,8 +920,8 @@ func NewSettingsStore() SettingsStore {
 	return &settingsStore{}
 }
 
-func (*settingsStore) CreateAccessToken(ctx gocontext.Context, userID int64, name string) (*database.AccessToken, error) {
-	return database.Handle.AccessTokens().Create(ctx, userID, name)
+func (*settingsStore) CreateAccessToken(ctx gocontext.Context, userID int64, name string, opts database.CreateAccessTokenOptions) (*database.AccessToken, error) {
+	return database.Handle.AccessTokens().Create(ctx, userID, name, opts)
 }
 
 func (*settingsStore) GetAccessTokenBySHA1(ctx gocontext.Context, sha1 string) (*database.AccessToken, error) {
@@ -785,3 +939,11 @@ func (*settingsStore) ListAccessTokens(ctx gocontext.Context, userID int64) ([]*
 func (*settingsStore) DeleteAccessTokenByID(ctx gocontext.Context, userID, id int64) error {
 	return database.Handle.AccessTokens().DeleteByID(ctx, userID, id)
 }
+
+func (*settingsStore) ListOAuth2Grants(ctx gocontext.Context, userID int64) ([]*database.OAuth2Grant, error) {
+	return database.Handle.OAuth2().ListGrants(ctx, userID)
+}
+
+func (*settingsStore) RevokeOAuth2Grant(ctx gocontext.Context, userID, grantID int64) error {
+	return database.Handle.OAuth2().RevokeGrant(ctx, userID, grantID)
+}
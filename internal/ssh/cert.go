@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/ssh"
+
+	"gogs.io/gogs/internal/database"
+)
+
+// watchedFile holds the parsed content of a file, which is parsed again when
+// the file has been modified.
+type watchedFile[T any] struct {
+	path  string
+	parse func(data []byte) (T, error)
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	value   T
+}
+
+// get returns the parsed content of the file. An error is returned when the
+// file cannot be read or parsed, so callers can fail closed.
+func (f *watchedFile[T]) get() (T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var zero T
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return zero, errors.Wrap(err, "stat")
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return f.value, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return zero, errors.Wrap(err, "read")
+	}
+	value, err := f.parse(data)
+	if err != nil {
+		return zero, errors.Wrap(err, "parse")
+	}
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	f.value = value
+	return value, nil
+}
+
+// parseAuthorityKeys parses public keys of certificate authorities, one per
+// line in the format of "authorized_keys".
+func parseAuthorityKeys(data []byte) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse key on line %d", i)
+		} else if _, ok := key.(*ssh.Certificate); ok {
+			return nil, errors.Newf("line %d: certificate cannot be used as an authority", i)
+		}
+		keys[string(key.Marshal())] = true
+	}
+	return keys, scanner.Err()
+}
+
+// RevokedKeys is a list of revoked keys and certificates loaded from a file,
+// which is either an OpenSSH key revocation list (KRL) or public keys in the
+// format of "authorized_keys". The file is read again when modified.
+type RevokedKeys struct {
+	file *watchedFile[*revocationList]
+}
+
+// NewRevokedKeys loads the list of revoked keys and certificates from the file.
+func NewRevokedKeys(path string) (*RevokedKeys, error) {
+	r := &RevokedKeys{
+		file: &watchedFile[*revocationList]{
+			path:  path,
+			parse: parseRevocationList,
+		},
+	}
+	if _, err := r.file.get(); err != nil {
+		return nil, errors.Wrapf(err, "load revoked keys %q", path)
+	}
+	return r, nil
+}
+
+// IsRevoked returns true if the key, or when the key is a certificate, the
+// certificate or any of its keys is revoked. It returns an error when the file
+// cannot be loaded, so callers can fail closed.
+func (r *RevokedKeys) IsRevoked(key ssh.PublicKey) (bool, error) {
+	l, err := r.file.get()
+	if err != nil {
+		return false, errors.Wrapf(err, "load revoked keys %q", r.file.path)
+	}
+	return l.isRevoked(key), nil
+}
+
+// UserCertChecker authenticates users by SSH user certificates signed by
+// trusted certificate authorities.
+type UserCertChecker struct {
+	authorities *watchedFile[map[string]bool]
+	// It is nil when no revocation list is configured.
+	revoked *RevokedKeys
+
+	// getUserByName returns the user with the given name, it is replaceable for
+	// testing.
+	getUserByName func(ctx context.Context, name string) (*database.User, error)
+	// now returns the current time, it is replaceable for testing.
+	now func() time.Time
+}
+
+// NewUserCertChecker returns a new checker that trusts certificate authorities
+// listed in the caKeysFile, which is read again when modified. Certificates
+// listed in revoked are rejected, it is optional.
+func NewUserCertChecker(caKeysFile string, revoked *RevokedKeys) (*UserCertChecker, error) {
+	c := &UserCertChecker{
+		authorities: &watchedFile[map[string]bool]{
+			path:  caKeysFile,
+			parse: parseAuthorityKeys,
+		},
+		getUserByName: func(ctx context.Context, name string) (*database.User, error) {
+			return database.Handle.Users().GetByUsername(ctx, name)
+		},
+		now:     time.Now,
+		revoked: revoked,
+	}
+	if _, err := c.authorities.get(); err != nil {
+		return nil, errors.Wrapf(err, "load trusted user CA keys %q", caKeysFile)
+	}
+	return c, nil
+}
+
+// Authenticate checks the user certificate and returns the user that the
+// certificate is issued for. The certificate must be signed by a trusted
+// certificate authority, be valid at the moment, not be revoked, and not
+// contain unsupported critical options. The first principal that names an
+// active user (not an organization) who is allowed to sign in is used.
+//
+// The "source-address" critical option is not checked here, it must be
+// enforced by the caller using the returned critical options.
+func (c *UserCertChecker) Authenticate(ctx context.Context, cert *ssh.Certificate) (*database.User, error) {
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.Newf("certificate type %d is not a user certificate", cert.CertType)
+	}
+
+	authorities, err := c.authorities.get()
+	if err != nil {
+		return nil, errors.Wrapf(err, "load trusted user CA keys %q", c.authorities.path)
+	}
+	if !authorities[string(cert.SignatureKey.Marshal())] {
+		return nil, errors.Newf("certificate %q is signed by an untrusted authority", cert.KeyId)
+	}
+
+	if c.revoked != nil {
+		revoked, err := c.revoked.IsRevoked(cert)
+		if err != nil {
+			return nil, err
+		} else if revoked {
+			return nil, errors.Newf("certificate %q is revoked", cert.KeyId)
+		}
+	}
+
+	if len(cert.ValidPrincipals) == 0 {
+		return nil, errors.Newf("certificate %q has no principals", cert.KeyId)
+	}
+
+	checker := &ssh.CertChecker{
+		Clock: c.now,
+	}
+	for _, principal := range cert.ValidPrincipals {
+		// It checks the principal, validity window, critical options and signature.
+		if err = checker.CheckCert(principal, cert); err != nil {
+			return nil, errors.Wrapf(err, "check certificate %q", cert.KeyId)
+		}
+
+		u, err := c.getUserByName(ctx, principal)
+		if err != nil {
+			if database.IsErrUserNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "get user %q", principal)
+		}
+		if u.IsOrganization() || !u.IsActive || u.ProhibitLogin {
+			continue
+		}
+		return u, nil
+	}
+	return nil, errors.Newf("no principal of certificate %q names a user who is allowed to sign in", cert.KeyId)
+}
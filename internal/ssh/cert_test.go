@@ -0,0 +1,167 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"gogs.io/gogs/internal/database"
+)
+
+func TestUserCertChecker_Authenticate(t *testing.T) {
+	caKey, ca := newTestKey(t)
+	_, otherCA := newTestKey(t)
+
+	dir := t.TempDir()
+	caKeysFile := filepath.Join(dir, "trusted_user_ca_keys")
+	require.NoError(t, os.WriteFile(caKeysFile, ssh.MarshalAuthorizedKey(caKey), 0o600))
+	revokedKeysFile := filepath.Join(dir, "revoked_keys")
+	require.NoError(t, os.WriteFile(revokedKeysFile, nil, 0o600))
+
+	revoked, err := NewRevokedKeys(revokedKeysFile)
+	require.NoError(t, err)
+	checker, err := NewUserCertChecker(caKeysFile, revoked)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	checker.now = func() time.Time { return now }
+	users := map[string]*database.User{
+		"alice":    {ID: 1, Name: "alice", IsActive: true},
+		"bob":      {ID: 2, Name: "bob", IsActive: true, ProhibitLogin: true},
+		"inactive": {ID: 3, Name: "inactive"},
+		"org":      {ID: 4, Name: "org", IsActive: true, Type: database.UserTypeOrganization},
+	}
+	checker.getUserByName = func(_ context.Context, name string) (*database.User, error) {
+		u, ok := users[name]
+		if !ok {
+			return nil, database.ErrUserNotExist{}
+		}
+		return u, nil
+	}
+
+	newCert := func(t *testing.T, signer ssh.Signer, update func(cert *ssh.Certificate)) *ssh.Certificate {
+		t.Helper()
+
+		key, _ := newTestKey(t)
+		cert := &ssh.Certificate{
+			Key:             key,
+			Serial:          1,
+			CertType:        ssh.UserCert,
+			KeyId:           "test",
+			ValidPrincipals: []string{"alice"},
+			ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+		}
+		if update != nil {
+			update(cert)
+		}
+		require.NoError(t, cert.SignCert(rand.Reader, signer))
+		return cert
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		u, err := checker.Authenticate(context.Background(), newCert(t, ca, nil))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", u.Name)
+	})
+
+	t.Run("first principal naming an allowed user", func(t *testing.T) {
+		cert := newCert(t, ca, func(cert *ssh.Certificate) {
+			cert.ValidPrincipals = []string{"unknown", "bob", "inactive", "org", "alice"}
+		})
+		u, err := checker.Authenticate(context.Background(), cert)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", u.Name)
+	})
+
+	t.Run("source address", func(t *testing.T) {
+		cert := newCert(t, ca, func(cert *ssh.Certificate) {
+			cert.CriticalOptions = map[string]string{"source-address": "10.0.0.0/8"}
+		})
+		_, err := checker.Authenticate(context.Background(), cert)
+		require.NoError(t, err)
+	})
+
+	tests := []struct {
+		name    string
+		signer  ssh.Signer
+		update  func(cert *ssh.Certificate)
+		wantErr string
+	}{
+		{
+			name:    "host certificate",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.CertType = ssh.HostCert },
+			wantErr: "not a user certificate",
+		},
+		{
+			name:    "untrusted authority",
+			signer:  otherCA,
+			wantErr: "untrusted authority",
+		},
+		{
+			name:    "no principals",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.ValidPrincipals = nil },
+			wantErr: "has no principals",
+		},
+		{
+			name:    "expired",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.ValidBefore = uint64(now.Add(-time.Minute).Unix()) },
+			wantErr: "expired",
+		},
+		{
+			name:    "not yet valid",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.ValidAfter = uint64(now.Add(time.Minute).Unix()) },
+			wantErr: "not yet valid",
+		},
+		{
+			name:    "unsupported critical option",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.CriticalOptions = map[string]string{"force-command": "true"} },
+			wantErr: "unsupported critical option",
+		},
+		{
+			name:    "no allowed user",
+			signer:  ca,
+			update:  func(cert *ssh.Certificate) { cert.ValidPrincipals = []string{"bob", "inactive", "org"} },
+			wantErr: "no principal",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := checker.Authenticate(context.Background(), newCert(t, test.signer, test.update))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+
+	t.Run("revoked", func(t *testing.T) {
+		cert := newCert(t, ca, nil)
+		_, err := checker.Authenticate(context.Background(), cert)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(revokedKeysFile, ssh.MarshalAuthorizedKey(cert.Key), 0o600))
+		// Make sure the modification is noticed regardless of the file system
+		// timestamp granularity.
+		require.NoError(t, os.Chtimes(revokedKeysFile, now, now.Add(time.Minute)))
+		_, err = checker.Authenticate(context.Background(), cert)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is revoked")
+
+		// Fail closed when the revocation list cannot be loaded.
+		require.NoError(t, os.Remove(revokedKeysFile))
+		_, err = checker.Authenticate(context.Background(), newCert(t, ca, nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "load revoked keys")
+	})
+}
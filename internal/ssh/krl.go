@@ -0,0 +1,297 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// krlMagic is the magic number at the beginning of an OpenSSH key revocation
+// list, see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.krl.
+const krlMagic = "SSHKRL\n\x00"
+
+const (
+	krlSectionCertificates      = 1
+	krlSectionExplicitKey       = 2
+	krlSectionFingerprintSHA1   = 3
+	krlSectionSignature         = 4
+	krlSectionFingerprintSHA256 = 5
+
+	krlSectionCertSerialList   = 0x20
+	krlSectionCertSerialRange  = 0x21
+	krlSectionCertSerialBitmap = 0x22
+	krlSectionCertKeyID        = 0x23
+)
+
+// krlCertificates contains revoked certificates issued by a certificate
+// authority.
+type krlCertificates struct {
+	// The public key blob of the certificate authority, empty for any.
+	caKey   []byte
+	serials []krlSerialRange
+	bitmaps []krlSerialBitmap
+	keyIDs  map[string]bool
+}
+
+type krlSerialRange struct {
+	min, max uint64
+}
+
+type krlSerialBitmap struct {
+	offset uint64
+	bitmap *big.Int
+}
+
+func (c *krlCertificates) isRevoked(cert *ssh.Certificate) bool {
+	if len(c.caKey) > 0 && !bytes.Equal(c.caKey, cert.SignatureKey.Marshal()) {
+		return false
+	}
+	if c.keyIDs[cert.KeyId] {
+		return true
+	}
+	for _, r := range c.serials {
+		if r.min <= cert.Serial && cert.Serial <= r.max {
+			return true
+		}
+	}
+	for _, b := range c.bitmaps {
+		if cert.Serial >= b.offset && cert.Serial-b.offset < uint64(b.bitmap.BitLen()) &&
+			b.bitmap.Bit(int(cert.Serial-b.offset)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// revocationList is a list of revoked keys and certificates, which is either
+// an OpenSSH key revocation list or a list of public keys in the format of
+// "authorized_keys".
+type revocationList struct {
+	certificates []*krlCertificates
+	// Blobs of revoked public keys.
+	keys map[string]bool
+	// SHA-1 and SHA-256 fingerprints of revoked public keys.
+	sha1s   map[[sha1.Size]byte]bool
+	sha256s map[[sha256.Size]byte]bool
+}
+
+// isRevoked returns true if the key, or when the key is a certificate, the
+// certificate, its certified key or the key of its certificate authority is
+// revoked.
+func (l *revocationList) isRevoked(key ssh.PublicKey) bool {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		for _, c := range l.certificates {
+			if c.isRevoked(cert) {
+				return true
+			}
+		}
+		return l.isRevoked(cert.Key) || l.isRevoked(cert.SignatureKey)
+	}
+
+	blob := key.Marshal()
+	return l.keys[string(blob)] || l.sha1s[sha1.Sum(blob)] || l.sha256s[sha256.Sum256(blob)]
+}
+
+// parseRevocationList parses an OpenSSH key revocation list or a list of public
+// keys. Signatures of key revocation lists are not verified.
+func parseRevocationList(data []byte) (*revocationList, error) {
+	l := &revocationList{
+		keys:    make(map[string]bool),
+		sha1s:   make(map[[sha1.Size]byte]bool),
+		sha256s: make(map[[sha256.Size]byte]bool),
+	}
+	if !bytes.HasPrefix(data, []byte(krlMagic)) {
+		return l, l.parseKeys(data)
+	}
+	return l, l.parseKRL(data[len(krlMagic):])
+}
+
+func (l *revocationList) parseKeys(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return errors.Wrapf(err, "parse key on line %d", i)
+		}
+		l.keys[string(key.Marshal())] = true
+	}
+	return scanner.Err()
+}
+
+// krlReader reads values encoded in the SSH wire format.
+type krlReader struct {
+	data []byte
+	err  error
+}
+
+func (r *krlReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	} else if n < 0 || len(r.data) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *krlReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *krlReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *krlReader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *krlReader) string() []byte {
+	n := r.uint32()
+	if r.err == nil && uint64(n) > uint64(len(r.data)) {
+		r.err = errors.New("string length exceeds data")
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+func (r *krlReader) empty() bool {
+	return r.err == nil && len(r.data) == 0
+}
+
+func (l *revocationList) parseKRL(data []byte) error {
+	r := &krlReader{data: data}
+	if version := r.uint32(); r.err == nil && version != 1 {
+		return errors.Newf("unsupported format version %d", version)
+	}
+	r.uint64() // KRL version
+	r.uint64() // Generated date
+	r.uint64() // Flags
+	r.string() // Reserved
+	r.string() // Comment
+
+	for !r.empty() {
+		typ := r.byte()
+		section := &krlReader{data: r.string()}
+		if r.err != nil {
+			break
+		}
+
+		switch typ {
+		case krlSectionCertificates:
+			c, err := parseKRLCertificates(section)
+			if err != nil {
+				return errors.Wrap(err, "parse certificates section")
+			}
+			l.certificates = append(l.certificates, c)
+		case krlSectionExplicitKey:
+			for !section.empty() {
+				if blob := section.string(); section.err == nil {
+					l.keys[string(blob)] = true
+				}
+			}
+		case krlSectionFingerprintSHA1:
+			for !section.empty() {
+				if hash := section.string(); section.err == nil {
+					if len(hash) != sha1.Size {
+						return errors.New("invalid SHA-1 fingerprint")
+					}
+					l.sha1s[[sha1.Size]byte(hash)] = true
+				}
+			}
+		case krlSectionFingerprintSHA256:
+			for !section.empty() {
+				if hash := section.string(); section.err == nil {
+					if len(hash) != sha256.Size {
+						return errors.New("invalid SHA-256 fingerprint")
+					}
+					l.sha256s[[sha256.Size]byte(hash)] = true
+				}
+			}
+		case krlSectionSignature:
+			// Signatures are the last sections and cover everything before them.
+			return nil
+		default:
+			return errors.Newf("unknown section type %d", typ)
+		}
+		if section.err != nil {
+			return errors.Wrapf(section.err, "parse section type %d", typ)
+		}
+	}
+	return errors.Wrap(r.err, "parse sections")
+}
+
+func parseKRLCertificates(r *krlReader) (*krlCertificates, error) {
+	c := &krlCertificates{
+		caKey:  r.string(),
+		keyIDs: make(map[string]bool),
+	}
+	r.string() // Reserved
+
+	for !r.empty() {
+		typ := r.byte()
+		section := &krlReader{data: r.string()}
+		if r.err != nil {
+			break
+		}
+
+		switch typ {
+		case krlSectionCertSerialList:
+			for !section.empty() {
+				serial := section.uint64()
+				c.serials = append(c.serials, krlSerialRange{min: serial, max: serial})
+			}
+		case krlSectionCertSerialRange:
+			min, max := section.uint64(), section.uint64()
+			c.serials = append(c.serials, krlSerialRange{min: min, max: max})
+		case krlSectionCertSerialBitmap:
+			offset := section.uint64()
+			bitmap := section.string()
+			if section.err == nil && len(bitmap) > 0 && bitmap[0]&0x80 != 0 {
+				return nil, errors.New("negative serial bitmap")
+			}
+			c.bitmaps = append(c.bitmaps, krlSerialBitmap{offset: offset, bitmap: new(big.Int).SetBytes(bitmap)})
+		case krlSectionCertKeyID:
+			for !section.empty() {
+				if id := section.string(); section.err == nil {
+					c.keyIDs[string(id)] = true
+				}
+			}
+		default:
+			return nil, errors.Newf("unknown certificate section type %d", typ)
+		}
+		if section.err != nil {
+			return nil, errors.Wrapf(section.err, "parse certificate section type %d", typ)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c, nil
+}
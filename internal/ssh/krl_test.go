@@ -0,0 +1,187 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// krlBuilder builds values encoded in the SSH wire format.
+type krlBuilder []byte
+
+func (b *krlBuilder) byte(v byte) *krlBuilder {
+	*b = append(*b, v)
+	return b
+}
+
+func (b *krlBuilder) uint32(v uint32) *krlBuilder {
+	*b = binary.BigEndian.AppendUint32(*b, v)
+	return b
+}
+
+func (b *krlBuilder) uint64(v uint64) *krlBuilder {
+	*b = binary.BigEndian.AppendUint64(*b, v)
+	return b
+}
+
+func (b *krlBuilder) string(v []byte) *krlBuilder {
+	b.uint32(uint32(len(v)))
+	*b = append(*b, v...)
+	return b
+}
+
+func (b *krlBuilder) section(typ byte, data *krlBuilder) *krlBuilder {
+	return b.byte(typ).string(*data)
+}
+
+func newKRL() *krlBuilder {
+	b := krlBuilder(krlMagic)
+	b.uint32(1).uint64(1).uint64(0).uint64(0).string(nil).string([]byte("comment"))
+	return &b
+}
+
+func newTestKey(t *testing.T) (ssh.PublicKey, ssh.Signer) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key, signer
+}
+
+func newTestCert(t *testing.T, ca ssh.Signer, serial uint64, keyID string) *ssh.Certificate {
+	t.Helper()
+
+	key, _ := newTestKey(t)
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+	return cert
+}
+
+func TestParseRevocationList(t *testing.T) {
+	revokedKey, _ := newTestKey(t)
+	otherKey, _ := newTestKey(t)
+	hashedKey, _ := newTestKey(t)
+	caKey, ca := newTestKey(t)
+	_, otherCA := newTestKey(t)
+
+	t.Run("authorized keys", func(t *testing.T) {
+		data := "# Revoked keys\n\n" + string(ssh.MarshalAuthorizedKey(revokedKey))
+		l, err := parseRevocationList([]byte(data))
+		require.NoError(t, err)
+
+		assert.True(t, l.isRevoked(revokedKey))
+		assert.False(t, l.isRevoked(otherKey))
+
+		// Certificates are revoked when their keys are revoked.
+		cert := newTestCert(t, ca, 1, "alice")
+		assert.False(t, l.isRevoked(cert))
+		cert.Key = revokedKey
+		assert.True(t, l.isRevoked(cert))
+	})
+
+	t.Run("invalid authorized keys", func(t *testing.T) {
+		_, err := parseRevocationList([]byte("not a key"))
+		assert.Error(t, err)
+	})
+
+	t.Run("KRL", func(t *testing.T) {
+		hash := sha256.Sum256(hashedKey.Marshal())
+		certs := new(krlBuilder).
+			string(caKey.Marshal()).
+			string(nil).
+			section(krlSectionCertSerialList, new(krlBuilder).uint64(1).uint64(3)).
+			section(krlSectionCertSerialRange, new(krlBuilder).uint64(10).uint64(20)).
+			// Bits 0 and 2 are set, i.e. serials 100 and 102.
+			section(krlSectionCertSerialBitmap, new(krlBuilder).uint64(100).string([]byte{0x05})).
+			section(krlSectionCertKeyID, new(krlBuilder).string([]byte("revoked")))
+		data := newKRL().
+			section(krlSectionCertificates, certs).
+			section(krlSectionExplicitKey, new(krlBuilder).string(revokedKey.Marshal())).
+			section(krlSectionFingerprintSHA256, new(krlBuilder).string(hash[:])).
+			section(krlSectionSignature, new(krlBuilder).string([]byte("signature")))
+
+		l, err := parseRevocationList(*data)
+		require.NoError(t, err)
+
+		assert.True(t, l.isRevoked(revokedKey))
+		assert.True(t, l.isRevoked(hashedKey))
+		assert.False(t, l.isRevoked(otherKey))
+
+		tests := []struct {
+			name   string
+			ca     ssh.Signer
+			serial uint64
+			keyID  string
+			want   bool
+		}{
+			{name: "listed serial", ca: ca, serial: 3, keyID: "alice", want: true},
+			{name: "unlisted serial", ca: ca, serial: 2, keyID: "alice", want: false},
+			{name: "serial in range", ca: ca, serial: 15, keyID: "alice", want: true},
+			{name: "serial in bitmap", ca: ca, serial: 102, keyID: "alice", want: true},
+			{name: "serial not in bitmap", ca: ca, serial: 101, keyID: "alice", want: false},
+			{name: "key ID", ca: ca, serial: 50, keyID: "revoked", want: true},
+			{name: "other CA", ca: otherCA, serial: 3, keyID: "revoked", want: false},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				cert := newTestCert(t, test.ca, test.serial, test.keyID)
+				assert.Equal(t, test.want, l.isRevoked(cert))
+			})
+		}
+	})
+
+	t.Run("KRL for any CA", func(t *testing.T) {
+		certs := new(krlBuilder).
+			string(nil).
+			string(nil).
+			section(krlSectionCertKeyID, new(krlBuilder).string([]byte("revoked")))
+		l, err := parseRevocationList(*newKRL().section(krlSectionCertificates, certs))
+		require.NoError(t, err)
+
+		assert.True(t, l.isRevoked(newTestCert(t, otherCA, 1, "revoked")))
+		assert.False(t, l.isRevoked(newTestCert(t, otherCA, 1, "alice")))
+	})
+
+	t.Run("revoked CA key", func(t *testing.T) {
+		l, err := parseRevocationList(*newKRL().section(krlSectionExplicitKey, new(krlBuilder).string(caKey.Marshal())))
+		require.NoError(t, err)
+
+		assert.True(t, l.isRevoked(newTestCert(t, ca, 1, "alice")))
+	})
+
+	t.Run("invalid KRL", func(t *testing.T) {
+		tests := []struct {
+			name string
+			data *krlBuilder
+		}{
+			{name: "truncated header", data: func() *krlBuilder { b := krlBuilder(krlMagic); return b.uint32(1) }()},
+			{name: "unsupported version", data: func() *krlBuilder { b := krlBuilder(krlMagic); return b.uint32(2) }()},
+			{name: "unknown section", data: newKRL().section(99, new(krlBuilder))},
+			{name: "truncated section", data: newKRL().byte(krlSectionExplicitKey).uint32(100)},
+			{name: "invalid fingerprint", data: newKRL().section(krlSectionFingerprintSHA256, new(krlBuilder).string([]byte("short")))},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := parseRevocationList(*test.data)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -30,7 +30,10 @@ func cleanCommand(cmd string) string {
 	return cmd[i:]
 }
 
-func handleServerConn(keyID string, chans <-chan ssh.NewChannel) {
+// handleServerConn handles channels of the connection, the servArg is the
+// argument passed to the "serv" command to identify the authenticated key or
+// user, i.e. "key-<id>" or "user-<id>".
+func handleServerConn(servArg string, chans <-chan ssh.NewChannel) {
 	for newChan := range chans {
 		if newChan.ChannelType() != "session" {
 			_ = newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
@@ -58,7 +61,7 @@ func handleServerConn(keyID string, chans <-chan ssh.NewChannel) {
 					cmdName := strings.TrimLeft(payload, "'()")
 					log.Trace("SSH: Payload: %v", cmdName)
 
-					args := []string{"serv", "key-" + keyID, "--config=" + conf.CustomConf}
+					args := []string{"serv", servArg, "--config=" + conf.CustomConf}
 					log.Trace("SSH: Arguments: %v", args)
 					cmd := exec.Command(conf.AppPath(), args...)
 					cmd.Env = append(os.Environ(), "SSH_ORIGINAL_COMMAND="+cmdName)
@@ -149,19 +152,65 @@ func listen(config *ssh.ServerConfig, host string, port int) {
 			log.Trace("SSH: Connection from %s (%s)", sConn.RemoteAddr(), sConn.ClientVersion())
 			// The incoming Request channel must be serviced.
 			go ssh.DiscardRequests(reqs)
-			go handleServerConn(sConn.Permissions.Extensions["key-id"], chans)
+			go handleServerConn(sConn.Permissions.Extensions["serv-arg"], chans)
 		}()
 	}
 }
 
 // Listen starts a SSH server listens on given port.
 func Listen(opts conf.SSHOpts, appDataPath string) {
+	var revokedKeys *RevokedKeys
+	if opts.RevokedKeys != "" {
+		var err error
+		revokedKeys, err = NewRevokedKeys(opts.RevokedKeys)
+		if err != nil {
+			log.Fatal("SSH: Failed to set up revoked keys: %v", err)
+		}
+	}
+
+	var certChecker *UserCertChecker
+	if opts.TrustedUserCAKeys != "" {
+		var err error
+		certChecker, err = NewUserCertChecker(opts.TrustedUserCAKeys, revokedKeys)
+		if err != nil {
+			log.Fatal("SSH: Failed to set up certificate authentication: %v", err)
+		}
+	}
+
 	config := &ssh.ServerConfig{
 		Config: ssh.Config{
 			Ciphers: opts.ServerCiphers,
 			MACs:    opts.ServerMACs,
 		},
 		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if cert, ok := key.(*ssh.Certificate); ok {
+				if certChecker == nil {
+					return nil, errors.New("certificate authentication is not enabled")
+				}
+
+				u, err := certChecker.Authenticate(context.Background(), cert)
+				if err != nil {
+					log.Trace("SSH: Certificate authentication failed: %v", err)
+					return nil, err
+				}
+				log.Trace("SSH: Certificate %q is accepted for user %q", cert.KeyId, u.Name)
+				// The "source-address" critical option is enforced by the server.
+				return &ssh.Permissions{
+					CriticalOptions: cert.CriticalOptions,
+					Extensions:      map[string]string{"serv-arg": "user-" + strconv.FormatInt(u.ID, 10)},
+				}, nil
+			}
+
+			if revokedKeys != nil {
+				revoked, err := revokedKeys.IsRevoked(key)
+				if err != nil {
+					log.Error("SSH: Failed to check revoked keys: %v", err)
+					return nil, err
+				} else if revoked {
+					return nil, errors.New("key is revoked")
+				}
+			}
+
 			pkey, err := database.SearchPublicKeyByContent(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
 			if err != nil {
 				if !database.IsErrKeyNotExist(err) {
@@ -169,7 +218,7 @@ func Listen(opts conf.SSHOpts, appDataPath string) {
 				}
 				return nil, err
 			}
-			return &ssh.Permissions{Extensions: map[string]string{"key-id": strconv.FormatInt(pkey.ID, 10)}}, nil
+			return &ssh.Permissions{Extensions: map[string]string{"serv-arg": "key-" + strconv.FormatInt(pkey.ID, 10)}}, nil
 		},
 	}
 
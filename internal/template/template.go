@@ -83,7 +83,9 @@ func FuncMap() []template.FuncMap {
 			"Add": func(a, b int) int {
 				return a + b
 			},
-			"ActionIcon": ActionIcon,
+			"ActionIcon":        ActionIcon,
+			"CommitStatusIcon":  CommitStatusIcon,
+			"CommitStatusColor": CommitStatusColor,
 			"DateFmtLong": func(t time.Time) string {
 				return t.Format(time.RFC1123Z)
 			},
@@ -247,6 +249,30 @@ func ActionIcon(opType int) string {
 	}
 }
 
+// CommitStatusIcon returns the icon class name of given commit status state.
+func CommitStatusIcon(state database.CommitStatusState) string {
+	switch state {
+	case database.CommitStatusSuccess:
+		return "check"
+	case database.CommitStatusError, database.CommitStatusFailure:
+		return "x"
+	default:
+		return "primitive-dot"
+	}
+}
+
+// CommitStatusColor returns the color class name of given commit status state.
+func CommitStatusColor(state database.CommitStatusState) string {
+	switch state {
+	case database.CommitStatusSuccess:
+		return "green"
+	case database.CommitStatusError, database.CommitStatusFailure:
+		return "red"
+	default:
+		return "yellow"
+	}
+}
+
 func ActionContent2Commits(act Actioner) *database.PushCommits {
 	push := database.NewPushCommits()
 	if err := json.Unmarshal([]byte(act.GetContent()), push); err != nil {
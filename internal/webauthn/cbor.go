@@ -0,0 +1,164 @@
+package webauthn
+
+import (
+	"bytes"
+	"math"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+// maxCBORDepth is the maximum nesting depth of arrays and maps, which is far
+// deeper than anything an authenticator produces.
+const maxCBORDepth = 16
+
+var errCBORUnexpectedEnd = errors.New("unexpected end of CBOR data")
+
+// decodeCBOR decodes the first CBOR data item in b and returns it along with
+// the number of bytes it occupies, see https://datatracker.ietf.org/doc/html/rfc8949.
+//
+// Only the subset of CBOR that authenticators use is supported: integers are
+// decoded as int64, byte strings as []byte, text strings as string, arrays as
+// []any, maps as map[any]any with int64 or string keys, and simple values as
+// bool or nil. Tags are skipped, and indefinite-length items and floating-point
+// numbers are rejected.
+func decodeCBOR(b []byte) (any, int, error) {
+	d := &cborDecoder{data: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.off, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	off  int
+}
+
+// head reads the initial byte and the argument of the next data item.
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.off >= len(d.data) {
+		return 0, 0, errCBORUnexpectedEnd
+	}
+	initial := d.data[d.off]
+	d.off++
+
+	major = initial >> 5
+	info := initial & 0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(d.data)-d.off < n {
+			return 0, 0, errCBORUnexpectedEnd
+		}
+		for _, c := range d.data[d.off : d.off+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		d.off += n
+		return major, arg, nil
+	}
+	return 0, 0, errors.Newf("unsupported additional information %d", info)
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("CBOR data is nested too deeply")
+	}
+
+	start := d.off
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every data item takes at least one byte, which bounds the lengths below
+	// before anything is allocated.
+	remaining := uint64(len(d.data) - d.off)
+	switch major {
+	case 0: // Unsigned integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("integer overflows int64")
+		}
+		return int64(arg), nil
+
+	case 1: // Negative integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+
+	case 2, 3: // Byte string and text string
+		if arg > remaining {
+			return nil, errCBORUnexpectedEnd
+		}
+		s := d.data[d.off : d.off+int(arg)]
+		d.off += int(arg)
+		if major == 2 {
+			return bytes.Clone(s), nil
+		} else if !utf8.Valid(s) {
+			return nil, errors.New("text string is not valid UTF-8")
+		}
+		return string(s), nil
+
+	case 4: // Array
+		if arg > remaining {
+			return nil, errCBORUnexpectedEnd
+		}
+		items := make([]any, 0, arg)
+		for range arg {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+
+	case 5: // Map
+		if arg > remaining/2 {
+			return nil, errCBORUnexpectedEnd
+		}
+		m := make(map[any]any, arg)
+		for range arg {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.Newf("unsupported map key type %T", k)
+			}
+			if _, ok := m[k]; ok {
+				return nil, errors.Newf("duplicate map key %v", k)
+			}
+
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+
+	case 6: // Tag, the tagged data item is returned as-is.
+		return d.decode(depth + 1)
+
+	default: // Simple values and floating-point numbers
+		if d.data[start]&0x1f >= 24 {
+			return nil, errors.New("floating-point numbers are not supported")
+		}
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+		return nil, errors.Newf("unsupported simple value %d", arg)
+	}
+}
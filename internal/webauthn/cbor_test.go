@@ -0,0 +1,122 @@
+package webauthn
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cborMap is a CBOR map whose entries are encoded in the given order.
+type cborMap [][2]any
+
+// encodeCBOR encodes the value like an authenticator would, for tests only.
+func encodeCBOR(v any) []byte {
+	head := func(major byte, arg uint64) []byte {
+		switch {
+		case arg < 24:
+			return []byte{major<<5 | byte(arg)}
+		case arg <= 0xff:
+			return []byte{major<<5 | 24, byte(arg)}
+		case arg <= 0xffff:
+			return []byte{major<<5 | 25, byte(arg >> 8), byte(arg)}
+		default:
+			return []byte{major<<5 | 26, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+		}
+	}
+
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []any:
+		b := head(4, uint64(len(v)))
+		for _, item := range v {
+			b = append(b, encodeCBOR(item)...)
+		}
+		return b
+	case cborMap:
+		b := head(5, uint64(len(v)))
+		for _, entry := range v {
+			b = append(b, encodeCBOR(entry[0])...)
+			b = append(b, encodeCBOR(entry[1])...)
+		}
+		return b
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	}
+	panic("unsupported type")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want any
+	}{
+		{name: "small unsigned integer", hex: "17", want: int64(23)},
+		{name: "unsigned integer", hex: "1903e8", want: int64(1000)},
+		{name: "negative integer", hex: "3901ff", want: int64(-512)},
+		{name: "byte string", hex: "4401020304", want: []byte{1, 2, 3, 4}},
+		{name: "text string", hex: "6449455446", want: "IETF"},
+		{name: "array", hex: "83010203", want: []any{int64(1), int64(2), int64(3)}},
+		{name: "map", hex: "a201020326", want: map[any]any{int64(1): int64(2), int64(3): int64(-7)}},
+		{name: "map with text keys", hex: "a163666374646e6f6e65", want: map[any]any{"fct": "none"}},
+		{name: "tag", hex: "c11a514b67b0", want: int64(1363896240)},
+		{name: "simple values", hex: "83f4f5f6", want: []any{false, true, nil}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			got, n, err := decodeCBOR(b)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, len(b), n)
+		})
+	}
+
+	t.Run("trailing bytes are not consumed", func(t *testing.T) {
+		got, n, err := decodeCBOR([]byte{0x01, 0x02})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), got)
+		assert.Equal(t, 1, n)
+	})
+
+	errTests := []struct {
+		name    string
+		hex     string
+		wantErr string
+	}{
+		{name: "empty", hex: "", wantErr: "unexpected end of CBOR data"},
+		{name: "truncated byte string", hex: "4401", wantErr: "unexpected end of CBOR data"},
+		{name: "oversized array", hex: "9bffffffffffffffff", wantErr: "unexpected end of CBOR data"},
+		{name: "indefinite length", hex: "5f4101ff", wantErr: "unsupported additional information 31"},
+		{name: "floating-point number", hex: "f93c00", wantErr: "floating-point numbers are not supported"},
+		{name: "duplicate map key", hex: "a201020103", wantErr: "duplicate map key 1"},
+		{name: "array map key", hex: "a18001", wantErr: "unsupported map key type []interface {}"},
+		{name: "invalid UTF-8", hex: "62c328", wantErr: "text string is not valid UTF-8"},
+		{name: "integer overflow", hex: "1bffffffffffffffff", wantErr: "integer overflows int64"},
+		{name: "nested too deeply", hex: "818181818181818181818181818181818101", wantErr: "CBOR data is nested too deeply"},
+	}
+	for _, test := range errTests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			_, _, err = decodeCBOR(b)
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
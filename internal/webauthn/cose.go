@@ -0,0 +1,120 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/cockroachdb/errors"
+)
+
+// COSE algorithm identifiers of the supported credential public keys, see
+// https://www.iana.org/assignments/cose/cose.xhtml#algorithms.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// supportedAlgs are the algorithms of credentials that can be registered, in
+// the order of preference.
+var supportedAlgs = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters, see https://datatracker.ietf.org/doc/html/rfc9053#section-7.
+const (
+	coseKeyType = 1
+	coseKeyAlg  = 3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// parsePublicKey parses the credential public key in the COSE_Key format and
+// returns its algorithm along with the key.
+func parsePublicKey(b []byte) (alg int64, _ crypto.PublicKey, _ error) {
+	v, n, err := decodeCBOR(b)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "decode")
+	} else if n != len(b) {
+		return 0, nil, errors.New("unexpected trailing bytes")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return 0, nil, errors.New("key is not a map")
+	}
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ = m[int64(coseKeyAlg)].(int64)
+	bytesParam := func(label int64) []byte {
+		b, _ := m[label].([]byte)
+		return b
+	}
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		if crv, _ := m[int64(-1)].(int64); crv != coseCurveP256 {
+			return 0, nil, errors.Newf("unsupported curve %d", crv)
+		}
+		x, y := bytesParam(-2), bytesParam(-3)
+		if len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("invalid coordinate length")
+		}
+		curve := elliptic.P256()
+		px, py := new(big.Int).SetBytes(x), new(big.Int).SetBytes(y)
+		if !curve.IsOnCurve(px, py) {
+			return 0, nil, errors.New("point is not on the curve")
+		}
+		return alg, &ecdsa.PublicKey{Curve: curve, X: px, Y: py}, nil
+
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		if crv, _ := m[int64(-1)].(int64); crv != coseCurveEd25519 {
+			return 0, nil, errors.Newf("unsupported curve %d", crv)
+		}
+		x := bytesParam(-2)
+		if len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("invalid public key length")
+		}
+		return alg, ed25519.PublicKey(x), nil
+
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, e := new(big.Int).SetBytes(bytesParam(-1)), new(big.Int).SetBytes(bytesParam(-2))
+		if n.BitLen() < 2048 {
+			return 0, nil, errors.New("modulus is too small")
+		} else if !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3 {
+			return 0, nil, errors.New("invalid exponent")
+		}
+		return alg, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	}
+	return 0, nil, errors.Newf("unsupported key type %d with algorithm %d", kty, alg)
+}
+
+// verifySignature verifies the signature of the data with the public key using
+// the COSE algorithm.
+func verifySignature(alg int64, key crypto.PublicKey, data, signature []byte) error {
+	switch alg {
+	case AlgES256:
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case AlgEdDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), data, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case AlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature)
+	}
+	return errors.Newf("unsupported algorithm %d", alg)
+}
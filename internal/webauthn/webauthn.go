@@ -0,0 +1,413 @@
+// Package webauthn implements the relying party operations of the WebAuthn
+// registration and authentication ceremonies, see
+// https://www.w3.org/TR/webauthn-2/#sctn-rp-operations.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// timeout is how long in milliseconds the browser waits for the user to
+// interact with the authenticator.
+const timeout = 300000
+
+// RelyingParty is the WebAuthn relying party, which is the site that users
+// register credentials with and authenticate to.
+type RelyingParty struct {
+	// ID is the RP ID that credentials are scoped to, which is the host name of
+	// the site.
+	ID string
+	// Name is the human-palatable name of the site.
+	Name string
+	// Origin is the origin of the pages that perform the ceremonies, e.g.
+	// "https://gogs.example.com".
+	Origin string
+}
+
+// NewRelyingParty returns the relying party with the given name for the site
+// served at the external URL.
+func NewRelyingParty(name, externalURL string) (*RelyingParty, error) {
+	u, err := url.Parse(externalURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse external URL")
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Newf("external URL %q is not absolute", externalURL)
+	}
+
+	// Browsers serialize origins without the default port of the scheme.
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+	return &RelyingParty{
+		ID:     strings.ToLower(u.Hostname()),
+		Name:   name,
+		Origin: u.Scheme + "://" + host,
+	}, nil
+}
+
+// NewChallenge returns a random challenge for a ceremony. It is encoded with
+// unpadded base64url, which is how the browser puts it in the client data.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// EncodeBinary encodes binary values with unpadded base64url, which is how
+// they are exchanged with the browser.
+func EncodeBinary(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBinary decodes binary values encoded by EncodeBinary.
+func DecodeBinary(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// User is the user account that credentials are created for.
+type User struct {
+	// ID is the opaque user handle that authenticators store along with
+	// discoverable credentials.
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions are the options for navigator.credentials.create() to start
+// the registration ceremony, see
+// https://www.w3.org/TR/webauthn-2/#dictdef-publickeycredentialcreationoptions.
+// Binary values are encoded with EncodeBinary.
+type CreationOptions struct {
+	RP                     relyingPartyEntity     `json:"rp"`
+	User                   userEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []credentialParameters `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options for navigator.credentials.get() to start the
+// authentication ceremony, see
+// https://www.w3.org/TR/webauthn-2/#dictdef-publickeycredentialrequestoptions.
+// Binary values are encoded with EncodeBinary.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+func credentialDescriptors(ids [][]byte) []credentialDescriptor {
+	descriptors := make([]credentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		descriptors = append(descriptors, credentialDescriptor{Type: "public-key", ID: EncodeBinary(id)})
+	}
+	return descriptors
+}
+
+// CreationOptions returns the options to register a new credential for the
+// user, excluding authenticators that already have one of the existing
+// credentials. Credentials for passwordless sign-in are required to be
+// discoverable and to verify the user.
+func (rp *RelyingParty) CreationOptions(challenge string, user User, existingCredentialIDs [][]byte, passwordless bool) *CreationOptions {
+	params := make([]credentialParameters, 0, len(supportedAlgs))
+	for _, alg := range supportedAlgs {
+		params = append(params, credentialParameters{Type: "public-key", Alg: alg})
+	}
+
+	selection := authenticatorSelection{
+		ResidentKey:      "discouraged",
+		UserVerification: "discouraged",
+	}
+	if passwordless {
+		selection = authenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		}
+	}
+
+	return &CreationOptions{
+		RP: relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User: userEntity{
+			ID:          EncodeBinary(user.ID),
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+		},
+		Challenge:              challenge,
+		PubKeyCredParams:       params,
+		Timeout:                timeout,
+		ExcludeCredentials:     credentialDescriptors(existingCredentialIDs),
+		AuthenticatorSelection: selection,
+		Attestation:            "none",
+	}
+}
+
+// RequestOptions returns the options to authenticate with one of the allowed
+// credentials. When no credentials are allowed, the authenticator chooses from
+// its discoverable credentials, and the user must be verified.
+func (rp *RelyingParty) RequestOptions(challenge string, allowedCredentialIDs [][]byte) *RequestOptions {
+	userVerification := "discouraged"
+	if len(allowedCredentialIDs) == 0 {
+		userVerification = "required"
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout,
+		RPID:             rp.ID,
+		AllowCredentials: credentialDescriptors(allowedCredentialIDs),
+		UserVerification: userVerification,
+	}
+}
+
+// clientData is the client data collected by the browser, see
+// https://www.w3.org/TR/webauthn-2/#dictdef-collectedclientdata.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, typ, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return errors.Wrap(err, "decode client data")
+	}
+
+	if cd.Type != typ {
+		return errors.Newf("unexpected client data type %q", cd.Type)
+	} else if challenge == "" || subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	} else if cd.Origin != rp.Origin {
+		return errors.Newf("unexpected origin %q", cd.Origin)
+	} else if cd.CrossOrigin {
+		return errors.New("cross-origin ceremonies are not allowed")
+	}
+	return nil
+}
+
+// Flags of the authenticator data, see
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data.
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+	flagExtensionData          = 0x80
+)
+
+// maxCredentialIDLength is the maximum length of credential IDs, see
+// https://www.w3.org/TR/webauthn-2/#credential-id.
+const maxCredentialIDLength = 1023
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// The attested credential data, which is only present in the registration
+	// ceremony.
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	ad := &authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+
+	rest := b[37:]
+	if ad.flags&flagAttestedCredentialData != 0 {
+		// The AAGUID (16 bytes) is followed by the length of the credential ID (2 bytes).
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n > maxCredentialIDLength || len(rest) < n {
+			return nil, errors.New("invalid credential ID length")
+		}
+		ad.credentialID = rest[:n]
+		rest = rest[n:]
+
+		_, size, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode credential public key")
+		}
+		ad.publicKey = rest[:size]
+		rest = rest[size:]
+	}
+	if ad.flags&flagExtensionData != 0 {
+		_, size, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode extensions")
+		}
+		rest = rest[size:]
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("unexpected trailing bytes in authenticator data")
+	}
+	return ad, nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(ad *authenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return errors.New("RP ID hash mismatch")
+	} else if ad.flags&flagUserPresent == 0 {
+		return errors.New("user is not present")
+	} else if requireUserVerification && ad.flags&flagUserVerified == 0 {
+		return errors.New("user is not verified")
+	}
+	return nil
+}
+
+// Credential is a public key credential created by an authenticator in the
+// registration ceremony.
+type Credential struct {
+	ID []byte
+	// PublicKey is the credential public key in the COSE_Key format.
+	PublicKey []byte
+	SignCount uint32
+}
+
+// VerifyRegistration verifies the response of the authenticator to the
+// registration ceremony with the challenge, and returns the created
+// credential, see https://www.w3.org/TR/webauthn-2/#sctn-registering-a-new-credential.
+//
+// Attestation statements are not verified because any authenticator is allowed
+// to be registered, which is equivalent to requesting "none" attestation.
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte, requireUserVerification bool) (*Credential, error) {
+	err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	v, n, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode attestation object")
+	} else if n != len(attestationObject) {
+		return nil, errors.New("unexpected trailing bytes in attestation object")
+	}
+	m, _ := v.(map[any]any)
+	rawAuthData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse authenticator data")
+	}
+	err = rp.verifyAuthenticatorData(ad, requireUserVerification)
+	if err != nil {
+		return nil, err
+	} else if ad.flags&flagAttestedCredentialData == 0 {
+		return nil, errors.New("authenticator data has no attested credential data")
+	}
+
+	if _, _, err = parsePublicKey(ad.publicKey); err != nil {
+		return nil, errors.Wrap(err, "parse credential public key")
+	}
+	return &Credential{
+		ID:        bytes.Clone(ad.credentialID),
+		PublicKey: bytes.Clone(ad.publicKey),
+		SignCount: ad.signCount,
+	}, nil
+}
+
+// Assertion is the response of the authenticator to the authentication
+// ceremony, see https://www.w3.org/TR/webauthn-2/#authenticatorassertionresponse.
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	// UserHandle is the user handle of discoverable credentials, which may be
+	// empty for other credentials.
+	UserHandle []byte
+}
+
+// VerifyAssertion verifies the assertion of the authentication ceremony with
+// the challenge against the public key and the last known signature counter of
+// the credential, and returns the new signature counter, see
+// https://www.w3.org/TR/webauthn-2/#sctn-verifying-assertion.
+func (rp *RelyingParty) VerifyAssertion(challenge string, a *Assertion, publicKey []byte, signCount uint32, requireUserVerification bool) (uint32, error) {
+	err := rp.verifyClientData(a.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	ad, err := parseAuthenticatorData(a.AuthenticatorData)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse authenticator data")
+	}
+	err = rp.verifyAuthenticatorData(ad, requireUserVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	alg, key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse credential public key")
+	}
+	clientDataHash := sha256.Sum256(a.ClientDataJSON)
+	signed := append(bytes.Clone(a.AuthenticatorData), clientDataHash[:]...)
+	if err = verifySignature(alg, key, signed, a.Signature); err != nil {
+		return 0, errors.Wrap(err, "verify signature")
+	}
+
+	// A signature counter that does not increase is a signal of the
+	// authenticator being cloned, see https://www.w3.org/TR/webauthn-2/#sctn-sign-counter.
+	if (ad.signCount != 0 || signCount != 0) && ad.signCount <= signCount {
+		return 0, errors.New("signature counter did not increase")
+	}
+	return ad.signCount, nil
+}
@@ -0,0 +1,271 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRelyingParty(t *testing.T) {
+	tests := []struct {
+		externalURL string
+		want        *RelyingParty
+	}{
+		{
+			externalURL: "https://gogs.example.com/",
+			want:        &RelyingParty{ID: "gogs.example.com", Name: "Gogs", Origin: "https://gogs.example.com"},
+		},
+		{
+			externalURL: "https://Gogs.Example.com:443/gogs/",
+			want:        &RelyingParty{ID: "gogs.example.com", Name: "Gogs", Origin: "https://gogs.example.com"},
+		},
+		{
+			externalURL: "http://localhost:3000/",
+			want:        &RelyingParty{ID: "localhost", Name: "Gogs", Origin: "http://localhost:3000"},
+		},
+		{
+			externalURL: "http://[::1]:80/",
+			want:        &RelyingParty{ID: "::1", Name: "Gogs", Origin: "http://[::1]"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.externalURL, func(t *testing.T) {
+			got, err := NewRelyingParty("Gogs", test.externalURL)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	_, err := NewRelyingParty("Gogs", "/gogs/")
+	assert.EqualError(t, err, `external URL "/gogs/" is not absolute`)
+}
+
+// testAuthenticator is a stand-in authenticator that holds a single credential.
+type testAuthenticator struct {
+	credentialID []byte
+	alg          int
+	key          crypto.Signer
+	signCount    uint32
+	// Whether the authenticator does not implement the signature counter.
+	noCounter bool
+	// The flags of the authenticator data, defaults to user present.
+	flags byte
+}
+
+func newTestAuthenticator(t *testing.T, alg int) *testAuthenticator {
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	require.NoError(t, err)
+	return &testAuthenticator{
+		credentialID: []byte("credential-" + t.Name()),
+		alg:          alg,
+		key:          key,
+		flags:        flagUserPresent,
+	}
+}
+
+func (a *testAuthenticator) publicKey() []byte {
+	switch pub := a.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		point, err := pub.Bytes()
+		if err != nil {
+			panic(err)
+		}
+		return encodeCBOR(cborMap{{1, 2}, {3, AlgES256}, {-1, 1}, {-2, point[1:33]}, {-3, point[33:]}})
+	case ed25519.PublicKey:
+		return encodeCBOR(cborMap{{1, 1}, {3, AlgEdDSA}, {-1, 6}, {-2, []byte(pub)}})
+	case *rsa.PublicKey:
+		return encodeCBOR(cborMap{{1, 3}, {3, AlgRS256}, {-1, pub.N.Bytes()}, {-2, []byte{1, 0, 1}}})
+	}
+	panic("unsupported key")
+}
+
+func (a *testAuthenticator) authenticatorData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	b := append(rpIDHash[:], a.flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], a.signCount)
+	if attested {
+		b[32] |= flagAttestedCredentialData
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.credentialID)))
+		b = append(b, a.credentialID...)
+		b = append(b, a.publicKey()...)
+	}
+	return b
+}
+
+func clientDataJSON(t *testing.T, typ, challenge, origin string) []byte {
+	b, err := json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      origin,
+		"crossOrigin": false,
+	})
+	require.NoError(t, err)
+	return b
+}
+
+func (a *testAuthenticator) create(t *testing.T, rp *RelyingParty, challenge string) (clientData, attestationObject []byte) {
+	clientData = clientDataJSON(t, "webauthn.create", challenge, rp.Origin)
+	attestationObject = encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authenticatorData(rp.ID, true)},
+	})
+	return clientData, attestationObject
+}
+
+func (a *testAuthenticator) get(t *testing.T, rpID, origin, challenge string) *Assertion {
+	if !a.noCounter {
+		a.signCount++
+	}
+	clientData := clientDataJSON(t, "webauthn.get", challenge, origin)
+	authData := a.authenticatorData(rpID, false)
+
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(authData, clientDataHash[:]...)
+	var signature []byte
+	var err error
+	switch a.alg {
+	case AlgEdDSA:
+		signature, err = a.key.Sign(rand.Reader, signed, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(signed)
+		signature, err = a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	require.NoError(t, err)
+
+	return &Assertion{
+		CredentialID:      a.credentialID,
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}
+}
+
+func TestRelyingParty(t *testing.T) {
+	rp, err := NewRelyingParty("Gogs", "https://gogs.example.com/")
+	require.NoError(t, err)
+
+	for _, alg := range supportedAlgs {
+		t.Run("register and authenticate", func(t *testing.T) {
+			a := newTestAuthenticator(t, int(alg))
+
+			challenge, err := NewChallenge()
+			require.NoError(t, err)
+			clientData, attestationObject := a.create(t, rp, challenge)
+			cred, err := rp.VerifyRegistration(challenge, clientData, attestationObject, false)
+			require.NoError(t, err)
+			assert.Equal(t, a.credentialID, cred.ID)
+			assert.Equal(t, a.publicKey(), cred.PublicKey)
+			assert.Equal(t, uint32(0), cred.SignCount)
+
+			challenge, err = NewChallenge()
+			require.NoError(t, err)
+			signCount, err := rp.VerifyAssertion(challenge, a.get(t, rp.ID, rp.Origin, challenge), cred.PublicKey, cred.SignCount, false)
+			require.NoError(t, err)
+			assert.Equal(t, uint32(1), signCount)
+		})
+	}
+
+	t.Run("registration", func(t *testing.T) {
+		a := newTestAuthenticator(t, AlgES256)
+
+		clientData, attestationObject := a.create(t, rp, "challenge")
+		_, err := rp.VerifyRegistration("other", clientData, attestationObject, false)
+		assert.EqualError(t, err, "challenge mismatch")
+
+		_, err = rp.VerifyRegistration("challenge", clientDataJSON(t, "webauthn.get", "challenge", rp.Origin), attestationObject, false)
+		assert.EqualError(t, err, `unexpected client data type "webauthn.get"`)
+
+		_, err = rp.VerifyRegistration("challenge", clientDataJSON(t, "webauthn.create", "challenge", "https://evil.com"), attestationObject, false)
+		assert.EqualError(t, err, `unexpected origin "https://evil.com"`)
+
+		_, err = rp.VerifyRegistration("challenge", clientData, append(attestationObject, 0), false)
+		assert.EqualError(t, err, "unexpected trailing bytes in attestation object")
+
+		_, err = rp.VerifyRegistration("challenge", clientData, attestationObject, true)
+		assert.EqualError(t, err, "user is not verified")
+
+		a.flags |= flagUserVerified
+		clientData, attestationObject = a.create(t, rp, "challenge")
+		_, err = rp.VerifyRegistration("challenge", clientData, attestationObject, true)
+		assert.NoError(t, err)
+
+		other := &RelyingParty{ID: "evil.com", Origin: rp.Origin}
+		_, err = other.VerifyRegistration("challenge", clientData, attestationObject, false)
+		assert.EqualError(t, err, "RP ID hash mismatch")
+	})
+
+	t.Run("authentication", func(t *testing.T) {
+		a := newTestAuthenticator(t, AlgES256)
+		publicKey := a.publicKey()
+
+		assertion := a.get(t, rp.ID, rp.Origin, "challenge")
+		_, err := rp.VerifyAssertion("other", assertion, publicKey, 0, false)
+		assert.EqualError(t, err, "challenge mismatch")
+
+		_, err = rp.VerifyAssertion("challenge", assertion, publicKey, 0, true)
+		assert.EqualError(t, err, "user is not verified")
+
+		_, err = rp.VerifyAssertion("challenge", assertion, newTestAuthenticator(t, AlgES256).publicKey(), 0, false)
+		assert.EqualError(t, err, "verify signature: invalid signature")
+
+		assertion.AuthenticatorData[32] |= flagUserVerified
+		_, err = rp.VerifyAssertion("challenge", assertion, publicKey, 0, true)
+		assert.EqualError(t, err, "verify signature: invalid signature")
+
+		_, err = rp.VerifyAssertion("challenge", a.get(t, rp.ID, rp.Origin, "challenge"), publicKey, 5, false)
+		assert.EqualError(t, err, "signature counter did not increase")
+
+		a.flags = 0
+		_, err = rp.VerifyAssertion("challenge", a.get(t, rp.ID, rp.Origin, "challenge"), publicKey, 0, false)
+		assert.EqualError(t, err, "user is not present")
+	})
+
+	t.Run("authenticators without signature counters", func(t *testing.T) {
+		a := newTestAuthenticator(t, AlgEdDSA)
+		a.noCounter = true
+
+		for range 2 {
+			signCount, err := rp.VerifyAssertion("challenge", a.get(t, rp.ID, rp.Origin, "challenge"), a.publicKey(), 0, false)
+			require.NoError(t, err)
+			assert.Zero(t, signCount)
+		}
+	})
+}
+
+func TestRelyingParty_RequestOptions(t *testing.T) {
+	rp := &RelyingParty{ID: "gogs.example.com", Name: "Gogs", Origin: "https://gogs.example.com"}
+
+	got := rp.RequestOptions("challenge", [][]byte{{1, 2, 3}})
+	assert.Equal(t, &RequestOptions{
+		Challenge:        "challenge",
+		Timeout:          timeout,
+		RPID:             "gogs.example.com",
+		AllowCredentials: []credentialDescriptor{{Type: "public-key", ID: "AQID"}},
+		UserVerification: "discouraged",
+	}, got)
+
+	got = rp.RequestOptions("challenge", nil)
+	assert.Empty(t, got.AllowCredentials)
+	assert.Equal(t, "required", got.UserVerification)
+}
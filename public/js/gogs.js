@@ -572,6 +572,67 @@ function initRepository() {
       }
     });
 
+    // Code comments of pull request reviews
+    var findLineRow = function($box, line) {
+      var selector =
+        line > 0
+          ? ".lines-num-new[data-line-number=" + line + "]"
+          : ".lines-num-old[data-line-number=" + -line + "]";
+      return $box.find(selector).first().parent();
+    };
+    $(".code-comment-thread").each(function() {
+      var $thread = $(this);
+      var $box = $thread.closest(".diff-file-box");
+      var $row = findLineRow($box, $thread.data("line"));
+      if ($row.length === 0) {
+        return;
+      }
+      var $cell = $('<td class="code-comments"></td>').attr(
+        "colspan",
+        $row.children().length
+      );
+      $row.after($('<tr class="code-comment-row"></tr>').append($cell));
+      $cell.append($thread);
+    });
+    $(".code-comment-list").each(function() {
+      if ($(this).children().length === 0) {
+        $(this).remove();
+      }
+    });
+
+    var $codeCommentForm = $("#code-comment-form");
+    if ($codeCommentForm.length > 0) {
+      $(".diff-file-box .lines-num[data-line-number]").click(function() {
+        var $num = $(this);
+        var $row = $num.parent();
+        if ($row.next().is(".code-comment-form-row")) {
+          $row.next().find("textarea").focus();
+          return;
+        }
+
+        var line = parseInt($num.data("line-number"));
+        if ($num.hasClass("lines-num-old")) {
+          line = -line;
+        }
+        var $form = $($codeCommentForm.html());
+        $form
+          .find("input[name=tree_path]")
+          .val($num.closest(".diff-file-box").data("tree-path"));
+        $form.find("input[name=line]").val(line);
+        $form.find(".cancel").click(function() {
+          $(this)
+            .closest(".code-comment-form-row")
+            .remove();
+        });
+
+        var $cell = $('<td class="code-comments"></td>')
+          .attr("colspan", $row.children().length)
+          .append($form);
+        $row.after($('<tr class="code-comment-form-row"></tr>').append($cell));
+        $form.find("textarea").focus();
+      });
+    }
+
     $(window)
       .on("hashchange", function(e) {
         $(".diff-file-box .lines-code.active").removeClass("active");
@@ -615,6 +676,11 @@ function initRepository() {
       } else {
         $(".commit.description.field").hide();
       }
+      if ($(this).val() === "squash") {
+        $(".squash.message.field").show();
+      } else {
+        $(".squash.message.field").hide();
+      }
     });
   }
 }
@@ -1050,6 +1116,8 @@ function initAdmin() {
       $(".smtp").hide();
       $(".pam").hide();
       $(".github").hide();
+      $(".oidc").hide();
+      $(".saml").hide();
       $(".has-tls").hide();
 
       var authType = $(this).val();
@@ -1071,6 +1139,13 @@ function initAdmin() {
           $(".github").show();
           $(".has-tls").show();
           break;
+        case "7": // OpenID Connect
+          $(".oidc").show();
+          $(".has-tls").show();
+          break;
+        case "8": // SAML 2.0
+          $(".saml").show();
+          break;
       }
 
       if (authType == "2" || authType == "5") {
@@ -1337,6 +1412,117 @@ function initUserSettings() {
       }
     });
   }
+
+  // Security
+  if ($(".user.settings.security").length > 0) {
+    initWebAuthnRegistration();
+  }
+}
+
+// WebAuthn binary values are exchanged with the server as unpadded base64url.
+function base64URLToBuffer(s) {
+  var binary = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+  var bytes = new Uint8Array(binary.length);
+  for (var i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function bufferToBase64URL(buffer) {
+  var bytes = new Uint8Array(buffer);
+  var binary = "";
+  for (var i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary)
+    .replace(/\+/g, "-")
+    .replace(/\//g, "_")
+    .replace(/=+$/, "");
+}
+
+// postWebAPI posts the JSON payload to the web API, and rejects with the
+// message of the error response.
+function postWebAPI(url, payload) {
+  return fetch(url, {
+    method: "POST",
+    credentials: "same-origin",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(payload)
+  }).then(function(res) {
+    if (res.status === 204) {
+      return null;
+    }
+    return res
+      .json()
+      .catch(function() {
+        return {};
+      })
+      .then(function(body) {
+        if (!res.ok) {
+          var message = body.error;
+          for (var field in body.fields || {}) {
+            message = message || body.fields[field];
+          }
+          throw new Error(message || "");
+        }
+        return body;
+      });
+  });
+}
+
+function initWebAuthnRegistration() {
+  var $form = $("#webauthn-register");
+  if ($form.length === 0) {
+    return;
+  }
+  if (!window.PublicKeyCredential) {
+    $("#webauthn-unsupported").show();
+    $form.find("button").addClass("disabled");
+    return;
+  }
+
+  var url = $form.data("url");
+  var $error = $("#webauthn-error");
+  var $button = $form.find("button");
+  $form.submit(function(e) {
+    e.preventDefault();
+    $error.hide();
+    $button.addClass("loading disabled");
+
+    postWebAPI(url + "/options", {
+      passwordless: $("#webauthn-passwordless").is(":checked"),
+      password: $("#webauthn-password").val(),
+      passcode: $("#webauthn-passcode").val() || ""
+    })
+      .then(function(options) {
+        options.challenge = base64URLToBuffer(options.challenge);
+        options.user.id = base64URLToBuffer(options.user.id);
+        options.excludeCredentials.forEach(function(credential) {
+          credential.id = base64URLToBuffer(credential.id);
+        });
+        return navigator.credentials.create({ publicKey: options });
+      })
+      .then(function(credential) {
+        return postWebAPI(url, {
+          name: $("#webauthn-name").val(),
+          clientDataJSON: bufferToBase64URL(credential.response.clientDataJSON),
+          attestationObject: bufferToBase64URL(
+            credential.response.attestationObject
+          )
+        });
+      })
+      .then(function() {
+        window.location.reload();
+      })
+      .catch(function(err) {
+        // Errors of the browser, e.g. the user cancelled the ceremony, are
+        // not worth showing as-is.
+        var message = err instanceof DOMException ? "" : err.message;
+        $error.text(message || $error.data("default")).show();
+        $button.removeClass("loading disabled");
+      });
+  });
 }
 
 function initRepositoryCollaboration() {
@@ -1613,6 +1799,10 @@ $(document).ready(function() {
     } else {
       $($(this).data("target")).addClass("disabled");
       $($(this).data("uncheck")).prop("checked", false);
+      // Keep targets shared with other checked boxes enabled.
+      $(".enable-system:checked").each(function() {
+        $($(this).data("target")).removeClass("disabled");
+      });
     }
   });
   $(".enable-system-radio").change(function() {
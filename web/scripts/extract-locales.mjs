@@ -79,6 +79,13 @@ const KEYS = [
   "auth.sign_up_failed",
   "auth.sign_in_submitting",
   "auth.sign_in_failed",
+  "auth.sign_in_with",
+  "auth.oidc_failed",
+  "auth.oidc_user_exists",
+  "auth.saml_failed",
+  "auth.saml_user_exists",
+  "auth.sign_in_with_passkey",
+  "auth.passkey_sign_in_failed",
   "auth.show_password",
   "auth.hide_password",
   "auth.back_to_sign_in",
@@ -105,6 +112,10 @@ const KEYS = [
   "auth.mfa_verifying",
   "auth.mfa_session_expired",
   "auth.mfa_verify_failed",
+  "auth.mfa_security_key",
+  "auth.mfa_use_security_key",
+  "auth.mfa_verify_security_key",
+  "auth.mfa_invalid_security_key",
   "auth.activate_your_account",
   "auth.resend_rate_limited",
   "auth.send_activation_email",
@@ -0,0 +1,68 @@
+// Helpers for the WebAuthn authentication ceremony. Binary values are
+// exchanged with the server as unpadded base64url, since JSON has no way to
+// carry an ArrayBuffer.
+
+export interface RequestOptionsJSON {
+  challenge: string;
+  timeout: number;
+  rpId: string;
+  allowCredentials: { type: "public-key"; id: string }[];
+  userVerification: UserVerificationRequirement;
+}
+
+export interface AssertionJSON {
+  id: string;
+  clientDataJSON: string;
+  authenticatorData: string;
+  signature: string;
+  userHandle: string;
+}
+
+function base64URLToBuffer(s: string): ArrayBuffer {
+  const binary = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function bufferToBase64URL(buffer: ArrayBuffer): string {
+  let binary = "";
+  for (const b of new Uint8Array(buffer)) {
+    binary += String.fromCharCode(b);
+  }
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+export function isWebAuthnSupported(): boolean {
+  return typeof window.PublicKeyCredential !== "undefined";
+}
+
+// getAssertion asks the browser to sign the challenge in the options with one
+// of the allowed credentials, or with any discoverable credential of the site
+// when none is listed. It rejects when the user cancels or no authenticator
+// is available.
+export async function getAssertion(options: RequestOptionsJSON): Promise<AssertionJSON> {
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      challenge: base64URLToBuffer(options.challenge),
+      timeout: options.timeout,
+      rpId: options.rpId,
+      allowCredentials: options.allowCredentials.map((c) => ({ type: c.type, id: base64URLToBuffer(c.id) })),
+      userVerification: options.userVerification,
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error("No credential was returned");
+  }
+
+  const response = credential.response as AuthenticatorAssertionResponse;
+  return {
+    id: bufferToBase64URL(credential.rawId),
+    clientDataJSON: bufferToBase64URL(response.clientDataJSON),
+    authenticatorData: bufferToBase64URL(response.authenticatorData),
+    signature: bufferToBase64URL(response.signature),
+    userHandle: response.userHandle ? bufferToBase64URL(response.userHandle) : "",
+  };
+}
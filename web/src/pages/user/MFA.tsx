@@ -8,13 +8,45 @@ import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { usePageTitle } from "@/lib/page-title";
 import { subUrl } from "@/lib/url";
+import { type RequestOptionsJSON, getAssertion, isWebAuthnSupported } from "@/lib/webauthn";
+
+export interface MFAPage {
+  pending: boolean;
+  // Whether the user can verify with a TOTP passcode or a recovery code.
+  passcode: boolean;
+  // Whether the user can verify with a WebAuthn security key or passkey.
+  securityKey: boolean;
+}
 
 interface MFAErrorResponse {
   error?: string;
   fields?: Record<string, string | null>;
 }
 
-type Mode = "passcode" | "recovery";
+type Mode = "passcode" | "recovery" | "securityKey";
+
+const MODE_SWITCH_LABELS: Record<Mode, string> = {
+  passcode: "auth.mfa_use_passcode",
+  recovery: "auth.mfa_use_recovery_code",
+  securityKey: "auth.mfa_use_security_key",
+};
+
+const jsonPost: RequestInit = {
+  method: "POST",
+  credentials: "same-origin",
+  headers: { "Content-Type": "application/json" },
+};
+
+// verifySecurityKey runs the WebAuthn authentication ceremony with the
+// credentials of the user that is signing in.
+async function verifySecurityKey(): Promise<Response> {
+  const res = await fetch(subUrl("/api/web/user/mfa/webauthn/options"), { method: "POST", credentials: "same-origin" });
+  if (!res.ok) {
+    return res;
+  }
+  const assertion = await getAssertion((await res.json()) as RequestOptionsJSON);
+  return fetch(subUrl("/api/web/user/mfa/webauthn"), { ...jsonPost, body: JSON.stringify(assertion) });
+}
 
 const route = getRouteApi("/user/mfa");
 
@@ -24,9 +56,13 @@ export function MFA() {
   const navigate = useNavigate();
   // When no challenge is pending the loader has already kicked off a full
   // navigation away; the early return keeps this page from flashing.
-  const { pending } = route.useLoaderData();
+  const { pending, passcode: passcodeEnabled, securityKey: securityKeyEnabled } = route.useLoaderData();
+  const modes: Mode[] = [
+    ...(securityKeyEnabled && isWebAuthnSupported() ? (["securityKey"] as const) : []),
+    ...(passcodeEnabled ? (["passcode", "recovery"] as const) : []),
+  ];
 
-  const [mode, setMode] = useState<Mode>("passcode");
+  const [mode, setMode] = useState<Mode>(modes[0] ?? "passcode");
   const [passcode, setPasscode] = useState("");
   const [recoveryCode, setRecoveryCode] = useState("");
   const [submitting, setSubmitting] = useState(false);
@@ -42,7 +78,7 @@ export function MFA() {
   useEffect(() => {
     if (!pending) return;
     if (mode === "passcode") passcodeRef.current?.focus();
-    else recoveryRef.current?.focus();
+    else if (mode === "recovery") recoveryRef.current?.focus();
   }, [mode, pending]);
 
   if (!pending) {
@@ -62,14 +98,14 @@ export function MFA() {
     setSubmitting(true);
     void (async () => {
       try {
-        const url = mode === "passcode" ? subUrl("/api/web/user/mfa") : subUrl("/api/web/user/mfa/recovery");
-        const body = mode === "passcode" ? JSON.stringify({ passcode }) : JSON.stringify({ recoveryCode });
-        const res = await fetch(url, {
-          method: "POST",
-          credentials: "same-origin",
-          headers: { "Content-Type": "application/json" },
-          body,
-        });
+        let res: Response;
+        if (mode === "securityKey") {
+          res = await verifySecurityKey();
+        } else {
+          const url = mode === "passcode" ? subUrl("/api/web/user/mfa") : subUrl("/api/web/user/mfa/recovery");
+          const body = mode === "passcode" ? JSON.stringify({ passcode }) : JSON.stringify({ recoveryCode });
+          res = await fetch(url, { ...jsonPost, body });
+        }
         if (!res.ok) {
           const errBody = (await res.json().catch(() => ({}))) as MFAErrorResponse;
           if (res.status === 401 && !errBody.fields) {
@@ -88,7 +124,7 @@ export function MFA() {
           // past the commit with rAF.
           requestAnimationFrame(() => {
             if (mode === "passcode") passcodeRef.current?.focus();
-            else recoveryRef.current?.focus();
+            else if (mode === "recovery") recoveryRef.current?.focus();
           });
           return;
         }
@@ -103,7 +139,7 @@ export function MFA() {
 
   const isPasscode = mode === "passcode";
   const inputId = isPasscode ? "passcode" : "recovery_code";
-  const inputErrorKey = isPasscode ? "passcode" : "recoveryCode";
+  const inputErrorKey = mode === "securityKey" ? "securityKey" : isPasscode ? "passcode" : "recoveryCode";
 
   return (
     <main className="flex flex-1 items-center justify-center px-4 py-10 sm:px-6 sm:py-16">
@@ -124,7 +160,14 @@ export function MFA() {
               )}
 
               <div className="flex flex-col gap-4">
-                {isPasscode ? (
+                {mode === "securityKey" ? (
+                  <div className="flex flex-col gap-1.5">
+                    <p className="text-sm text-(--color-muted-foreground)">{t("auth.mfa_security_key")}</p>
+                    {fieldErrors[inputErrorKey] && (
+                      <p className="text-sm text-(--color-destructive)">{fieldErrors[inputErrorKey]}</p>
+                    )}
+                  </div>
+                ) : isPasscode ? (
                   <div className="flex flex-col gap-1.5">
                     <Label htmlFor={inputId}>{t("auth.mfa_passcode")}</Label>
                     <Input
@@ -177,18 +220,27 @@ export function MFA() {
 
                 <div className="mt-2 flex flex-col gap-3">
                   <Button type="submit" disabled={submitting} tabIndex={2} className="w-full">
-                    {submitting ? t("auth.mfa_verifying") : t("auth.mfa_verify")}
-                  </Button>
-                  <Button
-                    type="button"
-                    variant="link"
-                    size="inline"
-                    tabIndex={3}
-                    className="self-center"
-                    onClick={() => switchMode(isPasscode ? "recovery" : "passcode")}
-                  >
-                    {isPasscode ? t("auth.mfa_use_recovery_code") : t("auth.mfa_use_passcode")}
+                    {submitting
+                      ? t("auth.mfa_verifying")
+                      : mode === "securityKey"
+                        ? t("auth.mfa_verify_security_key")
+                        : t("auth.mfa_verify")}
                   </Button>
+                  {modes
+                    .filter((m) => m !== mode)
+                    .map((m) => (
+                      <Button
+                        key={m}
+                        type="button"
+                        variant="link"
+                        size="inline"
+                        tabIndex={3}
+                        className="self-center"
+                        onClick={() => switchMode(m)}
+                      >
+                        {t(MODE_SWITCH_LABELS[m])}
+                      </Button>
+                    ))}
                   <Button variant="link" size="inline" asChild className="self-center">
                     <Link
                       to="/user/sign-in"
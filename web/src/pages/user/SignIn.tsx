@@ -10,6 +10,7 @@ import { Label } from "@/components/ui/label";
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import { usePageTitle } from "@/lib/page-title";
 import { subUrl } from "@/lib/url";
+import { type RequestOptionsJSON, getAssertion, isWebAuthnSupported } from "@/lib/webauthn";
 
 export interface LoginSource {
   id: number;
@@ -19,6 +20,8 @@ export interface LoginSource {
 
 export interface SignInPage {
   loginSources: LoginSource[];
+  oidcSources: LoginSource[];
+  samlSources: LoginSource[];
 }
 
 interface SignInResponse {
@@ -33,13 +36,47 @@ interface SignInErrorResponse {
 // Field display order; the first key with a server-side error gets focus.
 const FIELD_ORDER = ["username", "password"] as const;
 
+// Errors of signing in through an OpenID Provider or a SAML identity provider,
+// passed back by the server in the ?error= query parameter.
+const EXTERNAL_ERRORS = ["oidc_failed", "oidc_user_exists", "saml_failed", "saml_user_exists"] as const;
+
+function externalError(): (typeof EXTERNAL_ERRORS)[number] | null {
+  const error = new URLSearchParams(window.location.search).get("error");
+  return EXTERNAL_ERRORS.find((e) => e === error) ?? null;
+}
+
+function externalSignInUrl(protocol: "oidc" | "saml", id: number): string {
+  const redirectTo = new URLSearchParams(window.location.search).get("redirect_to");
+  const url = subUrl(`/api/web/user/${protocol}/${id}`);
+  return redirectTo ? url + "?redirect_to=" + encodeURIComponent(redirectTo) : url;
+}
+
+// signInWithPasskey runs the WebAuthn authentication ceremony with any
+// passkey the browser has for the site.
+async function signInWithPasskey(): Promise<Response> {
+  const res = await fetch(subUrl("/api/web/user/sign-in/passkey/options"), {
+    method: "POST",
+    credentials: "same-origin",
+  });
+  if (!res.ok) {
+    return res;
+  }
+  const assertion = await getAssertion((await res.json()) as RequestOptionsJSON);
+  return fetch(subUrl("/api/web/user/sign-in/passkey"), {
+    method: "POST",
+    credentials: "same-origin",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(assertion),
+  });
+}
+
 const route = getRouteApi("/user/sign-in");
 
 export function SignIn() {
   const { t } = useTranslation();
   usePageTitle(t("sign_in"));
   const navigate = useNavigate();
-  const { loginSources } = route.useLoaderData();
+  const { loginSources, oidcSources, samlSources } = route.useLoaderData();
   const defaultSource = loginSources.find((s) => s.isDefault);
 
   const [username, setUsername] = useState("");
@@ -47,11 +84,37 @@ export function SignIn() {
   const [loginSource, setLoginSource] = useState<number>(defaultSource?.id ?? 0);
   const [showPassword, setShowPassword] = useState(false);
   const [submitting, setSubmitting] = useState(false);
-  const [formError, setFormError] = useState<string | null>(null);
+  const [formError, setFormError] = useState<string | null>(() => {
+    const error = externalError();
+    return error ? t(`auth.${error}`) : null;
+  });
   const [fieldErrors, setFieldErrors] = useState<Record<string, string | null>>({});
   const usernameRef = useRef<HTMLInputElement>(null);
   const passwordRef = useRef<HTMLInputElement>(null);
 
+  function onPasskey() {
+    setFormError(null);
+    setFieldErrors({});
+    setSubmitting(true);
+    void (async () => {
+      try {
+        const res = await signInWithPasskey();
+        if (!res.ok) {
+          const body = (await res.json().catch(() => ({}))) as SignInErrorResponse;
+          setFormError(body.error ?? t("auth.passkey_sign_in_failed"));
+          setSubmitting(false);
+          return;
+        }
+        const to = new URLSearchParams(window.location.search).get("redirect_to") ?? "";
+        window.location.assign(subUrl("/redirect") + "?to=" + encodeURIComponent(to));
+      } catch {
+        // Most likely the user dismissed the browser prompt.
+        setFormError(t("auth.passkey_sign_in_failed"));
+        setSubmitting(false);
+      }
+    })();
+  }
+
   function onSubmit(event: React.FormEvent<HTMLFormElement>) {
     event.preventDefault();
     setFormError(null);
@@ -233,6 +296,29 @@ export function SignIn() {
                   <Button type="submit" disabled={submitting} tabIndex={5} className="w-full">
                     {submitting ? t("auth.sign_in_submitting") : t("sign_in")}
                   </Button>
+                  {[
+                    ...oidcSources.map((s) => ({ ...s, href: externalSignInUrl("oidc", s.id) })),
+                    ...samlSources.map((s) => ({ ...s, href: externalSignInUrl("saml", s.id) })),
+                  ].map((s) => (
+                    <Button key={s.id} variant="outline" asChild className="w-full">
+                      <a
+                        href={s.href}
+                        tabIndex={submitting ? -1 : 5}
+                        aria-disabled={submitting || undefined}
+                        className={submitting ? "pointer-events-none opacity-50" : undefined}
+                        onClick={(e) => {
+                          if (submitting) e.preventDefault();
+                        }}
+                      >
+                        {t("auth.sign_in_with", { name: s.name })}
+                      </a>
+                    </Button>
+                  ))}
+                  {isWebAuthnSupported() && (
+                    <Button type="button" variant="outline" tabIndex={6} className="w-full" onClick={onPasskey}>
+                      {t("auth.sign_in_with_passkey")}
+                    </Button>
+                  )}
                   <Button variant="link" size="inline" asChild className="self-center">
                     <a
                       href={subUrl("/user/sign-up")}
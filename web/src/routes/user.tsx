@@ -4,7 +4,7 @@ import { loaderResponseError } from "@/lib/loader-error";
 import { subUrl } from "@/lib/url";
 import type { UserInfo } from "@/lib/user-info";
 import { Activate, type ActivatePage } from "@/pages/user/Activate";
-import { MFA } from "@/pages/user/MFA";
+import { MFA, type MFAPage } from "@/pages/user/MFA";
 import { ResetPassword, type ResetPasswordPage } from "@/pages/user/ResetPassword";
 import { SignIn, type SignInPage } from "@/pages/user/SignIn";
 import { SignUp, type SignUpPage } from "@/pages/user/SignUp";
@@ -105,19 +105,20 @@ export function createUserRoutes(rootRoute: AnyRoute) {
   const mfaRoute = createRoute({
     getParentRoute: () => rootRoute,
     path: "/user/mfa",
-    loader: async (): Promise<{ pending: boolean }> => {
+    loader: async (): Promise<MFAPage> => {
       const res = await fetch(subUrl("/api/web/user/mfa"), { credentials: "same-origin" });
       if (res.status === 404) {
         // No pending MFA challenge. Fall through to the server-rendered home,
         // which will redirect to sign-in for anonymous visitors and to the
         // dashboard for signed-in ones.
         window.location.assign(subUrl("/"));
-        return { pending: false };
+        return { pending: false, passcode: false, securityKey: false };
       }
       if (!res.ok) {
         throw await loaderResponseError(res);
       }
-      return { pending: true };
+      const data = (await res.json()) as Omit<MFAPage, "pending">;
+      return { pending: true, ...data };
     },
     component: MFA,
   });